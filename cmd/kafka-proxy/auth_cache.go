@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/grepplabs/kafka-proxy/proxy"
+)
+
+type authCacheResponse struct {
+	Invalidated int `json:"invalidated"`
+}
+
+// authCacheHandler invalidates the cached auth decisions of the principal query parameter or all cached decisions on DELETE.
+// Requests must send the token of the token file as bearer token.
+func authCacheHandler(tokenFile string, authCache *proxy.AuthCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizedBearer(r, tokenFile) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", http.MethodDelete)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// the invalidation is logged with the pseudonymized principal
+		invalidated := authCache.Invalidate(r.URL.Query().Get("principal"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(authCacheResponse{Invalidated: invalidated})
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticPasswordAuthenticator struct{}
+
+func (staticPasswordAuthenticator) Authenticate(username, password string) (bool, int32, error) {
+	return true, 0, nil
+}
+
+func TestAuthCacheHandler(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "auth-cache")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	a.Nil(ioutil.WriteFile(tokenFile, []byte("secret"), 0600))
+
+	authCache := proxy.NewAuthCache(time.Minute, 0, 10)
+	authenticator := authCache.PasswordAuthenticator("local", staticPasswordAuthenticator{})
+	for _, user := range []string{"alice", "bob"} {
+		_, _, _ = authenticator.Authenticate(user, "secret")
+	}
+	handler := authCacheHandler(tokenFile, authCache)
+	request := func(method string, target string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	a.Equal(http.StatusUnauthorized, request(http.MethodDelete, "/api/auth-cache", "wrong").Code)
+	a.Equal(http.StatusMethodNotAllowed, request(http.MethodGet, "/api/auth-cache", "secret").Code)
+
+	w := request(http.MethodDelete, "/api/auth-cache?principal=alice", "secret")
+	a.Equal(http.StatusOK, w.Code)
+	a.JSONEq(`{"invalidated":1}`, w.Body.String())
+
+	w = request(http.MethodDelete, "/api/auth-cache", "secret")
+	a.JSONEq(`{"invalidated":1}`, w.Body.String())
+
+	// nil cache
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/api/auth-cache", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	authCacheHandler(tokenFile, nil)(w, r)
+	a.JSONEq(`{"invalidated":0}`, w.Body.String())
+}
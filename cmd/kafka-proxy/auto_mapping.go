@@ -0,0 +1,116 @@
+package server
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// autoMapper generates the mappings of the brokers in the cluster metadata and applies them with the reloader
+type autoMapper struct {
+	mapping  *proxy.AutoMapping
+	client   *proxy.Client
+	reloader *reloader
+	// addresses of the bootstrap servers
+	seeds    []string
+	interval time.Duration
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newAutoMapper(cfg *config.Config, client *proxy.Client, reloader *reloader) *autoMapper {
+	return &autoMapper{
+		mapping:  proxy.NewAutoMapping(cfg.Proxy.AutoMapping.PortOffset, cfg.Proxy.AutoMapping.ListenerAddress, cfg.Proxy.AutoMapping.AdvertisedAddress),
+		client:   client,
+		reloader: reloader,
+		seeds:    brokerAddresses(cfg.Proxy.BootstrapServers),
+		interval: cfg.Proxy.AutoMapping.RefreshInterval,
+		closed:   make(chan struct{}),
+	}
+}
+
+// run refreshes the mappings until closed. Failed refreshes keep the current mappings and are retried after the interval
+func (m *autoMapper) run() error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		if err := m.refresh(); err != nil {
+			logrus.Warnf("Broker mappings are not refreshed: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-m.closed:
+			return nil
+		}
+	}
+}
+
+func (m *autoMapper) close() {
+	m.closeOnce.Do(func() { close(m.closed) })
+}
+
+func (m *autoMapper) refresh() error {
+	// the previously discovered brokers are asked when the bootstrap servers are unavailable
+	addresses := append([]string{}, m.seeds...)
+	addresses = append(addresses, brokerAddresses(m.reloader.currentAutoMappings())...)
+	brokers, err := m.client.FetchBrokers(addresses)
+	if err != nil {
+		return err
+	}
+	if len(brokers) == 0 {
+		return errors.New("metadata contains no brokers")
+	}
+	mappings, err := m.mapping.Mappings(brokers)
+	if err != nil {
+		return err
+	}
+	return m.reloader.reloadAutoMappings(mappings)
+}
+
+// mergeAutoMappings adds the generated mappings of the brokers without a bootstrap server mapping
+func mergeAutoMappings(bootstrapServers []config.ListenerConfig, autoMappings []config.ListenerConfig) []config.ListenerConfig {
+	mapped := make(map[string]bool)
+	for _, v := range bootstrapServers {
+		mapped[v.BrokerAddress] = true
+	}
+	merged := append([]config.ListenerConfig{}, bootstrapServers...)
+	for _, v := range autoMappings {
+		if !mapped[v.BrokerAddress] {
+			merged = append(merged, v)
+		}
+	}
+	return merged
+}
+
+func (r *reloader) currentAutoMappings() []config.ListenerConfig {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.autoMappings
+}
+
+// reloadAutoMappings reloads the configuration with the changed generated mappings
+func (r *reloader) reloadAutoMappings(mappings []config.ListenerConfig) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if reflect.DeepEqual(r.autoMappings, mappings) {
+		return nil
+	}
+	for _, v := range mappings {
+		logrus.Infof("Generated mapping of broker %s to %s advertised as %s", v.BrokerAddress, v.ListenerAddress, v.AdvertisedAddress)
+	}
+	previous := r.autoMappings
+	r.autoMappings = mappings
+	if err := r.apply(); err != nil {
+		r.autoMappings = previous
+		return err
+	}
+	return nil
+}
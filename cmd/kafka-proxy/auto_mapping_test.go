@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestMergeAutoMappings(t *testing.T) {
+	bootstrapServers := []config.ListenerConfig{
+		{BrokerAddress: "kafka-0:9092", ListenerAddress: "0.0.0.0:32500", AdvertisedAddress: "0.0.0.0:32500"},
+	}
+	autoMappings := []config.ListenerConfig{
+		{BrokerAddress: "kafka-0:9092", ListenerAddress: "0.0.0.0:32400", AdvertisedAddress: "0.0.0.0:32400"},
+		{BrokerAddress: "kafka-1:9092", ListenerAddress: "0.0.0.0:32401", AdvertisedAddress: "0.0.0.0:32401"},
+	}
+	merged := mergeAutoMappings(bootstrapServers, autoMappings)
+	// bootstrap server mappings take precedence
+	assert.Equal(t, append(bootstrapServers, autoMappings[1]), merged)
+}
+
+func TestReloadAutoMappings(t *testing.T) {
+	setupBootstrapServersMappingTest()
+	a := assert.New(t)
+
+	bootstrapAddress, generatedAddress := freeAddress(t), freeAddress(t)
+	args := []string{"--bootstrap-server-mapping", "kafka-0:9092," + bootstrapAddress}
+	cfg, err := loadServerConfig(args, "", nil)
+	a.Nil(err)
+	listeners, err := proxy.NewListeners(cfg)
+	a.Nil(err)
+	_, err = listeners.ListenInstances(cfg.Proxy.BootstrapServers)
+	a.Nil(err)
+	client, err := proxy.NewClient(proxy.NewConnSet(), cfg, listeners.GetNetAddressMapping, nil, nil, nil, nil, nil, nil, nil)
+	a.Nil(err)
+	r := &reloader{args: args, listeners: listeners, client: client}
+
+	generated := []config.ListenerConfig{{BrokerAddress: "kafka-1:9092", ListenerAddress: generatedAddress, AdvertisedAddress: generatedAddress}}
+	a.Nil(r.reloadAutoMappings(generated))
+	a.Equal(generated, r.currentAutoMappings())
+	conn, err := net.Dial("tcp", generatedAddress)
+	a.Nil(err)
+	_ = conn.Close()
+
+	// generated mapping is kept on reload
+	a.Nil(r.reload())
+	conn, err = net.Dial("tcp", generatedAddress)
+	a.Nil(err)
+	_ = conn.Close()
+
+	// removed broker
+	a.Nil(r.reloadAutoMappings([]config.ListenerConfig{}))
+	_, err = net.Dial("tcp", generatedAddress)
+	a.NotNil(err)
+	client.Close()
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/pkg/errors"
+)
+
+type bootstrapListener struct {
+	BrokerAddress     string `json:"broker_address"`
+	ListenerAddress   string `json:"listener_address"`
+	AdvertisedAddress string `json:"advertised_address"`
+}
+
+type bootstrapResponse struct {
+	// bootstrap.servers of the clients
+	BootstrapServers string              `json:"bootstrap_servers"`
+	Listeners        []bootstrapListener `json:"listeners"`
+}
+
+// bootstrapServers returns the distinct advertised addresses of the listeners comma separated
+func bootstrapServers(listeners []config.ListenerConfig) string {
+	addresses := make([]string, 0, len(listeners))
+	seen := make(map[string]struct{})
+	for _, v := range listeners {
+		if _, ok := seen[v.AdvertisedAddress]; ok {
+			continue
+		}
+		seen[v.AdvertisedAddress] = struct{}{}
+		addresses = append(addresses, v.AdvertisedAddress)
+	}
+	return strings.Join(addresses, ",")
+}
+
+// bootstrapHandler returns the listeners of the bootstrap server mappings with the ports assigned by the OS on GET
+func bootstrapHandler(bootstrapListeners func() []config.ListenerConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		listeners := bootstrapListeners()
+		response := bootstrapResponse{BootstrapServers: bootstrapServers(listeners), Listeners: make([]bootstrapListener, 0, len(listeners))}
+		for _, v := range listeners {
+			response.Listeners = append(response.Listeners, bootstrapListener{BrokerAddress: v.BrokerAddress, ListenerAddress: v.ListenerAddress, AdvertisedAddress: v.AdvertisedAddress})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+// writeBootstrapFile replaces the file with the comma separated advertised addresses, so readers never see a partial file
+func writeBootstrapFile(file string, listeners []config.ListenerConfig) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file))
+	if err != nil {
+		return errors.Wrap(err, "bootstrap file")
+	}
+	_, err = tmp.WriteString(bootstrapServers(listeners) + "\n")
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return errors.Wrap(err, "bootstrap file")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testBootstrapListeners = []config.ListenerConfig{
+	{BrokerAddress: "kafka-0:9092", ListenerAddress: "127.0.0.1:41001", AdvertisedAddress: "127.0.0.1:41001"},
+	{BrokerAddress: "kafka-1:9092", ListenerAddress: "127.0.0.1:41002", AdvertisedAddress: "127.0.0.1:41002"},
+	{BrokerAddress: "kafka-1:9093", ListenerAddress: "127.0.0.1:41002", AdvertisedAddress: "127.0.0.1:41002"},
+}
+
+func TestBootstrapHandler(t *testing.T) {
+	a := assert.New(t)
+
+	handler := bootstrapHandler(func() []config.ListenerConfig { return testBootstrapListeners })
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/bootstrap", nil))
+	a.Equal(http.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/bootstrap", nil))
+	a.Equal(http.StatusOK, w.Code)
+	var response bootstrapResponse
+	a.Nil(json.Unmarshal(w.Body.Bytes(), &response))
+	a.Equal("127.0.0.1:41001,127.0.0.1:41002", response.BootstrapServers)
+	a.Len(response.Listeners, 3)
+	a.Equal(bootstrapListener{BrokerAddress: "kafka-0:9092", ListenerAddress: "127.0.0.1:41001", AdvertisedAddress: "127.0.0.1:41001"}, response.Listeners[0])
+}
+
+func TestWriteBootstrapFile(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "bootstrap")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "bootstrap-servers")
+
+	a.Nil(writeBootstrapFile(file, testBootstrapListeners[:1]))
+	a.Nil(writeBootstrapFile(file, testBootstrapListeners))
+	data, err := ioutil.ReadFile(file)
+	a.Nil(err)
+	a.Equal("127.0.0.1:41001,127.0.0.1:41002\n", string(data))
+
+	files, err := ioutil.ReadDir(dir)
+	a.Nil(err)
+	a.Len(files, 1)
+
+	a.NotNil(writeBootstrapFile(filepath.Join(dir, "missing", "bootstrap-servers"), testBootstrapListeners))
+}
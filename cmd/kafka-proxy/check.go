@@ -0,0 +1,213 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/proxy"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	checkConfig = new(config.Config)
+
+	checkBootstrapServersMapping = make([]string, 0)
+	checkExternalServersMapping  = make([]string, 0)
+	checkDialAddressMapping      = make([]string, 0)
+
+	checkConfigFile string
+	checkDial       bool
+)
+
+var Check = &cobra.Command{
+	Use:   "check",
+	Short: "Validate the kafka-proxy server configuration",
+	Long: "Validate the mappings, certificates and plugin definitions of the server configuration given by the server flags, " +
+		"the config file and the environment. With --dial the brokers are connected and the TLS handshake and the SASL authentication are completed. " +
+		"The command exits non-zero when a check fails.",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCheck(cmd.Flags(), os.Stdout)
+	},
+}
+
+func init() {
+	initCheckFlags()
+}
+
+func initCheckFlags() {
+	flags := Check.Flags()
+	addServerFlags(flags, checkConfig, &checkBootstrapServersMapping, &checkExternalServersMapping, &checkDialAddressMapping)
+	flags.StringVar(&checkConfigFile, "config-file", "", "YAML (.yaml, .yml) or TOML (.toml) file with options named like the flags")
+	flags.BoolVar(&checkDial, "dial", false, "Connect to the brokers and complete the TLS handshake and the SASL authentication")
+}
+
+// checkReport prints the results of the checks
+type checkReport struct {
+	out io.Writer
+	// kind of the first failed check
+	failedKind string
+	failed     int
+}
+
+func (r *checkReport) ok(format string, args ...interface{}) {
+	fmt.Fprintf(r.out, "[OK]   %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *checkReport) warn(format string, args ...interface{}) {
+	fmt.Fprintf(r.out, "[WARN] %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *checkReport) fail(kind string, err error) {
+	fmt.Fprintf(r.out, "[FAIL] %v\n", err)
+	if r.failed == 0 {
+		r.failedKind = kind
+	}
+	r.failed++
+}
+
+func (r *checkReport) result() error {
+	if r.failed == 0 {
+		return nil
+	}
+	return &StartupError{Kind: r.failedKind, Err: errors.Errorf("%d check(s) failed", r.failed)}
+}
+
+func runCheck(flags *pflag.FlagSet, out io.Writer) error {
+	report := &checkReport{out: out}
+	cfg := checkConfig
+
+	if checkConfigFile != "" {
+		if err := applyConfigFile(flags, checkConfigFile); err != nil {
+			report.fail(ErrorKindConfig, err)
+			return report.result()
+		}
+		report.ok("config file %s", checkConfigFile)
+	}
+	err := initConfig(cfg,
+		getOrEnvStringSlice(checkBootstrapServersMapping, "BOOTSTRAP_SERVER_MAPPING"),
+		getOrEnvStringSlice(checkExternalServersMapping, "EXTERNAL_SERVER_MAPPING"),
+		getOrEnvStringSlice(checkDialAddressMapping, "DIAL_ADDRESS_MAPPING"))
+	if err != nil {
+		report.fail(ErrorKindConfig, errors.Wrap(err, "configuration"))
+		return report.result()
+	}
+	report.ok("configuration")
+	for _, listener := range cfg.Proxy.BootstrapServers {
+		report.ok("mapping %s -> %s (advertised %s)", listener.ListenerAddress, listener.BrokerAddress, listener.AdvertisedAddress)
+	}
+	for _, listener := range cfg.Proxy.ExternalServers {
+		report.ok("external mapping %s -> %s", listener.ListenerAddress, listener.BrokerAddress)
+	}
+	for _, mapping := range cfg.Proxy.DialAddressMappings {
+		report.ok("dial mapping %s -> %s", mapping.SourceAddress, strings.Join(mapping.DialAddresses(), ", "))
+	}
+
+	if err = proxy.ValidateTLSConfig(cfg); err != nil {
+		report.fail(ErrorKindConfig, err)
+	} else {
+		report.ok("TLS configuration and certificates")
+	}
+	if errs := validatePluginCommands(cfg); len(errs) != 0 {
+		for _, err = range errs {
+			report.fail(ErrorKindPlugin, err)
+		}
+	} else {
+		report.ok("plugin definitions")
+	}
+
+	if checkDial && report.failed == 0 {
+		checkBrokers(cfg, report)
+	}
+	return report.result()
+}
+
+// checkBrokers connects to all brokers of the mappings
+func checkBrokers(cfg *config.Config, report *checkReport) {
+	dialCfg := *cfg
+	// authentication of the proxy clients is not used to connect to the brokers
+	dialCfg.Auth.Local.Enable = false
+	dialCfg.Auth.Gateway.Server.Enable = false
+	dialCfg.Auth.ListenerPolicies = nil
+
+	var saslTokenProvider, gatewayTokenProvider apis.TokenProvider
+	if dialCfg.Kafka.SASL.Plugin.Enable {
+		var err error
+		if saslTokenProvider, err = builtinTokenProvider(dialCfg.Kafka.SASL.Plugin.Command, dialCfg.Kafka.SASL.Plugin.Parameters); err != nil {
+			report.fail(ErrorKindPlugin, errors.Wrap(err, "SASL plugin"))
+			return
+		}
+		if saslTokenProvider == nil {
+			report.warn("SASL plugin %s is not built-in, SASL authentication is not checked", dialCfg.Kafka.SASL.Plugin.Command)
+			dialCfg.Kafka.SASL.Enable = false
+			dialCfg.Kafka.SASL.Plugin.Enable = false
+		}
+	}
+	if dialCfg.Auth.Gateway.Client.Enable {
+		var err error
+		if gatewayTokenProvider, err = builtinTokenProvider(dialCfg.Auth.Gateway.Client.Command, dialCfg.Auth.Gateway.Client.Parameters); err != nil {
+			report.fail(ErrorKindPlugin, errors.Wrap(err, "gateway client plugin"))
+			return
+		}
+		if gatewayTokenProvider == nil {
+			report.warn("gateway client plugin %s is not built-in, gateway authentication is not checked", dialCfg.Auth.Gateway.Client.Command)
+			dialCfg.Auth.Gateway.Client.Enable = false
+		}
+	}
+	client, err := proxy.NewClient(proxy.NewConnSet(), &dialCfg, nil, nil, nil, saslTokenProvider, gatewayTokenProvider, nil, nil, nil)
+	if err != nil {
+		report.fail(ErrorKindConfig, err)
+		return
+	}
+
+	var completed []string
+	if dialCfg.Kafka.TLS.Enable {
+		completed = append(completed, "TLS")
+	}
+	if dialCfg.Auth.Gateway.Client.Enable {
+		completed = append(completed, "gateway auth")
+	}
+	if dialCfg.Kafka.SASL.Enable {
+		mechanism := dialCfg.Kafka.SASL.Method
+		if dialCfg.Kafka.SASL.Plugin.Enable {
+			mechanism = dialCfg.Kafka.SASL.Plugin.Mechanism
+		}
+		completed = append(completed, "SASL "+mechanism)
+	}
+	description := ""
+	if len(completed) != 0 {
+		description = " (" + strings.Join(completed, ", ") + ")"
+	}
+	seen := make(map[string]bool)
+	for _, listener := range append(append([]config.ListenerConfig{}, cfg.Proxy.BootstrapServers...), cfg.Proxy.ExternalServers...) {
+		if seen[listener.BrokerAddress] {
+			continue
+		}
+		seen[listener.BrokerAddress] = true
+		if err = client.CheckBroker(listener.BrokerAddress); err != nil {
+			report.fail(ErrorKindUpstreamUnreachable, errors.Wrapf(err, "broker %s", listener.BrokerAddress))
+			continue
+		}
+		report.ok("broker %s%s", listener.BrokerAddress, description)
+	}
+}
+
+// builtinTokenProvider returns the built-in token provider of the command or nil for external plugins
+func builtinTokenProvider(command string, params []string) (apis.TokenProvider, error) {
+	component, err := lookupBuiltinComponent(new(apis.TokenProviderFactory), command)
+	if err != nil {
+		return nil, err
+	}
+	factory, ok := component.(apis.TokenProviderFactory)
+	if !ok {
+		return nil, nil
+	}
+	return factory.New(params)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func runCheckArgs(args ...string) (string, error) {
+	Check.ResetFlags()
+	checkConfig = new(config.Config)
+	initCheckFlags()
+	setupBootstrapServersMappingTest()
+
+	_ = Check.ParseFlags(args)
+	var out bytes.Buffer
+	err := runCheck(Check.Flags(), &out)
+	return out.String(), err
+}
+
+func TestCheckValidConfig(t *testing.T) {
+	a := assert.New(t)
+
+	out, err := runCheckArgs("--bootstrap-server-mapping", "192.168.99.100:32401,127.0.0.1:32401")
+	a.Nil(err)
+	a.Contains(out, "[OK]   configuration\n")
+	a.Contains(out, "[OK]   mapping 127.0.0.1:32401 -> 192.168.99.100:32401 (advertised 127.0.0.1:32401)\n")
+	a.Contains(out, "[OK]   plugin definitions\n")
+	a.NotContains(out, "broker")
+}
+
+func TestCheckInvalidConfig(t *testing.T) {
+	a := assert.New(t)
+
+	out, err := runCheckArgs()
+	a.NotNil(err)
+	a.Equal(ExitCodeConfig, ExitCode(err))
+	a.Contains(out, "[FAIL] configuration: list of bootstrap-server-mapping must not be empty")
+
+	out, err = runCheckArgs(
+		"--bootstrap-server-mapping", "192.168.99.100:32401,127.0.0.1:32401",
+		"--auth-local-enable",
+		"--auth-local-command", "/nonexistent/plugin",
+	)
+	a.NotNil(err)
+	a.Equal(ExitCodePlugin, ExitCode(err))
+	a.Contains(out, "[FAIL] local auth plugin: stat /nonexistent/plugin")
+	a.EqualError(err, "1 check(s) failed")
+}
+
+func TestCheckDial(t *testing.T) {
+	a := assert.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	closedAddress := closed.Addr().String()
+	_ = closed.Close()
+
+	out, err := runCheckArgs("--dial", "--bootstrap-server-mapping", l.Addr().String()+",127.0.0.1:32401")
+	a.Nil(err)
+	a.Contains(out, "[OK]   broker "+l.Addr().String()+"\n")
+
+	out, err = runCheckArgs("--dial",
+		"--bootstrap-server-mapping", l.Addr().String()+",127.0.0.1:32401",
+		"--bootstrap-server-mapping", closedAddress+",127.0.0.1:32402",
+	)
+	a.NotNil(err)
+	a.Equal(ExitCodeUpstreamUnreachable, ExitCode(err))
+	a.Contains(out, "[FAIL] broker "+closedAddress)
+}
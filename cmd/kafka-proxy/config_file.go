@@ -0,0 +1,175 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// flagEnvKeys are the environment variables of the flags. They take precedence over the config file
+var flagEnvKeys = map[string]string{
+	"bootstrap-server-mapping": "BOOTSTRAP_SERVER_MAPPING",
+	"external-server-mapping":  "EXTERNAL_SERVER_MAPPING",
+	"dial-address-mapping":     "DIAL_ADDRESS_MAPPING",
+}
+
+// applyConfigFile sets the flags from the YAML (.yaml, .yml) or TOML (.toml) config file. The options are named like the flags,
+// nested sections are joined with '-' e.g. 'tls: {enable: true}' sets --tls-enable. Flags set on the command line and
+// flags with a set environment variable are not overridden.
+func applyConfigFile(flags *pflag.FlagSet, path string) error {
+	options, err := readConfigFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "config file %s", path)
+	}
+	return applyConfigOptions(flags, "config file "+path, options)
+}
+
+// applyConfigOptions sets the flags from the options of the source like applyConfigFile
+func applyConfigOptions(flags *pflag.FlagSet, source string, options map[string]interface{}) error {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag := flags.Lookup(name)
+		if flag == nil || name == "config-file" {
+			return errors.Errorf("%s: unknown option %q", source, name)
+		}
+		if flag.Changed {
+			continue
+		}
+		if envKey, ok := flagEnvKeys[name]; ok && os.Getenv(envKey) != "" {
+			continue
+		}
+		values, err := configValues(options[name])
+		if err != nil {
+			return errors.Wrapf(err, "%s: option %q", source, name)
+		}
+		if len(values) != 1 && !isListFlag(flag) {
+			return errors.Errorf("%s: option %q requires a single value", source, name)
+		}
+		for _, value := range values {
+			if err = flags.Set(name, value); err != nil {
+				return errors.Errorf("%s: invalid value %q for option %q: %v", source, value, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func readConfigFile(path string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(content, filepath.Ext(path))
+}
+
+// parseConfig parses the options of the YAML or TOML document, the format is chosen by the file name extension
+func parseConfig(content []byte, ext string) (map[string]interface{}, error) {
+	options := make(map[string]interface{})
+	switch ext = strings.ToLower(ext); ext {
+	case ".yaml", ".yml":
+		var document map[string]interface{}
+		if err := yaml.UnmarshalStrict(content, &document); err != nil {
+			return nil, err
+		}
+		if err := flattenOptions(options, "", document); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		tree, err := toml.LoadBytes(content)
+		if err != nil {
+			return nil, err
+		}
+		if err = flattenOptions(options, "", tree.ToMap()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unsupported format %q, expected .yaml, .yml or .toml", ext)
+	}
+	return options, nil
+}
+
+// flattenOptions joins the names of nested sections with '-'
+func flattenOptions(options map[string]interface{}, prefix string, section map[string]interface{}) error {
+	for key, value := range section {
+		name := prefix + key
+		var err error
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			err = flattenOptions(options, name+"-", nested)
+		case map[interface{}]interface{}:
+			converted := make(map[string]interface{}, len(nested))
+			for k, v := range nested {
+				converted[fmt.Sprint(k)] = v
+			}
+			err = flattenOptions(options, name+"-", converted)
+		default:
+			if _, ok := options[name]; ok {
+				return errors.Errorf("duplicate option %q", name)
+			}
+			options[name] = value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func configValues(value interface{}) ([]string, error) {
+	if list, ok := value.([]interface{}); ok {
+		values := make([]string, 0, len(list))
+		for _, element := range list {
+			s, err := configValue(element)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	s, err := configValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", errors.New("value is missing")
+	default:
+		return "", errors.Errorf("unsupported value %v", v)
+	}
+}
+
+func isListFlag(flag *pflag.Flag) bool {
+	switch flag.Value.Type() {
+	case "stringSlice", "stringArray", "intSlice", "uintSlice", "boolSlice", "durationSlice", "ipSlice":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	dir, err := ioutil.TempDir("", "kafka-proxy-config")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestConfigFileYAML(t *testing.T) {
+	setupBootstrapServersMappingTest()
+
+	path := writeConfigFile(t, "kafka-proxy.yaml", `
+bootstrap-server-mapping:
+  - "192.168.99.100:32401,0.0.0.0:32401"
+  - "192.168.99.100:32402,0.0.0.0:32402"
+proxy-request-buffer-size: 8192
+proxy-listener-keep-alive: 30s
+tls:
+  enable: true
+  insecure-skip-verify: true
+sasl-enable: false
+forbidden-api-keys: [3, 4]
+`)
+	args := []string{"cobra.test", "--config-file", path, "--proxy-request-buffer-size", "1024"}
+	_ = Server.ParseFlags(args)
+	err := Server.PreRunE(nil, args)
+	a := assert.New(t)
+	a.Nil(err)
+	a.Len(c.Proxy.BootstrapServers, 2)
+	a.Equal("192.168.99.100:32402", c.Proxy.BootstrapServers[1].BrokerAddress)
+	// command line flags take precedence
+	a.Equal(1024, c.Proxy.RequestBufferSize)
+	a.Equal(30*time.Second, c.Proxy.ListenerKeepAlive)
+	a.True(c.Kafka.TLS.Enable)
+	a.True(c.Kafka.TLS.InsecureSkipVerify)
+	a.Equal([]int{3, 4}, c.Kafka.ForbiddenApiKeys)
+}
+
+func TestConfigFileTOML(t *testing.T) {
+	setupBootstrapServersMappingTest()
+
+	_ = os.Setenv("BOOTSTRAP_SERVER_MAPPING", "192.168.99.100:32404,0.0.0.0:32404")
+	path := writeConfigFile(t, "kafka-proxy.toml", `
+bootstrap-server-mapping = ["192.168.99.100:32401,0.0.0.0:32401"]
+dial-address-mapping = ["192.168.99.100:32404,10.0.0.1:9092"]
+
+[proxy]
+request-buffer-size = 8192
+`)
+	args := []string{"cobra.test", "--config-file", path}
+	_ = Server.ParseFlags(args)
+	err := Server.PreRunE(nil, args)
+	a := assert.New(t)
+	a.Nil(err)
+	// environment variables take precedence
+	a.Len(c.Proxy.BootstrapServers, 1)
+	a.Equal("192.168.99.100:32404", c.Proxy.BootstrapServers[0].BrokerAddress)
+	a.Len(c.Proxy.DialAddressMappings, 1)
+	a.Equal("10.0.0.1:9092", c.Proxy.DialAddressMappings[0].DestinationAddress)
+	a.Equal(8192, c.Proxy.RequestBufferSize)
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		err     string
+	}{
+		{name: "unknown option", file: "kafka-proxy.yaml", content: "tls-enabel: true",
+			err: `unknown option "tls-enabel"`},
+		{name: "invalid value", file: "kafka-proxy.yaml", content: "proxy-request-buffer-size: large",
+			err: `invalid value "large" for option "proxy-request-buffer-size"`},
+		{name: "single value", file: "kafka-proxy.yaml", content: "tls-enable: [true, false]",
+			err: `option "tls-enable" requires a single value`},
+		{name: "missing value", file: "kafka-proxy.yaml", content: "tls-enable:",
+			err: `option "tls-enable": value is missing`},
+		{name: "duplicate option", file: "kafka-proxy.yaml", content: "tls-enable: true\ntls:\n  enable: false",
+			err: `duplicate option "tls-enable"`},
+		{name: "syntax", file: "kafka-proxy.toml", content: "tls-enable = ",
+			err: "config file"},
+		{name: "format", file: "kafka-proxy.json", content: "{}",
+			err: `unsupported format ".json"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupBootstrapServersMappingTest()
+
+			args := []string{"cobra.test", "--config-file", writeConfigFile(t, tt.file, tt.content)}
+			_ = Server.ParseFlags(args)
+			err := Server.PreRunE(nil, args)
+			a := assert.New(t)
+			a.NotNil(err)
+			a.Contains(err.Error(), tt.err)
+		})
+	}
+}
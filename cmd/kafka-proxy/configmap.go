@@ -0,0 +1,116 @@
+package server
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/configmap-watcher"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// watcher of the ConfigMap, nil if disabled
+	configMapWatcher *configmapwatcher.Watcher
+	// options of the ConfigMap applied on startup
+	configMapOptions map[string]interface{}
+
+	newConfigMapWatcher = configmapwatcher.NewInCluster
+)
+
+// reloadableOptions are applied by a configuration reload, changes of other options require a restart
+var reloadableOptions = map[string]bool{
+	"bootstrap-server-mapping":                        true,
+	"external-server-mapping":                         true,
+	"forbidden-api-keys":                              true,
+	"scheduled-forbidden-api-keys":                    true,
+	"proxy-max-request-size":                          true,
+	"proxy-max-batch-size":                            true,
+	"proxy-listener-limits":                           true,
+	"proxy-listener-allow-cidr":                       true,
+	"proxy-listener-deny-cidr":                        true,
+	"proxy-listener-cert-file":                        true,
+	"proxy-listener-key-file":                         true,
+	"proxy-listener-key-password":                     true,
+	"proxy-listener-ca-chain-cert-file":               true,
+	"proxy-listener-cipher-suites":                    true,
+	"proxy-listener-curve-preferences":                true,
+	"proxy-listener-tls-client-cert-validate-subject": true,
+
+	// quotas, broker credentials and client authentication bans and cache
+	"quota":                   true,
+	"quota-window":            true,
+	"sasl-username":           true,
+	"sasl-password":           true,
+	"auth-ban-max-failures":   true,
+	"auth-ban-window":         true,
+	"auth-ban-duration":       true,
+	"auth-cache-ttl":          true,
+	"auth-cache-negative-ttl": true,
+	"auth-cache-max-entries":  true,
+}
+
+// applyConfigMap reads the options of the ConfigMap and sets the flags like the config file
+func applyConfigMap(flags *pflag.FlagSet, cfg *config.Config) error {
+	watcher, err := newConfigMapWatcher(configmapwatcher.Config{
+		Namespace:    cfg.Kubernetes.ConfigMap.Namespace,
+		Name:         cfg.Kubernetes.ConfigMap.Name,
+		Key:          cfg.Kubernetes.ConfigMap.Key,
+		PollInterval: cfg.Kubernetes.ConfigMap.PollInterval,
+	})
+	if err != nil {
+		return errors.Wrap(err, "configmap")
+	}
+	data, err := watcher.Get()
+	if err != nil {
+		return err
+	}
+	options, err := parseConfigMap(watcher, cfg.Kubernetes.ConfigMap.Key, data)
+	if err != nil {
+		return err
+	}
+	if err = applyConfigOptions(flags, configMapSource(watcher), options); err != nil {
+		return err
+	}
+	configMapWatcher = watcher
+	configMapOptions = options
+	return nil
+}
+
+func parseConfigMap(watcher *configmapwatcher.Watcher, key string, data string) (map[string]interface{}, error) {
+	options, err := parseConfig([]byte(data), filepath.Ext(key))
+	if err != nil {
+		return nil, errors.Wrap(err, configMapSource(watcher))
+	}
+	return options, nil
+}
+
+func configMapSource(watcher *configmapwatcher.Watcher) string {
+	return "configmap " + watcher.Name()
+}
+
+// restartOptions returns the names of the changed options which are not reloadable
+func restartOptions(previous, current map[string]interface{}) []string {
+	changed := make(map[string]bool)
+	for name, value := range current {
+		if !reflect.DeepEqual(previous[name], value) {
+			changed[name] = true
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			changed[name] = true
+		}
+	}
+	names := make([]string, 0)
+	for name := range changed {
+		if !reloadableOptions[name] && !strings.HasPrefix(name, "proxy-listener-tls-required-client-subject-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/pkg/libs/configmap-watcher"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupConfigMapTest(t *testing.T, data string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/kafka/configmaps/kafka-proxy" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"metadata":{"resourceVersion":"1"},"data":{"kafka-proxy.yaml":%q}}`, data)
+	}))
+	tokenFile := writeConfigFile(t, "token", "secret-token")
+	newConfigMapWatcher = func(cfg configmapwatcher.Config) (*configmapwatcher.Watcher, error) {
+		return configmapwatcher.New(cfg, server.URL, tokenFile, http.DefaultClient), nil
+	}
+	t.Cleanup(func() {
+		server.Close()
+		newConfigMapWatcher = configmapwatcher.NewInCluster
+		configMapWatcher = nil
+		configMapOptions = nil
+	})
+}
+
+func TestConfigMap(t *testing.T) {
+	setupBootstrapServersMappingTest()
+	setupConfigMapTest(t, `
+bootstrap-server-mapping:
+  - "192.168.99.100:32401,0.0.0.0:32401"
+forbidden-api-keys: [20]
+proxy-request-buffer-size: 8192
+`)
+	args := []string{"cobra.test", "--kubernetes-configmap-enable", "--kubernetes-configmap-namespace", "kafka", "--proxy-request-buffer-size", "1024"}
+	_ = Server.ParseFlags(args)
+	err := Server.PreRunE(nil, args)
+	a := assert.New(t)
+	a.Nil(err)
+	a.Len(c.Proxy.BootstrapServers, 1)
+	a.Equal([]int{20}, c.Kafka.ForbiddenApiKeys)
+	// command line flags take precedence
+	a.Equal(1024, c.Proxy.RequestBufferSize)
+	a.NotNil(configMapWatcher)
+
+	cfg, err := loadServerConfig(args[1:], configMapSource(configMapWatcher), configMapOptions)
+	a.Nil(err)
+	a.Len(cfg.Proxy.BootstrapServers, 1)
+	a.Equal([]int{20}, cfg.Kafka.ForbiddenApiKeys)
+}
+
+func TestConfigMapErrors(t *testing.T) {
+	setupBootstrapServersMappingTest()
+	setupConfigMapTest(t, "tls-enabel: true")
+
+	args := []string{"cobra.test", "--kubernetes-configmap-enable", "--kubernetes-configmap-namespace", "kafka"}
+	_ = Server.ParseFlags(args)
+	err := Server.PreRunE(nil, args)
+	a := assert.New(t)
+	a.EqualError(err, `configmap kafka/kafka-proxy: unknown option "tls-enabel"`)
+
+	setupBootstrapServersMappingTest()
+	args = []string{"cobra.test", "--kubernetes-configmap-enable", "--kubernetes-configmap-namespace", "default"}
+	_ = Server.ParseFlags(args)
+	err = Server.PreRunE(nil, args)
+	a.NotNil(err)
+	a.Contains(err.Error(), "404 Not Found")
+}
+
+func TestRestartOptions(t *testing.T) {
+	a := assert.New(t)
+
+	previous := map[string]interface{}{
+		"bootstrap-server-mapping":  []interface{}{"192.168.99.100:32401,0.0.0.0:32401"},
+		"proxy-request-buffer-size": 4096,
+		"sasl-enable":               true,
+	}
+	current := map[string]interface{}{
+		"bootstrap-server-mapping":                               []interface{}{"192.168.99.100:32402,0.0.0.0:32402"},
+		"proxy-request-buffer-size":                              8192,
+		"proxy-listener-tls-required-client-subject-common-name": "client",
+		"forbidden-api-keys":                                     []interface{}{20},
+		"quota":                                                  []interface{}{"alice=1048576,0,0"},
+		"sasl-password":                                          "secret",
+	}
+	a.Equal([]string{"proxy-request-buffer-size", "sasl-enable"}, restartOptions(previous, current))
+	a.Empty(restartOptions(current, current))
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/grepplabs/kafka-proxy/proxy"
+	"github.com/sirupsen/logrus"
+)
+
+// connectionsHandler lists the active client connections on GET <path> and closes a connection on DELETE <path>/<id>.
+// Requests must send the token of the token file as bearer token.
+func connectionsHandler(path string, tokenFile string, connections *proxy.Connections) http.HandlerFunc {
+	path = strings.TrimSuffix(path, "/")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizedBearer(r, tokenFile) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, path), "/")
+		switch {
+		case id == "" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(connections.List())
+		case id != "" && r.Method == http.MethodDelete:
+			if !connections.Close(id) {
+				http.Error(w, "connection not found", http.StatusNotFound)
+				return
+			}
+			logrus.Infof("Client connection %s closed by the connections endpoint", id)
+			w.WriteHeader(http.StatusNoContent)
+		case id == "":
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		default:
+			w.Header().Set("Allow", http.MethodDelete)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// authorizedBearer compares the bearer token of the request with the token file, which is read on each request
+func authorizedBearer(r *http.Request, tokenFile string) bool {
+	data, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		logrus.Errorf("Token file of the endpoint cannot be read: %v", err)
+		return false
+	}
+	token := strings.TrimSpace(string(data))
+	auth := r.Header.Get("Authorization")
+	if token == "" || !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) == 1
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionsHandler(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "connections")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	a.Nil(ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600))
+
+	handler := connectionsHandler("/api/connections", tokenFile, proxy.NewConnections(nil))
+	request := func(method string, path string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	a.Equal(http.StatusUnauthorized, request(http.MethodGet, "/api/connections", "").Code)
+	a.Equal(http.StatusUnauthorized, request(http.MethodGet, "/api/connections", "wrong").Code)
+
+	w := request(http.MethodGet, "/api/connections", "secret")
+	a.Equal(http.StatusOK, w.Code)
+	var list []proxy.ConnectionInfo
+	a.Nil(json.Unmarshal(w.Body.Bytes(), &list))
+	a.Empty(list)
+
+	a.Equal(http.StatusNotFound, request(http.MethodDelete, "/api/connections/7", "secret").Code)
+	a.Equal(http.StatusMethodNotAllowed, request(http.MethodPost, "/api/connections", "secret").Code)
+	a.Equal(http.StatusMethodNotAllowed, request(http.MethodGet, "/api/connections/7", "secret").Code)
+
+	// token is read on each request
+	a.Nil(os.Remove(tokenFile))
+	a.Equal(http.StatusUnauthorized, request(http.MethodGet, "/api/connections", "secret").Code)
+}
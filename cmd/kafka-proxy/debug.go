@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/supervisor"
+	"github.com/grepplabs/kafka-proxy/proxy"
+)
+
+// supervised plugins listed by the debug state endpoint
+var supervisedPlugins struct {
+	sync.Mutex
+	list []*supervisor.Supervisor
+}
+
+func addSupervisedPlugin(s *supervisor.Supervisor) {
+	supervisedPlugins.Lock()
+	defer supervisedPlugins.Unlock()
+	supervisedPlugins.list = append(supervisedPlugins.list, s)
+}
+
+func supervisedPluginStatuses() []supervisor.Status {
+	supervisedPlugins.Lock()
+	defer supervisedPlugins.Unlock()
+	statuses := make([]supervisor.Status, 0, len(supervisedPlugins.list))
+	for _, s := range supervisedPlugins.list {
+		statuses = append(statuses, s.Status())
+	}
+	return statuses
+}
+
+// debugState is the snapshot of the internal state returned by the debug state endpoint
+type debugState struct {
+	Version    string                `json:"version"`
+	Goroutines int                   `json:"goroutines"`
+	Listeners  []proxy.ListenerState `json:"listeners"`
+	Client     proxy.ClientState     `json:"client"`
+	Plugins    []supervisor.Status   `json:"plugins"`
+}
+
+func newDebugState(listeners *proxy.Listeners, client *proxy.Client) func() debugState {
+	return func() debugState {
+		return debugState{
+			Version:    config.Version,
+			Goroutines: runtime.NumGoroutine(),
+			Listeners:  listeners.ListenerStates(),
+			Client:     client.State(),
+			Plugins:    supervisedPluginStatuses(),
+		}
+	}
+}
+
+// newDebugHandler serves the pprof endpoints registered on the default mux and the state endpoint. All endpoints require
+// the bearer token read from the token file if it is set.
+func newDebugHandler(statePath string, tokenFile string, state func() debugState) http.Handler {
+	m := http.NewServeMux()
+	m.Handle("/", http.DefaultServeMux)
+	m.Handle(statePath, debugStateHandler(state))
+	if tokenFile == "" {
+		return m
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorizedBearer(r, tokenFile) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		m.ServeHTTP(w, r)
+	})
+}
+
+func debugStateHandler(state func() debugState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(state())
+	}
+}
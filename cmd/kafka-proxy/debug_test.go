@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugHandler(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "debug")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	a.Nil(ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600))
+
+	state := func() debugState {
+		return debugState{Version: "test", Goroutines: 7, Listeners: []proxy.ListenerState{{BrokerAddress: "kafka-0:9092", Kind: "bootstrap"}}}
+	}
+	request := func(handler http.Handler, method string, path string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	handler := newDebugHandler("/debug/state", tokenFile, state)
+	a.Equal(http.StatusUnauthorized, request(handler, http.MethodGet, "/debug/state", "").Code)
+	a.Equal(http.StatusUnauthorized, request(handler, http.MethodGet, "/debug/pprof/", "wrong").Code)
+	a.Equal(http.StatusMethodNotAllowed, request(handler, http.MethodPost, "/debug/state", "secret").Code)
+
+	w := request(handler, http.MethodGet, "/debug/state", "secret")
+	a.Equal(http.StatusOK, w.Code)
+	var response debugState
+	a.Nil(json.Unmarshal(w.Body.Bytes(), &response))
+	a.Equal(state(), response)
+
+	// pprof endpoints are served from the default mux
+	a.Equal(http.StatusOK, request(handler, http.MethodGet, "/debug/pprof/goroutine?debug=1", "secret").Code)
+
+	// the token is not required without token file
+	handler = newDebugHandler("/debug/state", "", state)
+	a.Equal(http.StatusOK, request(handler, http.MethodGet, "/debug/state", "").Code)
+}
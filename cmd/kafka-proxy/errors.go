@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Exit codes of the kafka-proxy binary. The values are stable and can be used by orchestration and alerting.
+const (
+	ExitCodeOK                  = 0
+	ExitCodeGeneric             = 1
+	ExitCodeConfig              = 2
+	ExitCodeBind                = 3
+	ExitCodePlugin              = 4
+	ExitCodeUpstreamUnreachable = 5
+)
+
+const (
+	ErrorKindConfig              = "config"
+	ErrorKindBind                = "bind"
+	ErrorKindPlugin              = "plugin"
+	ErrorKindUpstreamUnreachable = "upstream_unreachable"
+)
+
+var exitCodes = map[string]int{
+	ErrorKindConfig:              ExitCodeConfig,
+	ErrorKindBind:                ExitCodeBind,
+	ErrorKindPlugin:              ExitCodePlugin,
+	ErrorKindUpstreamUnreachable: ExitCodeUpstreamUnreachable,
+}
+
+// StartupError is an error which prevents the proxy from starting
+type StartupError struct {
+	Kind string
+	Err  error
+}
+
+func (e *StartupError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StartupError) Cause() error {
+	return e.Err
+}
+
+func (e *StartupError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StartupError) ExitCode() int {
+	if code, ok := exitCodes[e.Kind]; ok {
+		return code
+	}
+	return ExitCodeGeneric
+}
+
+func configError(err error) error {
+	return &StartupError{Kind: ErrorKindConfig, Err: err}
+}
+
+func bindError(err error) error {
+	return &StartupError{Kind: ErrorKindBind, Err: err}
+}
+
+func pluginError(err error) error {
+	return &StartupError{Kind: ErrorKindPlugin, Err: err}
+}
+
+func upstreamError(err error) error {
+	return &StartupError{Kind: ErrorKindUpstreamUnreachable, Err: err}
+}
+
+// ExitCode returns the process exit code for the error returned by command execution
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitCodeOK
+	}
+	var startupErr *StartupError
+	if errors.As(err, &startupErr) {
+		return startupErr.ExitCode()
+	}
+	return ExitCodeGeneric
+}
+
+type startupErrorReport struct {
+	Time     string `json:"time"`
+	Version  string `json:"version"`
+	Kind     string `json:"kind"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error"`
+}
+
+func writeStartupErrorReport(w io.Writer, err *StartupError) error {
+	now := time.Now()
+	if location, err := time.LoadLocation(c.Log.TimeZone); err == nil && c.Log.TimeZone != "" {
+		now = now.In(location)
+	}
+	return json.NewEncoder(w).Encode(startupErrorReport{
+		Time:     now.Format(time.RFC3339),
+		Version:  config.Version,
+		Kind:     err.Kind,
+		ExitCode: err.ExitCode(),
+		Error:    err.Error(),
+	})
+}
+
+// ReportError prints the error returned by command execution and returns the exit code
+func ReportError(err error) int {
+	var startupErr *StartupError
+	if errors.As(err, &startupErr) && c.Log.StartupErrorFormat == "json" {
+		_ = writeStartupErrorReport(os.Stderr, startupErr)
+	} else {
+		fmt.Println(err)
+	}
+	return ExitCode(err)
+}
+
+// fatal reports the startup error and exits
+func fatal(err error) {
+	var startupErr *StartupError
+	if errors.As(err, &startupErr) && c.Log.StartupErrorFormat == "json" {
+		_ = writeStartupErrorReport(os.Stderr, startupErr)
+	} else {
+		logrus.Error(err)
+	}
+	os.Exit(ExitCode(err))
+}
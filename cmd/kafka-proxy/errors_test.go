@@ -0,0 +1,53 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(ExitCodeOK, ExitCode(nil))
+	a.Equal(ExitCodeGeneric, ExitCode(errors.New("unknown command")))
+	a.Equal(ExitCodeConfig, ExitCode(configError(errors.New("invalid"))))
+	a.Equal(ExitCodeBind, ExitCode(bindError(errors.New("address already in use"))))
+	a.Equal(ExitCodePlugin, ExitCode(pluginError(errors.New("plugin exited"))))
+	a.Equal(ExitCodeUpstreamUnreachable, ExitCode(upstreamError(errors.New("connection refused"))))
+	a.Equal(ExitCodeGeneric, ExitCode(&StartupError{Kind: "other", Err: errors.New("other")}))
+	a.Equal(ExitCodeBind, ExitCode(fmt.Errorf("start: %w", bindError(errors.New("address already in use")))))
+	a.Equal(ExitCodeConfig, ExitCode(pkgerrors.Wrap(configError(errors.New("invalid")), "start")))
+}
+
+func TestWriteStartupErrorReport(t *testing.T) {
+	a := assert.New(t)
+
+	buf := new(bytes.Buffer)
+	err := writeStartupErrorReport(buf, bindError(errors.New("listen tcp 0.0.0.0:9080: bind: address already in use")).(*StartupError))
+	a.Nil(err)
+
+	var report map[string]interface{}
+	a.Nil(json.Unmarshal(buf.Bytes(), &report))
+	a.Equal("bind", report["kind"])
+	a.Equal(float64(ExitCodeBind), report["exit_code"])
+	a.Equal("listen tcp 0.0.0.0:9080: bind: address already in use", report["error"])
+	a.Contains(report, "time")
+	a.Contains(report, "version")
+}
+
+func TestPreRunConfigErrorKind(t *testing.T) {
+	setupBootstrapServersMappingTest()
+
+	args := []string{"cobra.test"}
+	_ = Server.ParseFlags(args)
+	err := Server.PreRunE(nil, args)
+	a := assert.New(t)
+	a.NotNil(err)
+	a.Equal(ExitCodeConfig, ExitCode(err))
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/configmap-watcher"
+	"github.com/grepplabs/kafka-proxy/proxy"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// reloader applies the configuration re-read from the server arguments, the config file, the ConfigMap and the environment to the
+// running proxy. The server mappings, the listener TLS settings, the forbidden api keys, the quotas, the request limits, the SASL
+// credentials and the authentication ban and cache settings are reloaded, other changes require a restart.
+type reloader struct {
+	args      []string
+	listeners *proxy.Listeners
+	client    *proxy.Client
+	lock      sync.Mutex
+
+	configMap        *configmapwatcher.Watcher
+	configMapKey     string
+	configMapOptions map[string]interface{}
+
+	// mappings generated from the cluster metadata
+	autoMappings []config.ListenerConfig
+
+	// rewritten with the reloaded mappings
+	bootstrapFile string
+}
+
+func (r *reloader) reload() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.apply()
+}
+
+// reloadConfigMap reloads the configuration with the changed ConfigMap data
+func (r *reloader) reloadConfigMap(data string) error {
+	options, err := parseConfigMap(r.configMap, r.configMapKey, data)
+	if err != nil {
+		return errors.Wrap(err, "reload failed")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if names := restartOptions(r.configMapOptions, options); len(names) != 0 {
+		logrus.Warnf("Changed options %v of %s are not reloaded, they are applied on restart", names, configMapSource(r.configMap))
+	}
+	previous := r.configMapOptions
+	r.configMapOptions = options
+	if err = r.apply(); err != nil {
+		r.configMapOptions = previous
+		return err
+	}
+	return nil
+}
+
+func (r *reloader) apply() error {
+	var source string
+	if r.configMap != nil {
+		source = configMapSource(r.configMap)
+	}
+	cfg, err := loadServerConfig(r.args, source, r.configMapOptions)
+	if err != nil {
+		return errors.Wrap(err, "reload failed")
+	}
+	cfg.Proxy.BootstrapServers = mergeAutoMappings(cfg.Proxy.BootstrapServers, r.autoMappings)
+	if err = r.listeners.Reload(cfg); err != nil {
+		return errors.Wrap(err, "reload failed")
+	}
+	r.client.Reload(cfg)
+	if r.bootstrapFile != "" {
+		if err = writeBootstrapFile(r.bootstrapFile, r.listeners.BootstrapListeners()); err != nil {
+			logrus.Errorf("Bootstrap file was not updated: %v", err)
+		}
+	}
+	logrus.Infof("Configuration reloaded: %d bootstrap server mapping(s)", len(cfg.Proxy.BootstrapServers))
+	return nil
+}
+
+// loadServerConfig parses the server arguments and applies the config file, the ConfigMap options and the environment like the server command
+func loadServerConfig(args []string, configMapSource string, configMapOptions map[string]interface{}) (*config.Config, error) {
+	var (
+		cfg              = new(config.Config)
+		bootstrapServers = make([]string, 0)
+		externalServers  = make([]string, 0)
+		dialAddresses    = make([]string, 0)
+		file             string
+	)
+	flags := pflag.NewFlagSet("reload", pflag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	addServerFlags(flags, cfg, &bootstrapServers, &externalServers, &dialAddresses)
+	flags.StringVar(&file, "config-file", "", "")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if file != "" {
+		if err := applyConfigFile(flags, file); err != nil {
+			return nil, err
+		}
+	}
+	if configMapOptions != nil {
+		if err := applyConfigOptions(flags, configMapSource, configMapOptions); err != nil {
+			return nil, err
+		}
+	}
+	err := initConfig(cfg,
+		getOrEnvStringSlice(bootstrapServers, "BOOTSTRAP_SERVER_MAPPING"),
+		getOrEnvStringSlice(externalServers, "EXTERNAL_SERVER_MAPPING"),
+		getOrEnvStringSlice(dialAddresses, "DIAL_ADDRESS_MAPPING"))
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// serverArgs returns the arguments following the server command
+func serverArgs(osArgs []string) []string {
+	for i, arg := range osArgs {
+		if arg == "server" {
+			return osArgs[i+1:]
+		}
+	}
+	return nil
+}
+
+// reloadHandler reloads the configuration on POST, the reload errors are logged only
+func reloadHandler(tokenFile string, reload func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizedBearer(r, tokenFile) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reload(); err != nil {
+			logrus.Errorf("Configuration reload requested by %s: %v", r.RemoteAddr, err)
+			http.Error(w, "reload failed", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`OK`))
+	}
+}
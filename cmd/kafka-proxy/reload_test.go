@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadServerConfig(t *testing.T) {
+	setupBootstrapServersMappingTest()
+	a := assert.New(t)
+
+	path := writeConfigFile(t, "kafka-proxy.yaml", `
+bootstrap-server-mapping:
+  - "192.168.99.100:32401,0.0.0.0:32401"
+  - "192.168.99.100:32402,0.0.0.0:32402"
+forbidden-api-keys: [20]
+proxy-max-request-size: 1048576
+`)
+	cfg, err := loadServerConfig([]string{"--config-file", path, "--proxy-max-request-size", "2048"}, "", nil)
+	a.Nil(err)
+	a.Len(cfg.Proxy.BootstrapServers, 2)
+	a.Equal([]int{20}, cfg.Kafka.ForbiddenApiKeys)
+	// command line flags take precedence
+	a.Equal(2048, cfg.Proxy.RequestLimits.MaxRequestSize)
+
+	path = writeConfigFile(t, "kafka-proxy.yaml", "forbidden-api-keys: [20]")
+	_, err = loadServerConfig([]string{"--config-file", path}, "", nil)
+	a.EqualError(err, "list of bootstrap-server-mapping must not be empty")
+}
+
+func TestServerArgs(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal([]string{"--config-file", "kafka-proxy.yaml"}, serverArgs([]string{"kafka-proxy", "server", "--config-file", "kafka-proxy.yaml"}))
+	a.Empty(serverArgs([]string{"kafka-proxy", "server"}))
+	a.Nil(serverArgs([]string{"kafka-proxy"}))
+}
+
+func TestReloadHandler(t *testing.T) {
+	a := assert.New(t)
+
+	reloads := 0
+	var reloadErr error
+	handler := reloadHandler(validateTokenFile(t), func() error {
+		reloads++
+		return reloadErr
+	})
+	request := func(method, token string) *http.Request {
+		r := httptest.NewRequest(method, "/reload", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, request(http.MethodPost, ""))
+	a.Equal(http.StatusUnauthorized, w.Code)
+	a.Equal("Bearer", w.Header().Get("WWW-Authenticate"))
+
+	w = httptest.NewRecorder()
+	handler(w, request(http.MethodPost, "wrong"))
+	a.Equal(http.StatusUnauthorized, w.Code)
+	a.Equal(0, reloads)
+
+	w = httptest.NewRecorder()
+	handler(w, request(http.MethodGet, "secret"))
+	a.Equal(http.StatusMethodNotAllowed, w.Code)
+	a.Equal(0, reloads)
+
+	w = httptest.NewRecorder()
+	handler(w, request(http.MethodPost, "secret"))
+	a.Equal(http.StatusOK, w.Code)
+	a.Equal(1, reloads)
+
+	reloadErr = errors.New("reload failed: open /etc/kafka-proxy/kafka-proxy.yaml: permission denied")
+	w = httptest.NewRecorder()
+	handler(w, request(http.MethodPost, "secret"))
+	a.Equal(http.StatusBadRequest, w.Code)
+	a.NotContains(w.Body.String(), "kafka-proxy.yaml")
+}
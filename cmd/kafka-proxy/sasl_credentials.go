@@ -0,0 +1,64 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/util"
+	"github.com/sirupsen/logrus"
+)
+
+// saslCredentialsRotator re-reads the SASL credentials when the JAAS config file changes or periodically from Vault
+type saslCredentialsRotator struct {
+	cfg    *config.Config
+	client saslCredentialsSetter
+
+	closeOnce sync.Once
+	closed    chan bool
+}
+
+type saslCredentialsSetter interface {
+	SetSASLCredentials(username, password string)
+}
+
+func newSASLCredentialsRotator(cfg *config.Config, client saslCredentialsSetter) *saslCredentialsRotator {
+	return &saslCredentialsRotator{cfg: cfg, client: client, closed: make(chan bool)}
+}
+
+func (r *saslCredentialsRotator) run() error {
+	if r.cfg.Kafka.SASL.JaasConfigFile != "" {
+		if err := util.WatchForUpdates(r.cfg.Kafka.SASL.JaasConfigFile, r.closed, r.refresh); err != nil {
+			return err
+		}
+		<-r.closed
+		return nil
+	}
+	logrus.Infof("Reading SASL credentials from vault %s every %v", r.cfg.Kafka.SASL.Vault.Path, r.cfg.Kafka.SASL.Vault.RefreshInterval)
+	ticker := time.NewTicker(r.cfg.Kafka.SASL.Vault.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.refresh()
+		case <-r.closed:
+			return nil
+		}
+	}
+}
+
+// refresh reads the credentials, on failure the current credentials are kept
+func (r *saslCredentialsRotator) refresh() {
+	credentials, err := r.cfg.LoadSASLCredentials()
+	if err != nil {
+		logrus.Warnf("Reading SASL credentials failed, current credentials are kept: %v", err)
+		return
+	}
+	if credentials != nil {
+		r.client.SetSASLCredentials(credentials.Username, credentials.Password)
+	}
+}
+
+func (r *saslCredentialsRotator) close() {
+	r.closeOnce.Do(func() { close(r.closed) })
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSASLCredentials struct {
+	username, password string
+}
+
+func (s *testSASLCredentials) SetSASLCredentials(username, password string) {
+	s.username, s.password = username, password
+}
+
+func TestSASLCredentialsRotatorJaasFile(t *testing.T) {
+	a := assert.New(t)
+
+	jaasFile := writeConfigFile(t, "kafka-proxy.jaas", `KafkaClient { org.apache.kafka.common.security.plain.PlainLoginModule required username="alice" password="secret-1"; };`)
+	cfg := config.NewConfig()
+	cfg.Kafka.SASL.JaasConfigFile = jaasFile
+	credentials := &testSASLCredentials{}
+	rotator := newSASLCredentialsRotator(cfg, credentials)
+
+	rotator.refresh()
+	a.Equal(testSASLCredentials{"alice", "secret-1"}, *credentials)
+
+	a.Nil(ioutil.WriteFile(jaasFile, []byte(`KafkaClient { org.apache.kafka.common.security.plain.PlainLoginModule required username="alice" password="secret-2"; };`), 0600))
+	rotator.refresh()
+	a.Equal(testSASLCredentials{"alice", "secret-2"}, *credentials)
+
+	// invalid files keep the current credentials
+	a.Nil(ioutil.WriteFile(jaasFile, []byte(`KafkaClient { username="alice"; };`), 0600))
+	rotator.refresh()
+	a.Equal(testSASLCredentials{"alice", "secret-2"}, *credentials)
+}
+
+func TestSASLCredentialsRotatorVault(t *testing.T) {
+	a := assert.New(t)
+
+	password := "secret-1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"data":{"username":"alice","password":"` + password + `"},"metadata":{}}}`))
+	}))
+	defer server.Close()
+
+	cfg := config.NewConfig()
+	cfg.Kafka.SASL.Vault.Address = server.URL
+	cfg.Kafka.SASL.Vault.TokenFile = writeConfigFile(t, "token", "s.token")
+	cfg.Kafka.SASL.Vault.Path = "secret/data/kafka-proxy"
+	credentials := &testSASLCredentials{}
+	rotator := newSASLCredentialsRotator(cfg, credentials)
+
+	rotator.refresh()
+	a.Equal(testSASLCredentials{"alice", "secret-1"}, *credentials)
+
+	password = "secret-2"
+	rotator.refresh()
+	a.Equal(testSASLCredentials{"alice", "secret-2"}, *credentials)
+}
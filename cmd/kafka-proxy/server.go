@@ -4,14 +4,15 @@ import (
 	"fmt"
 
 	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/activation"
 	"github.com/grepplabs/kafka-proxy/proxy"
 	"github.com/oklog/run"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
-	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -24,6 +25,11 @@ import (
 	"strings"
 
 	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/metrics"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/supervisor"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/wasm"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
+	interceptorshared "github.com/grepplabs/kafka-proxy/plugin/interceptor/shared"
 	localauth "github.com/grepplabs/kafka-proxy/plugin/local-auth/shared"
 	tokeninfo "github.com/grepplabs/kafka-proxy/plugin/token-info/shared"
 	tokenprovider "github.com/grepplabs/kafka-proxy/plugin/token-provider/shared"
@@ -32,8 +38,14 @@ import (
 
 	"github.com/grepplabs/kafka-proxy/pkg/registry"
 	// built-in plugins
+	_ "github.com/grepplabs/kafka-proxy/pkg/libs/auth-ldap"
+	_ "github.com/grepplabs/kafka-proxy/pkg/libs/auth-user"
 	_ "github.com/grepplabs/kafka-proxy/pkg/libs/googleid-info"
 	_ "github.com/grepplabs/kafka-proxy/pkg/libs/googleid-provider"
+	_ "github.com/grepplabs/kafka-proxy/pkg/libs/oidc-provider"
+	_ "github.com/grepplabs/kafka-proxy/pkg/libs/tenant-isolation"
+	_ "github.com/grepplabs/kafka-proxy/pkg/libs/token-exchange-provider"
+	_ "github.com/grepplabs/kafka-proxy/pkg/libs/unsecured-jwt-provider"
 	"github.com/spf13/viper"
 )
 
@@ -43,36 +55,65 @@ var (
 	bootstrapServersMapping = make([]string, 0)
 	externalServersMapping  = make([]string, 0)
 	dialAddressMapping      = make([]string, 0)
+
+	configFile string
+	// flags of the Server command
+	serverFlags *pflag.FlagSet
 )
 
 var Server = &cobra.Command{
 	Use:   "server",
 	Short: "Run the kafka-proxy server",
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		var configFileErr error
+		if configFile != "" {
+			// the config file can set the log and startup error format
+			configFileErr = applyConfigFile(serverFlags, configFile)
+		}
+		if configFileErr == nil && c.Kubernetes.ConfigMap.Enable {
+			configFileErr = applyConfigMap(serverFlags, c)
+		}
 		SetLogger()
 
+		if cmd != nil && c.Log.StartupErrorFormat == "json" {
+			// startup errors are reported as JSON by ReportError
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+		}
+		if configFileErr != nil {
+			return configError(configFileErr)
+		}
+
 		if err := c.InitSASLCredentials(); err != nil {
-			return err
+			return configError(err)
 		}
 		if err := c.InitBootstrapServers(getOrEnvStringSlice(bootstrapServersMapping, "BOOTSTRAP_SERVER_MAPPING")); err != nil {
-			return err
+			return configError(err)
 		}
 		if err := c.InitExternalServers(getOrEnvStringSlice(externalServersMapping, "EXTERNAL_SERVER_MAPPING")); err != nil {
-			return err
+			return configError(err)
 		}
 		if err := c.InitDialAddressMappings(getOrEnvStringSlice(dialAddressMapping, "DIAL_ADDRESS_MAPPING")); err != nil {
-			return err
+			return configError(err)
 		}
 		if err := c.Validate(); err != nil {
-			return err
+			return configError(err)
 		}
 		return nil
 	},
 	Run: Run,
 }
 
+func brokerAddresses(listenerConfigs []config.ListenerConfig) []string {
+	addresses := make([]string, 0, len(listenerConfigs))
+	for _, listenerConfig := range listenerConfigs {
+		addresses = append(addresses, listenerConfig.BrokerAddress)
+	}
+	return addresses
+}
+
 func getOrEnvStringSlice(value []string, envKey string) []string {
-	if len(bootstrapServersMapping) != 0 {
+	if len(value) != 0 {
 		return value
 	}
 	return strings.Fields(os.Getenv(envKey))
@@ -83,125 +124,294 @@ func init() {
 }
 
 func initFlags() {
+	serverFlags = Server.Flags()
+	addServerFlags(serverFlags, c, &bootstrapServersMapping, &externalServersMapping, &dialAddressMapping)
+	serverFlags.StringVar(&configFile, "config-file", "", "YAML (.yaml, .yml) or TOML (.toml) file with options named like the flags. Command line flags and environment variables take precedence")
+
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	viper.AutomaticEnv() // read in environment variables that match
+}
+
+// addServerFlags binds server flags to the config. It is also used to parse candidate configurations by the validate endpoint
+func addServerFlags(flags *pflag.FlagSet, c *config.Config, bootstrapServersMapping, externalServersMapping, dialAddressMapping *[]string) {
 	// proxy
-	Server.Flags().StringVar(&c.Proxy.DefaultListenerIP, "default-listener-ip", "127.0.0.1", "Default listener IP")
-	Server.Flags().StringVar(&c.Proxy.DynamicAdvertisedListener, "dynamic-advertised-listener", "", "Advertised address for dynamic listeners. If empty, default-listener-ip is used")
-	Server.Flags().StringArrayVar(&bootstrapServersMapping, "bootstrap-server-mapping", []string{}, "Mapping of Kafka bootstrap server address to local address (host:port,host:port(,advhost:advport))")
-	Server.Flags().StringArrayVar(&externalServersMapping, "external-server-mapping", []string{}, "Mapping of Kafka server address to external address (host:port,host:port). A listener for the external address is not started")
-	Server.Flags().StringArrayVar(&dialAddressMapping, "dial-address-mapping", []string{}, "Mapping of target broker address to new one (host:port,host:port). The mapping is performed during connection establishment")
-	Server.Flags().BoolVar(&c.Proxy.DisableDynamicListeners, "dynamic-listeners-disable", false, "Disable dynamic listeners.")
-	Server.Flags().IntVar(&c.Proxy.DynamicSequentialMinPort, "dynamic-sequential-min-port", 0, "If set to non-zero, makes the dynamic listener use a sequential port starting with this value rather than a random port every time.")
-
-	Server.Flags().IntVar(&c.Proxy.RequestBufferSize, "proxy-request-buffer-size", 4096, "Request buffer size pro tcp connection")
-	Server.Flags().IntVar(&c.Proxy.ResponseBufferSize, "proxy-response-buffer-size", 4096, "Response buffer size pro tcp connection")
-
-	Server.Flags().IntVar(&c.Proxy.ListenerReadBufferSize, "proxy-listener-read-buffer-size", 0, "Size of the operating system's receive buffer associated with the connection. If zero, system default is used")
-	Server.Flags().IntVar(&c.Proxy.ListenerWriteBufferSize, "proxy-listener-write-buffer-size", 0, "Sets the size of the operating system's transmit buffer associated with the connection. If zero, system default is used")
-	Server.Flags().DurationVar(&c.Proxy.ListenerKeepAlive, "proxy-listener-keep-alive", 60*time.Second, "Keep alive period for an active network connection. If zero, keep-alives are disabled")
-
-	Server.Flags().BoolVar(&c.Proxy.TLS.Enable, "proxy-listener-tls-enable", false, "Whether or not to use TLS listener")
-	Server.Flags().StringVar(&c.Proxy.TLS.ListenerCertFile, "proxy-listener-cert-file", "", "PEM encoded file with server certificate")
-	Server.Flags().StringVar(&c.Proxy.TLS.ListenerKeyFile, "proxy-listener-key-file", "", "PEM encoded file with private key for the server certificate")
-	Server.Flags().StringVar(&c.Proxy.TLS.ListenerKeyPassword, "proxy-listener-key-password", "", "Password to decrypt rsa private key")
-	Server.Flags().StringVar(&c.Proxy.TLS.CAChainCertFile, "proxy-listener-ca-chain-cert-file", "", "PEM encoded CA's certificate file. If provided, client certificate is required and verified")
-	Server.Flags().StringSliceVar(&c.Proxy.TLS.ListenerCipherSuites, "proxy-listener-cipher-suites", []string{}, "List of supported cipher suites")
-	Server.Flags().StringSliceVar(&c.Proxy.TLS.ListenerCurvePreferences, "proxy-listener-curve-preferences", []string{}, "List of curve preferences")
-
-	Server.Flags().BoolVar(&c.Proxy.TLS.ClientCert.ValidateSubject, "proxy-listener-tls-client-cert-validate-subject", false, "Whether to validate client certificate subject")
-	Server.Flags().StringVar(&c.Proxy.TLS.ClientCert.Subject.CommonName, "proxy-listener-tls-required-client-subject-common-name", "", "Required client certificate subject common name")
-	Server.Flags().StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.Country, "proxy-listener-tls-required-client-subject-country", []string{}, "Required client certificate subject country")
-	Server.Flags().StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.Province, "proxy-listener-tls-required-client-subject-province", []string{}, "Required client certificate subject province")
-	Server.Flags().StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.Locality, "proxy-listener-tls-required-client-subject-locality", []string{}, "Required client certificate subject locality")
-	Server.Flags().StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.Organization, "proxy-listener-tls-required-client-subject-organization", []string{}, "Required client certificate subject organization")
-	Server.Flags().StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.OrganizationalUnit, "proxy-listener-tls-required-client-subject-organizational-unit", []string{}, "Required client certificate subject organizational unit")
+	flags.StringVar(&c.Proxy.DefaultListenerIP, "default-listener-ip", "127.0.0.1", "Default listener IP")
+	flags.StringVar(&c.Proxy.DynamicAdvertisedListener, "dynamic-advertised-listener", "", "Advertised address for dynamic listeners. If empty, default-listener-ip is used")
+	flags.StringArrayVar(bootstrapServersMapping, "bootstrap-server-mapping", []string{}, "Mapping of Kafka bootstrap server address to local address (host:port,host:port(,advhost:advport))")
+	flags.StringArrayVar(externalServersMapping, "external-server-mapping", []string{}, "Mapping of Kafka server address to external address (host:port,host:port). A listener for the external address is not started")
+	flags.StringArrayVar(dialAddressMapping, "dial-address-mapping", []string{}, "Mapping of target broker address to new one (host:port,host:port). The mapping is performed during connection establishment. Further addresses (host:port,host:port,host:port...) are dialed in order when the previous ones are unreachable. An address srv://<name> is resolved to the targets of the SRV records")
+	flags.StringVar(&c.Proxy.BootstrapFile, "bootstrap-file", "", "File to which the advertised addresses of the bootstrap server mappings are written comma separated after the listeners are started and reloaded. Ports 0 of the local addresses are replaced by the ports assigned by the OS")
+	flags.StringVar(&c.Proxy.Transparent.ListenerAddress, "transparent-listener-address", "", "Address of a listener for connections to brokers redirected by iptables (Linux only). The original destination of a connection is the broker address, broker addresses in responses are not rewritten")
+	flags.BoolVar(&c.Proxy.Transparent.TProxy, "transparent-tproxy", false, "Connections are redirected to the transparent listener by iptables TPROXY instead of REDIRECT. Requires CAP_NET_ADMIN")
+	flags.BoolVar(&c.Proxy.DisableDynamicListeners, "dynamic-listeners-disable", false, "Disable dynamic listeners.")
+	flags.IntVar(&c.Proxy.DynamicSequentialMinPort, "dynamic-sequential-min-port", 0, "If set to non-zero, makes the dynamic listener use a sequential port starting with this value rather than a random port every time.")
+	flags.BoolVar(&c.Proxy.AutoMapping.Enable, "auto-mapping-enable", false, "Generate the mappings of all brokers from the metadata of the bootstrap servers and refresh them periodically")
+	flags.IntVar(&c.Proxy.AutoMapping.PortOffset, "auto-mapping-port-offset", 32400, "Listener port of a generated mapping is the offset plus the broker node id")
+	flags.StringVar(&c.Proxy.AutoMapping.ListenerAddress, "auto-mapping-listener-address", "0.0.0.0:$(listener_port)", "Listener address template of the generated mappings. Placeholders: $(node_id), $(listener_port), $(broker_host), $(broker_port)")
+	flags.StringVar(&c.Proxy.AutoMapping.AdvertisedAddress, "auto-mapping-advertised-address", "", "Advertised address template of the generated mappings e.g. 'kafka-proxy-$(node_id).example.com:$(listener_port)'. If empty, the listener address is used")
+	flags.DurationVar(&c.Proxy.AutoMapping.RefreshInterval, "auto-mapping-refresh-interval", 5*time.Minute, "How often the generated mappings are refreshed")
+
+	flags.IntVar(&c.Proxy.RequestBufferSize, "proxy-request-buffer-size", 4096, "Request buffer size pro tcp connection")
+	flags.IntVar(&c.Proxy.ResponseBufferSize, "proxy-response-buffer-size", 4096, "Response buffer size pro tcp connection")
+	flags.IntVar(&c.Proxy.MaxInFlightRequests, "proxy-max-inflight-requests", 0, "Maximal number of pipelined requests pro tcp connection awaiting a response. When reached, reading of client requests is paused until responses arrive. If zero, the limit is disabled")
+	flags.IntVar(&c.Proxy.RequestLimits.MaxRequestSize, "proxy-max-request-size", 0, "Maximal size of a Kafka request in bytes. Larger produce requests are answered with MESSAGE_TOO_LARGE, connections sending other larger requests are closed. If zero, the limit is disabled")
+	flags.IntVar(&c.Proxy.RequestLimits.MaxBatchSize, "proxy-max-batch-size", 0, "Maximal size of a produced record batch in bytes. Partitions with larger batches are answered with MESSAGE_TOO_LARGE. If zero, the limit is disabled")
+	flags.Var(&c.Proxy.ListenerRequestLimits, "proxy-listener-limits", "Request limits of a listener '<listener or broker address>=<max request size>,<max batch size>' overriding proxy-max-request-size and proxy-max-batch-size")
+	flags.Var(&c.Proxy.ListenerAllowCIDRs, "proxy-listener-allow-cidr", "Client networks allowed to connect to a listener '<listener or broker address>=<cidr>[,<cidr>...]', connections from other networks are refused")
+	flags.Var(&c.Proxy.ListenerDenyCIDRs, "proxy-listener-deny-cidr", "Client networks refused by a listener '<listener or broker address>=<cidr>[,<cidr>...]', takes precedence over proxy-listener-allow-cidr")
+	flags.Var(&c.Proxy.CompressionTranscodings, "proxy-listener-compression-transcoding", "Compression transcoding of a listener '<listener or broker address>=<broker codec>:<client codec>' e.g. '0.0.0.0:32400=zstd:gzip'. Fetched record batches compressed with the broker codec are recompressed with the client codec, produced ones with the client codec are recompressed with the broker codec. Codecs are none, gzip, snappy, lz4 and zstd")
+	flags.BoolVar(&c.Proxy.Multiplex.Enable, "proxy-multiplex-enable", false, "Multiplex the client connections over pooled broker connections. SASL authentication of the clients with the brokers is not supported")
+	flags.IntVar(&c.Proxy.Multiplex.Connections, "proxy-multiplex-connections", 4, "Number of pooled connections per broker shared by the multiplexed client connections")
+	flags.Var(&c.Proxy.Quotas.Principals, "quota", "Quota of a principal (SASL user or client certificate common name) '<principal>=<produce bytes/s>,<fetch bytes/s>,<requests/s>'. The principal * is the default for other principals, 0 disables a limit. Responses to principals exceeding the quota are throttled")
+	flags.DurationVar(&c.Proxy.Quotas.Window, "quota-window", 10*time.Second, "Time window over which the quota rates are measured")
+	flags.Var(&c.Proxy.MaintenanceWindows, "maintenance-window", "Time window '[days] HH:MM-HH:MM [zone]' during which new client connections are refused e.g. 'Sat,Sun 02:00-04:00 Europe/Berlin'. The time zone defaults to UTC")
+
+	flags.StringArrayVar(&c.Proxy.Passthrough.Principals, "passthrough-principal", []string{}, "Trusted principal (SASL user or client certificate common name) which connection is spliced without policy enforcement, only broker addresses are rewritten")
+	flags.DurationVar(&c.Proxy.Deprecation.ThrottleTime, "deprecation-throttle-time", 0, "Throttle time injected into responses to deprecated clients. Clients supporting KIP-219 delay further requests accordingly, the proxy delays the next request of the connection as well. If 0, deprecation throttling is disabled")
+	flags.StringArrayVar(&c.Proxy.Deprecation.ClientIDs, "deprecation-client-id", []string{}, "Deprecated client id which responses are throttled")
+	flags.Var(&c.Proxy.MaxApiVersions, "proxy-max-api-version", "Maximal version of a Kafka request type '<api key>=<version>' advertised to the clients in ApiVersions responses e.g. '1=11'. Request types whose minimal broker version is higher are not advertised")
+	flags.Var(&c.Proxy.Deprecation.MinApiVersions, "deprecation-min-api-version", "Minimal not deprecated version of a Kafka request type '<api key>=<version>' e.g. '0=3'. Responses to requests with lower versions are throttled")
+
+	flags.IntVar(&c.Proxy.ListenerReadBufferSize, "proxy-listener-read-buffer-size", 0, "Size of the operating system's receive buffer associated with the connection. If zero, system default is used")
+	flags.IntVar(&c.Proxy.ListenerWriteBufferSize, "proxy-listener-write-buffer-size", 0, "Sets the size of the operating system's transmit buffer associated with the connection. If zero, system default is used")
+	flags.DurationVar(&c.Proxy.ListenerKeepAlive, "proxy-listener-keep-alive", 60*time.Second, "Keep alive period for an active network connection. If zero, keep-alives are disabled")
+	flags.DurationVar(&c.Proxy.HandshakeTimeout, "proxy-handshake-timeout", 0, "Time in which a client must complete the TLS handshake, the gateway and the local SASL authentication, otherwise the connection is closed. If zero, the handshake is not limited")
+	flags.BoolVar(&c.Proxy.CredentialExpiry.Enable, "proxy-credential-expiry-enable", false, "Close client connections when the client certificate, the gateway token or the SASL OAUTHBEARER token expires")
+	flags.DurationVar(&c.Proxy.CredentialExpiry.GracePeriod, "proxy-credential-expiry-grace-period", 0, "Time after the credential expiry before the client connection is closed")
+	flags.BoolVar(&c.Proxy.RequestLatency.Enable, "proxy-request-latency-enable", false, "Measure the round-trip latency of the requests per broker and api key")
+	flags.DurationVar(&c.Proxy.RequestLatency.SlowThreshold, "proxy-slow-request-threshold", 0, "Log requests with a latency exceeding the threshold with the api key, the topics and the size. If zero, slow requests are not logged")
+
+	flags.BoolVar(&c.Proxy.TLS.Enable, "proxy-listener-tls-enable", false, "Whether or not to use TLS listener")
+	flags.StringVar(&c.Proxy.TLS.ListenerCertFile, "proxy-listener-cert-file", "", "PEM encoded file with server certificate")
+	flags.StringVar(&c.Proxy.TLS.ListenerKeyFile, "proxy-listener-key-file", "", "PEM encoded file with private key for the server certificate")
+	flags.StringVar(&c.Proxy.TLS.ListenerKeyPassword, "proxy-listener-key-password", "", "Password to decrypt rsa private key")
+	flags.StringVar(&c.Proxy.TLS.CAChainCertFile, "proxy-listener-ca-chain-cert-file", "", "PEM encoded CA's certificate file. If provided, client certificate is required and verified")
+	flags.StringSliceVar(&c.Proxy.TLS.ListenerCipherSuites, "proxy-listener-cipher-suites", []string{}, "List of supported cipher suites")
+	flags.StringSliceVar(&c.Proxy.TLS.ListenerCurvePreferences, "proxy-listener-curve-preferences", []string{}, "List of curve preferences")
+
+	flags.BoolVar(&c.Proxy.TLS.ClientCert.ValidateSubject, "proxy-listener-tls-client-cert-validate-subject", false, "Whether to validate client certificate subject")
+	flags.StringVar(&c.Proxy.TLS.ClientCert.Subject.CommonName, "proxy-listener-tls-required-client-subject-common-name", "", "Required client certificate subject common name")
+	flags.StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.Country, "proxy-listener-tls-required-client-subject-country", []string{}, "Required client certificate subject country")
+	flags.StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.Province, "proxy-listener-tls-required-client-subject-province", []string{}, "Required client certificate subject province")
+	flags.StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.Locality, "proxy-listener-tls-required-client-subject-locality", []string{}, "Required client certificate subject locality")
+	flags.StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.Organization, "proxy-listener-tls-required-client-subject-organization", []string{}, "Required client certificate subject organization")
+	flags.StringSliceVar(&c.Proxy.TLS.ClientCert.Subject.OrganizationalUnit, "proxy-listener-tls-required-client-subject-organizational-unit", []string{}, "Required client certificate subject organizational unit")
 
 	// local authentication plugin
-	Server.Flags().BoolVar(&c.Auth.Local.Enable, "auth-local-enable", false, "Enable local SASL/PLAIN authentication performed by listener - SASL handshake will not be passed to kafka brokers")
-	Server.Flags().StringVar(&c.Auth.Local.Command, "auth-local-command", "", "Path to authentication plugin binary")
-	Server.Flags().StringVar(&c.Auth.Local.Mechanism, "auth-local-mechanism", "PLAIN", "SASL mechanism used for local authentication: PLAIN or OAUTHBEARER")
-	Server.Flags().StringArrayVar(&c.Auth.Local.Parameters, "auth-local-param", []string{}, "Authentication plugin parameter")
-	Server.Flags().StringVar(&c.Auth.Local.LogLevel, "auth-local-log-level", "trace", "Log level of the auth plugin")
-	Server.Flags().DurationVar(&c.Auth.Local.Timeout, "auth-local-timeout", 10*time.Second, "Authentication timeout")
-
-	Server.Flags().BoolVar(&c.Auth.Gateway.Client.Enable, "auth-gateway-client-enable", false, "Enable gateway client authentication")
-	Server.Flags().StringVar(&c.Auth.Gateway.Client.Command, "auth-gateway-client-command", "", "Path to authentication plugin binary")
-	Server.Flags().StringArrayVar(&c.Auth.Gateway.Client.Parameters, "auth-gateway-client-param", []string{}, "Authentication plugin parameter")
-	Server.Flags().StringVar(&c.Auth.Gateway.Client.LogLevel, "auth-gateway-client-log-level", "trace", "Log level of the auth plugin")
-	Server.Flags().StringVar(&c.Auth.Gateway.Client.Method, "auth-gateway-client-method", "", "Authentication method")
-	Server.Flags().Uint64Var(&c.Auth.Gateway.Client.Magic, "auth-gateway-client-magic", 0, "Magic bytes sent in the handshake")
-	Server.Flags().DurationVar(&c.Auth.Gateway.Client.Timeout, "auth-gateway-client-timeout", 10*time.Second, "Authentication timeout")
-
-	Server.Flags().BoolVar(&c.Auth.Gateway.Server.Enable, "auth-gateway-server-enable", false, "Enable proxy server authentication")
-	Server.Flags().StringVar(&c.Auth.Gateway.Server.Command, "auth-gateway-server-command", "", "Path to authentication plugin binary")
-	Server.Flags().StringArrayVar(&c.Auth.Gateway.Server.Parameters, "auth-gateway-server-param", []string{}, "Authentication plugin parameter")
-	Server.Flags().StringVar(&c.Auth.Gateway.Server.LogLevel, "auth-gateway-server-log-level", "trace", "Log level of the auth plugin")
-	Server.Flags().StringVar(&c.Auth.Gateway.Server.Method, "auth-gateway-server-method", "", "Authentication method")
-	Server.Flags().Uint64Var(&c.Auth.Gateway.Server.Magic, "auth-gateway-server-magic", 0, "Magic bytes sent in the handshake")
-	Server.Flags().DurationVar(&c.Auth.Gateway.Server.Timeout, "auth-gateway-server-timeout", 10*time.Second, "Authentication timeout")
+	flags.BoolVar(&c.Auth.Local.Enable, "auth-local-enable", false, "Enable local SASL/PLAIN authentication performed by listener - SASL handshake will not be passed to kafka brokers")
+	flags.StringVar(&c.Auth.Local.Command, "auth-local-command", "", "Path to authentication plugin binary, WASM module (.wasm) or built-in plugin (builtin:<name>)")
+	flags.StringVar(&c.Auth.Local.Mechanism, "auth-local-mechanism", "PLAIN", "SASL mechanism used for local authentication: PLAIN or OAUTHBEARER")
+	flags.StringArrayVar(&c.Auth.Local.Parameters, "auth-local-param", []string{}, "Authentication plugin parameter")
+	flags.StringVar(&c.Auth.Local.LogLevel, "auth-local-log-level", "trace", "Log level of the auth plugin")
+	flags.DurationVar(&c.Auth.Local.Timeout, "auth-local-timeout", 10*time.Second, "Authentication timeout")
+	flags.StringVar(&c.Auth.Local.PrincipalClaim, "auth-local-principal-claim", "", "Claim returned by the OAUTHBEARER token info plugin used as principal. If not set or not returned, the subject is used")
+
+	flags.BoolVar(&c.Auth.Gateway.Client.Enable, "auth-gateway-client-enable", false, "Enable gateway client authentication")
+	flags.StringVar(&c.Auth.Gateway.Client.Command, "auth-gateway-client-command", "", "Path to authentication plugin binary, WASM module (.wasm) or built-in plugin (builtin:<name>)")
+	flags.StringArrayVar(&c.Auth.Gateway.Client.Parameters, "auth-gateway-client-param", []string{}, "Authentication plugin parameter")
+	flags.StringVar(&c.Auth.Gateway.Client.LogLevel, "auth-gateway-client-log-level", "trace", "Log level of the auth plugin")
+	flags.StringVar(&c.Auth.Gateway.Client.Method, "auth-gateway-client-method", "", "Authentication method")
+	flags.Uint64Var(&c.Auth.Gateway.Client.Magic, "auth-gateway-client-magic", 0, "Magic bytes sent in the handshake")
+	flags.DurationVar(&c.Auth.Gateway.Client.Timeout, "auth-gateway-client-timeout", 10*time.Second, "Authentication timeout")
+
+	flags.BoolVar(&c.Auth.Gateway.Server.Enable, "auth-gateway-server-enable", false, "Enable proxy server authentication")
+	flags.StringVar(&c.Auth.Gateway.Server.Command, "auth-gateway-server-command", "", "Path to authentication plugin binary, WASM module (.wasm) or built-in plugin (builtin:<name>)")
+	flags.StringArrayVar(&c.Auth.Gateway.Server.Parameters, "auth-gateway-server-param", []string{}, "Authentication plugin parameter")
+	flags.StringVar(&c.Auth.Gateway.Server.LogLevel, "auth-gateway-server-log-level", "trace", "Log level of the auth plugin")
+	flags.StringVar(&c.Auth.Gateway.Server.Method, "auth-gateway-server-method", "", "Authentication method")
+	flags.Uint64Var(&c.Auth.Gateway.Server.Magic, "auth-gateway-server-magic", 0, "Magic bytes sent in the handshake")
+	flags.DurationVar(&c.Auth.Gateway.Server.Timeout, "auth-gateway-server-timeout", 10*time.Second, "Authentication timeout")
+	flags.StringVar(&c.Auth.Gateway.Server.PrincipalClaim, "auth-gateway-server-principal-claim", "", "Claim returned by the token info plugin used as principal. If not set or not returned, the subject is used")
+
+	// brute-force protection
+	flags.IntVar(&c.Auth.BruteForce.MaxFailures, "auth-ban-max-failures", 0, "Failed gateway or local authentications from a client address within the ban window after which the address is banned. If 0, client addresses are not banned")
+	flags.DurationVar(&c.Auth.BruteForce.Window, "auth-ban-window", 1*time.Minute, "Window in which the failed authentications of a client address are counted")
+	flags.DurationVar(&c.Auth.BruteForce.BanDuration, "auth-ban-duration", 5*time.Minute, "Duration for which new connections from a banned client address are rejected")
+	flags.DurationVar(&c.Auth.Cache.TTL, "auth-cache-ttl", 0, "Duration for which successful decisions of the local and gateway auth plugins are cached, but not beyond the token expiry. If 0, successful decisions are not cached")
+	flags.DurationVar(&c.Auth.Cache.NegativeTTL, "auth-cache-negative-ttl", 0, "Duration for which failed decisions of the local and gateway auth plugins are cached. If 0, failed decisions are not cached")
+	flags.IntVar(&c.Auth.Cache.MaxEntries, "auth-cache-max-entries", 10000, "Maximal number of cached auth decisions")
+
+	flags.Var(&c.Auth.ListenerPolicies, "auth-listener-policy", "Authentication policy of a listener '<listener or broker address>=<option>=<value>,...' with the options local-auth (default, disabled or auth plugin name), gateway-auth (default, enabled or disabled) and client-cert (default, required, optional or none)")
+	flags.Var(&c.Auth.Plugins, "auth-plugin", "Local authentication plugin used by listener auth policies '<name>=<mechanism>,<command>'. Mechanism is PLAIN or OAUTHBEARER")
+	flags.Var(&c.Auth.PluginParams, "auth-plugin-param", "Parameter of a local authentication plugin '<name>=<parameter>'")
 
 	// kafka
-	Server.Flags().StringVar(&c.Kafka.ClientID, "kafka-client-id", "kafka-proxy", "An optional identifier to track the source of requests")
-	Server.Flags().IntVar(&c.Kafka.MaxOpenRequests, "kafka-max-open-requests", 256, "Maximal number of open requests pro tcp connection before sending on it blocks")
-	Server.Flags().DurationVar(&c.Kafka.DialTimeout, "kafka-dial-timeout", 15*time.Second, "How long to wait for the initial connection")
-	Server.Flags().DurationVar(&c.Kafka.WriteTimeout, "kafka-write-timeout", 30*time.Second, "How long to wait for a transmit")
-	Server.Flags().DurationVar(&c.Kafka.ReadTimeout, "kafka-read-timeout", 30*time.Second, "How long to wait for a response")
-	Server.Flags().DurationVar(&c.Kafka.KeepAlive, "kafka-keep-alive", 60*time.Second, "Keep alive period for an active network connection. If zero, keep-alives are disabled")
-	Server.Flags().IntVar(&c.Kafka.ConnectionReadBufferSize, "kafka-connection-read-buffer-size", 0, "Size of the operating system's receive buffer associated with the connection. If zero, system default is used")
-	Server.Flags().IntVar(&c.Kafka.ConnectionWriteBufferSize, "kafka-connection-write-buffer-size", 0, "Sets the size of the operating system's transmit buffer associated with the connection. If zero, system default is used")
+	flags.StringVar(&c.Kafka.ClientID, "kafka-client-id", "kafka-proxy", "An optional identifier to track the source of requests")
+	flags.IntVar(&c.Kafka.MaxOpenRequests, "kafka-max-open-requests", 256, "Maximal number of open requests pro tcp connection before sending on it blocks")
+	flags.BoolVar(&c.Kafka.StartupCheck, "kafka-startup-check", false, "Exit at startup if none of the bootstrap brokers is reachable")
+	flags.DurationVar(&c.Kafka.DialTimeout, "kafka-dial-timeout", 15*time.Second, "How long to wait for the initial connection")
+	flags.IntVar(&c.Kafka.DialRetry.Retries, "kafka-dial-retries", 0, "How many times a failed broker connection is retried before the client connection is closed")
+	flags.DurationVar(&c.Kafka.DialRetry.Backoff, "kafka-dial-retry-backoff", 100*time.Millisecond, "Backoff before the first retry of a failed broker connection, doubled on each retry")
+	flags.DurationVar(&c.Kafka.DialRetry.MaxBackoff, "kafka-dial-retry-max-backoff", 5*time.Second, "Maximal backoff between the retries of a failed broker connection")
+	flags.Float64Var(&c.Kafka.DialRetry.Jitter, "kafka-dial-retry-jitter", 0.2, "Random fraction of the backoff added or subtracted, between 0 and 1")
+	flags.StringVar(&c.Kafka.DNS.Server, "kafka-dns-server", "", "DNS server (host:port) queried for the SRV records of srv:// dial addresses e.g. the Consul DNS interface 127.0.0.1:8600. If empty, the system resolver is used")
+	flags.DurationVar(&c.Kafka.DNS.SRVTTL, "kafka-dns-srv-ttl", 30*time.Second, "Time the SRV records resolved by the system resolver are cached. Records of the DNS server are cached for their TTL")
+	flags.DurationVar(&c.Kafka.DNS.Timeout, "kafka-dns-timeout", 5*time.Second, "Timeout of the SRV record lookup")
+	flags.DurationVar(&c.Kafka.WriteTimeout, "kafka-write-timeout", 30*time.Second, "How long to wait for a transmit")
+	flags.DurationVar(&c.Kafka.ReadTimeout, "kafka-read-timeout", 30*time.Second, "How long to wait for a response")
+	flags.DurationVar(&c.Kafka.KeepAlive, "kafka-keep-alive", 60*time.Second, "Keep alive period for an active network connection. If zero, keep-alives are disabled")
+	flags.IntVar(&c.Kafka.ConnectionReadBufferSize, "kafka-connection-read-buffer-size", 0, "Size of the operating system's receive buffer associated with the connection. If zero, system default is used")
+	flags.IntVar(&c.Kafka.ConnectionWriteBufferSize, "kafka-connection-write-buffer-size", 0, "Sets the size of the operating system's transmit buffer associated with the connection. If zero, system default is used")
 
 	// http://kafka.apache.org/protocol.html#protocol_api_keys
-	Server.Flags().IntSliceVar(&c.Kafka.ForbiddenApiKeys, "forbidden-api-keys", []int{}, "Forbidden Kafka request types. The restriction should prevent some Kafka operations e.g. 20 - DeleteTopics")
+	flags.IntSliceVar(&c.Kafka.ForbiddenApiKeys, "forbidden-api-keys", []int{}, "Forbidden Kafka request types. The restriction should prevent some Kafka operations e.g. 20 - DeleteTopics")
+	flags.Var(&c.Kafka.ScheduledForbiddenApiKeys, "scheduled-forbidden-api-keys", "Kafka request types forbidden during a time window '[days] HH:MM-HH:MM [zone]=<api keys>' e.g. 'Mon-Fri 08:00-18:00 America/New_York=19,20'. The time zone defaults to UTC")
 
-	Server.Flags().BoolVar(&c.Kafka.Producer.Acks0Disabled, "producer-acks-0-disabled", false, "Assume fire-and-forget is never sent by the producer. Enabling this parameter will increase performance")
+	flags.BoolVar(&c.Kafka.Producer.Acks0Disabled, "producer-acks-0-disabled", false, "Assume fire-and-forget is never sent by the producer. Enabling this parameter will increase performance")
+	flags.Var(&c.Kafka.Producer.TopicWatermarks, "producer-topic-watermark", "Alert when the records or bytes produced to a topic within the sliding window cross the watermark 'topic=<name|*>,window=<duration>,max-records=<n>,max-bytes=<n>,min-records=<n>' e.g. 'topic=orders,window=5m,min-records=1'. Records of passthrough clients are not counted, the min-records alerts are suppressed while they are connected")
 
 	// TLS
-	Server.Flags().BoolVar(&c.Kafka.TLS.Enable, "tls-enable", false, "Whether or not to use TLS when connecting to the broker")
-	Server.Flags().BoolVar(&c.Kafka.TLS.InsecureSkipVerify, "tls-insecure-skip-verify", false, "It controls whether a client verifies the server's certificate chain and host name")
-	Server.Flags().StringVar(&c.Kafka.TLS.ClientCertFile, "tls-client-cert-file", "", "PEM encoded file with client certificate")
-	Server.Flags().StringVar(&c.Kafka.TLS.ClientKeyFile, "tls-client-key-file", "", "PEM encoded file with private key for the client certificate")
-	Server.Flags().StringVar(&c.Kafka.TLS.ClientKeyPassword, "tls-client-key-password", "", "Password to decrypt rsa private key")
-	Server.Flags().StringVar(&c.Kafka.TLS.CAChainCertFile, "tls-ca-chain-cert-file", "", "PEM encoded CA's certificate file")
+	flags.BoolVar(&c.Kafka.TLS.Enable, "tls-enable", false, "Whether or not to use TLS when connecting to the broker")
+	flags.BoolVar(&c.Kafka.TLS.InsecureSkipVerify, "tls-insecure-skip-verify", false, "It controls whether a client verifies the server's certificate chain and host name")
+	flags.StringVar(&c.Kafka.TLS.ClientCertFile, "tls-client-cert-file", "", "PEM encoded file with client certificate")
+	flags.StringVar(&c.Kafka.TLS.ClientKeyFile, "tls-client-key-file", "", "PEM encoded file with private key for the client certificate")
+	flags.StringVar(&c.Kafka.TLS.ClientKeyPassword, "tls-client-key-password", "", "Password to decrypt rsa private key")
+	flags.StringVar(&c.Kafka.TLS.CAChainCertFile, "tls-ca-chain-cert-file", "", "PEM encoded CA's certificate file")
 
 	//Same TLS client cert tls-same-client-cert-enable
-	Server.Flags().BoolVar(&c.Kafka.TLS.SameClientCertEnable, "tls-same-client-cert-enable", false, "Use only when mutual TLS is enabled on proxy and broker. It controls whether a proxy validates if proxy client certificate exactly matches brokers client cert (tls-client-cert-file)")
+	flags.BoolVar(&c.Kafka.TLS.SameClientCertEnable, "tls-same-client-cert-enable", false, "Use only when mutual TLS is enabled on proxy and broker. It controls whether a proxy validates if proxy client certificate exactly matches brokers client cert (tls-client-cert-file)")
 
 	// SASL by Proxy
-	Server.Flags().BoolVar(&c.Kafka.SASL.Enable, "sasl-enable", false, "Connect using SASL")
-	Server.Flags().StringVar(&c.Kafka.SASL.Username, "sasl-username", "", "SASL user name")
-	Server.Flags().StringVar(&c.Kafka.SASL.Password, "sasl-password", "", "SASL user password")
-	Server.Flags().StringVar(&c.Kafka.SASL.JaasConfigFile, "sasl-jaas-config-file", "", "Location of JAAS config file with SASL username and password")
-	Server.Flags().StringVar(&c.Kafka.SASL.Method, "sasl-method", "PLAIN", "SASL method to use (PLAIN, SCRAM-SHA-256, SCRAM-SHA-512")
+	flags.BoolVar(&c.Kafka.SASL.Enable, "sasl-enable", false, "Connect using SASL")
+	flags.StringVar(&c.Kafka.SASL.Username, "sasl-username", "", "SASL user name")
+	flags.StringVar(&c.Kafka.SASL.Password, "sasl-password", "", "SASL user password")
+	flags.StringVar(&c.Kafka.SASL.JaasConfigFile, "sasl-jaas-config-file", "", "Location of JAAS config file with SASL username and password. The file is re-read on changes")
+	flags.StringVar(&c.Kafka.SASL.Vault.Address, "sasl-vault-address", "", "Address of the Vault server with the SASL credentials e.g. https://vault:8200")
+	flags.StringVar(&c.Kafka.SASL.Vault.TokenFile, "sasl-vault-token-file", "", "File with the Vault token. The file is read on each request")
+	flags.StringVar(&c.Kafka.SASL.Vault.Path, "sasl-vault-path", "", "Path of the Vault KV secret with the keys username and password e.g. secret/data/kafka-proxy")
+	flags.DurationVar(&c.Kafka.SASL.Vault.RefreshInterval, "sasl-vault-refresh-interval", 5*time.Minute, "How often the SASL credentials are re-read from Vault")
+	flags.StringVar(&c.Kafka.SASL.Method, "sasl-method", "PLAIN", "SASL method to use (PLAIN, SCRAM-SHA-256, SCRAM-SHA-512")
 
 	// SASL by Proxy plugin
-	Server.Flags().BoolVar(&c.Kafka.SASL.Plugin.Enable, "sasl-plugin-enable", false, "Use plugin for SASL authentication")
-	Server.Flags().StringVar(&c.Kafka.SASL.Plugin.Command, "sasl-plugin-command", "", "Path to authentication plugin binary")
-	Server.Flags().StringVar(&c.Kafka.SASL.Plugin.Mechanism, "sasl-plugin-mechanism", "OAUTHBEARER", "SASL mechanism used for proxy authentication: PLAIN or OAUTHBEARER")
-	Server.Flags().StringArrayVar(&c.Kafka.SASL.Plugin.Parameters, "sasl-plugin-param", []string{}, "Authentication plugin parameter")
-	Server.Flags().StringVar(&c.Kafka.SASL.Plugin.LogLevel, "sasl-plugin-log-level", "trace", "Log level of the auth plugin")
-	Server.Flags().DurationVar(&c.Kafka.SASL.Plugin.Timeout, "sasl-plugin-timeout", 10*time.Second, "Authentication timeout")
+	flags.BoolVar(&c.Kafka.SASL.Plugin.Enable, "sasl-plugin-enable", false, "Use plugin for SASL authentication")
+	flags.StringVar(&c.Kafka.SASL.Plugin.Command, "sasl-plugin-command", "", "Path to authentication plugin binary, WASM module (.wasm) or built-in plugin (builtin:<name>)")
+	flags.StringVar(&c.Kafka.SASL.Plugin.Mechanism, "sasl-plugin-mechanism", "OAUTHBEARER", "SASL mechanism used for proxy authentication: PLAIN or OAUTHBEARER")
+	flags.StringArrayVar(&c.Kafka.SASL.Plugin.Parameters, "sasl-plugin-param", []string{}, "Authentication plugin parameter")
+	flags.StringVar(&c.Kafka.SASL.Plugin.LogLevel, "sasl-plugin-log-level", "trace", "Log level of the auth plugin")
+	flags.DurationVar(&c.Kafka.SASL.Plugin.Timeout, "sasl-plugin-timeout", 10*time.Second, "Authentication timeout")
+	flags.BoolVar(&c.Kafka.SASL.Reauthentication, "sasl-reauthentication-enable", false, "Re-authenticate the broker connections with a new token of the SASL plugin before the session set by the broker 'connections.max.reauth.ms' expires (KIP-368). Requires SaslAuthenticate v1 (Kafka 2.2+)")
 
 	// Web
-	Server.Flags().BoolVar(&c.Http.Disable, "http-disable", false, "Disable HTTP endpoints")
-	Server.Flags().StringVar(&c.Http.ListenAddress, "http-listen-address", "0.0.0.0:9080", "Address that kafka-proxy is listening on")
-	Server.Flags().StringVar(&c.Http.MetricsPath, "http-metrics-path", "/metrics", "Path on which to expose metrics")
-	Server.Flags().StringVar(&c.Http.HealthPath, "http-health-path", "/health", "Path on which to health endpoint")
+	flags.BoolVar(&c.Http.Disable, "http-disable", false, "Disable HTTP endpoints")
+	flags.StringVar(&c.Http.ListenAddress, "http-listen-address", "0.0.0.0:9080", "Address that kafka-proxy is listening on")
+	flags.StringVar(&c.Http.MetricsPath, "http-metrics-path", "/metrics", "Path on which to expose metrics")
+	flags.StringVar(&c.Http.HealthPath, "http-health-path", "/health", "Path on which to health endpoint")
+	flags.BoolVar(&c.Http.Validate.Enable, "http-validate-enable", false, "Enable endpoint validating candidate configurations (POST server arguments as JSON {\"args\": [...]})")
+	flags.StringVar(&c.Http.Validate.Path, "http-validate-path", "/validate", "Path on which to expose configuration validation endpoint")
+	flags.StringVar(&c.Http.Validate.TokenFile, "http-validate-token-file", "", "Path to the file containing the bearer token required by the configuration validation endpoint. The file is read on each request")
+	flags.BoolVar(&c.Http.Reload.Enable, "http-reload-enable", false, "Enable endpoint reloading the configuration like SIGHUP (POST)")
+	flags.StringVar(&c.Http.Reload.Path, "http-reload-path", "/reload", "Path on which to expose configuration reload endpoint")
+	flags.StringVar(&c.Http.Reload.TokenFile, "http-reload-token-file", "", "Path to the file containing the bearer token required by the configuration reload endpoint. The file is read on each request")
+	flags.BoolVar(&c.Http.Bootstrap.Enable, "http-bootstrap-enable", false, "Enable endpoint returning the advertised addresses of the bootstrap server mappings (GET)")
+	flags.StringVar(&c.Http.Bootstrap.Path, "http-bootstrap-path", "/bootstrap", "Path on which to expose bootstrap addresses endpoint")
+	flags.BoolVar(&c.Http.Connections.Enable, "http-connections-enable", false, "Enable endpoint listing the active client connections (GET) and closing a connection (DELETE <path>/<id>)")
+	flags.StringVar(&c.Http.Connections.Path, "http-connections-path", "/api/connections", "Path on which to expose client connections endpoint")
+	flags.StringVar(&c.Http.Connections.TokenFile, "http-connections-token-file", "", "Path to the file containing the bearer token required by the client connections endpoint. The file is read on each request")
+	flags.BoolVar(&c.Http.AuthCache.Enable, "http-auth-cache-enable", false, "Enable endpoint invalidating the cached auth decisions of a principal (DELETE <path>?principal=<principal>) or all cached decisions (DELETE)")
+	flags.StringVar(&c.Http.AuthCache.Path, "http-auth-cache-path", "/api/auth-cache", "Path on which to expose auth cache endpoint")
+	flags.StringVar(&c.Http.AuthCache.TokenFile, "http-auth-cache-token-file", "", "Path to the file containing the bearer token required by the auth cache endpoint. The file is read on each request")
+
+	// StatsD
+	flags.BoolVar(&c.Statsd.Enable, "statsd-enable", false, "Enable export of metrics to StatsD agent using DogStatsD format")
+	flags.StringVar(&c.Statsd.Address, "statsd-address", "127.0.0.1:8125", "UDP address of the StatsD agent")
+	flags.StringVar(&c.Statsd.Prefix, "statsd-prefix", "kafka_proxy.", "Prefix of StatsD metric names")
+	flags.DurationVar(&c.Statsd.FlushInterval, "statsd-flush-interval", 10*time.Second, "How often metrics are sent to StatsD agent")
+	flags.StringArrayVar(&c.Statsd.Tags, "statsd-tag", []string{}, "Tag added to all StatsD metrics (key:value)")
+
+	// Kubernetes
+	flags.BoolVar(&c.Kubernetes.ConfigMap.Enable, "kubernetes-configmap-enable", false, "Read options from a ConfigMap using the pod service account and reload them on changes")
+	flags.StringVar(&c.Kubernetes.ConfigMap.Namespace, "kubernetes-configmap-namespace", "", "Namespace of the ConfigMap. Defaults to the pod namespace")
+	flags.StringVar(&c.Kubernetes.ConfigMap.Name, "kubernetes-configmap-name", "kafka-proxy", "Name of the ConfigMap")
+	flags.StringVar(&c.Kubernetes.ConfigMap.Key, "kubernetes-configmap-key", "kafka-proxy.yaml", "ConfigMap key with the YAML (.yaml, .yml) or TOML (.toml) options named like the flags")
+	flags.DurationVar(&c.Kubernetes.ConfigMap.PollInterval, "kubernetes-configmap-poll-interval", 10*time.Second, "How often the ConfigMap is checked for changes")
 
 	// Debug
-	Server.Flags().BoolVar(&c.Debug.Enabled, "debug-enable", false, "Enable Debug endpoint")
-	Server.Flags().StringVar(&c.Debug.ListenAddress, "debug-listen-address", "0.0.0.0:6060", "Debug listen address")
+	flags.BoolVar(&c.Debug.Enabled, "debug-enable", false, "Enable Debug endpoint")
+	flags.StringVar(&c.Debug.ListenAddress, "debug-listen-address", "0.0.0.0:6060", "Debug listen address")
+	flags.StringVar(&c.Debug.StatePath, "debug-state-path", "/debug/state", "Path on which to expose the internal state (listeners, connection and cache sizes, plugin status) on the debug listener")
+	flags.StringVar(&c.Debug.TokenFile, "debug-token-file", "", "Path to the file containing the bearer token required by the debug endpoints. The file is read on each request")
 
 	// Logging
-	Server.Flags().StringVar(&c.Log.Format, "log-format", "text", "Log format text or json")
-	Server.Flags().StringVar(&c.Log.Level, "log-level", "info", "Log level debug, info, warning, error, fatal or panic")
-	Server.Flags().StringVar(&c.Log.LevelFieldName, "log-level-fieldname", "@level", "Log level fieldname for json format")
-	Server.Flags().StringVar(&c.Log.TimeFiledName, "log-time-fieldname", "@timestamp", "Time fieldname for json format")
-	Server.Flags().StringVar(&c.Log.MsgFiledName, "log-msg-fieldname", "@message", "Message fieldname for json format")
+	flags.StringVar(&c.Log.Format, "log-format", "text", "Log format text or json")
+	flags.StringVar(&c.Log.Level, "log-level", "info", "Log level debug, info, warning, error, fatal or panic")
+	flags.Var(&c.Log.SubsystemLevels, "log-subsystem-level", fmt.Sprintf("Log level of a subsystem '<subsystem>=<level>' overriding log-level, subsystems are %v", config.LogSubsystems))
+	flags.StringVar(&c.Log.LevelFieldName, "log-level-fieldname", "@level", "Log level fieldname for json format")
+	flags.StringVar(&c.Log.TimeFiledName, "log-time-fieldname", "@timestamp", "Time fieldname for json format")
+	flags.StringVar(&c.Log.MsgFiledName, "log-msg-fieldname", "@message", "Message fieldname for json format")
+	flags.StringVar(&c.Log.StartupErrorFormat, "log-startup-error-format", "text", "Format of the startup error report: text or json. The json report is written to stderr")
+	flags.StringVar(&c.Log.TimeZone, "log-time-zone", "", "Time zone of the RFC 3339 log timestamps e.g. UTC or Europe/Berlin. Defaults to the local time zone")
+
+	// Request interceptor plugin
+	flags.BoolVar(&c.Interceptor.Enable, "interceptor-enable", false, "Pass requests through an interceptor plugin which can deny or annotate them")
+	flags.StringVar(&c.Interceptor.Command, "interceptor-command", "", "Path to interceptor plugin binary or built-in plugin (builtin:<name>)")
+	flags.StringArrayVar(&c.Interceptor.Parameters, "interceptor-param", []string{}, "Interceptor plugin parameter")
+	flags.StringVar(&c.Interceptor.LogLevel, "interceptor-log-level", "trace", "Log level of the interceptor plugin")
+	flags.DurationVar(&c.Interceptor.Timeout, "interceptor-timeout", time.Second, "Interceptor plugin call timeout")
+	flags.BoolVar(&c.Interceptor.Responses, "interceptor-responses", false, "Pass response metadata to the interceptor plugin")
+	flags.BoolVar(&c.Interceptor.RecordHeaders, "interceptor-record-headers", false, "Pass the headers of records in produce requests up to v8 to the interceptor plugin")
+
+	// Topic policy
+	flags.StringArrayVar(&c.TopicPolicy.NamePatterns, "topic-policy-name-pattern", []string{}, "Regular expression which names of created topics must match")
+	flags.IntVar(&c.TopicPolicy.MinReplicationFactor, "topic-policy-min-replication-factor", 0, "Minimal replication factor of created topics. The replication factor must be set explicitly. If zero, replication factor is not checked")
+	flags.IntVar(&c.TopicPolicy.MaxPartitions, "topic-policy-max-partitions", 0, "Maximal number of partitions of created topics. CreatePartitions requests are forbidden. If zero, number of partitions is not checked")
+	flags.StringArrayVar(&c.TopicPolicy.RequiredConfigs, "topic-policy-required-config", []string{}, "Config which must be set for created topics. AlterConfigs and IncrementalAlterConfigs requests are forbidden. The value has the form <name> or <name>=<value>")
+	flags.StringArrayVar(&c.TopicPolicy.DeleteProtectedPatterns, "topic-policy-delete-protected-pattern", []string{}, "Regular expression of topic names which must not be deleted")
+
+	// Schema validation
+	flags.StringArrayVar(&c.SchemaValidation.Topics, "schema-validation-topic", []string{}, "Reject produced partitions of the topic with record values which do not reference a registered schema. The topic * matches all topics")
+	flags.StringVar(&c.SchemaValidation.RegistryURL, "schema-registry-url", "", "URL of the Confluent compatible Schema Registry e.g. http://schema-registry:8081")
+	flags.StringVar(&c.SchemaValidation.RegistryUsername, "schema-registry-username", "", "Schema Registry basic auth username")
+	flags.StringVar(&c.SchemaValidation.RegistryPassword, "schema-registry-password", "", "Schema Registry basic auth password")
+	flags.StringVar(&c.SchemaValidation.SubjectNameStrategy, "schema-validation-subject-strategy", "topic", "Subject name strategy: topic (schema must be registered under <topic>-value) or any (schema must be registered)")
+	flags.DurationVar(&c.SchemaValidation.Timeout, "schema-registry-timeout", 5*time.Second, "Schema Registry call timeout")
+	flags.DurationVar(&c.SchemaValidation.CacheTTL, "schema-registry-cache-ttl", 10*time.Minute, "Time to cache the subjects of a schema id")
+
+	// Payload encryption
+	flags.Var(&c.Encryption.Topics, "encryption-topic", "Encrypt produced record values of the topic with a data key of the named key and decrypt them in fetch responses. The value has the form <topic>=<key name>, the topic * matches all other topics")
+	flags.StringVar(&c.Encryption.KeyProvider, "encryption-key-provider", "local", "Provider of the key encryption keys: local or vault")
+	flags.StringVar(&c.Encryption.LocalKeyFile, "encryption-local-key-file", "", "Path to the file containing the base64 encoded 256 bit master key of the local key provider")
+	flags.StringVar(&c.Encryption.VaultAddress, "encryption-vault-address", "", "Address of the Vault server e.g. https://vault:8200")
+	flags.StringVar(&c.Encryption.VaultTokenFile, "encryption-vault-token-file", "", "Path to the file containing the Vault token, re-read on each request")
+	flags.StringVar(&c.Encryption.VaultTransitMount, "encryption-vault-transit-mount", "transit", "Mount path of the Vault transit secrets engine")
+	flags.DurationVar(&c.Encryption.DataKeyTTL, "encryption-data-key-ttl", time.Hour, "Time after which a new data key is generated for encryption")
+	flags.DurationVar(&c.Encryption.Timeout, "encryption-timeout", 5*time.Second, "Key provider call timeout")
+
+	// Mirroring
+	flags.BoolVar(&c.Mirror.Enable, "mirror-enable", false, "Duplicate the produce requests asynchronously to a second cluster. The requests are sent with acks=0 and dropped when the queue is full")
+	flags.StringSliceVar(&c.Mirror.BootstrapServers, "mirror-bootstrap-server", []string{}, "Bootstrap server address of the mirror cluster")
+	flags.StringArrayVar(&c.Mirror.Listeners, "mirror-listener", []string{}, "Listener or broker address whose produce requests are mirrored. If empty, the produce requests of all listeners are mirrored")
+	flags.IntVar(&c.Mirror.QueueSize, "mirror-queue-size", 1000, "Maximum number of produce requests awaiting to be mirrored")
+	flags.DurationVar(&c.Mirror.MetadataMaxAge, "mirror-metadata-max-age", 5*time.Minute, "Time after which the partition leaders of the mirror cluster are refreshed")
+	flags.BoolVar(&c.Mirror.TLSEnable, "mirror-tls-enable", false, "Connect to the mirror cluster using TLS with the Kafka TLS settings")
+
+	// Tenancy
+	flags.Var(&c.Tenancy.ListenerPrefixes, "tenancy-listener-prefix", "Prefix of the topic names, group ids and transactional ids of the clients of a listener '<address>=<prefix>' e.g. '0.0.0.0:32400=tenant-a.'. The address is the listener address or the broker address")
+	flags.Var(&c.Tenancy.PrincipalPrefixes, "tenancy-principal-prefix", "Prefix of the topic names, group ids and transactional ids of the clients of a principal '<principal>=<prefix>' e.g. 'alice=tenant-a.'. The principal prefix takes precedence over the listener prefix. Principals without a prefix on listeners without a prefix are not namespaced")
+
+	// Fault injection
+	flags.BoolVar(&c.Chaos.Enable, "chaos-enable", false, "Inject faults into the client connections to test the client retries. Not intended for production use")
+	flags.StringArrayVar(&c.Chaos.Listeners, "chaos-listener", []string{}, "Listener or broker address whose connections are subject to fault injection. If empty, the connections of all listeners are subject to fault injection")
+	flags.Float64Var(&c.Chaos.ConnectionPercentage, "chaos-connection-percentage", 100, "Percentage of the new connections with injected faults")
+	flags.DurationVar(&c.Chaos.Latency, "chaos-latency", 0, "Latency added to each response")
+	flags.Float64Var(&c.Chaos.ResetRate, "chaos-reset-rate", 0, "Probability between 0 and 1 that the client connection is reset instead of forwarding a response")
+	flags.Float64Var(&c.Chaos.TruncateRate, "chaos-truncate-rate", 0, "Probability between 0 and 1 that a response is truncated and the client connection is closed")
+	flags.DurationVar(&c.Chaos.SASLDelay, "chaos-sasl-delay", 0, "Delay of the SASL handshake and authenticate responses")
+
+	// Privacy
+	flags.BoolVar(&c.Privacy.Pseudonymize, "privacy-pseudonymize", false, "Replace principals, client ids and client addresses in logs, interceptor audit events and the client connections endpoint with a keyed HMAC pseudonym")
+	flags.StringVar(&c.Privacy.KeyFile, "privacy-key-file", "", "Path to the file containing the HMAC key (at least 16 bytes) used for pseudonymization")
+
+	// Plugin supervision
+	flags.DurationVar(&c.Plugin.HealthCheckInterval, "plugin-health-check-interval", 10*time.Second, "Interval of plugin health checks. Unhealthy plugins are restarted. If zero, plugins are not supervised")
+	flags.DurationVar(&c.Plugin.MaxRestartBackoff, "plugin-max-restart-backoff", time.Minute, "Maximal delay between plugin restart attempts")
+	flags.StringVar(&c.Plugin.FailPolicy, "plugin-fail-policy", "closed", "Result of interceptor calls while a plugin is down: closed (deny) or open (allow) for at most plugin-max-fail-open. Authentication and token providers always fail closed, established connections are kept")
+	flags.DurationVar(&c.Plugin.MaxFailOpen, "plugin-max-fail-open", time.Minute, "Maximal duration for which interceptor calls are allowed while a plugin is down with the open fail policy, afterwards they fail closed")
 
 	// Connect through Socks5 or HTTP CONNECT to Kafka
-	Server.Flags().StringVar(&c.ForwardProxy.Url, "forward-proxy", "", "URL of the forward proxy. Supported schemas are socks5 and http")
-
-	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-	viper.AutomaticEnv() // read in environment variables that match
+	flags.StringVar(&c.ForwardProxy.Url, "forward-proxy", "", "URL of the forward proxy. Supported schemas are socks5, http and https")
+	flags.StringVar(&c.ForwardProxy.BearerTokenFile, "forward-proxy-bearer-token-file", "", "Path to the file containing the bearer token sent to http and https forward proxies in the Proxy-Authorization header")
+	flags.StringVar(&c.ForwardProxy.CAChainCertFile, "forward-proxy-ca-chain-cert-file", "", "PEM encoded CA's certificate file verifying https forward proxies. If empty, the system roots are used")
+	flags.Var(&c.ForwardProxy.Mappings, "forward-proxy-mapping", "Forward proxy of a broker dial address '<dial address>=<forward proxy url>' or '<dial address>=direct' e.g. 'kafka-0:9092=https://proxy:3129'. Overrides --forward-proxy")
 }
 
 func Run(_ *cobra.Command, _ []string) {
@@ -210,63 +420,17 @@ func Run(_ *cobra.Command, _ []string) {
 	var localPasswordAuthenticator apis.PasswordAuthenticator
 	var localTokenAuthenticator apis.TokenInfo
 	if c.Auth.Local.Enable {
-		switch c.Auth.Local.Mechanism {
-		case "PLAIN":
-			var err error
-			factory, ok := registry.GetComponent(new(apis.PasswordAuthenticatorFactory), c.Auth.Local.Command).(apis.PasswordAuthenticatorFactory)
-			if ok {
-				logrus.Infof("Using built-in '%s' PasswordAuthenticator for local PasswordAuthenticator", c.Auth.Local.Command)
-				localPasswordAuthenticator, err = factory.New(c.Auth.Local.Parameters)
-				if err != nil {
-					logrus.Fatal(err)
-				}
-			} else {
-				client := NewPluginClient(localauth.Handshake, localauth.PluginMap, c.Auth.Local.LogLevel, c.Auth.Local.Command, c.Auth.Local.Parameters)
-				defer client.Kill()
-
-				rpcClient, err := client.Client()
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				raw, err := rpcClient.Dispense("passwordAuthenticator")
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				localPasswordAuthenticator, ok = raw.(apis.PasswordAuthenticator)
-				if !ok {
-					logrus.Fatal(errors.New("unsupported PasswordAuthenticator plugin type"))
-				}
-			}
-		case "OAUTHBEARER":
-			var err error
-			factory, ok := registry.GetComponent(new(apis.TokenInfoFactory), c.Auth.Local.Command).(apis.TokenInfoFactory)
-			if ok {
-				logrus.Infof("Using built-in '%s' TokenInfo for local TokenAuthenticator", c.Auth.Local.Command)
-
-				localTokenAuthenticator, err = factory.New(c.Auth.Local.Parameters)
-				if err != nil {
-					logrus.Fatal(err)
-				}
-			} else {
-				client := NewPluginClient(tokeninfo.Handshake, tokeninfo.PluginMap, c.Auth.Local.LogLevel, c.Auth.Local.Command, c.Auth.Local.Parameters)
-				defer client.Kill()
-
-				rpcClient, err := client.Client()
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				raw, err := rpcClient.Dispense("tokenInfo")
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				localTokenAuthenticator, ok = raw.(apis.TokenInfo)
-				if !ok {
-					logrus.Fatal(errors.New("unsupported TokenInfo plugin type"))
-				}
-			}
-		default:
-			logrus.Fatal(errors.New("unsupported local auth mechanism"))
-		}
+		var closeLocalAuth func()
+		localPasswordAuthenticator, localTokenAuthenticator, closeLocalAuth = newLocalAuthenticator("local", c.Auth.Local.Mechanism, c.Auth.Local.Command, c.Auth.Local.Parameters)
+		defer closeLocalAuth()
+	}
+	authPlugins := make(map[string]proxy.LocalAuthenticator)
+	for name, plugin := range c.Auth.Plugins {
+		authenticator := proxy.LocalAuthenticator{}
+		var closeAuthPlugin func()
+		authenticator.PasswordAuthenticator, authenticator.TokenAuthenticator, closeAuthPlugin = newLocalAuthenticator("auth plugin "+name, plugin.Mechanism, plugin.Command, c.Auth.PluginParams[name])
+		defer closeAuthPlugin()
+		authPlugins[name] = authenticator
 	}
 
 	var saslTokenProvider apis.TokenProvider
@@ -274,112 +438,152 @@ func Run(_ *cobra.Command, _ []string) {
 		switch c.Kafka.SASL.Plugin.Mechanism {
 		case "OAUTHBEARER":
 			var err error
-			factory, ok := registry.GetComponent(new(apis.TokenProviderFactory), c.Kafka.SASL.Plugin.Command).(apis.TokenProviderFactory)
+			factory, ok := getBuiltinComponent(new(apis.TokenProviderFactory), c.Kafka.SASL.Plugin.Command).(apis.TokenProviderFactory)
 			if ok {
 				logrus.Infof("Using built-in '%s' TokenProvider for sasl authentication", c.Kafka.SASL.Plugin.Command)
 
 				saslTokenProvider, err = factory.New(c.Kafka.SASL.Plugin.Parameters)
 				if err != nil {
-					logrus.Fatal(err)
+					fatal(pluginError(err))
 				}
+			} else if wasm.IsModule(c.Kafka.SASL.Plugin.Command) {
+				logrus.Infof("Using WASM module '%s' TokenProvider for sasl authentication", c.Kafka.SASL.Plugin.Command)
+				module := loadWasmModule(c.Kafka.SASL.Plugin.Command, c.Kafka.SASL.Plugin.Parameters)
+				defer module.Close()
+				saslTokenProvider = module.TokenProvider()
 			} else {
-				client := NewPluginClient(tokenprovider.Handshake, tokenprovider.PluginMap, c.Kafka.SASL.Plugin.LogLevel, c.Kafka.SASL.Plugin.Command, c.Kafka.SASL.Plugin.Parameters)
-				defer client.Kill()
+				supervised := NewSupervisedPlugin("tokenProvider", tokenprovider.Handshake, tokenprovider.ApiVersions, tokenprovider.PluginMap, c.Kafka.SASL.Plugin.LogLevel, c.Kafka.SASL.Plugin.Command, c.Kafka.SASL.Plugin.Parameters)
+				defer supervised.Close()
 
-				rpcClient, err := client.Client()
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				raw, err := rpcClient.Dispense("tokenProvider")
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				saslTokenProvider, ok = raw.(apis.TokenProvider)
+				saslTokenProvider, ok = supervised.TokenProvider()
 				if !ok {
-					logrus.Fatal(errors.New("unsupported TokenProvider plugin type"))
+					fatal(pluginError(errors.New("unsupported TokenProvider plugin type")))
 				}
 			}
 		default:
-			logrus.Fatal(errors.New("unsupported sasl auth mechanism"))
+			fatal(configError(errors.New("unsupported sasl auth mechanism")))
 		}
 	}
 
 	var gatewayTokenProvider apis.TokenProvider
 	if c.Auth.Gateway.Client.Enable {
 		var err error
-		factory, ok := registry.GetComponent(new(apis.TokenProviderFactory), c.Auth.Gateway.Client.Command).(apis.TokenProviderFactory)
+		factory, ok := getBuiltinComponent(new(apis.TokenProviderFactory), c.Auth.Gateway.Client.Command).(apis.TokenProviderFactory)
 		if ok {
 			logrus.Infof("Using built-in '%s' TokenProvider for Gateway Client", c.Auth.Gateway.Client.Command)
 			gatewayTokenProvider, err = factory.New(c.Auth.Gateway.Client.Parameters)
 			if err != nil {
-				logrus.Fatal(err)
+				fatal(pluginError(err))
 			}
+		} else if wasm.IsModule(c.Auth.Gateway.Client.Command) {
+			logrus.Infof("Using WASM module '%s' TokenProvider for Gateway Client", c.Auth.Gateway.Client.Command)
+			module := loadWasmModule(c.Auth.Gateway.Client.Command, c.Auth.Gateway.Client.Parameters)
+			defer module.Close()
+			gatewayTokenProvider = module.TokenProvider()
 		} else {
-			client := NewPluginClient(tokenprovider.Handshake, tokenprovider.PluginMap, c.Auth.Gateway.Client.LogLevel, c.Auth.Gateway.Client.Command, c.Auth.Gateway.Client.Parameters)
-			defer client.Kill()
+			supervised := NewSupervisedPlugin("tokenProvider", tokenprovider.Handshake, tokenprovider.ApiVersions, tokenprovider.PluginMap, c.Auth.Gateway.Client.LogLevel, c.Auth.Gateway.Client.Command, c.Auth.Gateway.Client.Parameters)
+			defer supervised.Close()
 
-			rpcClient, err := client.Client()
-			if err != nil {
-				logrus.Fatal(err)
-			}
-			raw, err := rpcClient.Dispense("tokenProvider")
-			if err != nil {
-				logrus.Fatal(err)
-			}
-			gatewayTokenProvider, ok = raw.(apis.TokenProvider)
+			gatewayTokenProvider, ok = supervised.TokenProvider()
 			if !ok {
-				logrus.Fatal(errors.New("unsupported TokenProvider plugin type"))
+				fatal(pluginError(errors.New("unsupported TokenProvider plugin type")))
 			}
 		}
 	}
 
 	var gatewayTokenInfo apis.TokenInfo
-	if c.Auth.Gateway.Server.Enable {
+	if c.Auth.Gateway.Server.Enable || c.Auth.ListenerPolicies.GatewayAuthEnabled() {
 		var err error
-		factory, ok := registry.GetComponent(new(apis.TokenInfoFactory), c.Auth.Gateway.Server.Command).(apis.TokenInfoFactory)
+		factory, ok := getBuiltinComponent(new(apis.TokenInfoFactory), c.Auth.Gateway.Server.Command).(apis.TokenInfoFactory)
 		if ok {
 			logrus.Infof("Using built-in '%s' TokenInfo for Gateway Server", c.Auth.Gateway.Server.Command)
 
 			gatewayTokenInfo, err = factory.New(c.Auth.Gateway.Server.Parameters)
 			if err != nil {
-				logrus.Fatal(err)
+				fatal(pluginError(err))
 			}
+		} else if wasm.IsModule(c.Auth.Gateway.Server.Command) {
+			logrus.Infof("Using WASM module '%s' TokenInfo for Gateway Server", c.Auth.Gateway.Server.Command)
+			module := loadWasmModule(c.Auth.Gateway.Server.Command, c.Auth.Gateway.Server.Parameters)
+			defer module.Close()
+			gatewayTokenInfo = module.TokenInfo()
 		} else {
-			client := NewPluginClient(tokeninfo.Handshake, tokeninfo.PluginMap, c.Auth.Gateway.Server.LogLevel, c.Auth.Gateway.Server.Command, c.Auth.Gateway.Server.Parameters)
-			defer client.Kill()
+			supervised := NewSupervisedPlugin("tokenInfo", tokeninfo.Handshake, tokeninfo.ApiVersions, tokeninfo.PluginMap, c.Auth.Gateway.Server.LogLevel, c.Auth.Gateway.Server.Command, c.Auth.Gateway.Server.Parameters)
+			defer supervised.Close()
 
-			rpcClient, err := client.Client()
-			if err != nil {
-				logrus.Fatal(err)
+			gatewayTokenInfo, ok = supervised.TokenInfo()
+			if !ok {
+				fatal(pluginError(errors.New("unsupported TokenInfo plugin type")))
 			}
-			raw, err := rpcClient.Dispense("tokenInfo")
+		}
+	}
+
+	var interceptor apis.Interceptor
+	if c.Interceptor.Enable {
+		factory, ok := getBuiltinComponent(new(apis.InterceptorFactory), c.Interceptor.Command).(apis.InterceptorFactory)
+		if ok {
+			logrus.Infof("Using built-in '%s' Interceptor", c.Interceptor.Command)
+
+			var err error
+			interceptor, err = factory.New(c.Interceptor.Parameters)
 			if err != nil {
-				logrus.Fatal(err)
+				fatal(pluginError(err))
 			}
-			gatewayTokenInfo, ok = raw.(apis.TokenInfo)
+		} else {
+			supervised := NewSupervisedPlugin("interceptor", interceptorshared.Handshake, interceptorshared.ApiVersions, interceptorshared.PluginMap, c.Interceptor.LogLevel, c.Interceptor.Command, c.Interceptor.Parameters)
+			defer supervised.Close()
+
+			interceptor, ok = supervised.Interceptor()
 			if !ok {
-				logrus.Fatal(errors.New("unsupported TokenInfo plugin type"))
+				fatal(pluginError(errors.New("unsupported Interceptor plugin type")))
 			}
 		}
 	}
 
 	var g run.Group
+	var configReloader *reloader
+	var proxyClient *proxy.Client
+	var bootstrapListeners func() []config.ListenerConfig
+	var connections *proxy.Connections
+	var authCache *proxy.AuthCache
+	var state func() debugState
 	{
 		// All active connections are stored in this variable.
 		connset := proxy.NewConnSet()
 		prometheus.MustRegister(proxy.NewCollector(connset))
 		listeners, err := proxy.NewListeners(c)
 		if err != nil {
-			logrus.Fatal(err)
+			fatal(configError(err))
 		}
 		connSrc, err := listeners.ListenInstances(c.Proxy.BootstrapServers)
 		if err != nil {
-			logrus.Fatal(err)
+			fatal(bindError(err))
+		}
+		if c.Proxy.Transparent.ListenerAddress != "" {
+			if _, err = listeners.ListenTransparent(c.Proxy.Transparent.ListenerAddress, c.Proxy.Transparent.TProxy); err != nil {
+				fatal(bindError(err))
+			}
 		}
-		proxyClient, err := proxy.NewClient(connset, c, listeners.GetNetAddressMapping, localPasswordAuthenticator, localTokenAuthenticator, saslTokenProvider, gatewayTokenProvider, gatewayTokenInfo)
+		if c.Proxy.BootstrapFile != "" {
+			if err = writeBootstrapFile(c.Proxy.BootstrapFile, listeners.BootstrapListeners()); err != nil {
+				fatal(configError(err))
+			}
+		}
+		proxyClient, err = proxy.NewClient(connset, c, listeners.GetNetAddressMapping, localPasswordAuthenticator, localTokenAuthenticator, saslTokenProvider, gatewayTokenProvider, gatewayTokenInfo, interceptor, authPlugins)
 		if err != nil {
-			logrus.Fatal(err)
+			fatal(configError(err))
 		}
+		if c.Kafka.StartupCheck {
+			if err = proxyClient.CheckUpstream(brokerAddresses(c.Proxy.BootstrapServers)); err != nil {
+				fatal(upstreamError(err))
+			}
+		}
+		bootstrapListeners = listeners.BootstrapListeners
+		connections = proxyClient.Connections()
+		authCache = proxyClient.AuthCache()
+		state = newDebugState(listeners, proxyClient)
+		configReloader = &reloader{args: serverArgs(os.Args), listeners: listeners, client: proxyClient, bootstrapFile: c.Proxy.BootstrapFile,
+			configMap: configMapWatcher, configMapKey: c.Kubernetes.ConfigMap.Key, configMapOptions: configMapOptions}
 		g.Add(func() error {
 			logrus.Print("Ready for new connections")
 			return proxyClient.Run(connSrc)
@@ -402,36 +606,183 @@ func Run(_ *cobra.Command, _ []string) {
 			close(cancelInterrupt)
 		})
 	}
+	{
+		cancelReload := make(chan struct{})
+		g.Add(func() error {
+			c := make(chan os.Signal, 1)
+			signal.Notify(c, syscall.SIGHUP)
+			defer signal.Stop(c)
+			for {
+				select {
+				case <-c:
+					logrus.Info("Received SIGHUP, reloading configuration")
+					if err := configReloader.reload(); err != nil {
+						logrus.Error(err)
+					}
+				case <-cancelReload:
+					return nil
+				}
+			}
+		}, func(error) {
+			close(cancelReload)
+		})
+	}
+	if c.Proxy.AutoMapping.Enable {
+		mapper := newAutoMapper(c, proxyClient, configReloader)
+		g.Add(func() error {
+			return mapper.run()
+		}, func(error) {
+			mapper.close()
+		})
+	}
+	if c.Kafka.SASL.Enable && !c.Kafka.SASL.Plugin.Enable && (c.Kafka.SASL.JaasConfigFile != "" || c.Kafka.SASL.Vault.Path != "") {
+		rotator := newSASLCredentialsRotator(c, proxyClient)
+		g.Add(func() error {
+			return rotator.run()
+		}, func(error) {
+			rotator.close()
+		})
+	}
+	if configMapWatcher != nil {
+		logrus.Infof("Watching %s for changes every %v", configMapSource(configMapWatcher), c.Kubernetes.ConfigMap.PollInterval)
+		g.Add(func() error {
+			return configMapWatcher.Run(configReloader.reloadConfigMap)
+		}, func(error) {
+			configMapWatcher.Close()
+		})
+	}
 	if !c.Http.Disable {
-		httpListener, err := net.Listen("tcp", c.Http.ListenAddress)
+		httpListener, err := activation.Listen("tcp", c.Http.ListenAddress)
 		if err != nil {
-			logrus.Fatal(err)
+			fatal(bindError(err))
 		}
 		g.Add(func() error {
-			return http.Serve(httpListener, NewHTTPHandler())
+			return http.Serve(httpListener, NewHTTPHandler(configReloader.reload, bootstrapListeners, connections, authCache))
 		}, func(error) {
 			httpListener.Close()
 		})
 	}
+	if c.Statsd.Enable {
+		sink, err := metrics.NewStatsdSink(c.Statsd.Address, c.Statsd.Prefix, c.Statsd.Tags)
+		if err != nil {
+			fatal(configError(err))
+		}
+		logrus.Infof("Sending metrics to StatsD %s every %v", c.Statsd.Address, c.Statsd.FlushInterval)
+		exporter := metrics.NewExporter(prometheus.DefaultGatherer, sink, "proxy_", c.Statsd.FlushInterval)
+		g.Add(func() error {
+			return exporter.Run()
+		}, func(error) {
+			exporter.Close()
+		})
+	}
 	if c.Debug.Enabled {
 		// https://golang.org/pkg/net/http/pprof/
 		// https://jvns.ca/blog/2017/09/24/profiling-go-with-pprof/
-		debugListener, err := net.Listen("tcp", c.Debug.ListenAddress)
+		debugListener, err := activation.Listen("tcp", c.Debug.ListenAddress)
 		if err != nil {
-			logrus.Fatal(err)
+			fatal(bindError(err))
 		}
 		g.Add(func() error {
-			return http.Serve(debugListener, http.DefaultServeMux)
+			return http.Serve(debugListener, newDebugHandler(c.Debug.StatePath, c.Debug.TokenFile, state))
 		}, func(error) {
 			debugListener.Close()
 		})
 	}
 
+	if service, ok := newWindowsService(); ok {
+		logrus.Info("Running as Windows service")
+		g.Add(service.run, service.stop)
+	}
+	for _, address := range activation.Unused() {
+		logrus.Warnf("Socket %s passed by systemd is not used by a listener", address)
+	}
+
 	err := g.Run()
 	logrus.Info("Exit ", err)
 }
 
-func NewHTTPHandler() http.Handler {
+// builtinPrefix selects a built-in plugin which is instantiated in-process e.g. builtin:auth-user
+const builtinPrefix = "builtin:"
+
+// getBuiltinComponent returns the built-in plugin registered for the command. Commands with the builtin: prefix must refer to a built-in plugin
+func getBuiltinComponent(iface interface{}, command string) interface{} {
+	component, err := lookupBuiltinComponent(iface, command)
+	if err != nil {
+		fatal(configError(err))
+	}
+	return component
+}
+
+func lookupBuiltinComponent(iface interface{}, command string) (interface{}, error) {
+	name := strings.TrimPrefix(command, builtinPrefix)
+	component := registry.GetComponent(iface, name)
+	if component == nil && name != command {
+		return nil, fmt.Errorf("unknown built-in plugin %s", name)
+	}
+	return component, nil
+}
+
+func loadWasmModule(command string, params []string) *wasm.Module {
+	module, err := wasm.Load(command, params)
+	if err != nil {
+		fatal(pluginError(err))
+	}
+	return module
+}
+
+// newLocalAuthenticator loads the password authenticator of the PLAIN or the token authenticator of the OAUTHBEARER mechanism.
+// The returned function closes the plugin.
+func newLocalAuthenticator(name string, mechanism string, command string, params []string) (apis.PasswordAuthenticator, apis.TokenInfo, func()) {
+	switch mechanism {
+	case "PLAIN":
+		factory, ok := getBuiltinComponent(new(apis.PasswordAuthenticatorFactory), command).(apis.PasswordAuthenticatorFactory)
+		if ok {
+			logrus.Infof("Using built-in '%s' PasswordAuthenticator for %s PasswordAuthenticator", command, name)
+			passwordAuthenticator, err := factory.New(params)
+			if err != nil {
+				fatal(pluginError(err))
+			}
+			return passwordAuthenticator, nil, func() {}
+		} else if wasm.IsModule(command) {
+			logrus.Infof("Using WASM module '%s' PasswordAuthenticator for %s PasswordAuthenticator", command, name)
+			module := loadWasmModule(command, params)
+			return module.PasswordAuthenticator(), nil, func() { _ = module.Close() }
+		}
+		supervised := NewSupervisedPlugin("passwordAuthenticator", localauth.Handshake, localauth.ApiVersions, localauth.PluginMap, c.Auth.Local.LogLevel, command, params)
+		passwordAuthenticator, ok := supervised.PasswordAuthenticator()
+		if !ok {
+			supervised.Close()
+			fatal(pluginError(errors.New("unsupported PasswordAuthenticator plugin type")))
+		}
+		return passwordAuthenticator, nil, supervised.Close
+	case "OAUTHBEARER":
+		factory, ok := getBuiltinComponent(new(apis.TokenInfoFactory), command).(apis.TokenInfoFactory)
+		if ok {
+			logrus.Infof("Using built-in '%s' TokenInfo for %s TokenAuthenticator", command, name)
+			tokenAuthenticator, err := factory.New(params)
+			if err != nil {
+				fatal(pluginError(err))
+			}
+			return nil, tokenAuthenticator, func() {}
+		} else if wasm.IsModule(command) {
+			logrus.Infof("Using WASM module '%s' TokenInfo for %s TokenAuthenticator", command, name)
+			module := loadWasmModule(command, params)
+			return nil, module.TokenInfo(), func() { _ = module.Close() }
+		}
+		supervised := NewSupervisedPlugin("tokenInfo", tokeninfo.Handshake, tokeninfo.ApiVersions, tokeninfo.PluginMap, c.Auth.Local.LogLevel, command, params)
+		tokenAuthenticator, ok := supervised.TokenInfo()
+		if !ok {
+			supervised.Close()
+			fatal(pluginError(errors.New("unsupported TokenInfo plugin type")))
+		}
+		return nil, tokenAuthenticator, supervised.Close
+	default:
+		fatal(configError(errors.New("unsupported local auth mechanism")))
+		return nil, nil, nil
+	}
+}
+
+func NewHTTPHandler(reload func() error, bootstrapListeners func() []config.ListenerConfig, connections *proxy.Connections, authCache *proxy.AuthCache) http.Handler {
 	m := http.NewServeMux()
 	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(
@@ -449,6 +800,23 @@ func NewHTTPHandler() http.Handler {
 		w.Write([]byte(`OK`))
 	})
 	m.Handle(c.Http.MetricsPath, promhttp.Handler())
+	if c.Http.Validate.Enable {
+		m.Handle(c.Http.Validate.Path, validateHandler(c.Http.Validate.TokenFile, c))
+	}
+	if c.Http.Reload.Enable {
+		m.Handle(c.Http.Reload.Path, reloadHandler(c.Http.Reload.TokenFile, reload))
+	}
+	if c.Http.Bootstrap.Enable {
+		m.Handle(c.Http.Bootstrap.Path, bootstrapHandler(bootstrapListeners))
+	}
+	if c.Http.Connections.Enable {
+		handler := connectionsHandler(c.Http.Connections.Path, c.Http.Connections.TokenFile, connections)
+		m.Handle(c.Http.Connections.Path, handler)
+		m.Handle(strings.TrimSuffix(c.Http.Connections.Path, "/")+"/", handler)
+	}
+	if c.Http.AuthCache.Enable {
+		m.Handle(c.Http.AuthCache.Path, authCacheHandler(c.Http.AuthCache.TokenFile, authCache))
+	}
 
 	return m
 }
@@ -463,9 +831,9 @@ func SetLogger() {
 			},
 			TimestampFormat: time.RFC3339,
 		}
-		logrus.SetFormatter(formatter)
+		logrus.SetFormatter(withTimeZone(formatter, c.Log.TimeZone))
 	} else {
-		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+		logrus.SetFormatter(withTimeZone(&logrus.TextFormatter{FullTimestamp: true, TimestampFormat: time.RFC3339}, c.Log.TimeZone))
 	}
 	level, err := logrus.ParseLevel(c.Log.Level)
 	if err != nil {
@@ -473,9 +841,77 @@ func SetLogger() {
 		level = logrus.InfoLevel
 	}
 	logrus.SetLevel(level)
+	proxy.SetLogSubsystemLevels(c.Log.SubsystemLevels)
+}
+
+// timeZoneFormatter formats log entries with timestamps in the configured time zone
+type timeZoneFormatter struct {
+	logrus.Formatter
+	location *time.Location
 }
 
-func NewPluginClient(handshakeConfig plugin.HandshakeConfig, plugins map[string]plugin.Plugin, logLevel string, command string, params []string) *plugin.Client {
+func (f *timeZoneFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	entry.Time = entry.Time.In(f.location)
+	return f.Formatter.Format(entry)
+}
+
+func withTimeZone(formatter logrus.Formatter, timeZone string) logrus.Formatter {
+	if timeZone == "" {
+		return formatter
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		logrus.Errorf("Couldn't load log time zone: %s", timeZone)
+		return formatter
+	}
+	return &timeZoneFormatter{Formatter: formatter, location: location}
+}
+
+func NewSupervisedPlugin(name string, handshakeConfig plugin.HandshakeConfig, apiVersions handshake.ApiVersions, plugins map[string]plugin.Plugin, logLevel string, command string, params []string) *supervisor.Supervisor {
+	launch := func() (*plugin.Client, interface{}, error) {
+		version := apiVersions.Latest()
+		for {
+			versionedHandshake := handshakeConfig
+			versionedHandshake.ProtocolVersion = version
+
+			client := NewPluginClient(versionedHandshake, plugins, logLevel, command, params, apiVersions.Env()...)
+			rpcClient, err := client.Client()
+			if err != nil {
+				client.Kill()
+				downgraded, err := handshake.Downgrade(apiVersions, version, err)
+				if err != nil {
+					return nil, nil, err
+				}
+				logrus.Warnf("Plugin %s supports API version %d, downgrading from version %d", command, downgraded, version)
+				version = downgraded
+				continue
+			}
+			raw, err := rpcClient.Dispense(name)
+			if err != nil {
+				client.Kill()
+				return nil, nil, err
+			}
+			if versioned, ok := raw.(handshake.Versioned); ok {
+				versioned.SetApiVersion(version)
+			}
+			logrus.Infof("Plugin %s uses API version %d with capabilities %v", command, version, apiVersions.Capabilities(version))
+			return client, raw, nil
+		}
+	}
+	supervised, err := supervisor.New(command, launch, supervisor.Options{
+		HealthCheckInterval: c.Plugin.HealthCheckInterval,
+		MaxRestartBackoff:   c.Plugin.MaxRestartBackoff,
+		FailPolicy:          c.Plugin.FailPolicy,
+		MaxFailOpen:         c.Plugin.MaxFailOpen,
+	})
+	if err != nil {
+		fatal(pluginError(err))
+	}
+	addSupervisedPlugin(supervised)
+	return supervised
+}
+
+func NewPluginClient(handshakeConfig plugin.HandshakeConfig, plugins map[string]plugin.Plugin, logLevel string, command string, params []string, env ...string) *plugin.Client {
 	jsonFormat := false
 	if c.Log.Format == "json" {
 		jsonFormat = true
@@ -488,11 +924,14 @@ func NewPluginClient(handshakeConfig plugin.HandshakeConfig, plugins map[string]
 		TimeFormat: time.RFC3339,
 	})
 
+	cmd := exec.Command(command, params...)
+	cmd.Env = env
+
 	return plugin.NewClient(&plugin.ClientConfig{
 		HandshakeConfig: handshakeConfig,
 		Plugins:         plugins,
 		Logger:          logger,
-		Cmd:             exec.Command(command, params...),
+		Cmd:             cmd,
 		AllowedProtocols: []plugin.Protocol{
 			plugin.ProtocolNetRPC, plugin.ProtocolGRPC},
 	})
@@ -66,6 +66,26 @@ func TestDialMappingFromFlags(t *testing.T) {
 	a.Equal(c.Proxy.DialAddressMappings[1].SourceAddress, "192.168.99.100:32402")
 	a.Equal(c.Proxy.DialAddressMappings[1].DestinationAddress, "0.0.0.0:32402")
 }
+
+func TestDialMappingWithFailoverAddressesFromFlags(t *testing.T) {
+	setupBootstrapServersMappingTest()
+
+	args := []string{"cobra.test",
+		"--bootstrap-server-mapping", "192.168.99.100:32401,0.0.0.0:32401",
+		"--dial-address-mapping", "192.168.99.100:32401,10.0.0.1:9092,10.0.0.2:9092,10.0.0.3:9092",
+	}
+
+	_ = Server.ParseFlags(args)
+	err := Server.PreRunE(nil, args)
+	a := assert.New(t)
+	a.Nil(err)
+	a.Len(c.Proxy.DialAddressMappings, 1)
+
+	a.Equal("192.168.99.100:32401", c.Proxy.DialAddressMappings[0].SourceAddress)
+	a.Equal("10.0.0.1:9092", c.Proxy.DialAddressMappings[0].DestinationAddress)
+	a.Equal([]string{"10.0.0.2:9092", "10.0.0.3:9092"}, c.Proxy.DialAddressMappings[0].FailoverAddresses)
+	a.Equal([]string{"10.0.0.1:9092", "10.0.0.2:9092", "10.0.0.3:9092"}, c.Proxy.DialAddressMappings[0].DialAddresses())
+}
 func TestBootstrapServersMappingFromEnv(t *testing.T) {
 	setupBootstrapServersMappingTest()
 
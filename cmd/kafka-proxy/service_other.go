@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package server
+
+type windowsService struct{}
+
+func newWindowsService() (*windowsService, bool) {
+	return nil, false
+}
+
+func (s *windowsService) run() error {
+	return nil
+}
+
+func (s *windowsService) stop(error) {}
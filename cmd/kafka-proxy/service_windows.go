@@ -0,0 +1,67 @@
+package server
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+const windowsServiceName = "kafka-proxy"
+
+var errServiceStopped = errors.New("service stopped")
+
+// windowsService reports the proxy state to the service control manager and stops the proxy on stop and shutdown requests
+type windowsService struct {
+	stopOnce sync.Once
+	stopped  chan struct{}
+	// the proxy stopped with an error
+	failed bool
+}
+
+// newWindowsService returns the service if the process was started by the service control manager
+func newWindowsService() (*windowsService, bool) {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil || interactive {
+		return nil, false
+	}
+	return &windowsService{stopped: make(chan struct{})}, true
+}
+
+func (s *windowsService) run() error {
+	if err := svc.Run(windowsServiceName, s); err != nil {
+		return err
+	}
+	return errServiceStopped
+}
+
+func (s *windowsService) stop(err error) {
+	s.stopOnce.Do(func() {
+		s.failed = err != nil && err != errServiceStopped
+		close(s.stopped)
+	})
+}
+
+func (s *windowsService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				return false, 0
+			}
+		case <-s.stopped:
+			status <- svc.Status{State: svc.StopPending}
+			if s.failed {
+				// service specific exit code
+				return true, 1
+			}
+			return false, 0
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/wasm"
+	"github.com/grepplabs/kafka-proxy/proxy"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const maxValidateRequestSize = 1 << 20
+
+type validateRequest struct {
+	Args []string `json:"args"`
+}
+
+type validateResponse struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+func (r *validateResponse) addError(err error) {
+	r.Errors = append(r.Errors, err.Error())
+}
+
+func (r *validateResponse) addWarning(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// validateHandler checks a candidate configuration (server command line arguments) against the flags and environment of the running binary.
+// Requests must send the token of the token file as bearer token, as the files and addresses of the candidate are accessed.
+func validateHandler(tokenFile string, running *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizedBearer(r, tokenFile) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxValidateRequestSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var request validateRequest
+		if err = json.Unmarshal(body, &request); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		response := validateArgs(request.Args, running)
+
+		w.Header().Set("Content-Type", "application/json")
+		if !response.Valid {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+func validateArgs(args []string, running *config.Config) *validateResponse {
+	response := &validateResponse{Errors: []string{}, Warnings: []string{}}
+
+	candidate, err := parseCandidateConfig(args)
+	if err != nil {
+		response.addError(err)
+		return response
+	}
+	if err = proxy.ValidateTLSConfig(candidate); err != nil {
+		response.addError(err)
+	}
+	for _, err = range validatePluginCommands(candidate) {
+		response.addError(err)
+	}
+	for _, address := range candidateListenAddresses(candidate) {
+		if isRunningListenAddress(running, address) {
+			response.addWarning("address %s is used by the running proxy, availability not checked", address)
+			continue
+		}
+		if err = checkAddressAvailable(address); err != nil {
+			response.addError(err)
+		}
+	}
+	response.Valid = len(response.Errors) == 0
+	return response
+}
+
+func parseCandidateConfig(args []string) (*config.Config, error) {
+	var (
+		candidate        = new(config.Config)
+		bootstrapServers = make([]string, 0)
+		externalServers  = make([]string, 0)
+		dialAddresses    = make([]string, 0)
+	)
+	flags := pflag.NewFlagSet("validate", pflag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	addServerFlags(flags, candidate, &bootstrapServers, &externalServers, &dialAddresses)
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := initConfig(candidate, bootstrapServers, externalServers, dialAddresses); err != nil {
+		return nil, err
+	}
+	return candidate, nil
+}
+
+// initConfig initializes the parsed configuration with the server mappings and validates it
+func initConfig(cfg *config.Config, bootstrapServers, externalServers, dialAddresses []string) error {
+	if err := cfg.InitSASLCredentials(); err != nil {
+		return err
+	}
+	if err := cfg.InitBootstrapServers(bootstrapServers); err != nil {
+		return err
+	}
+	if err := cfg.InitExternalServers(externalServers); err != nil {
+		return err
+	}
+	if err := cfg.InitDialAddressMappings(dialAddresses); err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
+
+func validatePluginCommands(cfg *config.Config) []error {
+	var errs []error
+	check := func(enabled bool, name string, command string, iface interface{}) {
+		if !enabled {
+			return
+		}
+		component, err := lookupBuiltinComponent(iface, command)
+		if err == nil && component == nil {
+			err = checkPluginCommand(command)
+		}
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "%s plugin", name))
+		}
+	}
+	var localAuthFactory interface{} = new(apis.PasswordAuthenticatorFactory)
+	if cfg.Auth.Local.Mechanism == "OAUTHBEARER" {
+		localAuthFactory = new(apis.TokenInfoFactory)
+	}
+	check(cfg.Auth.Local.Enable, "local auth", cfg.Auth.Local.Command, localAuthFactory)
+	for name, plugin := range cfg.Auth.Plugins {
+		var factory interface{} = new(apis.PasswordAuthenticatorFactory)
+		if plugin.Mechanism == "OAUTHBEARER" {
+			factory = new(apis.TokenInfoFactory)
+		}
+		check(true, "auth plugin "+name, plugin.Command, factory)
+	}
+	check(cfg.Kafka.SASL.Plugin.Enable, "SASL", cfg.Kafka.SASL.Plugin.Command, new(apis.TokenProviderFactory))
+	check(cfg.Auth.Gateway.Client.Enable, "gateway client", cfg.Auth.Gateway.Client.Command, new(apis.TokenProviderFactory))
+	check(cfg.Auth.Gateway.Server.Enable || cfg.Auth.ListenerPolicies.GatewayAuthEnabled(), "gateway server", cfg.Auth.Gateway.Server.Command, new(apis.TokenInfoFactory))
+	check(cfg.Interceptor.Enable, "interceptor", cfg.Interceptor.Command, new(apis.InterceptorFactory))
+	return errs
+}
+
+func checkPluginCommand(command string) error {
+	info, err := os.Stat(command)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is not a file", command)
+	}
+	if !wasm.IsModule(command) && info.Mode()&0111 == 0 {
+		return fmt.Errorf("%s is not an executable file", command)
+	}
+	return nil
+}
+
+func candidateListenAddresses(cfg *config.Config) []string {
+	addresses := make([]string, 0, len(cfg.Proxy.BootstrapServers)+1)
+	for _, listener := range cfg.Proxy.BootstrapServers {
+		addresses = append(addresses, listener.ListenerAddress)
+	}
+	if !cfg.Http.Disable {
+		addresses = append(addresses, cfg.Http.ListenAddress)
+	}
+	if cfg.Debug.Enabled {
+		addresses = append(addresses, cfg.Debug.ListenAddress)
+	}
+	return addresses
+}
+
+func isRunningListenAddress(running *config.Config, address string) bool {
+	if running == nil {
+		return false
+	}
+	for _, runningAddress := range candidateListenAddresses(running) {
+		if runningAddress == address {
+			return true
+		}
+	}
+	return false
+}
+
+func checkAddressAvailable(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.Wrapf(err, "address %s is not available", address)
+	}
+	return l.Close()
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func validateTokenFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	tokenFile := filepath.Join(dir, "token")
+	if err = ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return tokenFile
+}
+
+func postValidate(t *testing.T, running *config.Config, args []string) (int, validateResponse) {
+	body, _ := json.Marshal(validateRequest{Args: args})
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+	request.Header.Set("Authorization", "Bearer secret")
+	validateHandler(validateTokenFile(t), running).ServeHTTP(recorder, request)
+
+	var response validateResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder.Code, response
+}
+
+func TestValidateHandlerValidConfig(t *testing.T) {
+	a := assert.New(t)
+
+	code, response := postValidate(t, nil, []string{
+		"--bootstrap-server-mapping", "192.168.99.100:32401,127.0.0.1:0",
+		"--http-disable",
+	})
+	a.Equal(http.StatusOK, code)
+	a.True(response.Valid)
+	a.Empty(response.Errors)
+}
+
+func TestValidateHandlerInvalidConfig(t *testing.T) {
+	a := assert.New(t)
+
+	code, response := postValidate(t, nil, []string{"--unknown-flag"})
+	a.Equal(http.StatusBadRequest, code)
+	a.False(response.Valid)
+	a.Len(response.Errors, 1)
+
+	code, response = postValidate(t, nil, []string{
+		"--bootstrap-server-mapping", "192.168.99.100:32401,127.0.0.1:0",
+		"--http-disable",
+		"--proxy-listener-tls-enable",
+		"--proxy-listener-cert-file", "/nonexistent/cert.pem",
+		"--proxy-listener-key-file", "/nonexistent/key.pem",
+		"--auth-local-enable",
+		"--auth-local-command", "/nonexistent/plugin",
+	})
+	a.Equal(http.StatusBadRequest, code)
+	a.False(response.Valid)
+	a.Len(response.Errors, 2)
+}
+
+func TestValidateHandlerAddressInUse(t *testing.T) {
+	a := assert.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	defer l.Close()
+	address := l.Addr().String()
+
+	args := []string{"--bootstrap-server-mapping", "192.168.99.100:32401," + address, "--http-disable"}
+	code, response := postValidate(t, nil, args)
+	a.Equal(http.StatusBadRequest, code)
+	a.False(response.Valid)
+	a.Len(response.Errors, 1)
+
+	running := new(config.Config)
+	running.Http.Disable = true
+	running.Proxy.BootstrapServers = []config.ListenerConfig{{BrokerAddress: "192.168.99.100:32401", ListenerAddress: address}}
+
+	code, response = postValidate(t, running, args)
+	a.Equal(http.StatusOK, code)
+	a.True(response.Valid)
+	a.Len(response.Warnings, 1)
+}
+
+func TestValidateHandlerMethod(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	validateHandler(validateTokenFile(t), nil).ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestValidateHandlerUnauthorized(t *testing.T) {
+	a := assert.New(t)
+
+	tokenFile := validateTokenFile(t)
+	for _, token := range []string{"", "wrong"} {
+		body, _ := json.Marshal(validateRequest{Args: []string{"--http-disable"}})
+		request := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		validateHandler(tokenFile, nil).ServeHTTP(recorder, request)
+		a.Equal(http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestValidateHandlerBuiltinPlugins(t *testing.T) {
+	a := assert.New(t)
+
+	args := []string{
+		"--bootstrap-server-mapping", "192.168.99.100:32401,127.0.0.1:0",
+		"--http-disable",
+		"--auth-local-enable",
+		"--auth-local-command", "builtin:auth-user",
+		"--sasl-enable",
+		"--sasl-plugin-enable",
+		"--sasl-plugin-command", "unsecured-jwt-provider",
+	}
+	code, response := postValidate(t, nil, args)
+	a.Equal(http.StatusOK, code)
+	a.Empty(response.Errors)
+
+	code, response = postValidate(t, nil, []string{
+		"--bootstrap-server-mapping", "192.168.99.100:32401,127.0.0.1:0",
+		"--http-disable",
+		"--auth-local-enable",
+		"--auth-local-command", "builtin:unknown",
+	})
+	a.Equal(http.StatusBadRequest, code)
+	a.Equal([]string{"local auth plugin: unknown built-in plugin unknown"}, response.Errors)
+}
@@ -1,41 +1,23 @@
 package main
 
 import (
-	"flag"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/auth-user"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
 	"github.com/grepplabs/kafka-proxy/plugin/local-auth/shared"
 	"github.com/hashicorp/go-plugin"
 	"github.com/sirupsen/logrus"
 	"os"
 )
 
-type PasswordAuthenticator struct {
-	Username string
-	Password string
-}
-
-func (pa PasswordAuthenticator) Authenticate(username, password string) (bool, int32, error) {
-	// logrus.Printf("Authenticate request for %s:%s,expected %s:%s ", username, password, pa.username, pa.password)
-	return username == pa.Username && password == pa.Password, 0, nil
-}
-
-func (f *PasswordAuthenticator) flagSet() *flag.FlagSet {
-	fs := flag.NewFlagSet("auth plugin settings", flag.ContinueOnError)
-	fs.StringVar(&f.Username, "username", "", "Expected SASL username")
-	fs.StringVar(&f.Password, "password", "", "Expected SASL password")
-	return fs
-}
-
 func main() {
-	passwordAuthenticator := &PasswordAuthenticator{}
-	flags := passwordAuthenticator.flagSet()
-	flags.Parse(os.Args[1:])
-	if passwordAuthenticator.Username == "" || passwordAuthenticator.Password == "" {
-		logrus.Errorf("parameters username and password are required")
+	passwordAuthenticator, err := new(authuser.Factory).New(os.Args[1:])
+	if err != nil {
+		logrus.Error(err)
 		os.Exit(1)
 	}
 
 	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: shared.Handshake,
+		HandshakeConfig: handshake.Serve(shared.Handshake, shared.ApiVersions),
 		Plugins: map[string]plugin.Plugin{
 			"passwordAuthenticator": &shared.PasswordAuthenticatorPlugin{Impl: passwordAuthenticator},
 		},
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/grepplabs/kafka-proxy/pkg/libs/googleid-info"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
 	"github.com/grepplabs/kafka-proxy/plugin/token-info/shared"
 	"github.com/hashicorp/go-plugin"
 	"github.com/sirupsen/logrus"
@@ -16,7 +17,7 @@ func main() {
 	}
 
 	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: shared.Handshake,
+		HandshakeConfig: handshake.Serve(shared.Handshake, shared.ApiVersions),
 		Plugins: map[string]plugin.Plugin{
 			"tokenProvider": &shared.TokenInfoPlugin{Impl: tokenInfo},
 		},
@@ -4,6 +4,7 @@ import (
 	"os"
 
 	oidcprovider "github.com/grepplabs/kafka-proxy/pkg/libs/oidc-provider"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
 	"github.com/grepplabs/kafka-proxy/plugin/token-provider/shared"
 	"github.com/hashicorp/go-plugin"
 	"github.com/sirupsen/logrus"
@@ -18,7 +19,7 @@ func main() {
 	}
 
 	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: shared.Handshake,
+		HandshakeConfig: handshake.Serve(shared.Handshake, shared.ApiVersions),
 		Plugins: map[string]plugin.Plugin{
 			"tokenProvider": &shared.TokenProviderPlugin{Impl: tokenProvider},
 		},
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/grepplabs/kafka-proxy/pkg/libs/tenant-isolation"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
+	"github.com/grepplabs/kafka-proxy/plugin/interceptor/shared"
+	"github.com/hashicorp/go-plugin"
+	"github.com/sirupsen/logrus"
+	"os"
+)
+
+func main() {
+	interceptor, err := new(tenantisolation.Factory).New(os.Args[1:])
+	if err != nil {
+		logrus.Error(err)
+		os.Exit(1)
+	}
+
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: handshake.Serve(shared.Handshake, shared.ApiVersions),
+		Plugins: map[string]plugin.Plugin{
+			"interceptor": &shared.InterceptorPlugin{Impl: interceptor},
+		},
+		// A non-nil value here enables gRPC serving for this plugin...
+		GRPCServer: plugin.DefaultGRPCServer,
+	})
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+
+	tokenexchangeprovider "github.com/grepplabs/kafka-proxy/pkg/libs/token-exchange-provider"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
+	"github.com/grepplabs/kafka-proxy/plugin/token-provider/shared"
+	"github.com/hashicorp/go-plugin"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	tokenProvider, err := new(tokenexchangeprovider.Factory).New(os.Args[1:])
+
+	if err != nil {
+		logrus.Errorf("cannot initialize token exchange provider: %v", err)
+		os.Exit(1)
+	}
+
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: handshake.Serve(shared.Handshake, shared.ApiVersions),
+		Plugins: map[string]plugin.Plugin{
+			"tokenProvider": &shared.TokenProviderPlugin{Impl: tokenProvider},
+		},
+		// A non-nil value here enables gRPC serving for this plugin...
+		GRPCServer: plugin.DefaultGRPCServer,
+	})
+}
@@ -8,16 +8,17 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	stdjwt "github.com/dgrijalva/jwt-go"
-	"golang.org/x/oauth2/jwt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/jwks"
 	"github.com/grepplabs/kafka-proxy/pkg/libs/util"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
 	"github.com/grepplabs/kafka-proxy/plugin/token-info/shared"
 	"github.com/hashicorp/go-plugin"
 	"github.com/sirupsen/logrus"
@@ -33,34 +34,68 @@ const (
 	StatusNoExpirationTimeInToken = 6
 	StatusTokenTooEarly           = 7
 	StatusTokenExpired            = 8
+	StatusGetKeysFailed           = 9
+	StatusInvalidSignature        = 10
+	StatusNoNotBeforeInToken      = 11
+	StatusTokenLifetimeTooLong    = 12
 
-	AlgorithmNone = "none"
-)
+	AlgorithmNone = jwks.AlgorithmNone
 
-var (
-	clockSkew = 1 * time.Minute
+	defaultClockSkew = 1 * time.Minute
 )
 
 type UnsecuredJWTVerifier struct {
-	claimSub  map[string]struct{}
+	claimSub map[string]struct{}
+	// allowed algorithms, all supported signature algorithms if empty. Unsigned tokens are only accepted when none is allowed explicitly
 	algorithm map[string]struct{}
+	// the validation keys are fetched from the rewritten issuer URL
+	issuerURLRewrites []issuerURLRewrite
+	jwksHostOverride  string
+	// tolerated difference between the clocks of the issuer and the proxy
+	clockSkew time.Duration
+	// maximal exp - iat window of the tokens, unlimited if 0
+	maxTokenLifetime time.Duration
+	requireNbf       bool
+	// claims returned to the proxy with the subject of the verified token
+	claims []string
+	// issuers whose key sets are trusted, the subject and the claims are returned for their signed tokens only
+	trustedIssuers map[string]struct{}
+}
+
+// issuerURLRewrite replaces the issuer URL prefix e.g. when the issuer is not reachable with the URL in the tokens
+type issuerURLRewrite struct {
+	from string
+	to   string
 }
 
 type pluginMeta struct {
-	claimSub  util.ArrayFlags
-	algorithm util.ArrayFlags
+	claimSub          util.ArrayFlags
+	algorithm         util.ArrayFlags
+	issuerURLRewrites util.ArrayFlags
+	jwksHostOverride  string
+	clockSkew         time.Duration
+	maxTokenLifetime  time.Duration
+	requireNbf        bool
+	claims            util.ArrayFlags
+	trustedIssuers    util.ArrayFlags
 }
 
 func (f *pluginMeta) flagSet() *flag.FlagSet {
 	fs := flag.NewFlagSet("unsecured-jwt-info info settings", flag.ContinueOnError)
 	fs.Var(&f.claimSub, "claim-sub", "Allowed subject claim (user name)")
-	fs.Var(&f.algorithm, "algorithm", "Allowed algorithm")
+	fs.Var(&f.algorithm, "algorithm", "Allowed algorithm (RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512, EdDSA or none). All signature algorithms are allowed by default, unsigned tokens only when none is allowed")
+	fs.Var(&f.issuerURLRewrites, "issuer-url-rewrite", "Rewrite of the issuer URL prefix '<issuer URL prefix>=<URL prefix>' used to fetch the validation keys e.g. 'http://localhost:8080=http://keycloak:8080'")
+	fs.StringVar(&f.jwksHostOverride, "jwks-host-override", "", "Host (host:port) used to fetch the validation keys instead of the issuer host")
+	fs.DurationVar(&f.clockSkew, "clock-skew", defaultClockSkew, "Tolerated clock skew applied to the iat, nbf and exp claims")
+	fs.DurationVar(&f.maxTokenLifetime, "max-token-lifetime", 0, "Maximal token lifetime (exp - iat). If 0, the lifetime is not limited")
+	fs.BoolVar(&f.requireNbf, "require-nbf", false, "Reject tokens without not before (nbf) claim")
+	fs.Var(&f.claims, "claim", "Claim of the verified token returned to the proxy e.g. to be used as principal. The subject is always returned")
+	fs.Var(&f.trustedIssuers, "trusted-issuer", "Trusted issuer (iss claim). The subject and the claims are returned to the proxy only for signed tokens of trusted issuers")
 	return fs
 }
 
 // Implements apis.TokenInfo
 func (v UnsecuredJWTVerifier) VerifyToken(ctx context.Context, request apis.VerifyRequest) (apis.VerifyResponse, error) {
-	logrus.Printf("Verifying Token %s", request.Token)
 	if request.Token == "" {
 		return getVerifyResponseResponse(StatusEmptyToken)
 	}
@@ -69,9 +104,22 @@ func (v UnsecuredJWTVerifier) VerifyToken(ctx context.Context, request apis.Veri
 	if err != nil {
 		return getVerifyResponseResponse(StatusParseJWTFailed)
 	}
-	if len(v.algorithm) != 0 {
-		if _, ok := v.algorithm[header.Algorithm]; !ok {
-			return getVerifyResponseResponse(StatusUnauthorized)
+	if !v.algorithmAllowed(header.Algorithm) {
+		return getVerifyResponseResponse(StatusWrongAlgorithm)
+	}
+	if header.Algorithm != AlgorithmNone {
+		if claimSet.Iss == "" {
+			logrus.Errorf("Issuer URL is empty")
+			return getVerifyResponseResponse(StatusGetKeysFailed)
+		}
+		keys, err := getValidationKeys(v.jwksURL(claimSet.Iss))
+		if err != nil {
+			logrus.Errorf("Error \"%v\" getting validation keys of issuer %s", err, claimSet.Iss)
+			return getVerifyResponseResponse(StatusGetKeysFailed)
+		}
+		if err = jwks.Verify(request.Token, keys); err != nil {
+			logrus.Errorf("Token of issuer %s not verified: %v", claimSet.Iss, err)
+			return getVerifyResponseResponse(StatusInvalidSignature)
 		}
 	}
 	if len(v.claimSub) != 0 {
@@ -86,21 +134,18 @@ func (v UnsecuredJWTVerifier) VerifyToken(ctx context.Context, request apis.Veri
 		return getVerifyResponseResponse(StatusNoExpirationTimeInToken)
 	}
 
-	if claimSet.Iss != "" {
-		logrus.Printf("Issuer URL is %s, trying to retrieve validation certificate", claimSet.Iss)
-		cert, _, err := getKeycloakValidationCertificate(claimSet.Iss)
-		if err != nil {
-			logrus.Errorf("Error \"%v\" getting validation certificate", err)
-		} else {
-			logrus.Printf("Certificate: %s", cert)
-			stdjwt.Parse()
-		}
-	} else {
-		logrus.Errorf("Issuer URL is empty")
+	if claimSet.Nbf < 1 && v.requireNbf {
+		return getVerifyResponseResponse(StatusNoNotBeforeInToken)
+	}
+	if v.maxTokenLifetime > 0 && claimSet.Exp-claimSet.Iat > v.maxTokenLifetime.Seconds() {
+		return getVerifyResponseResponse(StatusTokenLifetimeTooLong)
 	}
 
-	earliest := int64(claimSet.Iat) - int64(clockSkew.Seconds())
-	latest := int64(claimSet.Exp) + int64(clockSkew.Seconds())
+	earliest := int64(claimSet.Iat) - int64(v.clockSkew.Seconds())
+	if claimSet.Nbf > claimSet.Iat {
+		earliest = int64(claimSet.Nbf) - int64(v.clockSkew.Seconds())
+	}
+	latest := int64(claimSet.Exp) + int64(v.clockSkew.Seconds())
 	unix := time.Now().Unix()
 
 	if unix < earliest {
@@ -109,74 +154,94 @@ func (v UnsecuredJWTVerifier) VerifyToken(ctx context.Context, request apis.Veri
 	if unix > latest {
 		return getVerifyResponseResponse(StatusTokenExpired)
 	}
-	return getVerifyResponseResponse(StatusOK)
+	if !v.issuerTrusted(header.Algorithm, claimSet.Iss) {
+		// the token is accepted, but its subject could be chosen by the client
+		return apis.VerifyResponse{Success: true, Status: StatusOK}, nil
+	}
+	return apis.VerifyResponse{Success: true, Status: StatusOK, Subject: claimSet.Sub, Claims: v.selectedClaims(claimSet)}, nil
 }
 
-type ValidationKey struct {
-	KeyId     string   `json:"kid"`
-	KeyType   string   `json:"kty"`
-	Algorithm string   `json:"alg"`
-	Use       string   `json:"use"`
-	N         string   `json:"n"`
-	E         string   `json:"e"`
-	X509Cert  []string `json:"x5c"`
-	X5t       string   `json:"x5t"`
-	X5t_S256  string   `json:"x5t#S256"`
+// issuerTrusted returns true if the token was signed with a key of a trusted issuer. The key set of other issuers
+// is fetched from the unverified iss claim of the token, so it is under control of the token creator.
+func (v UnsecuredJWTVerifier) issuerTrusted(algorithm string, issuer string) bool {
+	if algorithm == AlgorithmNone {
+		return false
+	}
+	_, ok := v.trustedIssuers[issuer]
+	return ok
 }
 
-type ValidationKeys struct {
-	Keys []ValidationKey `json:"keys"`
+// selectedClaims returns the values of the configured claims present in the token
+func (v UnsecuredJWTVerifier) selectedClaims(claimSet *ClaimSet) map[string]string {
+	claims := make(map[string]string)
+	for _, name := range v.claims {
+		value, ok := claimSet.OtherClaims[name]
+		if !ok || value == nil {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			claims[name] = s
+		} else {
+			claims[name] = fmt.Sprint(value)
+		}
+	}
+	return claims
 }
 
-func getKeycloakValidationCertificate(url string) (string, string, error) {
-	const subpath = "protocol/openid-connect/certs"
-	url = strings.Replace(url, "localhost", "host.docker.internal", 1)
-	response, err := http.Get(url + "/" + subpath)
-	if err != nil {
-		return "", "", err
+// algorithmAllowed returns true for the allowed algorithms. The algorithm none must be allowed explicitly.
+func (v UnsecuredJWTVerifier) algorithmAllowed(algorithm string) bool {
+	if len(v.algorithm) != 0 {
+		_, ok := v.algorithm[algorithm]
+		return ok && (algorithm == AlgorithmNone || jwks.IsSupportedAlgorithm(algorithm))
 	}
+	return jwks.IsSupportedAlgorithm(algorithm)
+}
 
-	responseData, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return "", "", err
+// jwksURL returns the URL of the JSON Web Key Set of the Keycloak issuer with the issuer URL rewrites and the host override applied
+func (v UnsecuredJWTVerifier) jwksURL(issuer string) string {
+	const subpath = "protocol/openid-connect/certs"
+	for _, rewrite := range v.issuerURLRewrites {
+		if strings.HasPrefix(issuer, rewrite.from) {
+			issuer = rewrite.to + strings.TrimPrefix(issuer, rewrite.from)
+			break
+		}
+	}
+	jwksURL := strings.TrimSuffix(issuer, "/") + "/" + subpath
+	if v.jwksHostOverride != "" {
+		if u, err := url.Parse(jwksURL); err == nil {
+			u.Host = v.jwksHostOverride
+			jwksURL = u.String()
+		}
 	}
+	return jwksURL
+}
 
-	keys := ValidationKeys{}
-	err = json.NewDecoder(bytes.NewBuffer(responseData)).Decode(&keys)
+// getValidationKeys returns the JSON Web Key Set
+func getValidationKeys(jwksURL string) (*jwks.KeySet, error) {
+	response, err := http.Get(jwksURL)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
+	defer response.Body.Close()
 
-	if len(keys.Keys) == 0 {
-		return "", "", fmt.Errorf("Keycloak Response contains no keys")
+	responseData, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
 	}
-
-	if len(keys.Keys[0].X509Cert) == 0 {
-		return "", "", fmt.Errorf("Keycloak validation key contains nur X.509 Certificates")
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Keycloak Response status %s", response.Status)
 	}
-	return keys.Keys[0].X509Cert[0], "", nil
-}
 
-func keyFunc(keys ValidationKeys, token *stdjwt.Token) (interface{}, error) {
-	validationKey := keys.Keys[0]
-	if keyId, ok := token.Header["kid"]; ok {
-		if k, err := getKeyById(keys, keyId); err == nil {
-			validationKey = *k
-		} else {
-			return nil, err
-		}
+	keys := &jwks.KeySet{}
+	err = json.NewDecoder(bytes.NewBuffer(responseData)).Decode(keys)
+	if err != nil {
+		return nil, err
 	}
-}
 
-func getKeyById(keys ValidationKeys, keyId interface{}) (*ValidationKey, error) {
-	// If Key ID is given but key is not found, return invalid index
-	for _, key := range keys.Keys {
-		if key.KeyId == keyId {
-			return &key, nil
-		}
+	if len(keys.Keys) == 0 {
+		return nil, fmt.Errorf("Keycloak Response contains no keys")
 	}
-
-	return nil, fmt.Errorf("no key with ID %v found", keyId)
+	return keys, nil
 }
 
 type Header struct {
@@ -188,6 +253,7 @@ type ClaimSet struct {
 	Sub         string                 `json:"sub,omitempty"`
 	Exp         float64                `json:"exp"`
 	Iat         float64                `json:"iat"`
+	Nbf         float64                `json:"nbf,omitempty"`
 	Iss         string                 `json:"iss,omitempty"`
 	OtherClaims map[string]interface{} `json:"-"`
 }
@@ -216,6 +282,10 @@ func Decode(token string) (*Header, *ClaimSet, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	err = json.NewDecoder(bytes.NewBuffer(decodedPayload)).Decode(&claimSet.OtherClaims)
+	if err != nil {
+		return nil, nil, err
+	}
 	return header, claimSet, nil
 }
 
@@ -224,20 +294,60 @@ func getVerifyResponseResponse(status int) (apis.VerifyResponse, error) {
 	return apis.VerifyResponse{Success: success, Status: int32(status)}, nil
 }
 
+func parseIssuerURLRewrites(values []string) ([]issuerURLRewrite, error) {
+	rewrites := make([]issuerURLRewrite, 0, len(values))
+	for _, value := range values {
+		pair := strings.SplitN(value, "=", 2)
+		if len(pair) != 2 || pair[0] == "" || pair[1] == "" {
+			return nil, fmt.Errorf("invalid issuer URL rewrite '%s', expected <issuer URL prefix>=<URL prefix>", value)
+		}
+		logrus.Infof("Validation keys of issuers %s* are fetched from %s*", pair[0], pair[1])
+		rewrites = append(rewrites, issuerURLRewrite{from: pair[0], to: pair[1]})
+	}
+	return rewrites, nil
+}
+
 func main() {
 	pluginMeta := &pluginMeta{}
 	fs := pluginMeta.flagSet()
 	_ = fs.Parse(os.Args[1:])
 
 	logrus.Infof("Unsecured JWT sub claims: %v", pluginMeta.claimSub)
+	for _, algorithm := range pluginMeta.algorithm {
+		if algorithm != AlgorithmNone && !jwks.IsSupportedAlgorithm(algorithm) {
+			logrus.Errorf("unsupported algorithm %s", algorithm)
+			os.Exit(1)
+		}
+	}
+
+	if pluginMeta.clockSkew < 0 || pluginMeta.maxTokenLifetime < 0 {
+		logrus.Errorf("clock-skew and max-token-lifetime must not be negative")
+		os.Exit(1)
+	}
+	logrus.Infof("Unsecured JWT clock skew: %v, max token lifetime: %v, require nbf: %v", pluginMeta.clockSkew, pluginMeta.maxTokenLifetime, pluginMeta.requireNbf)
+
+	issuerURLRewrites, err := parseIssuerURLRewrites(pluginMeta.issuerURLRewrites)
+	if err != nil {
+		logrus.Error(err)
+		os.Exit(1)
+	}
+
+	logrus.Infof("Unsecured JWT trusted issuers: %v", pluginMeta.trustedIssuers)
 
 	unsecuredJWTVerifier := &UnsecuredJWTVerifier{
-		claimSub:  pluginMeta.claimSub.AsMap(),
-		algorithm: pluginMeta.algorithm.AsMap(),
+		claimSub:          pluginMeta.claimSub.AsMap(),
+		algorithm:         pluginMeta.algorithm.AsMap(),
+		issuerURLRewrites: issuerURLRewrites,
+		jwksHostOverride:  pluginMeta.jwksHostOverride,
+		clockSkew:         pluginMeta.clockSkew,
+		maxTokenLifetime:  pluginMeta.maxTokenLifetime,
+		requireNbf:        pluginMeta.requireNbf,
+		claims:            pluginMeta.claims,
+		trustedIssuers:    pluginMeta.trustedIssuers.AsMap(),
 	}
 
 	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: shared.Handshake,
+		HandshakeConfig: handshake.Serve(shared.Handshake, shared.ApiVersions),
 		Plugins: map[string]plugin.Plugin{
 			"unsecuredJWTInfo": &shared.TokenInfoPlugin{Impl: unsecuredJWTVerifier},
 		},
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/libs/sidecar-injector"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// Kubernetes mutating admission webhook injecting the kafka-proxy sidecar into annotated pods
+func main() {
+	var (
+		listenAddress string
+		certFile      string
+		keyFile       string
+		injectorCfg   sidecarinjector.Config
+	)
+	flags := pflag.NewFlagSet("sidecar-injector", pflag.ExitOnError)
+	flags.StringVar(&listenAddress, "listen-address", "0.0.0.0:8443", "Address that the webhook is listening on")
+	flags.StringVar(&certFile, "tls-cert-file", "", "PEM encoded file with the webhook server certificate")
+	flags.StringVar(&keyFile, "tls-key-file", "", "PEM encoded file with the webhook server private key")
+	flags.StringVar(&injectorCfg.Image, "image", "grepplabs/kafka-proxy:latest", "Sidecar image. Can be overridden by the "+sidecarinjector.AnnotationImage+" annotation")
+	flags.StringVar(&injectorCfg.ImagePullPolicy, "image-pull-policy", "IfNotPresent", "Sidecar image pull policy")
+	flags.StringArrayVar(&injectorCfg.Args, "arg", []string{}, "Argument added to all kafka-proxy sidecars e.g. --log-format=json")
+	flags.IntVar(&injectorCfg.ListenerPort, "listener-port", 32400, "First local listener port. Can be overridden by the "+sidecarinjector.AnnotationListenerPort+" annotation")
+	flags.IntVar(&injectorCfg.HttpPort, "http-port", 9080, "Port of the kafka-proxy health and metrics endpoint")
+	flags.BoolVar(&injectorCfg.StartBeforeApp, "start-before-app", true, "Start the app containers after the sidecar is ready. Can be overridden by the "+sidecarinjector.AnnotationStartBeforeApp+" annotation")
+	flags.DurationVar(&injectorCfg.StopDelay, "stop-delay", 5*time.Second, "Keep the sidecar running after the pod termination started. Can be overridden by the "+sidecarinjector.AnnotationStopDelay+" annotation")
+	_ = flags.Parse(os.Args[1:])
+
+	if certFile == "" || keyFile == "" {
+		logrus.Error("parameters tls-cert-file and tls-key-file are required")
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/mutate", sidecarinjector.New(injectorCfg))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("OK"))
+	})
+
+	logrus.Infof("Sidecar injector listening on %s", listenAddress)
+	if err := http.ListenAndServeTLS(listenAddress, certFile, keyFile, mux); err != nil {
+		logrus.Error(err)
+		os.Exit(1)
+	}
+}
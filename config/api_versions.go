@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MinApiVersions is a flag value accepting repeated "<api key>=<version>" entries. Requests of the api key
+// with a lower version are matched.
+type MinApiVersions map[int16]int16
+
+func (m *MinApiVersions) String() string {
+	return apiVersionsString(*m)
+}
+
+func (m *MinApiVersions) Set(value string) error {
+	if *m == nil {
+		*m = make(MinApiVersions)
+	}
+	return setApiVersion(*m, value, "min")
+}
+
+func (m *MinApiVersions) Type() string {
+	return "stringArray"
+}
+
+// Deprecated reports whether the request version is lower than the minimal version of the api key
+func (m MinApiVersions) Deprecated(apiKey int16, apiVersion int16) bool {
+	minVersion, ok := m[apiKey]
+	return ok && apiVersion < minVersion
+}
+
+// MaxApiVersions is a flag value accepting repeated "<api key>=<version>" entries. The max version of the api key
+// advertised to the clients is capped to the version.
+type MaxApiVersions map[int16]int16
+
+func (m *MaxApiVersions) String() string {
+	return apiVersionsString(*m)
+}
+
+func (m *MaxApiVersions) Set(value string) error {
+	if *m == nil {
+		*m = make(MaxApiVersions)
+	}
+	return setApiVersion(*m, value, "max")
+}
+
+func (m *MaxApiVersions) Type() string {
+	return "stringArray"
+}
+
+// Capped returns the max versions with the api keys of caps capped to the lower of both versions
+func (m MaxApiVersions) Capped(caps map[int16]int16) MaxApiVersions {
+	capped := make(MaxApiVersions, len(m)+len(caps))
+	for apiKey, maxVersion := range m {
+		capped[apiKey] = maxVersion
+	}
+	for apiKey, capVersion := range caps {
+		if maxVersion, ok := capped[apiKey]; !ok || capVersion < maxVersion {
+			capped[apiKey] = capVersion
+		}
+	}
+	return capped
+}
+
+func apiVersionsString(m map[int16]int16) string {
+	apiKeys := make([]int, 0, len(m))
+	for apiKey := range m {
+		apiKeys = append(apiKeys, int(apiKey))
+	}
+	sort.Ints(apiKeys)
+	entries := make([]string, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		entries = append(entries, fmt.Sprintf("%d=%d", apiKey, m[int16(apiKey)]))
+	}
+	return "[" + strings.Join(entries, ",") + "]"
+}
+
+func setApiVersion(m map[int16]int16, value string, kind string) error {
+	pos := strings.Index(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid %s api version '%s', expected <api key>=<version>", kind, value)
+	}
+	apiKey, err := strconv.ParseInt(strings.TrimSpace(value[:pos]), 10, 16)
+	if err != nil || apiKey < 0 {
+		return errors.Errorf("invalid api key in '%s'", value)
+	}
+	version, err := strconv.ParseInt(strings.TrimSpace(value[pos+1:]), 10, 16)
+	if err != nil || version < 0 {
+		return errors.Errorf("invalid api version in '%s'", value)
+	}
+	m[int16(apiKey)] = int16(version)
+	return nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinApiVersionsSet(t *testing.T) {
+	a := assert.New(t)
+
+	var versions MinApiVersions
+	a.Nil(versions.Set("0=3"))
+	a.Nil(versions.Set(" 1 = 4 "))
+	a.Equal(MinApiVersions{0: 3, 1: 4}, versions)
+	a.Equal("[0=3,1=4]", versions.String())
+
+	a.True(versions.Deprecated(0, 2))
+	a.False(versions.Deprecated(0, 3))
+	a.False(versions.Deprecated(3, 0))
+
+	a.NotNil(versions.Set("0"))
+	a.NotNil(versions.Set("a=1"))
+	a.NotNil(versions.Set("0=-1"))
+}
+
+func TestMaxApiVersionsSet(t *testing.T) {
+	a := assert.New(t)
+
+	var versions MaxApiVersions
+	a.Nil(versions.Set("1=11"))
+	a.Nil(versions.Set("3=8"))
+	a.Nil(versions.Set("3=9"))
+	a.Equal(MaxApiVersions{1: 11, 3: 9}, versions)
+	a.Equal("[1=11,3=9]", versions.String())
+
+	a.NotNil(versions.Set("1=a"))
+	a.NotNil(versions.Set("-1=1"))
+}
+
+func TestMaxApiVersionsCapped(t *testing.T) {
+	a := assert.New(t)
+
+	versions := MaxApiVersions{0: 9, 3: 9}
+	a.Equal(MaxApiVersions{0: 8, 1: 11, 3: 9}, versions.Capped(map[int16]int16{0: 8, 1: 11}))
+	a.Equal(MaxApiVersions{0: 9, 3: 9}, versions)
+	a.Equal(MaxApiVersions{1: 11}, MaxApiVersions(nil).Capped(map[int16]int16{1: 11}))
+}
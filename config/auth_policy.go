@@ -0,0 +1,207 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// AuthPolicyDefault applies the global setting
+	AuthPolicyDefault = "default"
+	// AuthPolicyDisabled disables the local or the gateway authentication on the listener
+	AuthPolicyDisabled = "disabled"
+	// AuthPolicyEnabled enables the gateway authentication on the listener
+	AuthPolicyEnabled = "enabled"
+
+	ClientCertRequired = "required"
+	ClientCertOptional = "optional"
+	ClientCertNone     = "none"
+)
+
+// AuthPolicy overrides the authentication of the proxy clients connecting to a listener
+type AuthPolicy struct {
+	// LocalAuth is default (Auth.Local), disabled or the name of an auth plugin
+	LocalAuth string
+	// GatewayAuth is default (Auth.Gateway.Server), enabled or disabled
+	GatewayAuth string
+	// ClientCert is default (required when Proxy.TLS.CAChainCertFile is set), required, optional or none
+	ClientCert string
+}
+
+func (p AuthPolicy) String() string {
+	return fmt.Sprintf("local-auth=%s,gateway-auth=%s,client-cert=%s", p.LocalAuth, p.GatewayAuth, p.ClientCert)
+}
+
+// ListenerAuthPolicies is a flag value accepting repeated "<address>=<option>=<value>,..." entries with the options
+// local-auth, gateway-auth and client-cert. The address is either the listener address or the broker address of the listener.
+type ListenerAuthPolicies map[string]AuthPolicy
+
+func (m *ListenerAuthPolicies) String() string {
+	addresses := make([]string, 0, len(*m))
+	for address := range *m {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	entries := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		entries = append(entries, fmt.Sprintf("%s=%s", address, (*m)[address]))
+	}
+	return "[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *ListenerAuthPolicies) Set(value string) error {
+	pos := strings.Index(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid listener auth policy '%s', expected <address>=<option>=<value>,...", value)
+	}
+	address := strings.TrimSpace(value[:pos])
+	if address == "" {
+		return errors.Errorf("invalid listener auth policy '%s', expected <address>=<option>=<value>,...", value)
+	}
+	policy := AuthPolicy{LocalAuth: AuthPolicyDefault, GatewayAuth: AuthPolicyDefault, ClientCert: AuthPolicyDefault}
+	for _, option := range strings.Split(value[pos+1:], ",") {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[1]) == "" {
+			return errors.Errorf("invalid option '%s' in listener auth policy '%s'", option, value)
+		}
+		name, optionValue := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch name {
+		case "local-auth":
+			policy.LocalAuth = optionValue
+		case "gateway-auth":
+			if optionValue != AuthPolicyDefault && optionValue != AuthPolicyEnabled && optionValue != AuthPolicyDisabled {
+				return errors.Errorf("invalid gateway-auth '%s' in listener auth policy '%s', expected default, enabled or disabled", optionValue, value)
+			}
+			policy.GatewayAuth = optionValue
+		case "client-cert":
+			if optionValue != AuthPolicyDefault && optionValue != ClientCertRequired && optionValue != ClientCertOptional && optionValue != ClientCertNone {
+				return errors.Errorf("invalid client-cert '%s' in listener auth policy '%s', expected default, required, optional or none", optionValue, value)
+			}
+			policy.ClientCert = optionValue
+		default:
+			return errors.Errorf("unknown option '%s' in listener auth policy '%s'", name, value)
+		}
+	}
+	if *m == nil {
+		*m = make(ListenerAuthPolicies)
+	}
+	if _, ok := (*m)[address]; ok {
+		return errors.Errorf("duplicate auth policy for listener %s", address)
+	}
+	(*m)[address] = policy
+	return nil
+}
+
+func (m *ListenerAuthPolicies) Type() string {
+	return "stringArray"
+}
+
+// Policy returns the policy of the listener address or the broker address
+func (m ListenerAuthPolicies) Policy(listenerAddress string, brokerAddress string) (AuthPolicy, bool) {
+	if policy, ok := m[listenerAddress]; ok {
+		return policy, true
+	}
+	policy, ok := m[brokerAddress]
+	return policy, ok
+}
+
+// GatewayAuthEnabled returns true when a policy enables the gateway authentication
+func (m ListenerAuthPolicies) GatewayAuthEnabled() bool {
+	for _, policy := range m {
+		if policy.GatewayAuth == AuthPolicyEnabled {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthPlugin is a local authentication plugin referenced by name in the listener auth policies
+type AuthPlugin struct {
+	Mechanism string
+	Command   string
+}
+
+// AuthPlugins is a flag value accepting repeated "<name>=<mechanism>,<command>" entries
+type AuthPlugins map[string]AuthPlugin
+
+func (m *AuthPlugins) String() string {
+	names := make([]string, 0, len(*m))
+	for name := range *m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]string, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, fmt.Sprintf("%s=%s,%s", name, (*m)[name].Mechanism, (*m)[name].Command))
+	}
+	return "[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *AuthPlugins) Set(value string) error {
+	pos := strings.Index(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid auth plugin '%s', expected <name>=<mechanism>,<command>", value)
+	}
+	name := strings.TrimSpace(value[:pos])
+	parts := strings.SplitN(value[pos+1:], ",", 2)
+	if name == "" || len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return errors.Errorf("invalid auth plugin '%s', expected <name>=<mechanism>,<command>", value)
+	}
+	if name == AuthPolicyDefault || name == AuthPolicyDisabled {
+		return errors.Errorf("auth plugin name '%s' is reserved", name)
+	}
+	mechanism := strings.TrimSpace(parts[0])
+	if mechanism != "PLAIN" && mechanism != "OAUTHBEARER" {
+		return errors.Errorf("invalid mechanism '%s' of auth plugin %s, expected PLAIN or OAUTHBEARER", mechanism, name)
+	}
+	if *m == nil {
+		*m = make(AuthPlugins)
+	}
+	if _, ok := (*m)[name]; ok {
+		return errors.Errorf("duplicate auth plugin %s", name)
+	}
+	(*m)[name] = AuthPlugin{Mechanism: mechanism, Command: strings.TrimSpace(parts[1])}
+	return nil
+}
+
+func (m *AuthPlugins) Type() string {
+	return "stringArray"
+}
+
+// AuthPluginParams is a flag value accepting repeated "<name>=<parameter>" entries
+type AuthPluginParams map[string][]string
+
+func (m *AuthPluginParams) String() string {
+	names := make([]string, 0, len(*m))
+	for name := range *m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]string, 0)
+	for _, name := range names {
+		for _, param := range (*m)[name] {
+			entries = append(entries, name+"="+param)
+		}
+	}
+	return "[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *AuthPluginParams) Set(value string) error {
+	pos := strings.Index(value, "=")
+	if pos == -1 || strings.TrimSpace(value[:pos]) == "" {
+		return errors.Errorf("invalid auth plugin parameter '%s', expected <name>=<parameter>", value)
+	}
+	if *m == nil {
+		*m = make(AuthPluginParams)
+	}
+	name := strings.TrimSpace(value[:pos])
+	(*m)[name] = append((*m)[name], value[pos+1:])
+	return nil
+}
+
+func (m *AuthPluginParams) Type() string {
+	return "stringArray"
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerAuthPoliciesSet(t *testing.T) {
+	a := assert.New(t)
+
+	var policies ListenerAuthPolicies
+	a.Nil(policies.Set("0.0.0.0:32400=local-auth=disabled,client-cert=required"))
+	a.Nil(policies.Set(" kafka-0:9092 = local-auth=partners , gateway-auth=disabled, client-cert=none"))
+	a.Equal(ListenerAuthPolicies{
+		"0.0.0.0:32400": {LocalAuth: "disabled", GatewayAuth: "default", ClientCert: "required"},
+		"kafka-0:9092":  {LocalAuth: "partners", GatewayAuth: "disabled", ClientCert: "none"},
+	}, policies)
+	a.Equal("[0.0.0.0:32400=local-auth=disabled,gateway-auth=default,client-cert=required kafka-0:9092=local-auth=partners,gateway-auth=disabled,client-cert=none]", policies.String())
+
+	policy, ok := policies.Policy("0.0.0.0:32400", "kafka-0:9092")
+	a.True(ok)
+	a.Equal("disabled", policy.LocalAuth)
+	policy, ok = policies.Policy("0.0.0.0:32401", "kafka-0:9092")
+	a.True(ok)
+	a.Equal("partners", policy.LocalAuth)
+	_, ok = policies.Policy("0.0.0.0:32402", "kafka-2:9092")
+	a.False(ok)
+	a.False(policies.GatewayAuthEnabled())
+
+	a.NotNil(policies.Set("kafka-1:9092"))
+	a.NotNil(policies.Set("kafka-1:9092=local-auth"))
+	a.NotNil(policies.Set("kafka-1:9092=tls=required"))
+	a.NotNil(policies.Set("kafka-1:9092=gateway-auth=on"))
+	a.NotNil(policies.Set("kafka-1:9092=client-cert=any"))
+	a.NotNil(policies.Set("kafka-0:9092=local-auth=disabled"))
+}
+
+func TestAuthPluginsSet(t *testing.T) {
+	a := assert.New(t)
+
+	var plugins AuthPlugins
+	a.Nil(plugins.Set("partners=OAUTHBEARER,builtin:google-id-info"))
+	a.Nil(plugins.Set("internal=PLAIN,/opt/kafka-proxy/auth-ldap"))
+	a.Equal(AuthPlugins{
+		"partners": {Mechanism: "OAUTHBEARER", Command: "builtin:google-id-info"},
+		"internal": {Mechanism: "PLAIN", Command: "/opt/kafka-proxy/auth-ldap"},
+	}, plugins)
+	a.Equal("[internal=PLAIN,/opt/kafka-proxy/auth-ldap partners=OAUTHBEARER,builtin:google-id-info]", plugins.String())
+
+	a.NotNil(plugins.Set("other=PLAIN"))
+	a.NotNil(plugins.Set("other=GSSAPI,/opt/kafka-proxy/auth"))
+	a.NotNil(plugins.Set("disabled=PLAIN,/opt/kafka-proxy/auth"))
+	a.NotNil(plugins.Set("partners=PLAIN,/opt/kafka-proxy/auth"))
+
+	var params AuthPluginParams
+	a.Nil(params.Set("partners=--audience=kafka,proxy"))
+	a.Nil(params.Set("partners=--timeout=10"))
+	a.Equal(AuthPluginParams{"partners": {"--audience=kafka,proxy", "--timeout=10"}}, params)
+	a.NotNil(params.Set("=--timeout=10"))
+	a.NotNil(params.Set("partners"))
+}
+
+func TestValidateAuthPolicies(t *testing.T) {
+	newConfig := func() *Config {
+		c := NewConfig()
+		c.Auth.Local.Timeout = 10
+		c.Proxy.TLS.Enable = true
+		c.Proxy.TLS.CAChainCertFile = "ca.pem"
+		c.Auth.Plugins = AuthPlugins{"partners": {Mechanism: "OAUTHBEARER", Command: "builtin:google-id-info"}}
+		return c
+	}
+	tests := []struct {
+		name   string
+		policy string
+		modify func(c *Config)
+		err    string
+	}{
+		{name: "valid", policy: "0.0.0.0:32400=local-auth=partners,client-cert=optional"},
+		{name: "unknown plugin", policy: "0.0.0.0:32400=local-auth=internal",
+			err: "auth policy of listener 0.0.0.0:32400 references unknown auth plugin internal"},
+		{name: "unknown plugin params", policy: "0.0.0.0:32400=local-auth=disabled",
+			modify: func(c *Config) { c.Auth.PluginParams = AuthPluginParams{"internal": {"--timeout=10"}} },
+			err:    "parameters of unknown auth plugin internal"},
+		{name: "gateway", policy: "0.0.0.0:32400=gateway-auth=enabled",
+			err: "auth policy of listener 0.0.0.0:32400 enables gateway auth, Auth.Gateway.Server Command, Method, Magic and Timeout are required"},
+		{name: "client cert CA", policy: "0.0.0.0:32400=client-cert=required",
+			modify: func(c *Config) { c.Proxy.TLS.CAChainCertFile = "" },
+			err:    "auth policy of listener 0.0.0.0:32400 requires Proxy TLS with CAChainCertFile for client-cert required"},
+		{name: "client cert TLS", policy: "0.0.0.0:32400=client-cert=none",
+			modify: func(c *Config) { c.Proxy.TLS.Enable = false },
+			err:    "auth policy of listener 0.0.0.0:32400 requires Proxy TLS for client-cert none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig()
+			assert.Nil(t, c.Auth.ListenerPolicies.Set(tt.policy))
+			if tt.modify != nil {
+				tt.modify(c)
+			}
+			err := c.validateAuthPolicies()
+			if tt.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
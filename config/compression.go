@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CompressionCodecNames are the compression.type names of the record batch codecs
+var CompressionCodecNames = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+
+// CompressionTranscoding recompresses the fetched record batches compressed with the broker codec with the client codec
+// and the produced record batches compressed with the client codec with the broker codec
+type CompressionTranscoding struct {
+	BrokerCodec string
+	ClientCodec string
+}
+
+func (t CompressionTranscoding) String() string {
+	return t.BrokerCodec + ":" + t.ClientCodec
+}
+
+// ListenerCompressionTranscodings is a flag value accepting repeated "<address>=<broker codec>:<client codec>" entries.
+// The address is either the listener address or the broker address of the listener.
+type ListenerCompressionTranscodings map[string]CompressionTranscoding
+
+func (m *ListenerCompressionTranscodings) String() string {
+	addresses := make([]string, 0, len(*m))
+	for address := range *m {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	entries := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		entries = append(entries, fmt.Sprintf("%s=%s", address, (*m)[address]))
+	}
+	return "[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *ListenerCompressionTranscodings) Set(value string) error {
+	pos := strings.Index(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid compression transcoding '%s', expected <address>=<broker codec>:<client codec>", value)
+	}
+	address := strings.TrimSpace(value[:pos])
+	codecs := strings.Split(value[pos+1:], ":")
+	if address == "" || len(codecs) != 2 {
+		return errors.Errorf("invalid compression transcoding '%s', expected <address>=<broker codec>:<client codec>", value)
+	}
+	transcoding := CompressionTranscoding{BrokerCodec: strings.TrimSpace(codecs[0]), ClientCodec: strings.TrimSpace(codecs[1])}
+	for _, codec := range []string{transcoding.BrokerCodec, transcoding.ClientCodec} {
+		if !isCompressionCodec(codec) {
+			return errors.Errorf("invalid codec '%s' in compression transcoding '%s', expected one of %s", codec, value, strings.Join(CompressionCodecNames, ", "))
+		}
+	}
+	if transcoding.BrokerCodec == transcoding.ClientCodec {
+		return errors.Errorf("broker and client codec of compression transcoding '%s' must differ", value)
+	}
+	if *m == nil {
+		*m = make(ListenerCompressionTranscodings)
+	}
+	if _, ok := (*m)[address]; ok {
+		return errors.Errorf("duplicate compression transcoding for listener %s", address)
+	}
+	(*m)[address] = transcoding
+	return nil
+}
+
+func (m *ListenerCompressionTranscodings) Type() string {
+	return "stringArray"
+}
+
+// Transcoding returns the transcoding of the listener address or the broker address
+func (m ListenerCompressionTranscodings) Transcoding(listenerAddress string, brokerAddress string) (CompressionTranscoding, bool) {
+	if transcoding, ok := m[listenerAddress]; ok {
+		return transcoding, true
+	}
+	transcoding, ok := m[brokerAddress]
+	return transcoding, ok
+}
+
+func isCompressionCodec(name string) bool {
+	for _, codec := range CompressionCodecNames {
+		if codec == name {
+			return true
+		}
+	}
+	return false
+}
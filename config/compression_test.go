@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerCompressionTranscodingsSet(t *testing.T) {
+	a := assert.New(t)
+
+	var transcodings ListenerCompressionTranscodings
+	a.Nil(transcodings.Set("0.0.0.0:32400=zstd:gzip"))
+	a.Nil(transcodings.Set(" kafka-1:9092 = lz4 : none"))
+	a.Equal(ListenerCompressionTranscodings{
+		"0.0.0.0:32400": {BrokerCodec: "zstd", ClientCodec: "gzip"},
+		"kafka-1:9092":  {BrokerCodec: "lz4", ClientCodec: "none"},
+	}, transcodings)
+	a.Equal("[0.0.0.0:32400=zstd:gzip kafka-1:9092=lz4:none]", transcodings.String())
+
+	transcoding, ok := transcodings.Transcoding("0.0.0.0:32401", "kafka-1:9092")
+	a.True(ok)
+	a.Equal("lz4", transcoding.BrokerCodec)
+	_, ok = transcodings.Transcoding("0.0.0.0:32402", "kafka-2:9092")
+	a.False(ok)
+
+	a.NotNil(transcodings.Set("kafka-2:9092"))
+	a.NotNil(transcodings.Set("kafka-2:9092=zstd"))
+	a.NotNil(transcodings.Set("kafka-2:9092=zstd:brotli"))
+	a.NotNil(transcodings.Set("kafka-2:9092=gzip:gzip"))
+	a.NotNil(transcodings.Set("0.0.0.0:32400=snappy:gzip"))
+}
@@ -1,11 +1,14 @@
 package config
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"regexp"
 	"strings"
+
+	"github.com/grepplabs/kafka-proxy/pkg/libs/vault"
 )
 
 var (
@@ -26,6 +29,22 @@ func NewJaasCredentialFromFile(filename string) (*JaasCredentials, error) {
 	return NewJaasCredentials(string(bytes))
 }
 
+// NewVaultCredentials reads the username and password keys of the Vault KV secret
+func NewVaultCredentials(address, tokenFile, path string) (*JaasCredentials, error) {
+	kv, err := vault.NewKV(address, tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	values, err := kv.Read(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	if values["username"] == "" || values["password"] == "" {
+		return nil, fmt.Errorf("vault secret %s must contain username and password", path)
+	}
+	return &JaasCredentials{Username: values["username"], Password: values["password"]}, nil
+}
+
 func NewJaasCredentials(s string) (*JaasCredentials, error) {
 	username, err := getJaasAttr(regexUsername.FindAllStringSubmatch(s, -1))
 	if err != nil {
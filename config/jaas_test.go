@@ -110,3 +110,20 @@ func TestJaas2UsersOneLineError(t *testing.T) {
 	a := assert.New(t)
 	a.EqualError(err, "cannot retrieve jaas username: multiple entries were found")
 }
+
+func TestLoadSASLCredentialsSources(t *testing.T) {
+	a := assert.New(t)
+
+	c := NewConfig()
+	credentials, err := c.LoadSASLCredentials()
+	a.Nil(err)
+	a.Nil(credentials)
+
+	c.Kafka.SASL.Vault.Path = "secret/data/kafka-proxy"
+	_, err = c.LoadSASLCredentials()
+	a.EqualError(err, "SASL Vault.Address and Vault.TokenFile are required when Vault.Path is set")
+
+	c.Kafka.SASL.JaasConfigFile = "kafka-proxy.jaas"
+	_, err = c.LoadSASLCredentials()
+	a.EqualError(err, "SASL JaasConfigFile and Vault.Path must not be used together")
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// LogSubsystems are the parts of the proxy with their own log level
+var LogSubsystems = []string{
+	"connection", // accept, handshakes and close of client connections
+	"auth",       // authentication of clients and bans
+	"requests",   // processing of requests and responses
+	"broker",     // broker connections, re-authentication and mirroring
+}
+
+// LogSubsystemLevels is a flag value accepting repeated "<subsystem>=<level>" entries overriding the log level of a subsystem
+type LogSubsystemLevels map[string]logrus.Level
+
+func (m *LogSubsystemLevels) String() string {
+	subsystems := make([]string, 0, len(*m))
+	for subsystem := range *m {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+	entries := make([]string, 0, len(subsystems))
+	for _, subsystem := range subsystems {
+		entries = append(entries, subsystem+"="+(*m)[subsystem].String())
+	}
+	return "[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *LogSubsystemLevels) Set(value string) error {
+	pos := strings.Index(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid subsystem log level '%s', expected <subsystem>=<level>", value)
+	}
+	subsystem := strings.TrimSpace(value[:pos])
+	if !isLogSubsystem(subsystem) {
+		return errors.Errorf("unknown log subsystem '%s', expected one of %v", subsystem, LogSubsystems)
+	}
+	level, err := logrus.ParseLevel(strings.TrimSpace(value[pos+1:]))
+	if err != nil {
+		return errors.Wrapf(err, "invalid subsystem log level '%s'", value)
+	}
+	if *m == nil {
+		*m = make(LogSubsystemLevels)
+	}
+	(*m)[subsystem] = level
+	return nil
+}
+
+func (m *LogSubsystemLevels) Type() string {
+	return "stringArray"
+}
+
+func isLogSubsystem(subsystem string) bool {
+	for _, v := range LogSubsystems {
+		if v == subsystem {
+			return true
+		}
+	}
+	return false
+}
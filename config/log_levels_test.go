@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogSubsystemLevelsSet(t *testing.T) {
+	a := assert.New(t)
+
+	var levels LogSubsystemLevels
+	a.Nil(levels.Set("connection=debug"))
+	a.Nil(levels.Set(" auth = warn "))
+	a.Nil(levels.Set("connection=trace"))
+	a.Equal(LogSubsystemLevels{"connection": logrus.TraceLevel, "auth": logrus.WarnLevel}, levels)
+	a.Equal("[auth=warning connection=trace]", levels.String())
+
+	a.NotNil(levels.Set("requests"))
+	a.NotNil(levels.Set("requests=verbose"))
+	a.NotNil(levels.Set("http=debug"))
+}
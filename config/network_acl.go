@@ -0,0 +1,66 @@
+package config
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ListenerCIDRs is a flag value accepting repeated "<address>=<cidr>[,<cidr>...]" entries. The address is either the listener
+// address or the broker address of the listener. Repeated entries of an address add their networks.
+type ListenerCIDRs map[string][]*net.IPNet
+
+func (m *ListenerCIDRs) String() string {
+	addresses := make([]string, 0, len(*m))
+	for address := range *m {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	entries := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		networks := make([]string, 0, len((*m)[address]))
+		for _, network := range (*m)[address] {
+			networks = append(networks, network.String())
+		}
+		entries = append(entries, address+"="+strings.Join(networks, ","))
+	}
+	return "[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *ListenerCIDRs) Set(value string) error {
+	pos := strings.Index(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid listener networks '%s', expected <address>=<cidr>[,<cidr>...]", value)
+	}
+	address := strings.TrimSpace(value[:pos])
+	if address == "" {
+		return errors.Errorf("invalid listener networks '%s', expected <address>=<cidr>[,<cidr>...]", value)
+	}
+	var networks []*net.IPNet
+	for _, cidr := range strings.Split(value[pos+1:], ",") {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return errors.Errorf("invalid CIDR '%s' in listener networks '%s'", strings.TrimSpace(cidr), value)
+		}
+		networks = append(networks, network)
+	}
+	if *m == nil {
+		*m = make(ListenerCIDRs)
+	}
+	(*m)[address] = append((*m)[address], networks...)
+	return nil
+}
+
+func (m *ListenerCIDRs) Type() string {
+	return "stringArray"
+}
+
+// Networks returns the networks of the listener address or the broker address, nil for other listeners
+func (m ListenerCIDRs) Networks(listenerAddress string, brokerAddress string) []*net.IPNet {
+	if networks, ok := m[listenerAddress]; ok {
+		return networks
+	}
+	return m[brokerAddress]
+}
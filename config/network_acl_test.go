@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerCIDRsSet(t *testing.T) {
+	a := assert.New(t)
+
+	var cidrs ListenerCIDRs
+	a.Nil(cidrs.Set("0.0.0.0:32400=10.0.0.0/8, 192.168.1.17/32"))
+	a.Nil(cidrs.Set(" kafka-0:9092 = 172.16.0.0/12"))
+	a.Nil(cidrs.Set("kafka-0:9092=fd00::/8"))
+	a.Equal("[0.0.0.0:32400=10.0.0.0/8,192.168.1.17/32 kafka-0:9092=172.16.0.0/12,fd00::/8]", cidrs.String())
+
+	a.Len(cidrs.Networks("0.0.0.0:32400", "kafka-0:9092"), 2)
+	a.Equal("172.16.0.0/12", cidrs.Networks("0.0.0.0:32401", "kafka-0:9092")[0].String())
+	a.Nil(cidrs.Networks("0.0.0.0:32402", "kafka-2:9092"))
+
+	a.NotNil(cidrs.Set("kafka-1:9092"))
+	a.NotNil(cidrs.Set("=10.0.0.0/8"))
+	a.NotNil(cidrs.Set("kafka-1:9092=10.0.0.1"))
+	a.NotNil(cidrs.Set("kafka-1:9092=10.0.0.0/8,"))
+}
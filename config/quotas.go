@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultQuotaPrincipal selects the quota of principals without an own quota
+const DefaultQuotaPrincipal = "*"
+
+// Quota limits the produced bytes, the fetched bytes and the requests per second of a principal. Zero disables the limit.
+type Quota struct {
+	ProduceBytesPerSec int
+	FetchBytesPerSec   int
+	RequestsPerSec     int
+}
+
+// PrincipalQuotas is a flag value accepting repeated "<principal>=<produce bytes/s>,<fetch bytes/s>,<requests/s>" entries.
+// The principal is the SASL user or the client certificate common name, * is the default for other principals.
+type PrincipalQuotas map[string]Quota
+
+func (m *PrincipalQuotas) String() string {
+	principals := make([]string, 0, len(*m))
+	for principal := range *m {
+		principals = append(principals, principal)
+	}
+	sort.Strings(principals)
+	entries := make([]string, 0, len(principals))
+	for _, principal := range principals {
+		quota := (*m)[principal]
+		entries = append(entries, fmt.Sprintf("%s=%d,%d,%d", principal, quota.ProduceBytesPerSec, quota.FetchBytesPerSec, quota.RequestsPerSec))
+	}
+	return "[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *PrincipalQuotas) Set(value string) error {
+	pos := strings.LastIndex(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid quota '%s', expected <principal>=<produce bytes/s>,<fetch bytes/s>,<requests/s>", value)
+	}
+	principal := strings.TrimSpace(value[:pos])
+	rates := strings.Split(value[pos+1:], ",")
+	if principal == "" || len(rates) != 3 {
+		return errors.Errorf("invalid quota '%s', expected <principal>=<produce bytes/s>,<fetch bytes/s>,<requests/s>", value)
+	}
+	values := make([]int, len(rates))
+	for i, rate := range rates {
+		v, err := strconv.Atoi(strings.TrimSpace(rate))
+		if err != nil || v < 0 {
+			return errors.Errorf("invalid rate '%s' in quota '%s'", strings.TrimSpace(rate), value)
+		}
+		values[i] = v
+	}
+	if *m == nil {
+		*m = make(PrincipalQuotas)
+	}
+	if _, ok := (*m)[principal]; ok {
+		return errors.Errorf("duplicate quota for principal %s", principal)
+	}
+	(*m)[principal] = Quota{ProduceBytesPerSec: values[0], FetchBytesPerSec: values[1], RequestsPerSec: values[2]}
+	return nil
+}
+
+func (m *PrincipalQuotas) Type() string {
+	return "stringArray"
+}
+
+// Quota returns the quota of the principal or the default quota
+func (m PrincipalQuotas) Quota(principal string) (Quota, bool) {
+	if quota, ok := m[principal]; ok {
+		return quota, true
+	}
+	quota, ok := m[DefaultQuotaPrincipal]
+	return quota, ok
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalQuotasSet(t *testing.T) {
+	a := assert.New(t)
+
+	var quotas PrincipalQuotas
+	a.Nil(quotas.Set("alice=1048576,2097152,100"))
+	a.Nil(quotas.Set(" * = 524288 , 0 , 0 "))
+	a.Nil(quotas.Set("CN=bob,O=example=0,0,10"))
+	a.Equal(PrincipalQuotas{
+		"alice":            {ProduceBytesPerSec: 1048576, FetchBytesPerSec: 2097152, RequestsPerSec: 100},
+		"*":                {ProduceBytesPerSec: 524288},
+		"CN=bob,O=example": {RequestsPerSec: 10},
+	}, quotas)
+	a.Equal("[*=524288,0,0 CN=bob,O=example=0,0,10 alice=1048576,2097152,100]", quotas.String())
+
+	quota, ok := quotas.Quota("alice")
+	a.True(ok)
+	a.Equal(100, quota.RequestsPerSec)
+	quota, ok = quotas.Quota("carol")
+	a.True(ok)
+	a.Equal(524288, quota.ProduceBytesPerSec)
+
+	a.NotNil(quotas.Set("dave"))
+	a.NotNil(quotas.Set("dave=1,2"))
+	a.NotNil(quotas.Set("dave=1,-2,3"))
+	a.NotNil(quotas.Set("dave=1,2k,3"))
+	a.NotNil(quotas.Set("=1,2,3"))
+	a.NotNil(quotas.Set("alice=1,2,3"))
+
+	var empty PrincipalQuotas
+	_, ok = empty.Quota("alice")
+	a.False(ok)
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RequestLimits are the maximum request size and the maximum produce record batch size in bytes. Zero disables the limit.
+type RequestLimits struct {
+	MaxRequestSize int
+	MaxBatchSize   int
+}
+
+// ListenerRequestLimits is a flag value accepting repeated "<address>=<max request size>,<max batch size>" entries.
+// The address is either the listener address or the broker address of the listener.
+type ListenerRequestLimits map[string]RequestLimits
+
+func (m *ListenerRequestLimits) String() string {
+	addresses := make([]string, 0, len(*m))
+	for address := range *m {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	entries := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		limits := (*m)[address]
+		entries = append(entries, fmt.Sprintf("%s=%d,%d", address, limits.MaxRequestSize, limits.MaxBatchSize))
+	}
+	return "[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *ListenerRequestLimits) Set(value string) error {
+	pos := strings.Index(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid listener limits '%s', expected <address>=<max request size>,<max batch size>", value)
+	}
+	address := strings.TrimSpace(value[:pos])
+	sizes := strings.Split(value[pos+1:], ",")
+	if address == "" || len(sizes) != 2 {
+		return errors.Errorf("invalid listener limits '%s', expected <address>=<max request size>,<max batch size>", value)
+	}
+	var limits RequestLimits
+	var err error
+	if limits.MaxRequestSize, err = strconv.Atoi(strings.TrimSpace(sizes[0])); err != nil || limits.MaxRequestSize < 0 {
+		return errors.Errorf("invalid max request size in listener limits '%s'", value)
+	}
+	if limits.MaxBatchSize, err = strconv.Atoi(strings.TrimSpace(sizes[1])); err != nil || limits.MaxBatchSize < 0 {
+		return errors.Errorf("invalid max batch size in listener limits '%s'", value)
+	}
+	if *m == nil {
+		*m = make(ListenerRequestLimits)
+	}
+	if _, ok := (*m)[address]; ok {
+		return errors.Errorf("duplicate limits for listener %s", address)
+	}
+	(*m)[address] = limits
+	return nil
+}
+
+func (m *ListenerRequestLimits) Type() string {
+	return "stringArray"
+}
+
+// Limits returns the limits of the listener address or the broker address. The defaults are returned for other listeners.
+func (m ListenerRequestLimits) Limits(listenerAddress string, brokerAddress string, defaults RequestLimits) RequestLimits {
+	if limits, ok := m[listenerAddress]; ok {
+		return limits
+	}
+	if limits, ok := m[brokerAddress]; ok {
+		return limits
+	}
+	return defaults
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerRequestLimitsSet(t *testing.T) {
+	a := assert.New(t)
+
+	var limits ListenerRequestLimits
+	a.Nil(limits.Set("0.0.0.0:32400=1048576,0"))
+	a.Nil(limits.Set(" kafka-0:9092 = 2097152 , 1048576 "))
+	a.Equal(ListenerRequestLimits{"0.0.0.0:32400": {MaxRequestSize: 1048576}, "kafka-0:9092": {MaxRequestSize: 2097152, MaxBatchSize: 1048576}}, limits)
+	a.Equal("[0.0.0.0:32400=1048576,0 kafka-0:9092=2097152,1048576]", limits.String())
+
+	defaults := RequestLimits{MaxRequestSize: 100}
+	a.Equal(RequestLimits{MaxRequestSize: 1048576}, limits.Limits("0.0.0.0:32400", "kafka-0:9092", defaults))
+	a.Equal(RequestLimits{MaxRequestSize: 2097152, MaxBatchSize: 1048576}, limits.Limits("0.0.0.0:32401", "kafka-0:9092", defaults))
+	a.Equal(defaults, limits.Limits("0.0.0.0:32402", "kafka-2:9092", defaults))
+
+	a.NotNil(limits.Set("kafka-1:9092"))
+	a.NotNil(limits.Set("kafka-1:9092=1024"))
+	a.NotNil(limits.Set("kafka-1:9092=-1,0"))
+	a.NotNil(limits.Set("kafka-1:9092=1k,0"))
+	a.NotNil(limits.Set("kafka-0:9092=1024,0"))
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const minutesPerDay = 24 * 60
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// TimeWindow is a recurring time of day window in a time zone e.g. "Mon-Fri 22:00-06:00 Europe/Berlin".
+// The days are optional (every day) and the time zone defaults to UTC. A window ending before it starts spans midnight,
+// the day refers to the start of the window.
+type TimeWindow struct {
+	spec     string
+	days     [7]bool
+	start    int // minutes after midnight
+	end      int // minutes after midnight, exclusive
+	location *time.Location
+}
+
+func ParseTimeWindow(spec string) (TimeWindow, error) {
+	w := TimeWindow{spec: spec, location: time.UTC}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 3 {
+		return w, errors.Errorf("invalid time window '%s', expected [days] HH:MM-HH:MM [zone]", spec)
+	}
+	if !strings.Contains(fields[0], ":") {
+		if err := w.parseDays(fields[0]); err != nil {
+			return w, errors.Wrapf(err, "invalid time window '%s'", spec)
+		}
+		fields = fields[1:]
+	} else {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	}
+	if len(fields) == 0 {
+		return w, errors.Errorf("invalid time window '%s', missing time range", spec)
+	}
+	if len(fields) > 2 {
+		return w, errors.Errorf("invalid time window '%s', unexpected '%s' after the time zone", spec, strings.Join(fields[2:], " "))
+	}
+	if err := w.parseTimeRange(fields[0]); err != nil {
+		return w, errors.Wrapf(err, "invalid time window '%s'", spec)
+	}
+	if len(fields) == 2 {
+		location, err := time.LoadLocation(fields[1])
+		if err != nil {
+			return w, errors.Wrapf(err, "invalid time window '%s'", spec)
+		}
+		w.location = location
+	}
+	return w, nil
+}
+
+func (w *TimeWindow) parseDays(value string) error {
+	for _, part := range strings.Split(strings.ToLower(value), ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return errors.Errorf("unknown day '%s'", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[bounds[1]]; !ok {
+				return errors.Errorf("unknown day '%s'", bounds[1])
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			w.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (w *TimeWindow) parseTimeRange(value string) (err error) {
+	bounds := strings.SplitN(value, "-", 2)
+	if len(bounds) != 2 {
+		return errors.Errorf("invalid time range '%s'", value)
+	}
+	if w.start, err = parseTimeOfDay(bounds[0]); err != nil {
+		return err
+	}
+	if w.end, err = parseTimeOfDay(bounds[1]); err != nil {
+		return err
+	}
+	if w.start == w.end || w.start == minutesPerDay {
+		return errors.Errorf("invalid time range '%s'", value)
+	}
+	return nil
+}
+
+func parseTimeOfDay(value string) (int, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return 0, errors.Errorf("invalid time of day '%s', expected HH:MM", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, errors.Errorf("invalid time of day '%s', expected HH:MM", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, errors.Errorf("invalid time of day '%s', expected HH:MM", value)
+	}
+	result := hours*60 + minutes
+	if hours < 0 || minutes < 0 || minutes > 59 || result > minutesPerDay {
+		return 0, errors.Errorf("invalid time of day '%s'", value)
+	}
+	return result, nil
+}
+
+// Contains reports whether the time is inside the window, evaluated in the window time zone
+func (w TimeWindow) Contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && minute >= w.start && minute < w.end
+	}
+	// window spans midnight
+	if minute >= w.start {
+		return w.days[t.Weekday()]
+	}
+	return minute < w.end && w.days[(t.Weekday()+6)%7]
+}
+
+func (w TimeWindow) String() string {
+	return w.spec
+}
+
+// TimeWindows is a flag value accepting repeated time windows
+type TimeWindows []TimeWindow
+
+func (ws *TimeWindows) String() string {
+	specs := make([]string, 0, len(*ws))
+	for _, w := range *ws {
+		specs = append(specs, w.spec)
+	}
+	return "[" + strings.Join(specs, ",") + "]"
+}
+
+func (ws *TimeWindows) Set(value string) error {
+	w, err := ParseTimeWindow(value)
+	if err != nil {
+		return err
+	}
+	*ws = append(*ws, w)
+	return nil
+}
+
+func (ws *TimeWindows) Type() string {
+	return "stringArray"
+}
+
+func (ws TimeWindows) Contains(t time.Time) bool {
+	for _, w := range ws {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduledApiKeys are Kafka api keys applying only during the time window
+type ScheduledApiKeys struct {
+	Window  TimeWindow
+	ApiKeys []int
+}
+
+// ScheduledApiKeysList is a flag value accepting repeated "<time window>=<api key>,<api key>" entries
+type ScheduledApiKeysList []ScheduledApiKeys
+
+func (l *ScheduledApiKeysList) String() string {
+	entries := make([]string, 0, len(*l))
+	for _, s := range *l {
+		entries = append(entries, fmt.Sprintf("%s=%v", s.Window.spec, s.ApiKeys))
+	}
+	return "[" + strings.Join(entries, ",") + "]"
+}
+
+func (l *ScheduledApiKeysList) Set(value string) error {
+	pos := strings.LastIndex(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid scheduled api keys '%s', expected <time window>=<api keys>", value)
+	}
+	w, err := ParseTimeWindow(value[:pos])
+	if err != nil {
+		return err
+	}
+	apiKeys := make([]int, 0)
+	for _, field := range strings.Split(value[pos+1:], ",") {
+		apiKey, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || apiKey < 0 {
+			return errors.Errorf("invalid api key '%s' in '%s'", field, value)
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	*l = append(*l, ScheduledApiKeys{Window: w, ApiKeys: apiKeys})
+	return nil
+}
+
+func (l *ScheduledApiKeysList) Type() string {
+	return "stringArray"
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustTime(t *testing.T, value string) time.Time {
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	tests := []struct {
+		window   string
+		time     string
+		expected bool
+	}{
+		{"09:00-17:00", "2020-06-01T09:00:00Z", true},
+		{"09:00-17:00", "2020-06-01T16:59:59Z", true},
+		{"09:00-17:00", "2020-06-01T17:00:00Z", false},
+		{"09:00-17:00", "2020-06-01T08:59:00Z", false},
+		{"00:00-24:00", "2020-06-01T23:59:00Z", true},
+		// 2020-06-01 is Monday
+		{"Mon-Fri 09:00-17:00", "2020-06-01T10:00:00Z", true},
+		{"Mon-Fri 09:00-17:00", "2020-06-06T10:00:00Z", false},
+		{"Sat,Sun 09:00-17:00", "2020-06-07T10:00:00Z", true},
+		{"Fri-Mon 09:00-17:00", "2020-06-07T10:00:00Z", true},
+		{"Fri-Mon 09:00-17:00", "2020-06-03T10:00:00Z", false},
+		// spans midnight, day refers to the window start
+		{"Fri 22:00-06:00", "2020-06-05T23:00:00Z", true},
+		{"Fri 22:00-06:00", "2020-06-06T05:00:00Z", true},
+		{"Fri 22:00-06:00", "2020-06-05T05:00:00Z", false},
+		{"Fri 22:00-06:00", "2020-06-06T23:00:00Z", false},
+		// time zones
+		{"09:00-17:00 Europe/Berlin", "2020-06-01T07:30:00Z", true},
+		{"09:00-17:00 Europe/Berlin", "2020-06-01T15:30:00Z", false},
+		{"09:00-17:00 Europe/Berlin", "2020-01-01T15:30:00Z", true},
+		{"Mon 09:00-17:00 America/New_York", "2020-06-02T02:00:00+09:00", true},
+		{"Mon 09:00-17:00 America/New_York", "2020-06-01T21:00:00+09:00", false},
+		{"Mon 09:00-17:00 America/New_York", "2020-06-02T07:00:00+09:00", false},
+	}
+	for _, tt := range tests {
+		w, err := ParseTimeWindow(tt.window)
+		assert.Nil(t, err, tt.window)
+		assert.Equal(t, tt.expected, w.Contains(mustTime(t, tt.time)), "%s at %s", tt.window, tt.time)
+	}
+}
+
+func TestParseTimeWindowErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"Mon-Fri",
+		"09:00",
+		"09:00-09:00",
+		"25:00-26:00",
+		"09:60-10:00",
+		"Foo 09:00-17:00",
+		"09:00-17:00 Mars/Olympus",
+		"Mon 09:00-17:00 UTC extra",
+		"09:00-17:00 Europe/Berlin extra",
+		"09:00-17:00 UTC Europe/Berlin",
+	} {
+		_, err := ParseTimeWindow(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestScheduledApiKeysListSet(t *testing.T) {
+	a := assert.New(t)
+
+	var list ScheduledApiKeysList
+	a.Nil(list.Set("Mon-Fri 08:00-18:00 America/New_York=19, 20"))
+	a.Len(list, 1)
+	a.Equal([]int{19, 20}, list[0].ApiKeys)
+	a.Equal("Mon-Fri 08:00-18:00 America/New_York", list[0].Window.String())
+
+	a.NotNil(list.Set("Mon-Fri 08:00-18:00"))
+	a.NotNil(list.Set("Mon-Fri 08:00-18:00=abc"))
+	a.NotNil(list.Set("Mon-Fri 08:00=19"))
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TenantPrefixes is a flag value accepting repeated "<name>=<prefix>" entries. The name is either a listener address,
+// the broker address of a listener or a principal. Principals like certificate subjects may contain '=', the prefix must not.
+// Names may share a prefix, but a prefix must not start with another prefix, e.g. tenant-a. and tenant-a.b., as the names of
+// the tenant with the shorter prefix would include the names of the other tenant.
+type TenantPrefixes map[string]string
+
+func (m *TenantPrefixes) String() string {
+	names := make([]string, 0, len(*m))
+	for name := range *m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]string, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, fmt.Sprintf("%s=%s", name, (*m)[name]))
+	}
+	return "[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *TenantPrefixes) Set(value string) error {
+	pos := strings.LastIndex(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid tenant prefix '%s', expected <name>=<prefix>", value)
+	}
+	name := strings.TrimSpace(value[:pos])
+	prefix := strings.TrimSpace(value[pos+1:])
+	if name == "" || prefix == "" {
+		return errors.Errorf("invalid tenant prefix '%s', expected <name>=<prefix>", value)
+	}
+	if *m == nil {
+		*m = make(TenantPrefixes)
+	}
+	if _, ok := (*m)[name]; ok {
+		return errors.Errorf("duplicate tenant prefix for %s", name)
+	}
+	if other, ok := overlappingPrefix(prefix, *m); ok {
+		return errors.Errorf("tenant prefix %s of %s overlaps with the tenant prefix %s", prefix, name, other)
+	}
+	(*m)[name] = prefix
+	return nil
+}
+
+// ValidateTenantPrefixes returns an error if a prefix starts with another prefix of the tenant prefixes
+func ValidateTenantPrefixes(tenantPrefixes ...TenantPrefixes) error {
+	for _, prefixes := range tenantPrefixes {
+		for name, prefix := range prefixes {
+			if other, ok := overlappingPrefix(prefix, tenantPrefixes...); ok {
+				return errors.Errorf("tenant prefix %s of %s overlaps with the tenant prefix %s", prefix, name, other)
+			}
+		}
+	}
+	return nil
+}
+
+// overlappingPrefix returns a different prefix which starts with the prefix or the prefix starts with
+func overlappingPrefix(prefix string, tenantPrefixes ...TenantPrefixes) (string, bool) {
+	for _, prefixes := range tenantPrefixes {
+		for _, other := range prefixes {
+			if other != prefix && (strings.HasPrefix(other, prefix) || strings.HasPrefix(prefix, other)) {
+				return other, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (m *TenantPrefixes) Type() string {
+	return "stringArray"
+}
+
+// Prefix returns the prefix of the first name with a prefix
+func (m TenantPrefixes) Prefix(names ...string) (string, bool) {
+	for _, name := range names {
+		if prefix, ok := m[name]; ok {
+			return prefix, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantPrefixesSet(t *testing.T) {
+	a := assert.New(t)
+
+	var prefixes TenantPrefixes
+	a.Nil(prefixes.Set("0.0.0.0:32400=tenant-a."))
+	a.Nil(prefixes.Set(" CN=billing,O=example = tenant-b. "))
+	a.Equal(TenantPrefixes{"0.0.0.0:32400": "tenant-a.", "CN=billing,O=example": "tenant-b."}, prefixes)
+	a.Equal("[0.0.0.0:32400=tenant-a. CN=billing,O=example=tenant-b.]", prefixes.String())
+
+	prefix, ok := prefixes.Prefix("0.0.0.0:32401", "CN=billing,O=example")
+	a.True(ok)
+	a.Equal("tenant-b.", prefix)
+	_, ok = prefixes.Prefix("kafka-0:9092")
+	a.False(ok)
+
+	a.NotNil(prefixes.Set("tenant-c."))
+	a.NotNil(prefixes.Set("kafka-2:9092="))
+	a.NotNil(prefixes.Set("0.0.0.0:32400=tenant-c."))
+}
+
+func TestTenantPrefixesOverlapping(t *testing.T) {
+	a := assert.New(t)
+
+	var prefixes TenantPrefixes
+	a.Nil(prefixes.Set("alice=tenant-a."))
+	// principals of the same tenant share the prefix
+	a.Nil(prefixes.Set("carol=tenant-a."))
+	a.EqualError(prefixes.Set("bob=tenant-a.b."), "tenant prefix tenant-a.b. of bob overlaps with the tenant prefix tenant-a.")
+	a.EqualError(prefixes.Set("bob=tenant-"), "tenant prefix tenant- of bob overlaps with the tenant prefix tenant-a.")
+	a.Nil(prefixes.Set("bob=tenant-b."))
+
+	a.Nil(ValidateTenantPrefixes(TenantPrefixes{"0.0.0.0:32400": "tenant-c."}, prefixes))
+	a.EqualError(ValidateTenantPrefixes(TenantPrefixes{"0.0.0.0:32400": "tenant-b.x."}, prefixes), "tenant prefix tenant-b.x. of 0.0.0.0:32400 overlaps with the tenant prefix tenant-b.")
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TopicKeys is a flag value accepting repeated "<topic>=<key name>" entries. The topic * matches all topics
+// without an own entry.
+type TopicKeys map[string]string
+
+func (m *TopicKeys) String() string {
+	topics := make([]string, 0, len(*m))
+	for topic := range *m {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	entries := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		entries = append(entries, fmt.Sprintf("%s=%s", topic, (*m)[topic]))
+	}
+	return "[" + strings.Join(entries, ",") + "]"
+}
+
+func (m *TopicKeys) Set(value string) error {
+	pos := strings.Index(value, "=")
+	if pos == -1 {
+		return errors.Errorf("invalid topic key '%s', expected <topic>=<key name>", value)
+	}
+	topic := strings.TrimSpace(value[:pos])
+	keyName := strings.TrimSpace(value[pos+1:])
+	if topic == "" || keyName == "" {
+		return errors.Errorf("invalid topic key '%s', expected <topic>=<key name>", value)
+	}
+	if *m == nil {
+		*m = make(TopicKeys)
+	}
+	if _, ok := (*m)[topic]; ok {
+		return errors.Errorf("duplicate key for topic %s", topic)
+	}
+	(*m)[topic] = keyName
+	return nil
+}
+
+func (m *TopicKeys) Type() string {
+	return "stringArray"
+}
+
+// KeyName returns the key name of the topic
+func (m TopicKeys) KeyName(topic string) (string, bool) {
+	if keyName, ok := m[topic]; ok {
+		return keyName, true
+	}
+	keyName, ok := m[AllTopics]
+	return keyName, ok
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicKeysSet(t *testing.T) {
+	a := assert.New(t)
+
+	var keys TopicKeys
+	a.Nil(keys.Set("orders=orders-key"))
+	a.Nil(keys.Set(" * = default-key "))
+	a.Equal(TopicKeys{"orders": "orders-key", "*": "default-key"}, keys)
+	a.Equal("[*=default-key,orders=orders-key]", keys.String())
+
+	keyName, ok := keys.KeyName("orders")
+	a.True(ok)
+	a.Equal("orders-key", keyName)
+	keyName, ok = keys.KeyName("payments")
+	a.True(ok)
+	a.Equal("default-key", keyName)
+
+	_, ok = TopicKeys{"orders": "orders-key"}.KeyName("payments")
+	a.False(ok)
+
+	a.NotNil(keys.Set("orders"))
+	a.NotNil(keys.Set("orders="))
+	a.NotNil(keys.Set("orders=other-key"))
+}
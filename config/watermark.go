@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// AllTopics matches every produced topic without an own watermark
+	AllTopics = "*"
+
+	defaultWatermarkWindow = time.Minute
+)
+
+// TopicWatermark defines the thresholds of records and bytes produced to a topic within a sliding window.
+// A zero threshold is disabled.
+type TopicWatermark struct {
+	Topic      string
+	Window     time.Duration
+	MaxRecords int64
+	MaxBytes   int64
+	// MinRecords detects stopped traffic: an alert is raised when fewer records are produced within the window
+	MinRecords int64
+}
+
+// ParseTopicWatermark parses "topic=<name|*>,window=<duration>,max-records=<n>,max-bytes=<n>,min-records=<n>".
+// The window defaults to 1m and at least one threshold is required.
+func ParseTopicWatermark(spec string) (TopicWatermark, error) {
+	w := TopicWatermark{Window: defaultWatermarkWindow}
+	for _, field := range strings.Split(spec, ",") {
+		pos := strings.Index(field, "=")
+		if pos == -1 {
+			return w, errors.Errorf("invalid topic watermark '%s', expected key=value in '%s'", spec, field)
+		}
+		key, value := strings.TrimSpace(field[:pos]), strings.TrimSpace(field[pos+1:])
+		var err error
+		switch key {
+		case "topic":
+			w.Topic = value
+		case "window":
+			w.Window, err = time.ParseDuration(value)
+			if err == nil && w.Window <= 0 {
+				err = errors.New("window must be greater than 0")
+			}
+		case "max-records":
+			w.MaxRecords, err = parseThreshold(value)
+		case "max-bytes":
+			w.MaxBytes, err = parseThreshold(value)
+		case "min-records":
+			w.MinRecords, err = parseThreshold(value)
+		default:
+			err = errors.Errorf("unknown key %s", key)
+		}
+		if err != nil {
+			return w, errors.Wrapf(err, "invalid topic watermark '%s'", spec)
+		}
+	}
+	if w.Topic == "" {
+		return w, errors.Errorf("invalid topic watermark '%s', topic is required", spec)
+	}
+	if w.MaxRecords == 0 && w.MaxBytes == 0 && w.MinRecords == 0 {
+		return w, errors.Errorf("invalid topic watermark '%s', one of max-records, max-bytes or min-records is required", spec)
+	}
+	return w, nil
+}
+
+func parseThreshold(value string) (int64, error) {
+	threshold, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || threshold < 0 {
+		return 0, errors.Errorf("invalid threshold '%s'", value)
+	}
+	return threshold, nil
+}
+
+func (w TopicWatermark) String() string {
+	return fmt.Sprintf("topic=%s,window=%v,max-records=%d,max-bytes=%d,min-records=%d", w.Topic, w.Window, w.MaxRecords, w.MaxBytes, w.MinRecords)
+}
+
+// TopicWatermarks is a flag value accepting repeated topic watermarks
+type TopicWatermarks []TopicWatermark
+
+func (ws *TopicWatermarks) String() string {
+	specs := make([]string, 0, len(*ws))
+	for _, w := range *ws {
+		specs = append(specs, w.String())
+	}
+	return "[" + strings.Join(specs, " ") + "]"
+}
+
+func (ws *TopicWatermarks) Set(value string) error {
+	w, err := ParseTopicWatermark(value)
+	if err != nil {
+		return err
+	}
+	for _, other := range *ws {
+		if other.Topic == w.Topic {
+			return errors.Errorf("topic watermark for %s configured twice", w.Topic)
+		}
+	}
+	*ws = append(*ws, w)
+	return nil
+}
+
+func (ws *TopicWatermarks) Type() string {
+	return "stringArray"
+}
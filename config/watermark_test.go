@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTopicWatermark(t *testing.T) {
+	a := assert.New(t)
+
+	w, err := ParseTopicWatermark("topic=orders,window=5m,max-records=1000,max-bytes=1048576")
+	a.Nil(err)
+	a.Equal(TopicWatermark{Topic: "orders", Window: 5 * time.Minute, MaxRecords: 1000, MaxBytes: 1048576}, w)
+
+	w, err = ParseTopicWatermark("topic=*, min-records=1")
+	a.Nil(err)
+	a.Equal(TopicWatermark{Topic: AllTopics, Window: time.Minute, MinRecords: 1}, w)
+}
+
+func TestParseTopicWatermarkErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"orders",
+		"max-records=10",
+		"topic=orders",
+		"topic=orders,window=0s,max-records=10",
+		"topic=orders,window=1x,max-records=10",
+		"topic=orders,max-records=-1",
+		"topic=orders,max-records=ten",
+		"topic=orders,unknown=10",
+	} {
+		_, err := ParseTopicWatermark(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestTopicWatermarksSet(t *testing.T) {
+	a := assert.New(t)
+
+	var ws TopicWatermarks
+	a.Nil(ws.Set("topic=orders,max-records=10"))
+	a.Nil(ws.Set("topic=*,min-records=1"))
+	a.NotNil(ws.Set("topic=orders,max-bytes=10"))
+	a.Len(ws, 2)
+}
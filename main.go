@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"github.com/grepplabs/kafka-proxy/cmd/kafka-proxy"
 	"github.com/grepplabs/kafka-proxy/cmd/tools"
 	"github.com/spf13/cobra"
@@ -20,13 +19,13 @@ var RootCmd = &cobra.Command{
 
 func init() {
 	RootCmd.AddCommand(server.Server)
+	RootCmd.AddCommand(server.Check)
 	RootCmd.AddCommand(server.Version)
 	RootCmd.AddCommand(tools.Tools)
 }
 
 func main() {
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(server.ReportError(err))
 	}
 }
@@ -0,0 +1,64 @@
+package apis
+
+import (
+	"context"
+)
+
+type RequestInfo struct {
+	BrokerAddress string
+	// Principal is the SASL user authenticated by the proxy or the client certificate common name
+	Principal     string
+	ClientID      string
+	ApiKey        int32
+	ApiVersion    int32
+	CorrelationID int32
+	// Topics are provided for Produce (v0-v8), Fetch (v0-v11) and Metadata (v0-v8) requests
+	Topics []string
+	// TopicsDecoded is false, when the topics of the request could not be decoded.
+	// Topics of Metadata requests for all topics are decoded as empty
+	TopicsDecoded bool
+	// Records are provided for Produce requests when the record headers are enabled
+	Records []ProducedRecords
+}
+
+// RecordHeader is a header of a produced record, the value of a null header is nil
+type RecordHeader struct {
+	Key   string
+	Value []byte
+}
+
+// ProducedRecords are the headers of the records produced to a topic partition
+type ProducedRecords struct {
+	Topic     string
+	Partition int32
+	// Headers of each record in the order of the records. Legacy messages (magic v0 and v1) have no headers.
+	Headers [][]RecordHeader
+}
+
+type ResponseInfo struct {
+	BrokerAddress string
+	Principal     string
+	ClientID      string
+	ApiKey        int32
+	ApiVersion    int32
+	CorrelationID int32
+}
+
+type InterceptResult struct {
+	// Deny closes the client connection, as a Kafka request cannot be dropped
+	Deny   bool
+	Reason string
+	// Annotations are added to the proxy log
+	Annotations map[string]string
+}
+
+type Interceptor interface {
+	// InterceptRequest decides about the request before it is forwarded to the broker. The returned error is only used by the underlying rpc protocol
+	InterceptRequest(ctx context.Context, request RequestInfo) (InterceptResult, error)
+	// InterceptResponse decides about the response before it is returned to the client. The returned error is only used by the underlying rpc protocol
+	InterceptResponse(ctx context.Context, response ResponseInfo) (InterceptResult, error)
+}
+
+type InterceptorFactory interface {
+	New(params []string) (Interceptor, error)
+}
@@ -30,6 +30,10 @@ type VerifyRequest struct {
 type VerifyResponse struct {
 	Success bool
 	Status  int32
+	// Subject is the authenticated subject of the verified token, empty if the plugin does not provide it
+	Subject string
+	// Claims are the claims of the verified token selected by the plugin
+	Claims map[string]string
 }
 
 type TokenInfo interface {
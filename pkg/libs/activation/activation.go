@@ -0,0 +1,137 @@
+// Package activation provides the listener sockets passed by systemd socket activation (sd_listen_fds).
+// The sockets are held by systemd, so connections are queued while the proxy restarts.
+package activation
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// first file descriptor passed by systemd
+const listenFdsStart = 3
+
+type socket struct {
+	// FileDescriptorName of the socket unit
+	name     string
+	listener net.Listener
+	taken    bool
+}
+
+var (
+	inheritOnce sync.Once
+	mu          sync.Mutex
+	inherited   []*socket
+)
+
+// Listen returns the inherited listener matching the address or announces on the address
+func Listen(network, address string) (net.Listener, error) {
+	if l := take(address); l != nil {
+		logrus.Infof("Using socket %s passed by systemd for %s", l.Addr(), address)
+		return l, nil
+	}
+	return net.Listen(network, address)
+}
+
+// Unused returns the addresses of the inherited sockets which were not taken by Listen
+func Unused() []string {
+	inheritOnce.Do(inherit)
+	mu.Lock()
+	defer mu.Unlock()
+
+	addresses := make([]string, 0)
+	for _, s := range inherited {
+		if !s.taken {
+			addresses = append(addresses, s.listener.Addr().String())
+		}
+	}
+	return addresses
+}
+
+func take(address string) net.Listener {
+	inheritOnce.Do(inherit)
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, s := range inherited {
+		if !s.taken && matches(address, s) {
+			s.taken = true
+			return s.listener
+		}
+	}
+	return nil
+}
+
+func inherit() {
+	for _, f := range listenFiles() {
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			logrus.Warnf("Socket %s passed by systemd is not a listener: %v", f.Name(), err)
+			continue
+		}
+		inherited = append(inherited, &socket{name: f.Name(), listener: l})
+	}
+}
+
+// listenFiles returns the files passed by systemd if they are meant for the current process.
+// The environment variables are unset, so child processes do not inherit them
+func listenFiles() []*os.File {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	files := make([]*os.File, 0, n)
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		closeOnExec(fd)
+		name := "fd" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		files = append(files, os.NewFile(uintptr(fd), name))
+	}
+	return files
+}
+
+// matches reports whether the socket serves the address. The FileDescriptorName of the socket can be set to the address,
+// otherwise the bound address is compared. Sockets bound to all interfaces e.g. ListenStream=9092 match unspecified hosts.
+func matches(address string, s *socket) bool {
+	if s.name == address {
+		return true
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	boundHost, boundPort, err := net.SplitHostPort(s.listener.Addr().String())
+	if err != nil || port != boundPort {
+		return false
+	}
+	if host == boundHost {
+		return true
+	}
+	return isUnspecified(host) && isUnspecified(boundHost)
+}
+
+func isUnspecified(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
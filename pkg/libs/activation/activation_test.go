@@ -0,0 +1,69 @@
+package activation
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listen(t *testing.T, address string) net.Listener {
+	l, err := net.Listen("tcp", address)
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	return l
+}
+
+func TestMatches(t *testing.T) {
+	a := assert.New(t)
+
+	local := &socket{name: "fd3", listener: listen(t, "127.0.0.1:0")}
+	_, port, _ := net.SplitHostPort(local.listener.Addr().String())
+	a.True(matches("127.0.0.1:"+port, local))
+	a.False(matches("127.0.0.2:"+port, local))
+	a.False(matches("0.0.0.0:"+port, local))
+	a.False(matches("127.0.0.1:1", local))
+	a.False(matches("invalid", local))
+
+	all := &socket{name: "kafka", listener: listen(t, ":0")}
+	_, port, _ = net.SplitHostPort(all.listener.Addr().String())
+	a.True(matches("0.0.0.0:"+port, all))
+	a.True(matches(":"+port, all))
+	a.False(matches("127.0.0.1:"+port, all))
+
+	named := &socket{name: "0.0.0.0:32400", listener: local.listener}
+	a.True(matches("0.0.0.0:32400", named))
+}
+
+func TestListen(t *testing.T) {
+	a := assert.New(t)
+
+	inheritOnce.Do(func() {})
+	l := listen(t, "127.0.0.1:0")
+	inherited = []*socket{{name: "fd3", listener: l}}
+	defer func() { inherited = nil }()
+
+	a.Equal([]string{l.Addr().String()}, Unused())
+	taken, err := Listen("tcp", l.Addr().String())
+	a.Nil(err)
+	a.True(l == taken)
+	a.Empty(Unused())
+
+	// not inherited
+	other, err := Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	a.NotEqual(l.Addr().String(), other.Addr().String())
+	_ = other.Close()
+}
+
+func TestListenFilesOtherProcess(t *testing.T) {
+	a := assert.New(t)
+
+	_ = os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	_ = os.Setenv("LISTEN_FDS", "1")
+	a.Empty(listenFiles())
+	_, ok := os.LookupEnv("LISTEN_FDS")
+	a.False(ok)
+}
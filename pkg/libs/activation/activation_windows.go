@@ -0,0 +1,4 @@
+package activation
+
+// sockets are not passed on Windows
+func closeOnExec(int) {}
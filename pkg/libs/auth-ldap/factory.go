@@ -0,0 +1,132 @@
+package authldap
+
+import (
+	"flag"
+	"fmt"
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/registry"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	registry.NewComponentInterface(new(apis.PasswordAuthenticatorFactory))
+	registry.Register(new(Factory), "auth-ldap")
+}
+
+type pluginMeta struct {
+	url                string
+	caCertFile         string
+	insecureSkipVerify bool
+	startTLS           bool
+	upnDomain          string
+	userDN             string
+	userAttr           string
+
+	searchLDAP     bool
+	bindDN         string
+	bindPassword   string
+	userSearchBase string
+	userFilter     string
+}
+
+func (f *pluginMeta) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("auth plugin settings", flag.ContinueOnError)
+
+	fs.StringVar(&f.url, "url", "", "LDAP URL to connect to (eg: ldaps://127.0.0.1:636). Multiple URLs can be specified by concatenating them with commas.")
+	fs.StringVar(&f.caCertFile, "ldap-ca-cert-file", "", "X509 CA certificate (PEM) to verify peer against")
+	fs.BoolVar(&f.insecureSkipVerify, "ldap-insecure-skip-verify", false, "It controls whether a client verifies the server's certificate chain and host name")
+	fs.BoolVar(&f.startTLS, "start-tls", true, "Issue a StartTLS command after establishing unencrypted connection (optional)")
+	fs.StringVar(&f.upnDomain, "upn-domain", "", "Enables userPrincipalDomain login with [username]@UPNDomain (optional)")
+	fs.StringVar(&f.userDN, "user-dn", "", "LDAP domain to use for users (eg: cn=users,dc=example,dc=org)")
+	fs.StringVar(&f.userAttr, "user-attr", "uid", " Attribute used for users")
+
+	fs.BoolVar(&f.searchLDAP, "search-ldap", false, "Search LDAP for user DN even if --bind-dn is not set")
+	fs.StringVar(&f.bindDN, "bind-dn", "", "The Distinguished Name to bind to the LDAP directory to search a user. This can be a readonly or admin user")
+	fs.StringVar(&f.bindPassword, "bind-passwd", "", "The password used with bindDN")
+	fs.StringVar(&f.userSearchBase, "user-search-base", "", "The search base as the starting point for the user search e.g. ou=people,dc=example,dc=org")
+	fs.StringVar(&f.userFilter, "user-filter", "", fmt.Sprintf("The user search filter. It must contain '%s' placeholder for the username e.g. (&(objectClass=person)(uid=%s)(memberOf=cn=kafka-users,ou=realm-roles,dc=example,dc=org))", UsernamePlaceholder, UsernamePlaceholder))
+
+	return fs
+}
+
+func (f *pluginMeta) getUrls() ([]string, error) {
+	result := make([]string, 0)
+	urls := strings.Split(f.url, ",")
+	for _, uut := range urls {
+		u, err := url.Parse(uut)
+		if err != nil {
+			return nil, err
+		}
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			host = u.Host
+		}
+		switch u.Scheme {
+		case "ldap", "ldaps":
+			result = append(result, uut)
+		default:
+			return nil, fmt.Errorf("invalid LDAP scheme in url %q", net.JoinHostPort(host, port))
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("empty LDAP url list")
+	}
+	return result, nil
+}
+
+type Factory struct {
+}
+
+// New implements apis.PasswordAuthenticatorFactory
+func (t *Factory) New(params []string) (apis.PasswordAuthenticator, error) {
+	pluginMeta := &pluginMeta{}
+	flags := pluginMeta.flagSet()
+	if err := flags.Parse(params); err != nil {
+		return nil, err
+	}
+
+	urls, err := pluginMeta.getUrls()
+	if err != nil {
+		return nil, err
+	}
+	if pluginMeta.bindDN != "" || pluginMeta.searchLDAP {
+		logrus.Infof("user-search-base='%s',user-filter='%s'", pluginMeta.userSearchBase, pluginMeta.userFilter)
+
+		if pluginMeta.userSearchBase == "" {
+			logrus.Errorf("user-search-base is required")
+		}
+		if !strings.Contains(pluginMeta.userFilter, UsernamePlaceholder) {
+			logrus.Errorf("user-filter must contain '%s' as username placeholder", UsernamePlaceholder)
+		}
+
+	} else if pluginMeta.upnDomain != "" || pluginMeta.userDN != "" {
+		if pluginMeta.userDN != "" && pluginMeta.userAttr == "" {
+			return nil, errors.New("parameters user-dn and user-attr are required")
+		}
+	} else {
+		return nil, errors.New("parameters user-dn or bind-dn are required")
+	}
+
+	tlsConfig, err := getTlsConfig(pluginMeta.caCertFile, pluginMeta.insecureSkipVerify)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting TLS config")
+	}
+
+	return &LdapAuthenticator{
+		Urls:           urls,
+		TlsConfig:      tlsConfig,
+		StartTLS:       pluginMeta.startTLS,
+		UPNDomain:      pluginMeta.upnDomain,
+		UserDN:         pluginMeta.userDN,
+		UserAttr:       pluginMeta.userAttr,
+		SearchLDAP:     pluginMeta.searchLDAP || pluginMeta.bindDN != "",
+		BindDN:         pluginMeta.bindDN,
+		BindPassword:   pluginMeta.bindPassword,
+		UserSearchBase: pluginMeta.userSearchBase,
+		UserFilter:     pluginMeta.userFilter,
+	}, nil
+}
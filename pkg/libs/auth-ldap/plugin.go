@@ -0,0 +1,206 @@
+package authldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const UsernamePlaceholder = "%u"
+
+type LdapAuthenticator struct {
+	Urls      []string
+	StartTLS  bool
+	TlsConfig *tls.Config
+
+	UPNDomain string
+	UserDN    string
+	UserAttr  string
+
+	SearchLDAP     bool
+	BindDN         string
+	BindPassword   string
+	UserSearchBase string
+	UserFilter     string
+}
+
+func (pa LdapAuthenticator) Authenticate(username, password string) (bool, int32, error) {
+	// logrus.Printf("Authenticate request for %s:%s,expected %s:%s ", username, password, pa.username, pa.password)
+	l, err := pa.DialLDAP()
+	if err != nil {
+		logrus.Errorf("user %s ldap dial error %v", username, err)
+		return false, 1, nil
+	}
+	if l == nil {
+		logrus.Errorf("ldap connection is nil")
+		return false, 1, nil
+	}
+	defer l.Close()
+
+	bindDN, err := pa.getUserBindDN(l, username)
+	if err != nil {
+		logrus.Errorf("user %s ldap get user bindDN error %v", username, err)
+		return false, 1, nil
+	}
+	err = l.Bind(bindDN, password)
+	if err != nil {
+		if ldapErr, ok := err.(*ldap.Error); ok && ldapErr.ResultCode == ldap.LDAPResultInvalidCredentials {
+			logrus.Errorf("user %s credentials are invalid", username)
+			return false, 0, nil
+		}
+		logrus.Errorf("user %s ldap bind error %v", username, err)
+		return false, 2, nil
+	}
+	return true, 0, nil
+}
+
+func (pa LdapAuthenticator) getUserBindDN(conn *ldap.Conn, username string) (string, error) {
+	bindDN := ""
+	if pa.SearchLDAP {
+		var err error
+		if pa.BindDN != "" {
+			if pa.BindPassword != "" {
+				err = conn.Bind(pa.BindDN, pa.BindPassword)
+			} else {
+				err = conn.UnauthenticatedBind(pa.BindDN)
+			}
+			if err != nil {
+				return "", errors.Wrapf(err, "LDAP bind (service) failed")
+			}
+		}
+		filter := strings.ReplaceAll(pa.UserFilter, UsernamePlaceholder, username)
+		searchRequest := ldap.NewSearchRequest(
+			pa.UserSearchBase,
+			ldap.ScopeWholeSubtree,
+			ldap.NeverDerefAliases,
+			0,
+			0,
+			false,
+			filter,
+			[]string{"dn"},
+			nil,
+		)
+		sr, err := conn.Search(searchRequest)
+		if err != nil {
+			return "", errors.Wrapf(err, "base DN %s, filter %s", pa.UserSearchBase, filter)
+		}
+		if len(sr.Entries) < 1 {
+			return "", errors.Errorf("LDAP user search with base DN %s and filter %s returned empty result", pa.UserSearchBase, filter)
+		}
+		if len(sr.Entries) > 1 {
+			return "", errors.Errorf("LDAP user search with base DN %s and filter %s not unique result", pa.UserSearchBase, filter)
+		}
+		bindDN = sr.Entries[0].DN
+	} else {
+		if pa.UPNDomain != "" {
+			bindDN = fmt.Sprintf("%s@%s", escapeLDAPValue(username), pa.UPNDomain)
+		} else {
+			bindDN = fmt.Sprintf("%s=%s,%s", pa.UserAttr, escapeLDAPValue(username), pa.UserDN)
+		}
+	}
+	return bindDN, nil
+}
+
+func escapeLDAPValue(input string) string {
+	// RFC4514 forbids un-escaped:
+	// - leading space or hash
+	// - trailing space
+	// - special characters '"', '+', ',', ';', '<', '>', '\\'
+	// - null
+	for i := 0; i < len(input); i++ {
+		escaped := false
+		if input[i] == '\\' {
+			i++
+			escaped = true
+		}
+		switch input[i] {
+		case '"', '+', ',', ';', '<', '>', '\\':
+			if !escaped {
+				input = input[0:i] + "\\" + input[i:]
+				i++
+			}
+			continue
+		}
+		if escaped {
+			input = input[0:i] + "\\" + input[i:]
+			i++
+		}
+	}
+	if input[0] == ' ' || input[0] == '#' {
+		input = "\\" + input
+	}
+	if input[len(input)-1] == ' ' {
+		input = input[0:len(input)-1] + "\\ "
+	}
+	return input
+}
+func (pa LdapAuthenticator) DialLDAP() (*ldap.Conn, error) {
+	var retErr *multierror.Error
+	var conn *ldap.Conn
+	for _, uut := range pa.Urls {
+		u, err := url.Parse(uut)
+		if err != nil {
+			retErr = multierror.Append(retErr, fmt.Errorf("error parsing url %q: %s", uut, err.Error()))
+			continue
+		}
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			host = u.Host
+		}
+		switch u.Scheme {
+		case "ldap":
+			if port == "" {
+				port = "389"
+			}
+			conn, err = ldap.Dial("tcp", net.JoinHostPort(host, port))
+			if err != nil {
+				break
+			}
+			if conn == nil {
+				err = fmt.Errorf("empty connection after dialing")
+				break
+			}
+			if pa.StartTLS {
+				err = conn.StartTLS(&tls.Config{InsecureSkipVerify: true})
+			}
+		case "ldaps":
+			if port == "" {
+				port = "636"
+			}
+			conn, err = ldap.DialTLS("tcp", net.JoinHostPort(host, port), pa.TlsConfig)
+		default:
+			retErr = multierror.Append(retErr, fmt.Errorf("invalid LDAP scheme in url %q", net.JoinHostPort(host, port)))
+			continue
+		}
+		if err == nil {
+			retErr = nil
+			break
+		}
+		retErr = multierror.Append(retErr, fmt.Errorf("error connecting to host %q: %s", uut, err.Error()))
+	}
+	return conn, retErr.ErrorOrNil()
+}
+
+func getTlsConfig(caCertFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	if caCertFile == "" {
+		return &tls.Config{InsecureSkipVerify: insecureSkipVerify}, nil
+	} else {
+		certData, err := ioutil.ReadFile(caCertFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading certificate file %s", caCertFile)
+		}
+		certPool := x509.NewCertPool()
+		if ok := certPool.AppendCertsFromPEM(certData); !ok {
+			return nil, errors.Errorf("could not parse certificate(s) in file %s", caCertFile)
+		}
+		return &tls.Config{RootCAs: certPool}, nil
+	}
+}
@@ -0,0 +1,36 @@
+package authuser
+
+import (
+	"flag"
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/registry"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.NewComponentInterface(new(apis.PasswordAuthenticatorFactory))
+	registry.Register(new(Factory), "auth-user")
+}
+
+func (f *PasswordAuthenticator) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("auth plugin settings", flag.ContinueOnError)
+	fs.StringVar(&f.Username, "username", "", "Expected SASL username")
+	fs.StringVar(&f.Password, "password", "", "Expected SASL password")
+	return fs
+}
+
+type Factory struct {
+}
+
+// New implements apis.PasswordAuthenticatorFactory
+func (t *Factory) New(params []string) (apis.PasswordAuthenticator, error) {
+	passwordAuthenticator := &PasswordAuthenticator{}
+	flags := passwordAuthenticator.flagSet()
+	if err := flags.Parse(params); err != nil {
+		return nil, err
+	}
+	if passwordAuthenticator.Username == "" || passwordAuthenticator.Password == "" {
+		return nil, errors.New("parameters username and password are required")
+	}
+	return passwordAuthenticator, nil
+}
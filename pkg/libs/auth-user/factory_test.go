@@ -0,0 +1,30 @@
+package authuser
+
+import (
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactory(t *testing.T) {
+	a := assert.New(t)
+
+	factory, ok := registry.GetComponent(new(apis.PasswordAuthenticatorFactory), "auth-user").(apis.PasswordAuthenticatorFactory)
+	a.True(ok)
+
+	authenticator, err := factory.New([]string{"--username", "alice", "--password", "secret"})
+	a.Nil(err)
+
+	ok, status, err := authenticator.Authenticate("alice", "secret")
+	a.Nil(err)
+	a.True(ok)
+	a.Equal(int32(0), status)
+
+	ok, _, _ = authenticator.Authenticate("alice", "wrong")
+	a.False(ok)
+
+	_, err = factory.New([]string{"--username", "alice"})
+	a.NotNil(err)
+}
@@ -0,0 +1,11 @@
+package authuser
+
+type PasswordAuthenticator struct {
+	Username string
+	Password string
+}
+
+func (pa PasswordAuthenticator) Authenticate(username, password string) (bool, int32, error) {
+	// logrus.Printf("Authenticate request for %s:%s,expected %s:%s ", username, password, pa.username, pa.password)
+	return username == pa.Username && password == pa.Password, 0, nil
+}
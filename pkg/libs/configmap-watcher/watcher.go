@@ -0,0 +1,180 @@
+package configmapwatcher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// files of the pod service account
+const (
+	serviceAccountTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCAFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+type Config struct {
+	// namespace of the ConfigMap, defaults to the pod namespace
+	Namespace string
+	Name      string
+	// data key with the configuration
+	Key          string
+	PollInterval time.Duration
+}
+
+// subset of the core/v1 ConfigMap
+type configMap struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+}
+
+// Watcher polls a ConfigMap using the Kubernetes API and notifies about changes of the configuration key
+type Watcher struct {
+	cfg       Config
+	apiURL    string
+	tokenFile string
+	client    *http.Client
+
+	resourceVersion string
+	closeOnce       sync.Once
+	closed          chan struct{}
+}
+
+// NewInCluster creates a watcher authenticated with the pod service account
+func NewInCluster(cfg Config) (*Watcher, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+	caCert, err := ioutil.ReadFile(serviceAccountCAFile)
+	if err != nil {
+		return nil, err
+	}
+	rootCAs := x509.NewCertPool()
+	if ok := rootCAs.AppendCertsFromPEM(caCert); !ok {
+		return nil, errors.Errorf("failed to parse %s", serviceAccountCAFile)
+	}
+	if cfg.Namespace == "" {
+		namespace, err := ioutil.ReadFile(serviceAccountNamespaceFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Namespace = strings.TrimSpace(string(namespace))
+	}
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}},
+	}
+	return New(cfg, "https://"+net.JoinHostPort(host, port), serviceAccountTokenFile, client), nil
+}
+
+// New creates a watcher for the API server URL. The bearer token is read from the token file on each request, so rotated tokens are used
+func New(cfg Config, apiURL string, tokenFile string, client *http.Client) *Watcher {
+	return &Watcher{
+		cfg:       cfg,
+		apiURL:    strings.TrimSuffix(apiURL, "/"),
+		tokenFile: tokenFile,
+		client:    client,
+		closed:    make(chan struct{}),
+	}
+}
+
+// Name returns the namespace/name of the ConfigMap
+func (w *Watcher) Name() string {
+	return w.cfg.Namespace + "/" + w.cfg.Name
+}
+
+// Get returns the configuration of the ConfigMap
+func (w *Watcher) Get() (string, error) {
+	cm, err := w.fetch()
+	if err != nil {
+		return "", err
+	}
+	data, ok := cm.Data[w.cfg.Key]
+	if !ok {
+		return "", errors.Errorf("configmap %s has no key %s", w.Name(), w.cfg.Key)
+	}
+	w.resourceVersion = cm.Metadata.ResourceVersion
+	return data, nil
+}
+
+// Run calls onChange with the configuration when the ConfigMap changes until the watcher is closed.
+// Failed requests are retried on the next poll.
+func (w *Watcher) Run(onChange func(data string) error) error {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cm, err := w.fetch()
+			if err != nil {
+				logrus.Warnf("Polling configmap %s failed: %v", w.Name(), err)
+				continue
+			}
+			if cm.Metadata.ResourceVersion == w.resourceVersion {
+				continue
+			}
+			w.resourceVersion = cm.Metadata.ResourceVersion
+			data, ok := cm.Data[w.cfg.Key]
+			if !ok {
+				logrus.Warnf("Configmap %s has no key %s, the change is ignored", w.Name(), w.cfg.Key)
+				continue
+			}
+			logrus.Infof("Configmap %s changed (resource version %s)", w.Name(), cm.Metadata.ResourceVersion)
+			if err = onChange(data); err != nil {
+				logrus.Error(err)
+			}
+		case <-w.closed:
+			return nil
+		}
+	}
+}
+
+func (w *Watcher) Close() {
+	w.closeOnce.Do(func() { close(w.closed) })
+}
+
+func (w *Watcher) fetch() (*configMap, error) {
+	token, err := ioutil.ReadFile(w.tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	requestURL := fmt.Sprintf("%s/api/v1/namespaces/%s/configmaps/%s", w.apiURL, url.PathEscape(w.cfg.Namespace), url.PathEscape(w.cfg.Name))
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("get configmap %s: %s: %s", w.Name(), resp.Status, strings.TrimSpace(string(body)))
+	}
+	cm := &configMap{}
+	if err = json.Unmarshal(body, cm); err != nil {
+		return nil, errors.Wrapf(err, "get configmap %s", w.Name())
+	}
+	return cm, nil
+}
@@ -0,0 +1,103 @@
+package configmapwatcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAPIServer struct {
+	mu              sync.Mutex
+	resourceVersion int
+	data            string
+	tokens          []string
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = append(s.tokens, r.Header.Get("Authorization"))
+	if r.URL.Path != "/api/v1/namespaces/kafka/configmaps/kafka-proxy" {
+		http.Error(w, `{"kind":"Status","reason":"NotFound"}`, http.StatusNotFound)
+		return
+	}
+	_, _ = fmt.Fprintf(w, `{"metadata":{"name":"kafka-proxy","resourceVersion":"%d"},"data":{"kafka-proxy.yaml":%q}}`, s.resourceVersion, s.data)
+}
+
+func (s *fakeAPIServer) update(data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resourceVersion++
+	s.data = data
+}
+
+func newTestWatcher(t *testing.T, apiURL string, name string) *Watcher {
+	dir, err := ioutil.TempDir("", "configmap-watcher")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	tokenFile := filepath.Join(dir, "token")
+	assert.Nil(t, ioutil.WriteFile(tokenFile, []byte("secret-token\n"), 0600))
+	cfg := Config{Namespace: "kafka", Name: name, Key: "kafka-proxy.yaml", PollInterval: 10 * time.Millisecond}
+	return New(cfg, apiURL, tokenFile, http.DefaultClient)
+}
+
+func TestWatcherGet(t *testing.T) {
+	a := assert.New(t)
+
+	apiServer := &fakeAPIServer{}
+	apiServer.update("forbidden-api-keys: [20]")
+	server := httptest.NewServer(apiServer)
+	defer server.Close()
+
+	data, err := newTestWatcher(t, server.URL, "kafka-proxy").Get()
+	a.Nil(err)
+	a.Equal("forbidden-api-keys: [20]", data)
+	a.Equal([]string{"Bearer secret-token"}, apiServer.tokens)
+
+	_, err = newTestWatcher(t, server.URL, "missing").Get()
+	a.NotNil(err)
+	a.Contains(err.Error(), "404 Not Found")
+}
+
+func TestWatcherRun(t *testing.T) {
+	a := assert.New(t)
+
+	apiServer := &fakeAPIServer{}
+	apiServer.update("forbidden-api-keys: [20]")
+	server := httptest.NewServer(apiServer)
+	defer server.Close()
+
+	watcher := newTestWatcher(t, server.URL, "kafka-proxy")
+	_, err := watcher.Get()
+	a.Nil(err)
+
+	changes := make(chan string, 10)
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(func(data string) error {
+			changes <- data
+			return nil
+		})
+	}()
+	apiServer.update("forbidden-api-keys: [19, 20]")
+	select {
+	case data := <-changes:
+		a.Equal("forbidden-api-keys: [19, 20]", data)
+	case <-time.After(5 * time.Second):
+		a.Fail("change not notified")
+	}
+	// unchanged resource version is not notified
+	time.Sleep(50 * time.Millisecond)
+	a.Len(changes, 0)
+
+	watcher.Close()
+	a.Nil(<-done)
+}
@@ -0,0 +1,166 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// envelope header: magic (4 bytes), version (1 byte)
+var envelopeHeader = []byte{0x00, 'K', 'P', 'E', 0x01}
+
+const maxCachedDataKeys = 1024
+
+// Envelope encrypts values with AES-256-GCM data keys, which are generated and encrypted by the key provider.
+// An encrypted value consists of the envelope header, the key name, the encrypted data key, the nonce and the ciphertext.
+// The topic is authenticated as additional data. A data key is used for encryption until its TTL expires.
+type Envelope struct {
+	provider   KeyProvider
+	dataKeyTTL time.Duration
+	timeout    time.Duration
+	now        func() time.Time
+
+	mu             sync.Mutex
+	encryptionKeys map[string]*dataKey
+	// key name and encrypted data key to decrypted data key
+	decryptionKeys map[string]cipher.AEAD
+}
+
+type dataKey struct {
+	aead       cipher.AEAD
+	ciphertext []byte
+	expires    time.Time
+}
+
+func NewEnvelope(provider KeyProvider, dataKeyTTL time.Duration, timeout time.Duration) *Envelope {
+	return &Envelope{
+		provider:       provider,
+		dataKeyTTL:     dataKeyTTL,
+		timeout:        timeout,
+		now:            time.Now,
+		encryptionKeys: make(map[string]*dataKey),
+		decryptionKeys: make(map[string]cipher.AEAD),
+	}
+}
+
+// IsEncrypted reports whether the value starts with the envelope header
+func IsEncrypted(value []byte) bool {
+	return bytes.HasPrefix(value, envelopeHeader)
+}
+
+func (e *Envelope) encryptionKey(keyName string) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if key, ok := e.encryptionKeys[keyName]; ok && now.Before(key.expires) {
+		return key, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	plaintext, ciphertext, err := e.provider.GenerateDataKey(ctx, keyName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "data key generation for key %s failed", keyName)
+	}
+	if len(ciphertext) > math.MaxUint16 {
+		return nil, errors.Errorf("encrypted data key for key %s is too long", keyName)
+	}
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	key := &dataKey{aead: aead, ciphertext: ciphertext, expires: now.Add(e.dataKeyTTL)}
+	e.encryptionKeys[keyName] = key
+	return key, nil
+}
+
+func (e *Envelope) decryptionKey(keyName string, ciphertext []byte) (cipher.AEAD, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cacheKey := keyName + "\x00" + string(ciphertext)
+	if aead, ok := e.decryptionKeys[cacheKey]; ok {
+		return aead, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	plaintext, err := e.provider.DecryptDataKey(ctx, keyName, ciphertext)
+	if err != nil {
+		return nil, errors.Wrapf(err, "data key decryption for key %s failed", keyName)
+	}
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.decryptionKeys) >= maxCachedDataKeys {
+		e.decryptionKeys = make(map[string]cipher.AEAD)
+	}
+	e.decryptionKeys[cacheKey] = aead
+	return aead, nil
+}
+
+// Encrypt seals the value with the current data key of the key name
+func (e *Envelope) Encrypt(keyName string, topic string, value []byte) ([]byte, error) {
+	if len(keyName) > math.MaxUint8 {
+		return nil, errors.Errorf("key name %s is too long", keyName)
+	}
+	key, err := e.encryptionKey(keyName)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := key.aead.NonceSize()
+	result := make([]byte, 0, len(envelopeHeader)+1+len(keyName)+2+len(key.ciphertext)+nonceSize+len(value)+key.aead.Overhead())
+	result = append(result, envelopeHeader...)
+	result = append(result, byte(len(keyName)))
+	result = append(result, keyName...)
+	result = append(result, byte(len(key.ciphertext)>>8), byte(len(key.ciphertext)))
+	result = append(result, key.ciphertext...)
+
+	nonceStart := len(result)
+	result = result[:nonceStart+nonceSize]
+	if _, err = io.ReadFull(rand.Reader, result[nonceStart:]); err != nil {
+		return nil, err
+	}
+	return key.aead.Seal(result, result[nonceStart:], value, []byte(topic)), nil
+}
+
+// Decrypt opens the value encrypted with the key name. Values without the envelope header are returned unchanged.
+// The key name stored in the value is not trusted, values of other keys are not decrypted.
+func (e *Envelope) Decrypt(keyName string, topic string, value []byte) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	rest := value[len(envelopeHeader):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0])+2 {
+		return nil, errors.New("invalid encrypted value")
+	}
+	if valueKeyName := string(rest[1 : 1+int(rest[0])]); valueKeyName != keyName {
+		return nil, errors.Errorf("value is encrypted with key %s instead of %s", valueKeyName, keyName)
+	}
+	rest = rest[1+int(rest[0]):]
+	ciphertextLength := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+ciphertextLength {
+		return nil, errors.New("invalid encrypted value")
+	}
+	aead, err := e.decryptionKey(keyName, rest[2:2+ciphertextLength])
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[2+ciphertextLength:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted value")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(topic))
+	if err != nil {
+		return nil, errors.Wrapf(err, "value decryption with key %s failed", keyName)
+	}
+	return plaintext, nil
+}
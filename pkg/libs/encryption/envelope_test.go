@@ -0,0 +1,188 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLocalKeyProvider(t *testing.T) *LocalKeyProvider {
+	dir, err := ioutil.TempDir("", "encryption")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "master.key")
+	assert.Nil(t, ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))+"\n"), 0600))
+	provider, err := NewLocalKeyProvider(keyFile)
+	assert.Nil(t, err)
+	return provider
+}
+
+type countingKeyProvider struct {
+	KeyProvider
+	generated int
+	decrypted int
+}
+
+func (p *countingKeyProvider) GenerateDataKey(ctx context.Context, keyName string) ([]byte, []byte, error) {
+	p.generated++
+	return p.KeyProvider.GenerateDataKey(ctx, keyName)
+}
+
+func (p *countingKeyProvider) DecryptDataKey(ctx context.Context, keyName string, ciphertext []byte) ([]byte, error) {
+	p.decrypted++
+	return p.KeyProvider.DecryptDataKey(ctx, keyName, ciphertext)
+}
+
+func TestEnvelope(t *testing.T) {
+	a := assert.New(t)
+
+	provider := &countingKeyProvider{KeyProvider: newTestLocalKeyProvider(t)}
+	envelope := NewEnvelope(provider, time.Hour, time.Second)
+
+	encrypted, err := envelope.Encrypt("orders-key", "orders", []byte("secret"))
+	a.Nil(err)
+	a.True(IsEncrypted(encrypted))
+	a.NotContains(string(encrypted), "secret")
+
+	decrypted, err := envelope.Decrypt("orders-key", "orders", encrypted)
+	a.Nil(err)
+	a.Equal("secret", string(decrypted))
+
+	// the data key is reused and cached
+	encrypted2, err := envelope.Encrypt("orders-key", "orders", []byte("secret"))
+	a.Nil(err)
+	a.NotEqual(encrypted, encrypted2)
+	_, err = envelope.Decrypt("orders-key", "orders", encrypted2)
+	a.Nil(err)
+	a.Equal(1, provider.generated)
+	a.Equal(1, provider.decrypted)
+
+	// the topic is authenticated
+	_, err = envelope.Decrypt("orders-key", "payments", encrypted)
+	a.NotNil(err)
+
+	// a new envelope decrypts with the data key stored in the value
+	decrypted, err = NewEnvelope(provider, time.Hour, time.Second).Decrypt("orders-key", "orders", encrypted)
+	a.Nil(err)
+	a.Equal("secret", string(decrypted))
+
+	// plaintext values are returned unchanged
+	decrypted, err = envelope.Decrypt("orders-key", "orders", []byte("plain"))
+	a.Nil(err)
+	a.Equal("plain", string(decrypted))
+
+	// the key name is not taken from the value
+	_, err = envelope.Decrypt("payments-key", "orders", encrypted)
+	a.NotNil(err)
+
+	// truncated
+	_, err = envelope.Decrypt("orders-key", "orders", encrypted[:len(envelopeHeader)+3])
+	a.NotNil(err)
+}
+
+func TestEnvelopeDataKeyRotation(t *testing.T) {
+	a := assert.New(t)
+
+	provider := &countingKeyProvider{KeyProvider: newTestLocalKeyProvider(t)}
+	envelope := NewEnvelope(provider, time.Minute, time.Second)
+	now := time.Now()
+	envelope.now = func() time.Time { return now }
+
+	_, err := envelope.Encrypt("orders-key", "orders", []byte("a"))
+	a.Nil(err)
+	now = now.Add(59 * time.Second)
+	_, err = envelope.Encrypt("orders-key", "orders", []byte("b"))
+	a.Nil(err)
+	a.Equal(1, provider.generated)
+
+	now = now.Add(time.Second)
+	_, err = envelope.Encrypt("orders-key", "orders", []byte("c"))
+	a.Nil(err)
+	a.Equal(2, provider.generated)
+
+	_, err = envelope.Encrypt("payments-key", "payments", []byte("d"))
+	a.Nil(err)
+	a.Equal(3, provider.generated)
+}
+
+func TestLocalKeyProvider(t *testing.T) {
+	a := assert.New(t)
+
+	provider := newTestLocalKeyProvider(t)
+	plaintext, ciphertext, err := provider.GenerateDataKey(context.Background(), "orders-key")
+	a.Nil(err)
+	a.Len(plaintext, dataKeyLength)
+
+	decrypted, err := provider.DecryptDataKey(context.Background(), "orders-key", ciphertext)
+	a.Nil(err)
+	a.Equal(plaintext, decrypted)
+
+	_, err = provider.DecryptDataKey(context.Background(), "payments-key", ciphertext)
+	a.NotNil(err)
+
+	_, err = NewLocalKeyProvider("/nonexistent/master.key")
+	a.NotNil(err)
+}
+
+func TestVaultKeyProvider(t *testing.T) {
+	a := assert.New(t)
+
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		var body map[string]interface{}
+		a.Nil(json.NewDecoder(r.Body).Decode(&body))
+		switch r.URL.Path {
+		case "/v1/transit/datakey/plaintext/orders-key":
+			a.Equal(float64(256), body["bits"])
+			_, _ = w.Write([]byte(`{"data":{"plaintext":"` + base64.StdEncoding.EncodeToString(dataKey) + `","ciphertext":"vault:v1:abc"}}`))
+		case "/v1/transit/decrypt/orders-key":
+			a.Equal("vault:v1:abc", body["ciphertext"])
+			_, _ = w.Write([]byte(`{"data":{"plaintext":"` + base64.StdEncoding.EncodeToString(dataKey) + `"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "encryption")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	a.Nil(ioutil.WriteFile(tokenFile, []byte("s.token\n"), 0600))
+
+	provider, err := NewVaultKeyProvider(server.URL+"/", tokenFile, "transit")
+	a.Nil(err)
+
+	plaintext, ciphertext, err := provider.GenerateDataKey(context.Background(), "orders-key")
+	a.Nil(err)
+	a.Equal(dataKey, plaintext)
+	a.Equal("vault:v1:abc", string(ciphertext))
+
+	decrypted, err := provider.DecryptDataKey(context.Background(), "orders-key", ciphertext)
+	a.Nil(err)
+	a.Equal(dataKey, decrypted)
+
+	_, _, err = provider.GenerateDataKey(context.Background(), "unknown-key")
+	a.EqualError(err, "vault transit/datakey/plaintext/unknown-key failed with status 404: ")
+
+	// rotated token
+	a.Nil(ioutil.WriteFile(tokenFile, []byte("s.expired"), 0600))
+	_, err = provider.DecryptDataKey(context.Background(), "orders-key", ciphertext)
+	a.EqualError(err, "vault transit/decrypt/orders-key failed with status 403: permission denied")
+}
@@ -0,0 +1,78 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const dataKeyLength = 32
+
+// KeyProvider generates data keys encrypted by the named key encryption key and decrypts them
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key and the data key encrypted by the key encryption key
+	GenerateDataKey(ctx context.Context, keyName string) (plaintext []byte, ciphertext []byte, err error)
+	// DecryptDataKey returns the data key encrypted by the key encryption key
+	DecryptDataKey(ctx context.Context, keyName string, ciphertext []byte) ([]byte, error)
+}
+
+// LocalKeyProvider encrypts the data keys with a master key read from a file.
+// The key name is authenticated as additional data, so a data key can only be decrypted with the key name it was generated for.
+type LocalKeyProvider struct {
+	masterKey cipher.AEAD
+}
+
+// NewLocalKeyProvider reads the base64 encoded 256 bit master key from the file
+func NewLocalKeyProvider(keyFile string) (*LocalKeyProvider, error) {
+	content, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read master key")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, errors.Wrap(err, "master key must be base64 encoded")
+	}
+	if len(key) != dataKeyLength {
+		return nil, errors.Errorf("master key must be %d bytes long", dataKeyLength)
+	}
+	masterKey, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKeyProvider{masterKey: masterKey}, nil
+}
+
+func (p *LocalKeyProvider) GenerateDataKey(_ context.Context, keyName string) ([]byte, []byte, error) {
+	plaintext := make([]byte, dataKeyLength)
+	if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, p.masterKey.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	return plaintext, p.masterKey.Seal(nonce, nonce, plaintext, []byte(keyName)), nil
+}
+
+func (p *LocalKeyProvider) DecryptDataKey(_ context.Context, keyName string, ciphertext []byte) ([]byte, error) {
+	nonceSize := p.masterKey.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("invalid encrypted data key")
+	}
+	return p.masterKey.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], []byte(keyName))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
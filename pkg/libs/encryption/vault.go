@@ -0,0 +1,69 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grepplabs/kafka-proxy/pkg/libs/vault"
+	"github.com/pkg/errors"
+)
+
+// VaultKeyProvider uses the HashiCorp Vault transit secrets engine to generate and decrypt the data keys
+type VaultKeyProvider struct {
+	mount  string
+	client *vault.Client
+}
+
+func NewVaultKeyProvider(address string, tokenFile string, mount string) (*VaultKeyProvider, error) {
+	client, err := vault.NewClient(address, tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	return &VaultKeyProvider{
+		mount:  strings.Trim(mount, "/"),
+		client: client,
+	}, nil
+}
+
+type vaultDataKey struct {
+	Plaintext  string `json:"plaintext"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+func (p *VaultKeyProvider) GenerateDataKey(ctx context.Context, keyName string) ([]byte, []byte, error) {
+	resp, err := p.post(ctx, "datakey/plaintext/"+url.PathEscape(keyName), map[string]interface{}{"bits": dataKeyLength * 8})
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid vault data key")
+	}
+	if len(plaintext) != dataKeyLength || resp.Ciphertext == "" {
+		return nil, nil, errors.New("invalid vault data key")
+	}
+	return plaintext, []byte(resp.Ciphertext), nil
+}
+
+func (p *VaultKeyProvider) DecryptDataKey(ctx context.Context, keyName string, ciphertext []byte) ([]byte, error) {
+	resp, err := p.post(ctx, "decrypt/"+url.PathEscape(keyName), map[string]interface{}{"ciphertext": string(ciphertext)})
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid vault data key")
+	}
+	return plaintext, nil
+}
+
+func (p *VaultKeyProvider) post(ctx context.Context, path string, body interface{}) (*vaultDataKey, error) {
+	resp := &vaultDataKey{}
+	if err := p.client.Do(ctx, http.MethodPost, p.mount+"/"+path, body, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
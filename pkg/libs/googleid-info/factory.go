@@ -3,6 +3,7 @@ package googleidinfo
 import (
 	"flag"
 	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/googleid"
 	"github.com/grepplabs/kafka-proxy/pkg/libs/util"
 	"github.com/grepplabs/kafka-proxy/pkg/registry"
 )
@@ -22,6 +23,10 @@ type pluginMeta struct {
 	certsRefreshInterval int
 	audience             util.ArrayFlags
 	emailsRegex          util.ArrayFlags
+	clientIDs            util.ArrayFlags
+	serviceAccountIDs    util.ArrayFlags
+	jwksURL              string
+	certsMinRefresh      int
 }
 
 type Factory struct {
@@ -35,14 +40,22 @@ func (t *Factory) New(params []string) (apis.TokenInfo, error) {
 	fs.IntVar(&pluginMeta.certsRefreshInterval, "certs-refresh-interval", 60*60, "Certificates refresh interval in seconds")
 	fs.Var(&pluginMeta.audience, "audience", "The audience of a token")
 	fs.Var(&pluginMeta.emailsRegex, "email-regex", "Regex of the email claim")
+	fs.Var(&pluginMeta.clientIDs, "client-id", "The allowed authorized party (azp claim) of a token e.g. OAuth client ID or unique ID of an impersonated service account")
+	fs.Var(&pluginMeta.serviceAccountIDs, "service-account-id", "The allowed subject (unique ID) of a service account, accepts impersonated tokens without the email claim")
+	fs.StringVar(&pluginMeta.jwksURL, "jwks-url", googleid.DefaultJWKSURL, "URL of the JWKS with the public keys")
+	fs.IntVar(&pluginMeta.certsMinRefresh, "certs-min-refresh-interval", 60, "Minimum interval in seconds between refreshes of certificates for tokens signed by unknown keys")
 
 	fs.Parse(params)
 
 	opts := TokenInfoOptions{
-		Timeout:              pluginMeta.timeout,
-		CertsRefreshInterval: pluginMeta.certsRefreshInterval,
-		Audience:             pluginMeta.audience,
-		EmailsRegex:          pluginMeta.emailsRegex,
+		Timeout:                 pluginMeta.timeout,
+		CertsRefreshInterval:    pluginMeta.certsRefreshInterval,
+		Audience:                pluginMeta.audience,
+		EmailsRegex:             pluginMeta.emailsRegex,
+		ClientIDs:               pluginMeta.clientIDs,
+		ServiceAccountIDs:       pluginMeta.serviceAccountIDs,
+		JWKSURL:                 pluginMeta.jwksURL,
+		CertsMinRefreshInterval: pluginMeta.certsMinRefresh,
 	}
 
 	return NewTokenInfo(opts)
@@ -28,6 +28,7 @@ const (
 	StatusTokenExpired            = 9
 	StatusWrongAudience           = 10
 	StatusWrongEmail              = 11
+	StatusWrongClientID           = 12
 )
 
 var (
@@ -45,15 +46,32 @@ type TokenInfoOptions struct {
 	CertsRefreshInterval int
 	Audience             []string
 	EmailsRegex          []string
+	// ClientIDs are the allowed authorized parties (azp), the OAuth client IDs or the unique IDs of impersonated service accounts
+	ClientIDs []string
+	// ServiceAccountIDs are the allowed subjects (unique IDs) of service accounts, tokens minted by impersonation
+	// without the email claim are accepted if their subject is allowed
+	ServiceAccountIDs       []string
+	JWKSURL                 string
+	CertsMinRefreshInterval int
 }
 
 type TokenInfo struct {
-	timeout    time.Duration
-	audience   map[string]struct{}
-	emailRegex []*regexp.Regexp
+	timeout           time.Duration
+	audience          map[string]struct{}
+	emailRegex        []*regexp.Regexp
+	clientIDs         map[string]struct{}
+	serviceAccountIDs map[string]struct{}
 
-	publicKeys map[string]*rsa.PublicKey
-	l          sync.RWMutex
+	jwksURL            string
+	minRefreshInterval time.Duration
+
+	publicKeys  map[string]*rsa.PublicKey
+	certsMaxAge time.Duration
+	l           sync.RWMutex
+
+	// serializes the refreshes of certs for unknown key IDs
+	keyRefreshLock sync.Mutex
+	lastKeyRefresh time.Time
 }
 
 func NewTokenInfo(options TokenInfoOptions) (*TokenInfo, error) {
@@ -67,17 +85,27 @@ func NewTokenInfo(options TokenInfoOptions) (*TokenInfo, error) {
 	}
 	logrus.Infof("JWT target audience: %v", options.Audience)
 	logrus.Infof("JWT emails regexp: %v", emailRegex)
+	logrus.Infof("JWT client IDs: %v", options.ClientIDs)
+	logrus.Infof("JWT service account IDs: %v", options.ServiceAccountIDs)
 
-	if len(emailRegex) == 0 {
-		return nil, errors.New("parameter email (regex) is required")
+	if len(emailRegex) == 0 && len(options.ServiceAccountIDs) == 0 {
+		return nil, errors.New("parameter email (regex) or service account id is required")
 	}
 
-	audience := make(map[string]struct{})
-	for _, elem := range options.Audience {
-		audience[elem] = struct{}{}
+	jwksURL := options.JWKSURL
+	if jwksURL == "" {
+		jwksURL = googleid.DefaultJWKSURL
 	}
 
-	tokenInfo := &TokenInfo{timeout: time.Duration(options.Timeout) * time.Second, audience: audience, emailRegex: emailRegex}
+	tokenInfo := &TokenInfo{
+		timeout:            time.Duration(options.Timeout) * time.Second,
+		audience:           toSet(options.Audience),
+		emailRegex:         emailRegex,
+		clientIDs:          toSet(options.ClientIDs),
+		serviceAccountIDs:  toSet(options.ServiceAccountIDs),
+		jwksURL:            jwksURL,
+		minRefreshInterval: time.Duration(options.CertsMinRefreshInterval) * time.Second,
+	}
 
 	op := func() error {
 		return tokenInfo.refreshCerts()
@@ -91,6 +119,14 @@ func NewTokenInfo(options TokenInfoOptions) (*TokenInfo, error) {
 	return tokenInfo, nil
 }
 
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, elem := range values {
+		set[elem] = struct{}{}
+	}
+	return set
+}
+
 func (p *TokenInfo) getPublicKey(kid string) *rsa.PublicKey {
 	p.l.RLock()
 	defer p.l.RUnlock()
@@ -108,18 +144,59 @@ func (p *TokenInfo) getPublicKeyIDs() []string {
 	return kids
 }
 
-func (p *TokenInfo) setPublicKeys(publicKeys map[string]*rsa.PublicKey) {
+func (p *TokenInfo) setPublicKeys(publicKeys map[string]*rsa.PublicKey, maxAge time.Duration) {
 	p.l.Lock()
 	defer p.l.Unlock()
 
 	p.publicKeys = publicKeys
+	p.certsMaxAge = maxAge
+}
+
+// nextRefresh returns the refresh interval, shortened to the max age of the cached certs but not below the min refresh interval
+func (p *TokenInfo) nextRefresh(interval time.Duration) time.Duration {
+	p.l.RLock()
+	maxAge := p.certsMaxAge
+	p.l.RUnlock()
+
+	if maxAge > 0 && maxAge < interval {
+		interval = maxAge
+	}
+	if interval < p.minRefreshInterval {
+		interval = p.minRefreshInterval
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return interval
+}
+
+// refreshForKeyID refreshes the certs when a token is signed by an unknown key, as Google rotates the keys before the refresh interval
+// elapses. Refreshes are done at most once per min refresh interval.
+func (p *TokenInfo) refreshForKeyID(kid string) *rsa.PublicKey {
+	p.keyRefreshLock.Lock()
+	defer p.keyRefreshLock.Unlock()
+
+	// refreshed by a concurrent request
+	if publicKey := p.getPublicKey(kid); publicKey != nil {
+		return publicKey
+	}
+	now := nowFn()
+	if !p.lastKeyRefresh.IsZero() && now.Sub(p.lastKeyRefresh) < p.minRefreshInterval {
+		return nil
+	}
+	p.lastKeyRefresh = now
+	if err := p.refreshCerts(); err != nil {
+		logrus.Warnf("Refresh of certs for key ID %s failed: %v", kid, err)
+		return nil
+	}
+	return p.getPublicKey(kid)
 }
 
 func (p *TokenInfo) refreshCerts() error {
 	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
 	defer cancel()
 
-	certs, err := googleid.GetCerts(ctx)
+	certs, maxAge, err := googleid.GetCertsFromURL(ctx, p.jwksURL)
 	if err != nil {
 		return err
 	}
@@ -136,7 +213,7 @@ func (p *TokenInfo) refreshCerts() error {
 		publicKeys[key.Kid] = publicKey
 	}
 
-	p.setPublicKeys(publicKeys)
+	p.setPublicKeys(publicKeys, maxAge)
 
 	return nil
 }
@@ -178,11 +255,17 @@ func (p *TokenInfo) VerifyToken(parent context.Context, request apis.VerifyReque
 			return getVerifyResponseResponse(StatusWrongAudience)
 		}
 	}
-	if !p.checkEmail(token.ClaimSet.Email) {
+	if !p.checkClientID(token.ClaimSet) {
+		return getVerifyResponseResponse(StatusWrongClientID)
+	}
+	if !p.checkEmail(token.ClaimSet.Email) && !p.checkServiceAccountID(token.ClaimSet.Sub) {
 		return getVerifyResponseResponse(StatusWrongEmail)
 	}
 
 	publicKey := p.getPublicKey(token.Header.KeyID)
+	if publicKey == nil {
+		publicKey = p.refreshForKeyID(token.Header.KeyID)
+	}
 	if publicKey == nil {
 		return getVerifyResponseResponse(StatusPublicKeyNotFound)
 	}
@@ -190,7 +273,39 @@ func (p *TokenInfo) VerifyToken(parent context.Context, request apis.VerifyReque
 	if err != nil {
 		return getVerifyResponseResponse(StatusWrongSignature)
 	}
-	return apis.VerifyResponse{Success: true}, nil
+	return apis.VerifyResponse{Success: true, Subject: token.ClaimSet.Sub, Claims: verifiedClaims(token.ClaimSet)}, nil
+}
+
+// verifiedClaims returns the non empty claims which can be used as principal
+func verifiedClaims(claimSet *googleid.ClaimSet) map[string]string {
+	claims := make(map[string]string)
+	for name, value := range map[string]string{"iss": claimSet.Iss, "aud": claimSet.Aud, "azp": claimSet.Azp, "email": claimSet.Email} {
+		if value != "" {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+// checkClientID checks the authorized party, which is the audience if the token has a single audience
+func (p *TokenInfo) checkClientID(claimSet *googleid.ClaimSet) bool {
+	if len(p.clientIDs) == 0 {
+		return true
+	}
+	azp := claimSet.Azp
+	if azp == "" {
+		azp = claimSet.Aud
+	}
+	_, ok := p.clientIDs[azp]
+	return ok
+}
+
+func (p *TokenInfo) checkServiceAccountID(sub string) bool {
+	if sub == "" {
+		return false
+	}
+	_, ok := p.serviceAccountIDs[sub]
+	return ok
 }
 
 func (p *TokenInfo) checkEmail(email string) bool {
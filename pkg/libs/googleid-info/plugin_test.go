@@ -0,0 +1,167 @@
+package googleidinfo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/googleid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2/jws"
+)
+
+type testJWKS struct {
+	sync.Mutex
+	keys     map[string]*rsa.PrivateKey
+	requests int
+}
+
+func newTestJWKS(t *testing.T, kids ...string) *testJWKS {
+	jwks := &testJWKS{keys: make(map[string]*rsa.PrivateKey)}
+	for _, kid := range kids {
+		jwks.addKey(t, kid)
+	}
+	return jwks
+}
+
+func (j *testJWKS) addKey(t *testing.T, kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Lock()
+	defer j.Unlock()
+	j.keys[kid] = key
+}
+
+func (j *testJWKS) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	j.Lock()
+	defer j.Unlock()
+	j.requests++
+	certs := googleid.Certs{}
+	for kid, key := range j.keys {
+		certs.Keys = append(certs.Keys, googleid.Keys{
+			Kty: "RSA",
+			Alg: "RS256",
+			Use: "sig",
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	w.Header().Set("Cache-Control", "public, max-age=120")
+	_ = json.NewEncoder(w).Encode(certs)
+}
+
+func (j *testJWKS) token(t *testing.T, kid string, aud string, claims map[string]interface{}) string {
+	j.Lock()
+	key := j.keys[kid]
+	j.Unlock()
+	now := time.Now().Unix()
+	token, err := jws.Encode(&jws.Header{Algorithm: "RS256", Typ: "JWT", KeyID: kid},
+		&jws.ClaimSet{Iss: "https://accounts.google.com", Aud: aud, Iat: now, Exp: now + 3600, Sub: "112233", PrivateClaims: claims}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func verifyStatus(t *testing.T, tokenInfo *TokenInfo, token string) int32 {
+	resp, err := tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Status
+}
+
+func TestVerifyTokenAudiencesAndClientIDs(t *testing.T) {
+	a := assert.New(t)
+
+	jwks := newTestJWKS(t, "k1")
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	tokenInfo, err := NewTokenInfo(TokenInfoOptions{
+		Timeout:              5,
+		CertsRefreshInterval: 3600,
+		Audience:             []string{"tcp://kafka.dev.example.com", "tcp://kafka-batch.dev.example.com"},
+		EmailsRegex:          []string{"^.*@my-project.iam.gserviceaccount.com$"},
+		ClientIDs:            []string{"112233", "client-1.apps.googleusercontent.com"},
+		JWKSURL:              server.URL,
+	})
+	a.Nil(err)
+	a.Equal(120*time.Second, tokenInfo.nextRefresh(time.Hour))
+
+	email := "app@my-project.iam.gserviceaccount.com"
+	a.EqualValues(StatusOK, verifyStatus(t, tokenInfo, jwks.token(t, "k1", "tcp://kafka.dev.example.com", map[string]interface{}{"azp": "112233", "email": email})))
+	a.EqualValues(StatusOK, verifyStatus(t, tokenInfo, jwks.token(t, "k1", "tcp://kafka-batch.dev.example.com", map[string]interface{}{"azp": "client-1.apps.googleusercontent.com", "email": email})))
+	a.EqualValues(StatusWrongAudience, verifyStatus(t, tokenInfo, jwks.token(t, "k1", "tcp://kafka.prod.example.com", map[string]interface{}{"azp": "112233", "email": email})))
+	a.EqualValues(StatusWrongClientID, verifyStatus(t, tokenInfo, jwks.token(t, "k1", "tcp://kafka.dev.example.com", map[string]interface{}{"azp": "445566", "email": email})))
+	a.EqualValues(StatusWrongClientID, verifyStatus(t, tokenInfo, jwks.token(t, "k1", "tcp://kafka.dev.example.com", map[string]interface{}{"email": email})))
+	a.EqualValues(StatusWrongEmail, verifyStatus(t, tokenInfo, jwks.token(t, "k1", "tcp://kafka.dev.example.com", map[string]interface{}{"azp": "112233", "email": "app@other-project.iam.gserviceaccount.com"})))
+}
+
+func TestVerifyImpersonatedTokenWithoutEmail(t *testing.T) {
+	a := assert.New(t)
+
+	jwks := newTestJWKS(t, "k1")
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	tokenInfo, err := NewTokenInfo(TokenInfoOptions{
+		Timeout:              5,
+		CertsRefreshInterval: 3600,
+		Audience:             []string{"tcp://kafka.dev.example.com"},
+		ServiceAccountIDs:    []string{"112233"},
+		JWKSURL:              server.URL,
+	})
+	a.Nil(err)
+
+	// tokens minted by generateIdToken without includeEmail have the unique ID of the service account as subject
+	a.EqualValues(StatusOK, verifyStatus(t, tokenInfo, jwks.token(t, "k1", "tcp://kafka.dev.example.com", map[string]interface{}{"azp": "112233"})))
+	a.EqualValues(StatusWrongEmail, verifyStatus(t, tokenInfo, jwks.token(t, "k1", "tcp://kafka.dev.example.com", map[string]interface{}{"sub": "445566"})))
+
+	_, err = NewTokenInfo(TokenInfoOptions{Timeout: 5, CertsRefreshInterval: 3600, JWKSURL: server.URL})
+	a.NotNil(err)
+}
+
+func TestVerifyTokenRefreshesCertsForUnknownKey(t *testing.T) {
+	a := assert.New(t)
+
+	jwks := newTestJWKS(t, "k1")
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	tokenInfo, err := NewTokenInfo(TokenInfoOptions{
+		Timeout:                 5,
+		CertsRefreshInterval:    3600,
+		EmailsRegex:             []string{"^app@my-project.iam.gserviceaccount.com$"},
+		JWKSURL:                 server.URL,
+		CertsMinRefreshInterval: 60,
+	})
+	a.Nil(err)
+	claims := map[string]interface{}{"email": "app@my-project.iam.gserviceaccount.com"}
+
+	// rotated key is fetched on demand
+	jwks.addKey(t, "k2")
+	a.EqualValues(StatusOK, verifyStatus(t, tokenInfo, jwks.token(t, "k2", "aud", claims)))
+	a.Equal(2, jwks.requests)
+
+	// refreshes for unknown keys are rate limited
+	jwks.addKey(t, "k3")
+	a.EqualValues(StatusPublicKeyNotFound, verifyStatus(t, tokenInfo, jwks.token(t, "k3", "aud", claims)))
+	a.Equal(2, jwks.requests)
+
+	// the known keys are served from the cache
+	a.EqualValues(StatusOK, verifyStatus(t, tokenInfo, jwks.token(t, "k1", "aud", claims)))
+	a.Equal(2, jwks.requests)
+}
@@ -31,12 +31,14 @@ func (p *certsRefresher) refreshLoop() {
 			}
 		}
 	}()
-	logrus.Infof("Refreshing certs every: %v", p.interval)
-	syncTicker := time.NewTicker(p.interval)
+	logrus.Infof("Refreshing certs every: %v or by max age of the certs", p.interval)
+	syncTimer := time.NewTimer(p.tokenInfo.nextRefresh(p.interval))
+	defer syncTimer.Stop()
 	for {
 		select {
-		case <-syncTicker.C:
+		case <-syncTimer.C:
 			p.refreshTick()
+			syncTimer.Reset(p.tokenInfo.nextRefresh(p.interval))
 		case <-p.stopChannel:
 			return
 		}
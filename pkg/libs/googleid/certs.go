@@ -10,12 +10,15 @@ import (
 	"io/ioutil"
 	"math/big"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
+	// DefaultJWKSURL is the JWKS endpoint of the keys signing Google ID tokens
 	// https://accounts.google.com/.well-known/openid-configuration
-	jwksUri = "https://www.googleapis.com/oauth2/v3/certs"
+	DefaultJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
 )
 
 type Certs struct {
@@ -50,25 +53,53 @@ func (key *Keys) GetPublicKey() (*rsa.PublicKey, error) {
 }
 
 func GetCerts(ctx context.Context) (*Certs, error) {
+	certs, _, err := GetCertsFromURL(ctx, DefaultJWKSURL)
+	return certs, err
+}
+
+// GetCertsFromURL fetches the certs from the JWKS url and returns how long they can be cached according to the Cache-Control max-age
+// of the response. The max age is zero if the response does not allow caching.
+func GetCertsFromURL(ctx context.Context, uri string) (*Certs, time.Duration, error) {
 	client := &http.Client{
 		Timeout: time.Second * 10,
 	}
-	resp, err := ctxhttp.Get(ctx, client, jwksUri)
+	resp, err := ctxhttp.Get(ctx, client, uri)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	if c := resp.StatusCode; c < 200 || c > 299 {
-		return nil, fmt.Errorf("cannot fetch certs: %v\nResponse: %s", resp.Status, body)
+		return nil, 0, fmt.Errorf("cannot fetch certs: %v\nResponse: %s", resp.Status, body)
 	}
 	var certs *Certs
 	if err = json.Unmarshal(body, &certs); err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	if certs == nil {
+		return nil, 0, fmt.Errorf("cannot fetch certs: empty response")
+	}
+	return certs, cacheMaxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+func cacheMaxAge(cacheControl string) time.Duration {
+	maxAge := time.Duration(0)
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.ParseInt(strings.TrimPrefix(directive, "max-age="), 10, 64)
+			if err != nil || seconds < 0 {
+				return 0
+			}
+			maxAge = time.Duration(seconds) * time.Second
+		}
 	}
-	return certs, nil
+	return maxAge
 }
@@ -30,3 +30,11 @@ func TestGetCerts(t *testing.T) {
 		a.NotNil(pk)
 	}
 }
+
+func TestCacheMaxAge(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(19845*time.Second, cacheMaxAge("public, max-age=19845, must-revalidate, no-transform"))
+	a.Equal(time.Duration(0), cacheMaxAge(""))
+	a.Equal(time.Duration(0), cacheMaxAge("no-store, max-age=60"))
+	a.Equal(time.Duration(0), cacheMaxAge("max-age=abc"))
+}
@@ -0,0 +1,123 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// KeySet is a JSON Web Key Set https://tools.ietf.org/html/rfc7517#section-5
+type KeySet struct {
+	Keys []Key `json:"keys"`
+}
+
+// Key is a public JSON Web Key of type RSA, EC or OKP https://tools.ietf.org/html/rfc7518#section-6
+type Key struct {
+	KeyID     string   `json:"kid"`
+	KeyType   string   `json:"kty"`
+	Algorithm string   `json:"alg"`
+	Use       string   `json:"use"`
+	N         string   `json:"n"`
+	E         string   `json:"e"`
+	Curve     string   `json:"crv"`
+	X         string   `json:"x"`
+	Y         string   `json:"y"`
+	X509Cert  []string `json:"x5c"`
+}
+
+// PublicKey returns the *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey of the key.
+// RSA and EC keys without parameters are read from the first X.509 certificate.
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		if k.N == "" && k.E == "" {
+			return k.certificatePublicKey()
+		}
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid modulus of key %s", k.KeyID)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid exponent of key %s", k.KeyID)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.Errorf("invalid exponent of key %s", k.KeyID)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.X == "" && k.Y == "" {
+			return k.certificatePublicKey()
+		}
+		curve, ok := curves[k.Curve]
+		if !ok {
+			return nil, errors.Errorf("unsupported curve %s of key %s", k.Curve, k.KeyID)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid x coordinate of key %s", k.KeyID)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid y coordinate of key %s", k.KeyID)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.Errorf("point of key %s is not on curve %s", k.KeyID, k.Curve)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, errors.Errorf("unsupported curve %s of key %s", k.Curve, k.KeyID)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid public key of key %s", k.KeyID)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("invalid public key size %d of key %s", len(x), k.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.Errorf("unsupported key type %s of key %s", k.KeyType, k.KeyID)
+	}
+}
+
+func (k Key) certificatePublicKey() (crypto.PublicKey, error) {
+	if len(k.X509Cert) == 0 {
+		return nil, errors.Errorf("key %s has neither parameters nor X.509 certificates", k.KeyID)
+	}
+	// x5c entries are base64 (not base64url) encoded DER certificates
+	der, err := base64.StdEncoding.DecodeString(k.X509Cert[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid X.509 certificate of key %s", k.KeyID)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid X.509 certificate of key %s", k.KeyID)
+	}
+	return cert.PublicKey, nil
+}
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
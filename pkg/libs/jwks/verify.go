@@ -0,0 +1,153 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const AlgorithmNone = "none"
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signature algorithm")
+	ErrNoKey                = errors.New("no verification key found")
+	ErrInvalidSignature     = errors.New("invalid signature")
+)
+
+type verifyFunc func(key crypto.PublicKey, hash crypto.Hash, signingInput []byte, signature []byte) bool
+
+type algorithm struct {
+	keyType string
+	hash    crypto.Hash
+	verify  verifyFunc
+}
+
+// supported asymmetric JWS algorithms https://tools.ietf.org/html/rfc7518#section-3.1 and https://tools.ietf.org/html/rfc8037#section-3.1
+var algorithms = map[string]algorithm{
+	"RS256": {keyType: "RSA", hash: crypto.SHA256, verify: verifyPKCS1v15},
+	"RS384": {keyType: "RSA", hash: crypto.SHA384, verify: verifyPKCS1v15},
+	"RS512": {keyType: "RSA", hash: crypto.SHA512, verify: verifyPKCS1v15},
+	"PS256": {keyType: "RSA", hash: crypto.SHA256, verify: verifyPSS},
+	"PS384": {keyType: "RSA", hash: crypto.SHA384, verify: verifyPSS},
+	"PS512": {keyType: "RSA", hash: crypto.SHA512, verify: verifyPSS},
+	"ES256": {keyType: "EC", hash: crypto.SHA256, verify: verifyECDSA("P-256")},
+	"ES384": {keyType: "EC", hash: crypto.SHA384, verify: verifyECDSA("P-384")},
+	"ES512": {keyType: "EC", hash: crypto.SHA512, verify: verifyECDSA("P-521")},
+	"EdDSA": {keyType: "OKP", verify: verifyEd25519},
+}
+
+// IsSupportedAlgorithm returns true when signatures of the algorithm can be verified
+func IsSupportedAlgorithm(name string) bool {
+	_, ok := algorithms[name]
+	return ok
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Verify verifies the signature of the compact serialized JWS with a key of the key set. The key is selected by the kid header,
+// tokens without kid are verified with the keys matching the algorithm. Keys which cannot be parsed are skipped.
+// Unsigned tokens (algorithm none) are never verified.
+func Verify(token string, keySet *KeySet) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("jws: invalid token received")
+	}
+	decodedHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return err
+	}
+	h := &header{}
+	if err = json.Unmarshal(decodedHeader, h); err != nil {
+		return err
+	}
+	alg, ok := algorithms[h.Algorithm]
+	if !ok {
+		return errors.Wrapf(ErrUnsupportedAlgorithm, "algorithm '%s'", h.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+
+	var (
+		found  bool
+		keyErr error
+	)
+	for _, key := range keySet.Keys {
+		if h.KeyID != "" && key.KeyID != h.KeyID {
+			continue
+		}
+		if key.KeyType != alg.keyType || (key.Algorithm != "" && key.Algorithm != h.Algorithm) || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		publicKey, err := key.PublicKey()
+		if err != nil {
+			// e.g. a key of an unsupported curve published next to the signing keys
+			keyErr = err
+			continue
+		}
+		found = true
+		if alg.verify(publicKey, alg.hash, signingInput, signature) {
+			return nil
+		}
+	}
+	if !found {
+		if keyErr != nil {
+			return errors.Wrapf(ErrNoKey, "algorithm %s kid '%s', invalid key: %v", h.Algorithm, h.KeyID, keyErr)
+		}
+		return errors.Wrapf(ErrNoKey, "algorithm %s kid '%s'", h.Algorithm, h.KeyID)
+	}
+	return ErrInvalidSignature
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	hasher := hash.New()
+	_, _ = hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+func verifyPKCS1v15(key crypto.PublicKey, hash crypto.Hash, signingInput []byte, signature []byte) bool {
+	rsaKey, ok := key.(*rsa.PublicKey)
+	return ok && rsa.VerifyPKCS1v15(rsaKey, hash, digest(hash, signingInput), signature) == nil
+}
+
+func verifyPSS(key crypto.PublicKey, hash crypto.Hash, signingInput []byte, signature []byte) bool {
+	rsaKey, ok := key.(*rsa.PublicKey)
+	// the salt length equals the hash length https://tools.ietf.org/html/rfc7518#section-3.5
+	return ok && rsa.VerifyPSS(rsaKey, hash, digest(hash, signingInput), signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+}
+
+// verifyECDSA verifies the signature R || S with the key on the curve of the algorithm https://tools.ietf.org/html/rfc7518#section-3.4
+func verifyECDSA(curve string) verifyFunc {
+	return func(key crypto.PublicKey, hash crypto.Hash, signingInput []byte, signature []byte) bool {
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve.Params().Name != curve {
+			return false
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(ecKey, digest(hash, signingInput), r, s)
+	}
+}
+
+func verifyEd25519(key crypto.PublicKey, _ crypto.Hash, signingInput []byte, signature []byte) bool {
+	edKey, ok := key.(ed25519.PublicKey)
+	return ok && ed25519.Verify(edKey, signingInput, signature)
+}
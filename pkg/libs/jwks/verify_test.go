@@ -0,0 +1,171 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func signToken(t *testing.T, alg string, kid string, key crypto.Signer) string {
+	signingInput := encode([]byte(`{"alg":"`+alg+`","kid":"`+kid+`"}`)) + "." + encode([]byte(`{"sub":"alice"}`))
+	var signature []byte
+	var err error
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signingInput))
+	case *ecdsa.PrivateKey:
+		hash := algorithms[alg].hash
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest(hash, []byte(signingInput)))
+		require.NoError(t, err)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(signature[size-len(rb):size], rb)
+		copy(signature[2*size-len(sb):], sb)
+	case *rsa.PrivateKey:
+		hash := algorithms[alg].hash
+		if alg[:2] == "PS" {
+			signature, err = rsa.SignPSS(rand.Reader, k, hash, digest(hash, []byte(signingInput)), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest(hash, []byte(signingInput)))
+		}
+		require.NoError(t, err)
+	}
+	return signingInput + "." + encode(signature)
+}
+
+func rsaKey(kid string, key *rsa.PrivateKey) Key {
+	return Key{KeyID: kid, KeyType: "RSA", N: encode(key.N.Bytes()), E: encode(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecKey(kid string, curve string, key *ecdsa.PrivateKey) Key {
+	return Key{KeyID: kid, KeyType: "EC", Curve: curve, X: encode(key.X.Bytes()), Y: encode(key.Y.Bytes())}
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keySet := &KeySet{Keys: []Key{
+		rsaKey("rsa", rsaPrivate),
+		ecKey("p256", "P-256", p256),
+		ecKey("p384", "P-384", p384),
+		ecKey("p521", "P-521", p521),
+		{KeyID: "ed", KeyType: "OKP", Curve: "Ed25519", X: encode(edPublic)},
+	}}
+	tests := []struct {
+		alg string
+		kid string
+		key crypto.Signer
+	}{
+		{"RS256", "rsa", rsaPrivate},
+		{"RS384", "rsa", rsaPrivate},
+		{"RS512", "rsa", rsaPrivate},
+		{"PS256", "rsa", rsaPrivate},
+		{"PS384", "rsa", rsaPrivate},
+		{"PS512", "rsa", rsaPrivate},
+		{"ES256", "p256", p256},
+		{"ES384", "p384", p384},
+		{"ES512", "p521", p521},
+		{"EdDSA", "ed", edPrivate},
+		// without kid the keys matching the algorithm are tried
+		{"ES384", "", p384},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg+"/"+tt.kid, func(t *testing.T) {
+			token := signToken(t, tt.alg, tt.kid, tt.key)
+			assert.NoError(t, Verify(token, keySet))
+
+			tampered := token[:len(token)-4] + "AAAA"
+			assert.Error(t, Verify(tampered, keySet))
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keySet := &KeySet{Keys: []Key{ecKey("p256", "P-256", p256)}}
+
+	err = Verify(signToken(t, "ES256", "p256", other), keySet)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	err = Verify(signToken(t, "ES256", "unknown", p256), keySet)
+	assert.Equal(t, ErrNoKey, errors.Cause(err))
+
+	// the curve of the key must match the algorithm
+	err = Verify(signToken(t, "ES384", "p256", p256), keySet)
+	assert.Error(t, err)
+
+	// keys which cannot be parsed are skipped
+	invalid := Key{KeyID: "invalid", KeyType: "EC", Curve: "P-192", X: encode([]byte{1}), Y: encode([]byte{2})}
+	withInvalid := &KeySet{Keys: []Key{invalid, ecKey("p256", "P-256", p256)}}
+	assert.NoError(t, Verify(signToken(t, "ES256", "", p256), withInvalid))
+	err = Verify(signToken(t, "ES256", "invalid", p256), withInvalid)
+	assert.Equal(t, ErrNoKey, errors.Cause(err))
+
+	unsigned := encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(`{"sub":"alice"}`)) + "."
+	err = Verify(unsigned, keySet)
+	assert.Equal(t, ErrUnsupportedAlgorithm, errors.Cause(err))
+
+	hmac := encode([]byte(`{"alg":"HS256"}`)) + "." + encode([]byte(`{"sub":"alice"}`)) + "." + encode([]byte("mac"))
+	err = Verify(hmac, keySet)
+	assert.Equal(t, ErrUnsupportedAlgorithm, errors.Cause(err))
+}
+
+func TestPublicKeyFromCertificate(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "issuer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &private.PublicKey, private)
+	require.NoError(t, err)
+
+	keySet := &KeySet{Keys: []Key{{KeyID: "cert", KeyType: "EC", X509Cert: []string{base64.StdEncoding.EncodeToString(der)}}}}
+	assert.NoError(t, Verify(signToken(t, "ES256", "cert", private), keySet))
+}
+
+func TestPublicKeyErrors(t *testing.T) {
+	_, err := Key{KeyID: "k", KeyType: "EC", Curve: "P-256", X: encode([]byte{1}), Y: encode([]byte{2})}.PublicKey()
+	assert.EqualError(t, err, "point of key k is not on curve P-256")
+
+	_, err = Key{KeyID: "k", KeyType: "OKP", Curve: "X25519", X: encode(make([]byte, 32))}.PublicKey()
+	assert.EqualError(t, err, "unsupported curve X25519 of key k")
+
+	_, err = Key{KeyID: "k", KeyType: "oct"}.PublicKey()
+	assert.EqualError(t, err, "unsupported key type oct of key k")
+
+	_, err = Key{KeyID: "k", KeyType: "RSA"}.PublicKey()
+	assert.EqualError(t, err, "key k has neither parameters nor X.509 certificates")
+}
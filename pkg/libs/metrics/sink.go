@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+// Sink receives metric families gathered from the prometheus registry
+type Sink interface {
+	Emit(families []*dto.MetricFamily) error
+	Close() error
+}
+
+// Exporter periodically pushes metrics from the prometheus registry to the sink
+type Exporter struct {
+	gatherer      prometheus.Gatherer
+	sink          Sink
+	namePrefix    string
+	flushInterval time.Duration
+
+	stop chan struct{}
+}
+
+func NewExporter(gatherer prometheus.Gatherer, sink Sink, namePrefix string, flushInterval time.Duration) *Exporter {
+	return &Exporter{
+		gatherer:      gatherer,
+		sink:          sink,
+		namePrefix:    namePrefix,
+		flushInterval: flushInterval,
+		stop:          make(chan struct{}),
+	}
+}
+
+// Run flushes metrics until Close is called
+func (e *Exporter) Run() error {
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Flush(); err != nil {
+				logrus.Warnf("metrics flush failed: %v", err)
+			}
+		case <-e.stop:
+			if err := e.Flush(); err != nil {
+				logrus.Warnf("metrics flush failed: %v", err)
+			}
+			return e.sink.Close()
+		}
+	}
+}
+
+func (e *Exporter) Close() {
+	close(e.stop)
+}
+
+func (e *Exporter) Flush() error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	filtered := make([]*dto.MetricFamily, 0, len(families))
+	for _, family := range families {
+		if strings.HasPrefix(family.GetName(), e.namePrefix) {
+			filtered = append(filtered, family)
+		}
+	}
+	return e.sink.Emit(filtered)
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const statsdMaxPacketSize = 1432
+
+var statsdTagReplacer = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
+
+// StatsdSink emits metrics in DogStatsD format with tags. Prometheus counters are sent as deltas since the previous flush
+type StatsdSink struct {
+	conn   net.Conn
+	prefix string
+	tags   []string
+
+	lastValues map[string]float64
+}
+
+func NewStatsdSink(address string, prefix string, tags []string) (*StatsdSink, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsdSink{
+		conn:       conn,
+		prefix:     prefix,
+		tags:       tags,
+		lastValues: make(map[string]float64),
+	}, nil
+}
+
+func (s *StatsdSink) Emit(families []*dto.MetricFamily) error {
+	lines := s.format(families)
+
+	var packet bytes.Buffer
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > statsdMaxPacketSize {
+			if _, err := s.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		if _, err := s.conn.Write(packet.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StatsdSink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsdSink) format(families []*dto.MetricFamily) []string {
+	lines := make([]string, 0)
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			tags := s.formatTags(m.GetLabel())
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				lines = s.appendCounter(lines, name, tags, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				lines = append(lines, s.line(name, m.GetGauge().GetValue(), "g", tags))
+			case dto.MetricType_UNTYPED:
+				lines = append(lines, s.line(name, m.GetUntyped().GetValue(), "g", tags))
+			case dto.MetricType_HISTOGRAM:
+				lines = s.appendCounter(lines, name+".count", tags, float64(m.GetHistogram().GetSampleCount()))
+				lines = s.appendCounter(lines, name+".sum", tags, m.GetHistogram().GetSampleSum())
+			case dto.MetricType_SUMMARY:
+				lines = s.appendCounter(lines, name+".count", tags, float64(m.GetSummary().GetSampleCount()))
+				lines = s.appendCounter(lines, name+".sum", tags, m.GetSummary().GetSampleSum())
+			}
+		}
+	}
+	return lines
+}
+
+func (s *StatsdSink) appendCounter(lines []string, name string, tags string, value float64) []string {
+	key := name + "|" + tags
+	last, ok := s.lastValues[key]
+	s.lastValues[key] = value
+	delta := value
+	// value lower than the previous one means the counter was reset
+	if ok && value >= last {
+		delta = value - last
+	}
+	if delta == 0 {
+		return lines
+	}
+	return append(lines, s.line(name, delta, "c", tags))
+}
+
+func (s *StatsdSink) line(name string, value float64, metricType string, tags string) string {
+	line := fmt.Sprintf("%s%s:%s|%s", s.prefix, name, strconv.FormatFloat(value, 'f', -1, 64), metricType)
+	if tags != "" {
+		line += "|#" + tags
+	}
+	return line
+}
+
+func (s *StatsdSink) formatTags(labels []*dto.LabelPair) string {
+	tags := make([]string, 0, len(s.tags)+len(labels))
+	for _, tag := range s.tags {
+		tags = append(tags, statsdTagReplacer.Replace(tag))
+	}
+	labelTags := make([]string, 0, len(labels))
+	for _, label := range labels {
+		labelTags = append(labelTags, statsdTagReplacer.Replace(label.GetName())+":"+statsdTagReplacer.Replace(label.GetValue()))
+	}
+	sort.Strings(labelTags)
+	return strings.Join(append(tags, labelTags...), ",")
+}
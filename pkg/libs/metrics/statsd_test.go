@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsdSinkEmit(t *testing.T) {
+	a := assert.New(t)
+
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	a.Nil(err)
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "proxy_requests_total", Help: "requests"}, []string{"broker"})
+	connections := prometheus.NewGauge(prometheus.GaugeOpts{Name: "proxy_connections", Help: "connections"})
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_other", Help: "other"})
+	registry.MustRegister(requests, connections, other)
+
+	sink, err := NewStatsdSink(server.LocalAddr().String(), "kafka_proxy.", []string{"env:test"})
+	a.Nil(err)
+	exporter := NewExporter(registry, sink, "proxy_", time.Second)
+
+	requests.WithLabelValues("kafka-0:9092").Add(3)
+	connections.Set(2)
+	a.Nil(exporter.Flush())
+	a.Equal([]string{
+		"kafka_proxy.proxy_connections:2|g|#env:test",
+		"kafka_proxy.proxy_requests_total:3|c|#env:test,broker:kafka-0:9092",
+	}, readPacket(a, server))
+
+	requests.WithLabelValues("kafka-0:9092").Add(2)
+	a.Nil(exporter.Flush())
+	a.Equal([]string{
+		"kafka_proxy.proxy_connections:2|g|#env:test",
+		"kafka_proxy.proxy_requests_total:2|c|#env:test,broker:kafka-0:9092",
+	}, readPacket(a, server))
+
+	a.Nil(sink.Close())
+}
+
+func readPacket(a *assert.Assertions, conn net.PacketConn) []string {
+	buf := make([]byte, statsdMaxPacketSize)
+	a.Nil(conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	a.Nil(err)
+	return strings.Split(string(buf[:n]), "\n")
+}
@@ -0,0 +1,127 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const maxCachedSchemas = 4096
+
+// ErrSchemaNotFound is returned when the schema id is not registered
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// Client looks up the subjects of schema ids in a Confluent compatible Schema Registry.
+// Schema ids are immutable, so the found subjects are cached until the TTL expires. Missing schemas are not cached.
+type Client struct {
+	url      string
+	username string
+	password string
+	timeout  time.Duration
+	cacheTTL time.Duration
+	client   *http.Client
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[int32]*cachedSubjects
+}
+
+type cachedSubjects struct {
+	subjects []string
+	expires  time.Time
+}
+
+type subjectVersion struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func NewClient(url string, username string, password string, timeout time.Duration, cacheTTL time.Duration) *Client {
+	return &Client{
+		url:      strings.TrimSuffix(url, "/"),
+		username: username,
+		password: password,
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+		client:   &http.Client{},
+		now:      time.Now,
+		cache:    make(map[int32]*cachedSubjects),
+	}
+}
+
+// Subjects returns the subjects under which the schema id is registered
+func (c *Client) Subjects(id int32) ([]string, error) {
+	c.mu.Lock()
+	cached, ok := c.cache[id]
+	c.mu.Unlock()
+	if ok && c.now().Before(cached.expires) {
+		return cached.subjects, nil
+	}
+
+	subjects, err := c.fetchSubjects(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.cache) >= maxCachedSchemas {
+		c.cache = make(map[int32]*cachedSubjects)
+	}
+	c.cache[id] = &cachedSubjects{subjects: subjects, expires: c.now().Add(c.cacheTTL)}
+	return subjects, nil
+}
+
+func (c *Client) fetchSubjects(id int32) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/schemas/ids/%d/versions", c.url, id), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "schema %d lookup failed", id)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "schema %d lookup failed", id)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrSchemaNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		errResp := &errorResponse{}
+		_ = json.Unmarshal(body, errResp)
+		return nil, errors.Errorf("schema %d lookup failed with status %d: %s", id, resp.StatusCode, errResp.Message)
+	}
+	versions := make([]subjectVersion, 0)
+	if err = json.Unmarshal(body, &versions); err != nil {
+		return nil, errors.Wrapf(err, "invalid schema %d lookup response", id)
+	}
+	subjects := make([]string, 0, len(versions))
+	for _, version := range versions {
+		subjects = append(subjects, version.Subject)
+	}
+	return subjects, nil
+}
@@ -0,0 +1,60 @@
+package schemaregistry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientSubjects(t *testing.T) {
+	a := assert.New(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error_code":401,"message":"Unauthorized"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/schemas/ids/1/versions":
+			_, _ = w.Write([]byte(`[{"subject":"orders-value","version":1},{"subject":"payments-value","version":3}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "user", "secret", time.Second, time.Minute)
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	subjects, err := client.Subjects(1)
+	a.Nil(err)
+	a.Equal([]string{"orders-value", "payments-value"}, subjects)
+
+	// cached
+	_, err = client.Subjects(1)
+	a.Nil(err)
+	a.Equal(1, requests)
+
+	now = now.Add(time.Minute)
+	_, err = client.Subjects(1)
+	a.Nil(err)
+	a.Equal(2, requests)
+
+	// missing schemas are not cached
+	_, err = client.Subjects(2)
+	a.Equal(ErrSchemaNotFound, err)
+	_, err = client.Subjects(2)
+	a.Equal(ErrSchemaNotFound, err)
+	a.Equal(4, requests)
+
+	_, err = NewClient(server.URL, "user", "wrong", time.Second, time.Minute).Subjects(1)
+	a.EqualError(err, "schema 1 lookup failed with status 401: Unauthorized")
+}
@@ -0,0 +1,259 @@
+package sidecarinjector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	AnnotationPrefix = "kafka-proxy.grepplabs.com/"
+
+	// "true" enables the injection
+	AnnotationInject = AnnotationPrefix + "inject"
+	// set by the injector
+	AnnotationStatus = AnnotationPrefix + "status"
+	// comma separated Kafka bootstrap servers, each is mapped to a local listener
+	AnnotationBootstrapServers = AnnotationPrefix + "bootstrap-servers"
+	// first local listener port
+	AnnotationListenerPort = AnnotationPrefix + "listener-port"
+	// name of the environment variable with the local bootstrap servers added to the app containers
+	AnnotationBootstrapServersEnv = AnnotationPrefix + "bootstrap-servers-env"
+	// additional kafka-proxy server arguments separated by whitespaces
+	AnnotationArgs = AnnotationPrefix + "args"
+	// sidecar image
+	AnnotationImage = AnnotationPrefix + "image"
+	// "true" starts the app containers after the sidecar is ready
+	AnnotationStartBeforeApp = AnnotationPrefix + "start-before-app"
+	// duration the sidecar is kept running after the pod termination started, so the app containers are stopped first
+	AnnotationStopDelay = AnnotationPrefix + "stop-delay"
+
+	statusInjected = "injected"
+
+	SidecarName = "kafka-proxy"
+)
+
+var jsonPatchType = "JSONPatch"
+
+type Config struct {
+	Image           string
+	ImagePullPolicy string
+	// arguments added to all sidecars
+	Args           []string
+	ListenerPort   int
+	HttpPort       int
+	StartBeforeApp bool
+	StopDelay      time.Duration
+}
+
+type Injector struct {
+	config Config
+}
+
+func New(config Config) *Injector {
+	return &Injector{config: config}
+}
+
+// sidecar settings of the pod
+type podSettings struct {
+	image               string
+	bootstrapServers    []string
+	listenerPort        int
+	bootstrapServersEnv string
+	args                []string
+	startBeforeApp      bool
+	stopDelay           time.Duration
+}
+
+func (i *Injector) podSettings(annotations map[string]string) (*podSettings, error) {
+	settings := &podSettings{
+		image:               i.config.Image,
+		listenerPort:        i.config.ListenerPort,
+		bootstrapServersEnv: annotations[AnnotationBootstrapServersEnv],
+		args:                strings.Fields(annotations[AnnotationArgs]),
+		startBeforeApp:      i.config.StartBeforeApp,
+		stopDelay:           i.config.StopDelay,
+	}
+	for _, server := range strings.Split(annotations[AnnotationBootstrapServers], ",") {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return nil, errors.Wrapf(err, "annotation %s", AnnotationBootstrapServers)
+		}
+		settings.bootstrapServers = append(settings.bootstrapServers, server)
+	}
+	if len(settings.bootstrapServers) == 0 {
+		return nil, fmt.Errorf("annotation %s is required", AnnotationBootstrapServers)
+	}
+	if value, ok := annotations[AnnotationImage]; ok && value != "" {
+		settings.image = value
+	}
+	if value, ok := annotations[AnnotationListenerPort]; ok {
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port+len(settings.bootstrapServers) > math.MaxUint16 {
+			return nil, fmt.Errorf("annotation %s must be a valid port number", AnnotationListenerPort)
+		}
+		settings.listenerPort = port
+	}
+	if value, ok := annotations[AnnotationStartBeforeApp]; ok {
+		startBeforeApp, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %s must be true or false", AnnotationStartBeforeApp)
+		}
+		settings.startBeforeApp = startBeforeApp
+	}
+	if value, ok := annotations[AnnotationStopDelay]; ok {
+		stopDelay, err := time.ParseDuration(value)
+		if err != nil || stopDelay < 0 {
+			return nil, fmt.Errorf("annotation %s must be a non-negative duration", AnnotationStopDelay)
+		}
+		settings.stopDelay = stopDelay
+	}
+	return settings, nil
+}
+
+func (s *podSettings) localBootstrapServers() []string {
+	servers := make([]string, 0, len(s.bootstrapServers))
+	for n := range s.bootstrapServers {
+		servers = append(servers, net.JoinHostPort("127.0.0.1", strconv.Itoa(s.listenerPort+n)))
+	}
+	return servers
+}
+
+func (i *Injector) sidecar(settings *podSettings) container {
+	args := []string{"server"}
+	for n, local := range settings.localBootstrapServers() {
+		args = append(args, fmt.Sprintf("--bootstrap-server-mapping=%s,%s", settings.bootstrapServers[n], local))
+	}
+	args = append(args, fmt.Sprintf("--http-listen-address=0.0.0.0:%d", i.config.HttpPort))
+	args = append(args, i.config.Args...)
+	args = append(args, settings.args...)
+
+	sidecar := container{
+		Name:            SidecarName,
+		Image:           settings.image,
+		ImagePullPolicy: i.config.ImagePullPolicy,
+		Args:            args,
+		Ports:           []containerPort{{Name: "kafka-proxy-http", ContainerPort: i.config.HttpPort}},
+		ReadinessProbe: &probe{
+			HTTPGet:       &httpGetAction{Path: "/health", Port: i.config.HttpPort},
+			PeriodSeconds: 5,
+		},
+	}
+	if settings.startBeforeApp || settings.stopDelay > 0 {
+		sidecar.Lifecycle = &lifecycle{}
+	}
+	if settings.startBeforeApp {
+		// kubelet starts the next container after the post start hook of the previous one returned
+		sidecar.Lifecycle.PostStart = &handler{Exec: &execAction{Command: []string{
+			"/bin/sh", "-c", fmt.Sprintf("until wget -q -O /dev/null http://127.0.0.1:%d/health; do sleep 1; done", i.config.HttpPort),
+		}}}
+	}
+	if settings.stopDelay > 0 {
+		sidecar.Lifecycle.PreStop = &handler{Exec: &execAction{Command: []string{
+			"/bin/sh", "-c", fmt.Sprintf("sleep %d", int(math.Ceil(settings.stopDelay.Seconds()))),
+		}}}
+	}
+	return sidecar
+}
+
+// Mutate returns the JSON patch injecting the sidecar into the pod or nil if the pod is not selected for injection
+func (i *Injector) Mutate(object []byte) ([]byte, error) {
+	var p pod
+	if err := json.Unmarshal(object, &p); err != nil {
+		return nil, errors.Wrap(err, "invalid pod")
+	}
+	annotations := p.Metadata.Annotations
+	if inject, _ := strconv.ParseBool(annotations[AnnotationInject]); !inject {
+		return nil, nil
+	}
+	if annotations[AnnotationStatus] == statusInjected {
+		return nil, nil
+	}
+	for _, c := range p.Spec.Containers {
+		if c.Name == SidecarName {
+			return nil, nil
+		}
+	}
+	settings, err := i.podSettings(annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := make([]patchOperation, 0)
+	// app containers are patched before the sidecar shifts their indexes
+	if settings.bootstrapServersEnv != "" {
+		env := envVar{Name: settings.bootstrapServersEnv, Value: strings.Join(settings.localBootstrapServers(), ",")}
+		for n, c := range p.Spec.Containers {
+			if c.Env == nil {
+				patch = append(patch, patchOperation{Op: "add", Path: fmt.Sprintf("/spec/containers/%d/env", n), Value: []envVar{env}})
+			} else {
+				patch = append(patch, patchOperation{Op: "add", Path: fmt.Sprintf("/spec/containers/%d/env/-", n), Value: env})
+			}
+		}
+	}
+	// the inject annotation is present, so the annotations are not empty
+	patch = append(patch, patchOperation{Op: "add", Path: "/metadata/annotations/" + escapeJSONPointer(AnnotationStatus), Value: statusInjected})
+
+	sidecarPath := "/spec/containers/-"
+	if settings.startBeforeApp {
+		sidecarPath = "/spec/containers/0"
+	}
+	patch = append(patch, patchOperation{Op: "add", Path: sidecarPath, Value: i.sidecar(settings)})
+
+	return json.Marshal(patch)
+}
+
+func escapeJSONPointer(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
+
+// ServeHTTP handles the AdmissionReview requests of the mutating webhook
+func (i *Injector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	review := AdmissionReview{}
+	if err = json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+	response := &AdmissionResponse{UID: review.Request.UID, Allowed: true}
+	patch, err := i.Mutate(review.Request.Object)
+	if err != nil {
+		logrus.Warnf("Sidecar injection into pod in namespace %s rejected: %v", review.Request.Namespace, err)
+		response.Allowed = false
+		response.Result = &Status{Message: err.Error()}
+	} else if patch != nil {
+		logrus.Infof("Sidecar injected into pod in namespace %s", review.Request.Namespace)
+		response.Patch = patch
+		response.PatchType = &jsonPatchType
+	}
+	if review.APIVersion == "" {
+		review.APIVersion = "admission.k8s.io/v1"
+	}
+	result, err := json.Marshal(AdmissionReview{APIVersion: review.APIVersion, Kind: "AdmissionReview", Response: response})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(result)
+}
@@ -0,0 +1,172 @@
+package sidecarinjector
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = Config{
+	Image:          "grepplabs/kafka-proxy:latest",
+	Args:           []string{"--log-format=json"},
+	ListenerPort:   32400,
+	HttpPort:       9080,
+	StartBeforeApp: true,
+	StopDelay:      5 * time.Second,
+}
+
+func decodePatch(t *testing.T, patch []byte) []map[string]interface{} {
+	var ops []map[string]interface{}
+	assert.Nil(t, json.Unmarshal(patch, &ops))
+	return ops
+}
+
+func TestMutate(t *testing.T) {
+	a := assert.New(t)
+
+	object := `{
+		"metadata": {"name": "myapp", "annotations": {
+			"kafka-proxy.grepplabs.com/inject": "true",
+			"kafka-proxy.grepplabs.com/bootstrap-servers": "kafka-0:9093, kafka-1:9093",
+			"kafka-proxy.grepplabs.com/bootstrap-servers-env": "BOOTSTRAP_SERVERS",
+			"kafka-proxy.grepplabs.com/args": "--tls-enable --sasl-enable",
+			"kafka-proxy.grepplabs.com/stop-delay": "1500ms"
+		}},
+		"spec": {"containers": [
+			{"name": "myapp", "image": "myapp:latest"},
+			{"name": "worker", "image": "worker:latest", "env": [{"name": "A", "value": "a"}]}
+		]}
+	}`
+	patch, err := New(testConfig).Mutate([]byte(object))
+	a.Nil(err)
+	ops := decodePatch(t, patch)
+	a.Len(ops, 4)
+
+	a.Equal("/spec/containers/0/env", ops[0]["path"])
+	a.Equal([]interface{}{map[string]interface{}{"name": "BOOTSTRAP_SERVERS", "value": "127.0.0.1:32400,127.0.0.1:32401"}}, ops[0]["value"])
+	a.Equal("/spec/containers/1/env/-", ops[1]["path"])
+	a.Equal("/metadata/annotations/kafka-proxy.grepplabs.com~1status", ops[2]["path"])
+	a.Equal("injected", ops[2]["value"])
+
+	a.Equal("/spec/containers/0", ops[3]["path"])
+	sidecar := ops[3]["value"].(map[string]interface{})
+	a.Equal("kafka-proxy", sidecar["name"])
+	a.Equal("grepplabs/kafka-proxy:latest", sidecar["image"])
+	a.Equal([]interface{}{
+		"server",
+		"--bootstrap-server-mapping=kafka-0:9093,127.0.0.1:32400",
+		"--bootstrap-server-mapping=kafka-1:9093,127.0.0.1:32401",
+		"--http-listen-address=0.0.0.0:9080",
+		"--log-format=json",
+		"--tls-enable",
+		"--sasl-enable",
+	}, sidecar["args"])
+	lifecycle := sidecar["lifecycle"].(map[string]interface{})
+	a.NotNil(lifecycle["postStart"])
+	a.Equal(map[string]interface{}{"exec": map[string]interface{}{"command": []interface{}{"/bin/sh", "-c", "sleep 2"}}}, lifecycle["preStop"])
+}
+
+func TestMutateAppendsSidecar(t *testing.T) {
+	a := assert.New(t)
+
+	object := `{
+		"metadata": {"annotations": {
+			"kafka-proxy.grepplabs.com/inject": "true",
+			"kafka-proxy.grepplabs.com/bootstrap-servers": "kafka-0:9093",
+			"kafka-proxy.grepplabs.com/listener-port": "40000",
+			"kafka-proxy.grepplabs.com/image": "registry.local/kafka-proxy:v1",
+			"kafka-proxy.grepplabs.com/start-before-app": "false",
+			"kafka-proxy.grepplabs.com/stop-delay": "0s"
+		}},
+		"spec": {"containers": [{"name": "myapp"}]}
+	}`
+	patch, err := New(testConfig).Mutate([]byte(object))
+	a.Nil(err)
+	ops := decodePatch(t, patch)
+	a.Len(ops, 2)
+	a.Equal("/spec/containers/-", ops[1]["path"])
+	sidecar := ops[1]["value"].(map[string]interface{})
+	a.Equal("registry.local/kafka-proxy:v1", sidecar["image"])
+	a.Contains(sidecar["args"], "--bootstrap-server-mapping=kafka-0:9093,127.0.0.1:40000")
+	a.Nil(sidecar["lifecycle"])
+}
+
+func TestMutateSkipped(t *testing.T) {
+	tests := []struct {
+		name   string
+		object string
+	}{
+		{name: "not annotated", object: `{"metadata": {}, "spec": {"containers": [{"name": "myapp"}]}}`},
+		{name: "disabled", object: `{"metadata": {"annotations": {"kafka-proxy.grepplabs.com/inject": "false"}}, "spec": {"containers": [{"name": "myapp"}]}}`},
+		{name: "already injected", object: `{"metadata": {"annotations": {"kafka-proxy.grepplabs.com/inject": "true", "kafka-proxy.grepplabs.com/status": "injected"}}, "spec": {"containers": [{"name": "myapp"}]}}`},
+		{name: "sidecar exists", object: `{"metadata": {"annotations": {"kafka-proxy.grepplabs.com/inject": "true"}}, "spec": {"containers": [{"name": "kafka-proxy"}]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := New(testConfig).Mutate([]byte(tt.object))
+			assert.Nil(t, err)
+			assert.Nil(t, patch)
+		})
+	}
+}
+
+func TestMutateInvalidAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations string
+		err         string
+	}{
+		{name: "missing bootstrap servers", annotations: `{"kafka-proxy.grepplabs.com/inject": "true"}`, err: "annotation kafka-proxy.grepplabs.com/bootstrap-servers is required"},
+		{name: "invalid bootstrap server", annotations: `{"kafka-proxy.grepplabs.com/inject": "true", "kafka-proxy.grepplabs.com/bootstrap-servers": "kafka-0"}`, err: "annotation kafka-proxy.grepplabs.com/bootstrap-servers: address kafka-0: missing port in address"},
+		{name: "invalid listener port", annotations: `{"kafka-proxy.grepplabs.com/inject": "true", "kafka-proxy.grepplabs.com/bootstrap-servers": "kafka-0:9092", "kafka-proxy.grepplabs.com/listener-port": "65535"}`, err: "annotation kafka-proxy.grepplabs.com/listener-port must be a valid port number"},
+		{name: "invalid stop delay", annotations: `{"kafka-proxy.grepplabs.com/inject": "true", "kafka-proxy.grepplabs.com/bootstrap-servers": "kafka-0:9092", "kafka-proxy.grepplabs.com/stop-delay": "5"}`, err: "annotation kafka-proxy.grepplabs.com/stop-delay must be a non-negative duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object := `{"metadata": {"annotations": ` + tt.annotations + `}, "spec": {"containers": [{"name": "myapp"}]}}`
+			_, err := New(testConfig).Mutate([]byte(object))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	a := assert.New(t)
+
+	review := `{"apiVersion": "admission.k8s.io/v1", "kind": "AdmissionReview", "request": {"uid": "123", "namespace": "default", "object": {
+		"metadata": {"annotations": {"kafka-proxy.grepplabs.com/inject": "true", "kafka-proxy.grepplabs.com/bootstrap-servers": "kafka-0:9093"}},
+		"spec": {"containers": [{"name": "myapp"}]}
+	}}}`
+	recorder := httptest.NewRecorder()
+	New(testConfig).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewBufferString(review)))
+	a.Equal(http.StatusOK, recorder.Code)
+
+	var result AdmissionReview
+	a.Nil(json.Unmarshal(recorder.Body.Bytes(), &result))
+	a.Equal("admission.k8s.io/v1", result.APIVersion)
+	a.Equal("AdmissionReview", result.Kind)
+	a.Equal("123", result.Response.UID)
+	a.True(result.Response.Allowed)
+	a.Equal("JSONPatch", *result.Response.PatchType)
+	a.NotEmpty(result.Response.Patch)
+
+	review = `{"apiVersion": "admission.k8s.io/v1", "kind": "AdmissionReview", "request": {"uid": "456", "object": {
+		"metadata": {"annotations": {"kafka-proxy.grepplabs.com/inject": "true"}},
+		"spec": {"containers": [{"name": "myapp"}]}
+	}}}`
+	recorder = httptest.NewRecorder()
+	New(testConfig).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewBufferString(review)))
+	a.Nil(json.Unmarshal(recorder.Body.Bytes(), &result))
+	a.Equal("456", result.Response.UID)
+	a.False(result.Response.Allowed)
+	a.Equal("annotation kafka-proxy.grepplabs.com/bootstrap-servers is required", result.Response.Result.Message)
+
+	recorder = httptest.NewRecorder()
+	New(testConfig).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewBufferString("{}")))
+	a.Equal(http.StatusBadRequest, recorder.Code)
+}
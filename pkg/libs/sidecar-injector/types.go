@@ -0,0 +1,99 @@
+package sidecarinjector
+
+import "encoding/json"
+
+// Subset of the admission.k8s.io/v1 and core/v1 types used by the injector
+
+type AdmissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *AdmissionRequest  `json:"request,omitempty"`
+	Response   *AdmissionResponse `json:"response,omitempty"`
+}
+
+type AdmissionRequest struct {
+	UID       string          `json:"uid"`
+	Namespace string          `json:"namespace,omitempty"`
+	Object    json.RawMessage `json:"object"`
+}
+
+type AdmissionResponse struct {
+	UID       string  `json:"uid"`
+	Allowed   bool    `json:"allowed"`
+	Result    *Status `json:"status,omitempty"`
+	Patch     []byte  `json:"patch,omitempty"`
+	PatchType *string `json:"patchType,omitempty"`
+}
+
+type Status struct {
+	Message string `json:"message,omitempty"`
+}
+
+type pod struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     podSpec    `json:"spec"`
+}
+
+type objectMeta struct {
+	Name         string            `json:"name,omitempty"`
+	GenerateName string            `json:"generateName,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type podSpec struct {
+	Containers []podContainer `json:"containers"`
+}
+
+type podContainer struct {
+	Name string            `json:"name"`
+	Env  []json.RawMessage `json:"env,omitempty"`
+}
+
+type container struct {
+	Name            string          `json:"name"`
+	Image           string          `json:"image"`
+	ImagePullPolicy string          `json:"imagePullPolicy,omitempty"`
+	Args            []string        `json:"args"`
+	Ports           []containerPort `json:"ports,omitempty"`
+	ReadinessProbe  *probe          `json:"readinessProbe,omitempty"`
+	Lifecycle       *lifecycle      `json:"lifecycle,omitempty"`
+}
+
+type containerPort struct {
+	Name          string `json:"name"`
+	ContainerPort int    `json:"containerPort"`
+}
+
+type probe struct {
+	HTTPGet       *httpGetAction `json:"httpGet"`
+	PeriodSeconds int            `json:"periodSeconds,omitempty"`
+}
+
+type httpGetAction struct {
+	Path string `json:"path"`
+	Port int    `json:"port"`
+}
+
+type lifecycle struct {
+	PostStart *handler `json:"postStart,omitempty"`
+	PreStop   *handler `json:"preStop,omitempty"`
+}
+
+type handler struct {
+	Exec *execAction `json:"exec"`
+}
+
+type execAction struct {
+	Command []string `json:"command"`
+}
+
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
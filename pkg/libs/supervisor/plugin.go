@@ -0,0 +1,118 @@
+package supervisor
+
+import (
+	"context"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+)
+
+// TokenInfo returns apis.TokenInfo delegating to the supervised plugin
+func (s *Supervisor) TokenInfo() (apis.TokenInfo, bool) {
+	_, ok := s.current().(apis.TokenInfo)
+	return &tokenInfo{s}, ok
+}
+
+// TokenProvider returns apis.TokenProvider delegating to the supervised plugin
+func (s *Supervisor) TokenProvider() (apis.TokenProvider, bool) {
+	_, ok := s.current().(apis.TokenProvider)
+	return &tokenProvider{s}, ok
+}
+
+// PasswordAuthenticator returns apis.PasswordAuthenticator delegating to the supervised plugin
+func (s *Supervisor) PasswordAuthenticator() (apis.PasswordAuthenticator, bool) {
+	_, ok := s.current().(apis.PasswordAuthenticator)
+	return &passwordAuthenticator{s}, ok
+}
+
+type tokenInfo struct {
+	supervisor *Supervisor
+}
+
+// VerifyToken always fails closed, arbitrary tokens must not be accepted while the plugin is down.
+// The connections authenticated before are kept.
+func (p *tokenInfo) VerifyToken(ctx context.Context, request apis.VerifyRequest) (apis.VerifyResponse, error) {
+	impl, ok := p.supervisor.current().(apis.TokenInfo)
+	if !ok {
+		return apis.VerifyResponse{}, ErrPluginUnavailable
+	}
+	response, err := impl.VerifyToken(ctx, request)
+	if err != nil {
+		p.supervisor.callFailed(err)
+	}
+	return response, err
+}
+
+type tokenProvider struct {
+	supervisor *Supervisor
+}
+
+// GetToken cannot fail open, there is no token to provide while the plugin is down
+func (p *tokenProvider) GetToken(ctx context.Context, request apis.TokenRequest) (apis.TokenResponse, error) {
+	impl, ok := p.supervisor.current().(apis.TokenProvider)
+	if !ok {
+		return apis.TokenResponse{}, ErrPluginUnavailable
+	}
+	response, err := impl.GetToken(ctx, request)
+	if err != nil {
+		p.supervisor.callFailed(err)
+	}
+	return response, err
+}
+
+type passwordAuthenticator struct {
+	supervisor *Supervisor
+}
+
+// Authenticate always fails closed, arbitrary passwords must not be accepted while the plugin is down.
+// The connections authenticated before are kept.
+func (p *passwordAuthenticator) Authenticate(username, password string) (bool, int32, error) {
+	impl, ok := p.supervisor.current().(apis.PasswordAuthenticator)
+	if !ok {
+		return false, 0, ErrPluginUnavailable
+	}
+	success, status, err := impl.Authenticate(username, password)
+	if err != nil {
+		p.supervisor.callFailed(err)
+	}
+	return success, status, err
+}
+
+// Interceptor returns apis.Interceptor delegating to the supervised plugin
+func (s *Supervisor) Interceptor() (apis.Interceptor, bool) {
+	_, ok := s.current().(apis.Interceptor)
+	return &interceptor{s}, ok
+}
+
+type interceptor struct {
+	supervisor *Supervisor
+}
+
+func (p *interceptor) InterceptRequest(ctx context.Context, request apis.RequestInfo) (apis.InterceptResult, error) {
+	impl, ok := p.supervisor.current().(apis.Interceptor)
+	if !ok {
+		if p.supervisor.failOpen() {
+			return apis.InterceptResult{}, nil
+		}
+		return apis.InterceptResult{}, ErrPluginUnavailable
+	}
+	result, err := impl.InterceptRequest(ctx, request)
+	if err != nil {
+		p.supervisor.callFailed(err)
+	}
+	return result, err
+}
+
+func (p *interceptor) InterceptResponse(ctx context.Context, response apis.ResponseInfo) (apis.InterceptResult, error) {
+	impl, ok := p.supervisor.current().(apis.Interceptor)
+	if !ok {
+		if p.supervisor.failOpen() {
+			return apis.InterceptResult{}, nil
+		}
+		return apis.InterceptResult{}, ErrPluginUnavailable
+	}
+	result, err := impl.InterceptResponse(ctx, response)
+	if err != nil {
+		p.supervisor.callFailed(err)
+	}
+	return result, err
+}
@@ -0,0 +1,240 @@
+// Package supervisor keeps go-plugin subprocess plugins running. The plugin connection is health checked periodically,
+// a failed plugin is restarted with exponential backoff and while it is down calls are answered according to the fail policy.
+package supervisor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/hashicorp/go-plugin"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	FailClosed = "closed"
+	FailOpen   = "open"
+)
+
+// ErrPluginUnavailable is returned by fail closed calls while the plugin is down
+var ErrPluginUnavailable = errors.New("plugin is unavailable")
+
+var (
+	pluginUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "proxy_plugin_up",
+			Help: "Whether the supervised plugin is running and healthy.",
+		}, []string{"plugin"})
+	pluginRestartsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "proxy_plugin_restarts_total",
+			Help: "Number of supervised plugin restarts.",
+		}, []string{"plugin"})
+)
+
+func init() {
+	prometheus.MustRegister(pluginUp)
+	prometheus.MustRegister(pluginRestartsTotal)
+}
+
+// Launcher starts the plugin process and returns the client together with the dispensed plugin implementation
+type Launcher func() (*plugin.Client, interface{}, error)
+
+type Options struct {
+	// HealthCheckInterval is the interval of plugin health checks. Zero disables health checks and restarts.
+	HealthCheckInterval time.Duration
+	// MaxRestartBackoff is the maximal delay between restart attempts
+	MaxRestartBackoff time.Duration
+	// FailPolicy defines the result of interceptor calls while the plugin is down: FailOpen or FailClosed (default).
+	// Authentication calls always fail closed.
+	FailPolicy string
+	// MaxFailOpen bounds how long interceptor calls succeed after the plugin went down with FailOpen, later calls fail closed
+	MaxFailOpen time.Duration
+}
+
+type Supervisor struct {
+	name    string
+	launch  Launcher
+	options Options
+
+	mu       sync.RWMutex
+	client   *plugin.Client
+	impl     interface{}
+	restarts int
+	// the plugin is down since, calls fail closed when the max fail open duration elapsed
+	downSince time.Time
+
+	check    chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	running  sync.WaitGroup
+}
+
+// New launches the plugin and starts its supervision
+func New(name string, launch Launcher, options Options) (*Supervisor, error) {
+	if options.FailPolicy == "" {
+		options.FailPolicy = FailClosed
+	}
+	if options.FailPolicy != FailClosed && options.FailPolicy != FailOpen {
+		return nil, errors.Errorf("unknown plugin fail policy %s", options.FailPolicy)
+	}
+	if options.FailPolicy == FailOpen && options.MaxFailOpen <= 0 {
+		return nil, errors.New("max fail open duration is required by the open fail policy")
+	}
+	client, impl, err := launch()
+	if err != nil {
+		return nil, err
+	}
+	s := &Supervisor{
+		name:    name,
+		launch:  launch,
+		options: options,
+		client:  client,
+		impl:    impl,
+		check:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	pluginUp.WithLabelValues(name).Set(1)
+
+	if options.HealthCheckInterval > 0 {
+		s.running.Add(1)
+		go s.run()
+	}
+	return s, nil
+}
+
+// Close stops the supervision and kills the plugin
+func (s *Supervisor) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.running.Wait()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.client != nil {
+			s.client.Kill()
+		}
+		s.client = nil
+		s.impl = nil
+	})
+}
+
+// Status describes the supervised plugin
+type Status struct {
+	Name       string `json:"name"`
+	Up         bool   `json:"up"`
+	Restarts   int    `json:"restarts"`
+	FailPolicy string `json:"fail_policy"`
+}
+
+// Status returns whether the plugin is running and how often it was restarted
+func (s *Supervisor) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Status{Name: s.name, Up: s.impl != nil, Restarts: s.restarts, FailPolicy: s.options.FailPolicy}
+}
+
+func (s *Supervisor) current() interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.impl
+}
+
+func (s *Supervisor) failOpen() bool {
+	if s.options.FailPolicy != FailOpen {
+		return false
+	}
+	s.mu.RLock()
+	downSince := s.downSince
+	s.mu.RUnlock()
+	return time.Since(downSince) < s.options.MaxFailOpen
+}
+
+// callFailed requests an immediate health check
+func (s *Supervisor) callFailed(err error) {
+	logrus.Warnf("Plugin %s call failed: %v", s.name, err)
+	select {
+	case s.check <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Supervisor) run() {
+	defer s.running.Done()
+
+	ticker := time.NewTicker(s.options.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.check:
+		}
+		if err := s.healthCheck(); err != nil {
+			logrus.Errorf("Plugin %s health check failed: %v", s.name, err)
+			s.restart()
+		}
+	}
+}
+
+func (s *Supervisor) healthCheck() error {
+	s.mu.RLock()
+	client := s.client
+	s.mu.RUnlock()
+
+	if client == nil || client.Exited() {
+		return errors.New("plugin process exited")
+	}
+	rpcClient, err := client.Client()
+	if err != nil {
+		return err
+	}
+	return rpcClient.Ping()
+}
+
+func (s *Supervisor) restart() {
+	s.mu.Lock()
+	if s.client != nil {
+		s.client.Kill()
+	}
+	s.client = nil
+	s.impl = nil
+	s.downSince = time.Now()
+	s.mu.Unlock()
+	pluginUp.WithLabelValues(s.name).Set(0)
+
+	b := backoff.NewExponentialBackOff()
+	b.MaxInterval = s.options.MaxRestartBackoff
+	b.MaxElapsedTime = 0
+	if b.InitialInterval > b.MaxInterval {
+		b.InitialInterval = b.MaxInterval
+	}
+	b.Reset()
+
+	for {
+		client, impl, err := s.launch()
+		if err == nil {
+			s.mu.Lock()
+			s.client = client
+			s.impl = impl
+			s.restarts++
+			s.mu.Unlock()
+
+			pluginUp.WithLabelValues(s.name).Set(1)
+			pluginRestartsTotal.WithLabelValues(s.name).Inc()
+			logrus.Infof("Plugin %s restarted", s.name)
+			return
+		}
+		delay := b.NextBackOff()
+		logrus.Errorf("Plugin %s restart failed, retry in %v: %v", s.name, delay, err)
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/hashicorp/go-plugin"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTokenInfo struct{}
+
+func (testTokenInfo) VerifyToken(ctx context.Context, request apis.VerifyRequest) (apis.VerifyResponse, error) {
+	return apis.VerifyResponse{Success: request.Token == "valid", Status: 1}, nil
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// a launcher without a plugin process always fails the health check
+func failingAfterFirstLaunch(launches *int32) Launcher {
+	return func() (*plugin.Client, interface{}, error) {
+		if atomic.AddInt32(launches, 1) > 1 {
+			return nil, nil, errors.New("launch failed")
+		}
+		return nil, testTokenInfo{}, nil
+	}
+}
+
+func TestSupervisorFailClosed(t *testing.T) {
+	a := assert.New(t)
+
+	var launches int32
+	s, err := New("test", failingAfterFirstLaunch(&launches), Options{HealthCheckInterval: 10 * time.Millisecond, MaxRestartBackoff: 10 * time.Millisecond, FailPolicy: FailClosed})
+	a.Nil(err)
+	defer s.Close()
+
+	tokenInfo, ok := s.TokenInfo()
+	a.True(ok)
+	_, ok = s.TokenProvider()
+	a.False(ok)
+	a.Equal(Status{Name: "test", Up: true, FailPolicy: FailClosed}, s.Status())
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&launches) > 2 })
+	a.False(s.Status().Up)
+
+	_, err = tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: "valid"})
+	a.Equal(ErrPluginUnavailable, err)
+
+	interceptor, ok := s.Interceptor()
+	a.False(ok)
+	_, err = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{})
+	a.Equal(ErrPluginUnavailable, err)
+}
+
+func TestSupervisorFailOpen(t *testing.T) {
+	a := assert.New(t)
+
+	var launches int32
+	s, err := New("test", failingAfterFirstLaunch(&launches), Options{HealthCheckInterval: 10 * time.Millisecond, MaxRestartBackoff: 10 * time.Millisecond, FailPolicy: FailOpen, MaxFailOpen: time.Minute})
+	a.Nil(err)
+	defer s.Close()
+
+	tokenInfo, _ := s.TokenInfo()
+	response, err := tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: "invalid"})
+	a.Nil(err)
+	a.False(response.Success)
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&launches) > 2 })
+
+	// authentication never fails open
+	response, err = tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: "invalid"})
+	a.Equal(ErrPluginUnavailable, err)
+	a.False(response.Success)
+	authenticator, _ := s.PasswordAuthenticator()
+	ok, _, err := authenticator.Authenticate("alice", "invalid")
+	a.Equal(ErrPluginUnavailable, err)
+	a.False(ok)
+
+	interceptor, _ := s.Interceptor()
+	result, err := interceptor.InterceptRequest(context.Background(), apis.RequestInfo{})
+	a.Nil(err)
+	a.False(result.Deny)
+}
+
+func TestSupervisorFailOpenExpires(t *testing.T) {
+	a := assert.New(t)
+
+	var launches int32
+	s, err := New("test", failingAfterFirstLaunch(&launches), Options{HealthCheckInterval: 10 * time.Millisecond, MaxRestartBackoff: 10 * time.Millisecond, FailPolicy: FailOpen, MaxFailOpen: 50 * time.Millisecond})
+	a.Nil(err)
+	defer s.Close()
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&launches) > 2 })
+	time.Sleep(50 * time.Millisecond)
+
+	// the plugin is down for longer than the max fail open duration
+	interceptor, _ := s.Interceptor()
+	_, err = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{})
+	a.Equal(ErrPluginUnavailable, err)
+
+	_, err = New("test", failingAfterFirstLaunch(new(int32)), Options{FailPolicy: FailOpen})
+	a.NotNil(err)
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	a := assert.New(t)
+
+	var launches int32
+	launch := func() (*plugin.Client, interface{}, error) {
+		atomic.AddInt32(&launches, 1)
+		return nil, testTokenInfo{}, nil
+	}
+	s, err := New("test", launch, Options{HealthCheckInterval: 10 * time.Millisecond, MaxRestartBackoff: 10 * time.Millisecond})
+	a.Nil(err)
+
+	waitFor(t, func() bool { return s.Status().Restarts >= 2 })
+	s.Close()
+	a.Nil(s.current())
+
+	_, err = New("test", launch, Options{FailPolicy: "unknown"})
+	a.NotNil(err)
+}
@@ -0,0 +1,67 @@
+package tenantisolation
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/registry"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.NewComponentInterface(new(apis.InterceptorFactory))
+	registry.Register(new(Factory), "tenant-isolation")
+}
+
+type tenantList []string
+
+func (l *tenantList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *tenantList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+type pluginMeta struct {
+	tenants     tenantList
+	match       string
+	denyUnknown bool
+}
+
+func (f *pluginMeta) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("tenant isolation settings", flag.ContinueOnError)
+	fs.Var(&f.tenants, "tenant", "Tenant topic prefix '<principal or client id>=<topic prefix>'. May be given multiple times")
+	fs.StringVar(&f.match, "match", matchPrincipal, "Tenant is identified by principal or client-id. The client id is set by the client and does not isolate untrusted clients")
+	fs.BoolVar(&f.denyUnknown, "deny-unknown", false, "Deny requests with topics of clients without a tenant")
+	return fs
+}
+
+type Factory struct {
+}
+
+// New implements apis.InterceptorFactory
+func (t *Factory) New(params []string) (apis.Interceptor, error) {
+	meta := &pluginMeta{}
+	fs := meta.flagSet()
+	if err := fs.Parse(params); err != nil {
+		return nil, err
+	}
+	if meta.match != matchPrincipal && meta.match != matchClientID {
+		return nil, errors.Errorf("parameter match must be %s or %s", matchPrincipal, matchClientID)
+	}
+	prefixes := make(map[string]string)
+	for _, tenant := range meta.tenants {
+		pos := strings.Index(tenant, "=")
+		if pos <= 0 || pos == len(tenant)-1 {
+			return nil, errors.Errorf("invalid tenant '%s', expected <principal or client id>=<topic prefix>", tenant)
+		}
+		prefixes[tenant[:pos]] = tenant[pos+1:]
+	}
+	if len(prefixes) == 0 {
+		return nil, errors.New("parameter tenant is required")
+	}
+	return &Interceptor{prefixes: prefixes, match: meta.match, denyUnknown: meta.denyUnknown}, nil
+}
@@ -0,0 +1,81 @@
+package tenantisolation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactory(t *testing.T) {
+	a := assert.New(t)
+
+	factory, ok := registry.GetComponent(new(apis.InterceptorFactory), "tenant-isolation").(apis.InterceptorFactory)
+	a.True(ok)
+
+	interceptor, err := factory.New([]string{"--tenant", "alice=team-a.", "--tenant", "bob=team-b."})
+	a.Nil(err)
+
+	result, err := interceptor.InterceptRequest(context.Background(), apis.RequestInfo{Principal: "alice", TopicsDecoded: true, Topics: []string{"team-a.orders"}})
+	a.Nil(err)
+	a.False(result.Deny)
+	a.Equal(map[string]string{"tenant": "alice"}, result.Annotations)
+
+	result, _ = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{Principal: "alice", TopicsDecoded: true, Topics: []string{"team-a.orders", "team-b.orders"}})
+	a.True(result.Deny)
+
+	// unknown tenants are allowed by default
+	result, _ = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{Principal: "carol", TopicsDecoded: true, Topics: []string{"team-b.orders"}})
+	a.False(result.Deny)
+
+	_, err = factory.New([]string{})
+	a.NotNil(err)
+	_, err = factory.New([]string{"--tenant", "alice"})
+	a.NotNil(err)
+	_, err = factory.New([]string{"--tenant", "alice=a.", "--match", "user"})
+	a.NotNil(err)
+}
+
+func TestInterceptRequestClientID(t *testing.T) {
+	a := assert.New(t)
+
+	interceptor, err := new(Factory).New([]string{"--tenant", "app-a=team-a.", "--match", "client-id", "--deny-unknown"})
+	a.Nil(err)
+
+	result, _ := interceptor.InterceptRequest(context.Background(), apis.RequestInfo{ClientID: "app-a", Principal: "bob", TopicsDecoded: true, Topics: []string{"team-a.orders"}})
+	a.False(result.Deny)
+
+	result, _ = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{ClientID: "app-b", TopicsDecoded: true, Topics: []string{"team-a.orders"}})
+	a.True(result.Deny)
+
+	// requests without topics are not restricted
+	result, _ = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{ClientID: "app-b", ApiKey: 18})
+	a.False(result.Deny)
+}
+
+func TestInterceptRequestUncheckedTopics(t *testing.T) {
+	a := assert.New(t)
+
+	interceptor, err := new(Factory).New([]string{"--tenant", "alice=team-a."})
+	a.Nil(err)
+
+	// e.g. Fetch v12 or OffsetCommit
+	result, _ := interceptor.InterceptRequest(context.Background(), apis.RequestInfo{Principal: "alice", ApiKey: 1, ApiVersion: 12})
+	a.True(result.Deny)
+	result, _ = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{Principal: "alice", ApiKey: 8, ApiVersion: 8})
+	a.True(result.Deny)
+
+	// metadata of all topics
+	result, _ = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{Principal: "alice", ApiKey: 3, ApiVersion: 1, TopicsDecoded: true})
+	a.True(result.Deny)
+	result, _ = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{Principal: "alice", ApiKey: 3, ApiVersion: 0, TopicsDecoded: true, Topics: []string{}})
+	a.True(result.Deny)
+	result, _ = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{Principal: "alice", ApiKey: 3, ApiVersion: 1, TopicsDecoded: true, Topics: []string{"team-a.orders"}})
+	a.False(result.Deny)
+
+	// unknown tenants are not restricted without deny-unknown
+	result, _ = interceptor.InterceptRequest(context.Background(), apis.RequestInfo{Principal: "carol", ApiKey: 8, ApiVersion: 8})
+	a.False(result.Deny)
+}
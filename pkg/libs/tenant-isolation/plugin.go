@@ -0,0 +1,82 @@
+package tenantisolation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+)
+
+const (
+	matchPrincipal = "principal"
+	// the client id is chosen by the client, it is not a security boundary
+	matchClientID = "client-id"
+)
+
+const apiKeyMetadata = 3
+
+// topicRequests are the api keys of requests which refer to topics
+var topicRequests = map[int32]bool{
+	0:  true, // Produce
+	1:  true, // Fetch
+	2:  true, // ListOffsets
+	3:  true, // Metadata
+	8:  true, // OffsetCommit
+	9:  true, // OffsetFetch
+	19: true, // CreateTopics
+	20: true, // DeleteTopics
+	21: true, // DeleteRecords
+	23: true, // OffsetForLeaderEpoch
+	24: true, // AddPartitionsToTxn
+	28: true, // TxnOffsetCommit
+	32: true, // DescribeConfigs
+	33: true, // AlterConfigs
+	37: true, // CreatePartitions
+	43: true, // ElectLeaders
+	44: true, // IncrementalAlterConfigs
+	45: true, // AlterPartitionReassignments
+	46: true, // ListPartitionReassignments
+	47: true, // OffsetDelete
+	61: true, // DescribeProducers
+}
+
+// Interceptor restricts each tenant to the topics with the tenant prefix
+type Interceptor struct {
+	prefixes    map[string]string
+	match       string
+	denyUnknown bool
+}
+
+func (i *Interceptor) InterceptRequest(ctx context.Context, request apis.RequestInfo) (apis.InterceptResult, error) {
+	tenant := request.Principal
+	if i.match == matchClientID {
+		tenant = request.ClientID
+	}
+	if !topicRequests[request.ApiKey] {
+		return apis.InterceptResult{}, nil
+	}
+	prefix, ok := i.prefixes[tenant]
+	if !ok {
+		if i.denyUnknown {
+			return apis.InterceptResult{Deny: true, Reason: fmt.Sprintf("unknown tenant '%s'", tenant)}, nil
+		}
+		return apis.InterceptResult{}, nil
+	}
+	if !request.TopicsDecoded {
+		return apis.InterceptResult{Deny: true, Reason: fmt.Sprintf("topics of api key %d version %d could not be checked for tenant %s", request.ApiKey, request.ApiVersion, tenant)}, nil
+	}
+	if request.ApiKey == apiKeyMetadata && len(request.Topics) == 0 {
+		return apis.InterceptResult{Deny: true, Reason: fmt.Sprintf("metadata of all topics is not allowed for tenant %s", tenant)}, nil
+	}
+	for _, topic := range request.Topics {
+		if !strings.HasPrefix(topic, prefix) {
+			return apis.InterceptResult{Deny: true, Reason: fmt.Sprintf("topic %s does not belong to tenant %s", topic, tenant)}, nil
+		}
+	}
+	return apis.InterceptResult{Annotations: map[string]string{"tenant": tenant}}, nil
+}
+
+func (i *Interceptor) InterceptResponse(ctx context.Context, response apis.ResponseInfo) (apis.InterceptResult, error) {
+	return apis.InterceptResult{}, nil
+}
@@ -0,0 +1,82 @@
+package tokenexchangeprovider
+
+import (
+	"flag"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/registry"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.NewComponentInterface(new(apis.TokenProviderFactory))
+	registry.Register(new(Factory), "token-exchange-provider")
+}
+
+type pluginMeta struct {
+	timeout int
+
+	tokenURL           string
+	caCertFile         string
+	subjectTokenFile   string
+	subjectTokenType   string
+	requestedTokenType string
+	audience           string
+	resource           string
+	scope              string
+	clientID           string
+	clientSecretFile   string
+}
+
+func (f *pluginMeta) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("token exchange provider settings", flag.ContinueOnError)
+	fs.IntVar(&f.timeout, "timeout", 10, "Request timeout in seconds")
+	fs.StringVar(&f.tokenURL, "token-url", "", "URL of the token endpoint of the security token service")
+	fs.StringVar(&f.caCertFile, "ca-cert-file", "", "PEM encoded CA certificates to verify the token endpoint, system CAs if empty")
+	fs.StringVar(&f.subjectTokenFile, "subject-token-file", "/var/run/secrets/kubernetes.io/serviceaccount/token", "Location of the identity token exchanged for the access token. The file is read on each exchange")
+	fs.StringVar(&f.subjectTokenType, "subject-token-type", TokenTypeJWT, "Type of the identity token")
+	fs.StringVar(&f.requestedTokenType, "requested-token-type", TokenTypeAccessToken, "Type of the requested token")
+	fs.StringVar(&f.audience, "audience", "", "Audience of the requested token e.g. the Kafka cluster")
+	fs.StringVar(&f.resource, "resource", "", "URI of the resource the requested token is used for")
+	fs.StringVar(&f.scope, "scope", "", "Space-delimited scopes of the requested token")
+	fs.StringVar(&f.clientID, "client-id", "", "Client id to authenticate with the token endpoint")
+	fs.StringVar(&f.clientSecretFile, "client-secret-file", "", "Location of the client secret to authenticate with the token endpoint")
+	return fs
+}
+
+// Factory type
+type Factory struct {
+}
+
+// New implements apis.TokenProviderFactory
+func (t *Factory) New(params []string) (apis.TokenProvider, error) {
+	pluginMeta := &pluginMeta{}
+	fs := pluginMeta.flagSet()
+	if err := fs.Parse(params); err != nil {
+		return nil, err
+	}
+	if pluginMeta.tokenURL == "" {
+		return nil, errors.New("parameter token-url is required")
+	}
+	if pluginMeta.subjectTokenFile == "" {
+		return nil, errors.New("parameter subject-token-file is required")
+	}
+	if pluginMeta.clientSecretFile != "" && pluginMeta.clientID == "" {
+		return nil, errors.New("parameter client-id is required when client-secret-file is set")
+	}
+	options := TokenProviderOptions{
+		Timeout:            time.Duration(pluginMeta.timeout) * time.Second,
+		TokenURL:           pluginMeta.tokenURL,
+		CACertFile:         pluginMeta.caCertFile,
+		SubjectTokenFile:   pluginMeta.subjectTokenFile,
+		SubjectTokenType:   pluginMeta.subjectTokenType,
+		RequestedTokenType: pluginMeta.requestedTokenType,
+		Audience:           pluginMeta.audience,
+		Resource:           pluginMeta.resource,
+		Scope:              pluginMeta.scope,
+		ClientID:           pluginMeta.clientID,
+		ClientSecretFile:   pluginMeta.clientSecretFile,
+	}
+	return NewTokenProvider(options)
+}
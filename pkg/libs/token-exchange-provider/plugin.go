@@ -0,0 +1,278 @@
+package tokenexchangeprovider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/oidc"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	StatusOK                  = 0
+	StatusSubjectTokenFailed  = 1
+	StatusTokenExchangeFailed = 2
+
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+
+	// lifetime of exchanged tokens without expires_in and exp claim
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+var (
+	clockSkew = 1 * time.Minute
+	nowFn     = time.Now
+)
+
+// TokenProviderOptions - options of the token exchange
+type TokenProviderOptions struct {
+	Timeout time.Duration
+
+	TokenURL           string
+	CACertFile         string
+	SubjectTokenFile   string
+	SubjectTokenType   string
+	RequestedTokenType string
+	Audience           string
+	Resource           string
+	Scope              string
+	ClientID           string
+	ClientSecretFile   string
+}
+
+// TokenProvider exchanges the local identity token for an access token at the security token service (RFC 8693).
+// The access token is cached and refreshed in the background after half of its lifetime.
+type TokenProvider struct {
+	options TokenProviderOptions
+	client  *http.Client
+
+	l        sync.RWMutex
+	token    string
+	issuedAt time.Time
+	expiry   time.Time
+}
+
+// tokenExchangeResponse is the successful response of the token endpoint
+type tokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+}
+
+// tokenErrorResponse is the error response of the token endpoint
+type tokenErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewTokenProvider - Generate new token exchange provider
+func NewTokenProvider(options TokenProviderOptions) (*TokenProvider, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.CACertFile != "" {
+		caCert, err := ioutil.ReadFile(options.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs := x509.NewCertPool()
+		if ok := rootCAs.AppendCertsFromPEM(caCert); !ok {
+			return nil, errors.Errorf("failed to parse %s", options.CACertFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	}
+	tokenProvider := &TokenProvider{
+		options: options,
+		client:  &http.Client{Timeout: options.Timeout, Transport: transport},
+	}
+
+	op := func() error {
+		return tokenProvider.refresh(context.Background())
+	}
+	err := backoff.Retry(
+		op,
+		backoff.WithMaxTries(backoff.NewConstantBackOff(1*time.Second), 3))
+	if err != nil {
+		return nil, errors.Wrap(err, "initial token exchange failed")
+	}
+	go tokenProvider.refreshLoop()
+
+	return tokenProvider, nil
+}
+
+// GetToken implements apis.TokenProvider.GetToken method
+func (p *TokenProvider) GetToken(parent context.Context, _ apis.TokenRequest) (apis.TokenResponse, error) {
+	if token := p.getCurrentToken(); token != "" {
+		return getTokenResponse(token, StatusOK)
+	}
+	if err := p.refresh(parent); err != nil {
+		logrus.Errorf("token exchange failed: %v", err)
+		if _, ok := err.(subjectTokenError); ok {
+			return getTokenResponse("", StatusSubjectTokenFailed)
+		}
+		return getTokenResponse("", StatusTokenExchangeFailed)
+	}
+	return getTokenResponse(p.getCurrentToken(), StatusOK)
+}
+
+func getTokenResponse(token string, status int) (apis.TokenResponse, error) {
+	success := status == StatusOK
+	return apis.TokenResponse{Success: success, Status: int32(status), Token: token}, nil
+}
+
+// getCurrentToken returns the cached token or empty if it expires within the clock skew
+func (p *TokenProvider) getCurrentToken() string {
+	p.l.RLock()
+	defer p.l.RUnlock()
+
+	if p.token == "" || nowFn().After(p.expiry.Add(-clockSkew)) {
+		return ""
+	}
+	return p.token
+}
+
+func (p *TokenProvider) setCurrentToken(token string, issuedAt time.Time, expiry time.Time) {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	p.token = token
+	p.issuedAt = issuedAt
+	p.expiry = expiry
+}
+
+// renewEarliest returns true when half of the token lifetime has passed
+func (p *TokenProvider) renewEarliest() bool {
+	p.l.RLock()
+	defer p.l.RUnlock()
+
+	if p.token == "" {
+		return true
+	}
+	refreshTime := p.issuedAt.Add(p.expiry.Sub(p.issuedAt) / 2)
+	if latest := p.expiry.Add(-clockSkew); latest.Before(refreshTime) {
+		refreshTime = latest
+	}
+	return nowFn().After(refreshTime)
+}
+
+func (p *TokenProvider) refreshLoop() {
+	syncTicker := time.NewTicker(2 * time.Second)
+	defer syncTicker.Stop()
+
+	for range syncTicker.C {
+		if !p.renewEarliest() {
+			continue
+		}
+		if err := p.refresh(context.Background()); err != nil {
+			logrus.Errorf("refreshing of exchanged token failed: %v", err)
+		}
+	}
+}
+
+// refresh exchanges the current subject token and caches the issued token
+func (p *TokenProvider) refresh(parent context.Context) error {
+	ctx, cancel := context.WithTimeout(parent, p.options.Timeout)
+	defer cancel()
+
+	issuedAt := nowFn()
+	response, err := p.exchange(ctx)
+	if err != nil {
+		return err
+	}
+	expiry := tokenExpiry(response, issuedAt)
+	p.setCurrentToken(response.AccessToken, issuedAt, expiry)
+	logrus.Infof("Exchanged token of type %s expiry %v", response.IssuedTokenType, expiry)
+	return nil
+}
+
+type subjectTokenError struct {
+	error
+}
+
+func (p *TokenProvider) exchange(ctx context.Context) (*tokenExchangeResponse, error) {
+	subjectToken, err := ioutil.ReadFile(p.options.SubjectTokenFile)
+	if err != nil {
+		return nil, subjectTokenError{errors.Wrap(err, "cannot read subject token")}
+	}
+	form := url.Values{}
+	form.Set("grant_type", GrantTypeTokenExchange)
+	form.Set("subject_token", strings.TrimSpace(string(subjectToken)))
+	form.Set("subject_token_type", p.options.SubjectTokenType)
+	if p.options.RequestedTokenType != "" {
+		form.Set("requested_token_type", p.options.RequestedTokenType)
+	}
+	if p.options.Audience != "" {
+		form.Set("audience", p.options.Audience)
+	}
+	if p.options.Resource != "" {
+		form.Set("resource", p.options.Resource)
+	}
+	if p.options.Scope != "" {
+		form.Set("scope", p.options.Scope)
+	}
+	req, err := http.NewRequest(http.MethodPost, p.options.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.options.ClientID != "" {
+		var clientSecret string
+		if p.options.ClientSecretFile != "" {
+			secret, err := ioutil.ReadFile(p.options.ClientSecretFile)
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot read client secret")
+			}
+			clientSecret = strings.TrimSpace(string(secret))
+		}
+		req.SetBasicAuth(url.QueryEscape(p.options.ClientID), url.QueryEscape(clientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		errorResponse := &tokenErrorResponse{}
+		if json.Unmarshal(body, errorResponse) == nil && errorResponse.Error != "" {
+			return nil, errors.Errorf("token endpoint %s: %s: %s %s", p.options.TokenURL, resp.Status, errorResponse.Error, errorResponse.ErrorDescription)
+		}
+		return nil, errors.Errorf("token endpoint %s: %s", p.options.TokenURL, resp.Status)
+	}
+	response := &tokenExchangeResponse{}
+	if err = json.Unmarshal(body, response); err != nil {
+		return nil, errors.Wrapf(err, "token endpoint %s", p.options.TokenURL)
+	}
+	if response.AccessToken == "" {
+		return nil, errors.Errorf("token endpoint %s returned no access_token", p.options.TokenURL)
+	}
+	return response, nil
+}
+
+// tokenExpiry returns the expiry of expires_in, the exp claim of a JWT or the default lifetime
+func tokenExpiry(response *tokenExchangeResponse, issuedAt time.Time) time.Time {
+	if response.ExpiresIn > 0 {
+		return issuedAt.Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	if token, err := oidc.ParseJWT(response.AccessToken); err == nil && token.ClaimSet.Exp > 0 {
+		return time.Unix(token.ClaimSet.Exp, 0)
+	}
+	return issuedAt.Add(defaultTokenLifetime)
+}
@@ -0,0 +1,152 @@
+package tokenexchangeprovider
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func newTestSTS(t *testing.T, exchanges *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		username, password, _ := r.BasicAuth()
+		if username != "kafka-proxy" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		if r.PostForm.Get("grant_type") != GrantTypeTokenExchange || r.PostForm.Get("subject_token") != "workload-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"subject token is invalid"}`))
+			return
+		}
+		assert.Equal(t, TokenTypeJWT, r.PostForm.Get("subject_token_type"))
+		assert.Equal(t, TokenTypeAccessToken, r.PostForm.Get("requested_token_type"))
+		assert.Equal(t, "kafka", r.PostForm.Get("audience"))
+		n := atomic.AddInt32(exchanges, 1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":      "kafka-token-" + string('0'+rune(n)),
+			"issued_token_type": TokenTypeAccessToken,
+			"token_type":        "Bearer",
+			"expires_in":        3600,
+		})
+	}))
+}
+
+func newTestOptions(t *testing.T, tokenURL string, subjectToken string) TokenProviderOptions {
+	dir, err := ioutil.TempDir("", "token-exchange")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return TokenProviderOptions{
+		Timeout:            5 * time.Second,
+		TokenURL:           tokenURL,
+		SubjectTokenFile:   writeTestFile(t, dir, "token", subjectToken+"\n"),
+		SubjectTokenType:   TokenTypeJWT,
+		RequestedTokenType: TokenTypeAccessToken,
+		Audience:           "kafka",
+		ClientID:           "kafka-proxy",
+		ClientSecretFile:   writeTestFile(t, dir, "secret", "secret"),
+	}
+}
+
+func TestTokenExchangeCachesToken(t *testing.T) {
+	var exchanges int32
+	sts := newTestSTS(t, &exchanges)
+	defer sts.Close()
+
+	provider, err := NewTokenProvider(newTestOptions(t, sts.URL, "workload-token"))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		resp, err := provider.GetToken(context.Background(), apis.TokenRequest{})
+		require.NoError(t, err)
+		assert.True(t, resp.Success)
+		assert.Equal(t, "kafka-token-1", resp.Token)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&exchanges))
+	assert.False(t, provider.renewEarliest())
+}
+
+func TestTokenExchangeRefreshesExpiredToken(t *testing.T) {
+	var exchanges int32
+	sts := newTestSTS(t, &exchanges)
+	defer sts.Close()
+
+	provider, err := NewTokenProvider(newTestOptions(t, sts.URL, "workload-token"))
+	require.NoError(t, err)
+
+	defer func() { nowFn = time.Now }()
+	nowFn = func() time.Time { return time.Now().Add(35 * time.Minute) }
+	assert.True(t, provider.renewEarliest())
+	assert.Equal(t, "kafka-token-1", provider.getCurrentToken(), "token is valid until the clock skew before the expiry")
+
+	nowFn = func() time.Time { return time.Now().Add(59*time.Minute + 30*time.Second) }
+	resp, err := provider.GetToken(context.Background(), apis.TokenRequest{})
+	require.NoError(t, err)
+	assert.True(t, resp.Success)
+	assert.Equal(t, "kafka-token-2", resp.Token)
+}
+
+func TestTokenExchangeFailures(t *testing.T) {
+	var exchanges int32
+	sts := newTestSTS(t, &exchanges)
+	defer sts.Close()
+
+	_, err := NewTokenProvider(newTestOptions(t, sts.URL, "other-token"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_grant subject token is invalid")
+
+	options := newTestOptions(t, sts.URL, "workload-token")
+	provider, err := NewTokenProvider(options)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(options.SubjectTokenFile))
+	provider.setCurrentToken("", time.Time{}, time.Time{})
+	resp, err := provider.GetToken(context.Background(), apis.TokenRequest{})
+	require.NoError(t, err)
+	assert.False(t, resp.Success)
+	assert.Equal(t, int32(StatusSubjectTokenFailed), resp.Status)
+
+	writeTestFile(t, filepath.Dir(options.SubjectTokenFile), "token", "workload-token")
+	writeTestFile(t, filepath.Dir(options.ClientSecretFile), "secret", "wrong")
+	resp, err = provider.GetToken(context.Background(), apis.TokenRequest{})
+	require.NoError(t, err)
+	assert.False(t, resp.Success)
+	assert.Equal(t, int32(StatusTokenExchangeFailed), resp.Status)
+}
+
+func TestTokenExpiry(t *testing.T) {
+	issuedAt := time.Unix(1600000000, 0)
+
+	assert.Equal(t, issuedAt.Add(time.Hour), tokenExpiry(&tokenExchangeResponse{AccessToken: "opaque", ExpiresIn: 3600}, issuedAt))
+	assert.Equal(t, issuedAt.Add(defaultTokenLifetime), tokenExpiry(&tokenExchangeResponse{AccessToken: "opaque"}, issuedAt))
+	// {"alg":"none"}.{"exp":1600000600}.
+	assert.Equal(t, time.Unix(1600000600, 0), tokenExpiry(&tokenExchangeResponse{AccessToken: "eyJhbGciOiJub25lIn0.eyJleHAiOjE2MDAwMDA2MDB9."}, issuedAt))
+}
+
+func TestFactoryRequiresTokenURL(t *testing.T) {
+	_, err := new(Factory).New([]string{"--audience", "kafka"})
+	require.Error(t, err)
+	assert.Equal(t, "parameter token-url is required", err.Error())
+
+	_, err = new(Factory).New([]string{"--token-url", "https://sts.example.com/token", "--client-secret-file", "secret"})
+	require.Error(t, err)
+	assert.Equal(t, "parameter client-id is required when client-secret-file is set", err.Error())
+}
@@ -0,0 +1,41 @@
+package unsecuredjwtprovider
+
+import (
+	"flag"
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/registry"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.NewComponentInterface(new(apis.TokenProviderFactory))
+	registry.Register(new(Factory), "unsecured-jwt-provider")
+}
+
+type pluginMeta struct {
+	claimSub string
+}
+
+func (f *pluginMeta) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("unsecured-jwt-info info settings", flag.ContinueOnError)
+	fs.StringVar(&f.claimSub, "claim-sub", "", "subject claim")
+	return fs
+}
+
+type Factory struct {
+}
+
+// New implements apis.TokenProviderFactory
+func (t *Factory) New(params []string) (apis.TokenProvider, error) {
+	pluginMeta := &pluginMeta{}
+	flags := pluginMeta.flagSet()
+	if err := flags.Parse(params); err != nil {
+		return nil, err
+	}
+	if pluginMeta.claimSub == "" {
+		return nil, errors.New("parameter claim-sub is required")
+	}
+	return &UnsecuredJWTProvider{
+		claimSub: pluginMeta.claimSub,
+	}, nil
+}
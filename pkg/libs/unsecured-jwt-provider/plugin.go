@@ -0,0 +1,44 @@
+package unsecuredjwtprovider
+
+import (
+	"context"
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"golang.org/x/oauth2/jws"
+)
+
+const (
+	StatusOK          = 0
+	StatusEncodeError = 1
+	AlgorithmNone     = "none"
+)
+
+type UnsecuredJWTProvider struct {
+	claimSub string
+}
+
+func (v UnsecuredJWTProvider) GetToken(ctx context.Context, request apis.TokenRequest) (apis.TokenResponse, error) {
+	token, err := v.encodeToken()
+	if err != nil {
+		return getGetTokenResponse(StatusEncodeError, "")
+	}
+
+	return getGetTokenResponse(StatusOK, token)
+}
+
+func getGetTokenResponse(status int, token string) (apis.TokenResponse, error) {
+	success := status == StatusOK
+	return apis.TokenResponse{Success: success, Status: int32(status), Token: token}, nil
+}
+
+func (v UnsecuredJWTProvider) encodeToken() (string, error) {
+	header := &jws.Header{
+		Algorithm: AlgorithmNone,
+	}
+	claims := &jws.ClaimSet{
+		Sub: v.claimSub,
+	}
+	signer := func(data []byte) (sig []byte, err error) {
+		return []byte{}, nil
+	}
+	return jws.EncodeWithSigner(header, claims, signer)
+}
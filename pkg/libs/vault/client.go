@@ -0,0 +1,92 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Client calls the HashiCorp Vault HTTP API authenticated with the token of the token file
+type Client struct {
+	address   string
+	tokenFile string
+	client    *http.Client
+}
+
+// NewClient creates a client for the Vault address. The token file is read on each request, so renewed tokens are used
+func NewClient(address string, tokenFile string) (*Client, error) {
+	if address == "" {
+		return nil, errors.New("vault address is required")
+	}
+	if tokenFile == "" {
+		return nil, errors.New("vault token file is required")
+	}
+	if _, err := ioutil.ReadFile(tokenFile); err != nil {
+		return nil, errors.Wrap(err, "cannot read vault token")
+	}
+	return &Client{
+		address:   strings.TrimSuffix(address, "/"),
+		tokenFile: tokenFile,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []string        `json:"errors"`
+}
+
+// Do sends the request with the JSON encoded body (none if nil) to the API path e.g. transit/decrypt/my-key
+// and decodes the data of the response into data
+func (c *Client) Do(ctx context.Context, method string, path string, body interface{}, data interface{}) error {
+	token, err := ioutil.ReadFile(c.tokenFile)
+	if err != nil {
+		return errors.Wrap(err, "cannot read vault token")
+	}
+	path = strings.Trim(path, "/")
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/v1/%s", c.address, path), reader)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", strings.TrimSpace(string(token)))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	resp := &response{}
+	if err = json.NewDecoder(httpResp.Body).Decode(resp); err != nil && httpResp.StatusCode == http.StatusOK {
+		return errors.Wrap(err, "invalid vault response")
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return errors.Errorf("vault %s failed with status %d: %s", path, httpResp.StatusCode, strings.Join(resp.Errors, ", "))
+	}
+	if len(resp.Data) != 0 {
+		if err = json.Unmarshal(resp.Data, data); err != nil {
+			return errors.Wrap(err, "invalid vault response")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+)
+
+// KV reads secrets of the HashiCorp Vault KV secrets engine version 1 and 2
+type KV struct {
+	client *Client
+}
+
+// NewKV creates a reader authenticated with the token of the token file. The file is read on each request, so renewed tokens are used
+func NewKV(address string, tokenFile string) (*KV, error) {
+	client, err := NewClient(address, tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	return &KV{client: client}, nil
+}
+
+// Read returns the string values of the secret e.g. path secret/data/kafka-proxy of a version 2 engine mounted at secret
+func (k *KV) Read(ctx context.Context, path string) (map[string]string, error) {
+	data := make(map[string]interface{})
+	if err := k.client.Do(ctx, http.MethodGet, path, nil, &data); err != nil {
+		return nil, err
+	}
+	// version 2 nests the secret with its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	values := make(map[string]string, len(data))
+	for key, value := range data {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVRead(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/kafka-proxy":
+			_, _ = w.Write([]byte(`{"data":{"username":"proxy","password":"secret-1"}}`))
+		case "/v1/secret/data/kafka-proxy":
+			_, _ = w.Write([]byte(`{"data":{"data":{"username":"proxy","password":"secret-2","version":2},"metadata":{"version":3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vault-kv")
+	a.Nil(err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	a.Nil(ioutil.WriteFile(tokenFile, []byte("s.token\n"), 0600))
+
+	kv, err := NewKV(server.URL+"/", tokenFile)
+	a.Nil(err)
+
+	values, err := kv.Read(context.Background(), "kv/kafka-proxy")
+	a.Nil(err)
+	a.Equal(map[string]string{"username": "proxy", "password": "secret-1"}, values)
+
+	values, err = kv.Read(context.Background(), "/secret/data/kafka-proxy")
+	a.Nil(err)
+	a.Equal(map[string]string{"username": "proxy", "password": "secret-2"}, values)
+
+	_, err = kv.Read(context.Background(), "kv/missing")
+	a.EqualError(err, "vault kv/missing failed with status 404: ")
+
+	// rotated token
+	a.Nil(ioutil.WriteFile(tokenFile, []byte("s.expired"), 0600))
+	_, err = kv.Read(context.Background(), "kv/kafka-proxy")
+	a.EqualError(err, "vault kv/kafka-proxy failed with status 403: permission denied")
+
+	_, err = NewKV("", tokenFile)
+	a.NotNil(err)
+}
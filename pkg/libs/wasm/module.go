@@ -0,0 +1,148 @@
+// Package wasm loads token-info, token-provider and password authenticator plugins compiled to WebAssembly
+// and runs them in-process with wazero.
+//
+// A guest module must export its linear memory as "memory", a function "malloc(size i32) i32" which allocates
+// size bytes of guest memory and a function "free(ptr i32)" which releases it. Plugin functions take a pointer and length of a JSON encoded request and return the
+// pointer and length of a JSON encoded response packed into an i64 as (ptr << 32 | len):
+//
+//	verify_token(ptr i32, len i32) i64  // {"token": "...", "params": [...]} -> {"success": bool, "status": int, "subject": "...", "claims": {...}}
+//	get_token(ptr i32, len i32) i64     // {"params": [...]} -> {"success": bool, "status": int, "token": "..."}
+//	authenticate(ptr i32, len i32) i64  // {"username": "...", "password": "..."} -> {"success": bool, "status": int}
+//
+// The request is written to memory allocated with malloc. After the response is read, the host calls free with the
+// request and the response pointers, the guest must allocate the response with malloc or ignore frees of static memory.
+//
+// Plugin parameters are passed to the guest as WASI command line arguments. The WASI reactor initializer
+// "_initialize" is called when the module is instantiated.
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	mallocFunction = "malloc"
+	freeFunction   = "free"
+
+	verifyTokenFunction  = "verify_token"
+	getTokenFunction     = "get_token"
+	authenticateFunction = "authenticate"
+)
+
+// IsModule reports whether the plugin command refers to a WASM module
+func IsModule(command string) bool {
+	return strings.HasSuffix(command, ".wasm")
+}
+
+// Module is an instantiated guest module. Calls into the guest are serialized.
+type Module struct {
+	runtime wazero.Runtime
+	module  api.Module
+	malloc  api.Function
+	free    api.Function
+
+	mu sync.Mutex
+}
+
+// Load compiles and instantiates the WASM module. The params are passed as command line arguments.
+func Load(path string, params []string) (*Module, error) {
+	code, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(path, code, params)
+}
+
+// New instantiates the WASM module from code
+func New(name string, code []byte, params []string) (*Module, error) {
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, err
+	}
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return nil, errors.Wrapf(err, "compile wasm module %s", name)
+	}
+	moduleConfig := wazero.NewModuleConfig().
+		WithName(name).
+		WithArgs(append([]string{name}, params...)...).
+		WithStdout(os.Stdout).
+		WithStderr(os.Stderr).
+		WithStartFunctions("_initialize")
+
+	module, err := runtime.InstantiateModule(ctx, compiled, moduleConfig)
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return nil, errors.Wrapf(err, "instantiate wasm module %s", name)
+	}
+	malloc := module.ExportedFunction(mallocFunction)
+	free := module.ExportedFunction(freeFunction)
+	if malloc == nil || free == nil || module.Memory() == nil {
+		_ = runtime.Close(ctx)
+		return nil, errors.Errorf("wasm module %s must export memory, %s and %s", name, mallocFunction, freeFunction)
+	}
+	return &Module{runtime: runtime, module: module, malloc: malloc, free: free}, nil
+}
+
+// Close releases the module and the runtime
+func (m *Module) Close() error {
+	return m.runtime.Close(context.Background())
+}
+
+func (m *Module) call(ctx context.Context, function string, request interface{}, response interface{}) error {
+	fn := m.module.ExportedFunction(function)
+	if fn == nil {
+		return errors.Errorf("wasm module does not export %s", function)
+	}
+	input, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results, err := m.malloc.Call(ctx, uint64(len(input)))
+	if err != nil {
+		return errors.Wrap(err, mallocFunction)
+	}
+	ptr := uint32(results[0])
+	defer m.release(ctx, ptr)
+	if !m.module.Memory().Write(ptr, input) {
+		return errors.Errorf("%s returned out of range pointer %d", mallocFunction, ptr)
+	}
+	results, err = fn.Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return errors.Wrap(err, function)
+	}
+	outPtr, outLen := uint32(results[0]>>32), uint32(results[0])
+	defer m.release(ctx, outPtr)
+	output, ok := m.module.Memory().Read(outPtr, outLen)
+	if !ok {
+		return errors.Errorf("%s returned out of range response %d:%d", function, outPtr, outLen)
+	}
+	// the output is a view of the guest memory, it is decoded before the memory is released
+	return errors.Wrapf(json.Unmarshal(output, response), "%s response", function)
+}
+
+// release frees the guest memory, the module is still usable when free fails
+func (m *Module) release(ctx context.Context, ptr uint32) {
+	if _, err := m.free.Call(ctx, uint64(ptr)); err != nil {
+		logrus.Warnf("wasm module %s could not free memory at %d: %v", m.module.Name(), ptr, err)
+	}
+}
@@ -0,0 +1,93 @@
+package wasm
+
+import (
+	"context"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+)
+
+type verifyTokenRequest struct {
+	Token  string   `json:"token"`
+	Params []string `json:"params"`
+}
+
+type verifyTokenResponse struct {
+	Success bool              `json:"success"`
+	Status  int32             `json:"status"`
+	Subject string            `json:"subject,omitempty"`
+	Claims  map[string]string `json:"claims,omitempty"`
+}
+
+type getTokenRequest struct {
+	Params []string `json:"params"`
+}
+
+type getTokenResponse struct {
+	Success bool   `json:"success"`
+	Status  int32  `json:"status"`
+	Token   string `json:"token"`
+}
+
+type authenticateRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type authenticateResponse struct {
+	Success bool  `json:"success"`
+	Status  int32 `json:"status"`
+}
+
+// TokenInfo exposes the module as apis.TokenInfo
+func (m *Module) TokenInfo() apis.TokenInfo {
+	return &tokenInfo{module: m}
+}
+
+// TokenProvider exposes the module as apis.TokenProvider
+func (m *Module) TokenProvider() apis.TokenProvider {
+	return &tokenProvider{module: m}
+}
+
+// PasswordAuthenticator exposes the module as apis.PasswordAuthenticator
+func (m *Module) PasswordAuthenticator() apis.PasswordAuthenticator {
+	return &passwordAuthenticator{module: m}
+}
+
+type tokenInfo struct {
+	module *Module
+}
+
+// VerifyToken implements apis.TokenInfo
+func (p *tokenInfo) VerifyToken(ctx context.Context, request apis.VerifyRequest) (apis.VerifyResponse, error) {
+	var response verifyTokenResponse
+	if err := p.module.call(ctx, verifyTokenFunction, verifyTokenRequest{Token: request.Token, Params: request.Params}, &response); err != nil {
+		return apis.VerifyResponse{}, err
+	}
+	return apis.VerifyResponse{Success: response.Success, Status: response.Status, Subject: response.Subject, Claims: response.Claims}, nil
+}
+
+type tokenProvider struct {
+	module *Module
+}
+
+// GetToken implements apis.TokenProvider
+func (p *tokenProvider) GetToken(ctx context.Context, request apis.TokenRequest) (apis.TokenResponse, error) {
+	var response getTokenResponse
+	if err := p.module.call(ctx, getTokenFunction, getTokenRequest{Params: request.Params}, &response); err != nil {
+		return apis.TokenResponse{}, err
+	}
+	return apis.TokenResponse{Success: response.Success, Status: response.Status, Token: response.Token}, nil
+}
+
+type passwordAuthenticator struct {
+	module *Module
+}
+
+// Authenticate implements apis.PasswordAuthenticator
+func (p *passwordAuthenticator) Authenticate(username, password string) (bool, int32, error) {
+	var response authenticateResponse
+	if err := p.module.call(context.Background(), authenticateFunction, authenticateRequest{Username: username, Password: password}, &response); err != nil {
+		return false, 0, err
+	}
+	return response.Success, response.Status, nil
+}
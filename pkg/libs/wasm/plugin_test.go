@@ -0,0 +1,59 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsModule(t *testing.T) {
+	a := assert.New(t)
+	a.True(IsModule("/plugins/token-info.wasm"))
+	a.False(IsModule("/plugins/token-info"))
+	a.False(IsModule("google-id-info"))
+}
+
+func TestModulePlugins(t *testing.T) {
+	a := assert.New(t)
+
+	module, err := Load("testdata/plugin.wasm", []string{"--param", "value"})
+	a.Nil(err)
+	defer module.Close()
+
+	verifyResponse, err := module.TokenInfo().VerifyToken(context.Background(), apis.VerifyRequest{Token: "valid"})
+	a.Nil(err)
+	a.Equal(apis.VerifyResponse{Success: true, Status: 0}, verifyResponse)
+
+	verifyResponse, err = module.TokenInfo().VerifyToken(context.Background(), apis.VerifyRequest{Token: "invalid"})
+	a.Nil(err)
+	a.Equal(apis.VerifyResponse{Success: false, Status: 3}, verifyResponse)
+
+	tokenResponse, err := module.TokenProvider().GetToken(context.Background(), apis.TokenRequest{})
+	a.Nil(err)
+	a.Equal(apis.TokenResponse{Success: true, Status: 0, Token: "wasm-token"}, tokenResponse)
+
+	ok, status, err := module.PasswordAuthenticator().Authenticate("alice", "secret")
+	a.Nil(err)
+	a.True(ok)
+	a.Equal(int32(0), status)
+
+	ok, status, err = module.PasswordAuthenticator().Authenticate("bob", "secret")
+	a.Nil(err)
+	a.False(ok)
+	a.Equal(int32(3), status)
+
+	// the request and the response of each call are freed
+	a.Equal(uint64(10), module.module.ExportedGlobal("frees").Get())
+}
+
+func TestModuleInvalid(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := New("invalid.wasm", []byte("not a wasm module"), nil)
+	a.NotNil(err)
+
+	_, err = Load("testdata/missing.wasm", nil)
+	a.NotNil(err)
+}
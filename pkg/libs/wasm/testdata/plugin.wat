@@ -0,0 +1,54 @@
+;; Test guest module. plugin.wasm is the binary encoding of this module.
+(module
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+  (global $frees (export "frees") (mut i32) (i32.const 0))
+  (data (i32.const 16) "{\"success\":true,\"status\":0}")
+  (data (i32.const 64) "{\"success\":false,\"status\":3}")
+  (data (i32.const 128) "{\"success\":true,\"status\":0,\"token\":\"wasm-token\"}")
+
+  ;; bump allocator
+  (func (export "malloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    global.get $heap
+    local.set $ptr
+    global.get $heap
+    local.get $size
+    i32.add
+    global.set $heap
+    local.get $ptr)
+
+  ;; the bump allocator does not reuse memory, the freed pointers are counted
+  (func (export "free") (param $ptr i32)
+    global.get $frees
+    i32.const 1
+    i32.add
+    global.set $frees)
+
+  ;; tokens starting with 'v' are valid: {"token":"v...
+  (func (export "verify_token") (param $ptr i32) (param $len i32) (result i64)
+    local.get $ptr
+    i32.load8_u offset=10
+    i32.const 118
+    i32.eq
+    if (result i64)
+      i64.const 0x100000001b ;; 16:27
+    else
+      i64.const 0x400000001c ;; 64:28
+    end)
+
+  (func (export "get_token") (param $ptr i32) (param $len i32) (result i64)
+    i64.const 0x8000000030) ;; 128:48
+
+  ;; usernames starting with 'a' are valid: {"username":"a...
+  (func (export "authenticate") (param $ptr i32) (param $len i32) (result i64)
+    local.get $ptr
+    i32.load8_u offset=13
+    i32.const 97
+    i32.eq
+    if (result i64)
+      i64.const 0x100000001b
+    else
+      i64.const 0x400000001c
+    end)
+)
@@ -0,0 +1,156 @@
+// Package handshake negotiates the plugin API version between the proxy and the plugins.
+//
+// The proxy passes the API versions it supports to the plugin process in the KAFKA_PROXY_PLUGIN_API_VERSIONS environment variable.
+// The plugin serves the highest version supported by both sides. Older plugins always serve the base version of the handshake,
+// in which case the proxy downgrades to it when it is still supported.
+package handshake
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-plugin"
+)
+
+// EnvApiVersions is the environment variable with the comma separated API versions supported by the proxy
+const EnvApiVersions = "KAFKA_PROXY_PLUGIN_API_VERSIONS"
+
+// go-plugin: Incompatible API version with plugin. Plugin version: 1, Core version: 2
+var incompatibleVersionRegexp = regexp.MustCompile(`Incompatible API version with plugin\. Plugin version: (\d+), Core version: (\d+)`)
+
+// ApiVersions maps the plugin API versions to the capabilities introduced by them
+type ApiVersions map[uint][]string
+
+// Versioned is implemented by the plugin clients which adapt the calls to the negotiated API version
+type Versioned interface {
+	SetApiVersion(version uint)
+}
+
+func (v ApiVersions) sorted() []uint {
+	versions := make([]uint, 0, len(v))
+	for version := range v {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// Latest returns the highest API version
+func (v ApiVersions) Latest() uint {
+	versions := v.sorted()
+	if len(versions) == 0 {
+		return 0
+	}
+	return versions[len(versions)-1]
+}
+
+// Supports returns true if the API version is known
+func (v ApiVersions) Supports(version uint) bool {
+	_, ok := v[version]
+	return ok
+}
+
+// Capabilities returns the capabilities available in the API version i.e. introduced by the version or any prior one
+func (v ApiVersions) Capabilities(version uint) []string {
+	capabilities := make([]string, 0)
+	for _, known := range v.sorted() {
+		if known > version {
+			break
+		}
+		capabilities = append(capabilities, v[known]...)
+	}
+	return capabilities
+}
+
+// HasCapability returns true if the capability is available in the API version
+func (v ApiVersions) HasCapability(version uint, capability string) bool {
+	for _, available := range v.Capabilities(version) {
+		if available == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func (v ApiVersions) String() string {
+	versions := v.sorted()
+	values := make([]string, 0, len(versions))
+	for _, version := range versions {
+		values = append(values, strconv.FormatUint(uint64(version), 10))
+	}
+	return strings.Join(values, ",")
+}
+
+// Env returns the environment passed to the plugin process
+func (v ApiVersions) Env() []string {
+	return []string{EnvApiVersions + "=" + v.String()}
+}
+
+// ParseApiVersions parses comma separated API versions
+func ParseApiVersions(value string) ([]uint, error) {
+	versions := make([]uint, 0)
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		version, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plugin API version '%s'", s)
+		}
+		versions = append(versions, uint(version))
+	}
+	return versions, nil
+}
+
+// Serve returns the handshake config with the highest API version supported by the plugin and the proxy.
+// Proxies which do not pass their versions expect the base version of the handshake.
+// If there is no common version, the latest plugin version is served and the proxy reports the incompatibility.
+func Serve(handshake plugin.HandshakeConfig, versions ApiVersions) plugin.HandshakeConfig {
+	value, ok := os.LookupEnv(EnvApiVersions)
+	if !ok {
+		return handshake
+	}
+	proxyVersions, err := ParseApiVersions(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	handshake.ProtocolVersion = negotiate(proxyVersions, versions)
+	return handshake
+}
+
+func negotiate(proxyVersions []uint, versions ApiVersions) uint {
+	var negotiated uint
+	for _, version := range proxyVersions {
+		if versions.Supports(version) && version > negotiated {
+			negotiated = version
+		}
+	}
+	if negotiated == 0 {
+		fmt.Fprintf(os.Stderr, "No common plugin API version: proxy supports %v, plugin supports %s\n", proxyVersions, versions)
+		return versions.Latest()
+	}
+	return negotiated
+}
+
+// Downgrade returns the API version served by the plugin if the client failed due to a version mismatch and the proxy supports the lower plugin version.
+// Other errors are returned unchanged.
+func Downgrade(versions ApiVersions, current uint, err error) (uint, error) {
+	matches := incompatibleVersionRegexp.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return 0, err
+	}
+	pluginVersion, perr := strconv.ParseUint(matches[1], 10, 32)
+	if perr != nil {
+		return 0, err
+	}
+	version := uint(pluginVersion)
+	if version >= current || !versions.Supports(version) {
+		return 0, fmt.Errorf("plugin API version %d is incompatible, the proxy supports versions %s", version, versions)
+	}
+	return version, nil
+}
@@ -0,0 +1,98 @@
+package handshake
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/go-plugin"
+	"github.com/stretchr/testify/assert"
+)
+
+var testVersions = ApiVersions{
+	1: {"verify-token"},
+	2: {"claims"},
+	3: {"metadata"},
+}
+
+var testHandshake = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "TEST_PLUGIN",
+	MagicCookieValue: "hello",
+}
+
+func TestApiVersions(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(uint(3), testVersions.Latest())
+	a.Equal("1,2,3", testVersions.String())
+	a.Equal([]string{"KAFKA_PROXY_PLUGIN_API_VERSIONS=1,2,3"}, testVersions.Env())
+	a.Equal([]string{"verify-token", "claims"}, testVersions.Capabilities(2))
+	a.True(testVersions.Supports(3))
+	a.False(testVersions.Supports(4))
+	a.Equal(uint(0), ApiVersions{}.Latest())
+	a.True(testVersions.HasCapability(2, "claims"))
+	a.True(testVersions.HasCapability(3, "claims"))
+	a.False(testVersions.HasCapability(1, "claims"))
+}
+
+func TestParseApiVersions(t *testing.T) {
+	a := assert.New(t)
+
+	versions, err := ParseApiVersions(" 1, 2,,3")
+	a.Nil(err)
+	a.Equal([]uint{1, 2, 3}, versions)
+
+	_, err = ParseApiVersions("1,two")
+	a.NotNil(err)
+}
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      *string
+		expected uint
+	}{
+		{name: "proxy without negotiation", env: nil, expected: 1},
+		{name: "same versions", env: strPtr("1,2,3"), expected: 3},
+		{name: "older proxy", env: strPtr("1,2"), expected: 2},
+		{name: "newer proxy", env: strPtr("1,2,3,4"), expected: 3},
+		{name: "no common version", env: strPtr("4,5"), expected: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env == nil {
+				os.Unsetenv(EnvApiVersions)
+			} else {
+				os.Setenv(EnvApiVersions, *tt.env)
+			}
+			defer os.Unsetenv(EnvApiVersions)
+
+			handshake := Serve(testHandshake, testVersions)
+			assert.Equal(t, tt.expected, handshake.ProtocolVersion)
+			assert.Equal(t, testHandshake.MagicCookieKey, handshake.MagicCookieKey)
+		})
+	}
+}
+
+func TestDowngrade(t *testing.T) {
+	a := assert.New(t)
+
+	version, err := Downgrade(testVersions, 3, errors.New("Incompatible API version with plugin. Plugin version: 1, Core version: 3"))
+	a.Nil(err)
+	a.Equal(uint(1), version)
+
+	_, err = Downgrade(testVersions, 3, errors.New("Incompatible API version with plugin. Plugin version: 4, Core version: 3"))
+	a.EqualError(err, "plugin API version 4 is incompatible, the proxy supports versions 1,2,3")
+
+	_, err = Downgrade(ApiVersions{2: nil}, 2, errors.New("Incompatible API version with plugin. Plugin version: 1, Core version: 2"))
+	a.EqualError(err, "plugin API version 1 is incompatible, the proxy supports versions 2")
+
+	other := errors.New("plugin exited before we could connect")
+	_, err = Downgrade(testVersions, 3, other)
+	a.Equal(other, err)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,144 @@
+package shared
+
+import (
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/plugin/interceptor/proto"
+	"github.com/hashicorp/go-plugin"
+	"golang.org/x/net/context"
+)
+
+// GRPCClient is an implementation of Interceptor that talks over gRPC.
+type GRPCClient struct {
+	broker     *plugin.GRPCBroker
+	client     proto.InterceptorClient
+	apiVersion uint
+}
+
+// SetApiVersion implements handshake.Versioned
+func (m *GRPCClient) SetApiVersion(version uint) {
+	m.apiVersion = version
+}
+
+func (m *GRPCClient) InterceptRequest(ctx context.Context, request apis.RequestInfo) (apis.InterceptResult, error) {
+	request = requestForVersion(m.apiVersion, request)
+	resp, err := m.client.InterceptRequest(ctx, &proto.RequestInfo{
+		BrokerAddress: request.BrokerAddress,
+		Principal:     request.Principal,
+		ClientId:      request.ClientID,
+		ApiKey:        request.ApiKey,
+		ApiVersion:    request.ApiVersion,
+		CorrelationId: request.CorrelationID,
+		Topics:        request.Topics,
+		Records:       toProtoRecords(request.Records),
+		TopicsDecoded: request.TopicsDecoded,
+	})
+	if err != nil {
+		return apis.InterceptResult{}, err
+	}
+	return apis.InterceptResult{Deny: resp.Deny, Reason: resp.Reason, Annotations: resp.Annotations}, nil
+}
+
+func (m *GRPCClient) InterceptResponse(ctx context.Context, response apis.ResponseInfo) (apis.InterceptResult, error) {
+	resp, err := m.client.InterceptResponse(ctx, &proto.ResponseInfo{
+		BrokerAddress: response.BrokerAddress,
+		Principal:     response.Principal,
+		ClientId:      response.ClientID,
+		ApiKey:        response.ApiKey,
+		ApiVersion:    response.ApiVersion,
+		CorrelationId: response.CorrelationID,
+	})
+	if err != nil {
+		return apis.InterceptResult{}, err
+	}
+	return apis.InterceptResult{Deny: resp.Deny, Reason: resp.Reason, Annotations: resp.Annotations}, nil
+}
+
+// Here is the gRPC server that GRPCClient talks to.
+type GRPCServer struct {
+	broker *plugin.GRPCBroker
+	Impl   apis.Interceptor
+}
+
+func (m *GRPCServer) InterceptRequest(
+	ctx context.Context,
+	req *proto.RequestInfo) (*proto.InterceptResult, error) {
+	resp, err := m.Impl.InterceptRequest(ctx, apis.RequestInfo{
+		BrokerAddress: req.BrokerAddress,
+		Principal:     req.Principal,
+		ClientID:      req.ClientId,
+		ApiKey:        req.ApiKey,
+		ApiVersion:    req.ApiVersion,
+		CorrelationID: req.CorrelationId,
+		Topics:        req.Topics,
+		Records:       fromProtoRecords(req.Records),
+		TopicsDecoded: req.TopicsDecoded,
+	})
+	return &proto.InterceptResult{Deny: resp.Deny, Reason: resp.Reason, Annotations: resp.Annotations}, err
+}
+
+func (m *GRPCServer) InterceptResponse(
+	ctx context.Context,
+	req *proto.ResponseInfo) (*proto.InterceptResult, error) {
+	resp, err := m.Impl.InterceptResponse(ctx, apis.ResponseInfo{
+		BrokerAddress: req.BrokerAddress,
+		Principal:     req.Principal,
+		ClientID:      req.ClientId,
+		ApiKey:        req.ApiKey,
+		ApiVersion:    req.ApiVersion,
+		CorrelationID: req.CorrelationId,
+	})
+	return &proto.InterceptResult{Deny: resp.Deny, Reason: resp.Reason, Annotations: resp.Annotations}, err
+}
+
+// requestForVersion removes the record headers, if the plugin API version does not support them
+func requestForVersion(version uint, request apis.RequestInfo) apis.RequestInfo {
+	if !ApiVersions.HasCapability(version, capabilityRecordHeaders) {
+		request.Records = nil
+	}
+	return request
+}
+
+func toProtoRecords(records []apis.ProducedRecords) []*proto.ProducedRecords {
+	if records == nil {
+		return nil
+	}
+	result := make([]*proto.ProducedRecords, 0, len(records))
+	for _, partition := range records {
+		produced := &proto.ProducedRecords{Topic: partition.Topic, Partition: partition.Partition, Records: make([]*proto.RecordHeaders, 0, len(partition.Headers))}
+		for _, headers := range partition.Headers {
+			recordHeaders := &proto.RecordHeaders{Headers: make([]*proto.RecordHeader, 0, len(headers))}
+			for _, header := range headers {
+				// proto3 does not distinguish null and empty bytes
+				recordHeaders.Headers = append(recordHeaders.Headers, &proto.RecordHeader{Key: header.Key, Value: header.Value, NullValue: header.Value == nil})
+			}
+			produced.Records = append(produced.Records, recordHeaders)
+		}
+		result = append(result, produced)
+	}
+	return result
+}
+
+func fromProtoRecords(records []*proto.ProducedRecords) []apis.ProducedRecords {
+	if records == nil {
+		return nil
+	}
+	result := make([]apis.ProducedRecords, 0, len(records))
+	for _, partition := range records {
+		produced := apis.ProducedRecords{Topic: partition.Topic, Partition: partition.Partition, Headers: make([][]apis.RecordHeader, 0, len(partition.Records))}
+		for _, recordHeaders := range partition.Records {
+			var headers []apis.RecordHeader
+			for _, header := range recordHeaders.Headers {
+				value := header.Value
+				if header.NullValue {
+					value = nil
+				} else if value == nil {
+					value = []byte{}
+				}
+				headers = append(headers, apis.RecordHeader{Key: header.Key, Value: value})
+			}
+			produced.Headers = append(produced.Headers, headers)
+		}
+		result = append(result, produced)
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+// Package shared contains shared data between the host and plugins.
+package shared
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
+	"github.com/grepplabs/kafka-proxy/plugin/interceptor/proto"
+	"github.com/hashicorp/go-plugin"
+	"net/rpc"
+)
+
+// Handshake is a common handshake that is shared by plugin and host.
+var Handshake = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "INTERCEPTOR_PLUGIN",
+	MagicCookieValue: "hello",
+}
+
+const capabilityRecordHeaders = "record-headers"
+
+// ApiVersions are the plugin API versions with the capabilities introduced by them
+var ApiVersions = handshake.ApiVersions{
+	1: {"intercept-request", "intercept-response"},
+	2: {capabilityRecordHeaders},
+}
+
+var PluginMap = map[string]plugin.Plugin{
+	"interceptor": &InterceptorPlugin{},
+}
+
+type InterceptorPlugin struct {
+	Impl apis.Interceptor
+}
+
+func (p *InterceptorPlugin) GRPCServer(broker *plugin.GRPCBroker, s *grpc.Server) error {
+	proto.RegisterInterceptorServer(s, &GRPCServer{
+		Impl:   p.Impl,
+		broker: broker,
+	})
+	return nil
+}
+
+func (p *InterceptorPlugin) GRPCClient(ctx context.Context, broker *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return &GRPCClient{
+		client: proto.NewInterceptorClient(c),
+		broker: broker,
+	}, nil
+}
+
+func (p *InterceptorPlugin) Server(*plugin.MuxBroker) (interface{}, error) {
+	return &RPCServer{Impl: p.Impl}, nil
+}
+
+func (*InterceptorPlugin) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
+	return &RPCClient{client: c}, nil
+}
@@ -0,0 +1,45 @@
+package shared
+
+import (
+	"context"
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"net/rpc"
+)
+
+type RPCClient struct {
+	client     *rpc.Client
+	apiVersion uint
+}
+
+// SetApiVersion implements handshake.Versioned
+func (m *RPCClient) SetApiVersion(version uint) {
+	m.apiVersion = version
+}
+
+func (m *RPCClient) InterceptRequest(ctx context.Context, request apis.RequestInfo) (apis.InterceptResult, error) {
+	var resp apis.InterceptResult
+	err := m.client.Call("Plugin.InterceptRequest", requestForVersion(m.apiVersion, request), &resp)
+	return resp, err
+}
+
+func (m *RPCClient) InterceptResponse(ctx context.Context, response apis.ResponseInfo) (apis.InterceptResult, error) {
+	var resp apis.InterceptResult
+	err := m.client.Call("Plugin.InterceptResponse", response, &resp)
+	return resp, err
+}
+
+type RPCServer struct {
+	Impl apis.Interceptor
+}
+
+func (m *RPCServer) InterceptRequest(args apis.RequestInfo, resp *apis.InterceptResult) error {
+	r, err := m.Impl.InterceptRequest(context.Background(), args)
+	*resp = r
+	return err
+}
+
+func (m *RPCServer) InterceptResponse(args apis.ResponseInfo, resp *apis.InterceptResult) error {
+	r, err := m.Impl.InterceptResponse(context.Background(), args)
+	*resp = r
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
 	"github.com/grepplabs/kafka-proxy/plugin/local-auth/proto"
 	"github.com/hashicorp/go-plugin"
 	"net/rpc"
@@ -18,6 +19,11 @@ var Handshake = plugin.HandshakeConfig{
 	MagicCookieValue: "hello",
 }
 
+// ApiVersions are the plugin API versions with the capabilities introduced by them
+var ApiVersions = handshake.ApiVersions{
+	1: {"authenticate"},
+}
+
 var PluginMap = map[string]plugin.Plugin{
 	"passwordAuthenticator": &PasswordAuthenticatorPlugin{},
 }
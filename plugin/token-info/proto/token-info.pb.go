@@ -58,8 +58,10 @@ func (m *VerifyRequest) GetParams() []string {
 }
 
 type VerifyResponse struct {
-	Success bool  `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
-	Status  int32 `protobuf:"varint,2,opt,name=status" json:"status,omitempty"`
+	Success bool              `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Status  int32             `protobuf:"varint,2,opt,name=status" json:"status,omitempty"`
+	Subject string            `protobuf:"bytes,3,opt,name=subject" json:"subject,omitempty"`
+	Claims  map[string]string `protobuf:"bytes,4,rep,name=claims" json:"claims,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
 func (m *VerifyResponse) Reset()                    { *m = VerifyResponse{} }
@@ -81,6 +83,20 @@ func (m *VerifyResponse) GetStatus() int32 {
 	return 0
 }
 
+func (m *VerifyResponse) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *VerifyResponse) GetClaims() map[string]string {
+	if m != nil {
+		return m.Claims
+	}
+	return nil
+}
+
 func init() {
 	proto1.RegisterType((*VerifyRequest)(nil), "proto.VerifyRequest")
 	proto1.RegisterType((*VerifyResponse)(nil), "proto.VerifyResponse")
@@ -161,17 +177,21 @@ var _TokenInfo_serviceDesc = grpc.ServiceDesc{
 func init() { proto1.RegisterFile("token-info.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 178 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x28, 0xc9, 0xcf, 0x4e,
-	0xcd, 0xd3, 0xcd, 0xcc, 0x4b, 0xcb, 0xd7, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x05, 0x53,
-	0x4a, 0xb6, 0x5c, 0xbc, 0x61, 0xa9, 0x45, 0x99, 0x69, 0x95, 0x41, 0xa9, 0x85, 0xa5, 0xa9, 0xc5,
-	0x25, 0x42, 0x22, 0x5c, 0xac, 0x60, 0xb5, 0x12, 0x8c, 0x0a, 0x8c, 0x1a, 0x9c, 0x41, 0x10, 0x8e,
-	0x90, 0x18, 0x17, 0x5b, 0x41, 0x62, 0x51, 0x62, 0x6e, 0xb1, 0x04, 0x93, 0x02, 0xb3, 0x06, 0x67,
-	0x10, 0x94, 0xa7, 0xe4, 0xc4, 0xc5, 0x07, 0xd3, 0x5e, 0x5c, 0x90, 0x9f, 0x57, 0x9c, 0x2a, 0x24,
-	0xc1, 0xc5, 0x5e, 0x5c, 0x9a, 0x9c, 0x9c, 0x5a, 0x5c, 0x0c, 0x36, 0x81, 0x23, 0x08, 0xc6, 0x05,
-	0x99, 0x51, 0x5c, 0x92, 0x58, 0x52, 0x0a, 0x32, 0x83, 0x51, 0x83, 0x35, 0x08, 0xca, 0x33, 0x72,
-	0xe7, 0xe2, 0x0c, 0x01, 0x59, 0xe2, 0x99, 0x97, 0x96, 0x2f, 0x64, 0xc5, 0xc5, 0x0d, 0x31, 0x10,
-	0x2c, 0x24, 0x24, 0x02, 0x71, 0xad, 0x1e, 0x8a, 0x1b, 0xa5, 0x44, 0xd1, 0x44, 0x21, 0x56, 0x27,
-	0xb1, 0x81, 0x45, 0x8d, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0x44, 0xc5, 0xe0, 0x91, 0xed, 0x00,
-	0x00, 0x00,
+	// 251 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x90, 0xcf, 0x4b, 0xc3, 0x30,
+	0x14, 0xc7, 0xc9, 0x6a, 0xab, 0x7d, 0x45, 0x19, 0x61, 0x4a, 0xd8, 0xa9, 0xee, 0xd4, 0x8b, 0x3d,
+	0xcc, 0x8b, 0x1b, 0x78, 0x12, 0x11, 0xaf, 0x41, 0xbc, 0x67, 0x25, 0x85, 0xba, 0x2d, 0xa9, 0x7d,
+	0xa9, 0xd0, 0x7f, 0xd3, 0xbf, 0x48, 0xf2, 0xd2, 0x82, 0xca, 0x4e, 0xed, 0xe7, 0xf1, 0xde, 0xf7,
+	0x47, 0x60, 0xee, 0xec, 0x5e, 0x9b, 0xbb, 0xc6, 0xd4, 0xb6, 0x6c, 0x3b, 0xeb, 0x2c, 0x8f, 0xe9,
+	0xb3, 0x7a, 0x84, 0xcb, 0x77, 0xdd, 0x35, 0xf5, 0x20, 0xf5, 0x67, 0xaf, 0xd1, 0xf1, 0x05, 0xc4,
+	0xb4, 0x2b, 0x58, 0xce, 0x8a, 0x54, 0x06, 0xe0, 0x37, 0x90, 0xb4, 0xaa, 0x53, 0x47, 0x14, 0xb3,
+	0x3c, 0x2a, 0x52, 0x39, 0xd2, 0xea, 0x9b, 0xc1, 0xd5, 0x74, 0x8f, 0xad, 0x35, 0xa8, 0xb9, 0x80,
+	0x73, 0xec, 0xab, 0x4a, 0x23, 0x92, 0xc4, 0x85, 0x9c, 0xd0, 0x8b, 0xa0, 0x53, 0xae, 0xf7, 0x22,
+	0xac, 0x88, 0xe5, 0x48, 0xe1, 0x62, 0xf7, 0xa1, 0x2b, 0x27, 0x22, 0x32, 0x9d, 0x90, 0x6f, 0x20,
+	0xa9, 0x0e, 0xaa, 0x39, 0xa2, 0x38, 0xcb, 0xa3, 0x22, 0x5b, 0xdf, 0x86, 0xf0, 0xe5, 0x5f, 0xcb,
+	0xf2, 0x89, 0x76, 0x9e, 0x8d, 0xeb, 0x06, 0x39, 0x1e, 0x2c, 0x37, 0x90, 0xfd, 0x1a, 0xf3, 0x39,
+	0x44, 0x7b, 0x3d, 0x8c, 0xa5, 0xfc, 0xaf, 0x2f, 0xfa, 0xa5, 0x0e, 0xbd, 0xa6, 0x30, 0xa9, 0x0c,
+	0xb0, 0x9d, 0x3d, 0xb0, 0xf5, 0x0b, 0xa4, 0x6f, 0xbe, 0xf5, 0xab, 0xa9, 0x2d, 0xdf, 0x42, 0x16,
+	0xdc, 0x68, 0xc4, 0x17, 0xff, 0x12, 0xd0, 0xa3, 0x2d, 0xaf, 0x4f, 0xe6, 0xda, 0x25, 0x34, 0xbd,
+	0xff, 0x19, 0x00, 0x61, 0x0b, 0xbc, 0xe8, 0x7e, 0x01, 0x00, 0x00,
 }
@@ -9,13 +9,28 @@ import (
 
 // GRPCClient is an implementation of TokenInfo that talks over gRPC.
 type GRPCClient struct {
-	broker *plugin.GRPCBroker
-	client proto.TokenInfoClient
+	broker     *plugin.GRPCBroker
+	client     proto.TokenInfoClient
+	apiVersion uint
+}
+
+// SetApiVersion implements handshake.Versioned
+func (m *GRPCClient) SetApiVersion(version uint) {
+	m.apiVersion = version
 }
 
 func (m *GRPCClient) VerifyToken(ctx context.Context, request apis.VerifyRequest) (apis.VerifyResponse, error) {
 	resp, err := m.client.VerifyToken(ctx, &proto.VerifyRequest{Token: request.Token, Params: request.Params})
-	return apis.VerifyResponse{Success: resp.Success, Status: resp.Status}, err
+	return responseForVersion(m.apiVersion, apis.VerifyResponse{Success: resp.GetSuccess(), Status: resp.GetStatus(), Subject: resp.GetSubject(), Claims: resp.GetClaims()}), err
+}
+
+// responseForVersion ignores the subject and the claims, if the plugin API version does not provide them
+func responseForVersion(version uint, response apis.VerifyResponse) apis.VerifyResponse {
+	if !ApiVersions.HasCapability(version, capabilityClaims) {
+		response.Subject = ""
+		response.Claims = nil
+	}
+	return response
 }
 
 // Here is the gRPC server that GRPCClient talks to.
@@ -28,5 +43,5 @@ func (m *GRPCServer) VerifyToken(
 	ctx context.Context,
 	req *proto.VerifyRequest) (*proto.VerifyResponse, error) {
 	resp, err := m.Impl.VerifyToken(ctx, apis.VerifyRequest{Token: req.Token, Params: req.Params})
-	return &proto.VerifyResponse{Success: resp.Success, Status: resp.Status}, err
+	return &proto.VerifyResponse{Success: resp.Success, Status: resp.Status, Subject: resp.Subject, Claims: resp.Claims}, err
 }
@@ -6,6 +6,7 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/plugin/handshake"
 	"github.com/grepplabs/kafka-proxy/plugin/token-info/proto"
 	"github.com/hashicorp/go-plugin"
 	"net/rpc"
@@ -18,6 +19,14 @@ var Handshake = plugin.HandshakeConfig{
 	MagicCookieValue: "hello",
 }
 
+const capabilityClaims = "claims"
+
+// ApiVersions are the plugin API versions with the capabilities introduced by them
+var ApiVersions = handshake.ApiVersions{
+	1: {"verify-token"},
+	2: {capabilityClaims},
+}
+
 var PluginMap = map[string]plugin.Plugin{
 	"tokenInfo": &TokenInfoPlugin{},
 }
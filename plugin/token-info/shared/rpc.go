@@ -6,7 +6,15 @@ import (
 	"net/rpc"
 )
 
-type RPCClient struct{ client *rpc.Client }
+type RPCClient struct {
+	client     *rpc.Client
+	apiVersion uint
+}
+
+// SetApiVersion implements handshake.Versioned
+func (m *RPCClient) SetApiVersion(version uint) {
+	m.apiVersion = version
+}
 
 func (m *RPCClient) VerifyToken(ctx context.Context, request apis.VerifyRequest) (apis.VerifyResponse, error) {
 	var resp map[string]interface{}
@@ -14,7 +22,9 @@ func (m *RPCClient) VerifyToken(ctx context.Context, request apis.VerifyRequest)
 		"token":  request.Token,
 		"params": request.Params,
 	}, &resp)
-	return apis.VerifyResponse{Success: resp["success"].(bool), Status: resp["status"].(int32)}, err
+	subject, _ := resp["subject"].(string)
+	claims, _ := resp["claims"].(map[string]string)
+	return responseForVersion(m.apiVersion, apis.VerifyResponse{Success: resp["success"].(bool), Status: resp["status"].(int32), Subject: subject, Claims: claims}), err
 }
 
 type RPCServer struct {
@@ -27,6 +37,8 @@ func (m *RPCServer) VerifyToken(args map[string]interface{}, resp *map[string]in
 	*resp = map[string]interface{}{
 		"success": r.Success,
 		"status":  r.Status,
+		"subject": r.Subject,
+		"claims":  r.Claims,
 	}
 	return err
 }
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+)
+
+// ApiKeyRules are the forbidden and scheduled forbidden api keys shared by all connections. The rules are replaced
+// on configuration reload and apply to the next requests of open connections.
+type ApiKeyRules struct {
+	value atomic.Value // *apiKeyRules
+}
+
+type apiKeyRules struct {
+	forbidden map[int16]struct{}
+	scheduled []scheduledApiKeys
+}
+
+func NewApiKeyRules(forbiddenApiKeys []int, scheduledApiKeys config.ScheduledApiKeysList) *ApiKeyRules {
+	rules := &ApiKeyRules{}
+	rules.Update(forbiddenApiKeys, scheduledApiKeys)
+	return rules
+}
+
+// Update replaces the rules atomically
+func (r *ApiKeyRules) Update(forbiddenApiKeys []int, scheduledApiKeys config.ScheduledApiKeysList) {
+	forbidden := make(map[int16]struct{})
+	for _, apiKey := range forbiddenApiKeys {
+		forbidden[int16(apiKey)] = struct{}{}
+	}
+	r.value.Store(&apiKeyRules{forbidden: forbidden, scheduled: newScheduledApiKeys(scheduledApiKeys)})
+}
+
+// check returns an error if the api key is forbidden at the time
+func (r *ApiKeyRules) check(apiKey int16, t time.Time) error {
+	if r == nil {
+		return nil
+	}
+	rules := r.value.Load().(*apiKeyRules)
+	if _, ok := rules.forbidden[apiKey]; ok {
+		return fmt.Errorf("api key %d is forbidden", apiKey)
+	}
+	if isScheduledForbidden(rules.scheduled, apiKey, t) {
+		return fmt.Errorf("api key %d is forbidden by schedule", apiKey)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiKeyRules(t *testing.T) {
+	a := assert.New(t)
+
+	var scheduled config.ScheduledApiKeysList
+	a.Nil(scheduled.Set("Mon-Fri 09:00-17:00 UTC=20"))
+	monday := time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)
+	sunday := time.Date(2024, 1, 7, 10, 0, 0, 0, time.UTC)
+
+	rules := NewApiKeyRules([]int{19}, scheduled)
+	a.EqualError(rules.check(19, sunday), "api key 19 is forbidden")
+	a.EqualError(rules.check(20, monday), "api key 20 is forbidden by schedule")
+	a.Nil(rules.check(20, sunday))
+	a.Nil(rules.check(0, monday))
+
+	rules.Update([]int{0}, nil)
+	a.EqualError(rules.check(0, monday), "api key 0 is forbidden")
+	a.Nil(rules.check(19, monday))
+	a.Nil(rules.check(20, monday))
+
+	var disabled *ApiKeyRules
+	a.Nil(disabled.check(19, monday))
+}
@@ -1,17 +1,27 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"github.com/grepplabs/kafka-proxy/pkg/apis"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"io"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	issuerUnknown = "unknown"
+	issuerOther   = "other"
+)
+
 type AuthClient struct {
 	enabled bool
 	magic   uint64
@@ -29,15 +39,19 @@ func (b *AuthClient) sendAndReceiveGatewayAuth(conn DeadlineReaderWriter) error
 
 	resp, err := b.tokenProvider.GetToken(context.Background(), apis.TokenRequest{})
 	if err != nil {
+		proxyGatewayClientAuthTotal.WithLabelValues("error", "", issuerUnknown).Inc()
 		return err
 	}
 	if !resp.Success {
+		proxyGatewayClientAuthTotal.WithLabelValues("false", strconv.Itoa(int(resp.Status)), issuerUnknown).Inc()
 		return fmt.Errorf("get token failed with status: %d", resp.Status)
 	}
 	if resp.Token == "" {
+		proxyGatewayClientAuthTotal.WithLabelValues("false", strconv.Itoa(int(resp.Status)), issuerUnknown).Inc()
 		return errors.New("get token returned empty token")
 	}
 	data := resp.Token
+	issuer := gatewayIssuers.label(data, true)
 
 	length := len(b.method) + 1 + len(data)
 	// 8 - bytes magic, 4 bytes length
@@ -61,13 +75,23 @@ func (b *AuthClient) sendAndReceiveGatewayAuth(conn DeadlineReaderWriter) error
 	// Otherwise, the broker closes the connection and we get an EOF
 	if err != nil {
 		if err == io.EOF {
+			proxyGatewayClientAuthTotal.WithLabelValues("false", "rejected", issuer).Inc()
 			return errors.New("Gateway auth failed")
 		}
 		return errors.Wrap(err, "Failed to read response while gateway authenticating")
 	}
+	proxyGatewayClientAuthTotal.WithLabelValues("true", strconv.Itoa(int(resp.Status)), issuer).Inc()
 	return nil
 }
 
+type errGatewayAuthFailed struct {
+	status int32
+}
+
+func (e errGatewayAuthFailed) Error() string {
+	return fmt.Sprintf("gateway server verify token failed with status: %d", e.status)
+}
+
 type AuthServer struct {
 	enabled bool
 	magic   uint64
@@ -75,23 +99,27 @@ type AuthServer struct {
 	timeout time.Duration
 
 	tokenInfo apis.TokenInfo
+	// claim of the verified token used as principal instead of the subject
+	principalClaim string
 }
 
+// receiveAndSendGatewayAuth verifies the gateway token and returns the principal provided by the token info plugin or empty if the plugin does not provide it,
+// and the expiry of the token or zero if it is unknown
 //TODO: reset deadlines after method - ok
-func (b *AuthServer) receiveAndSendGatewayAuth(conn DeadlineReaderWriter) error {
-	err := conn.SetDeadline(time.Now().Add(b.timeout))
+func (b *AuthServer) receiveAndSendGatewayAuth(conn DeadlineReaderWriter) (principal string, expiry time.Time, err error) {
+	err = conn.SetDeadline(time.Now().Add(b.timeout))
 	if err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 	headerBuf := make([]byte, 12) // magic 8 + length 4
 	_, err = io.ReadFull(conn, headerBuf)
 	if err != nil {
-		return errors.Wrap(err, "Failed to read gateway bytes magic")
+		return "", time.Time{}, errors.Wrap(err, "Failed to read gateway bytes magic")
 	}
 
 	magic := binary.BigEndian.Uint64(headerBuf[:8])
 	if magic != b.magic {
-		return errors.New("gateway handshake magic bytes mismatch")
+		return "", time.Time{}, errors.New("gateway handshake magic bytes mismatch")
 	}
 
 	length := binary.BigEndian.Uint32(headerBuf[8:])
@@ -99,14 +127,14 @@ func (b *AuthServer) receiveAndSendGatewayAuth(conn DeadlineReaderWriter) error
 	payload := make([]byte, length)
 	_, err = io.ReadFull(conn, payload)
 	if err != nil {
-		return errors.Wrap(err, "failed to read gateway handshake payload")
+		return "", time.Time{}, errors.Wrap(err, "failed to read gateway handshake payload")
 	}
 	tokens := strings.Split(string(payload), "\x00")
 	if len(tokens) != 2 {
-		return fmt.Errorf("invalid gateway handshake: expected 2 tokens, got %d", len(tokens))
+		return "", time.Time{}, fmt.Errorf("invalid gateway handshake: expected 2 tokens, got %d", len(tokens))
 	}
 	if tokens[0] != b.method {
-		return fmt.Errorf("gateway handshake method mismatch: expected %s , got %s", b.method, tokens[0])
+		return "", time.Time{}, fmt.Errorf("gateway handshake method mismatch: expected %s , got %s", b.method, tokens[0])
 	}
 	data := tokens[1]
 
@@ -115,17 +143,109 @@ func (b *AuthServer) receiveAndSendGatewayAuth(conn DeadlineReaderWriter) error
 	//	defer cancel()
 	resp, err := b.tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: data})
 	if err != nil {
-		return err
+		proxyGatewayServerAuthTotal.WithLabelValues("error", "", gatewayIssuers.label(data, false)).Inc()
+		return "", time.Time{}, err
 	}
+	proxyGatewayServerAuthTotal.WithLabelValues(strconv.FormatBool(resp.Success), strconv.Itoa(int(resp.Status)), gatewayIssuers.label(data, resp.Success)).Inc()
 	if !resp.Success {
-		return fmt.Errorf("gateway server verify token failed with status: %d", resp.Status)
+		return "", time.Time{}, errGatewayAuthFailed{status: resp.Status}
 	}
 
 	logrus.Debugf("gateway handshake payload: %s", data)
 
 	header := make([]byte, 4)
 	if _, err := conn.Write(header); err != nil {
-		return err
+		return "", time.Time{}, err
 	}
-	return nil
+	expiry, _ = tokenExpiry(data)
+	return verifiedPrincipal(resp, b.principalClaim), expiry, nil
+}
+
+// verifiedPrincipal returns the value of the principal claim or the subject returned by the token info plugin.
+// Empty is returned if the plugin provides neither of them.
+func verifiedPrincipal(resp apis.VerifyResponse, principalClaim string) string {
+	if principalClaim != "" {
+		if value := resp.Claims[principalClaim]; value != "" {
+			return value
+		}
+	}
+	return resp.Subject
+}
+
+var gatewayIssuers = newIssuerLabels()
+
+// issuerLabels limits the cardinality of the issuer metric label.
+// Issuers are taken from unverified tokens, so an issuer is reported as is only after a token from it was accepted.
+type issuerLabels struct {
+	known map[string]struct{}
+	l     sync.RWMutex
+}
+
+func newIssuerLabels() *issuerLabels {
+	return &issuerLabels{known: make(map[string]struct{})}
+}
+
+func (p *issuerLabels) label(token string, trusted bool) string {
+	issuer := parseTokenClaims(token).Iss
+	if issuer == "" {
+		return issuerUnknown
+	}
+	if trusted {
+		p.l.Lock()
+		p.known[issuer] = struct{}{}
+		p.l.Unlock()
+		return issuer
+	}
+	p.l.RLock()
+	_, ok := p.known[issuer]
+	p.l.RUnlock()
+	if ok {
+		return issuer
+	}
+	return issuerOther
+}
+
+type tokenClaims struct {
+	Iss string `json:"iss"`
+	Sub string `json:"sub"`
+}
+
+// parseTokenClaims returns claims of a JWT without verifying it. Empty claims are returned if the token is not a JWT
+func parseTokenClaims(token string) tokenClaims {
+	claims := tokenClaims{}
+	args := strings.Split(token, ".")
+	if len(args) < 2 {
+		return claims
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(args[1])
+	if err != nil {
+		return claims
+	}
+	if err = json.NewDecoder(bytes.NewBuffer(payload)).Decode(&claims); err != nil {
+		return tokenClaims{}
+	}
+	return claims
+}
+
+// tokenExpiry returns the exp claim of a JWT without verifying it. False is returned if the token is not a JWT or has no numeric exp claim
+func tokenExpiry(token string) (time.Time, bool) {
+	args := strings.Split(token, ".")
+	if len(args) < 2 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(args[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	claims := struct {
+		Exp interface{} `json:"exp"`
+	}{}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, false
+	}
+	exp, ok := claims.Exp.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(exp), 0), true
 }
@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/sirupsen/logrus"
+)
+
+// AuthCache caches the decisions of the password authenticator and token info plugins, so remote plugins are not called
+// for every new connection. Successful decisions are cached for the ttl but not beyond the token expiry, failed decisions
+// for the negative ttl. Plugin errors, e.g. of a supervised plugin which is down, are not cached. Entries are keyed by the SHA-256 hash of the credentials.
+// A nil AuthCache caches nothing.
+type AuthCache struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+
+	lock    sync.Mutex
+	entries map[[sha256.Size]byte]*authCacheEntry
+	// principals of the invalidation in the logs
+	pseudonymizer *Pseudonymizer
+
+	nowFn func() time.Time
+}
+
+type authCacheEntry struct {
+	expires time.Time
+	// user name or verified token principal used by the invalidation
+	principal string
+
+	ok       bool
+	status   int32
+	response apis.VerifyResponse
+}
+
+func NewAuthCache(ttl time.Duration, negativeTTL time.Duration, maxEntries int) *AuthCache {
+	if (ttl <= 0 && negativeTTL <= 0) || maxEntries <= 0 {
+		return nil
+	}
+	return &AuthCache{
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		maxEntries:  maxEntries,
+		entries:     make(map[[sha256.Size]byte]*authCacheEntry),
+		nowFn:       time.Now,
+	}
+}
+
+func (c *AuthCache) enabled() bool {
+	return c != nil
+}
+
+// PasswordAuthenticator returns the authenticator with cached decisions. The scope separates the decisions of different plugins.
+func (c *AuthCache) PasswordAuthenticator(scope string, authenticator apis.PasswordAuthenticator) apis.PasswordAuthenticator {
+	if !c.enabled() || authenticator == nil {
+		return authenticator
+	}
+	return &cachedPasswordAuthenticator{cache: c, scope: scope, delegate: authenticator}
+}
+
+// TokenInfo returns the token info with cached decisions. The scope separates the decisions of different plugins.
+func (c *AuthCache) TokenInfo(scope string, tokenInfo apis.TokenInfo) apis.TokenInfo {
+	if !c.enabled() || tokenInfo == nil {
+		return tokenInfo
+	}
+	return &cachedTokenInfo{cache: c, scope: scope, delegate: tokenInfo}
+}
+
+// Update replaces the settings of the cache enabled on startup, the cached decisions are invalidated when the settings change.
+// With a zero ttl or max entries no decisions are cached until the next update.
+func (c *AuthCache) Update(ttl time.Duration, negativeTTL time.Duration, maxEntries int) {
+	if !c.enabled() {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ttl == ttl && c.negativeTTL == negativeTTL && c.maxEntries == maxEntries {
+		return
+	}
+	c.ttl, c.negativeTTL, c.maxEntries = ttl, negativeTTL, maxEntries
+	c.entries = make(map[[sha256.Size]byte]*authCacheEntry)
+	logrus.Infof("Auth cache settings changed, cached auth decisions invalidated")
+}
+
+// Invalidate removes the decisions of the principal or all decisions if the principal is empty and returns the number of removed decisions
+func (c *AuthCache) Invalidate(principal string) int {
+	if !c.enabled() {
+		return 0
+	}
+	c.lock.Lock()
+	removed := 0
+	for key, entry := range c.entries {
+		if principal == "" || entry.principal == principal {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	c.lock.Unlock()
+
+	if principal == "" {
+		logrus.Infof("All %d cached auth decisions invalidated", removed)
+	} else {
+		logrus.Infof("%d cached auth decisions of principal %s invalidated", removed, c.pseudonymizer.principal(principal))
+	}
+	return removed
+}
+
+func authCacheKey(scope string, credentials ...string) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(scope))
+	for _, v := range credentials {
+		h.Write([]byte{0})
+		h.Write([]byte(v))
+	}
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+func (c *AuthCache) get(scope string, key [sha256.Size]byte) (*authCacheEntry, bool) {
+	c.lock.Lock()
+	entry, ok := c.entries[key]
+	if ok && !c.nowFn().Before(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.lock.Unlock()
+	if ok {
+		proxyAuthCacheLookupsTotal.WithLabelValues(scope, "hit").Inc()
+	} else {
+		proxyAuthCacheLookupsTotal.WithLabelValues(scope, "miss").Inc()
+	}
+	return entry, ok
+}
+
+// put caches the decision for the ttl or the negative ttl, limited by the expiry if not zero
+func (c *AuthCache) put(key [sha256.Size]byte, entry *authCacheEntry, expiry time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ttl := c.ttl
+	if !entry.ok {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 || c.maxEntries <= 0 {
+		return
+	}
+	now := c.nowFn()
+	entry.expires = now.Add(ttl)
+	if !expiry.IsZero() && expiry.Before(entry.expires) {
+		entry.expires = expiry
+	}
+	if !now.Before(entry.expires) {
+		return
+	}
+	if len(c.entries) >= c.maxEntries {
+		for k, v := range c.entries {
+			if !now.Before(v.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		logrus.Debugf("Auth cache is full with %d entries, evicting an entry", len(c.entries))
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = entry
+}
+
+type cachedPasswordAuthenticator struct {
+	cache    *AuthCache
+	scope    string
+	delegate apis.PasswordAuthenticator
+}
+
+func (a *cachedPasswordAuthenticator) Authenticate(username, password string) (bool, int32, error) {
+	key := authCacheKey(a.scope, username, password)
+	if entry, ok := a.cache.get(a.scope, key); ok {
+		return entry.ok, entry.status, nil
+	}
+	ok, status, err := a.delegate.Authenticate(username, password)
+	if err != nil {
+		return ok, status, err
+	}
+	a.cache.put(key, &authCacheEntry{principal: username, ok: ok, status: status}, time.Time{})
+	return ok, status, nil
+}
+
+type cachedTokenInfo struct {
+	cache    *AuthCache
+	scope    string
+	delegate apis.TokenInfo
+}
+
+func (t *cachedTokenInfo) VerifyToken(ctx context.Context, request apis.VerifyRequest) (apis.VerifyResponse, error) {
+	credentials := append([]string{request.Token}, request.Params...)
+	key := authCacheKey(t.scope, credentials...)
+	if entry, ok := t.cache.get(t.scope, key); ok {
+		return entry.response, nil
+	}
+	resp, err := t.delegate.VerifyToken(ctx, request)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Success && resp.Subject == "" {
+		// without verified subject the decision could not be invalidated by principal
+		return resp, nil
+	}
+	expiry, _ := tokenExpiry(request.Token)
+	t.cache.put(key, &authCacheEntry{principal: resp.Subject, ok: resp.Success, response: resp}, expiry)
+	return resp, nil
+}
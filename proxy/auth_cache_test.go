@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/supervisor"
+	"github.com/hashicorp/go-plugin"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingPasswordAuthenticator struct {
+	calls int
+	err   error
+}
+
+func (a *countingPasswordAuthenticator) Authenticate(username, password string) (bool, int32, error) {
+	a.calls++
+	if a.err != nil {
+		return false, 1, a.err
+	}
+	if password == "secret" {
+		return true, 0, nil
+	}
+	return false, 3, nil
+}
+
+type countingTokenInfo struct {
+	calls int
+	// the plugin returns no subject
+	noSubject bool
+}
+
+func (t *countingTokenInfo) VerifyToken(ctx context.Context, request apis.VerifyRequest) (apis.VerifyResponse, error) {
+	t.calls++
+	resp := apis.VerifyResponse{Success: true, Claims: map[string]string{"tenant": "a"}}
+	if !t.noSubject {
+		resp.Subject = parseTokenClaims(request.Token).Sub
+	}
+	return resp, nil
+}
+
+func unsignedToken(payload string) string {
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + "."
+}
+
+func TestAuthCachePasswordAuthenticator(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	cache := NewAuthCache(time.Minute, 10*time.Second, 10)
+	cache.nowFn = func() time.Time { return now }
+	plugin := &countingPasswordAuthenticator{}
+	authenticator := cache.PasswordAuthenticator("local", plugin)
+
+	for i := 0; i < 2; i++ {
+		ok, status, err := authenticator.Authenticate("alice", "secret")
+		a.Nil(err)
+		a.True(ok)
+		a.Equal(int32(0), status)
+
+		ok, status, err = authenticator.Authenticate("alice", "wrong")
+		a.Nil(err)
+		a.False(ok)
+		a.Equal(int32(3), status)
+	}
+	a.Equal(2, plugin.calls)
+
+	// negative ttl expired
+	now = now.Add(30 * time.Second)
+	_, _, _ = authenticator.Authenticate("alice", "secret")
+	_, _, _ = authenticator.Authenticate("alice", "wrong")
+	a.Equal(3, plugin.calls)
+
+	// other plugins do not share the decisions
+	_, _, _ = cache.PasswordAuthenticator("plugin:ldap", plugin).Authenticate("alice", "secret")
+	a.Equal(4, plugin.calls)
+
+	// errors are not cached
+	failing := &countingPasswordAuthenticator{err: errors.New("ldap is down")}
+	authenticator = cache.PasswordAuthenticator("plugin:failing", failing)
+	for i := 0; i < 2; i++ {
+		_, _, err := authenticator.Authenticate("bob", "secret")
+		a.NotNil(err)
+	}
+	a.Equal(2, failing.calls)
+}
+
+func TestAuthCacheSupervisedPluginDown(t *testing.T) {
+	a := assert.New(t)
+
+	ldap := &countingPasswordAuthenticator{}
+	supervised, err := supervisor.New("ldap", func() (*plugin.Client, interface{}, error) {
+		return nil, ldap, nil
+	}, supervisor.Options{FailPolicy: supervisor.FailOpen, MaxFailOpen: time.Hour})
+	a.Nil(err)
+	delegate, _ := supervised.PasswordAuthenticator()
+
+	cache := NewAuthCache(time.Hour, time.Hour, 10)
+	authenticator := cache.PasswordAuthenticator("plugin:ldap", delegate)
+	// the plugin is down
+	supervised.Close()
+	for i := 0; i < 2; i++ {
+		ok, _, err := authenticator.Authenticate("alice", "any")
+		a.Equal(supervisor.ErrPluginUnavailable, err)
+		a.False(ok)
+	}
+	a.Empty(cache.entries)
+}
+
+func TestAuthCacheTokenInfo(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	cache := NewAuthCache(time.Hour, 0, 10)
+	cache.nowFn = func() time.Time { return now }
+	plugin := &countingTokenInfo{}
+	tokenInfo := cache.TokenInfo("gateway", plugin)
+
+	token := unsignedToken(fmt.Sprintf(`{"sub":"alice","exp":%d}`, now.Add(time.Minute).Unix()))
+	for i := 0; i < 2; i++ {
+		resp, err := tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: token})
+		a.Nil(err)
+		a.Equal(apis.VerifyResponse{Success: true, Subject: "alice", Claims: map[string]string{"tenant": "a"}}, resp)
+	}
+	a.Equal(1, plugin.calls)
+
+	// token expired before the ttl
+	now = now.Add(2 * time.Minute)
+	_, _ = tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: token})
+	a.Equal(2, plugin.calls)
+	// expired tokens are not cached
+	_, _ = tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: token})
+	a.Equal(3, plugin.calls)
+
+	other := unsignedToken(`{"sub":"bob"}`)
+	_, _ = tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: other})
+	_, _ = tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: other})
+	a.Equal(4, plugin.calls)
+
+	a.Equal(0, cache.Invalidate("alice"))
+	a.Equal(1, cache.Invalidate("bob"))
+	_, _ = tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: other})
+	a.Equal(5, plugin.calls)
+	a.Equal(1, cache.Invalidate(""))
+
+	// successful decisions without verified subject are not cached
+	plugin.noSubject = true
+	_, _ = tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: other})
+	_, _ = tokenInfo.VerifyToken(context.Background(), apis.VerifyRequest{Token: other})
+	a.Equal(7, plugin.calls)
+}
+
+func TestAuthCacheMaxEntries(t *testing.T) {
+	a := assert.New(t)
+
+	cache := NewAuthCache(time.Minute, 0, 2)
+	authenticator := cache.PasswordAuthenticator("local", &countingPasswordAuthenticator{})
+	for _, user := range []string{"alice", "bob", "carol"} {
+		_, _, _ = authenticator.Authenticate(user, "secret")
+	}
+	a.Len(cache.entries, 2)
+}
+
+func TestAuthCacheUpdate(t *testing.T) {
+	a := assert.New(t)
+
+	cache := NewAuthCache(time.Minute, 0, 10)
+	plugin := &countingPasswordAuthenticator{}
+	authenticator := cache.PasswordAuthenticator("local", plugin)
+	_, _, _ = authenticator.Authenticate("alice", "secret")
+	_, _, _ = authenticator.Authenticate("alice", "secret")
+	a.Equal(1, plugin.calls)
+
+	cache.Update(time.Minute, 0, 10)
+	_, _, _ = authenticator.Authenticate("alice", "secret")
+	a.Equal(1, plugin.calls, "unchanged settings keep the decisions")
+
+	cache.Update(2*time.Minute, 0, 10)
+	a.Empty(cache.entries)
+	_, _, _ = authenticator.Authenticate("alice", "secret")
+	a.Equal(2, plugin.calls)
+
+	cache.Update(2*time.Minute, 0, 0)
+	_, _, _ = authenticator.Authenticate("alice", "secret")
+	_, _, _ = authenticator.Authenticate("alice", "secret")
+	a.Equal(4, plugin.calls, "nothing is cached without entries")
+	a.Empty(cache.entries)
+
+	var disabled *AuthCache
+	disabled.Update(time.Minute, 0, 10)
+}
+
+func TestAuthCacheDisabled(t *testing.T) {
+	a := assert.New(t)
+
+	cache := NewAuthCache(0, 0, 10)
+	a.Nil(cache)
+	a.False(cache.enabled())
+	plugin := &countingPasswordAuthenticator{}
+	a.True(plugin == cache.PasswordAuthenticator("local", plugin))
+	a.Nil(cache.TokenInfo("local", nil))
+	a.Nil(NewAuthCache(time.Minute, 0, 10).PasswordAuthenticator("local", nil))
+	a.Equal(0, cache.Invalidate(""))
+}
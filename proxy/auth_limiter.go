@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AuthLimiter protects the gateway and local authentication against brute-force attacks. A client address with maxFailures
+// failed authentications within the window is banned, new connections from it are rejected until the ban expires.
+// Failures are kept until the window expires, successful authentications e.g. with own credentials between guesses do not reset them.
+// A nil AuthLimiter or one without max failures never bans. The settings are replaced on configuration reload.
+type AuthLimiter struct {
+	maxFailures int
+	window      time.Duration
+	banDuration time.Duration
+
+	pseudonymizer *Pseudonymizer
+
+	lock      sync.Mutex
+	failures  map[string][]time.Time
+	bans      map[string]time.Time
+	nextSweep time.Time
+
+	nowFn func() time.Time
+}
+
+func NewAuthLimiter(maxFailures int, window time.Duration, banDuration time.Duration, pseudonymizer *Pseudonymizer) *AuthLimiter {
+	return &AuthLimiter{
+		maxFailures:   maxFailures,
+		window:        window,
+		banDuration:   banDuration,
+		pseudonymizer: pseudonymizer,
+		failures:      make(map[string][]time.Time),
+		bans:          make(map[string]time.Time),
+		nowFn:         time.Now,
+	}
+}
+
+// Update replaces the settings, the recorded failures are forgotten and the bans are lifted when banning is disabled
+func (l *AuthLimiter) Update(maxFailures int, window time.Duration, banDuration time.Duration) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.maxFailures == maxFailures && l.window == window && l.banDuration == banDuration {
+		return
+	}
+	l.maxFailures, l.window, l.banDuration = maxFailures, window, banDuration
+	l.failures = make(map[string][]time.Time)
+	if maxFailures <= 0 {
+		l.bans = make(map[string]time.Time)
+	}
+	l.nextSweep = time.Time{}
+}
+
+// banned returns true if new connections from the client address are rejected
+func (l *AuthLimiter) banned(addr net.Addr) bool {
+	host := clientHost(addr)
+	if l == nil || host == "" {
+		return false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	until, ok := l.bans[host]
+	if !ok {
+		return false
+	}
+	if l.nowFn().Before(until) {
+		return true
+	}
+	delete(l.bans, host)
+	return false
+}
+
+// failed records a failed authentication of the client address and bans it when the failures exceed the limit
+func (l *AuthLimiter) failed(addr net.Addr, brokerAddress string) {
+	host := clientHost(addr)
+	if l == nil || host == "" {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.maxFailures <= 0 {
+		return
+	}
+	now := l.nowFn()
+	l.sweep(now)
+
+	failures := append(recentFailures(l.failures[host], now.Add(-l.window)), now)
+	if len(failures) < l.maxFailures {
+		l.failures[host] = failures
+		return
+	}
+	delete(l.failures, host)
+	l.bans[host] = now.Add(l.banDuration)
+	proxyAuthBansTotal.WithLabelValues(brokerAddress).Inc()
+	subsystemLog(logSubsystemAuth).WithFields(logrus.Fields{
+		"broker":         brokerAddress,
+		"client_address": l.pseudonymizer.pseudonymize("address", host),
+		"failures":       len(failures),
+		"window":         l.window.String(),
+		"ban_duration":   l.banDuration.String(),
+	}).Warnf("Client address banned after failed authentications")
+}
+
+// sweep removes the expired failures and bans, so addresses seen only once do not accumulate
+func (l *AuthLimiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	l.nextSweep = now.Add(l.window)
+	for host, failures := range l.failures {
+		if failures = recentFailures(failures, now.Add(-l.window)); len(failures) == 0 {
+			delete(l.failures, host)
+		} else {
+			l.failures[host] = failures
+		}
+	}
+	for host, until := range l.bans {
+		if !now.Before(until) {
+			delete(l.bans, host)
+		}
+	}
+}
+
+func recentFailures(failures []time.Time, since time.Time) []time.Time {
+	for i, failure := range failures {
+		if failure.After(since) {
+			return failures[i:]
+		}
+	}
+	return nil
+}
+
+// clientHost returns the host of the client address, the port differs per connection
+func clientHost(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// remoteAddr returns the remote address of the client connection or nil if it is unknown
+func remoteAddr(conn interface{}) net.Addr {
+	if c, ok := conn.(interface{ RemoteAddr() net.Addr }); ok {
+		return c.RemoteAddr()
+	}
+	return nil
+}
+
+// isAuthFailure returns true if the client presented credentials or a token which were rejected
+func isAuthFailure(err error) bool {
+	switch err.(type) {
+	case errLocalAuthFailed, errLocalOauthFailed, errGatewayAuthFailed:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthLimiterBansAfterMaxFailures(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	limiter := NewAuthLimiter(3, time.Minute, 5*time.Minute, nil)
+	limiter.nowFn = func() time.Time { return now }
+
+	client := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40001}
+	otherPort := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40002}
+	other := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 40001}
+
+	limiter.failed(client, "kafka-0:9092")
+	limiter.failed(otherPort, "kafka-0:9092")
+	a.False(limiter.banned(client))
+
+	limiter.failed(client, "kafka-1:9092")
+	a.True(limiter.banned(client))
+	a.True(limiter.banned(otherPort), "the ban applies to all connections from the host")
+	a.False(limiter.banned(other))
+
+	now = now.Add(5 * time.Minute)
+	a.False(limiter.banned(client), "the ban expired")
+	a.Empty(limiter.bans)
+}
+
+func TestAuthLimiterWindow(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	limiter := NewAuthLimiter(2, time.Minute, 5*time.Minute, nil)
+	limiter.nowFn = func() time.Time { return now }
+	client := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40001}
+
+	limiter.failed(client, "kafka-0:9092")
+	now = now.Add(61 * time.Second)
+	limiter.failed(client, "kafka-0:9092")
+	a.False(limiter.banned(client), "the first failure is outside of the window")
+
+	// successful authentications in between do not reset the failures
+	limiter.failed(client, "kafka-0:9092")
+	a.True(limiter.banned(client))
+}
+
+func TestAuthLimiterSweep(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	limiter := NewAuthLimiter(5, time.Minute, 5*time.Minute, nil)
+	limiter.nowFn = func() time.Time { return now }
+
+	limiter.failed(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40001}, "kafka-0:9092")
+	a.Len(limiter.failures, 1)
+
+	now = now.Add(2 * time.Minute)
+	limiter.failed(&net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 40001}, "kafka-0:9092")
+	a.Len(limiter.failures, 1, "expired failures of other addresses are removed")
+}
+
+func TestAuthLimiterNil(t *testing.T) {
+	var limiter *AuthLimiter
+	client := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40001}
+	limiter.failed(client, "kafka-0:9092")
+	assert.False(t, limiter.banned(client))
+	limiter.Update(1, time.Minute, time.Minute)
+}
+
+func TestAuthLimiterUpdate(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	limiter := NewAuthLimiter(0, time.Minute, 5*time.Minute, nil)
+	limiter.nowFn = func() time.Time { return now }
+	client := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40001}
+
+	limiter.failed(client, "kafka-0:9092")
+	a.False(limiter.banned(client), "banning is disabled")
+
+	limiter.Update(1, time.Minute, 5*time.Minute)
+	limiter.failed(client, "kafka-0:9092")
+	a.True(limiter.banned(client))
+
+	limiter.Update(1, time.Minute, 5*time.Minute)
+	a.True(limiter.banned(client), "unchanged settings keep the bans")
+
+	limiter.Update(0, time.Minute, 5*time.Minute)
+	a.False(limiter.banned(client), "disabling lifts the bans")
+	limiter.failed(client, "kafka-0:9092")
+	a.False(limiter.banned(client))
+}
+
+func TestIsAuthFailure(t *testing.T) {
+	a := assert.New(t)
+	a.True(isAuthFailure(errLocalAuthFailed{user: "alice"}))
+	a.True(isAuthFailure(errLocalOauthFailed{status: 4}))
+	a.True(isAuthFailure(errGatewayAuthFailed{status: 8}))
+	a.False(isAuthFailure(errors.New("gateway handshake magic bytes mismatch")))
+}
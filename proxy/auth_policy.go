@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// LocalAuthenticator is the authenticator of a named auth plugin, the password authenticator for PLAIN and the token authenticator for OAUTHBEARER
+type LocalAuthenticator struct {
+	PasswordAuthenticator apis.PasswordAuthenticator
+	TokenAuthenticator    apis.TokenInfo
+}
+
+// listenerAuth selects the authentication of the proxy clients by the auth policy of the listener
+type listenerAuth struct {
+	policies config.ListenerAuthPolicies
+	// by auth plugin name, default and disabled
+	localSasl   map[string]*LocalSasl
+	authServers map[string]*AuthServer
+}
+
+func newListenerAuth(c *config.Config, localSasl *LocalSasl, authServer *AuthServer, authPlugins map[string]LocalAuthenticator, pseudonymizer *Pseudonymizer) (*listenerAuth, error) {
+	if len(c.Auth.ListenerPolicies) == 0 {
+		return nil, nil
+	}
+	disabledAuthServer, enabledAuthServer := *authServer, *authServer
+	disabledAuthServer.enabled = false
+	enabledAuthServer.enabled = true
+
+	a := &listenerAuth{
+		policies: c.Auth.ListenerPolicies,
+		localSasl: map[string]*LocalSasl{
+			config.AuthPolicyDefault:  localSasl,
+			config.AuthPolicyDisabled: NewLocalSasl(LocalSaslParams{enabled: false}),
+		},
+		authServers: map[string]*AuthServer{
+			config.AuthPolicyDefault:  authServer,
+			config.AuthPolicyDisabled: &disabledAuthServer,
+			config.AuthPolicyEnabled:  &enabledAuthServer,
+		},
+	}
+	for name, plugin := range c.Auth.Plugins {
+		authenticator, ok := authPlugins[name]
+		if !ok || (authenticator.PasswordAuthenticator == nil && authenticator.TokenAuthenticator == nil) {
+			return nil, errors.Errorf("authenticator of auth plugin %s is missing", name)
+		}
+		a.localSasl[name] = NewLocalSasl(LocalSaslParams{
+			enabled:               true,
+			timeout:               c.Auth.Local.Timeout,
+			passwordAuthenticator: authenticator.PasswordAuthenticator,
+			tokenAuthenticator:    authenticator.TokenAuthenticator,
+			pseudonymizer:         pseudonymizer,
+			principalClaim:        c.Auth.Local.PrincipalClaim,
+		})
+		logrus.Infof("Auth plugin %s authenticates %s", name, plugin.Mechanism)
+	}
+	for address, policy := range c.Auth.ListenerPolicies {
+		if _, ok := a.localSasl[policy.LocalAuth]; !ok {
+			return nil, errors.Errorf("auth policy of listener %s references unknown auth plugin %s", address, policy.LocalAuth)
+		}
+		logrus.Infof("Proxy clients of listener %s will be authenticated with policy %s", address, policy)
+	}
+	return a, nil
+}
+
+// apply sets the local and gateway authentication of the listener policy
+func (a *listenerAuth) apply(cfg *ProcessorConfig, listenerAddress string, brokerAddress string) {
+	if a == nil {
+		return
+	}
+	policy, ok := a.policies.Policy(listenerAddress, brokerAddress)
+	if !ok {
+		return
+	}
+	cfg.LocalSasl = a.localSasl[policy.LocalAuth]
+	cfg.AuthServer = a.authServers[policy.GatewayAuth]
+}
+
+// clientCertTLSConfig returns the listener TLS config with the client certificate requirement of the auth policy
+func clientCertTLSConfig(tlsConfig *tls.Config, clientCert string) *tls.Config {
+	var clientAuth tls.ClientAuthType
+	switch clientCert {
+	case config.ClientCertRequired:
+		clientAuth = tls.RequireAndVerifyClientCert
+	case config.ClientCertOptional:
+		clientAuth = tls.VerifyClientCertIfGiven
+	case config.ClientCertNone:
+		clientAuth = tls.NoClientCert
+	default:
+		return tlsConfig
+	}
+	return &tls.Config{
+		Certificates: tlsConfig.Certificates,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			current, err := tlsConfig.GetConfigForClient(hello)
+			if err != nil {
+				return nil, err
+			}
+			current = current.Clone()
+			current.ClientAuth = clientAuth
+			if verify := current.VerifyPeerCertificate; verify != nil && clientAuth != tls.RequireAndVerifyClientCert {
+				// the subject is validated only when a certificate is presented
+				current.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+					if len(rawCerts) == 0 {
+						return nil
+					}
+					return verify(rawCerts, verifiedChains)
+				}
+			}
+			return current, nil
+		},
+	}
+}
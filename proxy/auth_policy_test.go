@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerAuthApply(t *testing.T) {
+	a := assert.New(t)
+
+	c := config.NewConfig()
+	c.Auth.Local.Timeout = 10 * time.Second
+	c.Auth.Plugins = config.AuthPlugins{"partners": {Mechanism: "OAUTHBEARER", Command: "builtin:google-id-info"}}
+	a.Nil(c.Auth.ListenerPolicies.Set("0.0.0.0:32400=local-auth=disabled,client-cert=required"))
+	a.Nil(c.Auth.ListenerPolicies.Set("kafka-1:9092=local-auth=partners,gateway-auth=enabled"))
+
+	localSasl := NewLocalSasl(LocalSaslParams{enabled: false})
+	authServer := &AuthServer{enabled: false, magic: 1, method: "google-id"}
+
+	_, err := newListenerAuth(c, localSasl, authServer, nil, nil)
+	a.EqualError(err, "authenticator of auth plugin partners is missing")
+
+	auth, err := newListenerAuth(c, localSasl, authServer, map[string]LocalAuthenticator{"partners": {TokenAuthenticator: &testTokenInfo{}}}, nil)
+	a.Nil(err)
+
+	cfg := ProcessorConfig{LocalSasl: localSasl, AuthServer: authServer}
+	auth.apply(&cfg, "0.0.0.0:32400", "kafka-0:9092")
+	a.False(cfg.LocalSasl.enabled)
+	a.False(cfg.AuthServer.enabled)
+
+	cfg = ProcessorConfig{LocalSasl: localSasl, AuthServer: authServer}
+	auth.apply(&cfg, "0.0.0.0:32401", "kafka-1:9092")
+	a.True(cfg.LocalSasl.enabled)
+	a.Contains(cfg.LocalSasl.localAuthenticators, SASLOAuthBearer)
+	a.True(cfg.AuthServer.enabled)
+	a.Equal("google-id", cfg.AuthServer.method)
+
+	// listeners without a policy use the global authentication
+	cfg = ProcessorConfig{LocalSasl: localSasl, AuthServer: authServer}
+	auth.apply(&cfg, "0.0.0.0:32402", "kafka-2:9092")
+	a.Equal(localSasl, cfg.LocalSasl)
+	a.Equal(authServer, cfg.AuthServer)
+
+	var noAuth *listenerAuth
+	noAuth.apply(&cfg, "0.0.0.0:32400", "kafka-0:9092")
+	a.Equal(localSasl, cfg.LocalSasl)
+}
+
+func TestClientCertTLSConfig(t *testing.T) {
+	a := assert.New(t)
+
+	subjectErr := errors.New("invalid subject")
+	current := &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		VerifyPeerCertificate: func([][]byte, [][]*x509.Certificate) error {
+			return subjectErr
+		},
+	}
+	tlsConfig := &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return current, nil
+		},
+	}
+	a.Equal(tlsConfig, clientCertTLSConfig(tlsConfig, config.AuthPolicyDefault))
+
+	optional, err := clientCertTLSConfig(tlsConfig, config.ClientCertOptional).GetConfigForClient(nil)
+	a.Nil(err)
+	a.Equal(tls.VerifyClientCertIfGiven, optional.ClientAuth)
+	a.Nil(optional.VerifyPeerCertificate(nil, nil))
+	a.Equal(subjectErr, optional.VerifyPeerCertificate([][]byte{{1}}, nil))
+
+	none, err := clientCertTLSConfig(tlsConfig, config.ClientCertNone).GetConfigForClient(nil)
+	a.Nil(err)
+	a.Equal(tls.NoClientCert, none.ClientAuth)
+
+	required, err := clientCertTLSConfig(tlsConfig, config.ClientCertRequired).GetConfigForClient(nil)
+	a.Nil(err)
+	a.Equal(tls.RequireAndVerifyClientCert, required.ClientAuth)
+	a.Equal(subjectErr, required.VerifyPeerCertificate(nil, nil))
+
+	// the current config is not modified
+	a.Equal(tls.RequireAndVerifyClientCert, current.ClientAuth)
+}
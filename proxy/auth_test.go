@@ -39,7 +39,8 @@ func testAuthHandshake(a *assert.Assertions, mp func() (c1, c2 net.Conn, stop fu
 	}}
 
 	tokenInfo := &testTokenInfo{
-		token: "my-test-token",
+		token:   "my-test-token",
+		subject: "alice",
 	}
 
 	client := &AuthClient{
@@ -66,8 +67,10 @@ func testAuthHandshake(a *assert.Assertions, mp func() (c1, c2 net.Conn, stop fu
 		cerr := client.sendAndReceiveGatewayAuth(c1)
 		clientResult <- cerr
 	}()
-	serr := server.receiveAndSendGatewayAuth(c2)
+	principal, expiry, serr := server.receiveAndSendGatewayAuth(c2)
 	a.Nil(serr)
+	a.Equal("alice", principal)
+	a.True(expiry.IsZero())
 	cerr := <-clientResult
 	a.Nil(cerr)
 }
@@ -83,14 +86,16 @@ func (p *testTokenProvider) GetToken(ctx context.Context, request apis.TokenRequ
 }
 
 type testTokenInfo struct {
-	token string
-	err   error
+	token   string
+	subject string
+	claims  map[string]string
+	err     error
 }
 
 // Implements apis.TokenProvider.GetToken
 func (p *testTokenInfo) VerifyToken(ctx context.Context, request apis.VerifyRequest) (apis.VerifyResponse, error) {
 	if p.token == request.Token {
-		return apis.VerifyResponse{Success: true}, p.err
+		return apis.VerifyResponse{Success: true, Subject: p.subject, Claims: p.claims}, p.err
 	}
 	return apis.VerifyResponse{Success: false}, p.err
 }
@@ -105,3 +110,52 @@ func RandomUint64() (uint64, error) {
 
 	return binary.LittleEndian.Uint64(b[:]), nil
 }
+
+func TestGatewayIssuerLabels(t *testing.T) {
+	a := assert.New(t)
+
+	// {"alg":"none"}.{"iss":"https://accounts.google.com","sub":"alice"}
+	token := "eyJhbGciOiJub25lIn0.eyJpc3MiOiJodHRwczovL2FjY291bnRzLmdvb2dsZS5jb20iLCJzdWIiOiJhbGljZSJ9."
+	a.Equal(tokenClaims{Iss: "https://accounts.google.com", Sub: "alice"}, parseTokenClaims(token))
+	a.Equal(tokenClaims{}, parseTokenClaims("my-test-token"))
+
+	labels := newIssuerLabels()
+	a.Equal(issuerUnknown, labels.label("my-test-token", true))
+	a.Equal(issuerOther, labels.label(token, false))
+	a.Equal("https://accounts.google.com", labels.label(token, true))
+	a.Equal("https://accounts.google.com", labels.label(token, false))
+}
+
+func TestVerifiedPrincipal(t *testing.T) {
+	a := assert.New(t)
+
+	resp := apis.VerifyResponse{Success: true, Subject: "alice", Claims: map[string]string{"email": "alice@example.com"}}
+	a.Equal("alice", verifiedPrincipal(resp, ""))
+	a.Equal("alice@example.com", verifiedPrincipal(resp, "email"))
+	a.Equal("alice", verifiedPrincipal(resp, "preferred_username"))
+	a.Equal("", verifiedPrincipal(apis.VerifyResponse{Success: true}, "email"))
+}
+
+func TestLocalSaslOauthPrincipal(t *testing.T) {
+	a := assert.New(t)
+
+	// {"alg":"none"}.{"iss":"https://accounts.google.com","sub":"alice"}
+	token := "eyJhbGciOiJub25lIn0.eyJpc3MiOiJodHRwczovL2FjY291bnRzLmdvb2dsZS5jb20iLCJzdWIiOiJhbGljZSJ9."
+	saslAuthBytes := []byte("n,a=bob,\x01auth=Bearer " + token + "\x01\x01")
+
+	oauth := NewLocalSaslOauth(&testTokenInfo{token: token})
+	principal, expiry, err := oauth.doLocalAuth(saslAuthBytes)
+	a.Nil(err)
+	a.Equal("", principal, "authzid is not used when the plugin provides no subject")
+	a.True(expiry.IsZero(), "token has no exp claim")
+
+	oauth = NewLocalSaslOauth(&testTokenInfo{token: token, subject: "service-account-1", claims: map[string]string{"client_id": "billing"}})
+	principal, _, err = oauth.doLocalAuth(saslAuthBytes)
+	a.Nil(err)
+	a.Equal("service-account-1", principal)
+
+	oauth.principalClaim = "client_id"
+	principal, _, err = oauth.doLocalAuth(saslAuthBytes)
+	a.Nil(err)
+	a.Equal("billing", principal)
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/pkg/errors"
+)
+
+// AutoMapping generates the listener mappings of the brokers in the cluster metadata. The listener port of a broker
+// is the port offset plus the broker node id, the listener and advertised addresses are templates with the placeholders
+// $(node_id), $(listener_port), $(broker_host) and $(broker_port).
+type AutoMapping struct {
+	portOffset        int
+	listenerAddress   string
+	advertisedAddress string
+}
+
+func NewAutoMapping(portOffset int, listenerAddress string, advertisedAddress string) *AutoMapping {
+	return &AutoMapping{portOffset: portOffset, listenerAddress: listenerAddress, advertisedAddress: advertisedAddress}
+}
+
+// Mappings returns the listener mappings of the brokers ordered by node id
+func (m *AutoMapping) Mappings(brokers []protocol.MetadataBroker) ([]config.ListenerConfig, error) {
+	mappings := make([]config.ListenerConfig, 0, len(brokers))
+	for _, broker := range brokers {
+		listenerPort := m.portOffset + int(broker.NodeID)
+		if broker.NodeID < 0 || listenerPort > 65535 {
+			return nil, errors.Errorf("listener port %d of broker %d is out of range", listenerPort, broker.NodeID)
+		}
+		replacer := strings.NewReplacer(
+			"$(node_id)", strconv.Itoa(int(broker.NodeID)),
+			"$(listener_port)", strconv.Itoa(listenerPort),
+			"$(broker_host)", broker.Host,
+			"$(broker_port)", strconv.Itoa(int(broker.Port)),
+		)
+		listenerAddress := replacer.Replace(m.listenerAddress)
+		advertisedAddress := listenerAddress
+		if m.advertisedAddress != "" {
+			advertisedAddress = replacer.Replace(m.advertisedAddress)
+		}
+		for _, address := range []string{listenerAddress, advertisedAddress} {
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				return nil, errors.Wrapf(err, "mapping of broker %d", broker.NodeID)
+			}
+		}
+		mappings = append(mappings, config.ListenerConfig{
+			BrokerAddress:     net.JoinHostPort(broker.Host, strconv.Itoa(int(broker.Port))),
+			ListenerAddress:   listenerAddress,
+			AdvertisedAddress: advertisedAddress,
+		})
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].ListenerAddress < mappings[j].ListenerAddress })
+	return mappings, nil
+}
+
+// FetchBrokers returns the brokers from the metadata of the first reachable broker
+func (c *Client) FetchBrokers(brokerAddresses []string) ([]protocol.MetadataBroker, error) {
+	var lastErr error
+	for _, brokerAddress := range brokerAddresses {
+		brokers, err := c.fetchBrokers(brokerAddress)
+		if err == nil {
+			return brokers, nil
+		}
+		lastErr = errors.Wrapf(err, "metadata of broker %s", brokerAddress)
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no broker addresses")
+	}
+	return nil, lastErr
+}
+
+func (c *Client) fetchBrokers(brokerAddress string) ([]protocol.MetadataBroker, error) {
+	conn, err := c.retryDial(brokerAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := &protocol.Request{
+		ClientID: c.config.Kafka.ClientID,
+		Body:     &protocol.MetadataRequestV1{Topics: []string{}},
+	}
+	reqBuf, err := protocol.Encode(req)
+	if err != nil {
+		return nil, err
+	}
+	sizeBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(sizeBuf, uint32(len(reqBuf)))
+
+	if err = conn.SetWriteDeadline(time.Now().Add(c.config.Kafka.WriteTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err = conn.Write(bytes.Join([][]byte{sizeBuf, reqBuf}, nil)); err != nil {
+		return nil, errors.Wrap(err, "Failed to send metadata request")
+	}
+	if err = conn.SetReadDeadline(time.Now().Add(c.config.Kafka.ReadTimeout)); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 8) // response header
+	if _, err = io.ReadFull(conn, header); err != nil {
+		return nil, errors.Wrap(err, "Failed to read metadata response header")
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 4 || int32(length) > protocol.MaxResponseSize {
+		return nil, fmt.Errorf("invalid metadata response length %d", length)
+	}
+	payload := make([]byte, length-4)
+	if _, err = io.ReadFull(conn, payload); err != nil {
+		return nil, errors.Wrap(err, "Failed to read metadata response payload")
+	}
+	return protocol.DecodeMetadataBrokers(1, payload)
+}
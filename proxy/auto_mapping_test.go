@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoMappingMappings(t *testing.T) {
+	a := assert.New(t)
+
+	brokers := []protocol.MetadataBroker{{NodeID: 2, Host: "kafka-2.kafka", Port: 9092}, {NodeID: 1, Host: "kafka-1.kafka", Port: 9092}}
+
+	mappings, err := NewAutoMapping(32400, "0.0.0.0:$(listener_port)", "").Mappings(brokers)
+	a.Nil(err)
+	a.Equal([]config.ListenerConfig{
+		{BrokerAddress: "kafka-1.kafka:9092", ListenerAddress: "0.0.0.0:32401", AdvertisedAddress: "0.0.0.0:32401"},
+		{BrokerAddress: "kafka-2.kafka:9092", ListenerAddress: "0.0.0.0:32402", AdvertisedAddress: "0.0.0.0:32402"},
+	}, mappings)
+
+	mappings, err = NewAutoMapping(30000, "0.0.0.0:$(listener_port)", "proxy-$(node_id).example.com:$(broker_port)").Mappings(brokers)
+	a.Nil(err)
+	a.Equal("proxy-1.example.com:9092", mappings[0].AdvertisedAddress)
+	a.Equal("0.0.0.0:30001", mappings[0].ListenerAddress)
+
+	_, err = NewAutoMapping(65535, "0.0.0.0:$(listener_port)", "").Mappings(brokers)
+	a.EqualError(err, "listener port 65537 of broker 2 is out of range")
+
+	_, err = NewAutoMapping(32400, "$(broker_host)", "").Mappings(brokers)
+	a.NotNil(err)
+}
+
+// serveMetadata answers one metadata request with the brokers
+func serveMetadata(t *testing.T, l net.Listener, brokers []protocol.MetadataBroker) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	sizeBuf := make([]byte, 4)
+	if _, err = io.ReadFull(conn, sizeBuf); err != nil {
+		return
+	}
+	request := make([]byte, binary.BigEndian.Uint32(sizeBuf))
+	if _, err = io.ReadFull(conn, request); err != nil {
+		return
+	}
+	assert.Equal(t, []byte{0, 3, 0, 1}, request[:4])
+
+	var body bytes.Buffer
+	_ = binary.Write(&body, binary.BigEndian, int32(len(brokers)))
+	for _, broker := range brokers {
+		_ = binary.Write(&body, binary.BigEndian, broker.NodeID)
+		_ = binary.Write(&body, binary.BigEndian, int16(len(broker.Host)))
+		body.WriteString(broker.Host)
+		_ = binary.Write(&body, binary.BigEndian, broker.Port)
+		_ = binary.Write(&body, binary.BigEndian, int16(-1))
+	}
+	_ = binary.Write(&body, binary.BigEndian, int32(1))
+	_ = binary.Write(&body, binary.BigEndian, int32(0))
+
+	var response bytes.Buffer
+	_ = binary.Write(&response, binary.BigEndian, int32(body.Len()+4))
+	response.Write(request[4:8]) // correlation id
+	response.Write(body.Bytes())
+	_, _ = conn.Write(response.Bytes())
+}
+
+func TestClientFetchBrokers(t *testing.T) {
+	a := assert.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	defer l.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	closedAddress := closed.Addr().String()
+	_ = closed.Close()
+
+	brokers := []protocol.MetadataBroker{{NodeID: 1, Host: "kafka-1", Port: 9092}}
+	go serveMetadata(t, l, brokers)
+
+	cfg := config.NewConfig()
+	client, err := NewClient(NewConnSet(), cfg, nil, nil, nil, nil, nil, nil, nil, nil)
+	a.Nil(err)
+
+	fetched, err := client.FetchBrokers([]string{closedAddress, l.Addr().String()})
+	a.Nil(err)
+	a.Equal(brokers, fetched)
+
+	_, err = client.FetchBrokers([]string{closedAddress})
+	a.NotNil(err)
+	a.Contains(err.Error(), "metadata of broker "+closedAddress)
+}
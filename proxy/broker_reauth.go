@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// length of the open requests sent by the proxy to re-authenticate, the responses are not forwarded to the client
+	brokerReauthRequestLength = -1
+	// part of the session lifetime after which the broker connection is re-authenticated, as done by the Java client
+	brokerReauthLifetimeRatio = 0.85
+)
+
+// saslSessionConn is a broker connection authenticated with a session of limited lifetime (KIP-368)
+type saslSessionConn struct {
+	net.Conn
+	auth     *SASLOAuthBearerAuth
+	lifetime time.Duration
+}
+
+// tcpConnOf returns the TCP connection of the broker connection
+func tcpConnOf(conn net.Conn) (*net.TCPConn, bool) {
+	if session, ok := conn.(*saslSessionConn); ok {
+		conn = session.Conn
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	return tcpConn, ok
+}
+
+// brokerReauth re-authenticates the broker connection in-band with SASL OAUTHBEARER (KIP-368), so brokers configured with
+// connections.max.reauth.ms do not close it when the session expires. The SaslHandshake and SaslAuthenticate requests are
+// sent before the next client request once 85% of the session lifetime elapsed. The broker processes the requests of
+// a connection in order, so their responses precede the response to the client request and are consumed by the proxy.
+// A nil brokerReauth does not re-authenticate.
+type brokerReauth struct {
+	auth          *SASLOAuthBearerAuth
+	brokerAddress string
+	log           *logrus.Entry
+
+	lock          sync.Mutex
+	reauthAt      time.Time
+	pending       bool
+	correlationID int32
+
+	nowFn func() time.Time
+}
+
+// newBrokerReauth returns nil if the broker session of the connection does not expire
+func newBrokerReauth(server net.Conn, brokerAddress string, log *logrus.Entry) *brokerReauth {
+	session, ok := server.(*saslSessionConn)
+	if !ok || session.lifetime <= 0 {
+		return nil
+	}
+	r := &brokerReauth{auth: session.auth, brokerAddress: brokerAddress, log: log, nowFn: time.Now}
+	r.setLifetime(session.lifetime)
+	return r
+}
+
+func (r *brokerReauth) setLifetime(lifetime time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pending = false
+	if lifetime <= 0 {
+		r.reauthAt = time.Time{}
+		return
+	}
+	r.reauthAt = r.nowFn().Add(time.Duration(float64(lifetime) * brokerReauthLifetimeRatio))
+}
+
+// start returns the correlation id of the re-authentication requests if the re-authentication is due
+func (r *brokerReauth) start() (int32, bool) {
+	if r == nil {
+		return 0, false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.pending || r.reauthAt.IsZero() || r.nowFn().Before(r.reauthAt) {
+		return 0, false
+	}
+	r.pending = true
+	// negative ids do not collide with the ids of the java client
+	r.correlationID--
+	return r.correlationID, true
+}
+
+// maybeReauthenticate sends the re-authentication requests to the broker when the re-authentication is due. The requests are
+// registered as open requests before the client request, which is sent afterwards.
+func (r *brokerReauth) maybeReauthenticate(dst DeadlineWriter, openRequestsChannel chan<- protocol.RequestKeyVersion) error {
+	correlationID, ok := r.start()
+	if !ok {
+		return nil
+	}
+	r.log.Infof("Re-authenticating connection to %s", r.brokerAddress)
+	token, err := r.auth.getOAuthBearerToken()
+	if err != nil {
+		proxyBrokerReauthenticationsTotal.WithLabelValues(r.brokerAddress, "error").Inc()
+		return errors.Wrap(err, "re-authentication token cannot be provided")
+	}
+	handshake := &protocol.Request{
+		ClientID:      r.auth.clientID,
+		CorrelationID: correlationID,
+		Body:          &protocol.SaslHandshakeRequestV0orV1{Version: 1, Mechanism: SASLOAuthBearer},
+	}
+	requests := []struct {
+		apiKey int16
+		req    *protocol.Request
+	}{
+		{apiKey: apiKeySaslHandshake, req: handshake},
+		{apiKey: apiKeySaslAuthenticate, req: r.auth.saslAuthenticateRequest(token, correlationID)},
+	}
+	for _, request := range requests {
+		reqBuf, err := protocol.Encode(request.req)
+		if err != nil {
+			return err
+		}
+		sizeBuf := make([]byte, 4)
+		binary.BigEndian.PutUint32(sizeBuf, uint32(len(reqBuf)))
+		if err = sendRequestKeyVersion(openRequestsChannel, openRequestSendTimeout, &protocol.RequestKeyVersion{Length: brokerReauthRequestLength, ApiKey: request.apiKey, ApiVersion: 1}); err != nil {
+			return err
+		}
+		if err = dst.SetWriteDeadline(time.Now().Add(r.auth.writeTimeout)); err != nil {
+			return err
+		}
+		if _, err = dst.Write(bytes.Join([][]byte{sizeBuf, reqBuf}, nil)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleResponse consumes the response to a re-authentication request. The connection is closed when the re-authentication failed,
+// as the broker closes it as well.
+func (r *brokerReauth) handleResponse(src DeadlineReader, requestKeyVersion *protocol.RequestKeyVersion, responseHeader *protocol.ResponseHeader) error {
+	if r == nil {
+		return errors.New("unexpected re-authentication response")
+	}
+	if responseHeader.Length < 4 || responseHeader.Length > protocol.MaxResponseSize {
+		return protocol.PacketDecodingError{Info: fmt.Sprintf("re-authentication response of length %d is invalid", responseHeader.Length)}
+	}
+	if err := src.SetReadDeadline(time.Now().Add(r.auth.readTimeout)); err != nil {
+		return err
+	}
+	// 4 bytes of the correlation id were read
+	payload := make([]byte, int(responseHeader.Length-4))
+	if _, err := io.ReadFull(src, payload); err != nil {
+		return err
+	}
+	if requestKeyVersion.ApiKey == apiKeySaslHandshake {
+		res := &protocol.SaslHandshakeResponseV0orV1{}
+		if err := protocol.Decode(payload, res); err != nil {
+			return errors.Wrap(err, "Failed to parse SASL handshake")
+		}
+		if res.Err != protocol.ErrNoError {
+			proxyBrokerReauthenticationsTotal.WithLabelValues(r.brokerAddress, "failed").Inc()
+			return errors.Wrap(res.Err, "Invalid SASL Mechanism")
+		}
+		return nil
+	}
+	lifetime, err := r.auth.decodeSaslAuthenticateResponse(payload)
+	if err != nil {
+		proxyBrokerReauthenticationsTotal.WithLabelValues(r.brokerAddress, "failed").Inc()
+		return errors.Wrapf(err, "re-authentication to %s failed", r.brokerAddress)
+	}
+	proxyBrokerReauthenticationsTotal.WithLabelValues(r.brokerAddress, "success").Inc()
+	r.log.Infof("Re-authenticated connection to %s, session lifetime %v", r.brokerAddress, lifetime)
+	r.setLifetime(lifetime)
+	return nil
+}
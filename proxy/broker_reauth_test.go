@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBrokerReauth(lifetime time.Duration) *brokerReauth {
+	auth := &SASLOAuthBearerAuth{
+		clientID:         "kafka-proxy",
+		writeTimeout:     time.Second,
+		readTimeout:      time.Second,
+		tokenProvider:    &testTokenProvider{response: apis.TokenResponse{Success: true, Token: "my-token"}},
+		reauthentication: true,
+	}
+	local, remote := net.Pipe()
+	_ = remote.Close()
+	return newBrokerReauth(&saslSessionConn{Conn: local, auth: auth, lifetime: lifetime}, "kafka-0:9092", subsystemLog(logSubsystemBroker))
+}
+
+func testResponseFrame(correlationID int32, body []byte) []byte {
+	frame := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(4+len(body)))
+	binary.BigEndian.PutUint32(frame[4:], uint32(correlationID))
+	return append(frame, body...)
+}
+
+func TestBrokerReauthDisabled(t *testing.T) {
+	a := assert.New(t)
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	a.Nil(newBrokerReauth(local, "kafka-0:9092", nil))
+	a.Nil(newBrokerReauth(&saslSessionConn{Conn: local}, "kafka-0:9092", nil))
+
+	var reauth *brokerReauth
+	a.Nil(reauth.maybeReauthenticate(&TestDeadlineWriter{Buffer: new(bytes.Buffer)}, make(chan protocol.RequestKeyVersion, 1)))
+
+	tcpConn, ok := tcpConnOf(&saslSessionConn{Conn: local})
+	a.False(ok)
+	a.Nil(tcpConn)
+}
+
+func TestBrokerReauthSendsRequests(t *testing.T) {
+	a := assert.New(t)
+
+	reauth := testBrokerReauth(time.Minute)
+	output := new(bytes.Buffer)
+	dst := &TestDeadlineWriter{Buffer: output}
+	openRequestsChannel := make(chan protocol.RequestKeyVersion, 4)
+
+	// 85% of the session lifetime did not elapse
+	a.Nil(reauth.maybeReauthenticate(dst, openRequestsChannel))
+	a.Equal(0, output.Len())
+
+	reauth.nowFn = func() time.Time { return time.Now().Add(52 * time.Second) }
+	a.Nil(reauth.maybeReauthenticate(dst, openRequestsChannel))
+	a.Len(openRequestsChannel, 2)
+	a.Equal(protocol.RequestKeyVersion{Length: brokerReauthRequestLength, ApiKey: apiKeySaslHandshake, ApiVersion: 1}, <-openRequestsChannel)
+	a.Equal(protocol.RequestKeyVersion{Length: brokerReauthRequestLength, ApiKey: apiKeySaslAuthenticate, ApiVersion: 1}, <-openRequestsChannel)
+
+	handshake := make([]byte, binary.BigEndian.Uint32(output.Next(4)))
+	_, _ = output.Read(handshake)
+	a.Equal(apiKeySaslHandshake, int16(binary.BigEndian.Uint16(handshake)))
+	a.True(bytes.HasSuffix(handshake, []byte(SASLOAuthBearer)))
+
+	authenticate := &protocol.SaslAuthenticateRequestV1{}
+	a.Nil(protocol.Decode(output.Next(int(binary.BigEndian.Uint32(output.Next(4)))), &protocol.Request{Body: authenticate}))
+	a.Contains(string(authenticate.SaslAuthBytes), "auth=Bearer my-token")
+	a.Equal(0, output.Len())
+
+	// requests are not repeated until the broker responded
+	a.Nil(reauth.maybeReauthenticate(dst, openRequestsChannel))
+	a.Equal(0, output.Len())
+}
+
+func TestBrokerReauthResponsesAreNotForwarded(t *testing.T) {
+	a := assert.New(t)
+
+	reauth := testBrokerReauth(time.Minute)
+	reauth.pending = true
+
+	handshake, err := protocol.Encode(&protocol.SaslHandshakeResponseV0orV1{Err: protocol.ErrNoError, EnabledMechanisms: []string{SASLOAuthBearer}})
+	a.Nil(err)
+	authenticate, err := protocol.Encode(&protocol.SaslAuthenticateResponseV1{Err: protocol.ErrNoError, SaslAuthBytes: []byte{}, SessionLifetimeMs: 3600000})
+	a.Nil(err)
+	// ListGroups v2 response to the client request
+	clientResponse, err := hex.DecodeString("0000002f000000040000000000000000000100154b61666b614578616d706c65436f6e73756d6572320008636f6e73756d6572")
+	a.Nil(err)
+
+	input := bytes.Join([][]byte{testResponseFrame(-1, handshake), testResponseFrame(-1, authenticate), clientResponse}, nil)
+	src := &TestDeadlineReader{Buffer: bytes.NewBuffer(input)}
+	output := new(bytes.Buffer)
+
+	openRequestsChannel := make(chan protocol.RequestKeyVersion, 3)
+	openRequestsChannel <- protocol.RequestKeyVersion{Length: brokerReauthRequestLength, ApiKey: apiKeySaslHandshake, ApiVersion: 1}
+	openRequestsChannel <- protocol.RequestKeyVersion{Length: brokerReauthRequestLength, ApiKey: apiKeySaslAuthenticate, ApiVersion: 1}
+	openRequestsChannel <- protocol.RequestKeyVersion{Length: 20, ApiKey: 16, ApiVersion: 2}
+
+	ctx := &ResponsesLoopContext{openRequestsChannel: openRequestsChannel, timeout: time.Second, buf: make([]byte, defaultResponseBufferSize), brokerReauth: reauth}
+	_, err = defaultResponseHandler.handleResponse(&TestDeadlineWriter{Buffer: output}, src, ctx)
+	a.Nil(err)
+	a.Equal(clientResponse, output.Bytes())
+	a.Empty(src.Bytes())
+
+	a.False(reauth.pending)
+	a.WithinDuration(time.Now().Add(51*time.Minute), reauth.reauthAt, time.Minute)
+}
+
+func TestBrokerReauthFailed(t *testing.T) {
+	a := assert.New(t)
+
+	reauth := testBrokerReauth(time.Minute)
+	errMsg := "token expired"
+	authenticate, err := protocol.Encode(&protocol.SaslAuthenticateResponseV1{Err: protocol.ErrSASLAuthenticationFailed, ErrMsg: &errMsg, SaslAuthBytes: []byte{}})
+	a.Nil(err)
+
+	openRequestsChannel := make(chan protocol.RequestKeyVersion, 1)
+	openRequestsChannel <- protocol.RequestKeyVersion{Length: brokerReauthRequestLength, ApiKey: apiKeySaslAuthenticate, ApiVersion: 1}
+	ctx := &ResponsesLoopContext{openRequestsChannel: openRequestsChannel, timeout: time.Second, buf: make([]byte, defaultResponseBufferSize), brokerReauth: reauth}
+	src := &TestDeadlineReader{Buffer: bytes.NewBuffer(testResponseFrame(-1, authenticate))}
+	_, err = defaultResponseHandler.handleResponse(&TestDeadlineWriter{Buffer: new(bytes.Buffer)}, src, ctx)
+	a.Error(err)
+}
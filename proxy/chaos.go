@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	chaosFaultLatency   = "latency"
+	chaosFaultReset     = "reset"
+	chaosFaultTruncate  = "truncate"
+	chaosFaultSASLDelay = "sasl_delay"
+)
+
+var (
+	errChaosReset    = errors.New("connection reset by fault injection")
+	errChaosTruncate = errors.New("response truncated by fault injection")
+)
+
+// Chaos injects faults into the connections of the selected listeners to test the retry behavior of the clients. A share of
+// the connections is selected when the connection is accepted, the faults are injected into the responses of the selected
+// connections only.
+type Chaos struct {
+	// listener or broker addresses, all listeners when empty
+	listeners map[string]bool
+	// percentage of the connections with injected faults
+	percentage   float64
+	latency      time.Duration
+	resetRate    float64
+	truncateRate float64
+	saslDelay    time.Duration
+
+	sleep  func(time.Duration)
+	random func() float64
+}
+
+func NewChaos(listeners []string, percentage float64, latency time.Duration, resetRate float64, truncateRate float64, saslDelay time.Duration) *Chaos {
+	c := &Chaos{
+		listeners:    make(map[string]bool),
+		percentage:   percentage,
+		latency:      latency,
+		resetRate:    resetRate,
+		truncateRate: truncateRate,
+		saslDelay:    saslDelay,
+		sleep:        time.Sleep,
+		random:       rand.Float64,
+	}
+	for _, listener := range listeners {
+		c.listeners[listener] = true
+	}
+	return c
+}
+
+func (c *Chaos) enabled() bool {
+	return c != nil
+}
+
+// selected returns true if faults are injected into the new connection accepted on the listener
+func (c *Chaos) selected(listenerAddress string, brokerAddress string) bool {
+	if c == nil {
+		return false
+	}
+	if len(c.listeners) != 0 && !c.listeners[listenerAddress] && !c.listeners[brokerAddress] {
+		return false
+	}
+	return c.random()*100 < c.percentage
+}
+
+// delay sleeps the added latency of the response, SASL handshake and authenticate responses are delayed additionally
+func (c *Chaos) delay(brokerAddress string, apiKey int16) {
+	if c.latency > 0 {
+		proxyChaosFaultsTotal.WithLabelValues(brokerAddress, chaosFaultLatency).Inc()
+		c.sleep(c.latency)
+	}
+	if apiKey == apiKeySaslHandshake || apiKey == apiKeySaslAuthenticate {
+		c.delaySASL(brokerAddress)
+	}
+}
+
+// delaySASL sleeps the SASL delay, used for the SASL handshakes handled by the proxy as well
+func (c *Chaos) delaySASL(brokerAddress string) {
+	if c.saslDelay > 0 {
+		proxyChaosFaultsTotal.WithLabelValues(brokerAddress, chaosFaultSASLDelay).Inc()
+		c.sleep(c.saslDelay)
+	}
+}
+
+// reset returns an error if the client connection is to be reset instead of forwarding the response. TCP connections
+// are closed with RST.
+func (c *Chaos) reset(brokerAddress string, conn interface{}) error {
+	if c.resetRate <= 0 || c.random() >= c.resetRate {
+		return nil
+	}
+	proxyChaosFaultsTotal.WithLabelValues(brokerAddress, chaosFaultReset).Inc()
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+	}
+	return errChaosReset
+}
+
+// truncate returns true if only a part of the response is to be forwarded before the connection is closed
+func (c *Chaos) truncate(brokerAddress string) bool {
+	if c.truncateRate <= 0 || c.random() >= c.truncateRate {
+		return false
+	}
+	proxyChaosFaultsTotal.WithLabelValues(brokerAddress, chaosFaultTruncate).Inc()
+	return true
+}
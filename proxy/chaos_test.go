@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestChaos(random float64, listeners ...string) (*Chaos, *[]time.Duration) {
+	chaos := NewChaos(listeners, 50, 0, 0, 0, 0)
+	chaos.random = func() float64 { return random }
+	var sleeps []time.Duration
+	chaos.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return chaos, &sleeps
+}
+
+func TestChaosSelected(t *testing.T) {
+	a := assert.New(t)
+
+	chaos, _ := newTestChaos(0.2)
+	a.True(chaos.selected("0.0.0.0:32400", "kafka-0:9092"))
+	chaos.random = func() float64 { return 0.7 }
+	a.False(chaos.selected("0.0.0.0:32400", "kafka-0:9092"))
+
+	chaos, _ = newTestChaos(0.2, "kafka-1:9092")
+	a.False(chaos.selected("0.0.0.0:32400", "kafka-0:9092"))
+	a.True(chaos.selected("0.0.0.0:32401", "kafka-1:9092"))
+
+	a.False((*Chaos)(nil).selected("0.0.0.0:32400", "kafka-0:9092"))
+	a.False((*Chaos)(nil).enabled())
+}
+
+func TestChaosDelay(t *testing.T) {
+	a := assert.New(t)
+
+	chaos, sleeps := newTestChaos(0)
+	chaos.delay("kafka-0:9092", apiKeyFetch)
+	a.Empty(*sleeps)
+
+	chaos.latency = 100 * time.Millisecond
+	chaos.saslDelay = time.Second
+	chaos.delay("kafka-0:9092", apiKeyFetch)
+	chaos.delay("kafka-0:9092", apiKeySaslAuthenticate)
+	a.Equal([]time.Duration{100 * time.Millisecond, 100 * time.Millisecond, time.Second}, *sleeps)
+}
+
+func TestChaosReset(t *testing.T) {
+	a := assert.New(t)
+
+	chaos, _ := newTestChaos(0.3)
+	a.Nil(chaos.reset("kafka-0:9092", nil))
+	chaos.resetRate = 0.3
+	a.Nil(chaos.reset("kafka-0:9092", nil))
+	chaos.resetRate = 0.5
+	a.Equal(errChaosReset, chaos.reset("kafka-0:9092", nil))
+}
+
+func TestHandleResponseChaosTruncate(t *testing.T) {
+	a := assert.New(t)
+
+	// Fetch v11, kafka-client 2.3.1
+	input, err := hex.DecodeString("0000003d0000000200000000000000010011746f7069632d73746172742d6f6c642d3200000001000000000000ffffffffffffffff000000000000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chaos, _ := newTestChaos(0.1)
+	chaos.truncateRate = 0.5
+
+	openRequestsChannel := make(chan protocol.RequestKeyVersion, 1)
+	openRequestsChannel <- protocol.RequestKeyVersion{ApiKey: apiKeyFetch, ApiVersion: 11}
+	output := bytes.NewBuffer(make([]byte, 0))
+	ctx := &ResponsesLoopContext{openRequestsChannel: openRequestsChannel, timeout: 1 * time.Second, buf: make([]byte, defaultResponseBufferSize),
+		chaos: chaos}
+
+	readErr, err := defaultResponseHandler.handleResponse(&TestDeadlineWriter{Buffer: output}, &TestDeadlineReader{Buffer: bytes.NewBuffer(input)}, ctx)
+	a.False(readErr)
+	a.Equal(errChaosTruncate, err)
+	// header and a half of the 57 bytes following the correlation id
+	a.Equal(input[:8+28], output.Bytes())
+}
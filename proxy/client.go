@@ -1,15 +1,21 @@
 package proxy
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/grepplabs/kafka-proxy/config"
 	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/encryption"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/schemaregistry"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -17,13 +23,20 @@ import (
 // Conn represents a connection from a client to a specific instance.
 type Conn struct {
 	BrokerAddress   string
+	ListenerAddress string
 	LocalConnection net.Conn
+	// accepted by the transparent listener
+	Transparent bool
 }
 
 // Client is a type to handle connecting to a Server. All fields are required
 // unless otherwise specified.
 type Client struct {
 	conns *ConnSet
+	// active client connections listed by the admin endpoint
+	connections *Connections
+	// decisions of the auth plugins, nil if not cached
+	authCache *AuthCache
 
 	// Kafka Net configuration
 	config *config.Config
@@ -32,6 +45,7 @@ type Client struct {
 	processorConfig ProcessorConfig
 
 	dialer         Dialer
+	dialRetry      *DialRetry
 	tcpConnOptions TCPConnOptions
 
 	stopRun  chan struct{}
@@ -42,10 +56,41 @@ type Client struct {
 
 	dialAddressMapping map[string]config.DialAddressMapping
 
+	// authentication of the proxy clients by listener, nil if no auth policies are configured
+	listenerAuth *listenerAuth
+
 	kafkaClientCert *x509.Certificate
+
+	pseudonymizer *Pseudonymizer
+
+	// credentials of the SASL PLAIN and SCRAM authentication, nil if not used
+	saslCredentials *SASLCredentials
+
+	// shares pooled broker connections between the client connections, nil if multiplexing is disabled
+	multiplexer *Multiplexer
+
+	// duplicates the produce requests to the mirror cluster, nil if mirroring is disabled
+	mirror *Mirror
+	// injects faults into the selected connections, nil if fault injection is disabled
+	chaos *Chaos
+	// prefixes the names of the tenants, nil if no tenants are configured
+	tenancy *Tenancy
+
+	// resolves the srv:// dial addresses
+	srvAddresses *SRVAddresses
+
+	// refuses client connections by network per listener
+	networkACL *NetworkACL
+
+	// replaced on configuration reload
+	reloadLock            sync.RWMutex
+	requestLimits         config.RequestLimits
+	listenerRequestLimits config.ListenerRequestLimits
+
+	compressionTranscodings config.ListenerCompressionTranscodings
 }
 
-func NewClient(conns *ConnSet, c *config.Config, netAddressMappingFunc config.NetAddressMappingFunc, localPasswordAuthenticator apis.PasswordAuthenticator, localTokenAuthenticator apis.TokenInfo, saslTokenProvider apis.TokenProvider, gatewayTokenProvider apis.TokenProvider, gatewayTokenInfo apis.TokenInfo) (*Client, error) {
+func NewClient(conns *ConnSet, c *config.Config, netAddressMappingFunc config.NetAddressMappingFunc, localPasswordAuthenticator apis.PasswordAuthenticator, localTokenAuthenticator apis.TokenInfo, saslTokenProvider apis.TokenProvider, gatewayTokenProvider apis.TokenProvider, gatewayTokenInfo apis.TokenInfo, interceptor apis.Interceptor, authPlugins map[string]LocalAuthenticator) (*Client, error) {
 	tlsConfig, err := newTLSClientConfig(c)
 	if err != nil {
 		return nil, err
@@ -69,12 +114,60 @@ func NewClient(conns *ConnSet, c *config.Config, netAddressMappingFunc config.Ne
 		ReadBufferSize:  c.Kafka.ConnectionReadBufferSize,
 	}
 
-	forbiddenApiKeys := make(map[int16]struct{})
+	pseudonymizer, err := newPseudonymizer(c)
+	if err != nil {
+		return nil, err
+	}
+	if pseudonymizer.enabled() {
+		logrus.Infof("Principals, client ids and client addresses will be pseudonymized.")
+	}
+	topicPolicy, err := NewTopicPolicy(c.TopicPolicy.NamePatterns, c.TopicPolicy.MinReplicationFactor, c.TopicPolicy.MaxPartitions, c.TopicPolicy.RequiredConfigs, c.TopicPolicy.DeleteProtectedPatterns)
+	if err != nil {
+		return nil, err
+	}
+	if topicPolicy.enabled() {
+		logrus.Infof("Topic policy will be applied to CreateTopics and DeleteTopics requests.")
+	}
+	if limits := c.Proxy.RequestLimits; limits.MaxRequestSize > 0 || limits.MaxBatchSize > 0 {
+		logrus.Infof("Requests larger than %d bytes and record batches larger than %d bytes will be rejected (0 means no limit).", limits.MaxRequestSize, limits.MaxBatchSize)
+	}
+	for address, limits := range c.Proxy.ListenerRequestLimits {
+		logrus.Infof("Requests to listener %s larger than %d bytes and record batches larger than %d bytes will be rejected (0 means no limit).", address, limits.MaxRequestSize, limits.MaxBatchSize)
+	}
+	schemaValidation := newSchemaValidation(c)
+	payloadEncryption, err := newPayloadEncryption(c)
+	if err != nil {
+		return nil, err
+	}
+	maxApiVersions := c.Proxy.MaxApiVersions
+	if payloadEncryption.enabled() {
+		// the records of newer produce and fetch versions cannot be encrypted and decrypted
+		maxApiVersions = maxApiVersions.Capped(protocol.RecordsMaxVersions)
+	}
+
 	if len(c.Kafka.ForbiddenApiKeys) != 0 {
 		logrus.Warnf("Kafka operations for Api Keys %v will be forbidden.", c.Kafka.ForbiddenApiKeys)
-		for _, apiKey := range c.Kafka.ForbiddenApiKeys {
-			forbiddenApiKeys[int16(apiKey)] = struct{}{}
-		}
+	}
+	for _, scheduled := range c.Kafka.ScheduledForbiddenApiKeys {
+		logrus.Warnf("Kafka operations for Api Keys %v will be forbidden during '%s'.", scheduled.ApiKeys, scheduled.Window)
+	}
+	if len(c.Proxy.ListenerAllowCIDRs) != 0 || len(c.Proxy.ListenerDenyCIDRs) != 0 {
+		logrus.Infof("Client connections will be refused by network, allowed %s, denied %s.", &c.Proxy.ListenerAllowCIDRs, &c.Proxy.ListenerDenyCIDRs)
+	}
+	for _, window := range c.Proxy.MaintenanceWindows {
+		logrus.Infof("New connections will be refused during maintenance window '%s'.", window)
+	}
+	if c.Proxy.Deprecation.ThrottleTime > 0 {
+		logrus.Infof("Responses to deprecated client ids %v and api versions %s will be throttled by %v.", c.Proxy.Deprecation.ClientIDs, &c.Proxy.Deprecation.MinApiVersions, c.Proxy.Deprecation.ThrottleTime)
+	}
+	if len(c.Proxy.MaxApiVersions) != 0 {
+		logrus.Infof("Api versions advertised to the clients will be capped to %s.", &c.Proxy.MaxApiVersions)
+	}
+	if len(c.Proxy.CompressionTranscodings) != 0 {
+		logrus.Infof("Record batches will be transcoded between broker and client codecs %s.", &c.Proxy.CompressionTranscodings)
+	}
+	for _, watermark := range c.Kafka.Producer.TopicWatermarks {
+		logrus.Infof("Produced records will be tracked with topic watermark '%s'.", watermark)
 	}
 	if c.Auth.Local.Enable && (localPasswordAuthenticator == nil && localTokenAuthenticator == nil) {
 		return nil, errors.New("Auth.Local.Enable is enabled but passwordAuthenticator and localTokenAuthenticator are nil")
@@ -83,10 +176,27 @@ func NewClient(conns *ConnSet, c *config.Config, netAddressMappingFunc config.Ne
 	if c.Auth.Gateway.Client.Enable && gatewayTokenProvider == nil {
 		return nil, errors.New("Auth.Gateway.Client.Enable is enabled but tokenProvider is nil")
 	}
-	if c.Auth.Gateway.Server.Enable && gatewayTokenInfo == nil {
+	if (c.Auth.Gateway.Server.Enable || c.Auth.ListenerPolicies.GatewayAuthEnabled()) && gatewayTokenInfo == nil {
 		return nil, errors.New("Auth.Gateway.Server.Enable is enabled but tokenInfo is nil")
 	}
+	authCache := NewAuthCache(c.Auth.Cache.TTL, c.Auth.Cache.NegativeTTL, c.Auth.Cache.MaxEntries)
+	if authCache.enabled() {
+		logrus.Infof("Auth plugin decisions will be cached for %v, failed decisions for %v.", c.Auth.Cache.TTL, c.Auth.Cache.NegativeTTL)
+		authCache.pseudonymizer = pseudonymizer
+		localPasswordAuthenticator = authCache.PasswordAuthenticator("local", localPasswordAuthenticator)
+		localTokenAuthenticator = authCache.TokenInfo("local", localTokenAuthenticator)
+		gatewayTokenInfo = authCache.TokenInfo("gateway", gatewayTokenInfo)
+		cachedAuthPlugins := make(map[string]LocalAuthenticator, len(authPlugins))
+		for name, plugin := range authPlugins {
+			cachedAuthPlugins[name] = LocalAuthenticator{
+				PasswordAuthenticator: authCache.PasswordAuthenticator("plugin:"+name, plugin.PasswordAuthenticator),
+				TokenAuthenticator:    authCache.TokenInfo("plugin:"+name, plugin.TokenAuthenticator),
+			}
+		}
+		authPlugins = cachedAuthPlugins
+	}
 	var saslAuthByProxy SASLAuthByProxy
+	var saslCredentials *SASLCredentials
 	if c.Kafka.SASL.Plugin.Enable {
 		if c.Kafka.SASL.Plugin.Mechanism == SASLOAuthBearer && saslTokenProvider != nil {
 			saslAuthByProxy = &SASLOAuthBearerAuth{
@@ -94,27 +204,28 @@ func NewClient(conns *ConnSet, c *config.Config, netAddressMappingFunc config.Ne
 				writeTimeout:  c.Kafka.WriteTimeout,
 				readTimeout:   c.Kafka.ReadTimeout,
 				tokenProvider: saslTokenProvider,
+
+				reauthentication: c.Kafka.SASL.Reauthentication,
 			}
 		} else {
 			return nil, errors.Errorf("SASLAuthByProxy plugin unsupported or plugin misconfiguration for mechanism '%s' ", c.Kafka.SASL.Plugin.Mechanism)
 		}
 
 	} else if c.Kafka.SASL.Enable {
+		saslCredentials = NewSASLCredentials(c.Kafka.SASL.Username, c.Kafka.SASL.Password)
 		if c.Kafka.SASL.Method == SASLPlain {
 			saslAuthByProxy = &SASLPlainAuth{
 				clientID:     c.Kafka.ClientID,
 				writeTimeout: c.Kafka.WriteTimeout,
 				readTimeout:  c.Kafka.ReadTimeout,
-				username:     c.Kafka.SASL.Username,
-				password:     c.Kafka.SASL.Password,
+				credentials:  saslCredentials,
 			}
 		} else if c.Kafka.SASL.Method == SASLSCRAM256 || c.Kafka.SASL.Method == SASLSCRAM512 {
 			saslAuthByProxy = &SASLSCRAMAuth{
 				clientID:     c.Kafka.ClientID,
 				writeTimeout: c.Kafka.WriteTimeout,
 				readTimeout:  c.Kafka.ReadTimeout,
-				username:     c.Kafka.SASL.Username,
-				password:     c.Kafka.SASL.Password,
+				credentials:  saslCredentials,
 				mechanism:    c.Kafka.SASL.Method,
 			}
 		} else {
@@ -125,9 +236,35 @@ func NewClient(conns *ConnSet, c *config.Config, netAddressMappingFunc config.Ne
 	if err != nil {
 		return nil, err
 	}
+	localSasl := NewLocalSasl(LocalSaslParams{
+		enabled:               c.Auth.Local.Enable,
+		timeout:               c.Auth.Local.Timeout,
+		passwordAuthenticator: localPasswordAuthenticator,
+		tokenAuthenticator:    localTokenAuthenticator,
+		pseudonymizer:         pseudonymizer,
+		principalClaim:        c.Auth.Local.PrincipalClaim,
+	})
+	authServer := &AuthServer{
+		enabled:        c.Auth.Gateway.Server.Enable,
+		magic:          c.Auth.Gateway.Server.Magic,
+		method:         c.Auth.Gateway.Server.Method,
+		timeout:        c.Auth.Gateway.Server.Timeout,
+		tokenInfo:      gatewayTokenInfo,
+		principalClaim: c.Auth.Gateway.Server.PrincipalClaim,
+	}
+	listenerAuth, err := newListenerAuth(c, localSasl, authServer, authPlugins, pseudonymizer)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Kafka.DialRetry.Retries > 0 {
+		logrus.Infof("Failed broker connections will be retried %d time(s) with backoff %v up to %v.", c.Kafka.DialRetry.Retries, c.Kafka.DialRetry.Backoff, c.Kafka.DialRetry.MaxBackoff)
+	}
+	dialRetry := NewDialRetry(c.Kafka.DialRetry.Retries, c.Kafka.DialRetry.Backoff, c.Kafka.DialRetry.MaxBackoff, c.Kafka.DialRetry.Jitter)
 
-	return &Client{conns: conns, config: c, dialer: dialer, tcpConnOptions: tcpConnOptions, stopRun: make(chan struct{}, 1),
+	client := &Client{conns: conns, connections: NewConnections(pseudonymizer), authCache: authCache, config: c, dialer: dialer, dialRetry: dialRetry, tcpConnOptions: tcpConnOptions, stopRun: make(chan struct{}, 1),
 		saslAuthByProxy: saslAuthByProxy,
+		saslCredentials: saslCredentials,
 		authClient: &AuthClient{
 			enabled:       c.Auth.Gateway.Client.Enable,
 			magic:         c.Auth.Gateway.Client.Magic,
@@ -137,30 +274,131 @@ func NewClient(conns *ConnSet, c *config.Config, netAddressMappingFunc config.Ne
 		},
 		processorConfig: ProcessorConfig{
 			MaxOpenRequests:       c.Kafka.MaxOpenRequests,
+			MaxInFlightRequests:   c.Proxy.MaxInFlightRequests,
 			NetAddressMappingFunc: netAddressMappingFunc,
 			RequestBufferSize:     c.Proxy.RequestBufferSize,
 			ResponseBufferSize:    c.Proxy.ResponseBufferSize,
 			ReadTimeout:           c.Kafka.ReadTimeout,
 			WriteTimeout:          c.Kafka.WriteTimeout,
-			LocalSasl: NewLocalSasl(LocalSaslParams{
-				enabled:               c.Auth.Local.Enable,
-				timeout:               c.Auth.Local.Timeout,
-				passwordAuthenticator: localPasswordAuthenticator,
-				tokenAuthenticator:    localTokenAuthenticator,
-			}),
-			AuthServer: &AuthServer{
-				enabled:   c.Auth.Gateway.Server.Enable,
-				magic:     c.Auth.Gateway.Server.Magic,
-				method:    c.Auth.Gateway.Server.Method,
-				timeout:   c.Auth.Gateway.Server.Timeout,
-				tokenInfo: gatewayTokenInfo,
-			},
-			ForbiddenApiKeys:      forbiddenApiKeys,
+			LocalSasl:             localSasl,
+			AuthServer:            authServer,
+			ApiKeyRules:           NewApiKeyRules(c.Kafka.ForbiddenApiKeys, c.Kafka.ScheduledForbiddenApiKeys),
 			ProducerAcks0Disabled: c.Kafka.Producer.Acks0Disabled,
+			Passthrough:           NewPassthrough(c.Proxy.Passthrough.Principals),
+			TopicWatermarks:       newTopicWatermarks(c.Kafka.Producer.TopicWatermarks, time.Now()),
+			MaxApiVersions:        maxApiVersions,
+			Deprecation:           NewDeprecation(c.Proxy.Deprecation.ThrottleTime, c.Proxy.Deprecation.ClientIDs, c.Proxy.Deprecation.MinApiVersions),
+			Interceptor:           NewRequestInterceptor(interceptor, c.Interceptor.Timeout, c.Interceptor.Responses, c.Interceptor.RecordHeaders, pseudonymizer),
+			Pseudonymizer:         pseudonymizer,
+			TopicPolicy:           topicPolicy,
+			SchemaValidation:      schemaValidation,
+			PayloadEncryption:     payloadEncryption,
+			Quotas:                newQuotas(c),
+			AuthLimiter:           newAuthLimiter(c, pseudonymizer),
+			RequestLatency:        NewRequestLatency(c.Proxy.RequestLatency.Enable, c.Proxy.RequestLatency.SlowThreshold, pseudonymizer),
 		},
-		dialAddressMapping: dialAddressMapping,
-		kafkaClientCert:    kafkaClientCert,
-	}, nil
+		listenerAuth:          listenerAuth,
+		dialAddressMapping:    dialAddressMapping,
+		kafkaClientCert:       kafkaClientCert,
+		pseudonymizer:         pseudonymizer,
+		requestLimits:         c.Proxy.RequestLimits,
+		listenerRequestLimits: c.Proxy.ListenerRequestLimits,
+		networkACL:            NewNetworkACL(c.Proxy.ListenerAllowCIDRs, c.Proxy.ListenerDenyCIDRs),
+
+		compressionTranscodings: c.Proxy.CompressionTranscodings,
+	}
+	client.srvAddresses = NewSRVAddresses(newSRVResolver(c))
+	if c.Mirror.Enable {
+		var mirrorDialer Dialer = directDialer{dialTimeout: c.Kafka.DialTimeout, keepAlive: c.Kafka.KeepAlive}
+		if c.Mirror.TLSEnable {
+			mirrorDialer = tlsDialer{timeout: c.Kafka.DialTimeout, rawDialer: mirrorDialer, config: tlsConfig}
+		}
+		logrus.Infof("Produce requests will be mirrored to %v.", c.Mirror.BootstrapServers)
+		client.mirror = NewMirror(c.Mirror.BootstrapServers, c.Mirror.Listeners, c.Mirror.QueueSize, c.Mirror.MetadataMaxAge, c.Kafka.ClientID, mirrorDialer, c.Kafka.WriteTimeout, c.Kafka.ReadTimeout)
+	}
+	if len(c.Tenancy.ListenerPrefixes) != 0 || len(c.Tenancy.PrincipalPrefixes) != 0 {
+		logrus.Infof("Names of the tenants will be prefixed per listener %s and per principal %s.", &c.Tenancy.ListenerPrefixes, &c.Tenancy.PrincipalPrefixes)
+		client.tenancy = NewTenancy(c.Tenancy.ListenerPrefixes, c.Tenancy.PrincipalPrefixes, maxApiVersions)
+	}
+	if c.Chaos.Enable {
+		logrus.Warnf("Fault injection is enabled for %v%% of the connections. Do not use it in production.", c.Chaos.ConnectionPercentage)
+		client.chaos = NewChaos(c.Chaos.Listeners, c.Chaos.ConnectionPercentage, c.Chaos.Latency, c.Chaos.ResetRate, c.Chaos.TruncateRate, c.Chaos.SASLDelay)
+	}
+	if c.Proxy.Multiplex.Enable {
+		logrus.Infof("Client connections will be multiplexed over %d pooled connection(s) per broker.", c.Proxy.Multiplex.Connections)
+		client.multiplexer = NewMultiplexer(c.Proxy.Multiplex.Connections, client.dialBroker)
+	}
+	return client, nil
+}
+
+func newSRVResolver(c *config.Config) SRVResolver {
+	if c.Kafka.DNS.Server != "" {
+		return dnsServerSRVResolver{server: c.Kafka.DNS.Server, timeout: c.Kafka.DNS.Timeout}
+	}
+	return systemSRVResolver{ttl: c.Kafka.DNS.SRVTTL, timeout: c.Kafka.DNS.Timeout}
+}
+
+func newPseudonymizer(c *config.Config) (*Pseudonymizer, error) {
+	if !c.Privacy.Pseudonymize {
+		return nil, nil
+	}
+	key, err := ioutil.ReadFile(c.Privacy.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read pseudonymization key")
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) < minPseudonymKeyLength {
+		return nil, errors.Errorf("pseudonymization key must be at least %d bytes long", minPseudonymKeyLength)
+	}
+	return NewPseudonymizer(key), nil
+}
+
+func newQuotas(c *config.Config) *Quotas {
+	// the quotas may be configured on reload
+	if len(c.Proxy.Quotas.Principals) != 0 {
+		logrus.Infof("Principals exceeding their quotas %s will be throttled.", &c.Proxy.Quotas.Principals)
+	}
+	return NewQuotas(c.Proxy.Quotas.Principals, c.Proxy.Quotas.Window)
+}
+
+func newAuthLimiter(c *config.Config, pseudonymizer *Pseudonymizer) *AuthLimiter {
+	// the banning may be configured on reload
+	if c.Auth.BruteForce.MaxFailures > 0 {
+		logrus.Infof("Client addresses with %d failed authentications within %v will be banned for %v.", c.Auth.BruteForce.MaxFailures, c.Auth.BruteForce.Window, c.Auth.BruteForce.BanDuration)
+	}
+	return NewAuthLimiter(c.Auth.BruteForce.MaxFailures, c.Auth.BruteForce.Window, c.Auth.BruteForce.BanDuration, pseudonymizer)
+}
+
+func newSchemaValidation(c *config.Config) *SchemaValidation {
+	if len(c.SchemaValidation.Topics) == 0 {
+		return nil
+	}
+	logrus.Infof("Produce requests to topics %v will be validated against the schema registry %s.", c.SchemaValidation.Topics, c.SchemaValidation.RegistryURL)
+	registry := schemaregistry.NewClient(c.SchemaValidation.RegistryURL, c.SchemaValidation.RegistryUsername, c.SchemaValidation.RegistryPassword, c.SchemaValidation.Timeout, c.SchemaValidation.CacheTTL)
+	return NewSchemaValidation(c.SchemaValidation.Topics, registry, c.SchemaValidation.SubjectNameStrategy)
+}
+
+func newPayloadEncryption(c *config.Config) (*PayloadEncryption, error) {
+	if len(c.Encryption.Topics) == 0 {
+		return nil, nil
+	}
+	var (
+		provider encryption.KeyProvider
+		err      error
+	)
+	switch c.Encryption.KeyProvider {
+	case "local":
+		provider, err = encryption.NewLocalKeyProvider(c.Encryption.LocalKeyFile)
+	case "vault":
+		provider, err = encryption.NewVaultKeyProvider(c.Encryption.VaultAddress, c.Encryption.VaultTokenFile, c.Encryption.VaultTransitMount)
+	default:
+		err = errors.Errorf("unknown encryption key provider %s", c.Encryption.KeyProvider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("Record values of topics %s will be encrypted with %s keys.", &c.Encryption.Topics, c.Encryption.KeyProvider)
+	return NewPayloadEncryption(c.Encryption.Topics, encryption.NewEnvelope(provider, c.Encryption.DataKeyTTL, c.Encryption.Timeout)), nil
 }
 
 func getAddressToDialAddressMapping(cfg *config.Config) (map[string]config.DialAddressMapping, error) {
@@ -168,12 +406,16 @@ func getAddressToDialAddressMapping(cfg *config.Config) (map[string]config.DialA
 
 	for _, v := range cfg.Proxy.DialAddressMappings {
 		if lc, ok := addressToDialAddressMapping[v.SourceAddress]; ok {
-			if lc.SourceAddress != v.SourceAddress || lc.DestinationAddress != v.DestinationAddress {
+			if lc.SourceAddress != v.SourceAddress || strings.Join(lc.DialAddresses(), ",") != strings.Join(v.DialAddresses(), ",") {
 				return nil, fmt.Errorf("dial address mapping %s configured twice: %v and %v", v.SourceAddress, v, lc)
 			}
 			continue
 		}
-		logrus.Infof("Dial address mapping src %s dst %s", v.SourceAddress, v.DestinationAddress)
+		if len(v.FailoverAddresses) != 0 {
+			logrus.Infof("Dial address mapping src %s dst %s failover %v", v.SourceAddress, v.DestinationAddress, v.FailoverAddresses)
+		} else {
+			logrus.Infof("Dial address mapping src %s dst %s", v.SourceAddress, v.DestinationAddress)
+		}
 		addressToDialAddressMapping[v.SourceAddress] = v
 	}
 	return addressToDialAddressMapping, nil
@@ -185,33 +427,37 @@ func newDialer(c *config.Config, tlsConfig *tls.Config) (Dialer, error) {
 		keepAlive:   c.Kafka.KeepAlive,
 	}
 
-	var rawDialer Dialer
+	var rawDialer Dialer = directDialer
 	if c.ForwardProxy.Url != "" {
-		switch c.ForwardProxy.Scheme {
-		case "socks5":
-			logrus.Infof("Kafka clients will connect through the SOCKS5 proxy %s", c.ForwardProxy.Address)
-			rawDialer = &socks5Dialer{
-				directDialer: directDialer,
-				proxyNetwork: "tcp",
-				proxyAddr:    c.ForwardProxy.Address,
-				username:     c.ForwardProxy.Username,
-				password:     c.ForwardProxy.Password,
+		endpoint := config.ForwardProxyEndpoint{
+			Scheme:   c.ForwardProxy.Scheme,
+			Address:  c.ForwardProxy.Address,
+			Username: c.ForwardProxy.Username,
+			Password: c.ForwardProxy.Password,
+		}
+		var err error
+		if rawDialer, err = newForwardProxyDialer(c, endpoint, directDialer); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.ForwardProxy.Mappings) != 0 {
+		router := forwardProxyRouter{dialers: make(map[string]Dialer), defaultDialer: rawDialer}
+		for address, proxyUrl := range c.ForwardProxy.Mappings {
+			if proxyUrl == config.ForwardProxyDirect {
+				logrus.Infof("Kafka clients will connect to %s without forward proxy", address)
+				router.dialers[address] = directDialer
+				continue
+			}
+			endpoint, err := config.ParseForwardProxyURL(proxyUrl)
+			if err != nil {
+				return nil, err
 			}
-		case "http":
-			logrus.Infof("Kafka clients will connect through the HTTP proxy %s using CONNECT", c.ForwardProxy.Address)
-
-			rawDialer = &httpProxy{
-				forwardDialer: directDialer,
-				network:       "tcp",
-				hostPort:      c.ForwardProxy.Address,
-				username:      c.ForwardProxy.Username,
-				password:      c.ForwardProxy.Password,
+			logrus.Infof("Kafka clients will connect to %s through the forward proxy %s://%s", address, endpoint.Scheme, endpoint.Address)
+			if router.dialers[address], err = newForwardProxyDialer(c, endpoint, directDialer); err != nil {
+				return nil, err
 			}
-		default:
-			return nil, errors.New("Only http or socks5 proxy is supported")
 		}
-	} else {
-		rawDialer = directDialer
+		rawDialer = router
 	}
 	if c.Kafka.TLS.Enable {
 		if tlsConfig == nil {
@@ -227,9 +473,55 @@ func newDialer(c *config.Config, tlsConfig *tls.Config) (Dialer, error) {
 	return rawDialer, nil
 }
 
+func newForwardProxyDialer(c *config.Config, endpoint config.ForwardProxyEndpoint, directDialer directDialer) (Dialer, error) {
+	switch endpoint.Scheme {
+	case "socks5":
+		logrus.Infof("Kafka clients will connect through the SOCKS5 proxy %s", endpoint.Address)
+		return &socks5Dialer{
+			directDialer: directDialer,
+			proxyNetwork: "tcp",
+			proxyAddr:    endpoint.Address,
+			username:     endpoint.Username,
+			password:     endpoint.Password,
+		}, nil
+	case "http", "https":
+		var forwardDialer Dialer = directDialer
+		if endpoint.Scheme == "https" {
+			tlsConfig, err := newForwardProxyTLSConfig(c)
+			if err != nil {
+				return nil, err
+			}
+			forwardDialer = tlsDialer{
+				timeout:   c.Kafka.DialTimeout,
+				rawDialer: directDialer,
+				config:    tlsConfig,
+			}
+			logrus.Infof("Kafka clients will connect through the HTTPS proxy %s using CONNECT", endpoint.Address)
+		} else {
+			logrus.Infof("Kafka clients will connect through the HTTP proxy %s using CONNECT", endpoint.Address)
+		}
+		return &httpProxy{
+			forwardDialer:   forwardDialer,
+			network:         "tcp",
+			hostPort:        endpoint.Address,
+			username:        endpoint.Username,
+			password:        endpoint.Password,
+			bearerTokenFile: c.ForwardProxy.BearerTokenFile,
+		}, nil
+	default:
+		return nil, errors.New("Only http, https or socks5 proxy is supported")
+	}
+}
+
 // Run causes the client to start waiting for new connections to connSrc and
 // proxy them to the destination instance. It blocks until connSrc is closed.
 func (c *Client) Run(connSrc <-chan Conn) error {
+	if c.processorConfig.TopicWatermarks != nil {
+		go c.processorConfig.TopicWatermarks.run(c.stopRun)
+	}
+	if c.mirror != nil {
+		go c.mirror.run(c.stopRun)
+	}
 STOP:
 	for {
 		select {
@@ -245,6 +537,7 @@ STOP:
 	if err := c.conns.Close(); err != nil {
 		logrus.Infof("closing client had error: %v", err)
 	}
+	c.multiplexer.close()
 
 	logrus.Info("Proxy is stopped")
 	return nil
@@ -258,43 +551,219 @@ func (c *Client) Close() {
 
 func (c *Client) handleConn(conn Conn) {
 	localConn := conn.LocalConnection
+	localDesc := "local connection on " + localConn.LocalAddr().String() + " from " + c.pseudonymizer.address(localConn.RemoteAddr()) + " (" + conn.BrokerAddress + ")"
+	connID := c.connections.newID()
+	log := connLog(logSubsystemConnection, connID)
+	if conn.Transparent {
+		log.Infof("New transparent connection for %s", conn.BrokerAddress)
+	} else {
+		log.Infof("New connection for %s", conn.BrokerAddress)
+	}
+	if reason := c.networkACL.rejected(conn.ListenerAddress, conn.BrokerAddress, localConn.RemoteAddr()); reason != "" {
+		log.Infof("Client network is %s, refusing %s", strings.Replace(reason, "_", " ", -1), localDesc)
+		proxyNetworkACLRejectedConnectionsTotal.WithLabelValues(conn.BrokerAddress, reason).Inc()
+		_ = localConn.Close()
+		return
+	}
+	handshakeDeadline := newHandshakeDeadline(c.config.Proxy.HandshakeTimeout, localConn, conn.BrokerAddress, localDesc, log)
+	defer handshakeDeadline.complete()
+
 	if c.kafkaClientCert != nil {
 		err := handshakeAsTLSAndValidateClientCert(localConn, c.kafkaClientCert, c.config.Kafka.DialTimeout)
 
 		if err != nil {
-			logrus.Info(err.Error())
+			log.Info(err.Error())
+			_ = localConn.Close()
+			return
+		}
+	} else if tlsConn, ok := localConn.(*tls.Conn); ok && (handshakeDeadline != nil || c.config.Proxy.CredentialExpiry.Enable) {
+		// the handshake is otherwise done by the first read after the broker connection is established
+		if err := tlsConn.Handshake(); err != nil {
+			log.Infof("TLS handshake of %s failed: %v", localDesc, err)
 			_ = localConn.Close()
 			return
 		}
 	}
 
+	credentialExpiry := newCredentialExpiry(c.config.Proxy.CredentialExpiry.Enable, c.config.Proxy.CredentialExpiry.GracePeriod, localConn, conn.BrokerAddress, localDesc, log)
+	defer credentialExpiry.stop()
+	credentialExpiry.set(credentialClientCertificate, tlsPeerCertificateExpiry(localConn))
+
+	if c.config.Proxy.MaintenanceWindows.Contains(time.Now()) {
+		log.Infof("Maintenance window, refusing connection from %s (%s)", c.pseudonymizer.address(localConn.RemoteAddr()), conn.BrokerAddress)
+		_ = localConn.Close()
+		return
+	}
+
+	if c.processorConfig.AuthLimiter.banned(localConn.RemoteAddr()) {
+		log.Infof("Client address %s is banned, refusing connection (%s)", c.pseudonymizer.address(localConn.RemoteAddr()), conn.BrokerAddress)
+		proxyAuthBannedConnectionsTotal.WithLabelValues(conn.BrokerAddress).Inc()
+		_ = localConn.Close()
+		return
+	}
+
 	proxyConnectionsTotal.WithLabelValues(conn.BrokerAddress).Inc()
 
 	dialAddress := conn.BrokerAddress
 	if addressMapping, ok := c.dialAddressMapping[dialAddress]; ok {
 		dialAddress = addressMapping.DestinationAddress
-		logrus.Infof("Dial address changed from %s to %s", conn.BrokerAddress, dialAddress)
+		connLog(logSubsystemBroker, connID).Infof("Dial address changed from %s to %s", conn.BrokerAddress, dialAddress)
 	}
 
-	server, err := c.DialAndAuth(dialAddress)
+	var server net.Conn
+	var err error
+	if c.multiplexer != nil {
+		server, err = c.multiplexer.conn(conn.BrokerAddress)
+	} else {
+		server, err = c.dialBroker(conn.BrokerAddress)
+	}
 	if err != nil {
-		logrus.Infof("couldn't connect to %s(%s): %v", dialAddress, conn.BrokerAddress, err)
+		connLog(logSubsystemBroker, connID).Infof("couldn't connect to %s(%s): %v", dialAddress, conn.BrokerAddress, err)
 		_ = conn.LocalConnection.Close()
 		return
 	}
-	if tcpConn, ok := server.(*net.TCPConn); ok {
-		if err := c.tcpConnOptions.setTCPConnOptions(tcpConn); err != nil {
-			logrus.Infof("WARNING: Error while setting TCP options for kafka connection %s on %v: %v", conn.BrokerAddress, server.LocalAddr(), err)
-		}
-	}
 	c.conns.Add(conn.BrokerAddress, conn.LocalConnection)
-	localDesc := "local connection on " + conn.LocalConnection.LocalAddr().String() + " from " + conn.LocalConnection.RemoteAddr().String() + " (" + conn.BrokerAddress + ")"
-	copyThenClose(c.processorConfig, server, conn.LocalConnection, conn.BrokerAddress, conn.BrokerAddress, localDesc)
+	tracked := c.connections.add(conn, connID)
+	processorConfig := c.connProcessorConfig(conn)
+	processorConfig.HandshakeDeadline = handshakeDeadline
+	processorConfig.CredentialExpiry = credentialExpiry
+	processorConfig.BrokerReauth = newBrokerReauth(server, conn.BrokerAddress, connLog(logSubsystemBroker, connID))
+	processorConfig.Connection = tracked
+	copyThenClose(processorConfig, server, conn.LocalConnection, conn.BrokerAddress, conn.BrokerAddress, localDesc)
+	c.connections.remove(tracked)
 	if err := c.conns.Remove(conn.BrokerAddress, conn.LocalConnection); err != nil {
-		logrus.Info(err)
+		log.Info(err)
 	}
 }
 
+// Connections returns the active client connections
+func (c *Client) Connections() *Connections {
+	return c.connections
+}
+
+// AuthCache returns the cache of the auth plugin decisions, nil if decisions are not cached
+func (c *Client) AuthCache() *AuthCache {
+	return c.authCache
+}
+
+// connProcessorConfig returns the processor config with the request limits, the auth policy, the compression transcoding, the mirroring, the fault injection and the tenancy of the connection listener
+func (c *Client) connProcessorConfig(conn Conn) ProcessorConfig {
+	cfg := c.processorConfig
+	c.listenerAuth.apply(&cfg, conn.ListenerAddress, conn.BrokerAddress)
+	c.reloadLock.RLock()
+	limits := c.listenerRequestLimits.Limits(conn.ListenerAddress, conn.BrokerAddress, c.requestLimits)
+	c.reloadLock.RUnlock()
+	cfg.RequestLimits = NewRequestLimits(limits.MaxRequestSize, limits.MaxBatchSize)
+	if transcoding, ok := c.compressionTranscodings.Transcoding(conn.ListenerAddress, conn.BrokerAddress); ok {
+		cfg.CompressionTranscoding = NewCompressionTranscoding(transcoding)
+	}
+	if c.mirror.mirrored(conn.ListenerAddress, conn.BrokerAddress) {
+		cfg.Mirror = c.mirror
+	}
+	if c.chaos.selected(conn.ListenerAddress, conn.BrokerAddress) {
+		cfg.Chaos = c.chaos
+	}
+	if tenancy := c.tenancy.connection(conn.ListenerAddress, conn.BrokerAddress); tenancy != nil {
+		cfg.Tenancy = tenancy
+		cfg.MaxApiVersions = c.tenancy.maxApiVersions
+	}
+	if cfg.CompressionTranscoding.enabled() {
+		// the records of newer produce and fetch versions cannot be transcoded
+		cfg.MaxApiVersions = cfg.MaxApiVersions.Capped(protocol.RecordsMaxVersions)
+	}
+	if conn.Transparent {
+		// the clients connect to the broker addresses
+		cfg.NetAddressMappingFunc = nil
+	}
+	return cfg
+}
+
+// Reload applies the reloadable settings of the configuration. Forbidden api keys and quotas apply to the next requests of open connections,
+// the authentication ban and cache settings to the next authentications, request limits, SASL credentials and listener networks apply
+// to new connections.
+func (c *Client) Reload(cfg *config.Config) {
+	c.processorConfig.ApiKeyRules.Update(cfg.Kafka.ForbiddenApiKeys, cfg.Kafka.ScheduledForbiddenApiKeys)
+	c.processorConfig.Quotas.Update(cfg.Proxy.Quotas.Principals, cfg.Proxy.Quotas.Window)
+	c.processorConfig.AuthLimiter.Update(cfg.Auth.BruteForce.MaxFailures, cfg.Auth.BruteForce.Window, cfg.Auth.BruteForce.BanDuration)
+	c.authCache.Update(cfg.Auth.Cache.TTL, cfg.Auth.Cache.NegativeTTL, cfg.Auth.Cache.MaxEntries)
+	c.SetSASLCredentials(cfg.Kafka.SASL.Username, cfg.Kafka.SASL.Password)
+	c.networkACL.Update(cfg.Proxy.ListenerAllowCIDRs, cfg.Proxy.ListenerDenyCIDRs)
+
+	c.reloadLock.Lock()
+	defer c.reloadLock.Unlock()
+	c.requestLimits = cfg.Proxy.RequestLimits
+	c.listenerRequestLimits = cfg.Proxy.ListenerRequestLimits
+}
+
+// SetSASLCredentials replaces the credentials of the SASL PLAIN and SCRAM authentication used for new broker connections
+func (c *Client) SetSASLCredentials(username, password string) {
+	if c.saslCredentials == nil {
+		return
+	}
+	if c.saslCredentials.Set(username, password) {
+		logrus.Infof("SASL credentials of user %s are used for new broker connections", username)
+	}
+}
+
+// CheckUpstream succeeds when at least one of the brokers accepts a connection
+func (c *Client) CheckUpstream(brokerAddresses []string) error {
+	var lastErr error
+	for _, brokerAddress := range brokerAddresses {
+		for _, dialAddress := range c.dialAddresses(brokerAddress) {
+			conn, err := c.dialer.Dial("tcp", dialAddress)
+			if err != nil {
+				logrus.Warnf("couldn't connect to %s(%s): %v", dialAddress, brokerAddress, err)
+				lastErr = err
+				continue
+			}
+			_ = conn.Close()
+			return nil
+		}
+	}
+	if lastErr == nil {
+		return errors.New("no broker addresses to check")
+	}
+	return errors.Wrap(lastErr, "none of the brokers is reachable")
+}
+
+// CheckBroker connects to the broker and completes the TLS handshake and the gateway and SASL authentication
+func (c *Client) CheckBroker(brokerAddress string) error {
+	conn, err := c.retryDial(brokerAddress)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// dialAddresses returns the addresses of the dial address mapping in failover order or the broker address.
+// SRV addresses are replaced by the addresses of their targets.
+func (c *Client) dialAddresses(brokerAddress string) []string {
+	if addressMapping, ok := c.dialAddressMapping[brokerAddress]; ok {
+		return c.srvAddresses.expand(addressMapping.DialAddresses())
+	}
+	return []string{brokerAddress}
+}
+
+// retryDial dials and authenticates a broker connection, the dial addresses are resolved again for each retry
+func (c *Client) retryDial(brokerAddress string) (net.Conn, error) {
+	return c.dialRetry.dial(brokerAddress, func() []string { return c.dialAddresses(brokerAddress) }, c.DialAndAuth)
+}
+
+// dialBroker dials and authenticates a broker connection with the TCP options applied.
+// The dial addresses of the broker are tried in failover order and retried with backoff.
+func (c *Client) dialBroker(brokerAddress string) (net.Conn, error) {
+	server, err := c.retryDial(brokerAddress)
+	if err != nil {
+		return nil, err
+	}
+	if tcpConn, ok := tcpConnOf(server); ok {
+		if err := c.tcpConnOptions.setTCPConnOptions(tcpConn); err != nil {
+			logrus.Infof("WARNING: Error while setting TCP options for kafka connection %s on %v: %v", brokerAddress, server.LocalAddr(), err)
+		}
+	}
+	return server, nil
+}
+
 func (c *Client) DialAndAuth(brokerAddress string) (net.Conn, error) {
 	conn, err := c.dialer.Dial("tcp", brokerAddress)
 	if err != nil {
@@ -304,34 +773,44 @@ func (c *Client) DialAndAuth(brokerAddress string) (net.Conn, error) {
 		_ = conn.Close()
 		return nil, err
 	}
-	err = c.auth(conn)
+	lifetime, err := c.auth(conn)
 	if err != nil {
 		return nil, err
 	}
+	if lifetime > 0 {
+		return &saslSessionConn{Conn: conn, auth: c.saslAuthByProxy.(*SASLOAuthBearerAuth), lifetime: lifetime}, nil
+	}
 	return conn, nil
 }
 
-func (c *Client) auth(conn net.Conn) error {
+// auth returns the lifetime of the SASL session if the broker connection is re-authenticated, otherwise zero
+func (c *Client) auth(conn net.Conn) (time.Duration, error) {
 	if c.config.Auth.Gateway.Client.Enable {
 		if err := c.authClient.sendAndReceiveGatewayAuth(conn); err != nil {
 			_ = conn.Close()
-			return err
+			return 0, err
 		}
 		if err := conn.SetDeadline(time.Time{}); err != nil {
 			_ = conn.Close()
-			return err
+			return 0, err
 		}
 	}
+	var lifetime time.Duration
 	if c.config.Kafka.SASL.Enable {
-		err := c.saslAuthByProxy.sendAndReceiveSASLAuth(conn)
+		var err error
+		if oauth, ok := c.saslAuthByProxy.(*SASLOAuthBearerAuth); ok && oauth.reauthentication {
+			lifetime, err = oauth.authenticate(conn)
+		} else {
+			err = c.saslAuthByProxy.sendAndReceiveSASLAuth(conn)
+		}
 		if err != nil {
 			_ = conn.Close()
-			return err
+			return 0, err
 		}
 		if err := conn.SetDeadline(time.Time{}); err != nil {
 			_ = conn.Close()
-			return err
+			return 0, err
 		}
 	}
-	return nil
+	return lifetime, nil
 }
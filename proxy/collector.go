@@ -25,6 +25,183 @@ var (
 			Help: "Size of incoming responses"},
 		[]string{"broker"})
 
+	proxyGatewayServerAuthTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_gateway_server_auth_total",
+			Help: "Total number of gateway server token verifications. Status is empty when the verification failed with an error"},
+		[]string{"success", "status", "issuer"})
+
+	proxyGatewayClientAuthTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_gateway_client_auth_total",
+			Help: "Total number of gateway client authentications. Status rejected means the token was refused by the gateway server, status is empty when the token could not be obtained"},
+		[]string{"success", "status", "issuer"})
+
+	proxyInFlightRequests = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{Name: "proxy_inflight_requests",
+			Help:    "Number of pipelined requests awaiting a response, observed when a request is sent",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10)},
+		[]string{"broker"})
+
+	proxyInFlightWaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{Name: "proxy_inflight_wait_seconds",
+			Help: "Time a client connection was paused because the max in-flight requests limit was reached"},
+		[]string{"broker"})
+
+	proxyTopicWindowRecords = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "proxy_topic_window_records",
+			Help: "Number of records produced to the topic within the watermark window"},
+		[]string{"topic"})
+
+	proxyTopicWindowBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "proxy_topic_window_bytes",
+			Help: "Size of record batches produced to the topic within the watermark window"},
+		[]string{"topic"})
+
+	proxyTopicWatermarkAlert = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "proxy_topic_watermark_alert",
+			Help: "Whether the topic watermark alert is firing"},
+		[]string{"topic", "watermark"})
+
+	proxyTopicWatermarkAlertsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_topic_watermark_alerts_total",
+			Help: "Total number of raised topic watermark alerts"},
+		[]string{"topic", "watermark"})
+
+	proxyDeprecationThrottledResponsesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_deprecation_throttled_responses_total",
+			Help: "Total number of responses to deprecated clients with injected throttle time"},
+		[]string{"broker", "api_key", "api_version"})
+
+	proxyDialRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_dial_retries_total",
+			Help: "Total number of retried broker connections"},
+		[]string{"broker"})
+
+	proxyDialFailoversTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_dial_failovers_total",
+			Help: "Total number of broker connections established to a failover address of the dial address mapping"},
+		[]string{"broker", "address"})
+
+	proxyDialFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_dial_failures_total",
+			Help: "Total number of broker connections failed after all retries"},
+		[]string{"broker"})
+
+	proxyAuthBansTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_auth_bans_total",
+			Help: "Total number of client addresses banned after failed authentications"},
+		[]string{"broker"})
+
+	proxyAuthBannedConnectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_auth_banned_connections_total",
+			Help: "Total number of connections rejected from banned client addresses"},
+		[]string{"broker"})
+
+	proxyNetworkACLRejectedConnectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_network_acl_rejected_connections_total",
+			Help: "Total number of connections refused by the allowed and denied networks of the listener"},
+		[]string{"broker", "reason"})
+
+	proxyAuthCacheLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_auth_cache_lookups_total",
+			Help: "Total number of auth decision cache lookups. Scope is the cached plugin, result hit or miss"},
+		[]string{"scope", "result"})
+
+	proxyHandshakeTimeoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_handshake_timeouts_total",
+			Help: "Total number of client connections closed because the TLS handshake and authentication were not completed in time"},
+		[]string{"broker"})
+
+	proxyCredentialExpiredConnectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_credential_expired_connections_total",
+			Help: "Total number of client connections closed because the client certificate or the authentication token expired"},
+		[]string{"broker", "credential"})
+
+	proxyBrokerReauthenticationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_broker_reauthentications_total",
+			Help: "Total number of SASL re-authentications of broker connections by result"},
+		[]string{"broker", "result"})
+
+	proxyRequestLatencySeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{Name: "proxy_request_latency_seconds",
+			Help:    "Time between sending a request to the broker and receiving the response",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16)},
+		[]string{"broker", "api_key"})
+
+	proxySlowRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_slow_requests_total",
+			Help: "Total number of requests with a latency exceeding the slow request threshold"},
+		[]string{"broker", "api_key"})
+
+	proxyMirrorRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_mirror_requests_total",
+			Help: "Total number of produce requests mirrored to the second cluster by result. Result dropped means the queue was full, skipped means the request was transactional"},
+		[]string{"result"})
+
+	proxyMultiplexBrokerConnections = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "proxy_multiplex_broker_connections",
+			Help: "Number of pooled broker connections shared by the multiplexed client connections"},
+		[]string{"broker"})
+
+	proxyQuotaThrottledResponsesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_quota_throttled_responses_total",
+			Help: "Total number of responses to principals exceeding their quota with injected throttle time"},
+		[]string{"broker", "quota"})
+
+	proxyInterceptorDecisionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_interceptor_decisions_total",
+			Help: "Total number of interceptor decisions. Decision error means the interceptor call failed"},
+		[]string{"broker", "kind", "api_key", "decision"})
+
+	proxyInterceptorRecordBatchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_interceptor_record_batches_total",
+			Help: "Total number of produced record batches passed to the interceptor. Source cached means the headers were decoded before"},
+		[]string{"source"})
+
+	proxyPayloadEncryptionRecordsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_payload_encryption_records_total",
+			Help: "Total number of encrypted and decrypted record values"},
+		[]string{"topic", "operation"})
+
+	proxyPayloadEncryptionErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_payload_encryption_errors_total",
+			Help: "Total number of record values, batches or fetch responses (empty topic) which could not be encrypted or decrypted"},
+		[]string{"topic", "operation"})
+
+	proxyCompressionTranscodedBatchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_compression_transcoded_batches_total",
+			Help: "Total number of record batches recompressed with another codec"},
+		[]string{"broker", "from", "to"})
+
+	proxyCompressionTranscodingSecondsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_compression_transcoding_seconds_total",
+			Help: "Total time spent in transcoding produce requests and fetch responses"},
+		[]string{"broker", "api"})
+
+	proxyCompressionTranscodingErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_compression_transcoding_errors_total",
+			Help: "Total number of produce requests and fetch responses which could not be transcoded"},
+		[]string{"broker", "api"})
+
+	proxyTopicPolicyViolationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_topic_policy_violations_total",
+			Help: "Total number of topics rejected by the topic policy"},
+		[]string{"operation"})
+
+	proxyRequestLimitsRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_request_limits_rejected_total",
+			Help: "Total number of requests and produced partitions rejected by the request size or batch size limit"},
+		[]string{"broker", "reason"})
+
+	proxySchemaValidationRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_schema_validation_rejected_total",
+			Help: "Total number of produced partitions rejected by the schema validation"},
+		[]string{"topic", "reason"})
+
+	proxyChaosFaultsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "proxy_chaos_faults_total",
+			Help: "Total number of faults injected into the client connections"},
+		[]string{"broker", "fault"})
+
 	proxyOpenedConnections = prometheus.NewDesc(
 		"proxy_opened_connections",
 		"Number of opened connections",
@@ -43,6 +220,41 @@ func init() {
 	prometheus.MustRegister(proxyRequestsBytes)
 	prometheus.MustRegister(proxyResponsesBytes)
 	prometheus.MustRegister(proxyLocalAuthTotal)
+	prometheus.MustRegister(proxyGatewayServerAuthTotal)
+	prometheus.MustRegister(proxyGatewayClientAuthTotal)
+	prometheus.MustRegister(proxyInFlightRequests)
+	prometheus.MustRegister(proxyInFlightWaitSeconds)
+	prometheus.MustRegister(proxyTopicWindowRecords)
+	prometheus.MustRegister(proxyTopicWindowBytes)
+	prometheus.MustRegister(proxyTopicWatermarkAlert)
+	prometheus.MustRegister(proxyTopicWatermarkAlertsTotal)
+	prometheus.MustRegister(proxyDeprecationThrottledResponsesTotal)
+	prometheus.MustRegister(proxyQuotaThrottledResponsesTotal)
+	prometheus.MustRegister(proxyAuthBansTotal)
+	prometheus.MustRegister(proxyAuthBannedConnectionsTotal)
+	prometheus.MustRegister(proxyNetworkACLRejectedConnectionsTotal)
+	prometheus.MustRegister(proxyAuthCacheLookupsTotal)
+	prometheus.MustRegister(proxyHandshakeTimeoutsTotal)
+	prometheus.MustRegister(proxyCredentialExpiredConnectionsTotal)
+	prometheus.MustRegister(proxyBrokerReauthenticationsTotal)
+	prometheus.MustRegister(proxyRequestLatencySeconds)
+	prometheus.MustRegister(proxySlowRequestsTotal)
+	prometheus.MustRegister(proxyMirrorRequestsTotal)
+	prometheus.MustRegister(proxyMultiplexBrokerConnections)
+	prometheus.MustRegister(proxyDialRetriesTotal)
+	prometheus.MustRegister(proxyDialFailoversTotal)
+	prometheus.MustRegister(proxyDialFailuresTotal)
+	prometheus.MustRegister(proxyInterceptorDecisionsTotal)
+	prometheus.MustRegister(proxyInterceptorRecordBatchesTotal)
+	prometheus.MustRegister(proxyTopicPolicyViolationsTotal)
+	prometheus.MustRegister(proxyRequestLimitsRejectedTotal)
+	prometheus.MustRegister(proxySchemaValidationRejectedTotal)
+	prometheus.MustRegister(proxyPayloadEncryptionRecordsTotal)
+	prometheus.MustRegister(proxyPayloadEncryptionErrorsTotal)
+	prometheus.MustRegister(proxyCompressionTranscodedBatchesTotal)
+	prometheus.MustRegister(proxyCompressionTranscodingSecondsTotal)
+	prometheus.MustRegister(proxyCompressionTranscodingErrorsTotal)
+	prometheus.MustRegister(proxyChaosFaultsTotal)
 }
 
 type proxyCollector struct {
@@ -101,19 +101,42 @@ func myCopyN(dst io.Writer, src io.Reader, size int64, buf []byte) (readErr bool
 	return
 }
 
-func copyError(readDesc, writeDesc string, readErr bool, err error) {
+// forwardingReader writes the read bytes to the writer and records whether a read or a write failed
+type forwardingReader struct {
+	reader   io.Reader
+	writer   io.Writer
+	readErr  error
+	writeErr error
+}
+
+func (r *forwardingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if _, werr := r.writer.Write(p[:n]); werr != nil {
+			r.writeErr = werr
+			return n, werr
+		}
+	}
+	if err != nil && err != io.EOF {
+		r.readErr = err
+	}
+	return n, err
+}
+
+func copyError(log *logrus.Entry, readDesc, writeDesc string, readErr bool, err error) {
 	var desc string
 	if readErr {
 		desc = "Reading data from " + readDesc
 	} else {
 		desc = "Writing data to " + writeDesc
 	}
-	logrus.Infof("%v had error: %s", desc, err.Error())
+	log.Infof("%v had error: %s", desc, err.Error())
 }
 
 func copyThenClose(cfg ProcessorConfig, remote, local DeadlineReadWriteCloser, brokerAddress string, remoteDesc, localDesc string) {
 
 	processor := newProcessor(cfg, brokerAddress)
+	log := cfg.Connection.log(logSubsystemConnection)
 
 	firstErr := make(chan error, 1)
 
@@ -122,9 +145,9 @@ func copyThenClose(cfg ProcessorConfig, remote, local DeadlineReadWriteCloser, b
 		select {
 		case firstErr <- err:
 			if readErr && err == io.EOF {
-				logrus.Infof("Client closed %v", localDesc)
+				log.Infof("Client closed %v", localDesc)
 			} else {
-				copyError(localDesc, remoteDesc, readErr, err)
+				copyError(log, localDesc, remoteDesc, readErr, err)
 			}
 			remote.Close()
 			local.Close()
@@ -136,9 +159,9 @@ func copyThenClose(cfg ProcessorConfig, remote, local DeadlineReadWriteCloser, b
 	select {
 	case firstErr <- err:
 		if readErr && err == io.EOF {
-			logrus.Infof("Server %v closed connection", remoteDesc)
+			log.Infof("Server %v closed connection", remoteDesc)
 		} else {
-			copyError(remoteDesc, localDesc, readErr, err)
+			copyError(log, remoteDesc, localDesc, readErr, err)
 		}
 		remote.Close()
 		local.Close()
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+)
+
+// CompressionTranscoding recompresses the record batches of fetch responses compressed with the broker codec with the client codec
+// and the record batches of produce requests compressed with the client codec with the broker codec.
+// Clients which cannot decompress the broker codec consume and produce with the client codec.
+type CompressionTranscoding struct {
+	brokerCodec     int
+	clientCodec     int
+	brokerCodecName string
+	clientCodecName string
+}
+
+func NewCompressionTranscoding(transcoding config.CompressionTranscoding) *CompressionTranscoding {
+	brokerCodec, ok := protocol.CompressionCodecs[transcoding.BrokerCodec]
+	if !ok {
+		return nil
+	}
+	clientCodec, ok := protocol.CompressionCodecs[transcoding.ClientCodec]
+	if !ok || clientCodec == brokerCodec {
+		return nil
+	}
+	return &CompressionTranscoding{
+		brokerCodec:     brokerCodec,
+		clientCodec:     clientCodec,
+		brokerCodecName: transcoding.BrokerCodec,
+		clientCodecName: transcoding.ClientCodec,
+	}
+}
+
+func (t *CompressionTranscoding) enabled() bool {
+	return t != nil
+}
+
+// transcodeProduceRequest returns the produce request body starting after the api key and version with the record batches
+// compressed with the broker codec
+func (t *CompressionTranscoding) transcodeProduceRequest(apiVersion int16, body []byte, brokerAddress string) ([]byte, error) {
+	defer t.observe(time.Now(), brokerAddress, "produce")
+
+	request := &protocol.ProduceRequest{Version: apiVersion}
+	if err := protocol.Decode(body, request); err != nil {
+		return nil, err
+	}
+	transcoded := 0
+	for i, topicData := range request.TopicData {
+		for j, partitionData := range topicData.PartitionData {
+			records, count, err := protocol.TranscodeRecordBatches(partitionData.Records, t.clientCodec, t.brokerCodec)
+			if err != nil {
+				proxyCompressionTranscodingErrorsTotal.WithLabelValues(brokerAddress, "produce").Inc()
+				return nil, err
+			}
+			request.TopicData[i].PartitionData[j].Records = records
+			transcoded += count
+		}
+	}
+	if transcoded == 0 {
+		return body, nil
+	}
+	proxyCompressionTranscodedBatchesTotal.WithLabelValues(brokerAddress, t.clientCodecName, t.brokerCodecName).Add(float64(transcoded))
+	return protocol.Encode(request)
+}
+
+// transcodeFetchResponse returns the fetch response body following the response header with the record batches
+// compressed with the client codec
+func (t *CompressionTranscoding) transcodeFetchResponse(apiVersion int16, body []byte, brokerAddress string) ([]byte, error) {
+	defer t.observe(time.Now(), brokerAddress, "fetch")
+
+	response := &protocol.FetchResponse{Version: apiVersion}
+	if err := protocol.Decode(body, response); err != nil {
+		return nil, err
+	}
+	transcoded := 0
+	for i, topicResponse := range response.Responses {
+		for j, partition := range topicResponse.Partitions {
+			records, count, err := protocol.TranscodeRecordBatches(partition.Records, t.brokerCodec, t.clientCodec)
+			if err != nil {
+				proxyCompressionTranscodingErrorsTotal.WithLabelValues(brokerAddress, "fetch").Inc()
+				return nil, err
+			}
+			response.Responses[i].Partitions[j].Records = records
+			transcoded += count
+		}
+	}
+	if transcoded == 0 {
+		return body, nil
+	}
+	proxyCompressionTranscodedBatchesTotal.WithLabelValues(brokerAddress, t.brokerCodecName, t.clientCodecName).Add(float64(transcoded))
+	return protocol.Encode(response)
+}
+
+// observe adds the time spent in decoding, decompression, compression and encoding, which is mostly CPU time
+func (t *CompressionTranscoding) observe(start time.Time, brokerAddress string, api string) {
+	proxyCompressionTranscodingSecondsTotal.WithLabelValues(brokerAddress, api).Add(time.Since(start).Seconds())
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchCodec returns the compression codec of the first record batch (low bits of the attributes at offset 21)
+func batchCodec(records []byte) byte {
+	return records[22] & 0x07
+}
+
+func TestCompressionTranscodingRoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	transcoding := NewCompressionTranscoding(config.CompressionTranscoding{BrokerCodec: "zstd", ClientCodec: "none"})
+	clientID := "producer"
+
+	produceRequest := &protocol.ProduceRequest{
+		Version:       7,
+		CorrelationID: 1,
+		ClientID:      &clientID,
+		Acks:          -1,
+		Timeout:       1000,
+		TopicData: []protocol.ProduceTopicData{
+			{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: uncompressedRecordBatch("order")}}},
+		},
+	}
+	body, err := protocol.Encode(produceRequest)
+	a.Nil(err)
+
+	transcodedBody, err := transcoding.transcodeProduceRequest(7, body, "kafka-0:9092")
+	a.Nil(err)
+	transcodedRequest := &protocol.ProduceRequest{Version: 7}
+	a.Nil(protocol.Decode(transcodedBody, transcodedRequest))
+	records := transcodedRequest.TopicData[0].PartitionData[0].Records
+	a.Equal(byte(4), batchCodec(records), "produced batches are compressed with zstd")
+
+	fetchResponse := &protocol.FetchResponse{
+		Version: 11,
+		Responses: []protocol.FetchTopicResponse{
+			{Topic: "orders", Partitions: []protocol.FetchPartitionResponse{{Partition: 0, HighWatermark: 1, Records: records}}},
+		},
+	}
+	body, err = protocol.Encode(fetchResponse)
+	a.Nil(err)
+
+	transcodedBody, err = transcoding.transcodeFetchResponse(11, body, "kafka-0:9092")
+	a.Nil(err)
+	transcodedResponse := &protocol.FetchResponse{Version: 11}
+	a.Nil(protocol.Decode(transcodedBody, transcodedResponse))
+	records = transcodedResponse.Responses[0].Partitions[0].Records
+	a.Equal(byte(0), batchCodec(records), "fetched batches are decompressed")
+	a.Equal([]string{"order"}, recordBatchValues(t, records))
+
+	// responses without batches of the broker codec are not changed
+	transcodedBody, err = transcoding.transcodeFetchResponse(11, transcodedBody, "kafka-0:9092")
+	a.Nil(err)
+	body, err = protocol.Encode(transcodedResponse)
+	a.Nil(err)
+	a.Equal(body, transcodedBody)
+}
+
+func TestCompressionTranscodingDisabled(t *testing.T) {
+	a := assert.New(t)
+
+	var transcoding *CompressionTranscoding
+	a.False(transcoding.enabled())
+	a.Nil(NewCompressionTranscoding(config.CompressionTranscoding{BrokerCodec: "zstd", ClientCodec: "zstd"}))
+	a.Nil(NewCompressionTranscoding(config.CompressionTranscoding{BrokerCodec: "brotli", ClientCodec: "gzip"}))
+}
+
+func TestCompressionTranscodingMaxApiVersions(t *testing.T) {
+	a := assert.New(t)
+
+	c := &Client{
+		processorConfig:         ProcessorConfig{MaxApiVersions: config.MaxApiVersions{1: 13, 3: 9}},
+		compressionTranscodings: config.ListenerCompressionTranscodings{"0.0.0.0:32400": {BrokerCodec: "zstd", ClientCodec: "gzip"}},
+	}
+	cfg := c.connProcessorConfig(Conn{ListenerAddress: "0.0.0.0:32400", BrokerAddress: "kafka-0:9092"})
+	a.True(cfg.CompressionTranscoding.enabled())
+	a.Equal(config.MaxApiVersions{0: 8, 1: 11, 3: 9}, cfg.MaxApiVersions)
+
+	// the other listeners advertise the configured versions
+	cfg = c.connProcessorConfig(Conn{ListenerAddress: "0.0.0.0:32401", BrokerAddress: "kafka-1:9092"})
+	a.False(cfg.CompressionTranscoding.enabled())
+	a.Equal(config.MaxApiVersions{1: 13, 3: 9}, cfg.MaxApiVersions)
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConnectionInfo describes an active client connection
+type ConnectionInfo struct {
+	ID              string    `json:"id"`
+	ClientAddress   string    `json:"client_address"`
+	ListenerAddress string    `json:"listener_address"`
+	BrokerAddress   string    `json:"broker_address"`
+	Principal       string    `json:"principal"`
+	Started         time.Time `json:"started"`
+	AgeSeconds      float64   `json:"age_seconds"`
+	RequestBytes    int64     `json:"request_bytes"`
+	ResponseBytes   int64     `json:"response_bytes"`
+}
+
+// trackedConnection is the state of a client connection shared by its requests and responses loops
+type trackedConnection struct {
+	requestBytes  int64
+	responseBytes int64
+
+	id      uint64
+	conn    net.Conn
+	broker  string
+	address string
+	started time.Time
+
+	mu        sync.RWMutex
+	principal string
+}
+
+func (c *trackedConnection) addRequestBytes(n int32) {
+	if c != nil {
+		atomic.AddInt64(&c.requestBytes, int64(n))
+	}
+}
+
+func (c *trackedConnection) addResponseBytes(n int32) {
+	if c != nil {
+		atomic.AddInt64(&c.responseBytes, int64(n))
+	}
+}
+
+// log returns the log entry of the subsystem with the connection id
+func (c *trackedConnection) log(subsystem string) *logrus.Entry {
+	if c == nil {
+		return subsystemLog(subsystem)
+	}
+	return connLog(subsystem, c.id)
+}
+
+// setPrincipal sets the SASL user authenticated by the proxy or the principal of the gateway token
+func (c *trackedConnection) setPrincipal(principal string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.principal = principal
+}
+
+func (c *trackedConnection) info(now time.Time, pseudonymizer *Pseudonymizer) ConnectionInfo {
+	c.mu.RLock()
+	principal := c.principal
+	c.mu.RUnlock()
+	if principal == "" {
+		// the client certificate is known after the TLS handshake
+		principal = tlsPeerPrincipal(c.conn)
+	}
+	return ConnectionInfo{
+		ID:              strconv.FormatUint(c.id, 10),
+		ClientAddress:   pseudonymizer.address(c.conn.RemoteAddr()),
+		ListenerAddress: c.address,
+		BrokerAddress:   c.broker,
+		Principal:       pseudonymizer.principal(principal),
+		Started:         c.started,
+		AgeSeconds:      now.Sub(c.started).Seconds(),
+		RequestBytes:    atomic.LoadInt64(&c.requestBytes),
+		ResponseBytes:   atomic.LoadInt64(&c.responseBytes),
+	}
+}
+
+// Connections tracks the active client connections, which can be listed and closed by the admin endpoint
+type Connections struct {
+	// last assigned connection id, first for the 64-bit alignment of atomic operations
+	next uint64
+
+	lock  sync.RWMutex
+	conns map[uint64]*trackedConnection
+	// client addresses and principals in the list like in the logs
+	pseudonymizer *Pseudonymizer
+}
+
+func NewConnections(pseudonymizer *Pseudonymizer) *Connections {
+	return &Connections{conns: make(map[uint64]*trackedConnection), pseudonymizer: pseudonymizer}
+}
+
+// newID returns the id of a new client connection, which is assigned on accept so it identifies the log entries of the connection
+func (c *Connections) newID() uint64 {
+	return atomic.AddUint64(&c.next, 1)
+}
+
+func (c *Connections) add(conn Conn, id uint64) *trackedConnection {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tracked := &trackedConnection{id: id, conn: conn.LocalConnection, broker: conn.BrokerAddress, address: conn.ListenerAddress, started: time.Now()}
+	c.conns[tracked.id] = tracked
+	return tracked
+}
+
+func (c *Connections) remove(tracked *trackedConnection) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.conns, tracked.id)
+}
+
+// List returns the active connections ordered by their start, the client addresses and principals are pseudonymized if enabled
+func (c *Connections) List() []ConnectionInfo {
+	c.lock.RLock()
+	tracked := make([]*trackedConnection, 0, len(c.conns))
+	for _, v := range c.conns {
+		tracked = append(tracked, v)
+	}
+	c.lock.RUnlock()
+
+	sort.Slice(tracked, func(i, j int) bool { return tracked[i].id < tracked[j].id })
+	now := time.Now()
+	result := make([]ConnectionInfo, 0, len(tracked))
+	for _, v := range tracked {
+		result = append(result, v.info(now, c.pseudonymizer))
+	}
+	return result
+}
+
+// Close closes the client connection with the id, the broker connection is closed by the proxy. It returns false if the connection is not active.
+func (c *Connections) Close(id string) bool {
+	key, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return false
+	}
+	c.lock.RLock()
+	tracked, ok := c.conns[key]
+	c.lock.RUnlock()
+	if !ok {
+		return false
+	}
+	_ = tracked.conn.Close()
+	return true
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnections(t *testing.T) {
+	a := assert.New(t)
+
+	connections := NewConnections(nil)
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	first := connections.add(Conn{BrokerAddress: "kafka-0:9092", ListenerAddress: "127.0.0.1:32400", LocalConnection: local}, connections.newID())
+	second := connections.add(Conn{BrokerAddress: "kafka-1:9092", ListenerAddress: "127.0.0.1:32401", LocalConnection: local}, connections.newID())
+	first.setPrincipal("alice")
+	first.addRequestBytes(100)
+	first.addResponseBytes(250)
+	first.addResponseBytes(50)
+
+	list := connections.List()
+	a.Len(list, 2)
+	a.Equal("1", list[0].ID)
+	a.Equal("kafka-0:9092", list[0].BrokerAddress)
+	a.Equal("127.0.0.1:32400", list[0].ListenerAddress)
+	a.Equal("alice", list[0].Principal)
+	a.Equal(int64(100), list[0].RequestBytes)
+	a.Equal(int64(300), list[0].ResponseBytes)
+	a.Equal("2", list[1].ID)
+	a.Equal("", list[1].Principal)
+
+	connections.remove(second)
+	a.Len(connections.List(), 1)
+	a.False(connections.Close("2"))
+	a.False(connections.Close("x"))
+
+	a.True(connections.Close("1"))
+	_, err := local.Write([]byte{0})
+	a.NotNil(err)
+}
+
+func TestConnectionsPseudonymized(t *testing.T) {
+	a := assert.New(t)
+
+	pseudonymizer := NewPseudonymizer([]byte("0123456789abcdef"))
+	connections := NewConnections(pseudonymizer)
+	local, remote := net.Pipe()
+	defer remote.Close()
+	defer local.Close()
+
+	tracked := connections.add(Conn{BrokerAddress: "kafka-0:9092", ListenerAddress: "127.0.0.1:32400", LocalConnection: local}, connections.newID())
+	tracked.setPrincipal("alice")
+
+	list := connections.List()
+	a.Len(list, 1)
+	a.Equal(pseudonymizer.principal("alice"), list[0].Principal)
+	a.Equal(pseudonymizer.address(local.RemoteAddr()), list[0].ClientAddress)
+	a.NotEqual(local.RemoteAddr().String(), list[0].ClientAddress)
+	a.Equal("127.0.0.1:32400", list[0].ListenerAddress)
+}
+
+func TestTrackedConnectionDisabled(t *testing.T) {
+	var tracked *trackedConnection
+	tracked.setPrincipal("alice")
+	tracked.addRequestBytes(1)
+	tracked.addResponseBytes(1)
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	credentialClientCertificate = "client certificate"
+	credentialGatewayToken      = "gateway token"
+	credentialSASLToken         = "SASL token"
+)
+
+// credentialExpiry closes the client connection when one of its credentials (client certificate, gateway token or
+// SASL token) expires, so long-lived connections do not outlive the credentials they were authenticated with.
+// The connection is closed after the grace period following the earliest expiry. A nil credentialExpiry enforces nothing.
+type credentialExpiry struct {
+	gracePeriod   time.Duration
+	conn          io.Closer
+	brokerAddress string
+	connDesc      string
+	log           *logrus.Entry
+
+	lock     sync.Mutex
+	expiries map[string]time.Time
+	timer    *time.Timer
+	stopped  bool
+}
+
+func newCredentialExpiry(enable bool, gracePeriod time.Duration, conn io.Closer, brokerAddress string, connDesc string, log *logrus.Entry) *credentialExpiry {
+	if !enable {
+		return nil
+	}
+	return &credentialExpiry{
+		gracePeriod:   gracePeriod,
+		conn:          conn,
+		brokerAddress: brokerAddress,
+		connDesc:      connDesc,
+		log:           log,
+		expiries:      make(map[string]time.Time),
+	}
+}
+
+// set replaces the expiry of the credential, a zero expiry means the credential does not expire
+func (e *credentialExpiry) set(credential string, expiry time.Time) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.stopped {
+		return
+	}
+	if expiry.IsZero() {
+		delete(e.expiries, credential)
+	} else {
+		e.expiries[credential] = expiry
+	}
+	e.schedule()
+}
+
+// schedule (re)starts the timer for the earliest expiry, it must be called with the lock held
+func (e *credentialExpiry) schedule() {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	var credential string
+	var earliest time.Time
+	for k, v := range e.expiries {
+		if earliest.IsZero() || v.Before(earliest) {
+			credential, earliest = k, v
+		}
+	}
+	if earliest.IsZero() {
+		return
+	}
+	e.timer = time.AfterFunc(time.Until(earliest.Add(e.gracePeriod)), func() {
+		e.lock.Lock()
+		if e.stopped {
+			e.lock.Unlock()
+			return
+		}
+		e.stopped = true
+		e.lock.Unlock()
+		e.log.Infof("The %s of %s expired at %v, closing connection", credential, e.connDesc, earliest.UTC().Format(time.RFC3339))
+		proxyCredentialExpiredConnectionsTotal.WithLabelValues(e.brokerAddress, credential).Inc()
+		_ = e.conn.Close()
+	})
+}
+
+// stop stops the timer when the connection is closed
+func (e *credentialExpiry) stop() {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.stopped = true
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+}
+
+// tlsPeerCertificateExpiry returns the expiry of the client certificate or zero if the connection is not mutual TLS.
+// The TLS handshake must be completed.
+func tlsPeerCertificateExpiry(conn net.Conn) time.Time {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return time.Time{}
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return time.Time{}
+	}
+	return certs[0].NotAfter
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialExpiryClosesConnection(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	expiry := newCredentialExpiry(true, 0, local, "kafka-0:9092", "test connection", subsystemLog(logSubsystemConnection))
+	expiry.set(credentialClientCertificate, time.Now().Add(time.Hour))
+	expiry.set(credentialSASLToken, time.Now().Add(50*time.Millisecond))
+	_, err := local.Read(make([]byte, 1))
+	assert.Error(t, err, "the connection is closed when the earliest credential expires")
+	expiry.stop()
+}
+
+func TestCredentialExpiryReplaced(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	expiry := newCredentialExpiry(true, 0, local, "kafka-0:9092", "test connection", subsystemLog(logSubsystemConnection))
+	defer expiry.stop()
+	expiry.set(credentialSASLToken, time.Now().Add(50*time.Millisecond))
+	expiry.set(credentialSASLToken, time.Now().Add(time.Hour))
+	expiry.set(credentialClientCertificate, time.Time{})
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_, _ = remote.Write([]byte{1})
+	}()
+	n, err := local.Read(make([]byte, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestCredentialExpiryGracePeriodAndStop(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	expiry := newCredentialExpiry(true, time.Hour, local, "kafka-0:9092", "test connection", subsystemLog(logSubsystemConnection))
+	expiry.set(credentialGatewayToken, time.Now().Add(-time.Minute))
+	expiry.stop()
+	expiry.set(credentialSASLToken, time.Now().Add(-time.Minute))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = remote.Write([]byte{1})
+	}()
+	_, err := local.Read(make([]byte, 1))
+	assert.NoError(t, err, "the connection is not closed within the grace period or after stop")
+}
+
+func TestCredentialExpiryDisabled(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	expiry := newCredentialExpiry(false, 0, local, "kafka-0:9092", "test connection", subsystemLog(logSubsystemConnection))
+	assert.Nil(t, expiry)
+	expiry.set(credentialSASLToken, time.Now())
+	expiry.stop()
+	assert.True(t, tlsPeerCertificateExpiry(local).IsZero())
+}
+
+func TestLocalSaslOauthTokenExpiry(t *testing.T) {
+	a := assert.New(t)
+
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := unsignedToken(fmt.Sprintf(`{"sub":"alice","exp":%d}`, exp.Unix()))
+	oauth := NewLocalSaslOauth(&testTokenInfo{token: token, subject: "alice"})
+	principal, expiry, err := oauth.doLocalAuth([]byte("n,a=bob,\x01auth=Bearer " + token + "\x01\x01"))
+	a.Nil(err)
+	a.Equal("alice", principal)
+	a.True(exp.Equal(expiry))
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"sort"
+)
+
+// ListenerState is an entry of the listener table of the debug endpoint
+type ListenerState struct {
+	BrokerAddress     string `json:"broker_address"`
+	ListenerAddress   string `json:"listener_address"`
+	AdvertisedAddress string `json:"advertised_address"`
+	// bootstrap, external or dynamic
+	Kind string `json:"kind"`
+}
+
+// ClientState is a snapshot of the connection and cache sizes of the debug endpoint
+type ClientState struct {
+	Connections             int `json:"connections"`
+	PooledBrokerConnections int `json:"pooled_broker_connections"`
+	AuthCacheEntries        int `json:"auth_cache_entries"`
+	SRVCacheEntries         int `json:"srv_cache_entries"`
+	MirrorQueueLength       int `json:"mirror_queue_length"`
+}
+
+// ListenerStates returns the listeners of the bootstrap server mappings followed by the external server mappings and the
+// dynamic listeners, ordered by the broker address
+func (p *Listeners) ListenerStates() []ListenerState {
+	bootstrap := p.BootstrapListeners()
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	result := make([]ListenerState, 0, len(p.brokerToListenerConfig)+len(bootstrap))
+	bootstrapBrokers := make(map[string]bool, len(bootstrap))
+	for _, v := range bootstrap {
+		bootstrapBrokers[v.BrokerAddress] = true
+		result = append(result, ListenerState{BrokerAddress: v.BrokerAddress, ListenerAddress: v.ListenerAddress, AdvertisedAddress: v.AdvertisedAddress, Kind: "bootstrap"})
+	}
+	var others []ListenerState
+	for brokerAddress, v := range p.brokerToListenerConfig {
+		if bootstrapBrokers[brokerAddress] {
+			continue
+		}
+		kind := "external"
+		if _, ok := p.dynamicBrokers[brokerAddress]; ok {
+			kind = "dynamic"
+		}
+		others = append(others, ListenerState{BrokerAddress: v.BrokerAddress, ListenerAddress: v.ListenerAddress, AdvertisedAddress: v.AdvertisedAddress, Kind: kind})
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].BrokerAddress < others[j].BrokerAddress })
+	return append(result, others...)
+}
+
+// State returns the connection and cache sizes
+func (c *Client) State() ClientState {
+	return ClientState{
+		Connections:             c.connections.len(),
+		PooledBrokerConnections: c.multiplexer.len(),
+		AuthCacheEntries:        c.authCache.len(),
+		SRVCacheEntries:         c.srvAddresses.len(),
+		MirrorQueueLength:       c.mirror.queueLength(),
+	}
+}
+
+func (c *Connections) len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.conns)
+}
+
+func (m *Multiplexer) len() int {
+	if m == nil {
+		return 0
+	}
+	m.lock.Lock()
+	pools := make([]*brokerPool, 0, len(m.pools))
+	for _, pool := range m.pools {
+		pools = append(pools, pool)
+	}
+	m.lock.Unlock()
+	n := 0
+	for _, pool := range pools {
+		pool.lock.Lock()
+		n += len(pool.conns)
+		pool.lock.Unlock()
+	}
+	return n
+}
+
+func (c *AuthCache) len() int {
+	if !c.enabled() {
+		return 0
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.entries)
+}
+
+func (s *SRVAddresses) len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.entries)
+}
+
+func (m *Mirror) queueLength() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.queue)
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerStates(t *testing.T) {
+	a := assert.New(t)
+
+	cfg := &config.Config{}
+	cfg.Proxy.DefaultListenerIP = "127.0.0.1"
+	cfg.Proxy.BootstrapServers = []config.ListenerConfig{
+		{BrokerAddress: "kafka-0:9092", ListenerAddress: "127.0.0.1:0", AdvertisedAddress: "127.0.0.1:0"},
+	}
+	cfg.Proxy.ExternalServers = []config.ListenerConfig{
+		{BrokerAddress: "kafka-1:9092", ListenerAddress: "proxy-1:32401", AdvertisedAddress: "proxy-1:32401"},
+	}
+	listeners, err := NewListeners(cfg)
+	a.Nil(err)
+	_, err = listeners.ListenInstances(cfg.Proxy.BootstrapServers)
+	a.Nil(err)
+	_, _, err = listeners.GetNetAddressMapping("kafka-2", 9092)
+	a.Nil(err)
+	defer func() {
+		for _, l := range listeners.staticListeners {
+			_ = l.Close()
+		}
+	}()
+
+	states := listeners.ListenerStates()
+	a.Len(states, 3)
+	a.Equal("kafka-0:9092", states[0].BrokerAddress)
+	a.Equal("bootstrap", states[0].Kind)
+	a.NotEqual("127.0.0.1:0", states[0].ListenerAddress)
+	a.Equal(ListenerState{BrokerAddress: "kafka-1:9092", ListenerAddress: "proxy-1:32401", AdvertisedAddress: "proxy-1:32401", Kind: "external"}, states[1])
+	a.Equal("kafka-2:9092", states[2].BrokerAddress)
+	a.Equal("dynamic", states[2].Kind)
+}
+
+func TestClientState(t *testing.T) {
+	a := assert.New(t)
+
+	client := &Client{connections: NewConnections(nil), srvAddresses: NewSRVAddresses(&testSRVResolver{})}
+	client.connections.add(Conn{BrokerAddress: "kafka-0:9092"}, client.connections.newID())
+	client.srvAddresses.expand([]string{"srv://_kafka._tcp.example.com"})
+	client.mirror = newTestMirror("127.0.0.1:1", nil, 10)
+	client.mirror.enqueue(3, testProduceRequestBody(t, nil, 0))
+
+	a.Equal(ClientState{Connections: 1, SRVCacheEntries: 1, MirrorQueueLength: 1}, client.State())
+}
@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+)
+
+const (
+	throttleTimeNone = iota
+	// throttle_time_ms is the first field of the response body
+	throttleTimeFirst
+	// throttle_time_ms is the last field of the response body
+	throttleTimeLast
+)
+
+// minimal response versions with throttle_time_ms as the first field of the response body
+var throttleTimeFirstVersions = map[int16]int16{
+	1:  1, // Fetch
+	2:  2, // ListOffsets
+	3:  3, // Metadata
+	8:  3, // OffsetCommit
+	9:  3, // OffsetFetch
+	10: 1, // FindCoordinator
+	11: 2, // JoinGroup
+	12: 1, // Heartbeat
+	13: 1, // LeaveGroup
+	14: 1, // SyncGroup
+	15: 1, // DescribeGroups
+	16: 1, // ListGroups
+	19: 2, // CreateTopics
+	20: 1, // DeleteTopics
+	21: 0, // DeleteRecords
+	22: 0, // InitProducerId
+	24: 0, // AddPartitionsToTxn
+	25: 0, // AddOffsetsToTxn
+	26: 0, // EndTxn
+	28: 0, // TxnOffsetCommit
+	29: 0, // DescribeAcls
+	30: 0, // CreateAcls
+	31: 0, // DeleteAcls
+	32: 0, // DescribeConfigs
+	33: 0, // AlterConfigs
+}
+
+func throttleTimePosition(apiKey int16, apiVersion int16) int {
+	if apiKey == apiKeyProduce {
+		// v9+ is flexible and ends with tagged fields
+		if apiVersion >= 1 && apiVersion <= 8 {
+			return throttleTimeLast
+		}
+		return throttleTimeNone
+	}
+	if minVersion, ok := throttleTimeFirstVersions[apiKey]; ok && apiVersion >= minVersion {
+		return throttleTimeFirst
+	}
+	return throttleTimeNone
+}
+
+// Deprecation selects clients using deprecated client ids or api versions. Their responses carry an artificial throttle_time_ms,
+// which clients supporting KIP-219 honour by delaying further requests, so they are nudged to migrate without being blocked.
+// The proxy delays the next request of the connection by the throttle time as well, as older clients ignore throttle_time_ms.
+type Deprecation struct {
+	throttleTimeMs int32
+	clientIDs      map[string]struct{}
+	minApiVersions config.MinApiVersions
+}
+
+func NewDeprecation(throttleTime time.Duration, clientIDs []string, minApiVersions config.MinApiVersions) *Deprecation {
+	d := &Deprecation{
+		throttleTimeMs: int32(throttleTime / time.Millisecond),
+		clientIDs:      make(map[string]struct{}),
+		minApiVersions: minApiVersions,
+	}
+	for _, clientID := range clientIDs {
+		d.clientIDs[clientID] = struct{}{}
+	}
+	return d
+}
+
+func (d *Deprecation) enabled() bool {
+	return d != nil && d.throttleTimeMs > 0 && (len(d.clientIDs) != 0 || len(d.minApiVersions) != 0)
+}
+
+func (d *Deprecation) clientIDsEnabled() bool {
+	return d.enabled() && len(d.clientIDs) != 0
+}
+
+func (d *Deprecation) matchClientID(clientID string) bool {
+	if d == nil || clientID == "" {
+		return false
+	}
+	_, ok := d.clientIDs[clientID]
+	return ok
+}
+
+// throttleTime returns throttle_time_ms to inject into the response or 0. The connection is throttled for the returned time.
+func (d *Deprecation) throttleTime(state *deprecationState, requestKeyVersion *protocol.RequestKeyVersion, now time.Time) int32 {
+	if !d.enabled() || state == nil || atomic.LoadInt32(&state.exempt) == 1 {
+		return 0
+	}
+	if atomic.LoadInt32(&state.deprecatedClientID) == 1 || d.minApiVersions.Deprecated(requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion) {
+		throttleUntil(&state.throttledUntil, now.Add(time.Duration(d.throttleTimeMs)*time.Millisecond))
+		return d.throttleTimeMs
+	}
+	return 0
+}
+
+// deprecationState is shared by the requests and responses loops of a connection
+type deprecationState struct {
+	// passthrough client
+	exempt             int32
+	deprecatedClientID int32
+	// unix nanos until the next request is read
+	throttledUntil int64
+}
+
+// wait delays the next request of a throttled connection
+func (s *deprecationState) wait() {
+	if s == nil {
+		return
+	}
+	waitUntil(&s.throttledUntil)
+}
+
+func (s *deprecationState) update(exempt bool, deprecatedClientID bool) {
+	atomic.StoreInt32(&s.exempt, boolToInt32(exempt))
+	atomic.StoreInt32(&s.deprecatedClientID, boolToInt32(deprecatedClientID))
+}
+
+func boolToInt32(value bool) int32 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// throttleUntil extends the unix nanos until a connection is throttled
+func throttleUntil(throttledUntil *int64, until time.Time) {
+	for {
+		current := atomic.LoadInt64(throttledUntil)
+		if current >= until.UnixNano() || atomic.CompareAndSwapInt64(throttledUntil, current, until.UnixNano()) {
+			return
+		}
+	}
+}
+
+// waitUntil sleeps until the unix nanos a connection is throttled
+func waitUntil(throttledUntil *int64) {
+	if delay := time.Until(time.Unix(0, atomic.LoadInt64(throttledUntil))); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// injectThrottleTime sets throttle_time_ms to at least the given value
+func injectThrottleTime(buf []byte, throttleTimeMs int32) {
+	if int32(binary.BigEndian.Uint32(buf)) < throttleTimeMs {
+		binary.BigEndian.PutUint32(buf, uint32(throttleTimeMs))
+	}
+}
+
+// copyThrottledResponse copies the response body of given length replacing the throttle_time_ms field
+func copyThrottledResponse(dst io.Writer, src io.Reader, length int64, position int, throttleTimeMs int32, buf []byte) (readErr bool, err error) {
+	throttleTimeBuf := make([]byte, 4)
+	if position == throttleTimeLast {
+		if readErr, err = myCopyN(dst, src, length-4, buf); err != nil {
+			return readErr, err
+		}
+	}
+	if _, err = io.ReadFull(src, throttleTimeBuf); err != nil {
+		return true, err
+	}
+	injectThrottleTime(throttleTimeBuf, throttleTimeMs)
+	if _, err = dst.Write(throttleTimeBuf); err != nil {
+		return false, err
+	}
+	if position == throttleTimeFirst {
+		return myCopyN(dst, src, length-4, buf)
+	}
+	return false, nil
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottleTimePosition(t *testing.T) {
+	tests := []struct {
+		apiKey     int16
+		apiVersion int16
+		expected   int
+	}{
+		{apiKey: 0, apiVersion: 0, expected: throttleTimeNone},
+		{apiKey: 0, apiVersion: 1, expected: throttleTimeLast},
+		{apiKey: 0, apiVersion: 8, expected: throttleTimeLast},
+		{apiKey: 0, apiVersion: 9, expected: throttleTimeNone},
+		{apiKey: 1, apiVersion: 0, expected: throttleTimeNone},
+		{apiKey: 1, apiVersion: 11, expected: throttleTimeFirst},
+		{apiKey: 3, apiVersion: 2, expected: throttleTimeNone},
+		{apiKey: 3, apiVersion: 3, expected: throttleTimeFirst},
+		{apiKey: 18, apiVersion: 3, expected: throttleTimeNone},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, throttleTimePosition(tt.apiKey, tt.apiVersion), "api key %d version %d", tt.apiKey, tt.apiVersion)
+	}
+}
+
+func TestDeprecationThrottleTime(t *testing.T) {
+	a := assert.New(t)
+
+	deprecation := NewDeprecation(500*time.Millisecond, []string{"legacy-app"}, config.MinApiVersions{0: 3})
+	a.True(deprecation.enabled())
+	a.True(deprecation.matchClientID("legacy-app"))
+	a.False(deprecation.matchClientID("app"))
+
+	now := time.Now()
+	state := &deprecationState{}
+	a.EqualValues(500, deprecation.throttleTime(state, &protocol.RequestKeyVersion{ApiKey: 0, ApiVersion: 2}, now))
+	a.EqualValues(0, deprecation.throttleTime(state, &protocol.RequestKeyVersion{ApiKey: 0, ApiVersion: 3}, now))
+	// the next request of the connection is delayed
+	a.Equal(now.Add(500*time.Millisecond).UnixNano(), state.throttledUntil)
+
+	state.update(false, true)
+	a.EqualValues(500, deprecation.throttleTime(state, &protocol.RequestKeyVersion{ApiKey: 1, ApiVersion: 11}, now))
+
+	// passthrough clients are not throttled
+	state.update(true, true)
+	a.EqualValues(0, deprecation.throttleTime(state, &protocol.RequestKeyVersion{ApiKey: 0, ApiVersion: 2}, now))
+
+	a.False(NewDeprecation(0, []string{"legacy-app"}, nil).enabled())
+	a.False(NewDeprecation(time.Second, nil, nil).enabled())
+	a.False((*Deprecation)(nil).enabled())
+}
+
+func TestDeprecationStateWait(t *testing.T) {
+	a := assert.New(t)
+
+	state := &deprecationState{}
+	start := time.Now()
+	state.wait()
+	a.True(time.Since(start) < 50*time.Millisecond)
+
+	throttleUntil(&state.throttledUntil, start.Add(100*time.Millisecond))
+	// an earlier time does not shorten the throttling
+	throttleUntil(&state.throttledUntil, start)
+	state.wait()
+	a.True(time.Since(start) >= 100*time.Millisecond)
+
+	(*deprecationState)(nil).wait()
+}
+
+func TestCopyThrottledResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		position int
+		input    string
+		expected string
+	}{
+		{name: "first", position: throttleTimeFirst, input: "00000000aabbcc", expected: "000001f4aabbcc"},
+		{name: "first keeps higher throttle", position: throttleTimeFirst, input: "00000bb8aabbcc", expected: "00000bb8aabbcc"},
+		{name: "last", position: throttleTimeLast, input: "aabbcc00000064", expected: "aabbcc000001f4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			input, err := hex.DecodeString(tt.input)
+			a.Nil(err)
+
+			output := new(bytes.Buffer)
+			_, err = copyThrottledResponse(output, bytes.NewReader(input), int64(len(input)), tt.position, 500, make([]byte, 2))
+			a.Nil(err)
+			a.Equal(tt.expected, hex.EncodeToString(output.Bytes()))
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"golang.org/x/net/proxy"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
@@ -142,6 +143,8 @@ type httpProxy struct {
 	network            string
 	hostPort           string
 	username, password string
+	// bearerTokenFile is read on every dial, so the token can be rotated without restart
+	bearerTokenFile string
 }
 
 func (s *httpProxy) Dial(network, addr string) (net.Conn, error) {
@@ -157,6 +160,12 @@ func (s *httpProxy) Dial(network, addr string) (net.Conn, error) {
 	if s.username != "" && s.password != "" {
 		basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(s.username+":"+s.password))
 		req.Header.Set("Proxy-Authorization", basic)
+	} else if s.bearerTokenFile != "" {
+		token, err := ioutil.ReadFile(s.bearerTokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read forward proxy bearer token")
+		}
+		req.Header.Set("Proxy-Authorization", "Bearer "+strings.TrimSpace(string(token)))
 	}
 
 	c, err := s.forwardDialer.Dial(s.network, s.hostPort)
@@ -182,3 +191,16 @@ func (s *httpProxy) Dial(network, addr string) (net.Conn, error) {
 
 	return c, nil
 }
+
+// forwardProxyRouter selects the dialer by the dial address, addresses without mapping use the default dialer
+type forwardProxyRouter struct {
+	dialers       map[string]Dialer
+	defaultDialer Dialer
+}
+
+func (r forwardProxyRouter) Dial(network, addr string) (net.Conn, error) {
+	if dialer, ok := r.dialers[addr]; ok {
+		return dialer.Dial(network, addr)
+	}
+	return r.defaultDialer.Dial(network, addr)
+}
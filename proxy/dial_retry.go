@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DialRetry dials the addresses of a broker in failover order and retries with exponential backoff and jitter
+// when none of them is reachable
+type DialRetry struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	jitter     float64
+
+	sleep  func(time.Duration)
+	random func() float64
+}
+
+func NewDialRetry(retries int, backoff time.Duration, maxBackoff time.Duration, jitter float64) *DialRetry {
+	return &DialRetry{
+		retries:    retries,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		jitter:     jitter,
+		sleep:      time.Sleep,
+		random:     rand.Float64,
+	}
+}
+
+// dial returns the connection to the first reachable address, the error of the last address if all retries failed.
+// The addresses are resolved for each attempt, an attempt without addresses fails.
+func (r *DialRetry) dial(brokerAddress string, addresses func() []string, dial func(address string) (net.Conn, error)) (net.Conn, error) {
+	var retries int
+	if r != nil {
+		retries = r.retries
+	}
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			backoff := r.backoffTime(attempt)
+			subsystemLog(logSubsystemBroker).Infof("Retrying connection to %s in %v (%d/%d): %v", brokerAddress, backoff, attempt, retries, lastErr)
+			proxyDialRetriesTotal.WithLabelValues(brokerAddress).Inc()
+			r.sleep(backoff)
+		}
+		attemptAddresses := addresses()
+		if len(attemptAddresses) == 0 {
+			lastErr = errors.Errorf("no dial addresses of broker %s", brokerAddress)
+			continue
+		}
+		for i, address := range attemptAddresses {
+			conn, err := dial(address)
+			if err == nil {
+				if i > 0 {
+					subsystemLog(logSubsystemBroker).Infof("Connection to %s failed over to %s", brokerAddress, address)
+					proxyDialFailoversTotal.WithLabelValues(brokerAddress, address).Inc()
+				}
+				return conn, nil
+			}
+			lastErr = err
+		}
+	}
+	proxyDialFailuresTotal.WithLabelValues(brokerAddress).Inc()
+	return nil, lastErr
+}
+
+// backoffTime returns the exponential backoff of the retry capped at the max backoff with the jitter applied
+func (r *DialRetry) backoffTime(attempt int) time.Duration {
+	backoff := r.backoff
+	for i := 1; i < attempt && backoff < r.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.maxBackoff {
+		backoff = r.maxBackoff
+	}
+	if r.jitter > 0 {
+		backoff += time.Duration(float64(backoff) * r.jitter * (2*r.random() - 1))
+	}
+	return backoff
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDialer struct {
+	// dial attempts by address
+	dialed []string
+	// address dialed successfully after the number of failed dials
+	reachable map[string]int
+}
+
+func (d *fakeDialer) dial(address string) (net.Conn, error) {
+	d.dialed = append(d.dialed, address)
+	failures, ok := d.reachable[address]
+	if !ok {
+		return nil, errors.New("connection refused " + address)
+	}
+	if failures > 0 {
+		d.reachable[address] = failures - 1
+		return nil, errors.New("connection refused " + address)
+	}
+	conn, _ := net.Pipe()
+	return conn, nil
+}
+
+func addresses(addresses ...string) func() []string {
+	return func() []string { return addresses }
+}
+
+func newTestDialRetry(retries int, jitter float64) (*DialRetry, *[]time.Duration) {
+	sleeps := make([]time.Duration, 0)
+	r := NewDialRetry(retries, 100*time.Millisecond, 300*time.Millisecond, jitter)
+	r.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	r.random = func() float64 { return 1 }
+	return r, &sleeps
+}
+
+func TestDialRetryFailover(t *testing.T) {
+	r, sleeps := newTestDialRetry(0, 0)
+	dialer := &fakeDialer{reachable: map[string]int{"b:9092": 0}}
+
+	conn, err := r.dial("broker:9092", addresses("a:9092", "b:9092", "c:9092"), dialer.dial)
+	require.NoError(t, err)
+	_ = conn.Close()
+	assert.Equal(t, []string{"a:9092", "b:9092"}, dialer.dialed)
+	assert.Empty(t, *sleeps)
+}
+
+func TestDialRetryBackoff(t *testing.T) {
+	r, sleeps := newTestDialRetry(4, 0)
+	dialer := &fakeDialer{reachable: map[string]int{"b:9092": 3}}
+
+	conn, err := r.dial("broker:9092", addresses("a:9092", "b:9092"), dialer.dial)
+	require.NoError(t, err)
+	_ = conn.Close()
+	assert.Equal(t, []string{"a:9092", "b:9092", "a:9092", "b:9092", "a:9092", "b:9092", "a:9092", "b:9092"}, dialer.dialed)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}, *sleeps)
+}
+
+func TestDialRetryExhausted(t *testing.T) {
+	r, sleeps := newTestDialRetry(2, 0)
+	dialer := &fakeDialer{reachable: map[string]int{}}
+
+	_, err := r.dial("broker:9092", addresses("a:9092"), dialer.dial)
+	require.Error(t, err)
+	assert.Equal(t, "connection refused a:9092", err.Error())
+	assert.Len(t, dialer.dialed, 3)
+	assert.Len(t, *sleeps, 2)
+}
+
+func TestDialRetryJitter(t *testing.T) {
+	r, _ := newTestDialRetry(1, 0.2)
+	assert.Equal(t, 120*time.Millisecond, r.backoffTime(1))
+	r.random = func() float64 { return 0 }
+	assert.Equal(t, 80*time.Millisecond, r.backoffTime(1))
+	assert.Equal(t, 240*time.Millisecond, r.backoffTime(5))
+}
+
+func TestDialRetryDisabled(t *testing.T) {
+	var r *DialRetry
+	dialer := &fakeDialer{reachable: map[string]int{}}
+
+	_, err := r.dial("broker:9092", addresses("a:9092", "b:9092"), dialer.dial)
+	require.Error(t, err)
+	assert.Equal(t, []string{"a:9092", "b:9092"}, dialer.dialed)
+}
+
+func TestDialRetryResolved(t *testing.T) {
+	r, sleeps := newTestDialRetry(2, 0)
+	dialer := &fakeDialer{reachable: map[string]int{"b:9092": 0}}
+
+	// e.g. the SRV records are resolved after the first attempt
+	resolved := [][]string{nil, {"b:9092"}}
+	conn, err := r.dial("broker:9092", func() []string {
+		attemptAddresses := resolved[0]
+		resolved = resolved[1:]
+		return attemptAddresses
+	}, dialer.dial)
+	require.NoError(t, err)
+	_ = conn.Close()
+	assert.Equal(t, []string{"b:9092"}, dialer.dialed)
+	assert.Len(t, *sleeps, 1)
+}
+
+func TestDialRetryNoAddresses(t *testing.T) {
+	r, sleeps := newTestDialRetry(1, 0)
+	dialer := &fakeDialer{reachable: map[string]int{}}
+
+	conn, err := r.dial("broker:9092", addresses(), dialer.dial)
+	assert.Nil(t, conn)
+	require.Error(t, err)
+	assert.Equal(t, "no dial addresses of broker broker:9092", err.Error())
+	assert.Empty(t, dialer.dialed)
+	assert.Len(t, *sleeps, 1)
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startConnectProxy accepts CONNECT requests and sends the Proxy-Authorization header of each request to the channel
+func startConnectProxy(t *testing.T) (net.Listener, <-chan string) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	headers := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			req, err := http.ReadRequest(bufio.NewReader(conn))
+			if err == nil && req.Method == "CONNECT" {
+				headers <- req.Header.Get("Proxy-Authorization")
+				_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+			}
+			conn.Close()
+		}
+	}()
+	return listener, headers
+}
+
+func TestHttpProxyAuthorization(t *testing.T) {
+	a := assert.New(t)
+	listener, headers := startConnectProxy(t)
+	defer listener.Close()
+
+	tokenFile, err := ioutil.TempFile("", "proxy-token")
+	require.NoError(t, err)
+	defer os.Remove(tokenFile.Name())
+	_, err = tokenFile.WriteString("my-token\n")
+	require.NoError(t, err)
+	require.NoError(t, tokenFile.Close())
+
+	forwardDialer := directDialer{dialTimeout: 2 * time.Second, keepAlive: 60 * time.Second}
+	tests := []struct {
+		name   string
+		dialer *httpProxy
+		header string
+	}{
+		{"none", &httpProxy{forwardDialer: forwardDialer, network: "tcp", hostPort: listener.Addr().String()}, ""},
+		{"basic", &httpProxy{forwardDialer: forwardDialer, network: "tcp", hostPort: listener.Addr().String(), username: "user", password: "secret"}, "Basic dXNlcjpzZWNyZXQ="},
+		{"bearer", &httpProxy{forwardDialer: forwardDialer, network: "tcp", hostPort: listener.Addr().String(), bearerTokenFile: tokenFile.Name()}, "Bearer my-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tt.dialer.Dial("tcp", "kafka-0:9092")
+			require.NoError(t, err)
+			conn.Close()
+			a.Equal(tt.header, <-headers)
+		})
+	}
+
+	_, err = (&httpProxy{forwardDialer: forwardDialer, network: "tcp", hostPort: listener.Addr().String(), bearerTokenFile: tokenFile.Name() + "-missing"}).Dial("tcp", "kafka-0:9092")
+	a.Error(err)
+}
+
+type recordingDialer struct {
+	name   string
+	dialed *[]string
+}
+
+func (d recordingDialer) Dial(_, addr string) (net.Conn, error) {
+	*d.dialed = append(*d.dialed, d.name+" "+addr)
+	return nil, nil
+}
+
+func TestForwardProxyRouter(t *testing.T) {
+	var dialed []string
+	router := forwardProxyRouter{
+		dialers:       map[string]Dialer{"kafka-0:9092": recordingDialer{name: "https", dialed: &dialed}},
+		defaultDialer: recordingDialer{name: "default", dialed: &dialed},
+	}
+	_, _ = router.Dial("tcp", "kafka-0:9092")
+	_, _ = router.Dial("tcp", "kafka-1:9092")
+	assert.Equal(t, []string{"https kafka-0:9092", "default kafka-1:9092"}, dialed)
+}
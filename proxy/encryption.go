@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"fmt"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/encryption"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	apiKeyFetch = int16(1)
+
+	encryptOperation = "encrypt"
+	decryptOperation = "decrypt"
+)
+
+// PayloadEncryption encrypts the record values of produce requests and decrypts the record values of fetch responses.
+// The values are encrypted and decrypted with the data key of the key name configured for the topic.
+type PayloadEncryption struct {
+	topics   config.TopicKeys
+	envelope *encryption.Envelope
+}
+
+func NewPayloadEncryption(topics config.TopicKeys, envelope *encryption.Envelope) *PayloadEncryption {
+	if len(topics) == 0 {
+		return nil
+	}
+	return &PayloadEncryption{topics: topics, envelope: envelope}
+}
+
+func (e *PayloadEncryption) enabled() bool {
+	return e != nil
+}
+
+// encryptProduceRequest returns the produce request body starting after the api key and version with encrypted record values.
+// Partitions with records compressed by an unsupported codec are removed from the request and rejected with UNSUPPORTED_COMPRESSION_TYPE.
+func (e *PayloadEncryption) encryptProduceRequest(apiVersion int16, body []byte, state *producePartitionErrorsState, log *logrus.Entry) ([]byte, error) {
+	request := &protocol.ProduceRequest{Version: apiVersion}
+	if err := protocol.Decode(body, request); err != nil {
+		return nil, err
+	}
+	encrypted := false
+	var partitionErrors []protocol.ProducePartitionError
+	for i, topicData := range request.TopicData {
+		keyName, ok := e.topics.KeyName(topicData.Topic)
+		if !ok {
+			continue
+		}
+		topic := topicData.Topic
+		for j, partitionData := range topicData.PartitionData {
+			count := 0
+			records, err := protocol.TransformRecordValues(partitionData.Records, func(value []byte) ([]byte, error) {
+				count++
+				return e.envelope.Encrypt(keyName, topic, value)
+			})
+			if unsupported, ok := err.(protocol.ErrUnsupportedCompression); ok {
+				proxyPayloadEncryptionErrorsTotal.WithLabelValues(topic, encryptOperation).Inc()
+				reason := fmt.Sprintf("records of topic %s cannot be encrypted: %v", topic, unsupported)
+				log.Infof("Produce to topic %s partition %d is rejected: %s", topic, partitionData.Partition, reason)
+				partitionErrors = append(partitionErrors, protocol.ProducePartitionError{Topic: topic, Partition: partitionData.Partition, ErrorCode: protocol.ErrUnsupportedCompressionType, ErrorMessage: &reason})
+				continue
+			}
+			if err != nil {
+				proxyPayloadEncryptionErrorsTotal.WithLabelValues(topic, encryptOperation).Inc()
+				return nil, errors.Wrapf(err, "encryption of topic %s records failed", topic)
+			}
+			proxyPayloadEncryptionRecordsTotal.WithLabelValues(topic, encryptOperation).Add(float64(count))
+			request.TopicData[i].PartitionData[j].Records = records
+		}
+		encrypted = true
+	}
+	if len(partitionErrors) != 0 {
+		return rejectProducePartitions(request, partitionErrors, state)
+	}
+	if !encrypted {
+		return body, nil
+	}
+	return protocol.Encode(request)
+}
+
+// decryptFetchResponse returns the fetch response body following the response header with decrypted record values.
+// Values which cannot be decrypted are kept unchanged.
+func (e *PayloadEncryption) decryptFetchResponse(apiVersion int16, body []byte) ([]byte, error) {
+	response := &protocol.FetchResponse{Version: apiVersion}
+	if err := protocol.Decode(body, response); err != nil {
+		// the topics are unknown, the records are returned encrypted
+		proxyPayloadEncryptionErrorsTotal.WithLabelValues("", decryptOperation).Inc()
+		return nil, err
+	}
+	decrypted := false
+	for i, topicResponse := range response.Responses {
+		keyName, ok := e.topics.KeyName(topicResponse.Topic)
+		if !ok {
+			continue
+		}
+		topic := topicResponse.Topic
+		for j, partition := range topicResponse.Partitions {
+			count, failed := 0, 0
+			records, err := protocol.TransformRecordValues(partition.Records, func(value []byte) ([]byte, error) {
+				if !encryption.IsEncrypted(value) {
+					return value, nil
+				}
+				plaintext, err := e.envelope.Decrypt(keyName, topic, value)
+				if err != nil {
+					failed++
+					logrus.Debugf("Record value of topic %s could not be decrypted: %v", topic, err)
+					return value, nil
+				}
+				count++
+				return plaintext, nil
+			})
+			if err != nil {
+				proxyPayloadEncryptionErrorsTotal.WithLabelValues(topic, decryptOperation).Inc()
+				logrus.Warnf("Records of topic %s partition %d could not be decrypted: %v", topic, partition.Partition, err)
+				continue
+			}
+			if failed > 0 {
+				proxyPayloadEncryptionErrorsTotal.WithLabelValues(topic, decryptOperation).Add(float64(failed))
+				logrus.Warnf("%d record values of topic %s partition %d could not be decrypted", failed, topic, partition.Partition)
+			}
+			proxyPayloadEncryptionRecordsTotal.WithLabelValues(topic, decryptOperation).Add(float64(count))
+			response.Responses[i].Partitions[j].Records = records
+		}
+		decrypted = true
+	}
+	if !decrypted {
+		return body, nil
+	}
+	return protocol.Encode(response)
+}
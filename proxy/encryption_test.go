@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/encryption"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPayloadEncryption(t *testing.T, topics config.TopicKeys) *PayloadEncryption {
+	dir, err := ioutil.TempDir("", "encryption")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "master.key")
+	assert.Nil(t, ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(make([]byte, 32))), 0600))
+	provider, err := encryption.NewLocalKeyProvider(keyFile)
+	assert.Nil(t, err)
+	return NewPayloadEncryption(topics, encryption.NewEnvelope(provider, time.Hour, time.Second))
+}
+
+// uncompressedRecordBatch returns a record batch (magic v2) with a single record without key and headers
+func uncompressedRecordBatch(value string) []byte {
+	record := []byte{0, 0, 0, 1} // attributes, timestampDelta, offsetDelta, null key (varint -1)
+	record = append(record, byte(len(value)<<1))
+	record = append(record, value...)
+	record = append(record, 0) // headers
+
+	batch := make([]byte, 61, 61+1+len(record))
+	batch[16] = 2 // magic
+	batch[60] = 1 // records count
+	batch = append(batch, byte(len(record)<<1))
+	batch = append(batch, record...)
+	batch[11] = byte(len(batch) - 12)
+	return batch
+}
+
+func recordBatchValues(t *testing.T, records []byte) []string {
+	values := make([]string, 0)
+	_, err := protocol.TransformRecordValues(records, func(value []byte) ([]byte, error) {
+		values = append(values, string(value))
+		return value, nil
+	})
+	assert.Nil(t, err)
+	return values
+}
+
+func TestPayloadEncryptionRoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	payloadEncryption := newTestPayloadEncryption(t, config.TopicKeys{"orders": "orders-key"})
+	clientID := "producer"
+
+	produceRequest := &protocol.ProduceRequest{
+		Version:       7,
+		CorrelationID: 1,
+		ClientID:      &clientID,
+		Acks:          -1,
+		Timeout:       1000,
+		TopicData: []protocol.ProduceTopicData{
+			{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: uncompressedRecordBatch("secret")}}},
+			{Topic: "logs", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: uncompressedRecordBatch("public")}}},
+		},
+	}
+	body, err := protocol.Encode(produceRequest)
+	a.Nil(err)
+
+	encryptedBody, err := payloadEncryption.encryptProduceRequest(7, body, &producePartitionErrorsState{}, logrus.NewEntry(logrus.New()))
+	a.Nil(err)
+	encryptedRequest := &protocol.ProduceRequest{Version: 7}
+	a.Nil(protocol.Decode(encryptedBody, encryptedRequest))
+
+	orderRecords := encryptedRequest.TopicData[0].PartitionData[0].Records
+	values := recordBatchValues(t, orderRecords)
+	a.Len(values, 1)
+	a.True(encryption.IsEncrypted([]byte(values[0])))
+	a.Equal([]string{"public"}, recordBatchValues(t, encryptedRequest.TopicData[1].PartitionData[0].Records))
+
+	fetchResponse := &protocol.FetchResponse{
+		Version: 11,
+		Responses: []protocol.FetchTopicResponse{
+			{Topic: "orders", Partitions: []protocol.FetchPartitionResponse{{Partition: 0, HighWatermark: 1, Records: orderRecords}}},
+		},
+	}
+	body, err = protocol.Encode(fetchResponse)
+	a.Nil(err)
+
+	decryptedBody, err := payloadEncryption.decryptFetchResponse(11, body)
+	a.Nil(err)
+	decryptedResponse := &protocol.FetchResponse{Version: 11}
+	a.Nil(protocol.Decode(decryptedBody, decryptedResponse))
+	a.Equal([]string{"secret"}, recordBatchValues(t, decryptedResponse.Responses[0].Partitions[0].Records))
+
+	// records of topics without a key name are not decrypted
+	fetchResponse.Responses[0].Topic = "logs"
+	body, err = protocol.Encode(fetchResponse)
+	a.Nil(err)
+	decryptedBody, err = payloadEncryption.decryptFetchResponse(11, body)
+	a.Nil(err)
+	a.Equal(body, decryptedBody)
+}
+
+func TestPayloadEncryptionUnsupportedCompression(t *testing.T) {
+	a := assert.New(t)
+
+	payloadEncryption := newTestPayloadEncryption(t, config.TopicKeys{"orders": "orders-key"})
+
+	unsupported := uncompressedRecordBatch("secret")
+	unsupported[22] |= 5 // unknown compression codec
+	clientID := "app"
+	produceRequest := &protocol.ProduceRequest{
+		Version:       7,
+		CorrelationID: 1,
+		ClientID:      &clientID,
+		Acks:          -1,
+		Timeout:       1000,
+		TopicData: []protocol.ProduceTopicData{
+			{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: unsupported}, {Partition: 1, Records: uncompressedRecordBatch("secret")}}},
+		},
+	}
+	body, err := protocol.Encode(produceRequest)
+	a.Nil(err)
+
+	state := &producePartitionErrorsState{}
+	encryptedBody, err := payloadEncryption.encryptProduceRequest(7, body, state, logrus.NewEntry(logrus.New()))
+	a.Nil(err)
+	encryptedRequest := &protocol.ProduceRequest{Version: 7}
+	a.Nil(protocol.Decode(encryptedBody, encryptedRequest))
+
+	// the partition is not forwarded unencrypted, the client receives an error
+	a.Len(encryptedRequest.TopicData, 1)
+	a.Len(encryptedRequest.TopicData[0].PartitionData, 1)
+	a.Equal(int32(1), encryptedRequest.TopicData[0].PartitionData[0].Partition)
+	partitionErrors := state.take(1)
+	a.Len(partitionErrors, 1)
+	a.Equal(int32(0), partitionErrors[0].Partition)
+	a.Equal(protocol.ErrUnsupportedCompressionType, partitionErrors[0].ErrorCode)
+}
+
+func TestPayloadEncryptionDisabled(t *testing.T) {
+	a := assert.New(t)
+
+	var payloadEncryption *PayloadEncryption
+	a.False(payloadEncryption.enabled())
+	a.Nil(NewPayloadEncryption(config.TopicKeys{}, nil))
+	a.True(newTestPayloadEncryption(t, config.TopicKeys{"*": "key"}).enabled())
+}
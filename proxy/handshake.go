@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// handshakeDeadline closes the client connection when the TLS handshake, the gateway authentication and the local SASL
+// authentication are not completed within the timeout. A timer is used instead of connection deadlines, as the
+// authentication steps set their own deadlines. A nil handshakeDeadline never expires.
+type handshakeDeadline struct {
+	timer *time.Timer
+	// 1 after the handshake is completed or the deadline expired
+	finished int32
+}
+
+func newHandshakeDeadline(timeout time.Duration, conn io.Closer, brokerAddress string, connDesc string, log *logrus.Entry) *handshakeDeadline {
+	if timeout <= 0 {
+		return nil
+	}
+	d := &handshakeDeadline{}
+	d.timer = time.AfterFunc(timeout, func() {
+		if !atomic.CompareAndSwapInt32(&d.finished, 0, 1) {
+			return
+		}
+		log.Infof("Handshake of %s not completed within %v, closing connection", connDesc, timeout)
+		proxyHandshakeTimeoutsTotal.WithLabelValues(brokerAddress).Inc()
+		_ = conn.Close()
+	})
+	return d
+}
+
+// complete stops the timer, the connection is not closed afterwards
+func (d *handshakeDeadline) complete() {
+	if d == nil {
+		return
+	}
+	if atomic.CompareAndSwapInt32(&d.finished, 0, 1) {
+		d.timer.Stop()
+	}
+}
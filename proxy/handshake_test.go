@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandshakeDeadlineClosesConnection(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	deadline := newHandshakeDeadline(50*time.Millisecond, local, "kafka-0:9092", "test connection", subsystemLog(logSubsystemConnection))
+	_, err := local.Read(make([]byte, 1))
+	assert.Error(t, err, "the connection is closed while the client is silent")
+	deadline.complete()
+}
+
+func TestHandshakeDeadlineCompleted(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	deadline := newHandshakeDeadline(50*time.Millisecond, local, "kafka-0:9092", "test connection", subsystemLog(logSubsystemConnection))
+	deadline.complete()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_, _ = remote.Write([]byte{1})
+	}()
+	n, err := local.Read(make([]byte, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestHandshakeDeadlineDisabled(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	deadline := newHandshakeDeadline(0, local, "kafka-0:9092", "test connection", subsystemLog(logSubsystemConnection))
+	assert.Nil(t, deadline)
+	deadline.complete()
+}
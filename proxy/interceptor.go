@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	interceptAllow = "allow"
+	interceptDeny  = "deny"
+	interceptError = "error"
+)
+
+// RequestInterceptor passes the decoded request and optionally response headers to the interceptor plugin, which allows, denies or annotates them.
+// The headers of the produced records are passed as well when enabled. A denied request or response closes the client connection.
+type RequestInterceptor struct {
+	interceptor apis.Interceptor
+	timeout     time.Duration
+	responses   bool
+
+	// nil when the record headers are not passed
+	recordHeaders *recordHeadersCache
+
+	pseudonymizer *Pseudonymizer
+}
+
+func NewRequestInterceptor(interceptor apis.Interceptor, timeout time.Duration, responses bool, recordHeaders bool, pseudonymizer *Pseudonymizer) *RequestInterceptor {
+	if interceptor == nil {
+		return nil
+	}
+	i := &RequestInterceptor{interceptor: interceptor, timeout: timeout, responses: responses, pseudonymizer: pseudonymizer}
+	if recordHeaders {
+		i.recordHeaders = newRecordHeadersCache(defaultRecordHeadersCacheSize)
+	}
+	return i
+}
+
+func (i *RequestInterceptor) enabled() bool {
+	return i != nil
+}
+
+func (i *RequestInterceptor) responsesEnabled() bool {
+	return i != nil && i.responses
+}
+
+// interceptedConnection is shared by the requests and responses loops of a connection
+type interceptedConnection struct {
+	mu        sync.RWMutex
+	principal string
+	clientID  string
+	// passthrough client
+	exempt bool
+}
+
+func (c *interceptedConnection) setExempt() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exempt = true
+}
+
+func (c *interceptedConnection) set(principal string, clientID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.principal = principal
+	c.clientID = clientID
+}
+
+func (c *interceptedConnection) get() (principal string, clientID string, exempt bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.principal, c.clientID, c.exempt
+}
+
+// interceptRequest decides about the request body starting after the api key and version
+func (i *RequestInterceptor) interceptRequest(brokerAddress string, principal string, conn *interceptedConnection, requestKeyVersion *protocol.RequestKeyVersion, body []byte) error {
+	summary := &protocol.RequestSummary{ApiKey: requestKeyVersion.ApiKey, ApiVersion: requestKeyVersion.ApiVersion}
+	if err := protocol.Decode(body, summary); err != nil {
+		return err
+	}
+	var clientID string
+	if summary.ClientID != nil {
+		clientID = *summary.ClientID
+	}
+	conn.set(principal, clientID)
+
+	var records []apis.ProducedRecords
+	if i.recordHeaders != nil && requestKeyVersion.ApiKey == 0 && requestKeyVersion.ApiVersion <= 8 {
+		request := &protocol.ProduceRequest{Version: requestKeyVersion.ApiVersion}
+		if err := protocol.Decode(body, request); err != nil {
+			return err
+		}
+		var err error
+		if records, err = i.recordHeaders.producedRecords(request); err != nil {
+			return fmt.Errorf("record headers of produce request could not be decoded: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), i.timeout)
+	defer cancel()
+	result, err := i.interceptor.InterceptRequest(ctx, apis.RequestInfo{
+		BrokerAddress: brokerAddress,
+		Principal:     principal,
+		ClientID:      clientID,
+		ApiKey:        int32(requestKeyVersion.ApiKey),
+		ApiVersion:    int32(requestKeyVersion.ApiVersion),
+		CorrelationID: summary.CorrelationID,
+		Topics:        summary.Topics,
+		TopicsDecoded: summary.TopicsDecoded,
+		Records:       records,
+	})
+	return i.decide("request", brokerAddress, principal, clientID, requestKeyVersion, summary.CorrelationID, result, err)
+}
+
+func (i *RequestInterceptor) interceptResponse(brokerAddress string, conn *interceptedConnection, requestKeyVersion *protocol.RequestKeyVersion, correlationID int32) error {
+	principal, clientID, exempt := conn.get()
+	if exempt {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), i.timeout)
+	defer cancel()
+	result, err := i.interceptor.InterceptResponse(ctx, apis.ResponseInfo{
+		BrokerAddress: brokerAddress,
+		Principal:     principal,
+		ClientID:      clientID,
+		ApiKey:        int32(requestKeyVersion.ApiKey),
+		ApiVersion:    int32(requestKeyVersion.ApiVersion),
+		CorrelationID: correlationID,
+	})
+	return i.decide("response", brokerAddress, principal, clientID, requestKeyVersion, correlationID, result, err)
+}
+
+func (i *RequestInterceptor) decide(kind string, brokerAddress string, principal string, clientID string, requestKeyVersion *protocol.RequestKeyVersion, correlationID int32, result apis.InterceptResult, err error) error {
+	apiKey := strconv.Itoa(int(requestKeyVersion.ApiKey))
+	if err != nil {
+		proxyInterceptorDecisionsTotal.WithLabelValues(brokerAddress, kind, apiKey, interceptError).Inc()
+		return fmt.Errorf("%s api key %d interception failed: %v", kind, requestKeyVersion.ApiKey, err)
+	}
+	if len(result.Annotations) != 0 {
+		fields := logrus.Fields{
+			"broker":         brokerAddress,
+			"principal":      i.pseudonymizer.principal(principal),
+			"client_id":      i.pseudonymizer.clientID(clientID),
+			"api_key":        requestKeyVersion.ApiKey,
+			"api_version":    requestKeyVersion.ApiVersion,
+			"correlation_id": correlationID,
+		}
+		for key, value := range result.Annotations {
+			fields[key] = value
+		}
+		logrus.WithFields(fields).Infof("Intercepted %s annotated", kind)
+	}
+	if result.Deny {
+		proxyInterceptorDecisionsTotal.WithLabelValues(brokerAddress, kind, apiKey, interceptDeny).Inc()
+		return fmt.Errorf("%s api key %d is denied by interceptor: %s", kind, requestKeyVersion.ApiKey, result.Reason)
+	}
+	proxyInterceptorDecisionsTotal.WithLabelValues(brokerAddress, kind, apiKey, interceptAllow).Inc()
+	return nil
+}
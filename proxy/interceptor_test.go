@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+type testInterceptor struct {
+	requests  []apis.RequestInfo
+	responses []apis.ResponseInfo
+	result    apis.InterceptResult
+	err       error
+}
+
+func (t *testInterceptor) InterceptRequest(ctx context.Context, info apis.RequestInfo) (apis.InterceptResult, error) {
+	t.requests = append(t.requests, info)
+	return t.result, t.err
+}
+
+func (t *testInterceptor) InterceptResponse(ctx context.Context, info apis.ResponseInfo) (apis.InterceptResult, error) {
+	t.responses = append(t.responses, info)
+	return t.result, t.err
+}
+
+// metadata request v0: correlation id 7, client id "app", topics ["orders"]
+var metadataRequestV0Body = []byte{
+	0, 0, 0, 7,
+	0, 3, 'a', 'p', 'p',
+	0, 0, 0, 1,
+	0, 6, 'o', 'r', 'd', 'e', 'r', 's',
+}
+
+func TestRequestInterceptor(t *testing.T) {
+	tests := []struct {
+		name   string
+		result apis.InterceptResult
+		err    error
+		failed bool
+	}{
+		{name: "allow"},
+		{name: "annotate", result: apis.InterceptResult{Annotations: map[string]string{"tenant": "a"}}},
+		{name: "deny", result: apis.InterceptResult{Deny: true, Reason: "forbidden topic"}, failed: true},
+		{name: "error", err: errors.New("plugin is down"), failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			plugin := &testInterceptor{result: tt.result, err: tt.err}
+			interceptor := NewRequestInterceptor(plugin, time.Second, true, false, nil)
+			a.True(interceptor.enabled())
+			a.True(interceptor.responsesEnabled())
+
+			conn := &interceptedConnection{}
+			keyVersion := &protocol.RequestKeyVersion{ApiKey: 3, ApiVersion: 0}
+
+			err := interceptor.interceptRequest("broker:9092", "alice", conn, keyVersion, metadataRequestV0Body)
+			a.Equal(tt.failed, err != nil)
+			a.Equal([]apis.RequestInfo{{
+				BrokerAddress: "broker:9092",
+				Principal:     "alice",
+				ClientID:      "app",
+				ApiKey:        3,
+				CorrelationID: 7,
+				Topics:        []string{"orders"},
+				TopicsDecoded: true,
+			}}, plugin.requests)
+
+			err = interceptor.interceptResponse("broker:9092", conn, keyVersion, 7)
+			a.Equal(tt.failed, err != nil)
+			a.Equal([]apis.ResponseInfo{{
+				BrokerAddress: "broker:9092",
+				Principal:     "alice",
+				ClientID:      "app",
+				ApiKey:        3,
+				CorrelationID: 7,
+			}}, plugin.responses)
+		})
+	}
+}
+
+func TestRequestInterceptorExemptResponses(t *testing.T) {
+	a := assert.New(t)
+
+	plugin := &testInterceptor{result: apis.InterceptResult{Deny: true}}
+	interceptor := NewRequestInterceptor(plugin, time.Second, true, false, nil)
+
+	conn := &interceptedConnection{}
+	conn.setExempt()
+	a.Nil(interceptor.interceptResponse("broker:9092", conn, &protocol.RequestKeyVersion{ApiKey: 3}, 7))
+	a.Empty(plugin.responses)
+}
+
+func TestRequestInterceptorDisabled(t *testing.T) {
+	a := assert.New(t)
+
+	interceptor := NewRequestInterceptor(nil, time.Second, true, false, nil)
+	a.Nil(interceptor)
+	a.False(interceptor.enabled())
+	a.False(interceptor.responsesEnabled())
+	a.False(NewRequestInterceptor(&testInterceptor{}, time.Second, false, false, nil).responsesEnabled())
+}
+
+// recordBatchWithHeader returns an uncompressed record batch with a record having a null key and value and the header
+func recordBatchWithHeader(key string, value string) []byte {
+	record := []byte{0, 0, 0, 1, 1} // attributes, timestampDelta, offsetDelta, null key and value (varint -1)
+	record = append(record, 2)      // headers count
+	record = append(record, byte(len(key)<<1))
+	record = append(record, key...)
+	record = append(record, byte(len(value)<<1))
+	record = append(record, value...)
+
+	batch := make([]byte, 61, 61+1+len(record))
+	batch[16] = 2 // magic
+	batch[60] = 1 // records count
+	batch = append(batch, byte(len(record)<<1))
+	batch = append(batch, record...)
+	batch[11] = byte(len(batch) - 12)
+	return batch
+}
+
+func TestRequestInterceptorRecordHeaders(t *testing.T) {
+	a := assert.New(t)
+
+	plugin := &testInterceptor{}
+	interceptor := NewRequestInterceptor(plugin, time.Second, false, true, nil)
+
+	clientID := "app"
+	batch := recordBatchWithHeader("tenant-id", "a")
+	body, err := protocol.Encode(&protocol.ProduceRequest{
+		Version:       3,
+		CorrelationID: 7,
+		ClientID:      &clientID,
+		Acks:          -1,
+		Timeout:       1000,
+		TopicData: []protocol.ProduceTopicData{
+			{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 1, Records: append(batch, batch...)}}},
+		},
+	})
+	a.Nil(err)
+
+	keyVersion := &protocol.RequestKeyVersion{ApiKey: 0, ApiVersion: 3}
+	a.Nil(interceptor.interceptRequest("broker:9092", "alice", &interceptedConnection{}, keyVersion, body))
+	a.Len(plugin.requests, 1)
+	header := []apis.RecordHeader{{Key: "tenant-id", Value: []byte("a")}}
+	a.Equal([]apis.ProducedRecords{{Topic: "orders", Partition: 1, Headers: [][]apis.RecordHeader{header, header}}}, plugin.requests[0].Records)
+	a.Len(interceptor.recordHeaders.entries, 1)
+
+	// batch with missing records
+	batch[60] = 2
+	body, err = protocol.Encode(&protocol.ProduceRequest{
+		Version:   3,
+		TopicData: []protocol.ProduceTopicData{{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 1, Records: batch}}}},
+	})
+	a.Nil(err)
+	a.NotNil(interceptor.interceptRequest("broker:9092", "alice", &interceptedConnection{}, keyVersion, body))
+	a.Len(plugin.requests, 1)
+}
+
+func TestRecordHeadersCacheEviction(t *testing.T) {
+	a := assert.New(t)
+
+	cache := newRecordHeadersCache(2)
+	for _, value := range []string{"a", "b", "c"} {
+		_, err := cache.batchHeaders(recordBatchWithHeader("tenant-id", value))
+		a.Nil(err)
+	}
+	a.Len(cache.entries, 2)
+	a.Len(cache.order, 2)
+	_, ok := cache.entries[sha256.Sum256(recordBatchWithHeader("tenant-id", "a"))]
+	a.False(ok)
+	_, ok = cache.entries[sha256.Sum256(recordBatchWithHeader("tenant-id", "c"))]
+	a.True(ok)
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	logSubsystemConnection = "connection"
+	logSubsystemAuth       = "auth"
+	logSubsystemRequests   = "requests"
+	logSubsystemBroker     = "broker"
+)
+
+// loggers of the subsystems with their own log level, other subsystems log with the standard logger.
+// Set on startup before connections are accepted.
+var subsystemLoggers = map[string]*logrus.Logger{}
+
+// SetLogSubsystemLevels creates the loggers of the subsystems with their own log level. The loggers share the formatter,
+// the output and the hooks of the standard logger, so it must be called after the standard logger is configured.
+func SetLogSubsystemLevels(levels config.LogSubsystemLevels) {
+	std := logrus.StandardLogger()
+	loggers := make(map[string]*logrus.Logger, len(levels))
+	for subsystem, level := range levels {
+		loggers[subsystem] = &logrus.Logger{
+			Out:          std.Out,
+			Hooks:        std.Hooks,
+			Formatter:    std.Formatter,
+			ReportCaller: std.ReportCaller,
+			Level:        level,
+			ExitFunc:     std.ExitFunc,
+		}
+	}
+	subsystemLoggers = loggers
+}
+
+// subsystemLog returns the log entry of the subsystem
+func subsystemLog(subsystem string) *logrus.Entry {
+	logger, ok := subsystemLoggers[subsystem]
+	if !ok {
+		logger = logrus.StandardLogger()
+	}
+	return logger.WithField("subsystem", subsystem)
+}
+
+// connLog returns the log entry of the subsystem with the id of the client connection, which is the id listed by the
+// connections endpoint. The id is omitted for connections without id.
+func connLog(subsystem string, connID uint64) *logrus.Entry {
+	entry := subsystemLog(subsystem)
+	if connID == 0 {
+		return entry
+	}
+	return entry.WithField("conn_id", connID)
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnLogSubsystemLevels(t *testing.T) {
+	a := assert.New(t)
+
+	std := logrus.StandardLogger()
+	out, formatter, level := std.Out, std.Formatter, std.Level
+	var buf bytes.Buffer
+	std.SetOutput(&buf)
+	std.SetFormatter(&logrus.JSONFormatter{})
+	std.SetLevel(logrus.InfoLevel)
+	SetLogSubsystemLevels(config.LogSubsystemLevels{logSubsystemRequests: logrus.DebugLevel, logSubsystemAuth: logrus.WarnLevel})
+	t.Cleanup(func() {
+		std.SetOutput(out)
+		std.SetFormatter(formatter)
+		std.SetLevel(level)
+		SetLogSubsystemLevels(nil)
+	})
+
+	connLog(logSubsystemRequests, 42).Debugf("Kafka request key %d", 3)
+	connLog(logSubsystemAuth, 42).Infof("not logged")
+	connLog(logSubsystemConnection, 42).Debugf("not logged")
+	connLog(logSubsystemConnection, 0).Infof("Client closed")
+
+	decoder := json.NewDecoder(&buf)
+	var entry map[string]interface{}
+	a.Nil(decoder.Decode(&entry))
+	a.Equal("Kafka request key 3", entry["msg"])
+	a.Equal("requests", entry["subsystem"])
+	a.Equal(float64(42), entry["conn_id"])
+
+	entry = nil
+	a.Nil(decoder.Decode(&entry))
+	a.Equal("Client closed", entry["msg"])
+	a.Equal("connection", entry["subsystem"])
+	a.NotContains(entry, "conn_id")
+	a.False(decoder.More())
+
+	var tracked *trackedConnection
+	a.NotContains(tracked.log(logSubsystemRequests).Data, "conn_id")
+}
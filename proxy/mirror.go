@@ -0,0 +1,301 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/pkg/errors"
+)
+
+// Mirror duplicates the produce requests accepted on the mirrored listeners asynchronously to a second cluster, e.g. to test
+// a migration or to validate a disaster recovery cluster. The requests are queued and sent by a single goroutine to the
+// partition leaders of the mirror cluster with acks=0, so neither the latency nor the result of the client request depend
+// on the mirror cluster. Requests are dropped when the queue is full. Transactional produce requests are not mirrored.
+// The producer ids and sequences of idempotent batches are cleared, the mirror cluster does not know the producers and would
+// reject their batches without a response to the acks=0 requests.
+type Mirror struct {
+	bootstrapServers []string
+	// listener or broker addresses, all listeners when empty
+	listeners      map[string]bool
+	clientID       string
+	dialer         Dialer
+	writeTimeout   time.Duration
+	readTimeout    time.Duration
+	metadataMaxAge time.Duration
+	queue          chan mirroredRequest
+
+	// used by the mirroring goroutine only
+	brokers       map[int32]string
+	leaders       map[mirrorPartition]int32
+	topics        map[string]bool
+	metadataAt    time.Time
+	conns         map[string]net.Conn
+	correlationID int32
+}
+
+type mirroredRequest struct {
+	apiVersion int16
+	// produce request body following the api key and version
+	body []byte
+}
+
+type mirrorPartition struct {
+	topic     string
+	partition int32
+}
+
+func NewMirror(bootstrapServers []string, listeners []string, queueSize int, metadataMaxAge time.Duration, clientID string, dialer Dialer, writeTimeout time.Duration, readTimeout time.Duration) *Mirror {
+	m := &Mirror{
+		bootstrapServers: bootstrapServers,
+		listeners:        make(map[string]bool),
+		clientID:         clientID,
+		dialer:           dialer,
+		writeTimeout:     writeTimeout,
+		readTimeout:      readTimeout,
+		metadataMaxAge:   metadataMaxAge,
+		queue:            make(chan mirroredRequest, queueSize),
+		brokers:          make(map[int32]string),
+		leaders:          make(map[mirrorPartition]int32),
+		topics:           make(map[string]bool),
+		conns:            make(map[string]net.Conn),
+	}
+	for _, listener := range listeners {
+		m.listeners[listener] = true
+	}
+	return m
+}
+
+func (m *Mirror) enabled() bool {
+	return m != nil
+}
+
+// mirrored returns true if the produce requests accepted on the listener are mirrored
+func (m *Mirror) mirrored(listenerAddress string, brokerAddress string) bool {
+	if m == nil {
+		return false
+	}
+	return len(m.listeners) == 0 || m.listeners[listenerAddress] || m.listeners[brokerAddress]
+}
+
+// enqueue queues the produce request body following the api key and version without blocking. The body must not be modified afterwards.
+func (m *Mirror) enqueue(apiVersion int16, body []byte) {
+	select {
+	case m.queue <- mirroredRequest{apiVersion: apiVersion, body: body}:
+	default:
+		proxyMirrorRequestsTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// run sends the queued requests to the mirror cluster until stop is closed
+func (m *Mirror) run(stop <-chan struct{}) {
+	defer m.closeConns()
+	for {
+		select {
+		case request := <-m.queue:
+			result, err := m.send(request)
+			if err != nil {
+				subsystemLog(logSubsystemBroker).Warnf("Produce request could not be mirrored: %v", err)
+			}
+			proxyMirrorRequestsTotal.WithLabelValues(result).Inc()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// send sends the partitions of the produce request to their leaders in the mirror cluster and returns the result label
+func (m *Mirror) send(request mirroredRequest) (string, error) {
+	produce := &protocol.ProduceRequest{Version: request.apiVersion}
+	if err := protocol.Decode(request.body, produce); err != nil {
+		return "failed", err
+	}
+	if produce.TransactionalID != nil {
+		// the transaction is not known to the mirror cluster
+		return "skipped", nil
+	}
+	if err := m.maybeRefreshMetadata(produce.TopicData, time.Now()); err != nil {
+		return "failed", err
+	}
+	topicDataByLeader := make(map[string][]protocol.ProduceTopicData)
+	for _, topicData := range produce.TopicData {
+		for _, partitionData := range topicData.PartitionData {
+			records, _, err := protocol.ClearProducerIDs(partitionData.Records)
+			if err != nil {
+				return "failed", errors.Wrapf(err, "records of topic %s partition %d", topicData.Topic, partitionData.Partition)
+			}
+			partitionData.Records = records
+			address, ok := m.leaderAddress(topicData.Topic, partitionData.Partition)
+			if !ok {
+				return "failed", fmt.Errorf("leader of topic %s partition %d is unknown", topicData.Topic, partitionData.Partition)
+			}
+			topicDataByLeader[address] = appendPartitionData(topicDataByLeader[address], topicData.Topic, partitionData)
+		}
+	}
+	for address, topicData := range topicDataByLeader {
+		produce.TopicData = topicData
+		if err := m.write(address, produce); err != nil {
+			// the leaders may have moved
+			m.metadataAt = time.Time{}
+			return "failed", errors.Wrapf(err, "mirror broker %s", address)
+		}
+	}
+	return "sent", nil
+}
+
+func appendPartitionData(topicData []protocol.ProduceTopicData, topic string, partitionData protocol.ProducePartitionData) []protocol.ProduceTopicData {
+	if n := len(topicData); n != 0 && topicData[n-1].Topic == topic {
+		topicData[n-1].PartitionData = append(topicData[n-1].PartitionData, partitionData)
+		return topicData
+	}
+	return append(topicData, protocol.ProduceTopicData{Topic: topic, PartitionData: []protocol.ProducePartitionData{partitionData}})
+}
+
+func (m *Mirror) leaderAddress(topic string, partition int32) (string, bool) {
+	leader, ok := m.leaders[mirrorPartition{topic: topic, partition: partition}]
+	if !ok {
+		return "", false
+	}
+	address, ok := m.brokers[leader]
+	return address, ok
+}
+
+// maybeRefreshMetadata fetches the metadata of the known and the new topics when the metadata is too old or a topic is new
+func (m *Mirror) maybeRefreshMetadata(topicData []protocol.ProduceTopicData, now time.Time) error {
+	refresh := now.Sub(m.metadataAt) >= m.metadataMaxAge
+	for _, data := range topicData {
+		if !m.topics[data.Topic] {
+			m.topics[data.Topic] = true
+			refresh = true
+		}
+	}
+	if !refresh {
+		return nil
+	}
+	topics := make([]string, 0, len(m.topics))
+	for topic := range m.topics {
+		topics = append(topics, topic)
+	}
+	var lastErr error
+	for _, bootstrapServer := range m.bootstrapServers {
+		payload, err := m.fetchMetadata(bootstrapServer, topics)
+		if err != nil {
+			lastErr = errors.Wrapf(err, "metadata of mirror broker %s", bootstrapServer)
+			continue
+		}
+		return m.updateMetadata(payload, now)
+	}
+	return lastErr
+}
+
+func (m *Mirror) updateMetadata(payload []byte, now time.Time) error {
+	brokers, err := protocol.DecodeMetadataBrokers(1, payload)
+	if err != nil {
+		return err
+	}
+	leaders, err := protocol.DecodeMetadataPartitionLeaders(1, payload)
+	if err != nil {
+		return err
+	}
+	m.brokers = make(map[int32]string, len(brokers))
+	for _, broker := range brokers {
+		m.brokers[broker.NodeID] = net.JoinHostPort(broker.Host, strconv.Itoa(int(broker.Port)))
+	}
+	m.leaders = make(map[mirrorPartition]int32, len(leaders))
+	for _, leader := range leaders {
+		m.leaders[mirrorPartition{topic: leader.Topic, partition: leader.Partition}] = leader.Leader
+	}
+	m.metadataAt = now
+	return nil
+}
+
+// fetchMetadata returns the metadata response v1 payload following the correlation id
+func (m *Mirror) fetchMetadata(bootstrapServer string, topics []string) ([]byte, error) {
+	conn, err := m.dialer.Dial("tcp", bootstrapServer)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	m.correlationID++
+	req := &protocol.Request{
+		ClientID:      m.clientID,
+		CorrelationID: m.correlationID,
+		Body:          &protocol.MetadataRequestV1{Topics: topics},
+	}
+	reqBuf, err := protocol.Encode(req)
+	if err != nil {
+		return nil, err
+	}
+	sizeBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(sizeBuf, uint32(len(reqBuf)))
+
+	if err = conn.SetWriteDeadline(time.Now().Add(m.writeTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err = conn.Write(bytes.Join([][]byte{sizeBuf, reqBuf}, nil)); err != nil {
+		return nil, errors.Wrap(err, "Failed to send metadata request")
+	}
+	if err = conn.SetReadDeadline(time.Now().Add(m.readTimeout)); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 8) // response header
+	if _, err = io.ReadFull(conn, header); err != nil {
+		return nil, errors.Wrap(err, "Failed to read metadata response header")
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 4 || int32(length) > protocol.MaxResponseSize {
+		return nil, fmt.Errorf("invalid metadata response length %d", length)
+	}
+	payload := make([]byte, length-4)
+	if _, err = io.ReadFull(conn, payload); err != nil {
+		return nil, errors.Wrap(err, "Failed to read metadata response payload")
+	}
+	return payload, nil
+}
+
+// write sends the produce request with acks=0 over the connection to the broker, the broker does not respond
+func (m *Mirror) write(address string, produce *protocol.ProduceRequest) error {
+	conn, ok := m.conns[address]
+	if !ok {
+		var err error
+		if conn, err = m.dialer.Dial("tcp", address); err != nil {
+			return err
+		}
+		m.conns[address] = conn
+	}
+	m.correlationID++
+	produce.CorrelationID = m.correlationID
+	produce.Acks = 0
+	body, err := protocol.Encode(produce)
+	if err != nil {
+		return err
+	}
+	// size, api key and version
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(4+len(body)))
+	binary.BigEndian.PutUint16(header[4:], uint16(apiKeyProduce))
+	binary.BigEndian.PutUint16(header[6:], uint16(produce.Version))
+
+	if err = conn.SetWriteDeadline(time.Now().Add(m.writeTimeout)); err == nil {
+		_, err = conn.Write(bytes.Join([][]byte{header, body}, nil))
+	}
+	if err != nil {
+		_ = conn.Close()
+		delete(m.conns, address)
+		return err
+	}
+	return nil
+}
+
+func (m *Mirror) closeConns() {
+	for address, conn := range m.conns {
+		_ = conn.Close()
+		delete(m.conns, address)
+	}
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// startMirrorBroker starts a broker leading the partitions 0 and 1 of the topic orders, which answers metadata requests
+// and passes the produce request bodies following the api key and version to the channel
+func startMirrorBroker(t *testing.T) (string, <-chan []byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	host, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	produced := make(chan []byte, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					sizeBuf := make([]byte, 4)
+					if _, err := io.ReadFull(conn, sizeBuf); err != nil {
+						return
+					}
+					request := make([]byte, binary.BigEndian.Uint32(sizeBuf))
+					if _, err := io.ReadFull(conn, request); err != nil {
+						return
+					}
+					if int16(binary.BigEndian.Uint16(request)) == apiKeyProduce {
+						produced <- request[4:]
+						continue
+					}
+					response := mirrorMetadataResponse(request[4:8], host, int32(port))
+					_, _ = conn.Write(response)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), produced
+}
+
+func mirrorMetadataResponse(correlationID []byte, host string, port int32) []byte {
+	var buf bytes.Buffer
+	put := func(v interface{}) { _ = binary.Write(&buf, binary.BigEndian, v) }
+	buf.Write(correlationID)
+	put(int32(1)) // brokers
+	put(int32(1))
+	put(int16(len(host)))
+	buf.WriteString(host)
+	put(port)
+	put(int16(-1)) // rack
+	put(int32(1))  // controller_id
+	put(int32(1))  // topic_metadata
+	put(int16(0))
+	put(int16(len("orders")))
+	buf.WriteString("orders")
+	buf.WriteByte(0) // is_internal
+	put(int32(2))    // partition_metadata
+	for partition := int32(0); partition < 2; partition++ {
+		put(int16(0))
+		put(partition)
+		put(int32(1)) // leader
+		put(int32(0)) // replicas
+		put(int32(0)) // isr
+	}
+	response := make([]byte, 4, 4+buf.Len())
+	binary.BigEndian.PutUint32(response, uint32(buf.Len()))
+	return append(response, buf.Bytes()...)
+}
+
+func testProduceRequestBody(t *testing.T, transactionalID *string, partitions ...int32) []byte {
+	request := &protocol.ProduceRequest{Version: 3, CorrelationID: 7, TransactionalID: transactionalID, Acks: -1, Timeout: 1000}
+	topicData := protocol.ProduceTopicData{Topic: "orders"}
+	for _, partition := range partitions {
+		topicData.PartitionData = append(topicData.PartitionData, protocol.ProducePartitionData{Partition: partition, Records: []byte{1, 2, 3}})
+	}
+	request.TopicData = []protocol.ProduceTopicData{topicData}
+	body, err := protocol.Encode(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func newTestMirror(bootstrapServer string, listeners []string, queueSize int) *Mirror {
+	return NewMirror([]string{bootstrapServer}, listeners, queueSize, time.Minute, "kafka-proxy", directDialer{dialTimeout: time.Second}, time.Second, time.Second)
+}
+
+func TestMirrorSendsToPartitionLeaders(t *testing.T) {
+	a := assert.New(t)
+
+	address, produced := startMirrorBroker(t)
+	mirror := newTestMirror(address, nil, 10)
+	defer mirror.closeConns()
+
+	result, err := mirror.send(mirroredRequest{apiVersion: 3, body: testProduceRequestBody(t, nil, 0, 1)})
+	a.Nil(err)
+	a.Equal("sent", result)
+
+	select {
+	case body := <-produced:
+		request := &protocol.ProduceRequest{Version: 3}
+		a.Nil(protocol.Decode(body, request))
+		a.Equal(int16(0), request.Acks, "mirrored requests are not acknowledged")
+		a.Len(request.TopicData, 1)
+		a.Len(request.TopicData[0].PartitionData, 2)
+	case <-time.After(5 * time.Second):
+		t.Fatal("produce request was not mirrored")
+	}
+
+	result, err = mirror.send(mirroredRequest{apiVersion: 3, body: testProduceRequestBody(t, nil, 2)})
+	a.Error(err, "partition 2 has no leader")
+	a.Equal("failed", result)
+}
+
+func TestMirrorClearsProducerIDs(t *testing.T) {
+	a := assert.New(t)
+
+	address, produced := startMirrorBroker(t)
+	mirror := newTestMirror(address, nil, 10)
+	defer mirror.closeConns()
+
+	// idempotent batch of producer 42
+	records := uncompressedRecordBatch("value")
+	binary.BigEndian.PutUint64(records[43:], 42)
+	request := &protocol.ProduceRequest{Version: 3, CorrelationID: 7, Acks: -1, Timeout: 1000, TopicData: []protocol.ProduceTopicData{
+		{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: records}}},
+	}}
+	body, err := protocol.Encode(request)
+	a.Nil(err)
+
+	result, err := mirror.send(mirroredRequest{apiVersion: 3, body: body})
+	a.Nil(err)
+	a.Equal("sent", result)
+
+	select {
+	case body := <-produced:
+		request := &protocol.ProduceRequest{Version: 3}
+		a.Nil(protocol.Decode(body, request))
+		mirrored := request.TopicData[0].PartitionData[0].Records
+		a.Equal(int64(-1), int64(binary.BigEndian.Uint64(mirrored[43:])), "producer id")
+		a.Equal(int32(-1), int32(binary.BigEndian.Uint32(mirrored[53:])), "base sequence")
+	case <-time.After(5 * time.Second):
+		t.Fatal("produce request was not mirrored")
+	}
+}
+
+func TestMirrorSkipsTransactionalRequests(t *testing.T) {
+	a := assert.New(t)
+
+	transactionalID := "tx"
+	mirror := newTestMirror("127.0.0.1:1", nil, 10)
+	result, err := mirror.send(mirroredRequest{apiVersion: 3, body: testProduceRequestBody(t, &transactionalID, 0)})
+	a.Nil(err)
+	a.Equal("skipped", result)
+}
+
+func TestMirrorDropsWhenQueueIsFull(t *testing.T) {
+	a := assert.New(t)
+
+	mirror := newTestMirror("127.0.0.1:1", nil, 1)
+	mirror.enqueue(3, testProduceRequestBody(t, nil, 0))
+	mirror.enqueue(3, testProduceRequestBody(t, nil, 1))
+	a.Len(mirror.queue, 1)
+}
+
+func TestMirrorListeners(t *testing.T) {
+	a := assert.New(t)
+
+	var disabled *Mirror
+	a.False(disabled.enabled())
+	a.False(disabled.mirrored("0.0.0.0:32400", "kafka-0:9092"))
+
+	a.True(newTestMirror("127.0.0.1:1", nil, 1).mirrored("0.0.0.0:32400", "kafka-0:9092"))
+	mirror := newTestMirror("127.0.0.1:1", []string{"0.0.0.0:32400", "kafka-1:9092"}, 1)
+	a.True(mirror.mirrored("0.0.0.0:32400", "kafka-0:9092"))
+	a.True(mirror.mirrored("0.0.0.0:32401", "kafka-1:9092"))
+	a.False(mirror.mirrored("0.0.0.0:32402", "kafka-2:9092"))
+}
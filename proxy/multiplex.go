@@ -0,0 +1,477 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/pkg/errors"
+)
+
+const apiKeySaslAuthenticate = int16(36)
+
+var (
+	errMultiplexSasl       = errors.New("SASL authentication with the brokers is not supported by multiplexed connections")
+	errMultiplexConnClosed = errors.New("multiplexed connection is closed")
+)
+
+// Multiplexer shares a small pool of broker connections between the client connections of a broker.
+// The correlation ids of the client requests are replaced by ids unique on the pooled connection and
+// the responses are demultiplexed to the client connections by the correlation id.
+type Multiplexer struct {
+	size int
+	dial func(brokerAddress string) (net.Conn, error)
+
+	lock   sync.Mutex
+	pools  map[string]*brokerPool
+	closed bool
+}
+
+func NewMultiplexer(size int, dial func(brokerAddress string) (net.Conn, error)) *Multiplexer {
+	return &Multiplexer{
+		size:  size,
+		dial:  dial,
+		pools: make(map[string]*brokerPool),
+	}
+}
+
+// conn returns a client connection multiplexed over a pooled connection to the broker
+func (m *Multiplexer) conn(brokerAddress string) (net.Conn, error) {
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return nil, errMultiplexConnClosed
+	}
+	pool, ok := m.pools[brokerAddress]
+	if !ok {
+		pool = &brokerPool{brokerAddress: brokerAddress, size: m.size, dial: m.dial}
+		m.pools[brokerAddress] = pool
+	}
+	m.lock.Unlock()
+	return pool.conn()
+}
+
+// close closes the pooled connections and the client connections multiplexed over them
+func (m *Multiplexer) close() {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.closed = true
+	for _, pool := range m.pools {
+		pool.close()
+	}
+}
+
+// brokerPool are the pooled connections to a broker
+type brokerPool struct {
+	brokerAddress string
+	size          int
+	dial          func(brokerAddress string) (net.Conn, error)
+
+	lock  sync.Mutex
+	conns []*pooledConn
+}
+
+// conn dials a new pooled connection until the pool is full, afterwards the client is assigned to the pooled connection with the fewest clients
+func (p *brokerPool) conn() (net.Conn, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var selected *pooledConn
+	conns := p.conns[:0]
+	for _, pooled := range p.conns {
+		if pooled.isClosed() {
+			continue
+		}
+		conns = append(conns, pooled)
+		if selected == nil || pooled.clientCount() < selected.clientCount() {
+			selected = pooled
+		}
+	}
+	p.conns = conns
+
+	if len(p.conns) < p.size && (selected == nil || selected.clientCount() > 0) {
+		conn, err := p.dial(p.brokerAddress)
+		if err != nil {
+			if selected == nil {
+				return nil, err
+			}
+			subsystemLog(logSubsystemBroker).Infof("couldn't add pooled connection to %s, multiplexing over existing connections: %v", p.brokerAddress, err)
+		} else {
+			selected = newPooledConn(p.brokerAddress, conn)
+			p.conns = append(p.conns, selected)
+			subsystemLog(logSubsystemBroker).Infof("Pooled connection %d/%d to %s opened on %v", len(p.conns), p.size, p.brokerAddress, conn.LocalAddr())
+		}
+	}
+	return selected.newClient()
+}
+
+func (p *brokerPool) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, pooled := range p.conns {
+		pooled.fail(errMultiplexConnClosed)
+	}
+	p.conns = nil
+}
+
+type pendingResponse struct {
+	client        *muxConn
+	correlationID int32
+}
+
+// pooledConn is a broker connection shared by the client connections
+type pooledConn struct {
+	brokerAddress string
+	conn          net.Conn
+
+	writeLock sync.Mutex
+
+	lock              sync.Mutex
+	nextCorrelationID int32
+	pending           map[int32]pendingResponse
+	clients           map[*muxConn]struct{}
+	err               error
+}
+
+func newPooledConn(brokerAddress string, conn net.Conn) *pooledConn {
+	pooled := &pooledConn{
+		brokerAddress: brokerAddress,
+		conn:          conn,
+		pending:       make(map[int32]pendingResponse),
+		clients:       make(map[*muxConn]struct{}),
+	}
+	proxyMultiplexBrokerConnections.WithLabelValues(brokerAddress).Inc()
+	go withRecover(pooled.readLoop)
+	return pooled
+}
+
+func (c *pooledConn) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err != nil
+}
+
+func (c *pooledConn) clientCount() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.clients)
+}
+
+func (c *pooledConn) newClient() (*muxConn, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	client := &muxConn{
+		pooled: c,
+		signal: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	c.clients[client] = struct{}{}
+	return client, nil
+}
+
+func (c *pooledConn) removeClient(client *muxConn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.clients, client)
+	// responses to the removed client are discarded by the read loop
+	for id, pending := range c.pending {
+		if pending.client == client {
+			delete(c.pending, id)
+		}
+	}
+}
+
+// send writes the request frame with a correlation id of the pooled connection
+func (c *pooledConn) send(client *muxConn, frame []byte, expectsResponse bool, deadline time.Time) error {
+	c.lock.Lock()
+	if c.err != nil {
+		c.lock.Unlock()
+		return c.err
+	}
+	c.nextCorrelationID++
+	if c.nextCorrelationID < 0 {
+		c.nextCorrelationID = 0
+	}
+	id := c.nextCorrelationID
+	correlationID := int32(binary.BigEndian.Uint32(frame[8:]))
+	if expectsResponse {
+		c.pending[id] = pendingResponse{client: client, correlationID: correlationID}
+	}
+	c.lock.Unlock()
+
+	binary.BigEndian.PutUint32(frame[8:], uint32(id))
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		c.fail(err)
+		return err
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+// readLoop demultiplexes the responses to the client connections until the pooled connection fails
+func (c *pooledConn) readLoop() {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			c.fail(err)
+			return
+		}
+		length := int32(binary.BigEndian.Uint32(header))
+		if length < 4 || length > protocol.MaxResponseSize {
+			c.fail(errors.Errorf("invalid response length %d from %s", length, c.brokerAddress))
+			return
+		}
+		frame := make([]byte, 4+length)
+		copy(frame, header)
+		if _, err := io.ReadFull(c.conn, frame[len(header):]); err != nil {
+			c.fail(err)
+			return
+		}
+		id := int32(binary.BigEndian.Uint32(frame[4:]))
+
+		c.lock.Lock()
+		pending, ok := c.pending[id]
+		delete(c.pending, id)
+		c.lock.Unlock()
+		if !ok {
+			continue
+		}
+		binary.BigEndian.PutUint32(frame[4:], uint32(pending.correlationID))
+		pending.client.deliver(frame)
+	}
+}
+
+// fail closes the pooled connection and the client connections multiplexed over it
+func (c *pooledConn) fail(err error) {
+	c.lock.Lock()
+	if c.err != nil {
+		c.lock.Unlock()
+		return
+	}
+	c.err = err
+	clients := c.clients
+	c.clients = make(map[*muxConn]struct{})
+	c.pending = make(map[int32]pendingResponse)
+	c.lock.Unlock()
+
+	_ = c.conn.Close()
+	proxyMultiplexBrokerConnections.WithLabelValues(c.brokerAddress).Dec()
+	if err != errMultiplexConnClosed {
+		subsystemLog(logSubsystemBroker).Infof("Pooled connection to %s on %v closed with %d client(s): %v", c.brokerAddress, c.conn.LocalAddr(), len(clients), err)
+	}
+	for client := range clients {
+		client.closeWithError(err)
+	}
+}
+
+// muxConn is a client connection multiplexed over a pooled broker connection
+type muxConn struct {
+	pooled *pooledConn
+
+	// request bytes not yet forming a complete request frame
+	requestBuf    []byte
+	writeDeadline time.Time
+
+	lock         sync.Mutex
+	responses    [][]byte
+	response     []byte
+	readDeadline time.Time
+	err          error
+	signal       chan struct{}
+	closed       chan struct{}
+	closeOnce    sync.Once
+}
+
+func (c *muxConn) Write(p []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, c.closeError()
+	default:
+	}
+	c.requestBuf = append(c.requestBuf, p...)
+	for len(c.requestBuf) >= 4 {
+		length := int32(binary.BigEndian.Uint32(c.requestBuf))
+		if length < 8 || length > protocol.MaxRequestSize {
+			return 0, errors.Errorf("invalid request length %d", length)
+		}
+		if len(c.requestBuf) < int(4+length) {
+			break
+		}
+		frame := c.requestBuf[:4+length]
+		c.requestBuf = c.requestBuf[4+length:]
+		if err := c.writeFrame(frame); err != nil {
+			return 0, err
+		}
+	}
+	if len(c.requestBuf) == 0 {
+		c.requestBuf = nil
+	}
+	return len(p), nil
+}
+
+func (c *muxConn) writeFrame(frame []byte) error {
+	apiKey := int16(binary.BigEndian.Uint16(frame[4:]))
+	apiVersion := int16(binary.BigEndian.Uint16(frame[6:]))
+	if apiKey == apiKeySaslHandshake || apiKey == apiKeySaslAuthenticate {
+		return errMultiplexSasl
+	}
+	expectsResponse := true
+	if apiKey == apiKeyProduce {
+		acks, err := produceAcks(apiVersion, frame[8:])
+		if err != nil {
+			return err
+		}
+		expectsResponse = acks != 0
+	}
+	return c.pooled.send(c, frame, expectsResponse, c.writeDeadline)
+}
+
+// produceAcks returns the acks of the produce request after the api key and version
+func produceAcks(apiVersion int16, request []byte) (int16, error) {
+	acksReader := protocol.RequestAcksReader{}
+	reader := bytes.NewReader(request)
+	// CorrelationID + ClientID
+	if err := acksReader.ReadAndDiscardHeaderV1Part(reader); err != nil {
+		return 0, err
+	}
+	switch apiVersion {
+	case 0, 1, 2:
+		return acksReader.ReadAndDiscardProduceAcks(reader)
+	case 3, 4, 5, 6, 7, 8:
+		return acksReader.ReadAndDiscardProduceTxnAcks(reader)
+	default:
+		return 0, errors.Errorf("produce version %d is not supported", apiVersion)
+	}
+}
+
+func (c *muxConn) deliver(frame []byte) {
+	c.lock.Lock()
+	c.responses = append(c.responses, frame)
+	c.lock.Unlock()
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (c *muxConn) Read(p []byte) (int, error) {
+	for {
+		c.lock.Lock()
+		if len(c.response) == 0 && len(c.responses) != 0 {
+			c.response = c.responses[0]
+			c.responses[0] = nil
+			c.responses = c.responses[1:]
+		}
+		if len(c.response) != 0 {
+			n := copy(p, c.response)
+			c.response = c.response[n:]
+			c.lock.Unlock()
+			return n, nil
+		}
+		deadline := c.readDeadline
+		c.lock.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, muxTimeoutError{}
+			}
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-c.signal:
+		case <-c.closed:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-c.closed:
+			c.lock.Lock()
+			queued := len(c.responses) != 0
+			c.lock.Unlock()
+			if !queued {
+				return 0, c.closeError()
+			}
+		default:
+		}
+	}
+}
+
+func (c *muxConn) Close() error {
+	c.closeWithError(errMultiplexConnClosed)
+	return nil
+}
+
+func (c *muxConn) closeWithError(err error) {
+	c.closeOnce.Do(func() {
+		c.lock.Lock()
+		c.err = err
+		c.lock.Unlock()
+		close(c.closed)
+		c.pooled.removeClient(c)
+	})
+}
+
+func (c *muxConn) closeError() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err == errMultiplexConnClosed {
+		return io.EOF
+	}
+	return c.err
+}
+
+func (c *muxConn) LocalAddr() net.Addr {
+	return c.pooled.conn.LocalAddr()
+}
+
+func (c *muxConn) RemoteAddr() net.Addr {
+	return c.pooled.conn.RemoteAddr()
+}
+
+func (c *muxConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *muxConn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline sets the deadline of the next request frame write to the pooled connection
+func (c *muxConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline = t
+	return nil
+}
+
+type muxTimeoutError struct{}
+
+func (muxTimeoutError) Error() string   { return "i/o timeout" }
+func (muxTimeoutError) Timeout() bool   { return true }
+func (muxTimeoutError) Temporary() bool { return true }
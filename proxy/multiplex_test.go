@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// request frame with a null client id and the body
+func multiplexRequest(apiKey, apiVersion int16, correlationID int32, body []byte) []byte {
+	frame := make([]byte, 14+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(10+len(body)))
+	binary.BigEndian.PutUint16(frame[4:], uint16(apiKey))
+	binary.BigEndian.PutUint16(frame[6:], uint16(apiVersion))
+	binary.BigEndian.PutUint32(frame[8:], uint32(correlationID))
+	binary.BigEndian.PutUint16(frame[12:], 0xffff)
+	copy(frame[14:], body)
+	return frame
+}
+
+func multiplexResponse(correlationID int32, body []byte) []byte {
+	frame := make([]byte, 8+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(4+len(body)))
+	binary.BigEndian.PutUint32(frame[4:], uint32(correlationID))
+	copy(frame[8:], body)
+	return frame
+}
+
+func readMultiplexFrame(t *testing.T, r io.Reader) []byte {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(r, header)
+	require.NoError(t, err)
+	frame := make([]byte, 4+binary.BigEndian.Uint32(header))
+	copy(frame, header)
+	_, err = io.ReadFull(r, frame[4:])
+	require.NoError(t, err)
+	return frame
+}
+
+// sendMultiplexRequest writes the request to the client connection and returns the request frame received by the broker
+func sendMultiplexRequest(t *testing.T, client net.Conn, broker net.Conn, request []byte) []byte {
+	written := make(chan error, 1)
+	go func() {
+		_, err := client.Write(request)
+		written <- err
+	}()
+	frame := readMultiplexFrame(t, broker)
+	require.NoError(t, <-written)
+	return frame
+}
+
+type fakeBrokers struct {
+	conns chan net.Conn
+}
+
+func newFakeBrokers() *fakeBrokers {
+	return &fakeBrokers{conns: make(chan net.Conn, 10)}
+}
+
+func (b *fakeBrokers) dial(string) (net.Conn, error) {
+	proxySide, brokerSide := net.Pipe()
+	b.conns <- brokerSide
+	return proxySide, nil
+}
+
+func (b *fakeBrokers) next(t *testing.T) net.Conn {
+	select {
+	case conn := <-b.conns:
+		return conn
+	case <-time.After(time.Second):
+		t.Fatal("no broker connection")
+		return nil
+	}
+}
+
+func TestMultiplexerDemultiplexesResponsesByCorrelationID(t *testing.T) {
+	brokers := newFakeBrokers()
+	m := NewMultiplexer(1, brokers.dial)
+	defer m.close()
+
+	client1, err := m.conn("broker1:9092")
+	require.NoError(t, err)
+	client2, err := m.conn("broker1:9092")
+	require.NoError(t, err)
+	broker := brokers.next(t)
+	assert.Len(t, brokers.conns, 0, "clients share the pooled connection")
+
+	// the request is written in parts like the processor does
+	request := multiplexRequest(3, 1, 7, nil)
+	_, err = client1.Write(request[:6])
+	require.NoError(t, err)
+	frame1 := sendMultiplexRequest(t, client1, broker, request[6:])
+	frame2 := sendMultiplexRequest(t, client2, broker, multiplexRequest(3, 1, 7, nil))
+
+	id1, id2 := int32(binary.BigEndian.Uint32(frame1[8:])), int32(binary.BigEndian.Uint32(frame2[8:]))
+	assert.NotEqual(t, id1, id2)
+
+	// responses in reverse order
+	go func() {
+		_, _ = broker.Write(multiplexResponse(id2, []byte("second")))
+		_, _ = broker.Write(multiplexResponse(id1, []byte("first")))
+	}()
+	assert.Equal(t, multiplexResponse(7, []byte("first")), readMultiplexFrame(t, client1))
+	assert.Equal(t, multiplexResponse(7, []byte("second")), readMultiplexFrame(t, client2))
+}
+
+func TestMultiplexerProduceWithoutAcks(t *testing.T) {
+	brokers := newFakeBrokers()
+	m := NewMultiplexer(1, brokers.dial)
+	defer m.close()
+
+	client, err := m.conn("broker1:9092")
+	require.NoError(t, err)
+	broker := brokers.next(t)
+
+	// produce v0 acks=0
+	sendMultiplexRequest(t, client, broker, multiplexRequest(apiKeyProduce, 0, 1, []byte{0, 0, 0, 0, 0, 100}))
+
+	pooled := client.(*muxConn).pooled
+	pooled.lock.Lock()
+	assert.Len(t, pooled.pending, 0)
+	pooled.lock.Unlock()
+
+	// produce v0 acks=1
+	sendMultiplexRequest(t, client, broker, multiplexRequest(apiKeyProduce, 0, 2, []byte{0, 1, 0, 0, 0, 100}))
+
+	pooled.lock.Lock()
+	assert.Len(t, pooled.pending, 1)
+	pooled.lock.Unlock()
+}
+
+func TestMultiplexerRejectsSasl(t *testing.T) {
+	brokers := newFakeBrokers()
+	m := NewMultiplexer(1, brokers.dial)
+	defer m.close()
+
+	client, err := m.conn("broker1:9092")
+	require.NoError(t, err)
+
+	_, err = client.Write(multiplexRequest(apiKeySaslHandshake, 1, 1, nil))
+	assert.Equal(t, errMultiplexSasl, err)
+}
+
+func TestMultiplexerReadDeadline(t *testing.T) {
+	brokers := newFakeBrokers()
+	m := NewMultiplexer(1, brokers.dial)
+	defer m.close()
+
+	client, err := m.conn("broker1:9092")
+	require.NoError(t, err)
+
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = client.Read(make([]byte, 1))
+	require.Error(t, err)
+	netErr, ok := err.(net.Error)
+	require.True(t, ok)
+	assert.True(t, netErr.Timeout())
+}
+
+func TestMultiplexerPoolAssignsLeastLoadedConnection(t *testing.T) {
+	brokers := newFakeBrokers()
+	m := NewMultiplexer(2, brokers.dial)
+	defer m.close()
+
+	client1, err := m.conn("broker1:9092")
+	require.NoError(t, err)
+	client2, err := m.conn("broker1:9092")
+	require.NoError(t, err)
+	assert.Len(t, brokers.conns, 2)
+	assert.NotEqual(t, client1.(*muxConn).pooled, client2.(*muxConn).pooled)
+
+	require.NoError(t, client1.Close())
+	client3, err := m.conn("broker1:9092")
+	require.NoError(t, err)
+	assert.Len(t, brokers.conns, 2, "pool is full")
+	assert.Equal(t, client1.(*muxConn).pooled, client3.(*muxConn).pooled)
+
+	// connections to other brokers are pooled separately
+	_, err = m.conn("broker2:9092")
+	require.NoError(t, err)
+	assert.Len(t, brokers.conns, 3)
+}
+
+func TestMultiplexerBrokerConnectionFailureClosesClients(t *testing.T) {
+	brokers := newFakeBrokers()
+	m := NewMultiplexer(1, brokers.dial)
+	defer m.close()
+
+	client, err := m.conn("broker1:9092")
+	require.NoError(t, err)
+	broker := brokers.next(t)
+	require.NoError(t, broker.Close())
+
+	_, err = client.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	// the failed connection is replaced
+	_, err = m.conn("broker1:9092")
+	require.NoError(t, err)
+	assert.Len(t, brokers.conns, 1)
+}
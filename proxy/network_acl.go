@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"net"
+	"sync"
+
+	"github.com/grepplabs/kafka-proxy/config"
+)
+
+// NetworkACL refuses client connections by the network of the client address per listener. It is checked on accept before
+// the TLS handshake, so refused clients cannot use the handshake or the authentication. The networks are replaced on reload.
+type NetworkACL struct {
+	lock  sync.RWMutex
+	allow config.ListenerCIDRs
+	deny  config.ListenerCIDRs
+}
+
+func NewNetworkACL(allow config.ListenerCIDRs, deny config.ListenerCIDRs) *NetworkACL {
+	return &NetworkACL{allow: allow, deny: deny}
+}
+
+// Update replaces the allowed and the denied networks
+func (a *NetworkACL) Update(allow config.ListenerCIDRs, deny config.ListenerCIDRs) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.allow = allow
+	a.deny = deny
+}
+
+// rejected returns the reason the client address is refused by the listener, "denied" if it is in a denied network and
+// "not_allowed" if the listener has allowed networks and it is in none of them. An empty reason accepts the connection.
+func (a *NetworkACL) rejected(listenerAddress string, brokerAddress string, addr net.Addr) string {
+	a.lock.RLock()
+	allow := a.allow.Networks(listenerAddress, brokerAddress)
+	deny := a.deny.Networks(listenerAddress, brokerAddress)
+	a.lock.RUnlock()
+	if len(allow) == 0 && len(deny) == 0 {
+		return ""
+	}
+	ip := net.ParseIP(clientHost(addr))
+	if ip == nil {
+		// unix sockets and unknown addresses cannot be matched
+		if len(allow) != 0 {
+			return "not_allowed"
+		}
+		return ""
+	}
+	if containsIP(deny, ip) {
+		return "denied"
+	}
+	if len(allow) != 0 && !containsIP(allow, ip) {
+		return "not_allowed"
+	}
+	return ""
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
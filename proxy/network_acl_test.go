@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func testListenerCIDRs(t *testing.T, values ...string) config.ListenerCIDRs {
+	var cidrs config.ListenerCIDRs
+	for _, value := range values {
+		if err := cidrs.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cidrs
+}
+
+func TestNetworkACLRejected(t *testing.T) {
+	a := assert.New(t)
+
+	acl := NewNetworkACL(
+		testListenerCIDRs(t, "0.0.0.0:32400=10.0.0.0/8,fd00::/8"),
+		testListenerCIDRs(t, "0.0.0.0:32400=10.1.0.0/16", "kafka-1:9092=192.168.0.0/16"),
+	)
+	client := func(ip string) net.Addr { return &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000} }
+
+	a.Equal("", acl.rejected("0.0.0.0:32400", "kafka-0:9092", client("10.2.3.4")))
+	a.Equal("", acl.rejected("0.0.0.0:32400", "kafka-0:9092", client("fd00::1")))
+	a.Equal("denied", acl.rejected("0.0.0.0:32400", "kafka-0:9092", client("10.1.3.4")))
+	a.Equal("not_allowed", acl.rejected("0.0.0.0:32400", "kafka-0:9092", client("172.16.0.1")))
+	a.Equal("not_allowed", acl.rejected("0.0.0.0:32400", "kafka-0:9092", &net.UnixAddr{Name: "@", Net: "unix"}))
+
+	// the deny list of the broker address applies to all its listeners, other clients are allowed
+	a.Equal("denied", acl.rejected("0.0.0.0:32401", "kafka-1:9092", client("192.168.1.1")))
+	a.Equal("", acl.rejected("0.0.0.0:32401", "kafka-1:9092", client("172.16.0.1")))
+	a.Equal("", acl.rejected("0.0.0.0:32402", "kafka-2:9092", client("192.168.1.1")))
+
+	acl.Update(nil, testListenerCIDRs(t, "kafka-2:9092=0.0.0.0/0"))
+	a.Equal("", acl.rejected("0.0.0.0:32400", "kafka-0:9092", client("172.16.0.1")))
+	a.Equal("denied", acl.rejected("0.0.0.0:32402", "kafka-2:9092", client("192.168.1.1")))
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"sync/atomic"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+)
+
+// Passthrough selects trusted clients, which requests are forwarded without policy enforcement (e.g. forbidden api keys).
+// The clients are trusted by their authenticated principal only, client ids are chosen by the clients.
+// The connection of a trusted client is spliced: the requests are copied without being decoded or buffered, so the request
+// limits, interceptor, payload encryption, compression transcoding and mirroring are not applied either. Rewriting of broker
+// addresses in responses is still performed as it is required for routing.
+type Passthrough struct {
+	principals map[string]struct{}
+}
+
+func NewPassthrough(principals []string) *Passthrough {
+	p := &Passthrough{
+		principals: make(map[string]struct{}),
+	}
+	for _, principal := range principals {
+		p.principals[principal] = struct{}{}
+	}
+	return p
+}
+
+func (p *Passthrough) enabled() bool {
+	return p != nil && len(p.principals) != 0
+}
+
+func (p *Passthrough) matchPrincipal(principal string) bool {
+	if p == nil || principal == "" {
+		return false
+	}
+	_, ok := p.principals[principal]
+	return ok
+}
+
+// passthroughState is shared by the requests and responses loops of a connection
+type passthroughState struct {
+	spliced int32
+}
+
+// splice marks the connection as spliced and reports whether it was not spliced before
+func (s *passthroughState) splice() bool {
+	return s != nil && atomic.CompareAndSwapInt32(&s.spliced, 0, 1)
+}
+
+func (s *passthroughState) isSpliced() bool {
+	return s != nil && atomic.LoadInt32(&s.spliced) == 1
+}
+
+// readRequestClientID reads CorrelationID and ClientID from the request header. The read bytes are returned as they must be forwarded to the broker.
+func readRequestClientID(src io.Reader, requestKeyVersion *protocol.RequestKeyVersion) (readBytes []byte, clientID string, err error) {
+	// ApiKey + ApiVersion + CorrelationID + ClientID length
+	if requestKeyVersion.Length < 10 {
+		return nil, "", nil
+	}
+	var bufferRead bytes.Buffer
+	reader := io.TeeReader(io.LimitReader(src, int64(requestKeyVersion.Length-4)), &bufferRead)
+
+	_, clientIDPtr, err := protocol.RequestHeaderReader{}.ReadHeaderV1Part(reader)
+	if err != nil {
+		return nil, "", err
+	}
+	if clientIDPtr != nil {
+		clientID = *clientIDPtr
+	}
+	return bufferRead.Bytes(), clientID, nil
+}
+
+// tlsPeerPrincipal returns common name of the client certificate or empty string if the connection is not mutual TLS
+func tlsPeerPrincipal(conn interface{}) string {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+	clientCert := filterClientCertificate(tlsConn.ConnectionState().PeerCertificates)
+	if clientCert == nil {
+		return ""
+	}
+	return clientCert.Subject.CommonName
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/grepplabs/kafka-proxy/config"
 	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/sirupsen/logrus"
 	"time"
 )
 
@@ -33,6 +34,7 @@ var (
 
 type ProcessorConfig struct {
 	MaxOpenRequests       int
+	MaxInFlightRequests   int
 	NetAddressMappingFunc config.NetAddressMappingFunc
 	RequestBufferSize     int
 	ResponseBufferSize    int
@@ -40,14 +42,45 @@ type ProcessorConfig struct {
 	ReadTimeout           time.Duration
 	LocalSasl             *LocalSasl
 	AuthServer            *AuthServer
-	ForbiddenApiKeys      map[int16]struct{}
+	ApiKeyRules           *ApiKeyRules
 	ProducerAcks0Disabled bool
+	Passthrough           *Passthrough
+	TopicWatermarks       *topicWatermarks
+	MaxApiVersions        config.MaxApiVersions
+	Deprecation           *Deprecation
+	Interceptor           *RequestInterceptor
+	Pseudonymizer         *Pseudonymizer
+	TopicPolicy           *TopicPolicy
+	SchemaValidation      *SchemaValidation
+	PayloadEncryption     *PayloadEncryption
+	RequestLimits         *RequestLimits
+	Quotas                *Quotas
+	AuthLimiter           *AuthLimiter
+	RequestLatency        *RequestLatency
+	// per connection, nil when the handshake timeout is disabled
+	HandshakeDeadline *handshakeDeadline
+	// per connection, nil when the credential expiry is not enforced
+	CredentialExpiry *credentialExpiry
+	// per connection, nil when the broker session does not expire
+	BrokerReauth *brokerReauth
+	// per connection listener, nil when the record batches are not transcoded
+	CompressionTranscoding *CompressionTranscoding
+	// per connection listener, nil when the produce requests are not mirrored
+	Mirror *Mirror
+	// per connection, nil when no faults are injected
+	Chaos *Chaos
+	// per connection, nil when the connection is not a tenant
+	Tenancy *tenantConnection
+	// per connection, nil when the connection is not tracked
+	Connection *trackedConnection
 }
 
 type processor struct {
 	openRequestsChannel        chan protocol.RequestKeyVersion
 	nextRequestHandlerChannel  chan RequestHandler
 	nextResponseHandlerChannel chan ResponseHandler
+	// nil when the in-flight requests limit is disabled
+	inFlightSlots chan struct{}
 
 	netAddressMappingFunc config.NetAddressMappingFunc
 	requestBufferSize     int
@@ -58,11 +91,48 @@ type processor struct {
 	localSasl  *LocalSasl
 	authServer *AuthServer
 
-	forbiddenApiKeys map[int16]struct{}
+	passthrough      *Passthrough
+	passthroughState *passthroughState
+
+	apiKeyRules *ApiKeyRules
 	// metrics
 	brokerAddress string
 	// producer will never send request with acks=0
 	producerAcks0Disabled bool
+
+	topicWatermarks *topicWatermarks
+
+	// empty when the api versions are not capped
+	maxApiVersions config.MaxApiVersions
+
+	deprecation      *Deprecation
+	deprecationState *deprecationState
+
+	interceptor           *RequestInterceptor
+	interceptedConnection *interceptedConnection
+
+	pseudonymizer *Pseudonymizer
+
+	topicPolicy            *TopicPolicy
+	topicPolicyState       *topicPolicyState
+	schemaValidation       *SchemaValidation
+	payloadEncryption      *PayloadEncryption
+	requestLimits          *RequestLimits
+	producePartitionErrors *producePartitionErrorsState
+	quotas                 *Quotas
+	quotaState             *quotaState
+	authLimiter            *AuthLimiter
+	requestLatency         *RequestLatency
+	requestLatencyState    *requestLatencyState
+	handshakeDeadline      *handshakeDeadline
+	credentialExpiry       *credentialExpiry
+	brokerReauth           *brokerReauth
+
+	compressionTranscoding *CompressionTranscoding
+	mirror                 *Mirror
+	chaos                  *Chaos
+	tenancy                *tenantConnection
+	connection             *trackedConnection
 }
 
 func newProcessor(cfg ProcessorConfig, brokerAddress string) *processor {
@@ -93,10 +163,16 @@ func newProcessor(cfg ProcessorConfig, brokerAddress string) *processor {
 	nextRequestHandlerChannel <- defaultRequestHandler
 	nextResponseHandlerChannel <- defaultResponseHandler
 
+	var inFlightSlots chan struct{}
+	if cfg.MaxInFlightRequests > 0 {
+		inFlightSlots = make(chan struct{}, cfg.MaxInFlightRequests)
+	}
+
 	return &processor{
 		openRequestsChannel:        make(chan protocol.RequestKeyVersion, maxOpenRequests),
 		nextRequestHandlerChannel:  nextRequestHandlerChannel,
 		nextResponseHandlerChannel: nextResponseHandlerChannel,
+		inFlightSlots:              inFlightSlots,
 		netAddressMappingFunc:      cfg.NetAddressMappingFunc,
 		requestBufferSize:          requestBufferSize,
 		responseBufferSize:         responseBufferSize,
@@ -105,34 +181,110 @@ func newProcessor(cfg ProcessorConfig, brokerAddress string) *processor {
 		brokerAddress:              brokerAddress,
 		localSasl:                  cfg.LocalSasl,
 		authServer:                 cfg.AuthServer,
-		forbiddenApiKeys:           cfg.ForbiddenApiKeys,
+		apiKeyRules:                cfg.ApiKeyRules,
 		producerAcks0Disabled:      cfg.ProducerAcks0Disabled,
+		passthrough:                cfg.Passthrough,
+		passthroughState:           &passthroughState{},
+		topicWatermarks:            cfg.TopicWatermarks,
+		maxApiVersions:             cfg.MaxApiVersions,
+		deprecation:                cfg.Deprecation,
+		deprecationState:           &deprecationState{},
+		interceptor:                cfg.Interceptor,
+		interceptedConnection:      &interceptedConnection{},
+		pseudonymizer:              cfg.Pseudonymizer,
+		topicPolicy:                cfg.TopicPolicy,
+		topicPolicyState:           &topicPolicyState{},
+		schemaValidation:           cfg.SchemaValidation,
+		payloadEncryption:          cfg.PayloadEncryption,
+		compressionTranscoding:     cfg.CompressionTranscoding,
+		mirror:                     cfg.Mirror,
+		chaos:                      cfg.Chaos,
+		tenancy:                    cfg.Tenancy,
+		requestLimits:              cfg.RequestLimits,
+		producePartitionErrors:     &producePartitionErrorsState{},
+		quotas:                     cfg.Quotas,
+		quotaState:                 &quotaState{},
+		authLimiter:                cfg.AuthLimiter,
+		requestLatency:             cfg.RequestLatency,
+		requestLatencyState:        &requestLatencyState{},
+		handshakeDeadline:          cfg.HandshakeDeadline,
+		credentialExpiry:           cfg.CredentialExpiry,
+		brokerReauth:               cfg.BrokerReauth,
+		connection:                 cfg.Connection,
 	}
 }
 
 func (p *processor) RequestsLoop(dst DeadlineWriter, src DeadlineReaderWriter) (readErr bool, err error) {
 
+	var gatewayPrincipal string
 	if p.authServer.enabled {
-		if err = p.authServer.receiveAndSendGatewayAuth(src); err != nil {
+		var gatewayExpiry time.Time
+		if gatewayPrincipal, gatewayExpiry, err = p.authServer.receiveAndSendGatewayAuth(src); err != nil {
+			if isAuthFailure(err) {
+				p.authLimiter.failed(remoteAddr(src), p.brokerAddress)
+			}
 			return true, err
 		}
+		p.connection.setPrincipal(gatewayPrincipal)
+		p.credentialExpiry.set(credentialGatewayToken, gatewayExpiry)
 	}
 	src.SetDeadline(time.Time{})
+	if !p.localSasl.enabled {
+		p.handshakeDeadline.complete()
+	}
 
 	ctx := &RequestsLoopContext{
 		openRequestsChannel:        p.openRequestsChannel,
 		nextRequestHandlerChannel:  p.nextRequestHandlerChannel,
 		nextResponseHandlerChannel: p.nextResponseHandlerChannel,
+		inFlightSlots:              p.inFlightSlots,
+		inFlightWaitTimeout:        p.readTimeout,
 		timeout:                    p.writeTimeout,
 		brokerAddress:              p.brokerAddress,
-		forbiddenApiKeys:           p.forbiddenApiKeys,
+		apiKeyRules:                p.apiKeyRules,
 		buf:                        make([]byte, p.requestBufferSize),
 		localSasl:                  p.localSasl,
 		localSaslDone:              false, // sequential processing - mutex is required
 		producerAcks0Disabled:      p.producerAcks0Disabled,
+		passthrough:                p.passthrough,
+		passthroughState:           p.passthroughState,
+		topicWatermarks:            p.topicWatermarks,
+		deprecation:                p.deprecation,
+		deprecationState:           p.deprecationState,
+		interceptor:                p.interceptor,
+		interceptedConnection:      p.interceptedConnection,
+		pseudonymizer:              p.pseudonymizer,
+		topicPolicy:                p.topicPolicy,
+		topicPolicyState:           p.topicPolicyState,
+		schemaValidation:           p.schemaValidation,
+		payloadEncryption:          p.payloadEncryption,
+		compressionTranscoding:     p.compressionTranscoding,
+		mirror:                     p.mirror,
+		chaos:                      p.chaos,
+		tenancy:                    p.tenancy,
+		requestLimits:              p.requestLimits,
+		producePartitionErrors:     p.producePartitionErrors,
+		quotas:                     p.quotas,
+		quotaState:                 p.quotaState,
+		authLimiter:                p.authLimiter,
+		requestLatency:             p.requestLatency,
+		requestLatencyState:        p.requestLatencyState,
+		handshakeDeadline:          p.handshakeDeadline,
+		credentialExpiry:           p.credentialExpiry,
+		brokerReauth:               p.brokerReauth,
+		principal:                  gatewayPrincipal,
+		connection:                 p.connection,
+	}
+	if ctx.passthrough.matchPrincipal(gatewayPrincipal) {
+		ctx.logger().Infof("Passthrough enabled for principal %s (%s)", ctx.pseudonymizer.principal(gatewayPrincipal), ctx.brokerAddress)
+		ctx.bypassPolicies = true
 	}
 
-	return ctx.requestsLoop(dst, src)
+	readErr, err = ctx.requestsLoop(dst, src)
+	if p.passthroughState.isSpliced() {
+		p.topicWatermarks.passthroughClosed()
+	}
+	return readErr, err
 }
 
 type RequestsLoopContext struct {
@@ -140,15 +292,85 @@ type RequestsLoopContext struct {
 	nextRequestHandlerChannel  chan RequestHandler
 	nextResponseHandlerChannel chan<- ResponseHandler
 
-	timeout          time.Duration
-	brokerAddress    string
-	forbiddenApiKeys map[int16]struct{}
-	buf              []byte // bufSize
+	inFlightSlots       chan<- struct{}
+	inFlightWaitTimeout time.Duration
+
+	timeout       time.Duration
+	brokerAddress string
+	apiKeyRules   *ApiKeyRules
+	buf           []byte // bufSize
 
 	localSasl     *LocalSasl
 	localSaslDone bool
 
 	producerAcks0Disabled bool
+
+	passthrough      *Passthrough
+	passthroughState *passthroughState
+	clientIDResolved bool
+	// the client certificate principal was checked for passthrough
+	tlsPrincipalResolved bool
+	// trusted client - policies are not enforced
+	bypassPolicies bool
+
+	// nil when no topic watermarks are configured
+	topicWatermarks *topicWatermarks
+
+	deprecation        *Deprecation
+	deprecationState   *deprecationState
+	deprecatedClientID bool
+
+	interceptor           *RequestInterceptor
+	interceptedConnection *interceptedConnection
+
+	pseudonymizer *Pseudonymizer
+
+	// nil when no topic policy is configured
+	topicPolicy      *TopicPolicy
+	topicPolicyState *topicPolicyState
+	// nil when no topics are validated
+	schemaValidation *SchemaValidation
+	// nil when no topics are encrypted
+	payloadEncryption *PayloadEncryption
+	// nil when the record batches are not transcoded
+	compressionTranscoding *CompressionTranscoding
+	// nil when the produce requests are not mirrored
+	mirror *Mirror
+	// nil when no faults are injected
+	chaos *Chaos
+	// nil when the connection is not a tenant
+	tenancy *tenantConnection
+	// nil when no request limits are configured
+	requestLimits          *RequestLimits
+	producePartitionErrors *producePartitionErrorsState
+	// disabled when no quotas are configured
+	quotas     *Quotas
+	quotaState *quotaState
+	// nil when brute-force protection is disabled
+	authLimiter *AuthLimiter
+	// nil when the request latency is not measured
+	requestLatency      *RequestLatency
+	requestLatencyState *requestLatencyState
+	// nil when the handshake timeout is disabled
+	handshakeDeadline *handshakeDeadline
+	// nil when the credential expiry is not enforced
+	credentialExpiry *credentialExpiry
+	// nil when the broker session does not expire
+	brokerReauth *brokerReauth
+	// SASL user authenticated by the proxy or the principal of the gateway token
+	principal string
+	// nil when the connection is not tracked
+	connection *trackedConnection
+	// created on first use
+	log *logrus.Entry
+}
+
+// logger returns the log entry of the requests with the connection id
+func (ctx *RequestsLoopContext) logger() *logrus.Entry {
+	if ctx.log == nil {
+		ctx.log = ctx.connection.log(logSubsystemRequests)
+	}
+	return ctx.log
 }
 
 // used by local authentication
@@ -214,10 +436,31 @@ func (p *processor) ResponsesLoop(dst DeadlineWriter, src DeadlineReader) (readE
 	ctx := &ResponsesLoopContext{
 		openRequestsChannel:        p.openRequestsChannel,
 		nextResponseHandlerChannel: p.nextResponseHandlerChannel,
+		inFlightSlots:              p.inFlightSlots,
 		netAddressMappingFunc:      p.netAddressMappingFunc,
+		maxApiVersions:             p.maxApiVersions,
+		passthroughState:           p.passthroughState,
 		timeout:                    p.readTimeout,
 		brokerAddress:              p.brokerAddress,
 		buf:                        make([]byte, p.responseBufferSize),
+		deprecation:                p.deprecation,
+		deprecationState:           p.deprecationState,
+		interceptor:                p.interceptor,
+		interceptedConnection:      p.interceptedConnection,
+		topicPolicy:                p.topicPolicy,
+		topicPolicyState:           p.topicPolicyState,
+		payloadEncryption:          p.payloadEncryption,
+		compressionTranscoding:     p.compressionTranscoding,
+		requestLimits:              p.requestLimits,
+		producePartitionErrors:     p.producePartitionErrors,
+		quotas:                     p.quotas,
+		quotaState:                 p.quotaState,
+		requestLatency:             p.requestLatency,
+		requestLatencyState:        p.requestLatencyState,
+		brokerReauth:               p.brokerReauth,
+		chaos:                      p.chaos,
+		tenancy:                    p.tenancy,
+		connection:                 p.connection,
 	}
 	return ctx.responsesLoop(dst, src)
 }
@@ -225,10 +468,58 @@ func (p *processor) ResponsesLoop(dst DeadlineWriter, src DeadlineReader) (readE
 type ResponsesLoopContext struct {
 	openRequestsChannel        <-chan protocol.RequestKeyVersion
 	nextResponseHandlerChannel <-chan ResponseHandler
+	inFlightSlots              <-chan struct{}
 	netAddressMappingFunc      config.NetAddressMappingFunc
 	timeout                    time.Duration
 	brokerAddress              string
 	buf                        []byte // bufSize
+
+	// empty when the api versions are not capped
+	maxApiVersions config.MaxApiVersions
+
+	// spliced connections of trusted clients
+	passthroughState *passthroughState
+
+	deprecation      *Deprecation
+	deprecationState *deprecationState
+
+	interceptor           *RequestInterceptor
+	interceptedConnection *interceptedConnection
+
+	// nil when no topic policy is configured
+	topicPolicy      *TopicPolicy
+	topicPolicyState *topicPolicyState
+	// nil when no topics are encrypted
+	payloadEncryption *PayloadEncryption
+	// nil when the record batches are not transcoded
+	compressionTranscoding *CompressionTranscoding
+	// nil when no request limits are configured
+	requestLimits          *RequestLimits
+	producePartitionErrors *producePartitionErrorsState
+	// disabled when no quotas are configured
+	quotas     *Quotas
+	quotaState *quotaState
+	// nil when the request latency is not measured
+	requestLatency      *RequestLatency
+	requestLatencyState *requestLatencyState
+	// nil when the broker session does not expire
+	brokerReauth *brokerReauth
+	// nil when no faults are injected
+	chaos *Chaos
+	// nil when the connection is not a tenant
+	tenancy *tenantConnection
+	// nil when the connection is not tracked
+	connection *trackedConnection
+	// created on first use
+	log *logrus.Entry
+}
+
+// logger returns the log entry of the responses with the connection id
+func (ctx *ResponsesLoopContext) logger() *logrus.Entry {
+	if ctx.log == nil {
+		ctx.log = ctx.connection.log(logSubsystemRequests)
+	}
+	return ctx.log
 }
 
 type ResponseHandler interface {
@@ -2,10 +2,10 @@ package proxy
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/grepplabs/kafka-proxy/proxy/protocol"
-	"github.com/sirupsen/logrus"
 	"io"
 	"strconv"
 	"time"
@@ -28,6 +28,15 @@ func (handler *DefaultRequestHandler) handleRequest(dst DeadlineWriter, src Dead
 		return true, err
 	}
 
+	if ctx.quotas.enabled() {
+		// the connection is muted until the throttle time of the last response elapsed
+		ctx.quotaState.wait()
+	}
+	if ctx.deprecation.enabled() {
+		// the connection is muted until the deprecation throttle time of the last response elapsed
+		ctx.deprecationState.wait()
+	}
+
 	keyVersionBuf := make([]byte, 8) // Size => int32 + ApiKey => int16 + ApiVersion => int16
 
 	if _, err = io.ReadFull(src, keyVersionBuf); err != nil {
@@ -38,7 +47,7 @@ func (handler *DefaultRequestHandler) handleRequest(dst DeadlineWriter, src Dead
 	if err = protocol.Decode(keyVersionBuf, requestKeyVersion); err != nil {
 		return true, err
 	}
-	logrus.Debugf("Kafka request key %v, version %v, length %v", requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion, requestKeyVersion.Length)
+	ctx.logger().Debugf("Kafka request key %v, version %v, length %v", requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion, requestKeyVersion.Length)
 
 	if requestKeyVersion.ApiKey < minRequestApiKey || requestKeyVersion.ApiKey > maxRequestApiKey {
 		return true, fmt.Errorf("api key %d is invalid", requestKeyVersion.ApiKey)
@@ -46,9 +55,43 @@ func (handler *DefaultRequestHandler) handleRequest(dst DeadlineWriter, src Dead
 
 	proxyRequestsTotal.WithLabelValues(ctx.brokerAddress, strconv.Itoa(int(requestKeyVersion.ApiKey)), strconv.Itoa(int(requestKeyVersion.ApiVersion))).Inc()
 	proxyRequestsBytes.WithLabelValues(ctx.brokerAddress).Add(float64(requestKeyVersion.Length + 4))
+	ctx.connection.addRequestBytes(requestKeyVersion.Length + 4)
+
+	var peekedBytes []byte
+	// locally handled SaslHandshake reads the whole request by itself
+	if ctx.deprecation.clientIDsEnabled() && !ctx.clientIDResolved && !(ctx.localSasl.enabled && requestKeyVersion.ApiKey == apiKeySaslHandshake) {
+		// the same client id is used for all requests sent over the connection
+		var clientID string
+		if peekedBytes, clientID, err = readRequestClientID(src, requestKeyVersion); err != nil {
+			return true, err
+		}
+		ctx.clientIDResolved = true
+		if ctx.deprecation.matchClientID(clientID) {
+			ctx.logger().Infof("Deprecated client id %s (%s)", ctx.pseudonymizer.clientID(clientID), ctx.brokerAddress)
+			ctx.deprecatedClientID = true
+		}
+	}
+	if ctx.passthrough.enabled() && !ctx.tlsPrincipalResolved {
+		ctx.tlsPrincipalResolved = true
+		// TLS handshake is completed after the first read
+		if principal := tlsPeerPrincipal(src); ctx.passthrough.matchPrincipal(principal) {
+			ctx.logger().Infof("Passthrough enabled for principal %s (%s)", ctx.pseudonymizer.principal(principal), ctx.brokerAddress)
+			ctx.bypassPolicies = true
+		}
+	}
 
-	if _, ok := ctx.forbiddenApiKeys[requestKeyVersion.ApiKey]; ok {
-		return true, fmt.Errorf("api key %d is forbidden", requestKeyVersion.ApiKey)
+	if ctx.deprecation.enabled() {
+		// read by the responses loop
+		ctx.deprecationState.update(ctx.bypassPolicies, ctx.deprecatedClientID)
+	}
+
+	if !ctx.bypassPolicies {
+		if err = ctx.apiKeyRules.check(requestKeyVersion.ApiKey, time.Now()); err != nil {
+			return true, err
+		}
+		if err = ctx.topicPolicy.checkRequest(requestKeyVersion.ApiKey); err != nil {
+			return true, err
+		}
 	}
 
 	if ctx.localSasl.enabled {
@@ -59,19 +102,34 @@ func (handler *DefaultRequestHandler) handleRequest(dst DeadlineWriter, src Dead
 		} else {
 			switch requestKeyVersion.ApiKey {
 			case apiKeySaslHandshake:
+				if ctx.chaos.enabled() {
+					ctx.chaos.delaySASL(ctx.brokerAddress)
+				}
+				var principal string
+				var expiry time.Time
 				switch requestKeyVersion.ApiVersion {
 				case 0:
-					if err = ctx.localSasl.receiveAndSendSASLAuthV0(src, keyVersionBuf); err != nil {
-						return true, err
-					}
+					principal, expiry, err = ctx.localSasl.receiveAndSendSASLAuthV0(src, keyVersionBuf)
 				case 1:
-					if err = ctx.localSasl.receiveAndSendSASLAuthV1(src, keyVersionBuf); err != nil {
-						return true, err
-					}
+					principal, expiry, err = ctx.localSasl.receiveAndSendSASLAuthV1(src, keyVersionBuf)
 				default:
 					return true, fmt.Errorf("only saslHandshake version 0 and 1 are supported, got version %d", requestKeyVersion.ApiVersion)
 				}
+				if err != nil {
+					if isAuthFailure(err) {
+						ctx.authLimiter.failed(remoteAddr(src), ctx.brokerAddress)
+					}
+					return true, err
+				}
+				ctx.handshakeDeadline.complete()
 				ctx.localSaslDone = true
+				ctx.principal = principal
+				ctx.connection.setPrincipal(principal)
+				ctx.credentialExpiry.set(credentialSASLToken, expiry)
+				if ctx.passthrough.matchPrincipal(principal) {
+					ctx.logger().Infof("Passthrough enabled for principal %s (%s)", ctx.pseudonymizer.principal(principal), ctx.brokerAddress)
+					ctx.bypassPolicies = true
+				}
 				if err = src.SetDeadline(time.Time{}); err != nil {
 					return false, err
 				}
@@ -85,16 +143,70 @@ func (handler *DefaultRequestHandler) handleRequest(dst DeadlineWriter, src Dead
 		}
 	}
 
-	mustReply, readBytes, err := handler.mustReply(requestKeyVersion, src, ctx)
+	// trusted clients are spliced, their requests are forwarded without being decoded or buffered
+	spliced := ctx.bypassPolicies
+	if spliced && ctx.passthroughState.splice() {
+		ctx.topicWatermarks.passthroughOpened()
+		if ctx.interceptor.enabled() {
+			ctx.interceptedConnection.setExempt()
+		}
+	}
+
+	if ctx.tenancy.enabled() {
+		// the principal is known after the local SASL authentication or the TLS handshake
+		if !ctx.tenancy.resolved && (!ctx.localSasl.enabled || ctx.localSaslDone) {
+			principal := ctx.principal
+			if principal == "" {
+				principal = tlsPeerPrincipal(src)
+			}
+			ctx.tenancy.resolve(principal, ctx.bypassPolicies)
+		}
+		if err = ctx.tenancy.checkRequest(requestKeyVersion); err != nil {
+			return true, err
+		}
+	}
+
+	if ctx.quotas.enabled() && !ctx.bypassPolicies {
+		// the principal is known after the local SASL authentication or the TLS handshake
+		if !ctx.quotas.resolved(ctx.quotaState) && (!ctx.localSasl.enabled || ctx.localSaslDone) {
+			principal := ctx.principal
+			if principal == "" {
+				principal = tlsPeerPrincipal(src)
+			}
+			ctx.quotas.resolve(ctx.quotaState, principal)
+		}
+		ctx.quotas.recordRequest(ctx.quotaState, requestKeyVersion.ApiKey, int(requestKeyVersion.Length)+4, time.Now())
+	}
+
+	var reader io.Reader = src
+	if len(peekedBytes) != 0 {
+		reader = io.MultiReader(bytes.NewReader(peekedBytes), src)
+	}
+	mustReply, readBytes, err := handler.mustReply(requestKeyVersion, reader, ctx)
 	if err != nil {
 		return true, err
 	}
+	if len(readBytes) < len(peekedBytes) {
+		readBytes = peekedBytes
+	}
+
+	// the re-authentication requests are sent before the client request
+	if err = ctx.brokerReauth.maybeReauthenticate(dst, ctx.openRequestsChannel); err != nil {
+		return false, err
+	}
 
 	// send inFlightRequest to channel before myCopyN to prevent race condition in proxyResponses
 	if mustReply {
+		if ctx.inFlightSlots != nil {
+			// backpressure: stop reading from the client until the broker answers pending requests
+			if err = acquireInFlightSlot(ctx.inFlightSlots, ctx.inFlightWaitTimeout, ctx.brokerAddress); err != nil {
+				return true, err
+			}
+		}
 		if err = sendRequestKeyVersion(ctx.openRequestsChannel, openRequestSendTimeout, requestKeyVersion); err != nil {
 			return true, err
 		}
+		proxyInFlightRequests.WithLabelValues(ctx.brokerAddress).Observe(float64(len(ctx.openRequestsChannel)))
 	}
 
 	requestDeadline := time.Now().Add(ctx.timeout)
@@ -107,6 +219,24 @@ func (handler *DefaultRequestHandler) handleRequest(dst DeadlineWriter, src Dead
 		return true, err
 	}
 
+	if !spliced {
+		if readBytes, err = handler.processRequestBody(src, ctx, requestKeyVersion, keyVersionBuf, readBytes); err != nil {
+			return true, err
+		}
+	} else if requestKeyVersion.ApiKey == apiKeyProduce && ctx.payloadEncryption.enabled() {
+		// the records of the spliced passthrough clients are encrypted as well
+		if readBytes, err = handler.encryptRequestBody(src, ctx, requestKeyVersion, keyVersionBuf, readBytes); err != nil {
+			return true, err
+		}
+	}
+	if mustReply && ctx.requestLatency.enabled() && !spliced {
+		// the request is recorded before it is sent, the response may be received before the write returns
+		if readBytes, err = ctx.requestLatency.readRequest(src, requestKeyVersion, readBytes); err != nil {
+			return true, err
+		}
+		ctx.requestLatency.requestSent(ctx.requestLatencyState, requestKeyVersion, readBytes, time.Now())
+	}
+
 	// write - send to broker
 	if _, err = dst.Write(keyVersionBuf); err != nil {
 		return false, err
@@ -118,7 +248,13 @@ func (handler *DefaultRequestHandler) handleRequest(dst DeadlineWriter, src Dead
 		}
 	}
 	// 4 bytes were written as keyVersionBuf (ApiKey, ApiVersion)
-	if readErr, err = myCopyN(dst, src, int64(requestKeyVersion.Length-int32(4+len(readBytes))), ctx.buf); err != nil {
+	remaining := int64(requestKeyVersion.Length - int32(4+len(readBytes)))
+	if requestKeyVersion.ApiKey == apiKeyProduce && ctx.topicWatermarks != nil && !spliced {
+		// the records are counted per topic while the produce request is forwarded
+		if readErr, err = ctx.topicWatermarks.copyProduceRequest(dst, src, requestKeyVersion.ApiVersion, readBytes, remaining, ctx.buf, ctx.logger()); err != nil {
+			return readErr, err
+		}
+	} else if readErr, err = myCopyN(dst, src, remaining, ctx.buf); err != nil {
 		return readErr, err
 	}
 	if requestKeyVersion.ApiKey == apiKeySaslHandshake {
@@ -133,6 +269,117 @@ func (handler *DefaultRequestHandler) handleRequest(dst DeadlineWriter, src Dead
 	}
 }
 
+// processRequestBody applies the policies and transformations to the request body following the api key and version. The body
+// read so far is passed in readBytes, the returned body is forwarded to the broker.
+func (handler *DefaultRequestHandler) processRequestBody(src io.Reader, ctx *RequestsLoopContext, requestKeyVersion *protocol.RequestKeyVersion, keyVersionBuf []byte, readBytes []byte) ([]byte, error) {
+	var err error
+	if ctx.requestLimits.enabled() {
+		// limits are enforced first to avoid buffering of large requests
+		if readBytes, err = ctx.requestLimits.applyRequest(src, requestKeyVersion, keyVersionBuf, readBytes, ctx.producePartitionErrors, ctx.brokerAddress, ctx.logger()); err != nil {
+			return nil, err
+		}
+	}
+	if prefix := ctx.tenancy.getPrefix(); prefix != "" && protocol.PrefixedRequestNames(requestKeyVersion.ApiKey) {
+		// the whole request is buffered to prefix the names, the following features see the broker names
+		if readBytes, err = readRemainingRequest(src, requestKeyVersion, readBytes); err != nil {
+			return nil, err
+		}
+		if readBytes, err = protocol.PrefixRequestNames(requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion, readBytes, prefix); err != nil {
+			return nil, err
+		}
+		setRequestLength(requestKeyVersion, keyVersionBuf, readBytes)
+	}
+	if ctx.interceptor.enabled() {
+		// the whole request is buffered to decode the topics
+		if readBytes, err = readRemainingRequest(src, requestKeyVersion, readBytes); err != nil {
+			return nil, err
+		}
+		principal := ctx.principal
+		if principal == "" {
+			principal = tlsPeerPrincipal(src)
+		}
+		if err = ctx.interceptor.interceptRequest(ctx.brokerAddress, principal, ctx.interceptedConnection, requestKeyVersion, readBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	if (requestKeyVersion.ApiKey == apiKeyCreateTopics || requestKeyVersion.ApiKey == apiKeyDeleteTopics) && ctx.topicPolicy.enabled() {
+		// the whole request is buffered to remove the topics violating the policy
+		if readBytes, err = readRemainingRequest(src, requestKeyVersion, readBytes); err != nil {
+			return nil, err
+		}
+		if requestKeyVersion.ApiKey == apiKeyCreateTopics {
+			readBytes, err = ctx.topicPolicy.applyCreateTopics(requestKeyVersion.ApiVersion, readBytes, ctx.topicPolicyState)
+		} else {
+			readBytes, err = ctx.topicPolicy.applyDeleteTopics(requestKeyVersion.ApiVersion, readBytes, ctx.topicPolicyState)
+		}
+		if err != nil {
+			return nil, err
+		}
+		setRequestLength(requestKeyVersion, keyVersionBuf, readBytes)
+	}
+	if requestKeyVersion.ApiKey == apiKeyProduce && ctx.schemaValidation.enabled() {
+		// the whole produce request is buffered to validate the record values
+		if readBytes, err = readRemainingRequest(src, requestKeyVersion, readBytes); err != nil {
+			return nil, err
+		}
+		if readBytes, err = ctx.schemaValidation.validateProduceRequest(requestKeyVersion.ApiVersion, readBytes, ctx.producePartitionErrors, ctx.logger()); err != nil {
+			return nil, err
+		}
+		setRequestLength(requestKeyVersion, keyVersionBuf, readBytes)
+	}
+	if requestKeyVersion.ApiKey == apiKeyProduce && ctx.payloadEncryption.enabled() {
+		if readBytes, err = handler.encryptRequestBody(src, ctx, requestKeyVersion, keyVersionBuf, readBytes); err != nil {
+			return nil, err
+		}
+	}
+	if requestKeyVersion.ApiKey == apiKeyProduce && ctx.compressionTranscoding.enabled() {
+		// the whole produce request is buffered to recompress the record batches after the encryption
+		if readBytes, err = readRemainingRequest(src, requestKeyVersion, readBytes); err != nil {
+			return nil, err
+		}
+		transcoded, err := ctx.compressionTranscoding.transcodeProduceRequest(requestKeyVersion.ApiVersion, readBytes, ctx.brokerAddress)
+		if err != nil {
+			// the broker validates the record batches of the unchanged request
+			ctx.logger().Warnf("Produce request v%d could not be transcoded (%s): %v", requestKeyVersion.ApiVersion, ctx.brokerAddress, err)
+		} else {
+			readBytes = transcoded
+			setRequestLength(requestKeyVersion, keyVersionBuf, readBytes)
+		}
+	}
+
+	if requestKeyVersion.ApiKey == apiKeyProduce && ctx.mirror.enabled() {
+		// the whole produce request is buffered to be mirrored as forwarded to the broker
+		if readBytes, err = readRemainingRequest(src, requestKeyVersion, readBytes); err != nil {
+			return nil, err
+		}
+		ctx.mirror.enqueue(requestKeyVersion.ApiVersion, readBytes)
+	}
+	return readBytes, nil
+}
+
+// setRequestLength updates the request length after the request body following the api key and version was modified
+func setRequestLength(requestKeyVersion *protocol.RequestKeyVersion, keyVersionBuf []byte, body []byte) {
+	// 4 bytes of keyVersionBuf (ApiKey, ApiVersion)
+	requestKeyVersion.Length = int32(4 + len(body))
+	binary.BigEndian.PutUint32(keyVersionBuf, uint32(requestKeyVersion.Length))
+}
+
+// readRemainingRequest reads the rest of the request following the already read bytes
+func readRemainingRequest(src io.Reader, requestKeyVersion *protocol.RequestKeyVersion, readBytes []byte) ([]byte, error) {
+	// 4 bytes were read as keyVersionBuf (ApiKey, ApiVersion)
+	remaining := int(requestKeyVersion.Length) - 4 - len(readBytes)
+	if remaining <= 0 {
+		return readBytes, nil
+	}
+	body := make([]byte, len(readBytes)+remaining)
+	copy(body, readBytes)
+	if _, err := io.ReadFull(src, body[len(readBytes):]); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
 func (handler *DefaultRequestHandler) mustReply(requestKeyVersion *protocol.RequestKeyVersion, src io.Reader, ctx *RequestsLoopContext) (bool, []byte, error) {
 	if requestKeyVersion.ApiKey == apiKeyProduce {
 		if ctx.producerAcks0Disabled {
@@ -203,8 +450,34 @@ func (handler *DefaultResponseHandler) handleResponse(dst DeadlineWriter, src De
 	if err != nil {
 		return true, err
 	}
+	if requestKeyVersion.Length == brokerReauthRequestLength {
+		// the response to the re-authentication is not forwarded, the handler continues with the next response
+		if err = ctx.brokerReauth.handleResponse(src, requestKeyVersion, &responseHeader); err != nil {
+			return true, err
+		}
+		return handler.handleResponse(dst, src, ctx)
+	}
+	releaseInFlightSlot(ctx.inFlightSlots)
+	if ctx.requestLatency.enabled() {
+		ctx.requestLatency.responseReceived(ctx.requestLatencyState, ctx.brokerAddress, &responseHeader, time.Now(), ctx.logger())
+	}
+	if ctx.interceptor.responsesEnabled() {
+		if err = ctx.interceptor.interceptResponse(ctx.brokerAddress, ctx.interceptedConnection, requestKeyVersion, responseHeader.CorrelationID); err != nil {
+			return false, err
+		}
+	}
 	proxyResponsesBytes.WithLabelValues(ctx.brokerAddress).Add(float64(responseHeader.Length + 4))
-	logrus.Debugf("Kafka response key %v, version %v, length %v", requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion, responseHeader.Length)
+	ctx.connection.addResponseBytes(responseHeader.Length + 4)
+	ctx.logger().Debugf("Kafka response key %v, version %v, length %v", requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion, responseHeader.Length)
+
+	// responses to the spliced passthrough clients are only modified to rewrite the broker addresses and to decrypt the records
+	spliced := ctx.passthroughState.isSpliced()
+	if ctx.chaos.enabled() && !spliced {
+		ctx.chaos.delay(ctx.brokerAddress, requestKeyVersion.ApiKey)
+		if err = ctx.chaos.reset(ctx.brokerAddress, dst); err != nil {
+			return false, err
+		}
+	}
 
 	responseDeadline := time.Now().Add(ctx.timeout)
 	err = dst.SetWriteDeadline(responseDeadline)
@@ -215,6 +488,16 @@ func (handler *DefaultResponseHandler) handleResponse(dst DeadlineWriter, src De
 	if err != nil {
 		return true, err
 	}
+	if ctx.chaos.enabled() && !spliced && ctx.chaos.truncate(ctx.brokerAddress) {
+		// the client receives the header and a half of the response before the connection is closed
+		if _, err = dst.Write(responseHeaderBuf); err != nil {
+			return false, err
+		}
+		if readErr, err = myCopyN(dst, src, int64(responseHeader.Length-4)/2, ctx.buf); err != nil {
+			return readErr, err
+		}
+		return false, errChaosTruncate
+	}
 	responseHeaderTaggedFields, err := protocol.NewResponseHeaderTaggedFields(requestKeyVersion)
 	if err != nil {
 		return true, err
@@ -225,11 +508,49 @@ func (handler *DefaultResponseHandler) handleResponse(dst DeadlineWriter, src De
 	}
 	readResponsesHeaderLength := int32(4 + len(unknownTaggedFields)) // 4 = Length + CorrelationID
 
+	throttlePosition := throttleTimeNone
+	now := time.Now()
+	deprecationThrottleMs := ctx.deprecation.throttleTime(ctx.deprecationState, requestKeyVersion, now)
+	quotaThrottleMs, quota := ctx.quotas.throttleTime(ctx.quotaState, requestKeyVersion.ApiKey, int(responseHeader.Length)+4, now)
+	throttleTimeMs := deprecationThrottleMs
+	if quotaThrottleMs > throttleTimeMs {
+		throttleTimeMs = quotaThrottleMs
+	}
+	if throttleTimeMs > 0 && responseHeader.Length-readResponsesHeaderLength >= 4 {
+		throttlePosition = throttleTimePosition(requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion)
+	}
+	if throttlePosition != throttleTimeNone && deprecationThrottleMs > 0 {
+		proxyDeprecationThrottledResponsesTotal.WithLabelValues(ctx.brokerAddress, strconv.Itoa(int(requestKeyVersion.ApiKey)), strconv.Itoa(int(requestKeyVersion.ApiVersion))).Inc()
+	}
+	if quotaThrottleMs > 0 {
+		proxyQuotaThrottledResponsesTotal.WithLabelValues(ctx.brokerAddress, quota).Inc()
+	}
+
 	responseModifier, err := protocol.GetResponseModifier(requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion, ctx.netAddressMappingFunc)
 	if err != nil {
 		return true, err
 	}
+	var modifyResponse func([]byte) ([]byte, error)
 	if responseModifier != nil {
+		modifyResponse = responseModifier.Apply
+	} else if !spliced || ctx.payloadEncryption.enabled() {
+		modifyResponse = handler.responseBodyModifier(ctx, requestKeyVersion, responseHeader.CorrelationID, spliced)
+	}
+	if prefix := ctx.tenancy.getPrefix(); prefix != "" && protocol.PrefixedResponseNames(requestKeyVersion.ApiKey) {
+		// the prefix is removed after the other modifications, e.g. the decryption with the topic keys
+		apiKey, apiVersion := requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion
+		modify := modifyResponse
+		modifyResponse = func(resp []byte) ([]byte, error) {
+			if modify != nil {
+				var err error
+				if resp, err = modify(resp); err != nil {
+					return nil, err
+				}
+			}
+			return protocol.UnprefixResponseNames(apiKey, apiVersion, resp, prefix)
+		}
+	}
+	if modifyResponse != nil {
 		if responseHeader.Length > protocol.MaxResponseSize {
 			return true, protocol.PacketDecodingError{Info: fmt.Sprintf("message of length %d too large", responseHeader.Length)}
 		}
@@ -237,10 +558,18 @@ func (handler *DefaultResponseHandler) handleResponse(dst DeadlineWriter, src De
 		if _, err = io.ReadFull(src, resp); err != nil {
 			return true, err
 		}
-		newResponseBuf, err := responseModifier.Apply(resp)
+		newResponseBuf, err := modifyResponse(resp)
 		if err != nil {
 			return true, err
 		}
+		if len(newResponseBuf) >= 4 {
+			switch throttlePosition {
+			case throttleTimeFirst:
+				injectThrottleTime(newResponseBuf, throttleTimeMs)
+			case throttleTimeLast:
+				injectThrottleTime(newResponseBuf[len(newResponseBuf)-4:], throttleTimeMs)
+			}
+		}
 		// add 4 bytes (CorrelationId) to the length
 		newHeaderBuf, err := protocol.Encode(&protocol.ResponseHeader{Length: int32(len(newResponseBuf) + int(readResponsesHeaderLength)), CorrelationID: responseHeader.CorrelationID})
 		if err != nil {
@@ -264,13 +593,81 @@ func (handler *DefaultResponseHandler) handleResponse(dst DeadlineWriter, src De
 			return false, err
 		}
 		// 4 bytes were written as responseHeaderBuf (CorrelationId) + tagged fields
-		if readErr, err = myCopyN(dst, src, int64(responseHeader.Length-readResponsesHeaderLength), ctx.buf); err != nil {
+		if throttlePosition != throttleTimeNone {
+			if readErr, err = copyThrottledResponse(dst, src, int64(responseHeader.Length-readResponsesHeaderLength), throttlePosition, throttleTimeMs, ctx.buf); err != nil {
+				return readErr, err
+			}
+		} else if readErr, err = myCopyN(dst, src, int64(responseHeader.Length-readResponsesHeaderLength), ctx.buf); err != nil {
 			return readErr, err
 		}
 	}
 	return false, nil // continue nextResponse
 }
 
+// responseBodyModifier returns the modification of the response body required by the policies and transformations applied
+// to the request or nil. The records of the responses to the spliced passthrough clients are decrypted but not transcoded.
+func (handler *DefaultResponseHandler) responseBodyModifier(ctx *ResponsesLoopContext, requestKeyVersion *protocol.RequestKeyVersion, correlationID int32, spliced bool) func([]byte) ([]byte, error) {
+	if (requestKeyVersion.ApiKey == apiKeyCreateTopics || requestKeyVersion.ApiKey == apiKeyDeleteTopics) && ctx.topicPolicy.enabled() {
+		// topics removed from the request by the topic policy
+		if topicErrors := ctx.topicPolicyState.take(correlationID); len(topicErrors) != 0 {
+			apiKey, apiVersion := requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion
+			return func(resp []byte) ([]byte, error) {
+				return protocol.AddTopicErrors(apiKey, apiVersion, resp, topicErrors)
+			}
+		}
+	} else if requestKeyVersion.ApiKey == apiKeyProduce {
+		// partitions removed from the request by the request limits, the schema validation or the payload encryption
+		if partitionErrors := ctx.producePartitionErrors.take(correlationID); len(partitionErrors) != 0 {
+			apiVersion := requestKeyVersion.ApiVersion
+			return func(resp []byte) ([]byte, error) {
+				return protocol.AddProducePartitionErrors(apiVersion, resp, partitionErrors)
+			}
+		}
+	} else if requestKeyVersion.ApiKey == apiKeyApiApiVersions && len(ctx.maxApiVersions) != 0 {
+		apiVersion := requestKeyVersion.ApiVersion
+		return func(resp []byte) ([]byte, error) {
+			return protocol.CapApiVersions(apiVersion, resp, ctx.maxApiVersions)
+		}
+	} else if requestKeyVersion.ApiKey == apiKeyFetch && (ctx.payloadEncryption.enabled() || ctx.compressionTranscoding.enabled() && !spliced) {
+		apiVersion := requestKeyVersion.ApiVersion
+		return func(resp []byte) ([]byte, error) {
+			if ctx.compressionTranscoding.enabled() && !spliced {
+				// the record batches are recompressed before the decryption
+				newResponseBuf, err := ctx.compressionTranscoding.transcodeFetchResponse(apiVersion, resp, ctx.brokerAddress)
+				if err != nil {
+					ctx.logger().Warnf("Fetch response v%d could not be transcoded (%s): %v", apiVersion, ctx.brokerAddress, err)
+				} else {
+					resp = newResponseBuf
+				}
+			}
+			if !ctx.payloadEncryption.enabled() {
+				return resp, nil
+			}
+			newResponseBuf, err := ctx.payloadEncryption.decryptFetchResponse(apiVersion, resp)
+			if err != nil {
+				// the encrypted records are never passed to the client
+				return nil, fmt.Errorf("fetch response v%d could not be decrypted: %v", apiVersion, err)
+			}
+			return newResponseBuf, nil
+		}
+	}
+	return nil
+}
+
+// encryptRequestBody encrypts the record values of the produce request body
+func (handler *DefaultRequestHandler) encryptRequestBody(src io.Reader, ctx *RequestsLoopContext, requestKeyVersion *protocol.RequestKeyVersion, keyVersionBuf []byte, readBytes []byte) ([]byte, error) {
+	var err error
+	// the whole produce request is buffered to encrypt the record values
+	if readBytes, err = readRemainingRequest(src, requestKeyVersion, readBytes); err != nil {
+		return nil, err
+	}
+	if readBytes, err = ctx.payloadEncryption.encryptProduceRequest(requestKeyVersion.ApiVersion, readBytes, ctx.producePartitionErrors, ctx.logger()); err != nil {
+		return nil, err
+	}
+	setRequestLength(requestKeyVersion, keyVersionBuf, readBytes)
+	return readBytes, nil
+}
+
 func sendRequestKeyVersion(openRequestsChannel chan<- protocol.RequestKeyVersion, timeout time.Duration, request *protocol.RequestKeyVersion) error {
 	select {
 	case openRequestsChannel <- *request:
@@ -288,6 +685,34 @@ func sendRequestKeyVersion(openRequestsChannel chan<- protocol.RequestKeyVersion
 	return nil
 }
 
+func acquireInFlightSlot(inFlightSlots chan<- struct{}, timeout time.Duration, brokerAddress string) error {
+	select {
+	case inFlightSlots <- struct{}{}:
+	default:
+		start := time.Now()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case inFlightSlots <- struct{}{}:
+			proxyInFlightWaitSeconds.WithLabelValues(brokerAddress).Observe(time.Since(start).Seconds())
+		case <-timer.C:
+			return fmt.Errorf("max in-flight requests %d reached and no response received within %v", cap(inFlightSlots), timeout)
+		}
+	}
+	return nil
+}
+
+func releaseInFlightSlot(inFlightSlots <-chan struct{}) {
+	if inFlightSlots == nil {
+		return
+	}
+	select {
+	case <-inFlightSlots:
+	default:
+	}
+}
+
 func receiveRequestKeyVersion(openRequestsChannel <-chan protocol.RequestKeyVersion, timeout time.Duration) (*protocol.RequestKeyVersion, error) {
 	var request protocol.RequestKeyVersion
 	select {
@@ -2,9 +2,13 @@ package proxy
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/encryption"
 	"github.com/grepplabs/kafka-proxy/proxy/protocol"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
@@ -129,6 +133,36 @@ func TestHandleRequest(t *testing.T) {
 	}
 }
 
+func TestHandleProduceRequestWithTopicWatermarks(t *testing.T) {
+	a := assert.New(t)
+
+	input, err := hex.DecodeString("000000c2000000030000000500144b61666b614578616d706c6550726f6475636572ffff00010000753000000001000f746573742d6e6f2d6865616465727300000001000000000000007b00000000000000000000006fffffffff0231f7fe0e000000000000000001734a66bef6000001734a66bef6ffffffffffffffffffffffffffff000000017a00000010000001734a66be5f2e48656c6c6f204d6f6d203135393436383131313432303702146865616465722d6b6579186865616465722d76616c7565")
+	a.Nil(err)
+
+	output := bytes.NewBuffer(make([]byte, 0))
+	readBuffer := bytes.NewBuffer(input)
+	src := &TestDeadlineReaderWriter{
+		reader: readBuffer,
+		writer: bytes.NewBuffer(make([]byte, 0)),
+	}
+	ctx := &RequestsLoopContext{
+		openRequestsChannel:        make(chan protocol.RequestKeyVersion, 1),
+		nextRequestHandlerChannel:  make(chan RequestHandler, 1),
+		nextResponseHandlerChannel: make(chan ResponseHandler, 1),
+		timeout:                    1 * time.Second,
+		buf:                        make([]byte, defaultRequestBufferSize),
+		localSasl:                  &LocalSasl{},
+		topicWatermarks:            newTopicWatermarks(config.TopicWatermarks{{Topic: "test-no-headers", Window: time.Minute, MaxRecords: 100}}, time.Now()),
+	}
+	_, err = defaultRequestHandler.handleRequest(&TestDeadlineWriter{Buffer: output}, src, ctx)
+	a.Nil(err)
+	a.Equal(input, output.Bytes())
+	a.Empty(readBuffer.Bytes())
+
+	records, _ := ctx.topicWatermarks.topics["test-no-headers"].counter.sum(time.Now())
+	a.EqualValues(1, records)
+}
+
 func TestHandleResponse(t *testing.T) {
 	netAddressMappingFunc := func(brokerHost string, brokerPort int32) (listenerHost string, listenerPort int32, err error) {
 		if brokerHost == "localhost" {
@@ -274,6 +308,30 @@ func TestHandleResponse(t *testing.T) {
 	}
 }
 
+func TestHandleResponseMaxApiVersions(t *testing.T) {
+	a := assert.New(t)
+
+	// ApiVersions v0: error code, [api key, min version, max version]
+	input, err := hex.DecodeString("0000001600000007" + "0000" + "00000002" + "000000000008" + "00010004000d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	openRequestsChannel := make(chan protocol.RequestKeyVersion, 1)
+	openRequestsChannel <- protocol.RequestKeyVersion{ApiKey: apiKeyApiApiVersions, ApiVersion: 0}
+	output := bytes.NewBuffer(make([]byte, 0))
+	ctx := &ResponsesLoopContext{openRequestsChannel: openRequestsChannel, timeout: 1 * time.Second, buf: make([]byte, defaultResponseBufferSize),
+		maxApiVersions: config.MaxApiVersions{0: 7, 1: 11}}
+
+	_, err = defaultResponseHandler.handleResponse(&TestDeadlineWriter{Buffer: output}, &TestDeadlineReader{Buffer: bytes.NewBuffer(input)}, ctx)
+	a.Nil(err)
+
+	expected, err := hex.DecodeString("0000001600000007" + "0000" + "00000002" + "000000000007" + "00010004000b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Equal(expected, output.Bytes())
+}
+
 type TestDeadlineWriter struct {
 	*bytes.Buffer
 }
@@ -313,3 +371,193 @@ func (w *TestDeadlineReaderWriter) Read(p []byte) (n int, err error) {
 func (w *TestDeadlineReaderWriter) Write(p []byte) (n int, err error) {
 	return w.reader.Write(p)
 }
+
+func TestAcquireInFlightSlot(t *testing.T) {
+	a := assert.New(t)
+
+	inFlightSlots := make(chan struct{}, 2)
+	a.Nil(acquireInFlightSlot(inFlightSlots, 10*time.Millisecond, "broker"))
+	a.Nil(acquireInFlightSlot(inFlightSlots, 10*time.Millisecond, "broker"))
+
+	err := acquireInFlightSlot(inFlightSlots, 10*time.Millisecond, "broker")
+	a.EqualError(err, "max in-flight requests 2 reached and no response received within 10ms")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		releaseInFlightSlot(inFlightSlots)
+	}()
+	a.Nil(acquireInFlightSlot(inFlightSlots, 1*time.Second, "broker"))
+	a.Len(inFlightSlots, 2)
+
+	releaseInFlightSlot(nil)
+}
+
+func TestHandleRequestPassthrough(t *testing.T) {
+	// ApiVersions v3, kafka-client 2.5.0 - client id KafkaExampleProducer
+	input, err := hex.DecodeString("00000038001200030000000000144b61666b614578616d706c6550726f647563657200126170616368652d6b61666b612d6a61766106322e352e3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := []struct {
+		name           string
+		bypassPolicies bool
+	}{
+		// e.g. the principal of the gateway token
+		{name: "trusted principal", bypassPolicies: true},
+		// client ids are chosen by the clients and never trusted
+		{name: "untrusted client", bypassPolicies: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := assert.New(t)
+			output := bytes.NewBuffer(make([]byte, 0))
+			dst := &TestDeadlineWriter{Buffer: output}
+			src := &TestDeadlineReaderWriter{
+				reader: bytes.NewBuffer(input),
+				writer: bytes.NewBuffer(make([]byte, 0)),
+			}
+			ctx := &RequestsLoopContext{
+				openRequestsChannel:        make(chan protocol.RequestKeyVersion, 1),
+				nextRequestHandlerChannel:  make(chan RequestHandler, 1),
+				nextResponseHandlerChannel: make(chan ResponseHandler, 1),
+				timeout:                    1 * time.Second,
+				buf:                        make([]byte, defaultRequestBufferSize),
+				localSasl:                  &LocalSasl{},
+				passthrough:                NewPassthrough([]string{"KafkaExampleProducer"}),
+				passthroughState:           &passthroughState{},
+				bypassPolicies:             tc.bypassPolicies,
+				// the request exceeds the maximum request size
+				requestLimits: NewRequestLimits(16, 0),
+			}
+			_, err := defaultRequestHandler.handleRequest(dst, src, ctx)
+			if tc.bypassPolicies {
+				// the connection is spliced and the request forwarded without the limits
+				a.Nil(err)
+				a.Equal(input, output.Bytes())
+			} else {
+				a.EqualError(err, "request of length 56 exceeds the maximum request size 16")
+			}
+			a.True(ctx.tlsPrincipalResolved)
+			a.Equal(tc.bypassPolicies, ctx.bypassPolicies)
+			a.Equal(tc.bypassPolicies, ctx.passthroughState.isSpliced())
+		})
+	}
+}
+
+func TestHandleRequestPassthroughEncryption(t *testing.T) {
+	a := assert.New(t)
+
+	clientID := "producer"
+	body, err := protocol.Encode(&protocol.ProduceRequest{
+		Version:       7,
+		CorrelationID: 1,
+		ClientID:      &clientID,
+		Acks:          -1,
+		Timeout:       1000,
+		TopicData: []protocol.ProduceTopicData{
+			{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: uncompressedRecordBatch("secret")}}},
+		},
+	})
+	a.Nil(err)
+	// length, api key and version
+	input := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(input, uint32(len(body)+4))
+	binary.BigEndian.PutUint16(input[4:], uint16(apiKeyProduce))
+	binary.BigEndian.PutUint16(input[6:], 7)
+	input = append(input, body...)
+
+	output := bytes.NewBuffer(make([]byte, 0))
+	src := &TestDeadlineReaderWriter{
+		reader: bytes.NewBuffer(input),
+		writer: bytes.NewBuffer(make([]byte, 0)),
+	}
+	ctx := &RequestsLoopContext{
+		openRequestsChannel:        make(chan protocol.RequestKeyVersion, 1),
+		nextRequestHandlerChannel:  make(chan RequestHandler, 1),
+		nextResponseHandlerChannel: make(chan ResponseHandler, 1),
+		timeout:                    1 * time.Second,
+		buf:                        make([]byte, defaultRequestBufferSize),
+		localSasl:                  &LocalSasl{},
+		passthroughState:           &passthroughState{},
+		bypassPolicies:             true,
+		payloadEncryption:          newTestPayloadEncryption(t, config.TopicKeys{"orders": "orders-key"}),
+	}
+	_, err = defaultRequestHandler.handleRequest(&TestDeadlineWriter{Buffer: output}, src, ctx)
+	a.Nil(err)
+	a.True(ctx.passthroughState.isSpliced())
+
+	// the records of the spliced connection are encrypted
+	request := &protocol.ProduceRequest{Version: 7}
+	a.Nil(protocol.Decode(output.Bytes()[8:], request))
+	values := recordBatchValues(t, request.TopicData[0].PartitionData[0].Records)
+	a.Len(values, 1)
+	a.True(encryption.IsEncrypted([]byte(values[0])))
+}
+
+func TestHandleResponsePassthroughDecryption(t *testing.T) {
+	payloadEncryption := newTestPayloadEncryption(t, config.TopicKeys{"orders": "orders-key"})
+	clientID := "producer"
+	body, err := protocol.Encode(&protocol.ProduceRequest{
+		Version:       7,
+		CorrelationID: 1,
+		ClientID:      &clientID,
+		Acks:          -1,
+		Timeout:       1000,
+		TopicData: []protocol.ProduceTopicData{
+			{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: uncompressedRecordBatch("secret")}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err = payloadEncryption.encryptProduceRequest(7, body, &producePartitionErrorsState{}, logrus.NewEntry(logrus.New())); err != nil {
+		t.Fatal(err)
+	}
+	request := &protocol.ProduceRequest{Version: 7}
+	if err = protocol.Decode(body, request); err != nil {
+		t.Fatal(err)
+	}
+	body, err = protocol.Encode(&protocol.FetchResponse{
+		Version: 11,
+		Responses: []protocol.FetchTopicResponse{
+			{Topic: "orders", Partitions: []protocol.FetchPartitionResponse{{Partition: 0, HighWatermark: 1, Records: request.TopicData[0].PartitionData[0].Records}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := []struct {
+		name string
+		body []byte
+		err  string
+	}{
+		{name: "decrypted", body: body},
+		// the encrypted records are never passed to the client
+		{name: "undecodable", body: body[:len(body)-1], err: "fetch response v11 could not be decrypted"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := assert.New(t)
+			input, err := protocol.Encode(&protocol.ResponseHeader{Length: int32(len(tc.body) + 4), CorrelationID: 1})
+			a.Nil(err)
+			input = append(input, tc.body...)
+
+			openRequestsChannel := make(chan protocol.RequestKeyVersion, 1)
+			openRequestsChannel <- protocol.RequestKeyVersion{ApiKey: apiKeyFetch, ApiVersion: 11}
+			output := bytes.NewBuffer(make([]byte, 0))
+			ctx := &ResponsesLoopContext{openRequestsChannel: openRequestsChannel, timeout: 1 * time.Second, buf: make([]byte, defaultResponseBufferSize),
+				passthroughState: &passthroughState{spliced: 1}, payloadEncryption: payloadEncryption}
+
+			_, err = defaultResponseHandler.handleResponse(&TestDeadlineWriter{Buffer: output}, &TestDeadlineReader{Buffer: bytes.NewBuffer(input)}, ctx)
+			if tc.err != "" {
+				a.Error(err)
+				a.Contains(err.Error(), tc.err)
+				return
+			}
+			a.Nil(err)
+			response := &protocol.FetchResponse{Version: 11}
+			a.Nil(protocol.Decode(output.Bytes()[8:], response))
+			a.Equal([]string{"secret"}, recordBatchValues(t, response.Responses[0].Partitions[0].Records))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+)
+
+// producePartitionErrorsState is shared by the requests and responses loops of a connection
+type producePartitionErrorsState struct {
+	mu sync.Mutex
+	// correlation id to the errors of the partitions removed from the produce request
+	partitionErrors map[int32][]protocol.ProducePartitionError
+}
+
+// put adds the errors of the partitions removed from the produce request, the partitions may be removed by several policies
+func (s *producePartitionErrorsState) put(correlationID int32, partitionErrors []protocol.ProducePartitionError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.partitionErrors == nil {
+		s.partitionErrors = make(map[int32][]protocol.ProducePartitionError)
+	}
+	s.partitionErrors[correlationID] = append(s.partitionErrors[correlationID], partitionErrors...)
+}
+
+func (s *producePartitionErrorsState) take(correlationID int32) []protocol.ProducePartitionError {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	partitionErrors := s.partitionErrors[correlationID]
+	delete(s.partitionErrors, correlationID)
+	return partitionErrors
+}
+
+// rejectProducePartitions returns the body of the produce request without the rejected partitions. The broker answers
+// the request without them and their errors are added to the response, acks=0 requests are not answered.
+func rejectProducePartitions(request *protocol.ProduceRequest, partitionErrors []protocol.ProducePartitionError, state *producePartitionErrorsState) ([]byte, error) {
+	rejected := make(map[string]map[int32]bool)
+	for _, partitionError := range partitionErrors {
+		if rejected[partitionError.Topic] == nil {
+			rejected[partitionError.Topic] = make(map[int32]bool)
+		}
+		rejected[partitionError.Topic][partitionError.Partition] = true
+	}
+	allowed := make([]protocol.ProduceTopicData, 0, len(request.TopicData))
+	for _, topicData := range request.TopicData {
+		partitions := make([]protocol.ProducePartitionData, 0, len(topicData.PartitionData))
+		for _, partitionData := range topicData.PartitionData {
+			if !rejected[topicData.Topic][partitionData.Partition] {
+				partitions = append(partitions, partitionData)
+			}
+		}
+		if len(partitions) != 0 {
+			allowed = append(allowed, protocol.ProduceTopicData{Topic: topicData.Topic, PartitionData: partitions})
+		}
+	}
+	request.TopicData = allowed
+	body, err := protocol.Encode(request)
+	if err != nil {
+		return nil, err
+	}
+	if request.Acks != 0 {
+		state.put(request.CorrelationID, partitionErrors)
+	}
+	return body, nil
+}
@@ -0,0 +1,95 @@
+package protocol
+
+import "fmt"
+
+// cappedApiVersionsResponse caps the max versions of the api keys of an api versions response body
+type cappedApiVersionsResponse struct {
+	version     int16
+	maxVersions map[int16]int16
+	body        []byte
+}
+
+type apiVersionsEntry struct {
+	apiKey       int16
+	minVersion   int16
+	maxVersion   int16
+	taggedFields TaggedFields
+}
+
+func (r *cappedApiVersionsResponse) encode(pe packetEncoder) (err error) {
+	rd := &realDecoder{raw: r.body}
+	errorCode, err := rd.getInt16()
+	if err != nil {
+		return err
+	}
+	pe.putInt16(errorCode)
+	if errorCode != 0 {
+		// error responses to unsupported versions are encoded as v0 by the broker
+		rest, err := rd.getRawBytes(rd.remaining())
+		if err != nil {
+			return err
+		}
+		return pe.putRawBytes(rest)
+	}
+	d := flexibleDecoder{pd: rd, flexible: r.version >= 3}
+	count, err := d.getArrayLength()
+	if err != nil {
+		return err
+	}
+	entries := make([]apiVersionsEntry, 0, count)
+	for i := 0; i < count; i++ {
+		var entry apiVersionsEntry
+		if entry.apiKey, err = rd.getInt16(); err != nil {
+			return err
+		}
+		if entry.minVersion, err = rd.getInt16(); err != nil {
+			return err
+		}
+		if entry.maxVersion, err = rd.getInt16(); err != nil {
+			return err
+		}
+		if err = d.getTaggedFields(&entry.taggedFields); err != nil {
+			return err
+		}
+		if maxVersion, ok := r.maxVersions[entry.apiKey]; ok {
+			if maxVersion < entry.minVersion {
+				// none of the versions supported by the broker is allowed
+				continue
+			}
+			if maxVersion < entry.maxVersion {
+				entry.maxVersion = maxVersion
+			}
+		}
+		entries = append(entries, entry)
+	}
+	e := flexibleEncoder{pe: pe, flexible: r.version >= 3}
+	if err = e.putArrayLength(len(entries)); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		pe.putInt16(entry.apiKey)
+		pe.putInt16(entry.minVersion)
+		pe.putInt16(entry.maxVersion)
+		if err = e.putTaggedFields(&entry.taggedFields); err != nil {
+			return err
+		}
+	}
+	// the throttle time and the tagged fields are copied unchanged
+	rest, err := rd.getRawBytes(rd.remaining())
+	if err != nil {
+		return err
+	}
+	return pe.putRawBytes(rest)
+}
+
+// CapApiVersions caps the max versions of the api keys advertised by the api versions response (v0-v4) body following the
+// response header. Api keys whose min version is greater than the cap are removed.
+func CapApiVersions(apiVersion int16, body []byte, maxVersions map[int16]int16) ([]byte, error) {
+	if apiVersion < 0 || apiVersion > 4 {
+		return nil, PacketEncodingError{fmt.Sprintf("api versions version %d is not supported", apiVersion)}
+	}
+	if len(maxVersions) == 0 {
+		return body, nil
+	}
+	return Encode(&cappedApiVersionsResponse{version: apiVersion, maxVersions: maxVersions, body: body})
+}
@@ -0,0 +1,64 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapApiVersions(t *testing.T) {
+	for _, version := range []int16{0, 1, 3} {
+		a := assert.New(t)
+
+		flexible := version >= 3
+		body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+			pe.putInt16(0)
+			e := flexibleEncoder{pe: pe, flexible: flexible}
+			_ = e.putArrayLength(3)
+			for _, entry := range [][3]int16{{0, 0, 9}, {1, 4, 13}, {3, 0, 12}} {
+				pe.putInt16(entry[0])
+				pe.putInt16(entry[1])
+				pe.putInt16(entry[2])
+				_ = e.putTaggedFields(&TaggedFields{})
+			}
+			if version >= 1 {
+				pe.putInt32(100) // throttle time
+			}
+			return e.putTaggedFields(&TaggedFields{})
+		}))
+		a.Nil(err)
+
+		result, err := CapApiVersions(version, body, map[int16]int16{0: 7, 1: 3, 3: 20})
+		a.Nil(err)
+
+		a.Nil(Decode(result, decoderFunc(func(pd packetDecoder) error {
+			d := flexibleDecoder{pd: pd, flexible: flexible}
+			code, _ := pd.getInt16()
+			a.Equal(int16(0), code)
+			count, _ := d.getArrayLength()
+			// fetch is removed, its min version is greater than the cap
+			a.Equal(2, count)
+			for _, expected := range [][3]int16{{0, 0, 7}, {3, 0, 12}} {
+				apiKey, _ := pd.getInt16()
+				minVersion, _ := pd.getInt16()
+				maxVersion, _ := pd.getInt16()
+				a.Equal(expected, [3]int16{apiKey, minVersion, maxVersion})
+				_ = d.getTaggedFields(&TaggedFields{})
+			}
+			if version >= 1 {
+				throttleTime, _ := pd.getInt32()
+				a.Equal(int32(100), throttleTime)
+			}
+			return d.getTaggedFields(&TaggedFields{})
+		})))
+	}
+
+	// error responses are not modified
+	body := []byte{0, 35, 0, 0, 0, 0}
+	result, err := CapApiVersions(3, body, map[int16]int16{0: 7})
+	assert.Nil(t, err)
+	assert.Equal(t, body, result)
+
+	_, err = CapApiVersions(5, nil, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,224 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+const (
+	compressionSnappy = 2
+	compressionLZ4    = 3
+	compressionZSTD   = 4
+)
+
+// CompressionCodecs maps the compression.type names to the record batch compression codecs
+var CompressionCodecs = map[string]int{
+	"none":   compressionNone,
+	"gzip":   compressionGZIP,
+	"snappy": compressionSnappy,
+	"lz4":    compressionLZ4,
+	"zstd":   compressionZSTD,
+}
+
+// snappy data compressed by the Java client starts with the xerial framing header
+var xerialHeader = []byte{130, 'S', 'N', 'A', 'P', 'P', 'Y', 0}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(MaxResponseSize)))
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+// decompress returns the records compressed with the codec. The decompressed size is limited by MaxResponseSize.
+func decompress(codec int, data []byte) ([]byte, error) {
+	switch codec {
+	case compressionNone:
+		return data, nil
+	case compressionGZIP:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return readLimited(reader)
+	case compressionSnappy:
+		if bytes.HasPrefix(data, xerialHeader) {
+			return decompressXerial(data)
+		}
+		return decodeSnappy(nil, data)
+	case compressionLZ4:
+		return readLimited(lz4.NewReader(bytes.NewReader(data)))
+	case compressionZSTD:
+		_, decoder, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		return decoder.DecodeAll(data, nil)
+	default:
+		return nil, ErrUnsupportedCompression{Codec: codec}
+	}
+}
+
+// compress returns the records compressed with the codec
+func compress(codec int, data []byte) ([]byte, error) {
+	switch codec {
+	case compressionNone:
+		return data, nil
+	case compressionGZIP:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case compressionSnappy:
+		// the Java client reads unframed snappy data as well
+		return snappy.Encode(nil, data), nil
+	case compressionLZ4:
+		var buf bytes.Buffer
+		writer := lz4.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case compressionZSTD:
+		encoder, _, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(data, nil), nil
+	default:
+		return nil, ErrUnsupportedCompression{Codec: codec}
+	}
+}
+
+func readLimited(reader io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, int64(MaxResponseSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > int(MaxResponseSize) {
+		return nil, PacketDecodingError{fmt.Sprintf("decompressed records larger than %d", MaxResponseSize)}
+	}
+	return data, nil
+}
+
+func decodeSnappy(dst []byte, block []byte) ([]byte, error) {
+	length, err := snappy.DecodedLen(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(dst)+length > int(MaxResponseSize) {
+		return nil, PacketDecodingError{fmt.Sprintf("decompressed records larger than %d", MaxResponseSize)}
+	}
+	decoded, err := snappy.Decode(nil, block)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, decoded...), nil
+}
+
+// decompressXerial decodes the xerial framing: header (8 bytes), version (INT32), compatible version (INT32) and chunks of size (INT32) and snappy block
+func decompressXerial(data []byte) ([]byte, error) {
+	if len(data) < len(xerialHeader)+8 {
+		return nil, ErrInsufficientData
+	}
+	data = data[len(xerialHeader)+8:]
+	var result []byte
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, ErrInsufficientData
+		}
+		size := int(binary.BigEndian.Uint32(data))
+		data = data[4:]
+		if size > len(data) {
+			return nil, ErrInsufficientData
+		}
+		var err error
+		if result, err = decodeSnappy(result, data[:size]); err != nil {
+			return nil, err
+		}
+		data = data[size:]
+	}
+	return result, nil
+}
+
+// TranscodeRecordBatches recompresses the record batches (magic v2) compressed with the from codec with the to codec and
+// returns the number of transcoded batches. Legacy messages (magic v0 and v1), batches compressed with other codecs
+// and a partial trailing batch are kept unchanged.
+func TranscodeRecordBatches(records []byte, from int, to int) ([]byte, int, error) {
+	if from == to {
+		return records, 0, nil
+	}
+	result := make([]byte, 0, len(records))
+	transcoded := 0
+	for len(records) > 0 {
+		if len(records) < logOverhead {
+			return append(result, records...), transcoded, nil
+		}
+		length := int(int32(binary.BigEndian.Uint32(records[sizeOffset:logOverhead])))
+		if length <= magicOffset-logOverhead {
+			return nil, 0, PacketDecodingError{fmt.Sprintf("invalid records length %d", length)}
+		}
+		if len(records) < logOverhead+length {
+			return append(result, records...), transcoded, nil
+		}
+		entry := records[:logOverhead+length]
+		records = records[logOverhead+length:]
+
+		if entry[magicOffset] != 2 {
+			result = append(result, entry...)
+			continue
+		}
+		if len(entry) < recordsOffset {
+			return nil, 0, ErrInsufficientData
+		}
+		attributes := binary.BigEndian.Uint16(entry[batchAttributesOffset:])
+		if attributes&controlBatchFlag != 0 || int(attributes&compressionCodecMask) != from {
+			result = append(result, entry...)
+			continue
+		}
+		data, err := decompress(from, entry[recordsOffset:])
+		if err != nil {
+			return nil, 0, err
+		}
+		if data, err = compress(to, data); err != nil {
+			return nil, 0, err
+		}
+		batch := make([]byte, recordsOffset, recordsOffset+len(data))
+		copy(batch, entry[:recordsOffset])
+		batch = append(batch, data...)
+		binary.BigEndian.PutUint16(batch[batchAttributesOffset:], attributes&^compressionCodecMask|uint16(to))
+		binary.BigEndian.PutUint32(batch[sizeOffset:], uint32(len(batch)-logOverhead))
+		binary.BigEndian.PutUint32(batch[batchCrcOffset:], crc32.Checksum(batch[batchAttributesOffset:], castagnoliTable))
+		result = append(result, batch...)
+		transcoded++
+	}
+	return result, transcoded, nil
+}
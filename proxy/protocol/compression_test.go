@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func batchCodec(batch []byte) int {
+	return int(binary.BigEndian.Uint16(batch[batchAttributesOffset:]) & compressionCodecMask)
+}
+
+func TestTranscodeRecordBatches(t *testing.T) {
+	a := assert.New(t)
+
+	records := append(buildRecordBatch(compressionGZIP, []byte("a"), []byte("bc")), buildMessage(1, []byte("d"))...)
+	for _, codecs := range [][2]int{
+		{compressionGZIP, compressionZSTD},
+		{compressionZSTD, compressionLZ4},
+		{compressionLZ4, compressionSnappy},
+		{compressionSnappy, compressionNone},
+	} {
+		transcoded, count, err := TranscodeRecordBatches(records, codecs[0], codecs[1])
+		require.NoError(t, err)
+		a.Equal(1, count)
+
+		batch := transcoded[:logOverhead+int(binary.BigEndian.Uint32(transcoded[sizeOffset:]))]
+		a.Equal(codecs[1], batchCodec(batch))
+		a.Equal(crc32.Checksum(batch[batchAttributesOffset:], castagnoliTable), binary.BigEndian.Uint32(batch[batchCrcOffset:]))
+		a.Equal(buildMessage(1, []byte("d")), transcoded[len(batch):], "legacy messages are kept")
+		records = transcoded
+	}
+	a.Equal([]string{"a", "bc", "d"}, recordValues(t, records))
+}
+
+func TestTranscodeRecordBatchesOtherCodec(t *testing.T) {
+	a := assert.New(t)
+
+	batch := buildRecordBatch(compressionNone, []byte("a"))
+	partial := buildRecordBatch(compressionGZIP, []byte("b"))[:recordsOffset]
+	records := append(batch, partial...)
+
+	transcoded, count, err := TranscodeRecordBatches(records, compressionGZIP, compressionZSTD)
+	a.Nil(err)
+	a.Equal(0, count)
+	a.Equal(records, transcoded)
+}
+
+func TestDecompressXerialSnappy(t *testing.T) {
+	a := assert.New(t)
+
+	data := append([]byte{}, xerialHeader...)
+	data = append(data, 0, 0, 0, 1, 0, 0, 0, 1)
+	for _, chunk := range []string{"hello ", "world"} {
+		block := snappy.Encode(nil, []byte(chunk))
+		size := make([]byte, 4)
+		binary.BigEndian.PutUint32(size, uint32(len(block)))
+		data = append(append(data, size...), block...)
+	}
+	decompressed, err := decompress(compressionSnappy, data)
+	a.Nil(err)
+	a.Equal("hello world", string(decompressed))
+
+	_, err = decompress(compressionSnappy, data[:len(data)-1])
+	a.Equal(ErrInsufficientData, err)
+}
@@ -0,0 +1,504 @@
+package protocol
+
+import (
+	"fmt"
+)
+
+const (
+	apiKeyCreateTopics = 19
+	apiKeyDeleteTopics = 20
+)
+
+// CreateTopicsRequest is a create topics request v0-v7 starting after the api key and version of the request header
+type CreateTopicsRequest struct {
+	Version int16
+
+	// request header v1, v2 (v5+)
+	CorrelationID      int32
+	ClientID           *string
+	HeaderTaggedFields TaggedFields
+
+	Topics       []CreatableTopic
+	TimeoutMs    int32
+	ValidateOnly bool // v1+
+	TaggedFields TaggedFields
+}
+
+type CreatableTopic struct {
+	Name              string
+	NumPartitions     int32
+	ReplicationFactor int16
+	Assignments       []CreatableReplicaAssignment
+	Configs           []CreatableTopicConfig
+	TaggedFields      TaggedFields
+}
+
+type CreatableReplicaAssignment struct {
+	PartitionIndex int32
+	BrokerIDs      []int32
+	TaggedFields   TaggedFields
+}
+
+type CreatableTopicConfig struct {
+	Name         string
+	Value        *string
+	TaggedFields TaggedFields
+}
+
+func (r *CreateTopicsRequest) flexible() bool {
+	return r.Version >= 5
+}
+
+func (r *CreateTopicsRequest) decode(pd packetDecoder) (err error) {
+	if r.Version < 0 || r.Version > 7 {
+		return PacketDecodingError{fmt.Sprintf("create topics version %d is not supported", r.Version)}
+	}
+	d := flexibleDecoder{pd: pd, flexible: r.flexible()}
+	if r.CorrelationID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.ClientID, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	if err = d.getTaggedFields(&r.HeaderTaggedFields); err != nil {
+		return err
+	}
+	topicCount, err := d.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make([]CreatableTopic, 0, topicCount)
+	for i := 0; i < topicCount; i++ {
+		var topic CreatableTopic
+		if err = topic.decode(d); err != nil {
+			return err
+		}
+		r.Topics = append(r.Topics, topic)
+	}
+	if r.TimeoutMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.Version >= 1 {
+		if r.ValidateOnly, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+	return d.getTaggedFields(&r.TaggedFields)
+}
+
+func (t *CreatableTopic) decode(d flexibleDecoder) (err error) {
+	if t.Name, err = d.getString(); err != nil {
+		return err
+	}
+	if t.NumPartitions, err = d.pd.getInt32(); err != nil {
+		return err
+	}
+	if t.ReplicationFactor, err = d.pd.getInt16(); err != nil {
+		return err
+	}
+	assignmentCount, err := d.getArrayLength()
+	if err != nil {
+		return err
+	}
+	t.Assignments = make([]CreatableReplicaAssignment, 0, assignmentCount)
+	for i := 0; i < assignmentCount; i++ {
+		var assignment CreatableReplicaAssignment
+		if assignment.PartitionIndex, err = d.pd.getInt32(); err != nil {
+			return err
+		}
+		brokerCount, err := d.getArrayLength()
+		if err != nil {
+			return err
+		}
+		assignment.BrokerIDs = make([]int32, 0, brokerCount)
+		for j := 0; j < brokerCount; j++ {
+			brokerID, err := d.pd.getInt32()
+			if err != nil {
+				return err
+			}
+			assignment.BrokerIDs = append(assignment.BrokerIDs, brokerID)
+		}
+		if err = d.getTaggedFields(&assignment.TaggedFields); err != nil {
+			return err
+		}
+		t.Assignments = append(t.Assignments, assignment)
+	}
+	configCount, err := d.getArrayLength()
+	if err != nil {
+		return err
+	}
+	t.Configs = make([]CreatableTopicConfig, 0, configCount)
+	for i := 0; i < configCount; i++ {
+		var config CreatableTopicConfig
+		if config.Name, err = d.getString(); err != nil {
+			return err
+		}
+		if config.Value, err = d.getNullableString(); err != nil {
+			return err
+		}
+		if err = d.getTaggedFields(&config.TaggedFields); err != nil {
+			return err
+		}
+		t.Configs = append(t.Configs, config)
+	}
+	return d.getTaggedFields(&t.TaggedFields)
+}
+
+func (r *CreateTopicsRequest) encode(pe packetEncoder) (err error) {
+	if r.Version < 0 || r.Version > 7 {
+		return PacketEncodingError{fmt.Sprintf("create topics version %d is not supported", r.Version)}
+	}
+	e := flexibleEncoder{pe: pe, flexible: r.flexible()}
+	pe.putInt32(r.CorrelationID)
+	if err = pe.putNullableString(r.ClientID); err != nil {
+		return err
+	}
+	if err = e.putTaggedFields(&r.HeaderTaggedFields); err != nil {
+		return err
+	}
+	if err = e.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for i := range r.Topics {
+		if err = r.Topics[i].encode(e); err != nil {
+			return err
+		}
+	}
+	pe.putInt32(r.TimeoutMs)
+	if r.Version >= 1 {
+		pe.putBool(r.ValidateOnly)
+	}
+	return e.putTaggedFields(&r.TaggedFields)
+}
+
+func (t *CreatableTopic) encode(e flexibleEncoder) (err error) {
+	if err = e.putString(t.Name); err != nil {
+		return err
+	}
+	e.pe.putInt32(t.NumPartitions)
+	e.pe.putInt16(t.ReplicationFactor)
+	if err = e.putArrayLength(len(t.Assignments)); err != nil {
+		return err
+	}
+	for i := range t.Assignments {
+		assignment := &t.Assignments[i]
+		e.pe.putInt32(assignment.PartitionIndex)
+		if err = e.putArrayLength(len(assignment.BrokerIDs)); err != nil {
+			return err
+		}
+		for _, brokerID := range assignment.BrokerIDs {
+			e.pe.putInt32(brokerID)
+		}
+		if err = e.putTaggedFields(&assignment.TaggedFields); err != nil {
+			return err
+		}
+	}
+	if err = e.putArrayLength(len(t.Configs)); err != nil {
+		return err
+	}
+	for i := range t.Configs {
+		config := &t.Configs[i]
+		if err = e.putString(config.Name); err != nil {
+			return err
+		}
+		if err = e.putNullableString(config.Value); err != nil {
+			return err
+		}
+		if err = e.putTaggedFields(&config.TaggedFields); err != nil {
+			return err
+		}
+	}
+	return e.putTaggedFields(&t.TaggedFields)
+}
+
+// DeleteTopicsRequest is a delete topics request v0-v6 starting after the api key and version of the request header
+type DeleteTopicsRequest struct {
+	Version int16
+
+	// request header v1, v2 (v4+)
+	CorrelationID      int32
+	ClientID           *string
+	HeaderTaggedFields TaggedFields
+
+	// v6+ topics are identified by the name or the topic id
+	Topics       []DeleteTopicState
+	TimeoutMs    int32
+	TaggedFields TaggedFields
+}
+
+type DeleteTopicState struct {
+	Name         *string
+	TopicID      [2]int64 // v6+
+	TaggedFields TaggedFields
+}
+
+func (r *DeleteTopicsRequest) flexible() bool {
+	return r.Version >= 4
+}
+
+func (r *DeleteTopicsRequest) decode(pd packetDecoder) (err error) {
+	if r.Version < 0 || r.Version > 6 {
+		return PacketDecodingError{fmt.Sprintf("delete topics version %d is not supported", r.Version)}
+	}
+	d := flexibleDecoder{pd: pd, flexible: r.flexible()}
+	if r.CorrelationID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.ClientID, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	if err = d.getTaggedFields(&r.HeaderTaggedFields); err != nil {
+		return err
+	}
+	topicCount, err := d.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make([]DeleteTopicState, 0, topicCount)
+	for i := 0; i < topicCount; i++ {
+		var topic DeleteTopicState
+		if r.Version >= 6 {
+			if topic.Name, err = d.getNullableString(); err != nil {
+				return err
+			}
+			for j := range topic.TopicID {
+				if topic.TopicID[j], err = pd.getInt64(); err != nil {
+					return err
+				}
+			}
+			if err = d.getTaggedFields(&topic.TaggedFields); err != nil {
+				return err
+			}
+		} else {
+			name, err := d.getString()
+			if err != nil {
+				return err
+			}
+			topic.Name = &name
+		}
+		r.Topics = append(r.Topics, topic)
+	}
+	if r.TimeoutMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+	return d.getTaggedFields(&r.TaggedFields)
+}
+
+func (r *DeleteTopicsRequest) encode(pe packetEncoder) (err error) {
+	if r.Version < 0 || r.Version > 6 {
+		return PacketEncodingError{fmt.Sprintf("delete topics version %d is not supported", r.Version)}
+	}
+	e := flexibleEncoder{pe: pe, flexible: r.flexible()}
+	pe.putInt32(r.CorrelationID)
+	if err = pe.putNullableString(r.ClientID); err != nil {
+		return err
+	}
+	if err = e.putTaggedFields(&r.HeaderTaggedFields); err != nil {
+		return err
+	}
+	if err = e.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for i := range r.Topics {
+		topic := &r.Topics[i]
+		if r.Version >= 6 {
+			if err = e.putNullableString(topic.Name); err != nil {
+				return err
+			}
+			for _, part := range topic.TopicID {
+				pe.putInt64(part)
+			}
+			if err = e.putTaggedFields(&topic.TaggedFields); err != nil {
+				return err
+			}
+		} else {
+			if topic.Name == nil {
+				return PacketEncodingError{fmt.Sprintf("delete topics version %d requires topic names", r.Version)}
+			}
+			if err = e.putString(*topic.Name); err != nil {
+				return err
+			}
+		}
+	}
+	pe.putInt32(r.TimeoutMs)
+	return e.putTaggedFields(&r.TaggedFields)
+}
+
+// TopicError is the result of a topic, which was not sent to the broker
+type TopicError struct {
+	Name         *string
+	TopicID      [2]int64
+	ErrorCode    KError
+	ErrorMessage *string
+}
+
+// topicErrorsResponse prepends the topic errors to the topic results of a create or delete topics response
+type topicErrorsResponse struct {
+	apiKey  int16
+	version int16
+	errors  []TopicError
+	body    []byte
+}
+
+func (r *topicErrorsResponse) flexible() bool {
+	if r.apiKey == apiKeyCreateTopics {
+		return r.version >= 5
+	}
+	return r.version >= 4
+}
+
+func (r *topicErrorsResponse) encode(pe packetEncoder) (err error) {
+	body := r.body
+	throttleTime := (r.apiKey == apiKeyCreateTopics && r.version >= 2) || (r.apiKey == apiKeyDeleteTopics && r.version >= 1)
+	rd := &realDecoder{raw: body}
+	var throttleTimeMs int32
+	if throttleTime {
+		if throttleTimeMs, err = rd.getInt32(); err != nil {
+			return err
+		}
+	}
+	d := flexibleDecoder{pd: rd, flexible: r.flexible()}
+	count, err := d.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if throttleTime {
+		pe.putInt32(throttleTimeMs)
+	}
+	e := flexibleEncoder{pe: pe, flexible: r.flexible()}
+	if err = e.putArrayLength(count + len(r.errors)); err != nil {
+		return err
+	}
+	for _, topicError := range r.errors {
+		if err = r.encodeTopicError(e, topicError); err != nil {
+			return err
+		}
+	}
+	// the remaining topic results and fields are copied unchanged
+	rest, err := rd.getRawBytes(rd.remaining())
+	if err != nil {
+		return err
+	}
+	return pe.putRawBytes(rest)
+}
+
+func (r *topicErrorsResponse) encodeTopicError(e flexibleEncoder, topicError TopicError) (err error) {
+	if r.apiKey == apiKeyDeleteTopics && r.version >= 6 {
+		if err = e.putNullableString(topicError.Name); err != nil {
+			return err
+		}
+		for _, part := range topicError.TopicID {
+			e.pe.putInt64(part)
+		}
+	} else {
+		name := ""
+		if topicError.Name != nil {
+			name = *topicError.Name
+		}
+		if err = e.putString(name); err != nil {
+			return err
+		}
+		if r.apiKey == apiKeyCreateTopics && r.version >= 7 {
+			for _, part := range topicError.TopicID {
+				e.pe.putInt64(part)
+			}
+		}
+	}
+	e.pe.putInt16(int16(topicError.ErrorCode))
+	if (r.apiKey == apiKeyCreateTopics && r.version >= 1) || (r.apiKey == apiKeyDeleteTopics && r.version >= 5) {
+		if err = e.putNullableString(topicError.ErrorMessage); err != nil {
+			return err
+		}
+	}
+	if r.apiKey == apiKeyCreateTopics && r.version >= 5 {
+		// num_partitions, replication_factor, null configs
+		e.pe.putInt32(-1)
+		e.pe.putInt16(-1)
+		if err = e.pe.putCompactNullableArrayLength(-1); err != nil {
+			return err
+		}
+	}
+	return e.putTaggedFields(&TaggedFields{})
+}
+
+// AddTopicErrors prepends the topic errors to the topic results of the create topics (v0-v7) or delete topics (v0-v6) response body
+// following the response header
+func AddTopicErrors(apiKey int16, apiVersion int16, body []byte, errors []TopicError) ([]byte, error) {
+	switch {
+	case apiKey == apiKeyCreateTopics && apiVersion >= 0 && apiVersion <= 7:
+	case apiKey == apiKeyDeleteTopics && apiVersion >= 0 && apiVersion <= 6:
+	default:
+		return nil, PacketEncodingError{fmt.Sprintf("topic errors for api key %d version %d are not supported", apiKey, apiVersion)}
+	}
+	if len(errors) == 0 {
+		return body, nil
+	}
+	return Encode(&topicErrorsResponse{apiKey: apiKey, version: apiVersion, errors: errors, body: body})
+}
+
+// flexibleDecoder decodes strings, arrays and tagged fields of flexible versions in the compact form
+type flexibleDecoder struct {
+	pd       packetDecoder
+	flexible bool
+}
+
+func (d flexibleDecoder) getString() (string, error) {
+	if d.flexible {
+		return d.pd.getCompactString()
+	}
+	return d.pd.getString()
+}
+
+func (d flexibleDecoder) getNullableString() (*string, error) {
+	if d.flexible {
+		return d.pd.getCompactNullableString()
+	}
+	return d.pd.getNullableString()
+}
+
+func (d flexibleDecoder) getArrayLength() (int, error) {
+	if d.flexible {
+		return d.pd.getCompactArrayLength()
+	}
+	return d.pd.getArrayLength()
+}
+
+func (d flexibleDecoder) getTaggedFields(fields *TaggedFields) error {
+	if d.flexible {
+		return fields.decode(d.pd)
+	}
+	return nil
+}
+
+type flexibleEncoder struct {
+	pe       packetEncoder
+	flexible bool
+}
+
+func (e flexibleEncoder) putString(in string) error {
+	if e.flexible {
+		return e.pe.putCompactString(in)
+	}
+	return e.pe.putString(in)
+}
+
+func (e flexibleEncoder) putNullableString(in *string) error {
+	if e.flexible {
+		return e.pe.putCompactNullableString(in)
+	}
+	return e.pe.putNullableString(in)
+}
+
+func (e flexibleEncoder) putArrayLength(in int) error {
+	if e.flexible {
+		return e.pe.putCompactArrayLength(in)
+	}
+	return e.pe.putArrayLength(in)
+}
+
+func (e flexibleEncoder) putTaggedFields(fields *TaggedFields) error {
+	if e.flexible {
+		return fields.encode(e.pe)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type encoderFunc func(pe packetEncoder) error
+
+func (f encoderFunc) encode(pe packetEncoder) error {
+	return f(pe)
+}
+
+type decoderFunc func(pd packetDecoder) error
+
+func (f decoderFunc) decode(pd packetDecoder) error {
+	return f(pd)
+}
+
+func TestEncodeDecodeCreateTopicsRequest(t *testing.T) {
+	clientID := "admin"
+	value := "compact"
+	for _, version := range []int16{0, 1, 4, 5, 7} {
+		a := assert.New(t)
+
+		request := &CreateTopicsRequest{
+			Version:       version,
+			CorrelationID: 3,
+			ClientID:      &clientID,
+			Topics: []CreatableTopic{
+				{Name: "orders", NumPartitions: 3, ReplicationFactor: 2, Configs: []CreatableTopicConfig{{Name: "cleanup.policy", Value: &value}}},
+				{Name: "payments", NumPartitions: -1, ReplicationFactor: -1, Assignments: []CreatableReplicaAssignment{{PartitionIndex: 0, BrokerIDs: []int32{1, 2}}}},
+			},
+			TimeoutMs:    30000,
+			ValidateOnly: version >= 1,
+		}
+		buf, err := Encode(request)
+		a.Nil(err)
+
+		decoded := &CreateTopicsRequest{Version: version}
+		a.Nil(Decode(buf, decoded))
+		a.Equal(request.Topics[0].Name, decoded.Topics[0].Name)
+		a.Equal(request.Topics[0].Configs[0].Value, decoded.Topics[0].Configs[0].Value)
+		a.Equal(request.Topics[1].Assignments[0].BrokerIDs, decoded.Topics[1].Assignments[0].BrokerIDs)
+		a.Equal(request.ValidateOnly, decoded.ValidateOnly)
+
+		reencoded, err := Encode(decoded)
+		a.Nil(err)
+		a.Equal(buf, reencoded)
+	}
+
+	_, err := Encode(&CreateTopicsRequest{Version: 8})
+	assert.NotNil(t, err)
+}
+
+func TestEncodeDecodeDeleteTopicsRequest(t *testing.T) {
+	clientID := "admin"
+	name := "orders"
+	for _, version := range []int16{0, 3, 4, 6} {
+		a := assert.New(t)
+
+		request := &DeleteTopicsRequest{
+			Version:       version,
+			CorrelationID: 5,
+			ClientID:      &clientID,
+			Topics:        []DeleteTopicState{{Name: &name}},
+			TimeoutMs:     30000,
+		}
+		if version >= 6 {
+			request.Topics = append(request.Topics, DeleteTopicState{TopicID: [2]int64{1, 2}})
+		}
+		buf, err := Encode(request)
+		a.Nil(err)
+
+		decoded := &DeleteTopicsRequest{Version: version}
+		a.Nil(Decode(buf, decoded))
+		a.Equal(len(request.Topics), len(decoded.Topics))
+		a.Equal(name, *decoded.Topics[0].Name)
+
+		reencoded, err := Encode(decoded)
+		a.Nil(err)
+		a.Equal(buf, reencoded)
+	}
+
+	_, err := Encode(&DeleteTopicsRequest{Version: 7})
+	assert.NotNil(t, err)
+}
+
+func TestAddTopicErrorsCreateTopicsV1(t *testing.T) {
+	a := assert.New(t)
+
+	body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+		_ = pe.putArrayLength(1)
+		_ = pe.putString("orders")
+		pe.putInt16(0)
+		return pe.putNullableString(nil)
+	}))
+	a.Nil(err)
+
+	name, message := "bad", "policy violated"
+	result, err := AddTopicErrors(apiKeyCreateTopics, 1, body, []TopicError{{Name: &name, ErrorCode: ErrPolicyViolation, ErrorMessage: &message}})
+	a.Nil(err)
+
+	a.Nil(Decode(result, decoderFunc(func(pd packetDecoder) error {
+		count, _ := pd.getArrayLength()
+		a.Equal(2, count)
+		for _, expected := range []struct {
+			name    string
+			code    int16
+			message *string
+		}{{"bad", 44, &message}, {"orders", 0, nil}} {
+			topic, _ := pd.getString()
+			code, _ := pd.getInt16()
+			msg, err := pd.getNullableString()
+			a.Equal(expected.name, topic)
+			a.Equal(expected.code, code)
+			a.Equal(expected.message, msg)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})))
+}
+
+func TestAddTopicErrorsCreateTopicsV5(t *testing.T) {
+	a := assert.New(t)
+
+	body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+		pe.putInt32(100) // throttle time
+		_ = pe.putCompactArrayLength(0)
+		return (&TaggedFields{}).encode(pe)
+	}))
+	a.Nil(err)
+
+	name, message := "bad", "policy violated"
+	result, err := AddTopicErrors(apiKeyCreateTopics, 5, body, []TopicError{{Name: &name, ErrorCode: ErrPolicyViolation, ErrorMessage: &message}})
+	a.Nil(err)
+
+	a.Nil(Decode(result, decoderFunc(func(pd packetDecoder) error {
+		throttleTime, _ := pd.getInt32()
+		a.Equal(int32(100), throttleTime)
+		count, _ := pd.getCompactArrayLength()
+		a.Equal(1, count)
+		topic, _ := pd.getCompactString()
+		a.Equal("bad", topic)
+		code, _ := pd.getInt16()
+		a.Equal(int16(44), code)
+		msg, _ := pd.getCompactNullableString()
+		a.Equal(&message, msg)
+		numPartitions, _ := pd.getInt32()
+		a.Equal(int32(-1), numPartitions)
+		replicationFactor, _ := pd.getInt16()
+		a.Equal(int16(-1), replicationFactor)
+		configs, _ := pd.getCompactNullableArrayLength()
+		a.Equal(-1, configs)
+		// topic and response tagged fields
+		for i := 0; i < 2; i++ {
+			if err := (&TaggedFields{}).decode(pd); err != nil {
+				return err
+			}
+		}
+		return nil
+	})))
+}
+
+func TestAddTopicErrorsDeleteTopicsV6(t *testing.T) {
+	a := assert.New(t)
+
+	body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+		pe.putInt32(0) // throttle time
+		_ = pe.putCompactArrayLength(0)
+		return (&TaggedFields{}).encode(pe)
+	}))
+	a.Nil(err)
+
+	message := "topic must be deleted by name"
+	result, err := AddTopicErrors(apiKeyDeleteTopics, 6, body, []TopicError{{TopicID: [2]int64{1, 2}, ErrorCode: ErrPolicyViolation, ErrorMessage: &message}})
+	a.Nil(err)
+
+	a.Nil(Decode(result, decoderFunc(func(pd packetDecoder) error {
+		_, _ = pd.getInt32()
+		count, _ := pd.getCompactArrayLength()
+		a.Equal(1, count)
+		topic, _ := pd.getCompactNullableString()
+		a.Nil(topic)
+		high, _ := pd.getInt64()
+		low, _ := pd.getInt64()
+		a.Equal([]int64{1, 2}, []int64{high, low})
+		code, _ := pd.getInt16()
+		a.Equal(int16(44), code)
+		msg, _ := pd.getCompactNullableString()
+		a.Equal(&message, msg)
+		for i := 0; i < 2; i++ {
+			if err := (&TaggedFields{}).decode(pd); err != nil {
+				return err
+			}
+		}
+		return nil
+	})))
+
+	_, err = AddTopicErrors(apiKeyDeleteTopics, 7, body, nil)
+	a.NotNil(err)
+}
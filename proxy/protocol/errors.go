@@ -116,6 +116,8 @@ const (
 	ErrSASLAuthenticationFailed           KError = 58
 	ErrUnknownProducerID                  KError = 59
 	ErrReassignmentInProgress             KError = 60
+	ErrUnsupportedCompressionType         KError = 76
+	ErrInvalidRecord                      KError = 87
 )
 
 func (err KError) Error() string {
@@ -246,6 +248,10 @@ func (err KError) Error() string {
 		return "kafka server: The broker could not locate the producer metadata associated with the Producer ID."
 	case ErrReassignmentInProgress:
 		return "kafka server: A partition reassignment is in progress."
+	case ErrUnsupportedCompressionType:
+		return "kafka server: The requesting client does not support the compression type of given partition."
+	case ErrInvalidRecord:
+		return "kafka server: This record has failed the validation on broker and hence will be rejected."
 	}
 
 	return fmt.Sprintf("Unknown error, how did this happen? Error code = %d", err)
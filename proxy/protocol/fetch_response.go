@@ -0,0 +1,181 @@
+package protocol
+
+import "fmt"
+
+// FetchResponse is a fetch response v0-v11 following the response header
+type FetchResponse struct {
+	Version int16
+
+	ThrottleTimeMs int32 // v1+
+	ErrorCode      int16 // v7+
+	SessionID      int32 // v7+
+	Responses      []FetchTopicResponse
+}
+
+type FetchTopicResponse struct {
+	Topic      string
+	Partitions []FetchPartitionResponse
+}
+
+type FetchPartitionResponse struct {
+	Partition            int32
+	ErrorCode            int16
+	HighWatermark        int64
+	LastStableOffset     int64                     // v4+
+	LogStartOffset       int64                     // v5+
+	AbortedTransactions  []FetchAbortedTransaction // v4+, nullable
+	PreferredReadReplica int32                     // v11+
+	Records              []byte
+}
+
+type FetchAbortedTransaction struct {
+	ProducerID  int64
+	FirstOffset int64
+}
+
+func (r *FetchResponse) decode(pd packetDecoder) (err error) {
+	if r.Version < 0 || r.Version > 11 {
+		return PacketDecodingError{fmt.Sprintf("fetch version %d is not supported", r.Version)}
+	}
+	if r.Version >= 1 {
+		if r.ThrottleTimeMs, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 7 {
+		if r.ErrorCode, err = pd.getInt16(); err != nil {
+			return err
+		}
+		if r.SessionID, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+	topicCount, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Responses = make([]FetchTopicResponse, 0, topicCount)
+	for i := 0; i < topicCount; i++ {
+		var topic FetchTopicResponse
+		if topic.Topic, err = pd.getString(); err != nil {
+			return err
+		}
+		partitionCount, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		topic.Partitions = make([]FetchPartitionResponse, 0, partitionCount)
+		for j := 0; j < partitionCount; j++ {
+			var partition FetchPartitionResponse
+			if err = partition.decode(pd, r.Version); err != nil {
+				return err
+			}
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+		r.Responses = append(r.Responses, topic)
+	}
+	return nil
+}
+
+func (p *FetchPartitionResponse) decode(pd packetDecoder, version int16) (err error) {
+	if p.Partition, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if p.ErrorCode, err = pd.getInt16(); err != nil {
+		return err
+	}
+	if p.HighWatermark, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if version >= 4 {
+		if p.LastStableOffset, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if version >= 5 {
+			if p.LogStartOffset, err = pd.getInt64(); err != nil {
+				return err
+			}
+		}
+		abortedCount, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if abortedCount >= 0 {
+			p.AbortedTransactions = make([]FetchAbortedTransaction, 0, abortedCount)
+		}
+		for i := 0; i < abortedCount; i++ {
+			var aborted FetchAbortedTransaction
+			if aborted.ProducerID, err = pd.getInt64(); err != nil {
+				return err
+			}
+			if aborted.FirstOffset, err = pd.getInt64(); err != nil {
+				return err
+			}
+			p.AbortedTransactions = append(p.AbortedTransactions, aborted)
+		}
+	}
+	if version >= 11 {
+		if p.PreferredReadReplica, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+	if p.Records, err = pd.getBytes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *FetchResponse) encode(pe packetEncoder) (err error) {
+	if r.Version < 0 || r.Version > 11 {
+		return PacketEncodingError{fmt.Sprintf("fetch version %d is not supported", r.Version)}
+	}
+	if r.Version >= 1 {
+		pe.putInt32(r.ThrottleTimeMs)
+	}
+	if r.Version >= 7 {
+		pe.putInt16(r.ErrorCode)
+		pe.putInt32(r.SessionID)
+	}
+	if err = pe.putArrayLength(len(r.Responses)); err != nil {
+		return err
+	}
+	for _, topic := range r.Responses {
+		if err = pe.putString(topic.Topic); err != nil {
+			return err
+		}
+		if err = pe.putArrayLength(len(topic.Partitions)); err != nil {
+			return err
+		}
+		for _, partition := range topic.Partitions {
+			if err = partition.encode(pe, r.Version); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *FetchPartitionResponse) encode(pe packetEncoder, version int16) (err error) {
+	pe.putInt32(p.Partition)
+	pe.putInt16(p.ErrorCode)
+	pe.putInt64(p.HighWatermark)
+	if version >= 4 {
+		pe.putInt64(p.LastStableOffset)
+		if version >= 5 {
+			pe.putInt64(p.LogStartOffset)
+		}
+		if p.AbortedTransactions == nil {
+			pe.putInt32(-1)
+		} else if err = pe.putArrayLength(len(p.AbortedTransactions)); err != nil {
+			return err
+		}
+		for _, aborted := range p.AbortedTransactions {
+			pe.putInt64(aborted.ProducerID)
+			pe.putInt64(aborted.FirstOffset)
+		}
+	}
+	if version >= 11 {
+		pe.putInt32(p.PreferredReadReplica)
+	}
+	return pe.putBytes(p.Records)
+}
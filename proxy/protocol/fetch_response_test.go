@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecodeFetchResponse(t *testing.T) {
+	for _, version := range []int16{0, 1, 4, 5, 7, 11} {
+		a := assert.New(t)
+
+		response := &FetchResponse{
+			Version: version,
+			Responses: []FetchTopicResponse{
+				{Topic: "orders", Partitions: []FetchPartitionResponse{
+					{Partition: 0, HighWatermark: 10, Records: buildRecordBatch(compressionNone, []byte("a"))},
+					{Partition: 1, ErrorCode: 6, HighWatermark: -1},
+				}},
+			},
+		}
+		if version >= 1 {
+			response.ThrottleTimeMs = 100
+		}
+		if version >= 4 {
+			response.Responses[0].Partitions[0].LastStableOffset = 10
+			response.Responses[0].Partitions[0].AbortedTransactions = []FetchAbortedTransaction{{ProducerID: 1, FirstOffset: 2}}
+		}
+		if version >= 5 {
+			response.Responses[0].Partitions[0].LogStartOffset = 3
+		}
+		if version >= 7 {
+			response.SessionID = 42
+		}
+		if version >= 11 {
+			response.Responses[0].Partitions[0].PreferredReadReplica = -1
+		}
+		buf, err := Encode(response)
+		a.Nil(err)
+
+		decoded := &FetchResponse{Version: version}
+		a.Nil(Decode(buf, decoded))
+		a.Equal(response, decoded)
+	}
+
+	_, err := Encode(&FetchResponse{Version: 12})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,130 @@
+package protocol
+
+import (
+	"errors"
+)
+
+// MetadataRequestV1 requests the brokers of the cluster without topic metadata
+type MetadataRequestV1 struct {
+	Topics []string
+}
+
+func (r *MetadataRequestV1) encode(pe packetEncoder) error {
+	// an empty array requests no topics, null requests all topics
+	return pe.putStringArray(r.Topics)
+}
+
+func (r *MetadataRequestV1) decode(pd packetDecoder) (err error) {
+	r.Topics, err = pd.getStringArray()
+	return err
+}
+
+func (r *MetadataRequestV1) key() int16 {
+	return apiKeyMetadata
+}
+
+func (r *MetadataRequestV1) version() int16 {
+	return 1
+}
+
+type MetadataBroker struct {
+	NodeID int32
+	Host   string
+	Port   int32
+}
+
+// DecodeMetadataBrokers returns the brokers of the metadata response body
+func DecodeMetadataBrokers(apiVersion int16, body []byte) ([]MetadataBroker, error) {
+	schema, err := getResponseSchema(apiKeyMetadata, apiVersion, metadataResponseSchemaVersions)
+	if err != nil {
+		return nil, err
+	}
+	decodedStruct, err := DecodeSchema(body, schema)
+	if err != nil {
+		return nil, err
+	}
+	brokersArray, ok := decodedStruct.Get(brokersKeyName).([]interface{})
+	if !ok {
+		return nil, errors.New("brokers list not found")
+	}
+	brokers := make([]MetadataBroker, 0, len(brokersArray))
+	for _, brokerElement := range brokersArray {
+		broker := brokerElement.(*Struct)
+		nodeID, ok := broker.Get("node_id").(int32)
+		if !ok {
+			return nil, errors.New("broker.node_id not found")
+		}
+		host, ok := broker.Get(hostKeyName).(string)
+		if !ok {
+			return nil, errors.New("broker.host not found")
+		}
+		port, ok := broker.Get(portKeyName).(int32)
+		if !ok {
+			return nil, errors.New("broker.port not found")
+		}
+		brokers = append(brokers, MetadataBroker{NodeID: nodeID, Host: host, Port: port})
+	}
+	return brokers, nil
+}
+
+type MetadataPartitionLeader struct {
+	Topic     string
+	Partition int32
+	Leader    int32
+}
+
+// DecodeMetadataPartitionLeaders returns the leaders of the partitions in the metadata response body. Topics and partitions
+// with an error or without a leader are omitted.
+func DecodeMetadataPartitionLeaders(apiVersion int16, body []byte) ([]MetadataPartitionLeader, error) {
+	schema, err := getResponseSchema(apiKeyMetadata, apiVersion, metadataResponseSchemaVersions)
+	if err != nil {
+		return nil, err
+	}
+	decodedStruct, err := DecodeSchema(body, schema)
+	if err != nil {
+		return nil, err
+	}
+	topicsArray, ok := decodedStruct.Get("topic_metadata").([]interface{})
+	if !ok {
+		return nil, errors.New("topic_metadata list not found")
+	}
+	var leaders []MetadataPartitionLeader
+	for _, topicElement := range topicsArray {
+		topic := topicElement.(*Struct)
+		topicErrorCode, ok := topic.Get("error_code").(int16)
+		if !ok {
+			return nil, errors.New("topic_metadata.error_code not found")
+		}
+		name, ok := topic.Get("topic").(string)
+		if !ok {
+			return nil, errors.New("topic_metadata.topic not found")
+		}
+		partitionsArray, ok := topic.Get("partition_metadata").([]interface{})
+		if !ok {
+			return nil, errors.New("topic_metadata.partition_metadata not found")
+		}
+		if topicErrorCode != 0 {
+			continue
+		}
+		for _, partitionElement := range partitionsArray {
+			partition := partitionElement.(*Struct)
+			errorCode, ok := partition.Get("error_code").(int16)
+			if !ok {
+				return nil, errors.New("partition_metadata.error_code not found")
+			}
+			index, ok := partition.Get("partition").(int32)
+			if !ok {
+				return nil, errors.New("partition_metadata.partition not found")
+			}
+			leader, ok := partition.Get("leader").(int32)
+			if !ok {
+				return nil, errors.New("partition_metadata.leader not found")
+			}
+			if errorCode != 0 || leader < 0 {
+				continue
+			}
+			leaders = append(leaders, MetadataPartitionLeader{Topic: name, Partition: index, Leader: leader})
+		}
+	}
+	return leaders, nil
+}
@@ -0,0 +1,91 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func metadataResponseV1(brokers []MetadataBroker) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, int32(len(brokers)))
+	for _, broker := range brokers {
+		_ = binary.Write(&buf, binary.BigEndian, broker.NodeID)
+		_ = binary.Write(&buf, binary.BigEndian, int16(len(broker.Host)))
+		buf.WriteString(broker.Host)
+		_ = binary.Write(&buf, binary.BigEndian, broker.Port)
+		_ = binary.Write(&buf, binary.BigEndian, int16(-1)) // rack
+	}
+	_ = binary.Write(&buf, binary.BigEndian, int32(1)) // controller_id
+	_ = binary.Write(&buf, binary.BigEndian, int32(0)) // topic_metadata
+	return buf.Bytes()
+}
+
+func TestMetadataRequestV1(t *testing.T) {
+	a := assert.New(t)
+
+	buf, err := Encode(&Request{CorrelationID: 5, ClientID: "kafka-proxy", Body: &MetadataRequestV1{Topics: []string{}}})
+	a.Nil(err)
+	// api key, version, correlation id, client id, empty topics array
+	a.Equal([]byte{0, 3, 0, 1, 0, 0, 0, 5, 0, 11, 'k', 'a', 'f', 'k', 'a', '-', 'p', 'r', 'o', 'x', 'y', 0, 0, 0, 0}, buf)
+
+	decoded := &Request{Body: &MetadataRequestV1{}}
+	a.Nil(Decode(buf, decoded))
+	a.Equal(int32(5), decoded.CorrelationID)
+	a.Empty(decoded.Body.(*MetadataRequestV1).Topics)
+}
+
+func TestDecodeMetadataBrokers(t *testing.T) {
+	a := assert.New(t)
+
+	brokers := []MetadataBroker{{NodeID: 1, Host: "kafka-1", Port: 9092}, {NodeID: 2, Host: "kafka-2", Port: 9093}}
+	decoded, err := DecodeMetadataBrokers(1, metadataResponseV1(brokers))
+	a.Nil(err)
+	a.Equal(brokers, decoded)
+
+	_, err = DecodeMetadataBrokers(1, []byte{0, 0, 0, 1})
+	a.NotNil(err)
+	_, err = DecodeMetadataBrokers(100, nil)
+	a.NotNil(err)
+}
+
+func TestDecodeMetadataPartitionLeaders(t *testing.T) {
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	putString := func(s string) {
+		_ = binary.Write(&buf, binary.BigEndian, int16(len(s)))
+		buf.WriteString(s)
+	}
+	putPartition := func(errorCode int16, partition int32, leader int32) {
+		_ = binary.Write(&buf, binary.BigEndian, errorCode)
+		_ = binary.Write(&buf, binary.BigEndian, partition)
+		_ = binary.Write(&buf, binary.BigEndian, leader)
+		_ = binary.Write(&buf, binary.BigEndian, int32(0)) // replicas
+		_ = binary.Write(&buf, binary.BigEndian, int32(0)) // isr
+	}
+	_ = binary.Write(&buf, binary.BigEndian, int32(0)) // brokers
+	_ = binary.Write(&buf, binary.BigEndian, int32(1)) // controller_id
+	_ = binary.Write(&buf, binary.BigEndian, int32(2)) // topic_metadata
+	_ = binary.Write(&buf, binary.BigEndian, int16(0))
+	putString("orders")
+	buf.WriteByte(0)                                   // is_internal
+	_ = binary.Write(&buf, binary.BigEndian, int32(3)) // partition_metadata
+	putPartition(0, 0, 1)
+	putPartition(0, 1, 2)
+	putPartition(5, 2, -1)                             // LEADER_NOT_AVAILABLE
+	_ = binary.Write(&buf, binary.BigEndian, int16(3)) // UNKNOWN_TOPIC_OR_PARTITION
+	putString("unknown")
+	buf.WriteByte(0)
+	_ = binary.Write(&buf, binary.BigEndian, int32(0))
+
+	leaders, err := DecodeMetadataPartitionLeaders(1, buf.Bytes())
+	a.Nil(err)
+	a.Equal([]MetadataPartitionLeader{{Topic: "orders", Partition: 0, Leader: 1}, {Topic: "orders", Partition: 1, Leader: 2}}, leaders)
+
+	leaders, err = DecodeMetadataPartitionLeaders(1, metadataResponseV1(nil))
+	a.Nil(err)
+	a.Empty(leaders)
+}
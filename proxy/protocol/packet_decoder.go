@@ -21,6 +21,7 @@ type packetDecoder interface {
 	getStringArray() ([]string, error)
 
 	getVarintBytes() ([]byte, error)
+	getRawBytes(length int) ([]byte, error)
 
 	getCompactBytes() ([]byte, error)
 	getCompactString() (string, error)
@@ -0,0 +1,662 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	apiKeyListOffsets          = 2
+	apiKeyOffsetCommit         = 8
+	apiKeyOffsetFetch          = 9
+	apiKeyJoinGroup            = 11
+	apiKeyHeartbeat            = 12
+	apiKeyLeaveGroup           = 13
+	apiKeySyncGroup            = 14
+	apiKeySaslHandshake        = 17
+	apiKeyApiVersions          = 18
+	apiKeyInitProducerId       = 22
+	apiKeyOffsetForLeaderEpoch = 23
+	apiKeySaslAuthenticate     = 36
+)
+
+// PrefixedNamesMaxVersions are the max versions of the api keys whose topic names, group ids and transactional ids
+// can be prefixed. These are the non-flexible versions.
+var PrefixedNamesMaxVersions = map[int16]int16{
+	apiKeyProduce:              8,
+	apiKeyFetch:                11,
+	apiKeyListOffsets:          5,
+	apiKeyMetadata:             8,
+	apiKeyOffsetCommit:         7,
+	apiKeyOffsetFetch:          5,
+	apiKeyFindCoordinator:      2,
+	apiKeyJoinGroup:            5,
+	apiKeyHeartbeat:            3,
+	apiKeyLeaveGroup:           3,
+	apiKeySyncGroup:            3,
+	apiKeyInitProducerId:       1,
+	apiKeyOffsetForLeaderEpoch: 3,
+}
+
+// RecordsMaxVersions are the max versions of the produce requests and fetch responses whose records can be decoded
+// e.g. to be encrypted or transcoded
+var RecordsMaxVersions = map[int16]int16{
+	apiKeyProduce: 8,
+	apiKeyFetch:   11,
+}
+
+// PrefixedNamesSupported reports whether the names of the request can be prefixed. SASL and api versions requests
+// without names are supported in all versions.
+func PrefixedNamesSupported(apiKey int16, apiVersion int16) bool {
+	switch apiKey {
+	case apiKeySaslHandshake, apiKeyApiVersions, apiKeySaslAuthenticate:
+		return true
+	}
+	maxVersion, ok := PrefixedNamesMaxVersions[apiKey]
+	return ok && apiVersion >= 0 && apiVersion <= maxVersion
+}
+
+// PrefixedRequestNames reports whether the requests of the api key contain names to be prefixed
+func PrefixedRequestNames(apiKey int16) bool {
+	_, ok := PrefixedNamesMaxVersions[apiKey]
+	return ok
+}
+
+// PrefixedResponseNames reports whether the responses of the api key contain topic names to be unprefixed
+func PrefixedResponseNames(apiKey int16) bool {
+	switch apiKey {
+	case apiKeyProduce, apiKeyFetch, apiKeyListOffsets, apiKeyMetadata, apiKeyOffsetCommit, apiKeyOffsetFetch, apiKeyOffsetForLeaderEpoch:
+		return true
+	}
+	return false
+}
+
+// nameRewriter copies a message body and replaces the names read at the decoded positions
+type nameRewriter struct {
+	rd  *realDecoder
+	out []byte
+	// offset of the first body byte not copied yet
+	copied int
+	// returns the new name, false if the enclosing array element is removed
+	rename func(name string) (string, bool)
+}
+
+func newNameRewriter(body []byte, rename func(name string) (string, bool)) *nameRewriter {
+	return &nameRewriter{rd: &realDecoder{raw: body}, out: make([]byte, 0, len(body)), rename: rename}
+}
+
+func (r *nameRewriter) flush() {
+	r.out = append(r.out, r.rd.raw[r.copied:r.rd.off]...)
+	r.copied = r.rd.off
+}
+
+func (r *nameRewriter) skip(n int) error {
+	_, err := r.rd.getRawBytes(n)
+	return err
+}
+
+func (r *nameRewriter) skipString() error {
+	_, err := r.rd.getNullableString()
+	return err
+}
+
+func (r *nameRewriter) skipBytes() error {
+	_, err := r.rd.getBytes()
+	return err
+}
+
+func (r *nameRewriter) skipArray(element func() error) error {
+	n, err := r.rd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err = element(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name renames the string at the current position, null strings are not renamed
+func (r *nameRewriter) name() (bool, error) {
+	r.flush()
+	name, err := r.rd.getNullableString()
+	if err != nil || name == nil {
+		return true, err
+	}
+	newName, keep := r.rename(*name)
+	if !keep {
+		return false, nil
+	}
+	r.copied = r.rd.off
+	if len(newName) > 0x7fff {
+		return false, PacketEncodingError{fmt.Sprintf("name of length %d too long", len(newName))}
+	}
+	r.out = append(r.out, 0, 0)
+	binary.BigEndian.PutUint16(r.out[len(r.out)-2:], uint16(len(newName)))
+	r.out = append(r.out, newName...)
+	return true, nil
+}
+
+// array rewrites the elements of the array at the current position, removed elements are not copied
+func (r *nameRewriter) array(element func() (bool, error)) error {
+	r.flush()
+	n, err := r.rd.getArrayLength()
+	if err != nil || n <= 0 {
+		// null and empty arrays are copied unchanged
+		return err
+	}
+	r.copied = r.rd.off
+	prefix := r.out
+	r.out = make([]byte, 0, len(r.rd.raw)-r.rd.off)
+	count := 0
+	for i := 0; i < n; i++ {
+		start := len(r.out)
+		keep, err := element()
+		if err != nil {
+			return err
+		}
+		r.flush()
+		if keep {
+			count++
+		} else {
+			r.out = r.out[:start]
+		}
+	}
+	elements := r.out
+	r.out = append(prefix, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(r.out[len(r.out)-4:], uint32(count))
+	r.out = append(r.out, elements...)
+	return nil
+}
+
+// topics rewrites the topic array at the current position, the name is the first field of the topic
+func (r *nameRewriter) topics(partition func() error) error {
+	return r.array(func() (bool, error) {
+		keep, err := r.name()
+		if err != nil {
+			return false, err
+		}
+		return keep, r.skipArray(partition)
+	})
+}
+
+func (r *nameRewriter) finish() ([]byte, error) {
+	r.rd.off = len(r.rd.raw)
+	r.flush()
+	return r.out, nil
+}
+
+// PrefixRequestNames adds the prefix to the topic names, group ids and transactional ids of the request body
+// following the api key and version
+func PrefixRequestNames(apiKey int16, apiVersion int16, body []byte, prefix string) ([]byte, error) {
+	if !PrefixedNamesSupported(apiKey, apiVersion) || !PrefixedRequestNames(apiKey) {
+		return nil, PacketEncodingError{fmt.Sprintf("names of api key %d version %d cannot be prefixed", apiKey, apiVersion)}
+	}
+	r := newNameRewriter(body, func(name string) (string, bool) {
+		return prefix + name, true
+	})
+	// correlation_id, client_id
+	if err := r.skip(4); err != nil {
+		return nil, err
+	}
+	if err := r.skipString(); err != nil {
+		return nil, err
+	}
+	var err error
+	switch apiKey {
+	case apiKeyProduce:
+		err = r.produceRequest(apiVersion)
+	case apiKeyFetch:
+		err = r.fetchRequest(apiVersion)
+	case apiKeyListOffsets:
+		err = r.listOffsetsRequest(apiVersion)
+	case apiKeyMetadata:
+		err = r.array(r.name)
+	case apiKeyOffsetCommit:
+		err = r.offsetCommitRequest(apiVersion)
+	case apiKeyOffsetFetch:
+		err = r.offsetFetchRequest()
+	case apiKeyFindCoordinator, apiKeyJoinGroup, apiKeyHeartbeat, apiKeyLeaveGroup, apiKeySyncGroup, apiKeyInitProducerId:
+		// the key, group id or transactional id is the first field
+		_, err = r.name()
+	case apiKeyOffsetForLeaderEpoch:
+		err = r.offsetForLeaderEpochRequest(apiVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.finish()
+}
+
+func (r *nameRewriter) produceRequest(apiVersion int16) error {
+	if apiVersion >= 3 {
+		// transactional_id
+		if _, err := r.name(); err != nil {
+			return err
+		}
+	}
+	// acks, timeout_ms
+	if err := r.skip(2 + 4); err != nil {
+		return err
+	}
+	return r.topics(func() error {
+		// partition_index, records
+		if err := r.skip(4); err != nil {
+			return err
+		}
+		return r.skipBytes()
+	})
+}
+
+func (r *nameRewriter) fetchRequest(apiVersion int16) error {
+	// replica_id, max_wait_ms, min_bytes
+	skip := 4 + 4 + 4
+	if apiVersion >= 3 {
+		// max_bytes
+		skip += 4
+	}
+	if apiVersion >= 4 {
+		// isolation_level
+		skip++
+	}
+	if apiVersion >= 7 {
+		// session_id, session_epoch
+		skip += 4 + 4
+	}
+	if err := r.skip(skip); err != nil {
+		return err
+	}
+	// partition, fetch_offset, partition_max_bytes
+	partitionSize := 4 + 8 + 4
+	if apiVersion >= 9 {
+		// current_leader_epoch
+		partitionSize += 4
+	}
+	if apiVersion >= 5 {
+		// log_start_offset
+		partitionSize += 8
+	}
+	if err := r.topics(func() error { return r.skip(partitionSize) }); err != nil {
+		return err
+	}
+	if apiVersion >= 7 {
+		// forgotten_topics_data
+		return r.topics(func() error { return r.skip(4) })
+	}
+	return nil
+}
+
+func (r *nameRewriter) listOffsetsRequest(apiVersion int16) error {
+	// replica_id
+	skip := 4
+	if apiVersion >= 2 {
+		// isolation_level
+		skip++
+	}
+	if err := r.skip(skip); err != nil {
+		return err
+	}
+	// partition_index, timestamp
+	partitionSize := 4 + 8
+	if apiVersion == 0 {
+		// max_num_offsets
+		partitionSize += 4
+	}
+	if apiVersion >= 4 {
+		// current_leader_epoch
+		partitionSize += 4
+	}
+	return r.topics(func() error { return r.skip(partitionSize) })
+}
+
+func (r *nameRewriter) offsetCommitRequest(apiVersion int16) error {
+	// group_id
+	if _, err := r.name(); err != nil {
+		return err
+	}
+	if apiVersion >= 1 {
+		// generation_id, member_id
+		if err := r.skip(4); err != nil {
+			return err
+		}
+		if err := r.skipString(); err != nil {
+			return err
+		}
+	}
+	if apiVersion >= 7 {
+		// group_instance_id
+		if err := r.skipString(); err != nil {
+			return err
+		}
+	}
+	if apiVersion >= 2 && apiVersion <= 4 {
+		// retention_time_ms
+		if err := r.skip(8); err != nil {
+			return err
+		}
+	}
+	// partition_index, committed_offset
+	partitionSize := 4 + 8
+	if apiVersion == 1 {
+		// commit_timestamp
+		partitionSize += 8
+	}
+	if apiVersion >= 6 {
+		// committed_leader_epoch
+		partitionSize += 4
+	}
+	return r.topics(func() error {
+		if err := r.skip(partitionSize); err != nil {
+			return err
+		}
+		// committed_metadata
+		return r.skipString()
+	})
+}
+
+func (r *nameRewriter) offsetFetchRequest() error {
+	// group_id
+	if _, err := r.name(); err != nil {
+		return err
+	}
+	// null topics (v2+) fetch the offsets of all topics
+	return r.topics(func() error { return r.skip(4) })
+}
+
+func (r *nameRewriter) offsetForLeaderEpochRequest(apiVersion int16) error {
+	if apiVersion >= 3 {
+		// replica_id
+		if err := r.skip(4); err != nil {
+			return err
+		}
+	}
+	// partition, leader_epoch
+	partitionSize := 4 + 4
+	if apiVersion >= 2 {
+		// current_leader_epoch
+		partitionSize += 4
+	}
+	return r.topics(func() error { return r.skip(partitionSize) })
+}
+
+// UnprefixResponseNames removes the prefix from the topic names of the response body following the response header.
+// Topics without the prefix are removed from the response.
+func UnprefixResponseNames(apiKey int16, apiVersion int16, body []byte, prefix string) ([]byte, error) {
+	if !PrefixedNamesSupported(apiKey, apiVersion) {
+		return nil, PacketDecodingError{fmt.Sprintf("names of api key %d version %d cannot be unprefixed", apiKey, apiVersion)}
+	}
+	if !PrefixedResponseNames(apiKey) {
+		return body, nil
+	}
+	r := newNameRewriter(body, func(name string) (string, bool) {
+		if !strings.HasPrefix(name, prefix) {
+			return "", false
+		}
+		return name[len(prefix):], true
+	})
+	var err error
+	switch apiKey {
+	case apiKeyProduce:
+		err = r.produceResponse(apiVersion)
+	case apiKeyFetch:
+		err = r.fetchResponse(apiVersion)
+	case apiKeyListOffsets:
+		err = r.listOffsetsResponse(apiVersion)
+	case apiKeyMetadata:
+		err = r.metadataResponse(apiVersion)
+	case apiKeyOffsetCommit:
+		err = r.offsetCommitResponse(apiVersion)
+	case apiKeyOffsetFetch:
+		err = r.offsetFetchResponse(apiVersion)
+	case apiKeyOffsetForLeaderEpoch:
+		err = r.offsetForLeaderEpochResponse(apiVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.finish()
+}
+
+func (r *nameRewriter) produceResponse(apiVersion int16) error {
+	// the throttle time follows the topics
+	return r.topics(func() error {
+		// partition_index, error_code, base_offset
+		partitionSize := 4 + 2 + 8
+		if apiVersion >= 2 {
+			// log_append_time_ms
+			partitionSize += 8
+		}
+		if apiVersion >= 5 {
+			// log_start_offset
+			partitionSize += 8
+		}
+		if err := r.skip(partitionSize); err != nil {
+			return err
+		}
+		if apiVersion < 8 {
+			return nil
+		}
+		// record_errors, error_message
+		if err := r.skipArray(func() error {
+			if err := r.skip(4); err != nil {
+				return err
+			}
+			return r.skipString()
+		}); err != nil {
+			return err
+		}
+		return r.skipString()
+	})
+}
+
+func (r *nameRewriter) fetchResponse(apiVersion int16) error {
+	skip := 0
+	if apiVersion >= 1 {
+		// throttle_time_ms
+		skip += 4
+	}
+	if apiVersion >= 7 {
+		// error_code, session_id
+		skip += 2 + 4
+	}
+	if err := r.skip(skip); err != nil {
+		return err
+	}
+	return r.topics(func() error {
+		// partition_index, error_code, high_watermark
+		partitionSize := 4 + 2 + 8
+		if apiVersion >= 4 {
+			// last_stable_offset
+			partitionSize += 8
+		}
+		if apiVersion >= 5 {
+			// log_start_offset
+			partitionSize += 8
+		}
+		if err := r.skip(partitionSize); err != nil {
+			return err
+		}
+		if apiVersion >= 4 {
+			// aborted_transactions: producer_id, first_offset
+			if err := r.skipArray(func() error { return r.skip(8 + 8) }); err != nil {
+				return err
+			}
+		}
+		if apiVersion >= 11 {
+			// preferred_read_replica
+			if err := r.skip(4); err != nil {
+				return err
+			}
+		}
+		return r.skipBytes()
+	})
+}
+
+func (r *nameRewriter) listOffsetsResponse(apiVersion int16) error {
+	if apiVersion >= 2 {
+		// throttle_time_ms
+		if err := r.skip(4); err != nil {
+			return err
+		}
+	}
+	return r.topics(func() error {
+		// partition_index, error_code
+		if err := r.skip(4 + 2); err != nil {
+			return err
+		}
+		if apiVersion == 0 {
+			// old_style_offsets
+			return r.skipArray(func() error { return r.skip(8) })
+		}
+		// timestamp, offset
+		partitionSize := 8 + 8
+		if apiVersion >= 4 {
+			// leader_epoch
+			partitionSize += 4
+		}
+		return r.skip(partitionSize)
+	})
+}
+
+func (r *nameRewriter) metadataResponse(apiVersion int16) error {
+	if apiVersion >= 3 {
+		// throttle_time_ms
+		if err := r.skip(4); err != nil {
+			return err
+		}
+	}
+	if err := r.skipArray(func() error {
+		// node_id, host, port
+		if err := r.skip(4); err != nil {
+			return err
+		}
+		if err := r.skipString(); err != nil {
+			return err
+		}
+		if err := r.skip(4); err != nil {
+			return err
+		}
+		if apiVersion >= 1 {
+			// rack
+			return r.skipString()
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if apiVersion >= 2 {
+		// cluster_id
+		if err := r.skipString(); err != nil {
+			return err
+		}
+	}
+	if apiVersion >= 1 {
+		// controller_id
+		if err := r.skip(4); err != nil {
+			return err
+		}
+	}
+	// the cluster authorized operations follow the topics
+	return r.array(func() (bool, error) {
+		// error_code
+		if err := r.skip(2); err != nil {
+			return false, err
+		}
+		keep, err := r.name()
+		if err != nil {
+			return false, err
+		}
+		if apiVersion >= 1 {
+			// is_internal
+			if err = r.skip(1); err != nil {
+				return false, err
+			}
+		}
+		if err = r.skipArray(func() error {
+			// error_code, partition_index, leader_id
+			partitionSize := 2 + 4 + 4
+			if apiVersion >= 7 {
+				// leader_epoch
+				partitionSize += 4
+			}
+			if err := r.skip(partitionSize); err != nil {
+				return err
+			}
+			// replica_nodes, isr_nodes
+			arrays := 2
+			if apiVersion >= 5 {
+				// offline_replicas
+				arrays++
+			}
+			for i := 0; i < arrays; i++ {
+				if err := r.skipArray(func() error { return r.skip(4) }); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return false, err
+		}
+		if apiVersion >= 8 {
+			// topic_authorized_operations
+			err = r.skip(4)
+		}
+		return keep, err
+	})
+}
+
+func (r *nameRewriter) offsetCommitResponse(apiVersion int16) error {
+	if apiVersion >= 3 {
+		// throttle_time_ms
+		if err := r.skip(4); err != nil {
+			return err
+		}
+	}
+	// partition_index, error_code
+	return r.topics(func() error { return r.skip(4 + 2) })
+}
+
+func (r *nameRewriter) offsetFetchResponse(apiVersion int16) error {
+	if apiVersion >= 3 {
+		// throttle_time_ms
+		if err := r.skip(4); err != nil {
+			return err
+		}
+	}
+	// the error code follows the topics
+	return r.topics(func() error {
+		// partition_index, committed_offset
+		partitionSize := 4 + 8
+		if apiVersion >= 5 {
+			// committed_leader_epoch
+			partitionSize += 4
+		}
+		if err := r.skip(partitionSize); err != nil {
+			return err
+		}
+		// metadata, error_code
+		if err := r.skipString(); err != nil {
+			return err
+		}
+		return r.skip(2)
+	})
+}
+
+func (r *nameRewriter) offsetForLeaderEpochResponse(apiVersion int16) error {
+	if apiVersion >= 2 {
+		// throttle_time_ms
+		if err := r.skip(4); err != nil {
+			return err
+		}
+	}
+	// error_code, partition, end_offset
+	partitionSize := 2 + 4 + 8
+	if apiVersion >= 1 {
+		// leader_epoch
+		partitionSize += 4
+	}
+	return r.topics(func() error { return r.skip(partitionSize) })
+}
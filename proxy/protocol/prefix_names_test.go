@@ -0,0 +1,212 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixProduceRequestNames(t *testing.T) {
+	a := assert.New(t)
+
+	transactionalID := "tx-1"
+	clientID := "producer"
+	request := func(prefix string) []byte {
+		body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+			pe.putInt32(7)
+			_ = pe.putNullableString(&clientID)
+			id := prefix + transactionalID
+			_ = pe.putNullableString(&id)
+			pe.putInt16(-1)
+			pe.putInt32(30000)
+			_ = pe.putArrayLength(2)
+			for _, topic := range []string{"orders", "payments"} {
+				_ = pe.putString(prefix + topic)
+				_ = pe.putArrayLength(1)
+				pe.putInt32(0)
+				_ = pe.putBytes([]byte{1, 2, 3})
+			}
+			return nil
+		}))
+		a.Nil(err)
+		return body
+	}
+
+	result, err := PrefixRequestNames(apiKeyProduce, 3, request(""), "tenant-a.")
+	a.Nil(err)
+	a.Equal(request("tenant-a."), result)
+
+	_, err = PrefixRequestNames(apiKeyProduce, 9, request(""), "tenant-a.")
+	a.NotNil(err)
+	_, err = PrefixRequestNames(19, 0, request(""), "tenant-a.")
+	a.NotNil(err)
+}
+
+func TestPrefixFetchRequestNames(t *testing.T) {
+	a := assert.New(t)
+
+	request := func(prefix string) []byte {
+		body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+			pe.putInt32(7)
+			_ = pe.putNullableString(nil)
+			// replica_id, max_wait_ms, min_bytes, max_bytes, isolation_level, session_id, session_epoch
+			pe.putInt32(-1)
+			pe.putInt32(500)
+			pe.putInt32(1)
+			pe.putInt32(1 << 20)
+			pe.putInt8(0)
+			pe.putInt32(0)
+			pe.putInt32(-1)
+			_ = pe.putArrayLength(1)
+			_ = pe.putString(prefix + "orders")
+			_ = pe.putArrayLength(1)
+			pe.putInt32(0)
+			pe.putInt32(5)
+			pe.putInt64(100)
+			pe.putInt64(0)
+			pe.putInt32(1 << 20)
+			_ = pe.putArrayLength(1)
+			_ = pe.putString(prefix + "payments")
+			_ = pe.putArrayLength(2)
+			pe.putInt32(0)
+			pe.putInt32(1)
+			return pe.putString("rack-1")
+		}))
+		a.Nil(err)
+		return body
+	}
+
+	result, err := PrefixRequestNames(apiKeyFetch, 11, request(""), "tenant-a.")
+	a.Nil(err)
+	a.Equal(request("tenant-a."), result)
+}
+
+func TestPrefixGroupRequestNames(t *testing.T) {
+	a := assert.New(t)
+
+	request := func(group string) []byte {
+		body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+			pe.putInt32(7)
+			_ = pe.putNullableString(nil)
+			_ = pe.putString(group)
+			// generation_id, member_id
+			pe.putInt32(3)
+			return pe.putString("member-1")
+		}))
+		a.Nil(err)
+		return body
+	}
+
+	result, err := PrefixRequestNames(apiKeyHeartbeat, 2, request("billing"), "tenant-a.")
+	a.Nil(err)
+	a.Equal(request("tenant-a.billing"), result)
+}
+
+func TestUnprefixMetadataResponseNames(t *testing.T) {
+	a := assert.New(t)
+
+	response := func(topics ...string) []byte {
+		body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+			pe.putInt32(0)
+			_ = pe.putArrayLength(1)
+			pe.putInt32(1)
+			_ = pe.putString("kafka-1")
+			pe.putInt32(9092)
+			_ = pe.putNullableString(nil)
+			clusterID := "cluster"
+			_ = pe.putNullableString(&clusterID)
+			pe.putInt32(1)
+			_ = pe.putArrayLength(len(topics))
+			for _, topic := range topics {
+				pe.putInt16(0)
+				_ = pe.putString(topic)
+				pe.putBool(false)
+				_ = pe.putArrayLength(1)
+				pe.putInt16(0)
+				pe.putInt32(0)
+				pe.putInt32(1)
+				pe.putInt32(4)
+				_ = pe.putInt32Array([]int32{1})
+				_ = pe.putInt32Array([]int32{1})
+				_ = pe.putInt32Array([]int32{})
+				pe.putInt32(-2147483648)
+			}
+			pe.putInt32(-2147483648)
+			return nil
+		}))
+		a.Nil(err)
+		return body
+	}
+
+	result, err := UnprefixResponseNames(apiKeyMetadata, 8, response("tenant-a.orders", "tenant-b.orders", "tenant-a.payments"), "tenant-a.")
+	a.Nil(err)
+	a.Equal(response("orders", "payments"), result)
+
+	result, err = UnprefixResponseNames(apiKeyMetadata, 8, response("tenant-b.orders"), "tenant-a.")
+	a.Nil(err)
+	a.Equal(response(), result)
+}
+
+func TestUnprefixFetchResponseNames(t *testing.T) {
+	a := assert.New(t)
+
+	response := func(prefix string) []byte {
+		body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+			// throttle_time_ms, error_code, session_id
+			pe.putInt32(0)
+			pe.putInt16(0)
+			pe.putInt32(0)
+			_ = pe.putArrayLength(1)
+			_ = pe.putString(prefix + "orders")
+			_ = pe.putArrayLength(1)
+			pe.putInt32(0)
+			pe.putInt16(0)
+			pe.putInt64(200)
+			pe.putInt64(200)
+			pe.putInt64(0)
+			_ = pe.putArrayLength(-1)
+			pe.putInt32(-1)
+			return pe.putBytes([]byte{1, 2, 3, 4})
+		}))
+		a.Nil(err)
+		return body
+	}
+
+	result, err := UnprefixResponseNames(apiKeyFetch, 11, response("tenant-a."), "tenant-a.")
+	a.Nil(err)
+	a.Equal(response(""), result)
+
+	// responses without names are not modified
+	body := []byte{0, 0, 0, 0, 0, 0}
+	result, err = UnprefixResponseNames(apiKeyHeartbeat, 2, body, "tenant-a.")
+	a.Nil(err)
+	a.Equal(body, result)
+}
+
+func TestUnprefixOffsetFetchResponseNames(t *testing.T) {
+	a := assert.New(t)
+
+	response := func(topics ...string) []byte {
+		body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+			pe.putInt32(0)
+			_ = pe.putArrayLength(len(topics))
+			for _, topic := range topics {
+				_ = pe.putString(topic)
+				_ = pe.putArrayLength(1)
+				pe.putInt32(0)
+				pe.putInt64(42)
+				pe.putInt32(-1)
+				_ = pe.putNullableString(nil)
+				pe.putInt16(0)
+			}
+			pe.putInt16(0)
+			return nil
+		}))
+		a.Nil(err)
+		return body
+	}
+
+	result, err := UnprefixResponseNames(apiKeyOffsetFetch, 5, response("tenant-a.orders", "orders"), "tenant-a.")
+	a.Nil(err)
+	a.Equal(response("orders"), result)
+}
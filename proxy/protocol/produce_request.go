@@ -0,0 +1,400 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ProduceRequest is a produce request v0-v8 starting after the api key and version of the request header
+type ProduceRequest struct {
+	Version int16
+
+	// request header v1
+	CorrelationID int32
+	ClientID      *string
+
+	TransactionalID *string // v3+
+	Acks            int16
+	Timeout         int32
+	TopicData       []ProduceTopicData
+}
+
+type ProduceTopicData struct {
+	Topic         string
+	PartitionData []ProducePartitionData
+}
+
+type ProducePartitionData struct {
+	Partition int32
+	Records   []byte
+}
+
+func (r *ProduceRequest) decode(pd packetDecoder) (err error) {
+	if r.Version < 0 || r.Version > 8 {
+		return PacketDecodingError{fmt.Sprintf("produce version %d is not supported", r.Version)}
+	}
+	if r.CorrelationID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.ClientID, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	if r.Version >= 3 {
+		if r.TransactionalID, err = pd.getNullableString(); err != nil {
+			return err
+		}
+	}
+	if r.Acks, err = pd.getInt16(); err != nil {
+		return err
+	}
+	if r.Timeout, err = pd.getInt32(); err != nil {
+		return err
+	}
+	topicCount, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.TopicData = make([]ProduceTopicData, 0, topicCount)
+	for i := 0; i < topicCount; i++ {
+		var topicData ProduceTopicData
+		if topicData.Topic, err = pd.getString(); err != nil {
+			return err
+		}
+		partitionCount, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		topicData.PartitionData = make([]ProducePartitionData, 0, partitionCount)
+		for j := 0; j < partitionCount; j++ {
+			var partitionData ProducePartitionData
+			if partitionData.Partition, err = pd.getInt32(); err != nil {
+				return err
+			}
+			if partitionData.Records, err = pd.getBytes(); err != nil {
+				return err
+			}
+			topicData.PartitionData = append(topicData.PartitionData, partitionData)
+		}
+		r.TopicData = append(r.TopicData, topicData)
+	}
+	return nil
+}
+
+func (r *ProduceRequest) encode(pe packetEncoder) (err error) {
+	if r.Version < 0 || r.Version > 8 {
+		return PacketEncodingError{fmt.Sprintf("produce version %d is not supported", r.Version)}
+	}
+	pe.putInt32(r.CorrelationID)
+	if err = pe.putNullableString(r.ClientID); err != nil {
+		return err
+	}
+	if r.Version >= 3 {
+		if err = pe.putNullableString(r.TransactionalID); err != nil {
+			return err
+		}
+	}
+	pe.putInt16(r.Acks)
+	pe.putInt32(r.Timeout)
+	if err = pe.putArrayLength(len(r.TopicData)); err != nil {
+		return err
+	}
+	for _, topicData := range r.TopicData {
+		if err = pe.putString(topicData.Topic); err != nil {
+			return err
+		}
+		if err = pe.putArrayLength(len(topicData.PartitionData)); err != nil {
+			return err
+		}
+		for _, partitionData := range topicData.PartitionData {
+			pe.putInt32(partitionData.Partition)
+			if err = pe.putBytes(partitionData.Records); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	// offset (INT64) + length (INT32)
+	logOverhead = 8 + 4
+	// offset of magic in record batch and legacy message: offset (INT64), length (INT32), partition_leader_epoch / crc (INT32)
+	magicOffset = logOverhead + 4
+	// offset of records count in record batch (magic v2)
+	recordsCountOffset = 57
+)
+
+// CountRecords returns the number of records in the record batches (magic v2) or message set (magic v0 and v1).
+// A compressed legacy message is counted as one record.
+func CountRecords(records []byte) (int, error) {
+	count := 0
+	for len(records) > 0 {
+		if len(records) < logOverhead {
+			return count, ErrInsufficientData
+		}
+		length := int(int32(binary.BigEndian.Uint32(records[8:logOverhead])))
+		if length <= magicOffset-logOverhead {
+			return count, PacketDecodingError{fmt.Sprintf("invalid records length %d", length)}
+		}
+		if len(records) < logOverhead+length {
+			// a partial trailing message is allowed in message set
+			return count, nil
+		}
+		switch magic := records[magicOffset]; magic {
+		case 0, 1:
+			count++
+		case 2:
+			if length+logOverhead < recordsCountOffset+4 {
+				return count, ErrInsufficientData
+			}
+			count += int(int32(binary.BigEndian.Uint32(records[recordsCountOffset : recordsCountOffset+4])))
+		default:
+			return count, PacketDecodingError{fmt.Sprintf("unknown records magic %d", magic)}
+		}
+		records = records[logOverhead+length:]
+	}
+	return count, nil
+}
+
+// MaxBatchSize returns the size of the largest record batch (magic v2) or message (magic v0 and v1) including the log overhead
+func MaxBatchSize(records []byte) (int, error) {
+	maxSize := 0
+	for len(records) > 0 {
+		if len(records) < logOverhead {
+			return maxSize, ErrInsufficientData
+		}
+		length := int(int32(binary.BigEndian.Uint32(records[8:logOverhead])))
+		if length < 0 {
+			return maxSize, PacketDecodingError{fmt.Sprintf("invalid records length %d", length)}
+		}
+		if size := logOverhead + length; size > maxSize {
+			maxSize = size
+		}
+		if len(records) < logOverhead+length {
+			// a partial trailing message is allowed in message set
+			return maxSize, nil
+		}
+		records = records[logOverhead+length:]
+	}
+	return maxSize, nil
+}
+
+// DiscardProduceRequest reads the produce request body of the given length starting after the api key and version.
+// The records are discarded instead of being buffered, so the returned request has no partition records.
+func DiscardProduceRequest(reader io.Reader, apiVersion int16, length int32) (*ProduceRequest, error) {
+	if apiVersion < 0 || apiVersion > 8 {
+		return nil, PacketDecodingError{fmt.Sprintf("produce version %d is not supported", apiVersion)}
+	}
+	limited := &io.LimitedReader{R: reader, N: int64(length)}
+	r := produceDiscardReader{reader: limited}
+	request := &ProduceRequest{Version: apiVersion}
+	request.CorrelationID = r.getInt32()
+	request.ClientID = r.getNullableString()
+	if apiVersion >= 3 {
+		request.TransactionalID = r.getNullableString()
+	}
+	request.Acks = r.getInt16()
+	request.Timeout = r.getInt32()
+	topicCount := r.getArrayLength()
+	for i := 0; i < topicCount && r.err == nil; i++ {
+		topicData := ProduceTopicData{Topic: r.getString()}
+		partitionCount := r.getArrayLength()
+		for j := 0; j < partitionCount && r.err == nil; j++ {
+			partitionData := ProducePartitionData{Partition: r.getInt32()}
+			r.discardBytes()
+			topicData.PartitionData = append(topicData.PartitionData, partitionData)
+		}
+		request.TopicData = append(request.TopicData, topicData)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	// tagged fields or other trailing bytes are not forwarded
+	if _, err := io.Copy(ioutil.Discard, limited); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// CountProduceRequestRecords reads the produce request body of the given length starting after the api key and version and
+// passes the number of records and the records size of each topic to observe. The records are read without being buffered.
+func CountProduceRequestRecords(reader io.Reader, apiVersion int16, length int32, observe func(topic string, records int64, size int64)) error {
+	if apiVersion < 0 || apiVersion > 8 {
+		return PacketDecodingError{fmt.Sprintf("produce version %d is not supported", apiVersion)}
+	}
+	limited := &io.LimitedReader{R: reader, N: int64(length)}
+	r := produceDiscardReader{reader: limited}
+	// correlation_id, client_id
+	r.getInt32()
+	r.getNullableString()
+	if apiVersion >= 3 {
+		// transactional_id
+		r.getNullableString()
+	}
+	// acks, timeout_ms
+	r.getInt16()
+	r.getInt32()
+	topicCount := r.getArrayLength()
+	for i := 0; i < topicCount && r.err == nil; i++ {
+		topic := r.getString()
+		var records, size int64
+		partitionCount := r.getArrayLength()
+		for j := 0; j < partitionCount && r.err == nil; j++ {
+			// partition_index
+			r.getInt32()
+			count, n := r.countRecords()
+			records += count
+			size += n
+		}
+		if r.err == nil {
+			observe(topic, records, size)
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	_, err := io.Copy(ioutil.Discard, limited)
+	return err
+}
+
+// produceDiscardReader reads the produce request fields from a stream, the first error stops further reads
+type produceDiscardReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *produceDiscardReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	buf := make([]byte, n)
+	if _, r.err = io.ReadFull(r.reader, buf); r.err != nil {
+		return nil
+	}
+	return buf
+}
+
+func (r *produceDiscardReader) getInt16() int16 {
+	if buf := r.read(2); buf != nil {
+		return int16(binary.BigEndian.Uint16(buf))
+	}
+	return 0
+}
+
+func (r *produceDiscardReader) getInt8() int8 {
+	if buf := r.read(1); buf != nil {
+		return int8(buf[0])
+	}
+	return 0
+}
+
+func (r *produceDiscardReader) getInt64() int64 {
+	if buf := r.read(8); buf != nil {
+		return int64(binary.BigEndian.Uint64(buf))
+	}
+	return 0
+}
+
+func (r *produceDiscardReader) getInt32() int32 {
+	if buf := r.read(4); buf != nil {
+		return int32(binary.BigEndian.Uint32(buf))
+	}
+	return 0
+}
+
+func (r *produceDiscardReader) getArrayLength() int {
+	length := r.getInt32()
+	if r.err == nil && length < -1 {
+		r.err = errInvalidArrayLength
+	}
+	return int(length)
+}
+
+func (r *produceDiscardReader) getNullableString() *string {
+	length := r.getInt16()
+	if r.err != nil || length == -1 {
+		return nil
+	}
+	if length < -1 {
+		r.err = errInvalidStringLength
+		return nil
+	}
+	value := string(r.read(int(length)))
+	return &value
+}
+
+func (r *produceDiscardReader) getString() string {
+	value := r.getNullableString()
+	if value == nil {
+		if r.err == nil {
+			r.err = errInvalidStringLength
+		}
+		return ""
+	}
+	return *value
+}
+
+// countRecords reads the records of a partition and returns the number of records in the record batches (magic v2) and
+// messages (magic v0 and v1) and the records size
+func (r *produceDiscardReader) countRecords() (count int64, size int64) {
+	length := r.getInt32()
+	if r.err != nil || length <= 0 {
+		return 0, 0
+	}
+	reader := r.reader
+	defer func() { r.reader = reader }()
+	records := &io.LimitedReader{R: reader, N: int64(length)}
+	r.reader = records
+	for r.err == nil && records.N >= logOverhead {
+		// base_offset, batch_length
+		r.getInt64()
+		batchLength := int64(r.getInt32())
+		if r.err != nil {
+			break
+		}
+		if batchLength <= magicOffset-logOverhead {
+			r.err = PacketDecodingError{fmt.Sprintf("invalid records length %d", batchLength)}
+			break
+		}
+		if records.N < batchLength {
+			// a partial trailing message is allowed in message set
+			break
+		}
+		// partition_leader_epoch or crc, magic
+		r.read(magicOffset - logOverhead)
+		magic := r.getInt8()
+		read := int64(magicOffset + 1 - logOverhead)
+		switch magic {
+		case 0, 1:
+			count++
+		case 2:
+			if batchLength < recordsCountOffset+4-logOverhead {
+				r.err = ErrInsufficientData
+				break
+			}
+			// attributes, last_offset_delta, timestamps, producer id and epoch, base_sequence
+			r.read(recordsCountOffset - magicOffset - 1)
+			count += int64(r.getInt32())
+			read = recordsCountOffset + 4 - logOverhead
+		default:
+			r.err = PacketDecodingError{fmt.Sprintf("unknown records magic %d", magic)}
+		}
+		if r.err == nil {
+			_, r.err = io.CopyN(ioutil.Discard, records, batchLength-read)
+		}
+	}
+	if r.err == nil {
+		_, r.err = io.Copy(ioutil.Discard, records)
+	}
+	return count, int64(length)
+}
+
+func (r *produceDiscardReader) discardBytes() {
+	length := r.getInt32()
+	if r.err != nil || length <= 0 {
+		return
+	}
+	_, r.err = io.CopyN(ioutil.Discard, r.reader, int64(length))
+}
@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordBatch returns a record batch (magic v2) header with the given records count and payload size
+func recordBatch(count int32, payload int) []byte {
+	batch := make([]byte, recordsCountOffset+4+payload)
+	binary.BigEndian.PutUint32(batch[8:12], uint32(len(batch)-logOverhead))
+	batch[magicOffset] = 2
+	binary.BigEndian.PutUint32(batch[recordsCountOffset:], uint32(count))
+	return batch
+}
+
+// legacyMessage returns a message set entry (magic v0 or v1) with the given value size
+func legacyMessage(magic byte, value int) []byte {
+	message := make([]byte, magicOffset+1+value)
+	binary.BigEndian.PutUint32(message[8:12], uint32(len(message)-logOverhead))
+	message[magicOffset] = magic
+	return message
+}
+
+func TestCountRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []byte
+		count   int
+		err     bool
+	}{
+		{name: "empty", records: nil, count: 0},
+		{name: "one batch", records: recordBatch(3, 10), count: 3},
+		{name: "two batches", records: append(recordBatch(3, 10), recordBatch(5, 0)...), count: 8},
+		{name: "message set", records: append(legacyMessage(0, 5), legacyMessage(1, 7)...), count: 2},
+		{name: "partial trailing message", records: append(legacyMessage(1, 5), legacyMessage(1, 7)[:15]...), count: 1},
+		{name: "truncated header", records: []byte{0, 0, 0}, err: true},
+		{name: "unknown magic", records: legacyMessage(7, 1), err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			count, err := CountRecords(tt.records)
+			if tt.err {
+				a.NotNil(err)
+				return
+			}
+			a.Nil(err)
+			a.Equal(tt.count, count)
+		})
+	}
+}
+
+func TestEncodeDecodeProduceRequest(t *testing.T) {
+	clientID := "producer"
+	transactionalID := "txn"
+	for _, version := range []int16{0, 3, 8} {
+		a := assert.New(t)
+
+		request := &ProduceRequest{
+			Version:       version,
+			CorrelationID: 7,
+			ClientID:      &clientID,
+			Acks:          -1,
+			Timeout:       30000,
+			TopicData: []ProduceTopicData{
+				{Topic: "orders", PartitionData: []ProducePartitionData{
+					{Partition: 0, Records: recordBatch(2, 4)},
+					{Partition: 1, Records: nil},
+				}},
+			},
+		}
+		if version >= 3 {
+			request.TransactionalID = &transactionalID
+		}
+		buf, err := Encode(request)
+		a.Nil(err)
+
+		decoded := &ProduceRequest{Version: version}
+		a.Nil(Decode(buf, decoded))
+		a.Equal(request, decoded)
+	}
+
+	_, err := Encode(&ProduceRequest{Version: 9})
+	assert.NotNil(t, err)
+}
+
+func TestMaxBatchSize(t *testing.T) {
+	a := assert.New(t)
+
+	size, err := MaxBatchSize(append(recordBatch(3, 10), recordBatch(5, 100)...))
+	a.Nil(err)
+	a.Equal(recordsCountOffset+4+100, size)
+
+	size, err = MaxBatchSize(nil)
+	a.Nil(err)
+	a.Equal(0, size)
+
+	_, err = MaxBatchSize([]byte{0, 0, 0})
+	a.NotNil(err)
+}
+
+func TestDiscardProduceRequest(t *testing.T) {
+	clientID := "producer"
+	transactionalID := "txn"
+	for _, version := range []int16{0, 3, 8} {
+		a := assert.New(t)
+
+		request := &ProduceRequest{
+			Version:       version,
+			CorrelationID: 7,
+			ClientID:      &clientID,
+			Acks:          1,
+			Timeout:       30000,
+			TopicData: []ProduceTopicData{
+				{Topic: "orders", PartitionData: []ProducePartitionData{
+					{Partition: 0, Records: recordBatch(2, 4)},
+					{Partition: 1, Records: nil},
+				}},
+				{Topic: "payments", PartitionData: []ProducePartitionData{{Partition: 2, Records: recordBatch(1, 100)}}},
+			},
+		}
+		if version >= 3 {
+			request.TransactionalID = &transactionalID
+		}
+		buf, err := Encode(request)
+		a.Nil(err)
+
+		// the bytes following the request are not read
+		reader := bytes.NewReader(append(buf, 1, 2, 3))
+		discarded, err := DiscardProduceRequest(reader, version, int32(len(buf)))
+		a.Nil(err)
+		a.Equal(3, reader.Len())
+
+		a.Equal(request.CorrelationID, discarded.CorrelationID)
+		a.Equal(request.TransactionalID, discarded.TransactionalID)
+		a.Equal(request.Acks, discarded.Acks)
+		a.Len(discarded.TopicData, 2)
+		a.Equal([]ProducePartitionData{{Partition: 0}, {Partition: 1}}, discarded.TopicData[0].PartitionData)
+		a.Equal("payments", discarded.TopicData[1].Topic)
+
+		_, err = DiscardProduceRequest(bytes.NewReader(buf[:len(buf)-10]), version, int32(len(buf)))
+		a.NotNil(err)
+	}
+}
+
+func TestCountProduceRequestRecords(t *testing.T) {
+	a := assert.New(t)
+
+	records := append(recordBatch(2, 4), recordBatch(3, 0)...)
+	legacy := append(append(legacyMessage(0, 5), legacyMessage(1, 3)...), 0, 0, 0)
+	request := &ProduceRequest{
+		Version: 3,
+		Acks:    1,
+		Timeout: 30000,
+		TopicData: []ProduceTopicData{
+			{Topic: "orders", PartitionData: []ProducePartitionData{
+				{Partition: 0, Records: records},
+				{Partition: 1, Records: nil},
+			}},
+			{Topic: "payments", PartitionData: []ProducePartitionData{{Partition: 2, Records: legacy}}},
+		},
+	}
+	buf, err := Encode(request)
+	a.Nil(err)
+
+	type observed struct {
+		records int64
+		size    int64
+	}
+	counts := make(map[string]observed)
+	observe := func(topic string, records int64, size int64) {
+		counts[topic] = observed{records: records, size: size}
+	}
+	// the bytes following the request are not read
+	reader := bytes.NewReader(append(buf, 1, 2, 3))
+	a.Nil(CountProduceRequestRecords(reader, 3, int32(len(buf)), observe))
+	a.Equal(3, reader.Len())
+	a.Equal(map[string]observed{
+		"orders":   {records: 5, size: int64(len(records))},
+		"payments": {records: 2, size: int64(len(legacy))},
+	}, counts)
+
+	a.NotNil(CountProduceRequestRecords(bytes.NewReader(buf[:len(buf)-10]), 3, int32(len(buf)), observe))
+	a.NotNil(CountProduceRequestRecords(bytes.NewReader(buf), 9, int32(len(buf)), observe))
+}
@@ -0,0 +1,94 @@
+package protocol
+
+import "fmt"
+
+// ProducePartitionError is a partition response error of the produce response
+type ProducePartitionError struct {
+	Topic        string
+	Partition    int32
+	ErrorCode    KError
+	ErrorMessage *string // v8+
+}
+
+// producePartitionErrorsResponse prepends the partition errors to the topic responses of the response body
+type producePartitionErrorsResponse struct {
+	version int16
+	errors  []ProducePartitionError
+	body    []byte
+}
+
+func (r *producePartitionErrorsResponse) encode(pe packetEncoder) (err error) {
+	rd := &realDecoder{raw: r.body}
+	count, err := rd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	// partitions of the same topic are grouped in one topic response
+	topics := make([]string, 0)
+	partitions := make(map[string][]ProducePartitionError)
+	for _, partitionError := range r.errors {
+		if _, ok := partitions[partitionError.Topic]; !ok {
+			topics = append(topics, partitionError.Topic)
+		}
+		partitions[partitionError.Topic] = append(partitions[partitionError.Topic], partitionError)
+	}
+	if err = pe.putArrayLength(count + len(topics)); err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		if err = pe.putString(topic); err != nil {
+			return err
+		}
+		if err = pe.putArrayLength(len(partitions[topic])); err != nil {
+			return err
+		}
+		for _, partitionError := range partitions[topic] {
+			if err = r.encodePartitionError(pe, partitionError); err != nil {
+				return err
+			}
+		}
+	}
+	// the remaining topic responses and the throttle time are copied unchanged
+	rest, err := rd.getRawBytes(rd.remaining())
+	if err != nil {
+		return err
+	}
+	return pe.putRawBytes(rest)
+}
+
+func (r *producePartitionErrorsResponse) encodePartitionError(pe packetEncoder, partitionError ProducePartitionError) (err error) {
+	pe.putInt32(partitionError.Partition)
+	pe.putInt16(int16(partitionError.ErrorCode))
+	// base_offset
+	pe.putInt64(-1)
+	if r.version >= 2 {
+		// log_append_time_ms
+		pe.putInt64(-1)
+	}
+	if r.version >= 5 {
+		// log_start_offset
+		pe.putInt64(-1)
+	}
+	if r.version >= 8 {
+		// record_errors
+		if err = pe.putArrayLength(0); err != nil {
+			return err
+		}
+		if err = pe.putNullableString(partitionError.ErrorMessage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddProducePartitionErrors prepends the partition errors to the topic responses of the produce response (v0-v8) body
+// following the response header
+func AddProducePartitionErrors(apiVersion int16, body []byte, errors []ProducePartitionError) ([]byte, error) {
+	if apiVersion < 0 || apiVersion > 8 {
+		return nil, PacketEncodingError{fmt.Sprintf("partition errors for produce version %d are not supported", apiVersion)}
+	}
+	if len(errors) == 0 {
+		return body, nil
+	}
+	return Encode(&producePartitionErrorsResponse{version: apiVersion, errors: errors, body: body})
+}
@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddProducePartitionErrors(t *testing.T) {
+	for _, version := range []int16{0, 2, 5, 8} {
+		a := assert.New(t)
+
+		body, err := Encode(encoderFunc(func(pe packetEncoder) error {
+			_ = pe.putArrayLength(1)
+			_ = pe.putString("orders")
+			_ = pe.putArrayLength(1)
+			pe.putInt32(0)
+			pe.putInt16(0)
+			pe.putInt64(42)
+			if version >= 2 {
+				pe.putInt64(-1)
+			}
+			if version >= 5 {
+				pe.putInt64(0)
+			}
+			if version >= 8 {
+				_ = pe.putArrayLength(0)
+				_ = pe.putNullableString(nil)
+			}
+			if version >= 1 {
+				pe.putInt32(100) // throttle time
+			}
+			return nil
+		}))
+		a.Nil(err)
+
+		message := "too large"
+		result, err := AddProducePartitionErrors(version, body, []ProducePartitionError{
+			{Topic: "payments", Partition: 1, ErrorCode: ErrMessageSizeTooLarge, ErrorMessage: &message},
+			{Topic: "payments", Partition: 2, ErrorCode: ErrMessageSizeTooLarge, ErrorMessage: &message},
+		})
+		a.Nil(err)
+
+		a.Nil(Decode(result, decoderFunc(func(pd packetDecoder) error {
+			count, _ := pd.getArrayLength()
+			a.Equal(2, count)
+			topic, _ := pd.getString()
+			a.Equal("payments", topic)
+			partitions, _ := pd.getArrayLength()
+			a.Equal(2, partitions)
+			for _, expected := range []int32{1, 2} {
+				partition, _ := pd.getInt32()
+				a.Equal(expected, partition)
+				code, _ := pd.getInt16()
+				a.Equal(int16(10), code)
+				baseOffset, _ := pd.getInt64()
+				a.Equal(int64(-1), baseOffset)
+				if version >= 2 {
+					_, _ = pd.getInt64()
+				}
+				if version >= 5 {
+					_, _ = pd.getInt64()
+				}
+				if version >= 8 {
+					recordErrors, _ := pd.getArrayLength()
+					a.Equal(0, recordErrors)
+					msg, _ := pd.getNullableString()
+					a.Equal(&message, msg)
+				}
+			}
+			// the broker topic response follows unchanged
+			rest, err := pd.getRawBytes(pd.remaining())
+			a.Equal(body[4:], rest)
+			return err
+		})))
+	}
+
+	_, err := AddProducePartitionErrors(9, nil, nil)
+	assert.NotNil(t, err)
+}
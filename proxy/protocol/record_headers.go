@@ -0,0 +1,124 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// RecordHeader is a header of a record (magic v2), the value of a null header is nil
+type RecordHeader struct {
+	Key   string
+	Value []byte
+}
+
+// SplitRecords returns the record batches and the legacy messages of the records. A partial trailing batch is dropped.
+func SplitRecords(records []byte) ([][]byte, error) {
+	var entries [][]byte
+	for len(records) >= logOverhead {
+		length := int(int32(binary.BigEndian.Uint32(records[sizeOffset:logOverhead])))
+		if length <= magicOffset-logOverhead {
+			return nil, PacketDecodingError{fmt.Sprintf("invalid records length %d", length)}
+		}
+		if len(records) < logOverhead+length {
+			break
+		}
+		entries = append(entries, records[:logOverhead+length])
+		records = records[logOverhead+length:]
+	}
+	return entries, nil
+}
+
+// DecodeRecordHeaders returns the headers of each record of the record batch (magic v2) returned by SplitRecords.
+// A legacy message (magic v0 and v1) is returned as a single record without headers, a control batch as no records.
+func DecodeRecordHeaders(entry []byte) ([][]RecordHeader, error) {
+	if len(entry) <= magicOffset {
+		return nil, ErrInsufficientData
+	}
+	switch magic := entry[magicOffset]; magic {
+	case 0, 1:
+		return [][]RecordHeader{nil}, nil
+	case 2:
+	default:
+		return nil, PacketDecodingError{fmt.Sprintf("unknown records magic %d", magic)}
+	}
+	if len(entry) < recordsOffset {
+		return nil, ErrInsufficientData
+	}
+	attributes := binary.BigEndian.Uint16(entry[batchAttributesOffset:])
+	if attributes&controlBatchFlag != 0 {
+		return nil, nil
+	}
+	count := int(int32(binary.BigEndian.Uint32(entry[recordsCountOffset:recordsOffset])))
+	data, err := decompress(int(attributes&compressionCodecMask), entry[recordsOffset:])
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 || count > len(data) {
+		return nil, PacketDecodingError{fmt.Sprintf("invalid records count %d", count)}
+	}
+	headers := make([][]RecordHeader, 0, count)
+	for i := 0; i < count; i++ {
+		length, n := binary.Varint(data)
+		if n <= 0 || length < 0 || int(length) > len(data)-n {
+			return nil, PacketDecodingError{"invalid record length"}
+		}
+		recordHeaders, err := decodeRecordHeaders(data[n : n+int(length)])
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, recordHeaders)
+		data = data[n+int(length):]
+	}
+	return headers, nil
+}
+
+// decodeRecordHeaders skips attributes (INT8), timestampDelta (VARINT), offsetDelta (VARINT), key (VARINT BYTES) and value (VARINT BYTES)
+// and decodes the headers: count (VARINT) and key (VARINT STRING) and value (VARINT BYTES) of each header
+func decodeRecordHeaders(record []byte) ([]RecordHeader, error) {
+	if len(record) < 1 {
+		return nil, PacketDecodingError{"invalid record"}
+	}
+	offset := 1
+	for i := 0; i < 2; i++ {
+		_, n := binary.Varint(record[offset:])
+		if n <= 0 {
+			return nil, PacketDecodingError{"invalid record"}
+		}
+		offset += n
+	}
+	var err error
+	for i := 0; i < 2; i++ {
+		if offset, err = varintBytesEnd(record, offset); err != nil {
+			return nil, err
+		}
+	}
+	count, n := binary.Varint(record[offset:])
+	if n <= 0 || count < 0 || int(count) > len(record)-offset-n {
+		return nil, PacketDecodingError{"invalid record headers count"}
+	}
+	offset += n
+	if count == 0 {
+		return nil, nil
+	}
+	headers := make([]RecordHeader, 0, count)
+	for i := 0; i < int(count); i++ {
+		keyLength, n := binary.Varint(record[offset:])
+		if n <= 0 || keyLength < 0 || int(keyLength) > len(record)-offset-n {
+			return nil, PacketDecodingError{"invalid record header key"}
+		}
+		header := RecordHeader{Key: string(record[offset+n : offset+n+int(keyLength)])}
+		offset += n + int(keyLength)
+
+		valueLength, n := binary.Varint(record[offset:])
+		if n <= 0 || valueLength < -1 || int(valueLength) > len(record)-offset-n {
+			return nil, PacketDecodingError{"invalid record header value"}
+		}
+		offset += n
+		if valueLength >= 0 {
+			header.Value = append([]byte{}, record[offset:offset+int(valueLength)]...)
+			offset += int(valueLength)
+		}
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
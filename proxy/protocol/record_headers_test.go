@@ -0,0 +1,82 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildRecordBatchWithHeaders returns a record batch (magic v2) with a record per header list
+func buildRecordBatchWithHeaders(codec int, headers ...[]RecordHeader) []byte {
+	var records []byte
+	for i, recordHeaders := range headers {
+		record := []byte{0}                     // attributes
+		record = appendVarint(record, 0)        // timestampDelta
+		record = appendVarint(record, int64(i)) // offsetDelta
+		record = appendVarint(record, -1)       // key
+		record = appendVarint(record, 1)        // value
+		record = append(record, 'v')
+		record = appendVarint(record, int64(len(recordHeaders)))
+		for _, header := range recordHeaders {
+			record = appendVarint(record, int64(len(header.Key)))
+			record = append(record, header.Key...)
+			if header.Value == nil {
+				record = appendVarint(record, -1)
+			} else {
+				record = appendVarint(record, int64(len(header.Value)))
+				record = append(record, header.Value...)
+			}
+		}
+		records = appendVarint(records, int64(len(record)))
+		records = append(records, record...)
+	}
+	records, _ = compress(codec, records)
+	batch := make([]byte, recordsOffset, recordsOffset+len(records))
+	batch[magicOffset] = 2
+	binary.BigEndian.PutUint16(batch[batchAttributesOffset:], uint16(codec))
+	binary.BigEndian.PutUint32(batch[recordsCountOffset:], uint32(len(headers)))
+	batch = append(batch, records...)
+	binary.BigEndian.PutUint32(batch[sizeOffset:], uint32(len(batch)-logOverhead))
+	binary.BigEndian.PutUint32(batch[batchCrcOffset:], crc32.Checksum(batch[batchAttributesOffset:], castagnoliTable))
+	return batch
+}
+
+func TestDecodeRecordHeaders(t *testing.T) {
+	a := assert.New(t)
+
+	headers := [][]RecordHeader{
+		{{Key: "tenant-id", Value: []byte("acme")}, {Key: "trace", Value: nil}},
+		nil,
+		{{Key: "region", Value: []byte("eu")}},
+	}
+	for _, codec := range []int{compressionNone, compressionGZIP, compressionZSTD} {
+		batch := buildRecordBatchWithHeaders(codec, headers...)
+		partial := buildRecordBatchWithHeaders(codec, headers[0])[:recordsOffset]
+		entries, err := SplitRecords(append(append(batch, buildMessage(1, []byte("a"))...), partial...))
+		a.Nil(err)
+		a.Len(entries, 2, "the partial batch is dropped")
+
+		decoded, err := DecodeRecordHeaders(entries[0])
+		a.Nil(err)
+		a.Equal(headers, decoded)
+
+		decoded, err = DecodeRecordHeaders(entries[1])
+		a.Nil(err)
+		a.Equal([][]RecordHeader{nil}, decoded, "legacy message without headers")
+	}
+}
+
+func TestDecodeRecordHeadersInvalid(t *testing.T) {
+	a := assert.New(t)
+
+	batch := buildRecordBatchWithHeaders(compressionNone, []RecordHeader{{Key: "tenant-id", Value: []byte("acme")}})
+	_, err := DecodeRecordHeaders(batch[:len(batch)-2])
+	a.Error(err)
+
+	batch[batchAttributesOffset+1] |= controlBatchFlag
+	decoded, err := DecodeRecordHeaders(batch)
+	a.Nil(err)
+	a.Empty(decoded)
+}
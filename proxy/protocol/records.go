@@ -0,0 +1,298 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	// offset of the record batch length and the legacy message size
+	sizeOffset = 8
+
+	// record batch (magic v2) offsets
+	batchCrcOffset        = magicOffset + 1
+	batchAttributesOffset = batchCrcOffset + 4
+	batchProducerIDOffset = recordsCountOffset - 14
+	recordsOffset         = recordsCountOffset + 4
+
+	// legacy message (magic v0 and v1) offsets
+	messageCrcOffset        = logOverhead
+	messageAttributesOffset = magicOffset + 1
+
+	compressionCodecMask = 0x07
+	controlBatchFlag     = 0x20
+
+	compressionNone = 0
+	compressionGZIP = 1
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// RecordValueFunc transforms a not null record value
+type RecordValueFunc func(value []byte) ([]byte, error)
+
+// ErrUnsupportedCompression is returned when the records are compressed with a codec which cannot be transformed
+type ErrUnsupportedCompression struct {
+	Codec int
+}
+
+func (e ErrUnsupportedCompression) Error() string {
+	return fmt.Sprintf("records compression codec %d is not supported", e.Codec)
+}
+
+// TransformRecordValues applies fn to the record values of the record batches (magic v2) and the message sets (magic v0 and v1).
+// The checksums and lengths are recalculated, unchanged batches and messages are kept as they are. Null values and control batches are not transformed, a partial trailing batch is kept unchanged.
+// Record batches compressed with any codec and uncompressed legacy messages are supported.
+func TransformRecordValues(records []byte, fn RecordValueFunc) ([]byte, error) {
+	result := make([]byte, 0, len(records))
+	for len(records) > 0 {
+		if len(records) < logOverhead {
+			// a partial trailing batch is allowed in fetch responses
+			return append(result, records...), nil
+		}
+		length := int(int32(binary.BigEndian.Uint32(records[sizeOffset:logOverhead])))
+		if length <= magicOffset-logOverhead {
+			return nil, PacketDecodingError{fmt.Sprintf("invalid records length %d", length)}
+		}
+		if len(records) < logOverhead+length {
+			return append(result, records...), nil
+		}
+		entry := records[:logOverhead+length]
+
+		var (
+			transformed []byte
+			err         error
+		)
+		switch magic := entry[magicOffset]; magic {
+		case 0, 1:
+			transformed, err = transformMessage(entry, magic, fn)
+		case 2:
+			transformed, err = transformRecordBatch(entry, fn)
+		default:
+			return nil, PacketDecodingError{fmt.Sprintf("unknown records magic %d", magic)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, transformed...)
+		records = records[logOverhead+length:]
+	}
+	return result, nil
+}
+
+// VisitRecordValues calls fn with the not null record values of the records supported by TransformRecordValues
+func VisitRecordValues(records []byte, fn func(value []byte) error) error {
+	_, err := TransformRecordValues(records, func(value []byte) ([]byte, error) {
+		return value, fn(value)
+	})
+	return err
+}
+
+// ClearProducerIDs returns the records with the producer id, producer epoch and base sequence of the idempotent record batches
+// (magic v2) reset to -1 and the number of cleared batches. Legacy messages and a partial trailing batch are kept unchanged.
+func ClearProducerIDs(records []byte) ([]byte, int, error) {
+	result := make([]byte, 0, len(records))
+	cleared := 0
+	for len(records) > 0 {
+		if len(records) < logOverhead {
+			return append(result, records...), cleared, nil
+		}
+		length := int(int32(binary.BigEndian.Uint32(records[sizeOffset:logOverhead])))
+		if length <= magicOffset-logOverhead {
+			return nil, 0, PacketDecodingError{fmt.Sprintf("invalid records length %d", length)}
+		}
+		if len(records) < logOverhead+length {
+			return append(result, records...), cleared, nil
+		}
+		entry := records[:logOverhead+length]
+		records = records[logOverhead+length:]
+
+		if entry[magicOffset] != 2 {
+			result = append(result, entry...)
+			continue
+		}
+		if len(entry) < recordsOffset {
+			return nil, 0, ErrInsufficientData
+		}
+		if int64(binary.BigEndian.Uint64(entry[batchProducerIDOffset:])) == -1 {
+			result = append(result, entry...)
+			continue
+		}
+		start := len(result)
+		result = append(result, entry...)
+		batch := result[start:]
+		// producer id (int64), producer epoch (int16) and base sequence (int32)
+		for i := batchProducerIDOffset; i < recordsCountOffset; i++ {
+			batch[i] = 0xff
+		}
+		binary.BigEndian.PutUint32(batch[batchCrcOffset:], crc32.Checksum(batch[batchAttributesOffset:], castagnoliTable))
+		cleared++
+	}
+	return result, cleared, nil
+}
+
+func transformRecordBatch(batch []byte, fn RecordValueFunc) ([]byte, error) {
+	if len(batch) < recordsOffset {
+		return nil, ErrInsufficientData
+	}
+	attributes := binary.BigEndian.Uint16(batch[batchAttributesOffset:])
+	if attributes&controlBatchFlag != 0 {
+		return batch, nil
+	}
+	codec := int(attributes & compressionCodecMask)
+	count := int(int32(binary.BigEndian.Uint32(batch[recordsCountOffset:recordsOffset])))
+
+	data, err := decompress(codec, batch[recordsOffset:])
+	if err != nil {
+		return nil, err
+	}
+
+	transformed := make([]byte, 0, len(data))
+	changed := false
+	for i := 0; i < count; i++ {
+		length, n := binary.Varint(data)
+		if n <= 0 || length < 0 || int(length) > len(data)-n {
+			return nil, PacketDecodingError{"invalid record length"}
+		}
+		record, err := transformRecord(data[n:n+int(length)], fn)
+		if err != nil {
+			return nil, err
+		}
+		changed = changed || !bytes.Equal(record, data[n:n+int(length)])
+		transformed = appendVarint(transformed, int64(len(record)))
+		transformed = append(transformed, record...)
+		data = data[n+int(length):]
+	}
+	if !changed {
+		// the batch is not compressed again
+		return batch, nil
+	}
+
+	if transformed, err = compress(codec, transformed); err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, recordsOffset, recordsOffset+len(transformed))
+	copy(result, batch[:recordsOffset])
+	result = append(result, transformed...)
+	binary.BigEndian.PutUint32(result[sizeOffset:], uint32(len(result)-logOverhead))
+	binary.BigEndian.PutUint32(result[batchCrcOffset:], crc32.Checksum(result[batchAttributesOffset:], castagnoliTable))
+	return result, nil
+}
+
+// transformRecord transforms the value of the record: attributes (INT8), timestampDelta (VARINT), offsetDelta (VARINT), key (VARINT BYTES), value (VARINT BYTES), headers
+func transformRecord(record []byte, fn RecordValueFunc) ([]byte, error) {
+	if len(record) < 1 {
+		return nil, PacketDecodingError{"invalid record"}
+	}
+	offset := 1
+	for i := 0; i < 2; i++ {
+		_, n := binary.Varint(record[offset:])
+		if n <= 0 {
+			return nil, PacketDecodingError{"invalid record"}
+		}
+		offset += n
+	}
+	keyEnd, err := varintBytesEnd(record, offset)
+	if err != nil {
+		return nil, err
+	}
+	valueLength, n := binary.Varint(record[keyEnd:])
+	if n <= 0 || valueLength < -1 || int(valueLength) > len(record)-keyEnd-n {
+		return nil, PacketDecodingError{"invalid record value"}
+	}
+	if valueLength < 0 {
+		return record, nil
+	}
+	valueStart := keyEnd + n
+	valueEnd := valueStart + int(valueLength)
+
+	value, err := fn(record[valueStart:valueEnd])
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(value, record[valueStart:valueEnd]) {
+		return record, nil
+	}
+	result := make([]byte, 0, len(record)-int(valueLength)+len(value)+binary.MaxVarintLen64)
+	result = append(result, record[:keyEnd]...)
+	result = appendVarint(result, int64(len(value)))
+	result = append(result, value...)
+	result = append(result, record[valueEnd:]...)
+	return result, nil
+}
+
+func varintBytesEnd(record []byte, offset int) (int, error) {
+	length, n := binary.Varint(record[offset:])
+	if n <= 0 || length < -1 || int(length) > len(record)-offset-n {
+		return 0, PacketDecodingError{"invalid record key"}
+	}
+	if length < 0 {
+		return offset + n, nil
+	}
+	return offset + n + int(length), nil
+}
+
+// transformMessage transforms the value of the legacy message: offset (INT64), size (INT32), crc (INT32), magic (INT8), attributes (INT8), timestamp (INT64) v1 only, key (BYTES), value (BYTES)
+func transformMessage(message []byte, magic byte, fn RecordValueFunc) ([]byte, error) {
+	if len(message) <= messageAttributesOffset {
+		return nil, ErrInsufficientData
+	}
+	if codec := int(message[messageAttributesOffset] & compressionCodecMask); codec != compressionNone {
+		return nil, ErrUnsupportedCompression{Codec: codec}
+	}
+	offset := messageAttributesOffset + 1
+	if magic == 1 {
+		offset += 8
+	}
+	keyEnd, err := bytesEnd(message, offset)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) < keyEnd+4 {
+		return nil, ErrInsufficientData
+	}
+	valueLength := int(int32(binary.BigEndian.Uint32(message[keyEnd:])))
+	if valueLength < -1 || valueLength > len(message)-keyEnd-4 {
+		return nil, PacketDecodingError{"invalid message value"}
+	}
+	if valueLength < 0 {
+		return message, nil
+	}
+	value, err := fn(message[keyEnd+4 : keyEnd+4+valueLength])
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(value, message[keyEnd+4:keyEnd+4+valueLength]) {
+		return message, nil
+	}
+	result := make([]byte, keyEnd+4, keyEnd+4+len(value))
+	copy(result, message[:keyEnd])
+	binary.BigEndian.PutUint32(result[keyEnd:], uint32(len(value)))
+	result = append(result, value...)
+	binary.BigEndian.PutUint32(result[sizeOffset:], uint32(len(result)-logOverhead))
+	binary.BigEndian.PutUint32(result[messageCrcOffset:], crc32.ChecksumIEEE(result[magicOffset:]))
+	return result, nil
+}
+
+func bytesEnd(message []byte, offset int) (int, error) {
+	if len(message) < offset+4 {
+		return 0, ErrInsufficientData
+	}
+	length := int(int32(binary.BigEndian.Uint32(message[offset:])))
+	if length < -1 || length > len(message)-offset-4 {
+		return 0, PacketDecodingError{"invalid message key"}
+	}
+	if length < 0 {
+		return offset + 4, nil
+	}
+	return offset + 4 + length, nil
+}
+
+func appendVarint(buf []byte, value int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], value)
+	return append(buf, tmp[:n]...)
+}
@@ -0,0 +1,196 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildRecordBatch returns a record batch (magic v2) with the given record values, a nil value is a null value
+func buildRecordBatch(codec int16, values ...[]byte) []byte {
+	var records []byte
+	for i, value := range values {
+		record := []byte{0}                     // attributes
+		record = appendVarint(record, 0)        // timestampDelta
+		record = appendVarint(record, int64(i)) // offsetDelta
+		record = appendVarint(record, 1)        // key
+		record = append(record, 'k')
+		if value == nil {
+			record = appendVarint(record, -1)
+		} else {
+			record = appendVarint(record, int64(len(value)))
+			record = append(record, value...)
+		}
+		record = appendVarint(record, 0) // headers
+		records = appendVarint(records, int64(len(record)))
+		records = append(records, record...)
+	}
+	records, _ = compress(int(codec), records)
+	batch := make([]byte, recordsOffset, recordsOffset+len(records))
+	batch[magicOffset] = 2
+	binary.BigEndian.PutUint16(batch[batchAttributesOffset:], uint16(codec))
+	binary.BigEndian.PutUint32(batch[recordsCountOffset:], uint32(len(values)))
+	batch = append(batch, records...)
+	binary.BigEndian.PutUint32(batch[sizeOffset:], uint32(len(batch)-logOverhead))
+	binary.BigEndian.PutUint32(batch[batchCrcOffset:], crc32.Checksum(batch[batchAttributesOffset:], castagnoliTable))
+	return batch
+}
+
+// buildMessage returns a legacy message (magic v0 or v1) with the given value
+func buildMessage(magic byte, value []byte) []byte {
+	message := make([]byte, messageAttributesOffset+1)
+	message[magicOffset] = magic
+	if magic == 1 {
+		message = append(message, make([]byte, 8)...) // timestamp
+	}
+	message = append(message, 0xff, 0xff, 0xff, 0xff) // null key
+	message = append(message, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(message[len(message)-4:], uint32(len(value)))
+	message = append(message, value...)
+	binary.BigEndian.PutUint32(message[sizeOffset:], uint32(len(message)-logOverhead))
+	binary.BigEndian.PutUint32(message[messageCrcOffset:], crc32.ChecksumIEEE(message[magicOffset:]))
+	return message
+}
+
+func recordValues(t *testing.T, records []byte) []string {
+	values := make([]string, 0)
+	_, err := TransformRecordValues(records, func(value []byte) ([]byte, error) {
+		values = append(values, string(value))
+		return value, nil
+	})
+	assert.Nil(t, err)
+	return values
+}
+
+func upperValues(value []byte) ([]byte, error) {
+	return []byte(strings.ToUpper(string(value)) + "!"), nil
+}
+
+func TestTransformRecordValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []byte
+		values  []string
+	}{
+		{name: "empty", records: nil, values: []string{}},
+		{name: "uncompressed batch", records: buildRecordBatch(compressionNone, []byte("a"), nil, []byte("bc")), values: []string{"A!", "BC!"}},
+		{name: "gzip batch", records: buildRecordBatch(compressionGZIP, []byte("a"), []byte("bc")), values: []string{"A!", "BC!"}},
+		{name: "snappy batch", records: buildRecordBatch(compressionSnappy, []byte("a"), []byte("bc")), values: []string{"A!", "BC!"}},
+		{name: "lz4 batch", records: buildRecordBatch(compressionLZ4, []byte("a"), []byte("bc")), values: []string{"A!", "BC!"}},
+		{name: "zstd batch", records: buildRecordBatch(compressionZSTD, []byte("a"), []byte("bc")), values: []string{"A!", "BC!"}},
+		{name: "two batches", records: append(buildRecordBatch(compressionNone, []byte("a")), buildRecordBatch(compressionGZIP, []byte("b"))...), values: []string{"A!", "B!"}},
+		{name: "legacy messages", records: append(buildMessage(0, []byte("a")), buildMessage(1, []byte("b"))...), values: []string{"A!", "B!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			transformed, err := TransformRecordValues(tt.records, upperValues)
+			a.Nil(err)
+			a.Equal(tt.values, recordValues(t, transformed))
+
+			for records := transformed; len(records) > 0; {
+				length := int(binary.BigEndian.Uint32(records[sizeOffset:]))
+				entry := records[:logOverhead+length]
+				if entry[magicOffset] == 2 {
+					a.Equal(crc32.Checksum(entry[batchAttributesOffset:], castagnoliTable), binary.BigEndian.Uint32(entry[batchCrcOffset:]))
+				} else {
+					a.Equal(crc32.ChecksumIEEE(entry[magicOffset:]), binary.BigEndian.Uint32(entry[messageCrcOffset:]))
+				}
+				records = records[logOverhead+length:]
+			}
+		})
+	}
+}
+
+func TestTransformRecordValuesPartialBatch(t *testing.T) {
+	a := assert.New(t)
+
+	batch := buildRecordBatch(compressionNone, []byte("a"))
+	partial := buildRecordBatch(compressionNone, []byte("b"))[:recordsOffset]
+
+	transformed, err := TransformRecordValues(append(batch, partial...), upperValues)
+	a.Nil(err)
+	a.Equal([]string{"A!"}, recordValues(t, transformed[:len(transformed)-len(partial)]))
+	a.Equal(partial, transformed[len(transformed)-len(partial):])
+}
+
+func TestTransformRecordValuesControlBatch(t *testing.T) {
+	a := assert.New(t)
+
+	batch := buildRecordBatch(compressionNone, []byte("a"))
+	batch[batchAttributesOffset+1] |= controlBatchFlag
+
+	transformed, err := TransformRecordValues(batch, upperValues)
+	a.Nil(err)
+	a.Equal(batch, transformed)
+}
+
+func TestTransformRecordValuesUnsupportedCompression(t *testing.T) {
+	a := assert.New(t)
+
+	batch := buildRecordBatch(compressionNone, []byte("a"))
+	batch[batchAttributesOffset+1] |= 5 // unknown codec
+
+	_, err := TransformRecordValues(batch, upperValues)
+	a.Equal(ErrUnsupportedCompression{Codec: 5}, err)
+
+	// compressed legacy messages
+	message := buildMessage(1, []byte("a"))
+	message[messageAttributesOffset] |= compressionSnappy
+	_, err = TransformRecordValues(message, upperValues)
+	a.Equal(ErrUnsupportedCompression{Codec: compressionSnappy}, err)
+}
+
+func TestVisitRecordValues(t *testing.T) {
+	a := assert.New(t)
+
+	records := append(buildRecordBatch(compressionGZIP, []byte("a"), nil), buildMessage(1, []byte("b"))...)
+	values := make([]string, 0)
+	err := VisitRecordValues(records, func(value []byte) error {
+		values = append(values, string(value))
+		return nil
+	})
+	a.Nil(err)
+	a.Equal([]string{"a", "b"}, values)
+
+	transformed, err := TransformRecordValues(records, func(value []byte) ([]byte, error) {
+		return value, nil
+	})
+	a.Nil(err)
+	a.Equal(records, transformed)
+}
+
+func TestClearProducerIDs(t *testing.T) {
+	a := assert.New(t)
+
+	idempotent := buildRecordBatch(compressionNone, []byte("a"))
+	binary.BigEndian.PutUint64(idempotent[batchProducerIDOffset:], 42)
+	binary.BigEndian.PutUint16(idempotent[batchProducerIDOffset+8:], 1)
+	binary.BigEndian.PutUint32(idempotent[batchProducerIDOffset+10:], 7)
+	binary.BigEndian.PutUint32(idempotent[batchCrcOffset:], crc32.Checksum(idempotent[batchAttributesOffset:], castagnoliTable))
+	plain := buildRecordBatch(compressionNone, []byte("b"))
+	binary.BigEndian.PutUint64(plain[batchProducerIDOffset:], ^uint64(0))
+	binary.BigEndian.PutUint32(plain[batchCrcOffset:], crc32.Checksum(plain[batchAttributesOffset:], castagnoliTable))
+	message := buildMessage(1, []byte("c"))
+
+	records := append(append(append([]byte{}, idempotent...), plain...), message...)
+	cleared, count, err := ClearProducerIDs(records)
+	a.Nil(err)
+	a.Equal(1, count)
+	a.Equal(len(records), len(cleared))
+	a.Equal(int64(-1), int64(binary.BigEndian.Uint64(cleared[batchProducerIDOffset:])))
+	a.Equal(int16(-1), int16(binary.BigEndian.Uint16(cleared[batchProducerIDOffset+8:])))
+	a.Equal(int32(-1), int32(binary.BigEndian.Uint32(cleared[batchProducerIDOffset+10:])))
+	a.Equal(crc32.Checksum(cleared[batchAttributesOffset:len(idempotent)], castagnoliTable), binary.BigEndian.Uint32(cleared[batchCrcOffset:]))
+	a.Equal(records[len(idempotent):], cleared[len(idempotent):])
+	a.Equal([]string{"a", "b", "c"}, recordValues(t, cleared))
+
+	// a partial trailing batch is kept unchanged
+	cleared, count, err = ClearProducerIDs([]byte{1, 2, 3})
+	a.Nil(err)
+	a.Equal(0, count)
+	a.Equal([]byte{1, 2, 3}, cleared)
+}
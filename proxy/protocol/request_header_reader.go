@@ -0,0 +1,35 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// RequestHeaderReader reads the part of the request header which follows ApiKey and ApiVersion.
+// CorrelationID and ClientID have the same encoding in request header version 1 and 2.
+type RequestHeaderReader struct {
+}
+
+func (r RequestHeaderReader) ReadHeaderV1Part(reader io.Reader) (correlationID int32, clientID *string, err error) {
+	// CorrelationID int32
+	if err = binary.Read(reader, binary.BigEndian, &correlationID); err != nil {
+		return 0, nil, err
+	}
+	// ClientID *string
+	var length int16
+	if err = binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return 0, nil, err
+	}
+	if length < -1 {
+		return 0, nil, errInvalidStringLength
+	}
+	if length == -1 {
+		return correlationID, nil, nil
+	}
+	buf := make([]byte, length)
+	if _, err = io.ReadFull(reader, buf); err != nil {
+		return 0, nil, err
+	}
+	s := string(buf)
+	return correlationID, &s, nil
+}
@@ -0,0 +1,136 @@
+package protocol
+
+const (
+	apiKeyProduce = 0
+	apiKeyFetch   = 1
+)
+
+// RequestSummary is the request header following the api key and version together with the topics of the request body.
+// Topics are decoded for the non-flexible versions of Produce (v0-8), Fetch (v0-11) and Metadata (v0-8).
+type RequestSummary struct {
+	ApiKey     int16
+	ApiVersion int16
+
+	CorrelationID int32
+	ClientID      *string
+	Topics        []string
+	// TopicsDecoded is false, when the topics of the request are not supported
+	TopicsDecoded bool
+}
+
+func (r *RequestSummary) decode(pd packetDecoder) (err error) {
+	if r.CorrelationID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.ClientID, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	switch {
+	case r.ApiKey == apiKeyProduce && r.ApiVersion <= 8:
+		err = r.decodeProduceTopics(pd)
+	case r.ApiKey == apiKeyFetch && r.ApiVersion <= 11:
+		err = r.decodeFetchTopics(pd)
+	case r.ApiKey == apiKeyMetadata && r.ApiVersion <= 8:
+		err = r.decodeMetadataTopics(pd)
+	}
+	if err != nil {
+		return err
+	}
+	// the rest of the body is not needed
+	_, err = pd.getRawBytes(pd.remaining())
+	return err
+}
+
+func (r *RequestSummary) decodeProduceTopics(pd packetDecoder) (err error) {
+	if r.ApiVersion >= 3 {
+		// transactional_id
+		if _, err = pd.getNullableString(); err != nil {
+			return err
+		}
+	}
+	// acks, timeout_ms
+	if _, err = pd.getRawBytes(2 + 4); err != nil {
+		return err
+	}
+	return r.decodeTopicArray(pd, func() error {
+		// partition_index
+		if _, err := pd.getInt32(); err != nil {
+			return err
+		}
+		// records
+		_, err := pd.getBytes()
+		return err
+	})
+}
+
+func (r *RequestSummary) decodeFetchTopics(pd packetDecoder) (err error) {
+	// replica_id, max_wait_ms, min_bytes
+	skip := 4 + 4 + 4
+	if r.ApiVersion >= 3 {
+		// max_bytes
+		skip += 4
+	}
+	if r.ApiVersion >= 4 {
+		// isolation_level
+		skip++
+	}
+	if r.ApiVersion >= 7 {
+		// session_id, session_epoch
+		skip += 4 + 4
+	}
+	if _, err = pd.getRawBytes(skip); err != nil {
+		return err
+	}
+	// partition, fetch_offset, partition_max_bytes
+	partitionSize := 4 + 8 + 4
+	if r.ApiVersion >= 5 {
+		// log_start_offset
+		partitionSize += 8
+	}
+	if r.ApiVersion >= 9 {
+		// current_leader_epoch
+		partitionSize += 4
+	}
+	return r.decodeTopicArray(pd, func() error {
+		_, err := pd.getRawBytes(partitionSize)
+		return err
+	})
+}
+
+func (r *RequestSummary) decodeMetadataTopics(pd packetDecoder) (err error) {
+	// null (v1+) requests all topics, empty array (v0) as well
+	topics, err := pd.getStringArray()
+	if err != nil {
+		return err
+	}
+	r.Topics = topics
+	r.TopicsDecoded = true
+	return nil
+}
+
+func (r *RequestSummary) decodeTopicArray(pd packetDecoder, skipPartition func() error) error {
+	topicCount, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	topics := make([]string, 0, topicCount)
+	for i := 0; i < topicCount; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		partitionCount, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		for j := 0; j < partitionCount; j++ {
+			if err = skipPartition(); err != nil {
+				return err
+			}
+		}
+		topics = append(topics, topic)
+	}
+	r.Topics = topics
+	r.TopicsDecoded = true
+	return nil
+}
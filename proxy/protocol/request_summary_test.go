@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeRequestSummary(t *testing.T) {
+	tests := []struct {
+		name          string
+		apiKey        int16
+		apiVersion    int16
+		hexBody       string
+		correlationID int32
+		clientID      string
+		topics        []string
+		topicsDecoded bool
+	}{
+		{name: "Produce v2", apiKey: 0, apiVersion: 2,
+			hexBody:       "0000000500144b61666b614578616d706c6550726f647563657200010000753000000001000f746573742d6e6f2d68656164657273000000010000000000000041000000000000000000000035fe96cb720100000001734a61d94200000008000001734a61d8df0000001748656c6c6f204d6f6d2031353934363830373933333131",
+			correlationID: 5, clientID: "KafkaExampleProducer", topics: []string{"test-no-headers"}, topicsDecoded: true,
+		},
+		{name: "Produce v8", apiKey: 0, apiVersion: 8,
+			hexBody:       "0000000300144b61666b614578616d706c6550726f6475636572ffff00010000753000000001000f746573742d6e6f2d6865616465727300000001000000000000007b00000000000000000000006fffffffff02662a226b000000000000000001734a69dfbd000001734a69dfbdffffffffffffffffffffffffffff000000017a00000010000001734a69deba2e48656c6c6f204d6f6d203135393436383133313930393802146865616465722d6b6579186865616465722d76616c7565",
+			correlationID: 3, clientID: "KafkaExampleProducer", topics: []string{"test-no-headers"}, topicsDecoded: true,
+		},
+		{name: "Fetch v4", apiKey: 1, apiVersion: 4,
+			hexBody:       "00000002ffffffffffff000001f40000000100100000000000000100036261720000000100000000000000000000000500100000",
+			correlationID: 2, topics: []string{"bar"}, topicsDecoded: true,
+		},
+		{name: "Metadata v1", apiKey: 3, apiVersion: 1,
+			hexBody:       "00000001000163000000010003666f6f",
+			correlationID: 1, clientID: "c", topics: []string{"foo"}, topicsDecoded: true,
+		},
+		{name: "Metadata v1 all topics", apiKey: 3, apiVersion: 1,
+			hexBody:       "00000001000163ffffffff",
+			correlationID: 1, clientID: "c", topicsDecoded: true,
+		},
+		{name: "ApiVersions v3", apiKey: 18, apiVersion: 3,
+			hexBody:       "0000000000144b61666b614578616d706c6550726f647563657200126170616368652d6b61666b612d6a61766106322e352e3000",
+			correlationID: 0, clientID: "KafkaExampleProducer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			body, err := hex.DecodeString(tt.hexBody)
+			a.Nil(err)
+
+			summary := &RequestSummary{ApiKey: tt.apiKey, ApiVersion: tt.apiVersion}
+			a.Nil(Decode(body, summary))
+			a.Equal(tt.correlationID, summary.CorrelationID)
+			if tt.clientID == "" {
+				a.Nil(summary.ClientID)
+			} else if a.NotNil(summary.ClientID) {
+				a.Equal(tt.clientID, *summary.ClientID)
+			}
+			a.Equal(tt.topics, summary.Topics)
+			a.Equal(tt.topicsDecoded, summary.TopicsDecoded)
+		})
+	}
+}
+
+func TestDecodeRequestSummaryTruncated(t *testing.T) {
+	body, _ := hex.DecodeString("00000001000163000000010003")
+	err := Decode(body, &RequestSummary{ApiKey: 3, ApiVersion: 1})
+	assert.Equal(t, ErrInsufficientData, err)
+}
@@ -348,6 +348,10 @@ func (f *responseModifier) Apply(resp []byte) ([]byte, error) {
 }
 
 func GetResponseModifier(apiKey int16, apiVersion int16, addressMappingFunc config.NetAddressMappingFunc) (ResponseModifier, error) {
+	if addressMappingFunc == nil {
+		// addresses are not rewritten
+		return nil, nil
+	}
 	switch apiKey {
 	case apiKeyMetadata:
 		return newResponseModifier(apiKey, apiVersion, addressMappingFunc, metadataResponseSchemaVersions, modifyMetadataResponse)
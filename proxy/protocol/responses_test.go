@@ -2739,3 +2739,13 @@ func (t *decodeCheck) Dump() {
 		fmt.Printf("\"%s\",\n", v)
 	}
 }
+
+func TestResponseModifierWithoutAddressMapping(t *testing.T) {
+	a := assert.New(t)
+
+	for _, apiKey := range []int16{apiKeyMetadata, apiKeyFindCoordinator} {
+		modifier, err := GetResponseModifier(apiKey, 0, nil)
+		a.Nil(err)
+		a.Nil(modifier)
+	}
+}
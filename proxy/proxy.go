@@ -4,9 +4,12 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/activation"
 	"github.com/grepplabs/kafka-proxy/pkg/libs/util"
 	"github.com/sirupsen/logrus"
 )
@@ -24,12 +27,22 @@ type Listeners struct {
 	tcpConnOptions TCPConnOptions
 
 	listenFunc ListenFunc
+	// TLS config of the connections accepted by a listener, nil if TLS is disabled
+	serverTLSConfig func(listener config.ListenerConfig) *tls.Config
+	// TLS config of the listeners, nil if TLS is disabled
+	listenerTLSConfig *atomic.Value
+	// client addresses in the logs
+	pseudonymizer *Pseudonymizer
 
 	disableDynamicListeners  bool
 	dynamicSequentialMinPort int
 
 	brokerToListenerConfig map[string]config.ListenerConfig
-	lock                   sync.RWMutex
+	// listeners of the bootstrap server mappings
+	staticListeners map[config.ListenerConfig]net.Listener
+	// brokers with a dynamic listener
+	dynamicBrokers map[string]struct{}
+	lock           sync.RWMutex
 }
 
 func NewListeners(cfg *config.Config) (*Listeners, error) {
@@ -44,33 +57,66 @@ func NewListeners(cfg *config.Config) (*Listeners, error) {
 	}
 
 	var tlsConfig *tls.Config
+	var listenerTLSConfig *atomic.Value
 	if cfg.Proxy.TLS.Enable {
-		var err error
-		tlsConfig, err = newTLSListenerConfig(cfg)
+		current, err := newTLSListenerConfig(cfg)
 		if err != nil {
 			return nil, err
 		}
+		listenerTLSConfig = &atomic.Value{}
+		listenerTLSConfig.Store(current)
+		// handshakes use the current config, which is replaced on reload
+		tlsConfig = &tls.Config{
+			Certificates: current.Certificates,
+			GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				return listenerTLSConfig.Load().(*tls.Config), nil
+			},
+		}
+	}
+
+	var serverTLSConfig func(listener config.ListenerConfig) *tls.Config
+	if tlsConfig != nil {
+		serverTLSConfig = func(listener config.ListenerConfig) *tls.Config {
+			if policy, ok := cfg.Auth.ListenerPolicies.Policy(listener.ListenerAddress, listener.BrokerAddress); ok {
+				return clientCertTLSConfig(tlsConfig, policy.ClientCert)
+			}
+			return tlsConfig
+		}
 	}
 
-	listenFunc := func(cfg config.ListenerConfig) (net.Listener, error) {
-		if tlsConfig != nil {
-			return tls.Listen("tcp", cfg.ListenerAddress, tlsConfig)
+	listenFunc := func(listener config.ListenerConfig) (net.Listener, error) {
+		// sockets passed by systemd are used for the addresses
+		l, err := activation.Listen("tcp", listener.ListenerAddress)
+		if err != nil {
+			return nil, err
+		}
+		if serverTLSConfig != nil {
+			return tls.NewListener(l, serverTLSConfig(listener)), nil
 		}
-		return net.Listen("tcp", cfg.ListenerAddress)
+		return l, nil
 	}
 
 	brokerToListenerConfig, err := getBrokerToListenerConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
+	pseudonymizer, err := newPseudonymizer(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Listeners{
 		defaultListenerIP:         defaultListenerIP,
 		dynamicAdvertisedListener: dynamicAdvertisedListener,
 		connSrc:                   make(chan Conn, 1),
 		brokerToListenerConfig:    brokerToListenerConfig,
+		staticListeners:           make(map[config.ListenerConfig]net.Listener),
+		dynamicBrokers:            make(map[string]struct{}),
 		tcpConnOptions:            tcpConnOptions,
 		listenFunc:                listenFunc,
+		serverTLSConfig:           serverTLSConfig,
+		listenerTLSConfig:         listenerTLSConfig,
+		pseudonymizer:             pseudonymizer,
 		disableDynamicListeners:   cfg.Proxy.DisableDynamicListeners,
 		dynamicSequentialMinPort:  cfg.Proxy.DynamicSequentialMinPort,
 	}, nil
@@ -167,6 +213,7 @@ func (p *Listeners) ListenDynamicInstance(brokerAddress string) (string, int32,
 
 	advertisedAddress := net.JoinHostPort(dynamicAdvertisedListener, fmt.Sprint(port))
 	p.brokerToListenerConfig[brokerAddress] = config.ListenerConfig{BrokerAddress: brokerAddress, ListenerAddress: address, AdvertisedAddress: advertisedAddress}
+	p.dynamicBrokers[brokerAddress] = struct{}{}
 
 	logrus.Infof("Dynamic listener %s for broker %s advertised as %s", address, brokerAddress, advertisedAddress)
 
@@ -179,22 +226,190 @@ func (p *Listeners) ListenInstances(cfgs []config.ListenerConfig) (<-chan Conn,
 
 	// allows multiple local addresses to point to the remote
 	for _, v := range cfgs {
-		_, err := listenInstance(p.connSrc, v, p.tcpConnOptions, p.listenFunc)
+		if _, ok := p.staticListeners[v]; ok {
+			continue
+		}
+		l, err := listenInstance(p.connSrc, v, p.tcpConnOptions, p.listenFunc)
 		if err != nil {
 			return nil, err
 		}
+		p.staticListeners[v] = l
 	}
+	p.applyBoundAddresses()
 	return p.connSrc, nil
 }
 
+// BootstrapListeners returns the listeners of the bootstrap server mappings ordered by the broker address. Ports 0 of
+// the listener and advertised addresses are replaced by the ports assigned by the OS.
+func (p *Listeners) BootstrapListeners() []config.ListenerConfig {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	result := make([]config.ListenerConfig, 0, len(p.staticListeners))
+	for v, l := range p.staticListeners {
+		result = append(result, boundListenerConfig(v, l))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BrokerAddress != result[j].BrokerAddress {
+			return result[i].BrokerAddress < result[j].BrokerAddress
+		}
+		return result[i].ListenerAddress < result[j].ListenerAddress
+	})
+	return result
+}
+
+// applyBoundAddresses advertises the ports assigned to the listeners on port 0
+func (p *Listeners) applyBoundAddresses() {
+	for v, l := range p.staticListeners {
+		bound := boundListenerConfig(v, l)
+		if bound == v {
+			continue
+		}
+		if current, ok := p.brokerToListenerConfig[v.BrokerAddress]; ok && current.ListenerAddress == v.ListenerAddress {
+			p.brokerToListenerConfig[v.BrokerAddress] = bound
+		}
+	}
+}
+
+// boundListenerConfig replaces port 0 of the listener address and the advertised address by the port of the listener
+func boundListenerConfig(cfg config.ListenerConfig, l net.Listener) config.ListenerConfig {
+	addr, ok := l.Addr().(*net.TCPAddr)
+	if !ok {
+		return cfg
+	}
+	port := fmt.Sprint(addr.Port)
+	if host, listenerPort, err := net.SplitHostPort(cfg.ListenerAddress); err == nil && listenerPort == "0" {
+		cfg.ListenerAddress = net.JoinHostPort(host, port)
+		if advertisedHost, advertisedPort, err := net.SplitHostPort(cfg.AdvertisedAddress); err == nil && advertisedPort == "0" {
+			cfg.AdvertisedAddress = net.JoinHostPort(advertisedHost, port)
+		}
+	}
+	return cfg
+}
+
+// Reload applies the bootstrap and external server mappings of the configuration. Listeners of new mappings are started and
+// listeners of removed mappings stop accepting connections while their open connections are kept. Unchanged listeners are untouched,
+// the listener of a listener address mapped to another broker is kept and remapped. If a new listener cannot be started, the
+// mappings are not changed.
+// The listener certificates and TLS settings apply to new connections if TLS was enabled on startup.
+func (p *Listeners) Reload(cfg *config.Config) error {
+	brokerToListenerConfig, err := getBrokerToListenerConfig(cfg)
+	if err != nil {
+		return err
+	}
+	var tlsConfig *tls.Config
+	if p.listenerTLSConfig != nil && cfg.Proxy.TLS.Enable {
+		if tlsConfig, err = newTLSListenerConfig(cfg); err != nil {
+			return err
+		}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	wanted := make(map[config.ListenerConfig]struct{})
+	for _, v := range cfg.Proxy.BootstrapServers {
+		wanted[v] = struct{}{}
+	}
+	// listeners of removed mappings by their fixed listener address
+	removed := make(map[string]config.ListenerConfig)
+	for v := range p.staticListeners {
+		if _, ok := wanted[v]; !ok && !isPortZero(v.ListenerAddress) {
+			removed[v.ListenerAddress] = v
+		}
+	}
+	var added []config.ListenerConfig
+	retargeted := make(map[config.ListenerConfig]config.ListenerConfig)
+	for v := range wanted {
+		if _, ok := p.staticListeners[v]; ok {
+			continue
+		}
+		if previous, ok := removed[v.ListenerAddress]; ok {
+			// the listener keeps its address and accepts the connections for the new mapping
+			retargeted[v] = previous
+			delete(removed, v.ListenerAddress)
+		} else {
+			added = append(added, v)
+		}
+	}
+	// the new listeners are bound first, so nothing is changed if one fails
+	started := make(map[config.ListenerConfig]net.Listener)
+	for _, v := range added {
+		l, err := listenInstance(p.connSrc, v, p.tcpConnOptions, p.listenFunc)
+		if err != nil {
+			for _, l := range started {
+				_ = l.Close()
+			}
+			return err
+		}
+		started[v] = l
+	}
+	for v, previous := range retargeted {
+		l := p.staticListeners[previous]
+		if mapped, ok := l.(*mappedListener); ok {
+			mapped.retarget(v)
+		}
+		logrus.Infof("Listener on %s is remapped from remote %s to remote %s", v.ListenerAddress, previous.BrokerAddress, v.BrokerAddress)
+		delete(p.staticListeners, previous)
+		p.staticListeners[v] = l
+	}
+	for v := range p.staticListeners {
+		if _, ok := wanted[v]; ok {
+			continue
+		}
+		logrus.Infof("Stopping listener on %s for remote %s, open connections are kept", v.ListenerAddress, v.BrokerAddress)
+		_ = p.staticListeners[v].Close()
+		delete(p.staticListeners, v)
+	}
+	for v, l := range started {
+		p.staticListeners[v] = l
+	}
+	// dynamic listeners of brokers without a mapping are kept
+	for brokerAddress := range p.dynamicBrokers {
+		if _, ok := brokerToListenerConfig[brokerAddress]; ok {
+			continue
+		}
+		if v, ok := p.brokerToListenerConfig[brokerAddress]; ok {
+			brokerToListenerConfig[brokerAddress] = v
+		}
+	}
+	p.brokerToListenerConfig = brokerToListenerConfig
+	p.applyBoundAddresses()
+	if tlsConfig != nil {
+		p.listenerTLSConfig.Store(tlsConfig)
+	}
+	return nil
+}
+
+// mappedListener is a listener whose mapping can be changed without releasing the listener address
+type mappedListener struct {
+	net.Listener
+	mapping atomic.Value
+}
+
+func (l *mappedListener) retarget(cfg config.ListenerConfig) {
+	l.mapping.Store(cfg)
+}
+
+func (l *mappedListener) config() config.ListenerConfig {
+	return l.mapping.Load().(config.ListenerConfig)
+}
+
+func isPortZero(address string) bool {
+	_, port, err := net.SplitHostPort(address)
+	return err == nil && port == "0"
+}
+
 func listenInstance(dst chan<- Conn, cfg config.ListenerConfig, opts TCPConnOptions, listenFunc ListenFunc) (net.Listener, error) {
-	l, err := listenFunc(cfg)
+	listener, err := listenFunc(cfg)
 	if err != nil {
 		return nil, err
 	}
+	l := &mappedListener{Listener: listener}
+	l.retarget(cfg)
 	go withRecover(func() {
 		for {
 			c, err := l.Accept()
+			cfg := l.config()
 			if err != nil {
 				logrus.Infof("Error in accept for %q on %v: %v", cfg, cfg.ListenerAddress, err)
 				l.Close()
@@ -205,8 +420,7 @@ func listenInstance(dst chan<- Conn, cfg config.ListenerConfig, opts TCPConnOpti
 					logrus.Infof("WARNING: Error while setting TCP options for accepted connection %q on %v: %v", cfg, l.Addr().String(), err)
 				}
 			}
-			logrus.Infof("New connection for %s", cfg.BrokerAddress)
-			dst <- Conn{BrokerAddress: cfg.BrokerAddress, LocalConnection: c}
+			dst <- Conn{BrokerAddress: cfg.BrokerAddress, ListenerAddress: cfg.ListenerAddress, LocalConnection: c}
 		}
 	})
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/grepplabs/kafka-proxy/config"
 	"github.com/stretchr/testify/assert"
+	"net"
 	"testing"
 )
 
@@ -187,3 +188,140 @@ func TestGetBrokerToListenerConfig(t *testing.T) {
 		a.Equal(tt.mapping, mapping)
 	}
 }
+
+func TestListenersReload(t *testing.T) {
+	a := assert.New(t)
+
+	addresses := make([]string, 4)
+	for i := range addresses {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		a.Nil(err)
+		addresses[i] = l.Addr().String()
+		_ = l.Close()
+	}
+	listenerConfig := func(broker string, address string) config.ListenerConfig {
+		return config.ListenerConfig{BrokerAddress: broker, ListenerAddress: address, AdvertisedAddress: address}
+	}
+	canDial := func(address string) bool {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}
+
+	cfg := &config.Config{}
+	cfg.Proxy.BootstrapServers = []config.ListenerConfig{
+		listenerConfig("kafka-0:9092", addresses[0]),
+		listenerConfig("kafka-1:9092", addresses[1]),
+	}
+	listeners, err := NewListeners(cfg)
+	a.Nil(err)
+	_, err = listeners.ListenInstances(cfg.Proxy.BootstrapServers)
+	a.Nil(err)
+	unchanged := listeners.staticListeners[cfg.Proxy.BootstrapServers[1]]
+	// dynamic listener of a broker without a mapping
+	listeners.brokerToListenerConfig["kafka-9:9092"] = listenerConfig("kafka-9:9092", "127.0.0.1:30009")
+	listeners.dynamicBrokers["kafka-9:9092"] = struct{}{}
+
+	// address is in use
+	busy, err := net.Listen("tcp", addresses[3])
+	a.Nil(err)
+	failing := &config.Config{}
+	failing.Proxy.BootstrapServers = []config.ListenerConfig{
+		listenerConfig("kafka-5:9092", addresses[0]),
+		listenerConfig("kafka-1:9092", addresses[1]),
+		listenerConfig("kafka-2:9092", addresses[2]),
+		listenerConfig("kafka-3:9092", addresses[3]),
+	}
+	a.NotNil(listeners.Reload(failing))
+	_ = busy.Close()
+	a.Len(listeners.staticListeners, 2)
+	a.True(canDial(addresses[0]))
+	a.False(canDial(addresses[2]))
+	// the mappings are not changed
+	a.Equal(addresses[0], listeners.brokerToListenerConfig["kafka-0:9092"].ListenerAddress)
+	_, ok := listeners.brokerToListenerConfig["kafka-5:9092"]
+	a.False(ok)
+	a.Equal("kafka-0:9092", listeners.staticListeners[cfg.Proxy.BootstrapServers[0]].(*mappedListener).config().BrokerAddress)
+
+	reloaded := &config.Config{}
+	reloaded.Proxy.BootstrapServers = []config.ListenerConfig{
+		listenerConfig("kafka-1:9092", addresses[1]),
+		listenerConfig("kafka-2:9092", addresses[2]),
+	}
+	a.Nil(listeners.Reload(reloaded))
+	a.Len(listeners.staticListeners, 2)
+	a.True(unchanged == listeners.staticListeners[reloaded.Proxy.BootstrapServers[0]])
+	a.False(canDial(addresses[0]))
+	a.True(canDial(addresses[1]))
+	a.True(canDial(addresses[2]))
+
+	_, ok = listeners.brokerToListenerConfig["kafka-0:9092"]
+	a.False(ok)
+	a.Equal(addresses[2], listeners.brokerToListenerConfig["kafka-2:9092"].ListenerAddress)
+	a.Equal("127.0.0.1:30009", listeners.brokerToListenerConfig["kafka-9:9092"].ListenerAddress)
+
+	// listener address is reused by another broker
+	moved := &config.Config{}
+	moved.Proxy.BootstrapServers = []config.ListenerConfig{
+		listenerConfig("kafka-1:9092", addresses[1]),
+		listenerConfig("kafka-3:9092", addresses[2]),
+	}
+	previous := listeners.staticListeners[reloaded.Proxy.BootstrapServers[1]]
+	a.Nil(listeners.Reload(moved))
+	a.True(previous == listeners.staticListeners[moved.Proxy.BootstrapServers[1]])
+	a.Equal(addresses[2], listeners.brokerToListenerConfig["kafka-3:9092"].ListenerAddress)
+	// the connections are accepted for the new broker
+	a.Equal("kafka-3:9092", previous.(*mappedListener).config().BrokerAddress)
+	a.True(canDial(addresses[2]))
+
+	for _, l := range listeners.staticListeners {
+		_ = l.Close()
+	}
+}
+
+func TestListenersOnPortZero(t *testing.T) {
+	a := assert.New(t)
+
+	cfg := &config.Config{}
+	cfg.Proxy.BootstrapServers = []config.ListenerConfig{
+		{BrokerAddress: "kafka-1:9092", ListenerAddress: "127.0.0.1:0", AdvertisedAddress: "127.0.0.1:0"},
+		{BrokerAddress: "kafka-0:9092", ListenerAddress: "127.0.0.1:0", AdvertisedAddress: "proxy:0"},
+	}
+	listeners, err := NewListeners(cfg)
+	a.Nil(err)
+	_, err = listeners.ListenInstances(cfg.Proxy.BootstrapServers)
+	a.Nil(err)
+
+	bootstrap := listeners.BootstrapListeners()
+	a.Len(bootstrap, 2)
+	a.Equal("kafka-0:9092", bootstrap[0].BrokerAddress)
+	a.Equal("kafka-1:9092", bootstrap[1].BrokerAddress)
+	for i, broker := range []string{"kafka-0", "kafka-1"} {
+		_, port, err := net.SplitHostPort(bootstrap[i].ListenerAddress)
+		a.Nil(err)
+		a.NotEqual("0", port)
+		a.Equal(bootstrap[i], listeners.brokerToListenerConfig[broker+":9092"])
+
+		host, advertisedPort, err := listeners.GetNetAddressMapping(broker, 9092)
+		a.Nil(err)
+		a.Equal(port, fmt.Sprint(advertisedPort))
+		if broker == "kafka-0" {
+			a.Equal("proxy", host)
+		}
+	}
+	conn, err := net.Dial("tcp", bootstrap[1].AdvertisedAddress)
+	a.Nil(err)
+	_ = conn.Close()
+
+	// assigned ports are kept on reload
+	a.Nil(listeners.Reload(cfg))
+	a.Equal(bootstrap, listeners.BootstrapListeners())
+	a.Equal(bootstrap[0], listeners.brokerToListenerConfig["kafka-0:9092"])
+
+	for _, l := range listeners.staticListeners {
+		_ = l.Close()
+	}
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+)
+
+const (
+	pseudonymPrefix = "anon-"
+	// truncated HMAC-SHA256 length in bytes
+	pseudonymLength = 12
+
+	minPseudonymKeyLength = 16
+)
+
+// Pseudonymizer replaces principals, client ids and client addresses in logs and audit events
+// with a keyed HMAC. The same value is always mapped to the same pseudonym, so the telemetry can still be correlated.
+// A nil Pseudonymizer returns the values unchanged.
+type Pseudonymizer struct {
+	key []byte
+}
+
+func NewPseudonymizer(key []byte) *Pseudonymizer {
+	if len(key) == 0 {
+		return nil
+	}
+	return &Pseudonymizer{key: key}
+}
+
+func (p *Pseudonymizer) enabled() bool {
+	return p != nil
+}
+
+func (p *Pseudonymizer) principal(principal string) string {
+	return p.pseudonymize("principal", principal)
+}
+
+func (p *Pseudonymizer) clientID(clientID string) string {
+	return p.pseudonymize("client-id", clientID)
+}
+
+// address pseudonymizes the host of the client address, the port is kept
+func (p *Pseudonymizer) address(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	if !p.enabled() {
+		return addr.String()
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return p.pseudonymize("address", addr.String())
+	}
+	return net.JoinHostPort(p.pseudonymize("address", host), port)
+}
+
+func (p *Pseudonymizer) pseudonymize(kind string, value string) string {
+	if !p.enabled() || value == "" {
+		return value
+	}
+	mac := hmac.New(sha256.New, p.key)
+	// the kind separates the domains e.g. a client id equal to a principal gets a different pseudonym
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil)[:pseudonymLength])
+}
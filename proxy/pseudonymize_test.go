@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPseudonymizer(t *testing.T) {
+	a := assert.New(t)
+
+	p := NewPseudonymizer([]byte("0123456789abcdef"))
+	a.True(p.enabled())
+
+	alice := p.principal("alice")
+	a.True(strings.HasPrefix(alice, pseudonymPrefix))
+	a.Len(alice, len(pseudonymPrefix)+2*pseudonymLength)
+	a.Equal(alice, p.principal("alice"))
+	a.NotEqual(alice, p.principal("bob"))
+	// domains are separated
+	a.NotEqual(alice, p.clientID("alice"))
+	// keyed
+	a.NotEqual(alice, NewPseudonymizer([]byte("fedcba9876543210")).principal("alice"))
+
+	a.Equal("", p.principal(""))
+
+	addr := p.address(&net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 52100})
+	host, port, err := net.SplitHostPort(addr)
+	a.Nil(err)
+	a.Equal("52100", port)
+	a.True(strings.HasPrefix(host, pseudonymPrefix))
+	a.Equal(addr, p.address(&net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 52100}))
+	a.Equal("", p.address(nil))
+}
+
+func TestPseudonymizerDisabled(t *testing.T) {
+	a := assert.New(t)
+
+	p := NewPseudonymizer(nil)
+	a.Nil(p)
+	a.False(p.enabled())
+	a.Equal("alice", p.principal("alice"))
+	a.Equal("app", p.clientID("app"))
+	a.Equal("192.168.1.10:52100", p.address(&net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 52100}))
+}
@@ -0,0 +1,270 @@
+package proxy
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+)
+
+const (
+	quotaProduce = "produce"
+	quotaFetch   = "fetch"
+	quotaRequest = "request"
+)
+
+// Quotas throttles principals exceeding their produced bytes, fetched bytes or requests per second like Kafka client quotas.
+// The responses carry throttle_time_ms and the next request of the connection is read after the throttle time,
+// so noisy clients are slowed down instead of being disconnected. The rates are measured per principal over all connections.
+type Quotas struct {
+	lock    sync.RWMutex
+	quotas  config.PrincipalQuotas
+	window  time.Duration
+	sensors map[string]*principalSensors
+	// incremented on update, the connections resolve the sensors of their principal again
+	generation int64
+}
+
+func NewQuotas(quotas config.PrincipalQuotas, window time.Duration) *Quotas {
+	return &Quotas{
+		quotas:     quotas,
+		window:     window,
+		sensors:    make(map[string]*principalSensors),
+		generation: 1,
+	}
+}
+
+func (q *Quotas) enabled() bool {
+	if q == nil {
+		return false
+	}
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return len(q.quotas) != 0
+}
+
+// Update replaces the quotas, which apply to the open connections from their next request. The rates of the principals
+// whose quota or the window is changed are measured again.
+func (q *Quotas) Update(quotas config.PrincipalQuotas, window time.Duration) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	sensors := make(map[string]*principalSensors)
+	if window == q.window {
+		for principal, current := range q.sensors {
+			if quota, ok := quotas.Quota(principal); ok && quota == current.quota {
+				sensors[principal] = current
+			}
+		}
+	}
+	q.quotas = quotas
+	q.window = window
+	q.sensors = sensors
+	q.generation++
+}
+
+// resolved returns true if the connection sensors were resolved with the current quotas
+func (q *Quotas) resolved(state *quotaState) bool {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return state.generation == q.generation
+}
+
+// resolve sets the sensors of the connection principal
+func (q *Quotas) resolve(state *quotaState, principal string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	state.set(q.principalSensorsLocked(principal), q.generation)
+}
+
+// principalSensors returns the sensors of the principal or nil if the principal has no quota
+func (q *Quotas) principalSensors(principal string) *principalSensors {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.principalSensorsLocked(principal)
+}
+
+func (q *Quotas) principalSensorsLocked(principal string) *principalSensors {
+	quota, ok := q.quotas.Quota(principal)
+	if !ok || principal == "" {
+		return nil
+	}
+	sensors, ok := q.sensors[principal]
+	if !ok {
+		sensors = newPrincipalSensors(quota, q.window, time.Now())
+		q.sensors[principal] = sensors
+	}
+	return sensors
+}
+
+// recordRequest records the request of the connection principal
+func (q *Quotas) recordRequest(state *quotaState, apiKey int16, requestSize int, now time.Time) {
+	if !q.enabled() || state == nil {
+		return
+	}
+	if sensors := state.get(); sensors != nil {
+		sensors.recordRequest(apiKey, requestSize, now)
+	}
+}
+
+// throttleTime returns throttle_time_ms to inject into the response or 0 and the exceeded quota.
+// The next request of the connection is read after the throttle time.
+func (q *Quotas) throttleTime(state *quotaState, apiKey int16, responseSize int, now time.Time) (int32, string) {
+	if !q.enabled() || state == nil {
+		return 0, ""
+	}
+	sensors := state.get()
+	if sensors == nil {
+		return 0, ""
+	}
+	throttle, quota := sensors.throttleTime(apiKey, responseSize, now)
+	if throttle <= 0 {
+		return 0, ""
+	}
+	state.throttle(now.Add(throttle))
+	return int32(throttle / time.Millisecond), quota
+}
+
+// quotaState is shared by the requests and responses loops of a connection
+type quotaState struct {
+	// *principalSensors of the connection principal
+	sensors atomic.Value
+	// unix nanos until the next request is read
+	throttledUntil int64
+	// generation of the quotas the sensors were resolved with, requests loop only
+	generation int64
+}
+
+func (s *quotaState) get() *principalSensors {
+	sensors, _ := s.sensors.Load().(*principalSensors)
+	return sensors
+}
+
+func (s *quotaState) set(sensors *principalSensors, generation int64) {
+	s.sensors.Store(sensors)
+	s.generation = generation
+}
+
+func (s *quotaState) throttle(until time.Time) {
+	throttleUntil(&s.throttledUntil, until)
+}
+
+// wait delays the next request of a throttled connection
+func (s *quotaState) wait() {
+	if s == nil {
+		return
+	}
+	waitUntil(&s.throttledUntil)
+}
+
+type principalSensors struct {
+	quota    config.Quota
+	window   time.Duration
+	lock     sync.Mutex
+	produce  *quotaSensor
+	fetch    *quotaSensor
+	requests *quotaSensor
+}
+
+func newPrincipalSensors(quota config.Quota, window time.Duration, now time.Time) *principalSensors {
+	return &principalSensors{
+		quota:    quota,
+		window:   window,
+		produce:  newQuotaSensor(window, now),
+		fetch:    newQuotaSensor(window, now),
+		requests: newQuotaSensor(window, now),
+	}
+}
+
+func (s *principalSensors) recordRequest(apiKey int16, requestSize int, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests.record(1, now)
+	if apiKey == apiKeyProduce {
+		s.produce.record(float64(requestSize), now)
+	}
+}
+
+func (s *principalSensors) throttleTime(apiKey int16, responseSize int, now time.Time) (time.Duration, string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	throttle, quota := s.requests.throttleTime(s.quota.RequestsPerSec, now), quotaRequest
+	switch apiKey {
+	case apiKeyProduce:
+		if produce := s.produce.throttleTime(s.quota.ProduceBytesPerSec, now); produce > throttle {
+			throttle, quota = produce, quotaProduce
+		}
+	case apiKeyFetch:
+		s.fetch.record(float64(responseSize), now)
+		if fetch := s.fetch.throttleTime(s.quota.FetchBytesPerSec, now); fetch > throttle {
+			throttle, quota = fetch, quotaFetch
+		}
+	}
+	// like Kafka the throttle time does not exceed the quota window
+	if throttle > s.window {
+		throttle = s.window
+	}
+	return throttle, quota
+}
+
+// quotaSensor measures the rate of a value over the quota window in samples of one second
+type quotaSensor struct {
+	created time.Time
+	window  time.Duration
+	samples []quotaSample
+}
+
+type quotaSample struct {
+	second int64
+	value  float64
+}
+
+func newQuotaSensor(window time.Duration, now time.Time) *quotaSensor {
+	size := int(window / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	return &quotaSensor{created: now, window: time.Duration(size) * time.Second, samples: make([]quotaSample, size)}
+}
+
+func (s *quotaSensor) record(value float64, now time.Time) {
+	second := now.Unix()
+	sample := &s.samples[int(second%int64(len(s.samples)))]
+	if sample.second != second {
+		sample.second = second
+		sample.value = 0
+	}
+	sample.value += value
+}
+
+// rate returns the rate per second over the elapsed part of the window
+func (s *quotaSensor) rate(now time.Time) (float64, time.Duration) {
+	second := now.Unix()
+	var total float64
+	for _, sample := range s.samples {
+		if sample.second > second-int64(len(s.samples)) && sample.second <= second {
+			total += sample.value
+		}
+	}
+	elapsed := now.Sub(s.created)
+	if elapsed > s.window {
+		elapsed = s.window
+	}
+	if elapsed < time.Second {
+		elapsed = time.Second
+	}
+	return total / elapsed.Seconds(), elapsed
+}
+
+// throttleTime returns the time to wait until the rate drops to the quota, 0 if the quota is not exceeded or disabled
+func (s *quotaSensor) throttleTime(quota int, now time.Time) time.Duration {
+	if quota <= 0 {
+		return 0
+	}
+	rate, elapsed := s.rate(now)
+	if rate <= float64(quota) {
+		return 0
+	}
+	return time.Duration((rate - float64(quota)) / float64(quota) * float64(elapsed))
+}
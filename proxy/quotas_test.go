@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaSensor(t *testing.T) {
+	a := assert.New(t)
+
+	start := time.Unix(1000, 0)
+	sensor := newQuotaSensor(10*time.Second, start)
+
+	sensor.record(500, start)
+	rate, elapsed := sensor.rate(start.Add(500 * time.Millisecond))
+	a.Equal(500.0, rate)
+	a.Equal(time.Second, elapsed)
+	a.Equal(time.Duration(0), sensor.throttleTime(1000, start))
+	a.Equal(time.Duration(0), sensor.throttleTime(0, start))
+
+	sensor.record(1500, start.Add(time.Second))
+	rate, elapsed = sensor.rate(start.Add(2 * time.Second))
+	a.Equal(1000.0, rate)
+	a.Equal(2*time.Second, elapsed)
+	// (1000 - 500) / 500 * 2s
+	a.Equal(2*time.Second, sensor.throttleTime(500, start.Add(2*time.Second)))
+
+	// samples older than the window are not counted
+	rate, elapsed = sensor.rate(start.Add(10 * time.Second))
+	a.Equal(150.0, rate)
+	a.Equal(10*time.Second, elapsed)
+	sensor.record(100, start.Add(20*time.Second))
+	rate, _ = sensor.rate(start.Add(20 * time.Second))
+	a.Equal(10.0, rate)
+}
+
+func TestQuotasThrottleTime(t *testing.T) {
+	a := assert.New(t)
+
+	quotas := NewQuotas(config.PrincipalQuotas{
+		"alice": {ProduceBytesPerSec: 1000, RequestsPerSec: 5},
+		"*":     {FetchBytesPerSec: 100},
+	}, 10*time.Second)
+	a.True(quotas.enabled())
+	a.False((*Quotas)(nil).enabled())
+	a.Nil(quotas.principalSensors(""))
+	// connections of the same principal share the sensors
+	a.True(quotas.principalSensors("alice") == quotas.principalSensors("alice"))
+	a.Equal(config.Quota{FetchBytesPerSec: 100}, quotas.principalSensors("bob").quota)
+
+	now := time.Now()
+	state := &quotaState{}
+	a.False(quotas.resolved(state))
+	quotas.resolve(state, "alice")
+	a.True(quotas.resolved(state))
+
+	quotas.recordRequest(state, apiKeyProduce, 800, now)
+	throttleMs, quota := quotas.throttleTime(state, apiKeyProduce, 50, now)
+	a.EqualValues(0, throttleMs)
+	a.Equal("", quota)
+
+	quotas.recordRequest(state, apiKeyProduce, 1200, now)
+	throttleMs, quota = quotas.throttleTime(state, apiKeyProduce, 50, now)
+	// (2000 - 1000) / 1000 * 1s
+	a.EqualValues(1000, throttleMs)
+	a.Equal(quotaProduce, quota)
+	a.Equal(now.Add(time.Second).UnixNano(), state.throttledUntil)
+
+	for i := 0; i < 10; i++ {
+		quotas.recordRequest(state, apiKeyApiApiVersions, 10, now)
+	}
+	throttleMs, quota = quotas.throttleTime(state, apiKeyApiApiVersions, 50, now)
+	// (12 - 5) / 5 * 1s
+	a.EqualValues(1400, throttleMs)
+	a.Equal(quotaRequest, quota)
+
+	// connections without a principal are not throttled
+	throttleMs, _ = quotas.throttleTime(&quotaState{}, apiKeyProduce, 50, now)
+	a.EqualValues(0, throttleMs)
+}
+
+func TestQuotasUpdate(t *testing.T) {
+	a := assert.New(t)
+
+	quotas := NewQuotas(config.PrincipalQuotas{
+		"alice": {ProduceBytesPerSec: 1000},
+		"bob":   {ProduceBytesPerSec: 1000},
+	}, 10*time.Second)
+	alice, bob := quotas.principalSensors("alice"), quotas.principalSensors("bob")
+	state := &quotaState{}
+	quotas.resolve(state, "carol")
+	a.Nil(state.get())
+
+	quotas.Update(config.PrincipalQuotas{
+		"alice": {ProduceBytesPerSec: 1000},
+		"bob":   {ProduceBytesPerSec: 500},
+		"carol": {RequestsPerSec: 1},
+	}, 10*time.Second)
+	// the open connections resolve the sensors again
+	a.False(quotas.resolved(state))
+	quotas.resolve(state, "carol")
+	a.Equal(config.Quota{RequestsPerSec: 1}, state.get().quota)
+	// the rates of unchanged quotas are kept
+	a.True(alice == quotas.principalSensors("alice"))
+	a.False(bob == quotas.principalSensors("bob"))
+
+	quotas.Update(config.PrincipalQuotas{}, 10*time.Second)
+	a.False(quotas.enabled())
+
+	// quotas configured on reload only
+	quotas = NewQuotas(config.PrincipalQuotas{}, 10*time.Second)
+	a.False(quotas.enabled())
+	quotas.Update(config.PrincipalQuotas{"*": {RequestsPerSec: 1}}, 10*time.Second)
+	a.True(quotas.enabled())
+}
+
+func TestQuotaStateWait(t *testing.T) {
+	a := assert.New(t)
+
+	state := &quotaState{}
+	start := time.Now()
+	state.wait()
+	a.True(time.Since(start) < 50*time.Millisecond)
+
+	state.throttle(time.Now().Add(100 * time.Millisecond))
+	// earlier ends do not shorten the throttle time
+	state.throttle(time.Now())
+	state.wait()
+	a.True(time.Since(start) >= 100*time.Millisecond)
+
+	(*quotaState)(nil).wait()
+}
+
+func TestHandleResponseQuotaThrottle(t *testing.T) {
+	a := assert.New(t)
+
+	// Fetch v11, kafka-client 2.3.1
+	input, err := hex.DecodeString("0000003d0000000200000000000000010011746f7069632d73746172742d6f6c642d3200000001000000000000ffffffffffffffff000000000000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quotas := NewQuotas(config.PrincipalQuotas{"alice": {FetchBytesPerSec: 10}}, time.Second)
+	state := &quotaState{}
+	quotas.resolve(state, "alice")
+
+	openRequestsChannel := make(chan protocol.RequestKeyVersion, 1)
+	openRequestsChannel <- protocol.RequestKeyVersion{ApiKey: apiKeyFetch, ApiVersion: 11}
+	output := bytes.NewBuffer(make([]byte, 0))
+	ctx := &ResponsesLoopContext{openRequestsChannel: openRequestsChannel, timeout: 1 * time.Second, buf: make([]byte, defaultResponseBufferSize),
+		quotas: quotas, quotaState: state}
+
+	_, err = defaultResponseHandler.handleResponse(&TestDeadlineWriter{Buffer: output}, &TestDeadlineReader{Buffer: bytes.NewBuffer(input)}, ctx)
+	a.Nil(err)
+	a.Len(output.Bytes(), len(input))
+	// throttle_time_ms follows the correlation id, capped by the quota window
+	a.EqualValues(1000, binary.BigEndian.Uint32(output.Bytes()[8:12]))
+	a.Equal(input[12:], output.Bytes()[12:])
+	a.True(state.throttledUntil > time.Now().UnixNano())
+}
+
+func TestHandleResponseQuotaThrottleModified(t *testing.T) {
+	a := assert.New(t)
+
+	// Produce v7 response: orders partition 1 and throttle_time_ms at the end
+	body := []byte{0, 0, 0, 1, 0, 6, 'o', 'r', 'd', 'e', 'r', 's', 0, 0, 0, 1, 0, 0, 0, 1, 0, 0}
+	body = append(body, make([]byte, 24)...)
+	body = append(body, 0, 0, 0, 0)
+	input := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(input, uint32(4+len(body)))
+	binary.BigEndian.PutUint32(input[4:], 2)
+	input = append(input, body...)
+
+	quotas := NewQuotas(config.PrincipalQuotas{"alice": {ProduceBytesPerSec: 10}}, time.Second)
+	state := &quotaState{}
+	quotas.resolve(state, "alice")
+	partitionErrors := &producePartitionErrorsState{}
+	// the partition removed from the request is added to the response
+	partitionErrors.put(2, []protocol.ProducePartitionError{{Topic: "orders", Partition: 0, ErrorCode: protocol.ErrUnsupportedCompressionType}})
+
+	openRequestsChannel := make(chan protocol.RequestKeyVersion, 1)
+	openRequestsChannel <- protocol.RequestKeyVersion{ApiKey: apiKeyProduce, ApiVersion: 7}
+	output := bytes.NewBuffer(make([]byte, 0))
+	ctx := &ResponsesLoopContext{openRequestsChannel: openRequestsChannel, timeout: 1 * time.Second, buf: make([]byte, defaultResponseBufferSize),
+		quotas: quotas, quotaState: state, producePartitionErrors: partitionErrors}
+	state.get().recordRequest(apiKeyProduce, 1000, time.Now())
+
+	_, err := defaultResponseHandler.handleResponse(&TestDeadlineWriter{Buffer: output}, &TestDeadlineReader{Buffer: bytes.NewBuffer(input)}, ctx)
+	a.Nil(err)
+	a.True(output.Len() > len(input), "the partition error is added")
+	// throttle_time_ms is the last field of the modified response
+	a.True(binary.BigEndian.Uint32(output.Bytes()[output.Len()-4:]) > 0)
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/grepplabs/kafka-proxy/pkg/apis"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+)
+
+const defaultRecordHeadersCacheSize = 1024
+
+// recordHeadersCache keeps the decoded headers of recently produced record batches, so batches retried by the producers
+// are not decompressed and decoded again. Batches are identified by their SHA-256 hash, as the batch CRC can be forged.
+// The oldest batches are evicted when the cache is full.
+type recordHeadersCache struct {
+	lock    sync.Mutex
+	size    int
+	entries map[[sha256.Size]byte][][]apis.RecordHeader
+	order   [][sha256.Size]byte
+	next    int
+}
+
+func newRecordHeadersCache(size int) *recordHeadersCache {
+	return &recordHeadersCache{
+		size:    size,
+		entries: make(map[[sha256.Size]byte][][]apis.RecordHeader, size),
+		order:   make([][sha256.Size]byte, 0, size),
+	}
+}
+
+// producedRecords returns the record headers of the produce request records
+func (c *recordHeadersCache) producedRecords(request *protocol.ProduceRequest) ([]apis.ProducedRecords, error) {
+	records := make([]apis.ProducedRecords, 0)
+	for _, topicData := range request.TopicData {
+		for _, partitionData := range topicData.PartitionData {
+			produced := apis.ProducedRecords{Topic: topicData.Topic, Partition: partitionData.Partition, Headers: make([][]apis.RecordHeader, 0)}
+			batches, err := protocol.SplitRecords(partitionData.Records)
+			if err != nil {
+				return nil, err
+			}
+			for _, batch := range batches {
+				headers, err := c.batchHeaders(batch)
+				if err != nil {
+					return nil, err
+				}
+				produced.Headers = append(produced.Headers, headers...)
+			}
+			records = append(records, produced)
+		}
+	}
+	return records, nil
+}
+
+func (c *recordHeadersCache) batchHeaders(batch []byte) ([][]apis.RecordHeader, error) {
+	key := sha256.Sum256(batch)
+	c.lock.Lock()
+	headers, ok := c.entries[key]
+	c.lock.Unlock()
+	if ok {
+		proxyInterceptorRecordBatchesTotal.WithLabelValues("cached").Inc()
+		return headers, nil
+	}
+
+	decoded, err := protocol.DecodeRecordHeaders(batch)
+	if err != nil {
+		return nil, err
+	}
+	headers = make([][]apis.RecordHeader, 0, len(decoded))
+	for _, recordHeaders := range decoded {
+		var converted []apis.RecordHeader
+		for _, header := range recordHeaders {
+			converted = append(converted, apis.RecordHeader{Key: header.Key, Value: header.Value})
+		}
+		headers = append(headers, converted)
+	}
+	proxyInterceptorRecordBatchesTotal.WithLabelValues("decoded").Inc()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return headers, nil
+	}
+	if len(c.order) < c.size {
+		c.order = append(c.order, key)
+	} else {
+		delete(c.entries, c.order[c.next])
+		c.order[c.next] = key
+		c.next = (c.next + 1) % c.size
+	}
+	c.entries[key] = headers
+	return headers, nil
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestLatency measures the round-trip time between sending a request to the broker and receiving the response header.
+// Requests and responses are matched by the correlation id. Requests slower than the slow threshold are logged with
+// the api key, the client id, the topics and the sizes of the request and the response.
+type RequestLatency struct {
+	slowThreshold time.Duration
+	pseudonymizer *Pseudonymizer
+}
+
+func NewRequestLatency(enable bool, slowThreshold time.Duration, pseudonymizer *Pseudonymizer) *RequestLatency {
+	if !enable {
+		return nil
+	}
+	return &RequestLatency{slowThreshold: slowThreshold, pseudonymizer: pseudonymizer}
+}
+
+func (l *RequestLatency) enabled() bool {
+	return l != nil
+}
+
+func (l *RequestLatency) slowLogEnabled() bool {
+	return l != nil && l.slowThreshold > 0
+}
+
+// sentRequest is the request awaiting the response
+type sentRequest struct {
+	apiKey     int16
+	apiVersion int16
+	size       int32
+	clientID   string
+	topics     []string
+	sentAt     time.Time
+}
+
+// requestLatencyState is shared by the requests and responses loops of a connection
+type requestLatencyState struct {
+	mu sync.Mutex
+	// correlation id to the request sent to the broker
+	sent map[int32]sentRequest
+}
+
+func (s *requestLatencyState) put(correlationID int32, request sentRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent == nil {
+		s.sent = make(map[int32]sentRequest)
+	}
+	s.sent[correlationID] = request
+}
+
+func (s *requestLatencyState) take(correlationID int32) (sentRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request, ok := s.sent[correlationID]
+	delete(s.sent, correlationID)
+	return request, ok
+}
+
+// readRequest reads the part of the request body following the api key and version which is needed to record the request.
+// The whole request is buffered for the slow log to decode the topics, otherwise only the correlation id is read.
+func (l *RequestLatency) readRequest(src io.Reader, requestKeyVersion *protocol.RequestKeyVersion, readBytes []byte) ([]byte, error) {
+	if l.slowLogEnabled() {
+		return readRemainingRequest(src, requestKeyVersion, readBytes)
+	}
+	// CorrelationID (INT32)
+	remaining := 4 - len(readBytes)
+	if remaining <= 0 || int(requestKeyVersion.Length)-4 < 4 {
+		return readBytes, nil
+	}
+	body := make([]byte, 4)
+	copy(body, readBytes)
+	if _, err := io.ReadFull(src, body[len(readBytes):]); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// requestSent records the request before it is written to the broker, body is the request body following the api key and version
+func (l *RequestLatency) requestSent(state *requestLatencyState, requestKeyVersion *protocol.RequestKeyVersion, body []byte, now time.Time) {
+	if len(body) < 4 {
+		return
+	}
+	request := sentRequest{
+		apiKey:     requestKeyVersion.ApiKey,
+		apiVersion: requestKeyVersion.ApiVersion,
+		size:       requestKeyVersion.Length + 4,
+		sentAt:     now,
+	}
+	correlationID := int32(binary.BigEndian.Uint32(body))
+	if l.slowLogEnabled() {
+		summary := &protocol.RequestSummary{ApiKey: requestKeyVersion.ApiKey, ApiVersion: requestKeyVersion.ApiVersion}
+		if err := protocol.Decode(body, summary); err != nil {
+			logrus.Debugf("Topics of request key %d version %d could not be decoded: %v", requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion, err)
+		} else {
+			if summary.ClientID != nil {
+				request.clientID = *summary.ClientID
+			}
+			request.topics = summary.Topics
+		}
+	}
+	state.put(correlationID, request)
+}
+
+// responseReceived observes the latency of the request after the response header was read
+func (l *RequestLatency) responseReceived(state *requestLatencyState, brokerAddress string, responseHeader *protocol.ResponseHeader, now time.Time, log *logrus.Entry) {
+	request, ok := state.take(responseHeader.CorrelationID)
+	if !ok {
+		return
+	}
+	latency := now.Sub(request.sentAt)
+	apiKey := strconv.Itoa(int(request.apiKey))
+	proxyRequestLatencySeconds.WithLabelValues(brokerAddress, apiKey).Observe(latency.Seconds())
+
+	if !l.slowLogEnabled() || latency < l.slowThreshold {
+		return
+	}
+	proxySlowRequestsTotal.WithLabelValues(brokerAddress, apiKey).Inc()
+	log.Warnf("Slow request to %s: api key %d version %d, client id %s, topics [%s], request %d bytes, response %d bytes, latency %v",
+		brokerAddress, request.apiKey, request.apiVersion, l.pseudonymizer.clientID(request.clientID), strings.Join(request.topics, ","),
+		request.size, responseHeader.Length+4, latency)
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// Metadata v1 request body with correlation id 7, client id "client" and the topics foo and bar
+const metadataRequestBodyHex = "00000007" + "0006636c69656e74" + "00000002" + "0003666f6f" + "0003626172"
+
+func TestRequestLatencyDisabled(t *testing.T) {
+	a := assert.New(t)
+
+	latency := NewRequestLatency(false, time.Second, nil)
+	a.Nil(latency)
+	a.False(latency.enabled())
+	a.False(latency.slowLogEnabled())
+}
+
+func TestRequestLatencyReadsCorrelationID(t *testing.T) {
+	a := assert.New(t)
+
+	body, err := hex.DecodeString(metadataRequestBodyHex)
+	a.Nil(err)
+	requestKeyVersion := &protocol.RequestKeyVersion{Length: int32(4 + len(body)), ApiKey: 3, ApiVersion: 1}
+
+	latency := NewRequestLatency(true, 0, nil)
+	src := bytes.NewBuffer(body[2:])
+	readBytes, err := latency.readRequest(src, requestKeyVersion, body[:2])
+	a.Nil(err)
+	a.Equal(body[:4], readBytes)
+	a.Equal(body[4:], src.Bytes(), "only the correlation id is read")
+
+	state := &requestLatencyState{}
+	sentAt := time.Now()
+	latency.requestSent(state, requestKeyVersion, readBytes, sentAt)
+	a.Equal(sentRequest{apiKey: 3, apiVersion: 1, size: requestKeyVersion.Length + 4, sentAt: sentAt}, state.sent[7])
+
+	latency.responseReceived(state, "kafka-0:9092", &protocol.ResponseHeader{Length: 100, CorrelationID: 7}, sentAt.Add(time.Millisecond), subsystemLog(logSubsystemRequests))
+	a.Empty(state.sent)
+	// unknown correlation ids e.g. of the re-authentication are ignored
+	latency.responseReceived(state, "kafka-0:9092", &protocol.ResponseHeader{Length: 100, CorrelationID: -1}, sentAt.Add(time.Millisecond), subsystemLog(logSubsystemRequests))
+}
+
+func TestRequestLatencySlowLogDecodesTopics(t *testing.T) {
+	a := assert.New(t)
+
+	body, err := hex.DecodeString(metadataRequestBodyHex)
+	a.Nil(err)
+	requestKeyVersion := &protocol.RequestKeyVersion{Length: int32(4 + len(body)), ApiKey: 3, ApiVersion: 1}
+
+	latency := NewRequestLatency(true, 10*time.Millisecond, nil)
+	a.True(latency.slowLogEnabled())
+	src := bytes.NewBuffer(body[4:])
+	readBytes, err := latency.readRequest(src, requestKeyVersion, body[:4])
+	a.Nil(err)
+	a.Equal(body, readBytes, "the whole request is buffered")
+
+	state := &requestLatencyState{}
+	sentAt := time.Now()
+	latency.requestSent(state, requestKeyVersion, readBytes, sentAt)
+	request := state.sent[7]
+	a.Equal("client", request.clientID)
+	a.Equal([]string{"foo", "bar"}, request.topics)
+
+	latency.responseReceived(state, "kafka-0:9092", &protocol.ResponseHeader{Length: 100, CorrelationID: 7}, sentAt.Add(time.Second), subsystemLog(logSubsystemRequests))
+	a.Empty(state.sent)
+}
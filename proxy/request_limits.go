@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestLimits rejects requests larger than the maximum request size and produced record batches larger than the maximum batch size
+// before they are forwarded. Produce requests are forwarded without the rejected partitions and the client receives
+// the MESSAGE_TOO_LARGE error for them.
+type RequestLimits struct {
+	maxRequestSize int32
+	maxBatchSize   int32
+}
+
+func NewRequestLimits(maxRequestSize int, maxBatchSize int) *RequestLimits {
+	if maxRequestSize <= 0 && maxBatchSize <= 0 {
+		return nil
+	}
+	return &RequestLimits{maxRequestSize: int32(maxRequestSize), maxBatchSize: int32(maxBatchSize)}
+}
+
+func (l *RequestLimits) enabled() bool {
+	return l != nil
+}
+
+// applyRequest enforces the limits on the request body following the api key and version. The body read so far is passed in
+// readBytes, the returned body replaces the request body when it was changed.
+func (l *RequestLimits) applyRequest(src io.Reader, requestKeyVersion *protocol.RequestKeyVersion, keyVersionBuf []byte, readBytes []byte, state *producePartitionErrorsState, brokerAddress string, log *logrus.Entry) ([]byte, error) {
+	if l.maxRequestSize > 0 && requestKeyVersion.Length > l.maxRequestSize {
+		proxyRequestLimitsRejectedTotal.WithLabelValues(brokerAddress, "request_size").Inc()
+		if requestKeyVersion.ApiKey != apiKeyProduce {
+			return nil, fmt.Errorf("request of length %d exceeds the maximum request size %d", requestKeyVersion.Length, l.maxRequestSize)
+		}
+		// the records are discarded without buffering the request and all partitions are rejected
+		reader := io.MultiReader(bytes.NewReader(readBytes), src)
+		request, err := protocol.DiscardProduceRequest(reader, requestKeyVersion.ApiVersion, requestKeyVersion.Length-4)
+		if err != nil {
+			return nil, err
+		}
+		reason := fmt.Sprintf("produce request of length %d exceeds the maximum request size %d", requestKeyVersion.Length, l.maxRequestSize)
+		log.Infof("Produce request is rejected (%s): %s", brokerAddress, reason)
+		var partitionErrors []protocol.ProducePartitionError
+		for _, topicData := range request.TopicData {
+			for _, partitionData := range topicData.PartitionData {
+				partitionErrors = append(partitionErrors, messageTooLarge(topicData.Topic, partitionData.Partition, reason))
+			}
+		}
+		return l.forward(request, partitionErrors, requestKeyVersion, keyVersionBuf, state)
+	}
+	if l.maxBatchSize > 0 && requestKeyVersion.ApiKey == apiKeyProduce {
+		// the whole produce request is buffered to check the record batches
+		body, err := readRemainingRequest(src, requestKeyVersion, readBytes)
+		if err != nil {
+			return nil, err
+		}
+		request := &protocol.ProduceRequest{Version: requestKeyVersion.ApiVersion}
+		if err = protocol.Decode(body, request); err != nil {
+			return nil, err
+		}
+		var partitionErrors []protocol.ProducePartitionError
+		for _, topicData := range request.TopicData {
+			for _, partitionData := range topicData.PartitionData {
+				batchSize, err := protocol.MaxBatchSize(partitionData.Records)
+				if err != nil {
+					return nil, err
+				}
+				if batchSize > int(l.maxBatchSize) {
+					proxyRequestLimitsRejectedTotal.WithLabelValues(brokerAddress, "batch_size").Inc()
+					reason := fmt.Sprintf("record batch of size %d exceeds the maximum batch size %d", batchSize, l.maxBatchSize)
+					log.Infof("Produce to topic %s partition %d is rejected (%s): %s", topicData.Topic, partitionData.Partition, brokerAddress, reason)
+					partitionErrors = append(partitionErrors, messageTooLarge(topicData.Topic, partitionData.Partition, reason))
+				}
+			}
+		}
+		if len(partitionErrors) == 0 {
+			return body, nil
+		}
+		return l.forward(request, partitionErrors, requestKeyVersion, keyVersionBuf, state)
+	}
+	return readBytes, nil
+}
+
+// forward re-encodes the produce request without the rejected partitions
+func (l *RequestLimits) forward(request *protocol.ProduceRequest, partitionErrors []protocol.ProducePartitionError,
+	requestKeyVersion *protocol.RequestKeyVersion, keyVersionBuf []byte, state *producePartitionErrorsState) ([]byte, error) {
+	body, err := rejectProducePartitions(request, partitionErrors, state)
+	if err != nil {
+		return nil, err
+	}
+	setRequestLength(requestKeyVersion, keyVersionBuf, body)
+	return body, nil
+}
+
+func messageTooLarge(topic string, partition int32, reason string) protocol.ProducePartitionError {
+	return protocol.ProducePartitionError{Topic: topic, Partition: partition, ErrorCode: protocol.ErrMessageSizeTooLarge, ErrorMessage: &reason}
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func produceKeyVersion(body []byte) (*protocol.RequestKeyVersion, []byte) {
+	requestKeyVersion := &protocol.RequestKeyVersion{ApiKey: apiKeyProduce, ApiVersion: 3, Length: int32(4 + len(body))}
+	keyVersionBuf := make([]byte, 8)
+	binary.BigEndian.PutUint32(keyVersionBuf, uint32(requestKeyVersion.Length))
+	binary.BigEndian.PutUint16(keyVersionBuf[6:], uint16(requestKeyVersion.ApiVersion))
+	return requestKeyVersion, keyVersionBuf
+}
+
+func TestRequestLimitsMaxRequestSize(t *testing.T) {
+	a := assert.New(t)
+
+	limits := NewRequestLimits(100, 0)
+	state := &producePartitionErrorsState{}
+	body := produceRequestBody(t, "orders", string(make([]byte, 200)))
+	requestKeyVersion, keyVersionBuf := produceKeyVersion(body)
+
+	// the produce header was already read to find out the acks
+	src := bytes.NewReader(body[10:])
+	forwarded, err := limits.applyRequest(src, requestKeyVersion, keyVersionBuf, body[:10], state, "kafka-0:9092", subsystemLog(logSubsystemRequests))
+	a.Nil(err)
+	a.Equal(0, src.Len())
+	a.Equal(int32(4+len(forwarded)), requestKeyVersion.Length)
+	a.Equal(uint32(requestKeyVersion.Length), binary.BigEndian.Uint32(keyVersionBuf))
+
+	decoded := &protocol.ProduceRequest{Version: 3}
+	a.Nil(protocol.Decode(forwarded, decoded))
+	a.Empty(decoded.TopicData)
+
+	partitionErrors := state.take(1)
+	a.Len(partitionErrors, 1)
+	a.Equal("orders", partitionErrors[0].Topic)
+	a.Equal(protocol.ErrMessageSizeTooLarge, partitionErrors[0].ErrorCode)
+	a.Equal(fmt.Sprintf("produce request of length %d exceeds the maximum request size 100", 4+len(body)), *partitionErrors[0].ErrorMessage)
+
+	// other requests close the connection
+	requestKeyVersion = &protocol.RequestKeyVersion{ApiKey: apiKeyFetch, ApiVersion: 4, Length: 101}
+	_, err = limits.applyRequest(bytes.NewReader(nil), requestKeyVersion, make([]byte, 8), nil, state, "kafka-0:9092", subsystemLog(logSubsystemRequests))
+	a.EqualError(err, "request of length 101 exceeds the maximum request size 100")
+}
+
+func TestRequestLimitsMaxBatchSize(t *testing.T) {
+	a := assert.New(t)
+
+	limits := NewRequestLimits(0, 100)
+	state := &producePartitionErrorsState{}
+	small, large := uncompressedRecordBatch("small"), uncompressedRecordBatch(string(make([]byte, 100)))
+	clientID := "producer"
+	body, err := protocol.Encode(&protocol.ProduceRequest{
+		Version:       3,
+		CorrelationID: 5,
+		ClientID:      &clientID,
+		Acks:          1,
+		Timeout:       1000,
+		TopicData: []protocol.ProduceTopicData{
+			{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: small}, {Partition: 1, Records: large}}},
+			{Topic: "payments", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: large}}},
+		},
+	})
+	a.Nil(err)
+	requestKeyVersion, keyVersionBuf := produceKeyVersion(body)
+
+	forwarded, err := limits.applyRequest(bytes.NewReader(body), requestKeyVersion, keyVersionBuf, nil, state, "kafka-0:9092", subsystemLog(logSubsystemRequests))
+	a.Nil(err)
+	decoded := &protocol.ProduceRequest{Version: 3}
+	a.Nil(protocol.Decode(forwarded, decoded))
+	a.Equal([]protocol.ProduceTopicData{{Topic: "orders", PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: small}}}}, decoded.TopicData)
+
+	partitionErrors := state.take(5)
+	a.Len(partitionErrors, 2)
+	a.Equal([]string{"orders", "payments"}, []string{partitionErrors[0].Topic, partitionErrors[1].Topic})
+	a.Equal(int32(1), partitionErrors[0].Partition)
+	a.Nil(state.take(5))
+
+	// unchanged request
+	body = produceRequestBody(t, "orders", "small")
+	requestKeyVersion, keyVersionBuf = produceKeyVersion(body)
+	forwarded, err = limits.applyRequest(bytes.NewReader(body), requestKeyVersion, keyVersionBuf, nil, state, "kafka-0:9092", subsystemLog(logSubsystemRequests))
+	a.Nil(err)
+	a.Equal(body, forwarded)
+	a.Nil(state.take(1))
+
+	a.False(NewRequestLimits(0, 0).enabled())
+}
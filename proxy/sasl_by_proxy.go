@@ -36,6 +36,9 @@ type SASLOAuthBearerAuth struct {
 	readTimeout  time.Duration
 
 	tokenProvider apis.TokenProvider
+
+	// SaslAuthenticate v1 is used to receive the session lifetime for the re-authentication (KIP-368)
+	reauthentication bool
 }
 
 type SASLPlainAuth struct {
@@ -44,8 +47,7 @@ type SASLPlainAuth struct {
 	writeTimeout time.Duration
 	readTimeout  time.Duration
 
-	username string
-	password string
+	credentials *SASLCredentials
 }
 
 type SASLAuthByProxy interface {
@@ -86,10 +88,11 @@ func (b *SASLPlainAuth) sendAndReceiveSASLAuth(conn DeadlineReaderWriter) error
 func (b *SASLPlainAuth) sendSaslAuthenticateRequest(conn DeadlineReaderWriter) error {
 	logrus.Debugf("Sending authentication opaque packets, mechanism PLAIN")
 
-	length := 1 + len(b.username) + 1 + len(b.password)
+	username, password := b.credentials.get()
+	length := 1 + len(username) + 1 + len(password)
 	authBytes := make([]byte, length+4) //4 byte length header + auth data
 	binary.BigEndian.PutUint32(authBytes, uint32(length))
-	copy(authBytes[4:], []byte("\x00"+b.username+"\x00"+b.password))
+	copy(authBytes[4:], []byte("\x00"+username+"\x00"+password))
 
 	err := conn.SetWriteDeadline(time.Now().Add(b.writeTimeout))
 	if err != nil {
@@ -111,7 +114,7 @@ func (b *SASLPlainAuth) sendSaslAuthenticateRequest(conn DeadlineReaderWriter) e
 	// Otherwise, the broker closes the connection and we get an EOF
 	if err != nil {
 		if err == io.EOF {
-			return fmt.Errorf("SASL/PLAIN auth for user %s failed", b.username)
+			return fmt.Errorf("SASL/PLAIN auth for user %s failed", username)
 		}
 		return errors.Wrap(err, "Failed to read response while authenticating with SASL")
 	}
@@ -186,10 +189,15 @@ func (b *SASLOAuthBearerAuth) getOAuthBearerToken() (string, error) {
 }
 
 func (b *SASLOAuthBearerAuth) sendAndReceiveSASLAuth(conn DeadlineReaderWriter) error {
+	_, err := b.authenticate(conn)
+	return err
+}
 
+// authenticate returns the session lifetime of the broker, zero if the session does not expire or the reauthentication is disabled
+func (b *SASLOAuthBearerAuth) authenticate(conn DeadlineReaderWriter) (time.Duration, error) {
 	token, err := b.getOAuthBearerToken()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	saslHandshake := &SASLHandshake{
 		clientID:     b.clientID,
@@ -200,62 +208,79 @@ func (b *SASLOAuthBearerAuth) sendAndReceiveSASLAuth(conn DeadlineReaderWriter)
 	}
 	handshakeErr := saslHandshake.sendAndReceiveHandshake(conn)
 	if handshakeErr != nil {
-		return handshakeErr
+		return 0, handshakeErr
 	}
 	return b.sendSaslAuthenticateRequest(token, conn)
 }
 
-func (b *SASLOAuthBearerAuth) sendSaslAuthenticateRequest(token string, conn DeadlineReaderWriter) error {
-	logrus.Debugf("Sending SaslAuthenticateRequest, mechanism OAUTHBEARER")
-
-	saslAuthReqV0 := protocol.SaslAuthenticateRequestV0{SaslAuthBytes: SaslOAuthBearer{}.ToBytes(token, "", make(map[string]string, 0))}
+// saslAuthenticateRequest returns the SaslAuthenticate request with the token, version 1 if the reauthentication is enabled
+func (b *SASLOAuthBearerAuth) saslAuthenticateRequest(token string, correlationID int32) *protocol.Request {
+	saslAuthBytes := SaslOAuthBearer{}.ToBytes(token, "", make(map[string]string, 0))
+	if b.reauthentication {
+		return &protocol.Request{ClientID: b.clientID, CorrelationID: correlationID, Body: &protocol.SaslAuthenticateRequestV1{SaslAuthBytes: saslAuthBytes}}
+	}
+	return &protocol.Request{ClientID: b.clientID, CorrelationID: correlationID, Body: &protocol.SaslAuthenticateRequestV0{SaslAuthBytes: saslAuthBytes}}
+}
 
-	req := &protocol.Request{
-		ClientID: b.clientID,
-		Body:     &saslAuthReqV0,
+// decodeSaslAuthenticateResponse returns the session lifetime of the SaslAuthenticate response
+func (b *SASLOAuthBearerAuth) decodeSaslAuthenticateResponse(payload []byte) (time.Duration, error) {
+	if !b.reauthentication {
+		res := &protocol.SaslAuthenticateResponseV0{}
+		if err := protocol.Decode(payload, res); err != nil {
+			return 0, errors.Wrap(err, "Failed to parse SASL auth response")
+		}
+		if res.Err != protocol.ErrNoError {
+			return 0, errors.Wrapf(res.Err, "SASL authentication failed, error message is '%v'", res.ErrMsg)
+		}
+		return 0, nil
 	}
-	reqBuf, err := protocol.Encode(req)
+	res := &protocol.SaslAuthenticateResponseV1{}
+	if err := protocol.Decode(payload, res); err != nil {
+		return 0, errors.Wrap(err, "Failed to parse SASL auth response")
+	}
+	if res.Err != protocol.ErrNoError {
+		return 0, errors.Wrapf(res.Err, "SASL authentication failed, error message is '%v'", res.ErrMsg)
+	}
+	return time.Duration(res.SessionLifetimeMs) * time.Millisecond, nil
+}
+
+func (b *SASLOAuthBearerAuth) sendSaslAuthenticateRequest(token string, conn DeadlineReaderWriter) (time.Duration, error) {
+	logrus.Debugf("Sending SaslAuthenticateRequest, mechanism OAUTHBEARER")
+
+	reqBuf, err := protocol.Encode(b.saslAuthenticateRequest(token, 0))
 	if err != nil {
-		return err
+		return 0, err
 	}
 	sizeBuf := make([]byte, 4)
 	binary.BigEndian.PutUint32(sizeBuf, uint32(len(reqBuf)))
 
 	err = conn.SetWriteDeadline(time.Now().Add(b.writeTimeout))
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	_, err = conn.Write(bytes.Join([][]byte{sizeBuf, reqBuf}, nil))
 	if err != nil {
-		return errors.Wrap(err, "Failed to send SASL auth request")
+		return 0, errors.Wrap(err, "Failed to send SASL auth request")
 	}
 
 	err = conn.SetReadDeadline(time.Now().Add(b.readTimeout))
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	//wait for the response
 	header := make([]byte, 8) // response header
 	_, err = io.ReadFull(conn, header)
 	if err != nil {
-		return errors.Wrap(err, "Failed to read SASL auth header")
+		return 0, errors.Wrap(err, "Failed to read SASL auth header")
 	}
 	length := binary.BigEndian.Uint32(header[:4])
 	payload := make([]byte, length-4)
 	_, err = io.ReadFull(conn, payload)
 	if err != nil {
-		return errors.Wrap(err, "Failed to read SASL auth payload")
+		return 0, errors.Wrap(err, "Failed to read SASL auth payload")
 	}
 
-	res := &protocol.SaslAuthenticateResponseV0{}
-	err = protocol.Decode(payload, res)
-	if err != nil {
-		return errors.Wrap(err, "Failed to parse SASL auth response")
-	}
-	if res.Err != protocol.ErrNoError {
-		return errors.Wrapf(res.Err, "SASL authentication failed, error message is '%v'", res.ErrMsg)
-	}
-	return nil
+	return b.decodeSaslAuthenticateResponse(payload)
 }
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"sync/atomic"
+)
+
+// SASLCredentials are the username and password of the SASL PLAIN and SCRAM authentication by proxy. The credentials can be
+// replaced while the proxy runs, new broker connections authenticate with the current ones and existing connections are kept.
+type SASLCredentials struct {
+	value atomic.Value // saslCredential
+}
+
+type saslCredential struct {
+	username string
+	password string
+}
+
+func NewSASLCredentials(username, password string) *SASLCredentials {
+	credentials := &SASLCredentials{}
+	credentials.Set(username, password)
+	return credentials
+}
+
+// Set replaces the credentials and reports whether they changed
+func (c *SASLCredentials) Set(username, password string) bool {
+	credential := saslCredential{username: username, password: password}
+	previous, ok := c.value.Load().(saslCredential)
+	c.value.Store(credential)
+	return !ok || previous != credential
+}
+
+func (c *SASLCredentials) get() (string, string) {
+	current := c.value.Load().(saslCredential)
+	return current.username, current.password
+}
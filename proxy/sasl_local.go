@@ -22,16 +22,23 @@ type LocalSaslParams struct {
 	timeout               time.Duration
 	passwordAuthenticator apis.PasswordAuthenticator
 	tokenAuthenticator    apis.TokenInfo
+	pseudonymizer         *Pseudonymizer
+	// claim of the verified token used as principal instead of the subject
+	principalClaim string
 }
 
 func NewLocalSasl(params LocalSaslParams) *LocalSasl {
 	localAuthenticators := make(map[string]LocalSaslAuth)
 	if params.passwordAuthenticator != nil {
-		localAuthenticators[SASLPlain] = NewLocalSaslPlain(params.passwordAuthenticator)
+		plain := NewLocalSaslPlain(params.passwordAuthenticator)
+		plain.pseudonymizer = params.pseudonymizer
+		localAuthenticators[SASLPlain] = plain
 	}
 
 	if params.tokenAuthenticator != nil {
-		localAuthenticators[SASLOAuthBearer] = NewLocalSaslOauth(params.tokenAuthenticator)
+		oauth := NewLocalSaslOauth(params.tokenAuthenticator)
+		oauth.principalClaim = params.principalClaim
+		localAuthenticators[SASLOAuthBearer] = oauth
 	}
 	return &LocalSasl{
 		enabled:             params.enabled,
@@ -40,26 +47,26 @@ func NewLocalSasl(params LocalSaslParams) *LocalSasl {
 	}
 }
 
-func (p *LocalSasl) receiveAndSendSASLAuthV1(conn DeadlineReaderWriter, readKeyVersionBuf []byte) (err error) {
+func (p *LocalSasl) receiveAndSendSASLAuthV1(conn DeadlineReaderWriter, readKeyVersionBuf []byte) (principal string, expiry time.Time, err error) {
 	var localSaslAuth LocalSaslAuth
 	if localSaslAuth, err = p.receiveAndSendSaslV0orV1(conn, readKeyVersionBuf, 1); err != nil {
-		return err
+		return "", time.Time{}, err
 	}
-	if err = p.receiveAndSendAuthV1(conn, localSaslAuth); err != nil {
-		return err
+	if principal, expiry, err = p.receiveAndSendAuthV1(conn, localSaslAuth); err != nil {
+		return "", time.Time{}, err
 	}
-	return nil
+	return principal, expiry, nil
 }
 
-func (p *LocalSasl) receiveAndSendSASLAuthV0(conn DeadlineReaderWriter, readKeyVersionBuf []byte) (err error) {
+func (p *LocalSasl) receiveAndSendSASLAuthV0(conn DeadlineReaderWriter, readKeyVersionBuf []byte) (principal string, expiry time.Time, err error) {
 	var localSaslAuth LocalSaslAuth
 	if localSaslAuth, err = p.receiveAndSendSaslV0orV1(conn, readKeyVersionBuf, 0); err != nil {
-		return err
+		return "", time.Time{}, err
 	}
-	if err = p.receiveAndSendAuthV0(conn, localSaslAuth); err != nil {
-		return err
+	if principal, expiry, err = p.receiveAndSendAuthV0(conn, localSaslAuth); err != nil {
+		return "", time.Time{}, err
 	}
-	return nil
+	return principal, expiry, nil
 }
 
 func (p *LocalSasl) receiveAndSendSaslV0orV1(conn DeadlineReaderWriter, keyVersionBuf []byte, version int16) (localSaslAuth LocalSaslAuth, err error) {
@@ -127,32 +134,32 @@ func (p *LocalSasl) receiveAndSendSaslV0orV1(conn DeadlineReaderWriter, keyVersi
 	return localSaslAuth, saslResult
 }
 
-func (p *LocalSasl) receiveAndSendAuthV1(conn DeadlineReaderWriter, localSaslAuth LocalSaslAuth) (err error) {
+func (p *LocalSasl) receiveAndSendAuthV1(conn DeadlineReaderWriter, localSaslAuth LocalSaslAuth) (principal string, expiry time.Time, err error) {
 	requestDeadline := time.Now().Add(p.timeout)
 	err = conn.SetDeadline(requestDeadline)
 	if err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 
 	keyVersionBuf := make([]byte, 8) // Size => int32 + ApiKey => int16 + ApiVersion => int16
 	if _, err = io.ReadFull(conn, keyVersionBuf); err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 	requestKeyVersion := &protocol.RequestKeyVersion{}
 	if err = protocol.Decode(keyVersionBuf, requestKeyVersion); err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 	if requestKeyVersion.ApiKey != 36 {
-		return errors.Errorf("SaslAuthenticate is expected, but got apiKey %d", requestKeyVersion.ApiKey)
+		return "", time.Time{}, errors.Errorf("SaslAuthenticate is expected, but got apiKey %d", requestKeyVersion.ApiKey)
 	}
 
 	if requestKeyVersion.Length > protocol.MaxRequestSize {
-		return protocol.PacketDecodingError{Info: fmt.Sprintf("sasl authenticate message of length %d too large", requestKeyVersion.Length)}
+		return "", time.Time{}, protocol.PacketDecodingError{Info: fmt.Sprintf("sasl authenticate message of length %d too large", requestKeyVersion.Length)}
 	}
 
 	resp := make([]byte, int(requestKeyVersion.Length-4))
 	if _, err = io.ReadFull(conn, resp); err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 	payload := bytes.Join([][]byte{keyVersionBuf[4:], resp}, nil)
 
@@ -161,10 +168,10 @@ func (p *LocalSasl) receiveAndSendAuthV1(conn DeadlineReaderWriter, localSaslAut
 		saslAuthReqV0 := &protocol.SaslAuthenticateRequestV0{}
 		req := &protocol.Request{Body: saslAuthReqV0}
 		if err = protocol.Decode(payload, req); err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 
-		authErr := localSaslAuth.doLocalAuth(saslAuthReqV0.SaslAuthBytes)
+		principal, expiry, authErr := localSaslAuth.doLocalAuth(saslAuthReqV0.SaslAuthBytes)
 
 		var saslAuthResV0 *protocol.SaslAuthenticateResponseV0
 		if authErr == nil {
@@ -176,28 +183,28 @@ func (p *LocalSasl) receiveAndSendAuthV1(conn DeadlineReaderWriter, localSaslAut
 		}
 		newResponseBuf, err := protocol.Encode(saslAuthResV0)
 		if err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 
 		newHeaderBuf, err := protocol.Encode(&protocol.ResponseHeader{Length: int32(len(newResponseBuf) + 4), CorrelationID: req.CorrelationID})
 		if err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 		if _, err := conn.Write(newHeaderBuf); err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 		if _, err := conn.Write(newResponseBuf); err != nil {
-			return err
+			return "", time.Time{}, err
 		}
-		return authErr
+		return principal, expiry, authErr
 	case 1:
 		saslAuthReqV1 := &protocol.SaslAuthenticateRequestV1{}
 		req := &protocol.Request{Body: saslAuthReqV1}
 		if err = protocol.Decode(payload, req); err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 
-		authErr := localSaslAuth.doLocalAuth(saslAuthReqV1.SaslAuthBytes)
+		principal, expiry, authErr := localSaslAuth.doLocalAuth(saslAuthReqV1.SaslAuthBytes)
 
 		var saslAuthResV1 *protocol.SaslAuthenticateResponseV1
 		if authErr == nil {
@@ -209,28 +216,28 @@ func (p *LocalSasl) receiveAndSendAuthV1(conn DeadlineReaderWriter, localSaslAut
 		}
 		newResponseBuf, err := protocol.Encode(saslAuthResV1)
 		if err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 
 		newHeaderBuf, err := protocol.Encode(&protocol.ResponseHeader{Length: int32(len(newResponseBuf) + 4), CorrelationID: req.CorrelationID})
 		if err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 		if _, err := conn.Write(newHeaderBuf); err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 		if _, err := conn.Write(newResponseBuf); err != nil {
-			return err
+			return "", time.Time{}, err
 		}
-		return authErr
+		return principal, expiry, authErr
 	case 2:
 		saslAuthReqV2 := &protocol.SaslAuthenticateRequestV2{}
 		req := &protocol.RequestV2{Body: saslAuthReqV2}
 		if err = protocol.Decode(payload, req); err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 
-		authErr := localSaslAuth.doLocalAuth(saslAuthReqV2.SaslAuthBytes)
+		principal, expiry, authErr := localSaslAuth.doLocalAuth(saslAuthReqV2.SaslAuthBytes)
 
 		var saslAuthResV2 *protocol.SaslAuthenticateResponseV2
 		if authErr == nil {
@@ -242,60 +249,60 @@ func (p *LocalSasl) receiveAndSendAuthV1(conn DeadlineReaderWriter, localSaslAut
 		}
 		newResponseBuf, err := protocol.Encode(saslAuthResV2)
 		if err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 		// 2 (Length) + 2 (CorrelationID) + 1 (empty TaggedFields)
 		newHeaderBuf, err := protocol.Encode(&protocol.ResponseHeaderV1{Length: int32(len(newResponseBuf) + 5), CorrelationID: req.CorrelationID})
 		if err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 		if _, err := conn.Write(newHeaderBuf); err != nil {
-			return err
+			return "", time.Time{}, err
 		}
 		if _, err := conn.Write(newResponseBuf); err != nil {
-			return err
+			return "", time.Time{}, err
 		}
-		return authErr
+		return principal, expiry, authErr
 	default:
-		return errors.Errorf("SaslAuthenticate version 0,1 or 2 is expected, apiVersion %d", requestKeyVersion.ApiVersion)
+		return "", time.Time{}, errors.Errorf("SaslAuthenticate version 0,1 or 2 is expected, apiVersion %d", requestKeyVersion.ApiVersion)
 	}
 }
 
-func (p *LocalSasl) receiveAndSendAuthV0(conn DeadlineReaderWriter, localSaslAuth LocalSaslAuth) (err error) {
+func (p *LocalSasl) receiveAndSendAuthV0(conn DeadlineReaderWriter, localSaslAuth LocalSaslAuth) (principal string, expiry time.Time, err error) {
 	requestDeadline := time.Now().Add(p.timeout)
 	err = conn.SetDeadline(requestDeadline)
 	if err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 
 	sizeBuf := make([]byte, 4) // Size => int32
 	if _, err = io.ReadFull(conn, sizeBuf); err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 
 	length := binary.BigEndian.Uint32(sizeBuf)
 	if int32(length) > protocol.MaxRequestSize {
-		return protocol.PacketDecodingError{Info: fmt.Sprintf("auth message of length %d too large", length)}
+		return "", time.Time{}, protocol.PacketDecodingError{Info: fmt.Sprintf("auth message of length %d too large", length)}
 	}
 
 	saslAuthBytes := make([]byte, length)
 	_, err = io.ReadFull(conn, saslAuthBytes)
 	if err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 
 	if localSaslAuth == nil {
-		return errors.New("localSaslAuth is nil")
+		return "", time.Time{}, errors.New("localSaslAuth is nil")
 	}
 
-	if err = localSaslAuth.doLocalAuth(saslAuthBytes); err != nil {
-		return err
+	if principal, expiry, err = localSaslAuth.doLocalAuth(saslAuthBytes); err != nil {
+		return "", time.Time{}, err
 	}
 	// If the credentials are valid, we would write a 4 byte response filled with null characters.
 	// Otherwise, the closes the connection i.e. return error
 	header := make([]byte, 4)
 	if _, err := conn.Write(header); err != nil {
-		return err
+		return "", time.Time{}, err
 	}
-	return nil
+	return principal, expiry, nil
 }
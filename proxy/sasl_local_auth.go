@@ -7,6 +7,7 @@ import (
 	"github.com/grepplabs/kafka-proxy/proxy/protocol"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type errLocalAuthFailed struct {
@@ -17,12 +18,21 @@ func (e errLocalAuthFailed) Error() string {
 	return fmt.Sprintf("user %s authentication failed", e.user)
 }
 
+type errLocalOauthFailed struct {
+	status int32
+}
+
+func (e errLocalOauthFailed) Error() string {
+	return fmt.Sprintf("local oauth verify token failed with status: %d", e.status)
+}
+
 type LocalSaslAuth interface {
-	doLocalAuth(saslAuthBytes []byte) (err error)
+	doLocalAuth(saslAuthBytes []byte) (principal string, expiry time.Time, err error)
 }
 
 type LocalSaslPlain struct {
 	localAuthenticator apis.PasswordAuthenticator
+	pseudonymizer      *Pseudonymizer
 }
 
 func NewLocalSaslPlain(localAuthenticator apis.PasswordAuthenticator) *LocalSaslPlain {
@@ -32,34 +42,35 @@ func NewLocalSaslPlain(localAuthenticator apis.PasswordAuthenticator) *LocalSasl
 }
 
 // implements LocalSaslAuth
-func (p *LocalSaslPlain) doLocalAuth(saslAuthBytes []byte) (err error) {
+func (p *LocalSaslPlain) doLocalAuth(saslAuthBytes []byte) (principal string, expiry time.Time, err error) {
 	tokens := strings.Split(string(saslAuthBytes), "\x00")
 	if len(tokens) != 3 {
-		return fmt.Errorf("invalid SASL/PLAIN request: expected 3 tokens, got %d", len(tokens))
+		return "", time.Time{}, fmt.Errorf("invalid SASL/PLAIN request: expected 3 tokens, got %d", len(tokens))
 	}
 	if p.localAuthenticator == nil {
-		return protocol.PacketDecodingError{Info: "Listener authenticator is not set"}
+		return "", time.Time{}, protocol.PacketDecodingError{Info: "Listener authenticator is not set"}
 	}
 
 	// logrus.Infof("user: %s , password: %s", tokens[1], tokens[2])
 	ok, status, err := p.localAuthenticator.Authenticate(tokens[1], tokens[2])
 	if err != nil {
 		proxyLocalAuthTotal.WithLabelValues("error", "1").Inc()
-		return err
+		return "", time.Time{}, err
 	}
 	proxyLocalAuthTotal.WithLabelValues(strconv.FormatBool(ok), strconv.Itoa(int(status))).Inc()
 
 	if !ok {
-		return errLocalAuthFailed{
-			user: tokens[1],
+		return "", time.Time{}, errLocalAuthFailed{
+			user: p.pseudonymizer.principal(tokens[1]),
 		}
 	}
-	return nil
+	return tokens[1], time.Time{}, nil
 }
 
 type LocalSaslOauth struct {
 	saslOAuthBearer    SaslOAuthBearer
 	tokenAuthenticator apis.TokenInfo
+	principalClaim     string
 }
 
 func NewLocalSaslOauth(tokenAuthenticator apis.TokenInfo) *LocalSaslOauth {
@@ -70,17 +81,23 @@ func NewLocalSaslOauth(tokenAuthenticator apis.TokenInfo) *LocalSaslOauth {
 }
 
 // implements LocalSaslAuth
-func (p *LocalSaslOauth) doLocalAuth(saslAuthBytes []byte) (err error) {
+func (p *LocalSaslOauth) doLocalAuth(saslAuthBytes []byte) (principal string, expiry time.Time, err error) {
 	token, _, _, err := p.saslOAuthBearer.GetClientInitialResponse(saslAuthBytes)
 	if err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 	resp, err := p.tokenAuthenticator.VerifyToken(context.Background(), apis.VerifyRequest{Token: token})
 	if err != nil {
-		return err
+		return "", time.Time{}, err
 	}
 	if !resp.Success {
-		return fmt.Errorf("local oauth verify token failed with status: %d", resp.Status)
+		return "", time.Time{}, errLocalOauthFailed{status: resp.Status}
+	}
+	// token was verified, so its exp claim can be trusted
+	expiry, _ = tokenExpiry(token)
+	if principal := verifiedPrincipal(resp, p.principalClaim); principal != "" {
+		return principal, expiry, nil
 	}
-	return nil
+	// neither the claims of the token nor the authzid chosen by the client are verified, the connection has no principal
+	return "", expiry, nil
 }
@@ -82,8 +82,11 @@ func TestLocalSaslReceiveAndSendAuthV1(t *testing.T) {
 				Password: tc.password,
 			})
 			localSasl := &LocalSasl{}
-			err = localSasl.receiveAndSendAuthV1(conn, localSaslAuth)
+			principal, _, err := localSasl.receiveAndSendAuthV1(conn, localSaslAuth)
 			a.Equal(tc.authError, err)
+			if tc.authError == nil {
+				a.Equal(tc.username, principal)
+			}
 
 			written := conn.writer.Bytes()
 			a.Equal(tc.resHex, hex.EncodeToString(written))
@@ -24,8 +24,7 @@ type SASLSCRAMAuth struct {
 	writeTimeout time.Duration
 	readTimeout  time.Duration
 
-	username      string
-	password      string
+	credentials   *SASLCredentials
 	mechanism     string
 	correlationID int32
 
@@ -47,15 +46,16 @@ func (b *SASLSCRAMAuth) sendAndReceiveSASLAuth(conn DeadlineReaderWriter) error
 		return err
 	}
 
+	username, password := b.credentials.get()
 	var scramClient *scram.Client
 	if b.mechanism == "SCRAM-SHA-256" {
-		scramClient, err = SHA256.NewClient(b.username, b.password, "")
+		scramClient, err = SHA256.NewClient(username, password, "")
 		if err != nil {
 			logrus.Debugf("Unable to make scram client for SCRAM-SHA-256: %v", err)
 			return err
 		}
 	} else if b.mechanism == "SCRAM-SHA-512" {
-		scramClient, err = SHA512.NewClient(b.username, b.password, "")
+		scramClient, err = SHA512.NewClient(username, password, "")
 		if err != nil {
 			logrus.Debugf("Unable to make scram client for SCRAM-SHA-512: %v", err)
 			return err
@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+)
+
+// scheduledApiKeys are api keys forbidden during the time window
+type scheduledApiKeys struct {
+	window  config.TimeWindow
+	apiKeys map[int16]struct{}
+}
+
+func newScheduledApiKeys(list config.ScheduledApiKeysList) []scheduledApiKeys {
+	result := make([]scheduledApiKeys, 0, len(list))
+	for _, entry := range list {
+		apiKeys := make(map[int16]struct{})
+		for _, apiKey := range entry.ApiKeys {
+			apiKeys[int16(apiKey)] = struct{}{}
+		}
+		result = append(result, scheduledApiKeys{window: entry.Window, apiKeys: apiKeys})
+	}
+	return result
+}
+
+func isScheduledForbidden(schedule []scheduledApiKeys, apiKey int16, t time.Time) bool {
+	for _, entry := range schedule {
+		if _, ok := entry.apiKeys[apiKey]; ok && entry.window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsScheduledForbidden(t *testing.T) {
+	a := assert.New(t)
+
+	var list config.ScheduledApiKeysList
+	a.Nil(list.Set("Mon-Fri 08:00-18:00 Europe/Berlin=19,20"))
+	schedule := newScheduledApiKeys(list)
+
+	// Monday 10:00 in Berlin
+	monday := time.Date(2020, 6, 1, 8, 0, 0, 0, time.UTC)
+	a.True(isScheduledForbidden(schedule, 19, monday))
+	a.True(isScheduledForbidden(schedule, 20, monday))
+	a.False(isScheduledForbidden(schedule, 0, monday))
+	a.False(isScheduledForbidden(schedule, 19, monday.Add(10*time.Hour)))
+	a.False(isScheduledForbidden(nil, 19, monday))
+}
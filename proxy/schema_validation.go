@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/pkg/libs/schemaregistry"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Confluent wire format: magic byte (0) followed by the schema id (INT32)
+	schemaMagicByte    = 0
+	schemaHeaderLength = 5
+
+	subjectNameStrategyTopic = "topic"
+
+	schemaRejectMissingMagicByte = "missing_magic_byte"
+	schemaRejectUnknownSchema    = "unknown_schema"
+	schemaRejectIncompatible     = "incompatible_schema"
+	schemaRejectRegistryError    = "registry_error"
+	schemaRejectInvalidRecords   = "invalid_records"
+	schemaRejectUnsupported      = "unsupported_compression"
+)
+
+type schemaSubjectsLookup interface {
+	Subjects(id int32) ([]string, error)
+}
+
+// SchemaValidation rejects produced partitions with record values, which do not reference a schema registered in the Schema Registry.
+// With the topic subject name strategy the schema must be registered under the subject <topic>-value. The rejected partitions
+// are removed from the produce request and the client receives the INVALID_RECORD error for them.
+type SchemaValidation struct {
+	topics              map[string]struct{}
+	registry            schemaSubjectsLookup
+	subjectNameStrategy string
+}
+
+func NewSchemaValidation(topics []string, registry *schemaregistry.Client, subjectNameStrategy string) *SchemaValidation {
+	if len(topics) == 0 {
+		return nil
+	}
+	topicSet := make(map[string]struct{}, len(topics))
+	for _, topic := range topics {
+		topicSet[topic] = struct{}{}
+	}
+	return &SchemaValidation{topics: topicSet, registry: registry, subjectNameStrategy: subjectNameStrategy}
+}
+
+func (v *SchemaValidation) enabled() bool {
+	return v != nil
+}
+
+func (v *SchemaValidation) matchTopic(topic string) bool {
+	if _, ok := v.topics[topic]; ok {
+		return true
+	}
+	_, ok := v.topics[config.AllTopics]
+	return ok
+}
+
+// validateProduceRequest checks the record values of the produce request body starting after the api key and version and returns
+// the body without the rejected partitions
+func (v *SchemaValidation) validateProduceRequest(apiVersion int16, body []byte, state *producePartitionErrorsState, log *logrus.Entry) ([]byte, error) {
+	request := &protocol.ProduceRequest{Version: apiVersion}
+	if err := protocol.Decode(body, request); err != nil {
+		return nil, err
+	}
+	var partitionErrors []protocol.ProducePartitionError
+	for _, topicData := range request.TopicData {
+		if !v.matchTopic(topicData.Topic) {
+			continue
+		}
+		for _, partitionData := range topicData.PartitionData {
+			rejection, ok := v.validateRecords(topicData.Topic, partitionData.Records).(schemaRejectionError)
+			if !ok {
+				continue
+			}
+			reason := rejection.Error()
+			log.Infof("Produce to topic %s partition %d is rejected: %s", topicData.Topic, partitionData.Partition, reason)
+			errorCode := protocol.ErrInvalidRecord
+			if rejection.reason == schemaRejectUnsupported {
+				errorCode = protocol.ErrUnsupportedCompressionType
+			}
+			partitionErrors = append(partitionErrors, protocol.ProducePartitionError{Topic: topicData.Topic, Partition: partitionData.Partition, ErrorCode: errorCode, ErrorMessage: &reason})
+		}
+	}
+	if len(partitionErrors) == 0 {
+		return body, nil
+	}
+	return rejectProducePartitions(request, partitionErrors, state)
+}
+
+func (v *SchemaValidation) validateRecords(topic string, records []byte) error {
+	// the schema ids of a batch are usually the same
+	validated := make(map[int32]struct{})
+	err := protocol.VisitRecordValues(records, func(value []byte) error {
+		if len(value) < schemaHeaderLength || value[0] != schemaMagicByte {
+			return schemaRejection(topic, schemaRejectMissingMagicByte, "record value does not start with the schema magic byte and id")
+		}
+		id := int32(binary.BigEndian.Uint32(value[1:schemaHeaderLength]))
+		if _, ok := validated[id]; ok {
+			return nil
+		}
+		if err := v.validateSchemaID(topic, id); err != nil {
+			return err
+		}
+		validated[id] = struct{}{}
+		return nil
+	})
+	if unsupported, ok := err.(protocol.ErrUnsupportedCompression); ok {
+		// compressed legacy messages
+		return schemaRejection(topic, schemaRejectUnsupported, unsupported.Error())
+	}
+	if _, ok := err.(schemaRejectionError); err != nil && !ok {
+		return schemaRejection(topic, schemaRejectInvalidRecords, err.Error())
+	}
+	return err
+}
+
+func (v *SchemaValidation) validateSchemaID(topic string, id int32) error {
+	subjects, err := v.registry.Subjects(id)
+	if err == schemaregistry.ErrSchemaNotFound {
+		return schemaRejection(topic, schemaRejectUnknownSchema, fmt.Sprintf("schema %d is not registered", id))
+	}
+	if err != nil {
+		return schemaRejection(topic, schemaRejectRegistryError, err.Error())
+	}
+	if v.subjectNameStrategy != subjectNameStrategyTopic {
+		return nil
+	}
+	subject := topic + "-value"
+	for _, s := range subjects {
+		if s == subject {
+			return nil
+		}
+	}
+	return schemaRejection(topic, schemaRejectIncompatible, fmt.Sprintf("schema %d is not registered under subject %s", id, subject))
+}
+
+type schemaRejectionError struct {
+	topic  string
+	reason string
+	msg    string
+}
+
+func (e schemaRejectionError) Error() string {
+	return fmt.Sprintf("produce request to topic %s is rejected by schema validation: %s", e.topic, e.msg)
+}
+
+func schemaRejection(topic string, reason string, msg string) error {
+	proxySchemaValidationRejectedTotal.WithLabelValues(topic, reason).Inc()
+	return schemaRejectionError{topic: topic, reason: reason, msg: msg}
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/pkg/libs/schemaregistry"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSchemaRegistry struct {
+	subjects map[int32][]string
+	err      error
+	lookups  int
+}
+
+func (r *testSchemaRegistry) Subjects(id int32) ([]string, error) {
+	r.lookups++
+	if r.err != nil {
+		return nil, r.err
+	}
+	subjects, ok := r.subjects[id]
+	if !ok {
+		return nil, schemaregistry.ErrSchemaNotFound
+	}
+	return subjects, nil
+}
+
+func schemaValue(id byte, payload string) string {
+	return string([]byte{0, 0, 0, 0, id}) + payload
+}
+
+func produceRequestBody(t *testing.T, topic string, values ...string) []byte {
+	records := make([]byte, 0)
+	for _, value := range values {
+		records = append(records, uncompressedRecordBatch(value)...)
+	}
+	return produceRequestRecords(t, topic, records)
+}
+
+func produceRequestRecords(t *testing.T, topic string, records []byte) []byte {
+	clientID := "producer"
+	body, err := protocol.Encode(&protocol.ProduceRequest{
+		Version:       3,
+		CorrelationID: 1,
+		ClientID:      &clientID,
+		Acks:          -1,
+		Timeout:       1000,
+		TopicData: []protocol.ProduceTopicData{
+			{Topic: topic, PartitionData: []protocol.ProducePartitionData{{Partition: 0, Records: records}}},
+		},
+	})
+	assert.Nil(t, err)
+	return body
+}
+
+// validate returns the request body forwarded to the broker and the errors of the partitions rejected by the schema validation
+func validate(t *testing.T, v *SchemaValidation, body []byte) ([]byte, []protocol.ProducePartitionError) {
+	state := &producePartitionErrorsState{}
+	body, err := v.validateProduceRequest(3, body, state, logrus.NewEntry(logrus.New()))
+	assert.Nil(t, err)
+	return body, state.take(1)
+}
+
+func TestSchemaValidation(t *testing.T) {
+	registry := &testSchemaRegistry{subjects: map[int32][]string{
+		1: {"orders-value"},
+		2: {"payments-value"},
+	}}
+	tests := []struct {
+		name     string
+		strategy string
+		topic    string
+		values   []string
+		err      string
+	}{
+		{name: "registered schema", strategy: "topic", topic: "orders", values: []string{schemaValue(1, "a"), schemaValue(1, "b")}},
+		{name: "not validated topic", strategy: "topic", topic: "logs", values: []string{"plain"}},
+		{name: "missing magic byte", strategy: "topic", topic: "orders", values: []string{"plain"},
+			err: "produce request to topic orders is rejected by schema validation: record value does not start with the schema magic byte and id"},
+		{name: "unknown schema", strategy: "topic", topic: "orders", values: []string{schemaValue(1, "a"), schemaValue(3, "b")},
+			err: "produce request to topic orders is rejected by schema validation: schema 3 is not registered"},
+		{name: "schema of another subject", strategy: "topic", topic: "orders", values: []string{schemaValue(2, "a")},
+			err: "produce request to topic orders is rejected by schema validation: schema 2 is not registered under subject orders-value"},
+		{name: "any subject", strategy: "any", topic: "orders", values: []string{schemaValue(2, "a")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &SchemaValidation{topics: map[string]struct{}{"orders": {}}, registry: registry, subjectNameStrategy: tt.strategy}
+			body := produceRequestBody(t, tt.topic, tt.values...)
+			forwarded, partitionErrors := validate(t, v, body)
+			if tt.err == "" {
+				assert.Empty(t, partitionErrors)
+				assert.Equal(t, body, forwarded)
+			} else {
+				assert.Len(t, partitionErrors, 1)
+				assert.Equal(t, protocol.ErrInvalidRecord, partitionErrors[0].ErrorCode)
+				assert.Equal(t, tt.err, *partitionErrors[0].ErrorMessage)
+				// the rejected partition is not forwarded
+				request := &protocol.ProduceRequest{Version: 3}
+				assert.Nil(t, protocol.Decode(forwarded, request))
+				assert.Empty(t, request.TopicData)
+			}
+		})
+	}
+}
+
+func TestSchemaValidationLookups(t *testing.T) {
+	a := assert.New(t)
+
+	registry := &testSchemaRegistry{subjects: map[int32][]string{1: {"orders-value"}}}
+	v := &SchemaValidation{topics: map[string]struct{}{"*": {}}, registry: registry, subjectNameStrategy: "topic"}
+	_, partitionErrors := validate(t, v, produceRequestBody(t, "orders", schemaValue(1, "a"), schemaValue(1, "b")))
+	a.Empty(partitionErrors)
+	// the schema id is looked up once per partition
+	a.Equal(1, registry.lookups)
+
+	registry.err = errors.New("connection refused")
+	_, partitionErrors = validate(t, v, produceRequestBody(t, "orders", schemaValue(1, "a")))
+	a.Len(partitionErrors, 1)
+	a.Equal("produce request to topic orders is rejected by schema validation: connection refused", *partitionErrors[0].ErrorMessage)
+
+	a.Nil(NewSchemaValidation(nil, nil, "topic"))
+}
+
+func TestSchemaValidationCompressedRecords(t *testing.T) {
+	a := assert.New(t)
+
+	registry := &testSchemaRegistry{subjects: map[int32][]string{1: {"orders-value"}}}
+	v := &SchemaValidation{topics: map[string]struct{}{"orders": {}}, registry: registry, subjectNameStrategy: "topic"}
+	for _, codec := range []string{"gzip", "snappy", "lz4", "zstd"} {
+		valid, _, err := protocol.TranscodeRecordBatches(uncompressedRecordBatch(schemaValue(1, "a")), 0, protocol.CompressionCodecs[codec])
+		a.Nil(err)
+		invalid, _, err := protocol.TranscodeRecordBatches(uncompressedRecordBatch("plain"), 0, protocol.CompressionCodecs[codec])
+		a.Nil(err)
+
+		_, partitionErrors := validate(t, v, produceRequestRecords(t, "orders", valid))
+		a.Empty(partitionErrors, codec)
+		_, partitionErrors = validate(t, v, produceRequestRecords(t, "orders", invalid))
+		a.Len(partitionErrors, 1, codec)
+	}
+}
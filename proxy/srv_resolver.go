@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// SRVResolver looks up the SRV records of a name and returns them with the time they may be cached
+type SRVResolver interface {
+	LookupSRV(name string) ([]*net.SRV, time.Duration, error)
+}
+
+// systemSRVResolver looks up the SRV records with the system resolver. The TTL of the records is not returned by the
+// system resolver, so they are cached for the configured time.
+type systemSRVResolver struct {
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+func (r systemSRVResolver) LookupSRV(name string) ([]*net.SRV, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, r.ttl, nil
+}
+
+// dnsServerSRVResolver queries the SRV records from the DNS server, e.g. the DNS interface of Consul, and returns the lowest
+// TTL of the records. Truncated UDP responses are queried again over TCP.
+type dnsServerSRVResolver struct {
+	server  string
+	timeout time.Duration
+}
+
+func (r dnsServerSRVResolver) LookupSRV(name string) ([]*net.SRV, time.Duration, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Intn(1 << 16)), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET}},
+	}
+	response, err := r.exchange("udp", query)
+	if err == nil && response.Truncated {
+		response, err = r.exchange("tcp", query)
+	}
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "SRV query of %s to %s", name, r.server)
+	}
+	if response.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, errors.Errorf("SRV query of %s to %s failed: %v", name, r.server, response.RCode)
+	}
+	var records []*net.SRV
+	var ttl uint32
+	for _, answer := range response.Answers {
+		srv, ok := answer.Body.(*dnsmessage.SRVResource)
+		if !ok {
+			continue
+		}
+		if len(records) == 0 || answer.Header.TTL < ttl {
+			ttl = answer.Header.TTL
+		}
+		records = append(records, &net.SRV{Target: srv.Target.String(), Port: srv.Port, Priority: srv.Priority, Weight: srv.Weight})
+	}
+	return records, time.Duration(ttl) * time.Second, nil
+}
+
+func (r dnsServerSRVResolver) exchange(network string, query dnsmessage.Message) (*dnsmessage.Message, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout(network, r.server, r.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(r.timeout)); err != nil {
+		return nil, err
+	}
+	var buf []byte
+	if network == "tcp" {
+		// messages are prefixed with the 2 bytes length over TCP
+		lengthBuf := make([]byte, 2)
+		binary.BigEndian.PutUint16(lengthBuf, uint16(len(packed)))
+		if _, err = conn.Write(append(lengthBuf, packed...)); err != nil {
+			return nil, err
+		}
+		if _, err = io.ReadFull(conn, lengthBuf); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, binary.BigEndian.Uint16(lengthBuf))
+		if _, err = io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err = conn.Write(packed); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[:n]
+	}
+	response := &dnsmessage.Message{}
+	if err = response.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if response.ID != query.ID {
+		return nil, errors.New("DNS response id does not match the query")
+	}
+	return response, nil
+}
+
+// SRVAddresses expands the srv://<name> dial addresses to the addresses of the SRV record targets. The records are cached
+// until their TTL expires. The targets are ordered by priority and within the same priority randomly by weight (RFC 2782),
+// so the connections are spread over the targets. Cached records are used after the TTL when the lookup fails.
+type SRVAddresses struct {
+	resolver SRVResolver
+
+	lock    sync.Mutex
+	entries map[string]srvEntry
+
+	nowFn  func() time.Time
+	random func(n int) int
+}
+
+type srvEntry struct {
+	records []*net.SRV
+	expires time.Time
+}
+
+func NewSRVAddresses(resolver SRVResolver) *SRVAddresses {
+	return &SRVAddresses{resolver: resolver, entries: make(map[string]srvEntry), nowFn: time.Now, random: rand.Intn}
+}
+
+// expand returns the addresses with the SRV addresses replaced by the addresses of their targets
+func (s *SRVAddresses) expand(addresses []string) []string {
+	var expanded []string
+	for _, address := range addresses {
+		if !strings.HasPrefix(address, config.SRVAddressPrefix) {
+			expanded = append(expanded, address)
+			continue
+		}
+		name := strings.TrimPrefix(address, config.SRVAddressPrefix)
+		for _, record := range s.order(s.lookup(name)) {
+			expanded = append(expanded, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))))
+		}
+	}
+	return expanded
+}
+
+func (s *SRVAddresses) lookup(name string) []*net.SRV {
+	s.lock.Lock()
+	entry, ok := s.entries[name]
+	s.lock.Unlock()
+	if ok && s.nowFn().Before(entry.expires) {
+		return entry.records
+	}
+	records, ttl, err := s.resolver.LookupSRV(name)
+	if err != nil {
+		subsystemLog(logSubsystemBroker).Warnf("SRV records of %s cannot be resolved, %d cached record(s) are used: %v", name, len(entry.records), err)
+		return entry.records
+	}
+	subsystemLog(logSubsystemBroker).Debugf("SRV records of %s resolved, cached for %v", name, ttl)
+	s.lock.Lock()
+	s.entries[name] = srvEntry{records: records, expires: s.nowFn().Add(ttl)}
+	s.lock.Unlock()
+	return records
+}
+
+// order returns the records ordered by priority and within the same priority by weighted random selection (RFC 2782).
+// Records with the target "." denote that the service is not available.
+func (s *SRVAddresses) order(records []*net.SRV) []*net.SRV {
+	ordered := make([]*net.SRV, 0, len(records))
+	for _, record := range records {
+		if record.Target != "." {
+			ordered = append(ordered, record)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Priority < ordered[j].Priority })
+	for start := 0; start < len(ordered); {
+		end := start
+		for end < len(ordered) && ordered[end].Priority == ordered[start].Priority {
+			end++
+		}
+		s.shuffleByWeight(ordered[start:end])
+		start = end
+	}
+	return ordered
+}
+
+func (s *SRVAddresses) shuffleByWeight(records []*net.SRV) {
+	sum := 0
+	for _, record := range records {
+		sum += int(record.Weight)
+	}
+	for i := range records {
+		if sum == 0 {
+			// records of weight 0 keep their order
+			return
+		}
+		selected := s.random(sum)
+		for j := i; j < len(records); j++ {
+			selected -= int(records[j].Weight)
+			if selected < 0 {
+				records[i], records[j] = records[j], records[i]
+				break
+			}
+		}
+		sum -= int(records[i].Weight)
+	}
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type testSRVResolver struct {
+	records []*net.SRV
+	ttl     time.Duration
+	err     error
+	lookups int
+}
+
+func (r *testSRVResolver) LookupSRV(name string) ([]*net.SRV, time.Duration, error) {
+	r.lookups++
+	return r.records, r.ttl, r.err
+}
+
+func TestSRVAddressesExpand(t *testing.T) {
+	a := assert.New(t)
+
+	resolver := &testSRVResolver{ttl: time.Minute, records: []*net.SRV{
+		{Target: "kafka-2.example.com.", Port: 9092, Priority: 20, Weight: 1},
+		{Target: "kafka-1.example.com.", Port: 9093, Priority: 10, Weight: 1},
+		{Target: ".", Port: 0, Priority: 5},
+	}}
+	now := time.Now()
+	addresses := NewSRVAddresses(resolver)
+	addresses.nowFn = func() time.Time { return now }
+
+	expanded := addresses.expand([]string{"srv://_kafka._tcp.example.com", "kafka-3.example.com:9092"})
+	a.Equal([]string{"kafka-1.example.com:9093", "kafka-2.example.com:9092", "kafka-3.example.com:9092"}, expanded)
+	a.Equal(1, resolver.lookups)
+
+	// the records are cached until the TTL expires
+	addresses.expand([]string{"srv://_kafka._tcp.example.com"})
+	a.Equal(1, resolver.lookups)
+	now = now.Add(time.Minute)
+	addresses.expand([]string{"srv://_kafka._tcp.example.com"})
+	a.Equal(2, resolver.lookups)
+
+	// the cached records are used when the lookup fails
+	now = now.Add(time.Minute)
+	resolver.err = errors.New("no such host")
+	expanded = addresses.expand([]string{"srv://_kafka._tcp.example.com"})
+	a.Equal([]string{"kafka-1.example.com:9093", "kafka-2.example.com:9092"}, expanded)
+	a.Empty(addresses.expand([]string{"srv://_unknown._tcp.example.com"}))
+}
+
+func TestSRVAddressesWeightedOrder(t *testing.T) {
+	a := assert.New(t)
+
+	addresses := NewSRVAddresses(&testSRVResolver{})
+	records := []*net.SRV{
+		{Target: "a", Priority: 1, Weight: 10},
+		{Target: "b", Priority: 1, Weight: 30},
+		{Target: "c", Priority: 1, Weight: 60},
+	}
+	// the random number below the running sum of the weights selects the record, the selected record is swapped to the front
+	addresses.random = func(n int) int { return n - 1 }
+	ordered := addresses.order(records)
+	a.Equal([]string{"c", "a", "b"}, []string{ordered[0].Target, ordered[1].Target, ordered[2].Target})
+
+	addresses.random = func(n int) int { return 0 }
+	ordered = addresses.order(records)
+	a.Equal([]string{"a", "b", "c"}, []string{ordered[0].Target, ordered[1].Target, ordered[2].Target})
+}
+
+func TestDNSServerSRVResolver(t *testing.T) {
+	a := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil {
+			return
+		}
+		target := dnsmessage.MustNewName("kafka-0.node.consul.")
+		answer := func(ttl uint32, port uint16) dnsmessage.Resource {
+			return dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: query.Questions[0].Name, Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET, TTL: ttl},
+				Body:   &dnsmessage.SRVResource{Priority: 1, Weight: 5, Port: port, Target: target},
+			}
+		}
+		response := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true},
+			Questions: query.Questions,
+			Answers:   []dnsmessage.Resource{answer(30, 9092), answer(10, 9093)},
+		}
+		packed, _ := response.Pack()
+		_, _ = conn.WriteTo(packed, addr)
+	}()
+
+	resolver := dnsServerSRVResolver{server: conn.LocalAddr().String(), timeout: 5 * time.Second}
+	records, ttl, err := resolver.LookupSRV("kafka.service.consul")
+	a.Nil(err)
+	a.Equal(10*time.Second, ttl, "the lowest TTL of the records")
+	a.Equal([]*net.SRV{
+		{Target: "kafka-0.node.consul.", Port: 9092, Priority: 1, Weight: 5},
+		{Target: "kafka-0.node.consul.", Port: 9093, Priority: 1, Weight: 5},
+	}, records)
+}
@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+)
+
+// Tenancy prefixes the topic names, group ids and transactional ids of the tenant connections, so tenants share a cluster
+// without client changes, e.g. the client sees the topic orders and the broker tenant-a.orders. The prefix of a connection
+// is the prefix of its principal or else of its listener, the names of principals without a prefix on listeners without a prefix
+// are not prefixed and see all topics. Topics of other tenants are removed from the responses. Only the
+// request types and versions whose names can be prefixed are advertised to and accepted from the tenants.
+type Tenancy struct {
+	listenerPrefixes  config.TenantPrefixes
+	principalPrefixes config.TenantPrefixes
+	// advertised to the tenant connections, api keys capped below 0 are not advertised
+	maxApiVersions config.MaxApiVersions
+}
+
+func NewTenancy(listenerPrefixes config.TenantPrefixes, principalPrefixes config.TenantPrefixes, maxApiVersions config.MaxApiVersions) *Tenancy {
+	if len(listenerPrefixes) == 0 && len(principalPrefixes) == 0 {
+		return nil
+	}
+	t := &Tenancy{
+		listenerPrefixes:  listenerPrefixes,
+		principalPrefixes: principalPrefixes,
+		maxApiVersions:    make(config.MaxApiVersions),
+	}
+	for apiKey := minRequestApiKey; apiKey <= maxRequestApiKey; apiKey++ {
+		maxVersion, capped := maxApiVersions[apiKey]
+		if tenantMaxVersion, ok := protocol.PrefixedNamesMaxVersions[apiKey]; ok {
+			if !capped || tenantMaxVersion < maxVersion {
+				maxVersion = tenantMaxVersion
+			}
+		} else if !protocol.PrefixedNamesSupported(apiKey, 0) {
+			maxVersion = -1
+		} else if !capped {
+			continue
+		}
+		t.maxApiVersions[apiKey] = maxVersion
+	}
+	return t
+}
+
+// connection returns the tenancy of a new connection accepted on the listener, nil if the connection cannot be a tenant
+func (t *Tenancy) connection(listenerAddress string, brokerAddress string) *tenantConnection {
+	if t == nil {
+		return nil
+	}
+	listenerPrefix, _ := t.listenerPrefixes.Prefix(listenerAddress, brokerAddress)
+	if listenerPrefix == "" && len(t.principalPrefixes) == 0 {
+		return nil
+	}
+	return &tenantConnection{tenancy: t, listenerPrefix: listenerPrefix}
+}
+
+// tenantConnection is shared by the requests and responses loops of a connection
+type tenantConnection struct {
+	tenancy        *Tenancy
+	listenerPrefix string
+	// prefix of the connection, empty if the names are not prefixed
+	prefix atomic.Value
+	// requests loop only
+	resolved bool
+}
+
+func (c *tenantConnection) enabled() bool {
+	return c != nil
+}
+
+// resolve sets the prefix of the principal or the listener. Trusted clients bypassing the policies are not prefixed.
+func (c *tenantConnection) resolve(principal string, bypass bool) {
+	prefix := c.listenerPrefix
+	if principalPrefix, ok := c.tenancy.principalPrefixes.Prefix(principal); ok && principal != "" {
+		prefix = principalPrefix
+	}
+	if bypass {
+		prefix = ""
+	}
+	c.prefix.Store(prefix)
+	c.resolved = true
+}
+
+func (c *tenantConnection) getPrefix() string {
+	if c == nil {
+		return ""
+	}
+	prefix, _ := c.prefix.Load().(string)
+	return prefix
+}
+
+// checkRequest fails if the names of the request cannot be prefixed
+func (c *tenantConnection) checkRequest(requestKeyVersion *protocol.RequestKeyVersion) error {
+	if c.getPrefix() == "" || protocol.PrefixedNamesSupported(requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion) {
+		return nil
+	}
+	return fmt.Errorf("api key %d version %d is not supported for tenants", requestKeyVersion.ApiKey, requestKeyVersion.ApiVersion)
+}
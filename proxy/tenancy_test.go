@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenancyConnection(t *testing.T) {
+	a := assert.New(t)
+
+	a.Nil(NewTenancy(nil, nil, nil))
+
+	tenancy := NewTenancy(config.TenantPrefixes{"0.0.0.0:32400": "tenant-a."}, nil, config.MaxApiVersions{apiKeyFetch: 10, apiKeyApiApiVersions: 2})
+	a.Nil(tenancy.connection("0.0.0.0:32401", "kafka-1:9092"))
+	conn := tenancy.connection("0.0.0.0:32400", "kafka-0:9092")
+	a.True(conn.enabled())
+	a.Equal("", conn.getPrefix())
+	conn.resolve("alice", false)
+	a.Equal("tenant-a.", conn.getPrefix())
+
+	// the configured caps are lowered to the versions supported for tenants, other api keys are not advertised
+	a.EqualValues(10, tenancy.maxApiVersions[apiKeyFetch])
+	a.EqualValues(8, tenancy.maxApiVersions[apiKeyProduce])
+	a.EqualValues(2, tenancy.maxApiVersions[apiKeyApiApiVersions])
+	a.EqualValues(-1, tenancy.maxApiVersions[apiKeyCreateTopics])
+	_, ok := tenancy.maxApiVersions[apiKeySaslHandshake]
+	a.False(ok)
+
+	a.Nil(conn.checkRequest(&protocol.RequestKeyVersion{ApiKey: apiKeyFetch, ApiVersion: 11}))
+	a.Nil(conn.checkRequest(&protocol.RequestKeyVersion{ApiKey: apiKeySaslHandshake, ApiVersion: 1}))
+	a.EqualError(conn.checkRequest(&protocol.RequestKeyVersion{ApiKey: apiKeyCreateTopics, ApiVersion: 4}), "api key 19 version 4 is not supported for tenants")
+	a.NotNil(conn.checkRequest(&protocol.RequestKeyVersion{ApiKey: apiKeyFetch, ApiVersion: 12}))
+
+	var nilConn *tenantConnection
+	a.False(nilConn.enabled())
+	a.Equal("", nilConn.getPrefix())
+}
+
+func TestTenancyPrincipalPrefix(t *testing.T) {
+	a := assert.New(t)
+
+	tenancy := NewTenancy(config.TenantPrefixes{"0.0.0.0:32400": "tenant-a."}, config.TenantPrefixes{"bob": "tenant-b."}, nil)
+
+	// any connection may be a principal tenant
+	conn := tenancy.connection("0.0.0.0:32401", "kafka-1:9092")
+	conn.resolve("alice", false)
+	a.Equal("", conn.getPrefix())
+	a.Nil(conn.checkRequest(&protocol.RequestKeyVersion{ApiKey: apiKeyCreateTopics, ApiVersion: 4}))
+
+	conn = tenancy.connection("0.0.0.0:32400", "kafka-0:9092")
+	conn.resolve("bob", false)
+	a.Equal("tenant-b.", conn.getPrefix())
+
+	// trusted clients are not prefixed
+	conn = tenancy.connection("0.0.0.0:32400", "kafka-0:9092")
+	conn.resolve("bob", true)
+	a.Equal("", conn.getPrefix())
+}
+
+func TestHandleProduceRequestWithTenancy(t *testing.T) {
+	a := assert.New(t)
+
+	// Produce v3 of the topic test-no-headers
+	input := "000000c2000000030000000500144b61666b614578616d706c6550726f6475636572ffff00010000753000000001000f746573742d6e6f2d6865616465727300000001000000000000007b00000000000000000000006fffffffff0231f7fe0e000000000000000001734a66bef6000001734a66bef6ffffffffffffffffffffffffffff000000017a00000010000001734a66be5f2e48656c6c6f204d6f6d203135393436383131313432303702146865616465722d6b6579186865616465722d76616c7565"
+	inputBytes, err := hex.DecodeString(input)
+	a.Nil(err)
+	expected := strings.Replace(input, "000f"+hex.EncodeToString([]byte("test-no-headers")), "0018"+hex.EncodeToString([]byte("tenant-a.test-no-headers")), 1)
+	expectedBytes, err := hex.DecodeString("000000cb" + expected[8:])
+	a.Nil(err)
+
+	tenancy := NewTenancy(config.TenantPrefixes{"0.0.0.0:32400": "tenant-a."}, nil, nil).connection("0.0.0.0:32400", "kafka-0:9092")
+	output := bytes.NewBuffer(make([]byte, 0))
+	readBuffer := bytes.NewBuffer(inputBytes)
+	src := &TestDeadlineReaderWriter{
+		reader: readBuffer,
+		writer: bytes.NewBuffer(make([]byte, 0)),
+	}
+	ctx := &RequestsLoopContext{
+		openRequestsChannel:        make(chan protocol.RequestKeyVersion, 1),
+		nextRequestHandlerChannel:  make(chan RequestHandler, 1),
+		nextResponseHandlerChannel: make(chan ResponseHandler, 1),
+		timeout:                    1 * time.Second,
+		buf:                        make([]byte, defaultRequestBufferSize),
+		localSasl:                  &LocalSasl{},
+		tenancy:                    tenancy,
+	}
+	_, err = defaultRequestHandler.handleRequest(&TestDeadlineWriter{Buffer: output}, src, ctx)
+	a.Nil(err)
+	a.Equal(expectedBytes, output.Bytes())
+	a.Empty(readBuffer.Bytes())
+}
@@ -358,6 +358,23 @@ func newTLSClientConfig(conf *config.Config) (*tls.Config, error) {
 	return cfg, nil
 }
 
+// newForwardProxyTLSConfig returns the TLS configuration of connections to https forward proxies
+func newForwardProxyTLSConfig(conf *config.Config) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if conf.ForwardProxy.CAChainCertFile != "" {
+		caCertPEMBlock, err := ioutil.ReadFile(conf.ForwardProxy.CAChainCertFile)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs := x509.NewCertPool()
+		if ok := rootCAs.AppendCertsFromPEM(caCertPEMBlock); !ok {
+			return nil, errors.New("Failed to parse forward proxy root certificate")
+		}
+		cfg.RootCAs = rootCAs
+	}
+	return cfg, nil
+}
+
 func decryptPEM(pemData []byte, password string) ([]byte, error) {
 
 	keyBlock, _ := pem.Decode(pemData)
@@ -456,3 +473,21 @@ func validateClientCert(actualClientCert *x509.Certificate, expectedCert *x509.C
 	}
 	return nil
 }
+
+// ValidateTLSConfig loads the certificates and keys referenced by the configuration without starting listeners or dialing brokers
+func ValidateTLSConfig(conf *config.Config) error {
+	if conf.Proxy.TLS.Enable {
+		if _, err := newTLSListenerConfig(conf); err != nil {
+			return errors.Wrap(err, "proxy TLS")
+		}
+	}
+	if _, err := newTLSClientConfig(conf); err != nil {
+		return errors.Wrap(err, "kafka TLS")
+	}
+	if conf.Kafka.TLS.SameClientCertEnable {
+		if _, err := parseCertificate(conf.Kafka.TLS.ClientCertFile); err != nil {
+			return errors.Wrap(err, "kafka TLS client certificate")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	apiKeyCreateTopics            = int16(19)
+	apiKeyDeleteTopics            = int16(20)
+	apiKeyAlterConfigs            = int16(33)
+	apiKeyCreatePartitions        = int16(37)
+	apiKeyIncrementalAlterConfigs = int16(44)
+)
+
+// TopicPolicy enforces guardrails on created and deleted topics. Topics violating the policy are removed from the request
+// and the client receives the POLICY_VIOLATION error for them. The requests which would change existing topics past the
+// policy are forbidden: CreatePartitions with a maximal number of partitions and AlterConfigs and IncrementalAlterConfigs
+// with required configs.
+type TopicPolicy struct {
+	namePatterns            []*regexp.Regexp
+	minReplicationFactor    int
+	maxPartitions           int
+	requiredConfigs         []requiredConfig
+	deleteProtectedPatterns []*regexp.Regexp
+}
+
+type requiredConfig struct {
+	name string
+	// nil if any value is accepted
+	value *string
+}
+
+func NewTopicPolicy(namePatterns []string, minReplicationFactor int, maxPartitions int, requiredConfigs []string, deleteProtectedPatterns []string) (*TopicPolicy, error) {
+	if len(namePatterns) == 0 && minReplicationFactor <= 0 && maxPartitions <= 0 && len(requiredConfigs) == 0 && len(deleteProtectedPatterns) == 0 {
+		return nil, nil
+	}
+	policy := &TopicPolicy{
+		minReplicationFactor: minReplicationFactor,
+		maxPartitions:        maxPartitions,
+	}
+	var err error
+	if policy.namePatterns, err = compilePatterns(namePatterns); err != nil {
+		return nil, err
+	}
+	if policy.deleteProtectedPatterns, err = compilePatterns(deleteProtectedPatterns); err != nil {
+		return nil, err
+	}
+	for _, config := range requiredConfigs {
+		if pos := strings.Index(config, "="); pos != -1 {
+			value := config[pos+1:]
+			policy.requiredConfigs = append(policy.requiredConfigs, requiredConfig{name: config[:pos], value: &value})
+		} else {
+			policy.requiredConfigs = append(policy.requiredConfigs, requiredConfig{name: config})
+		}
+	}
+	return policy, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic pattern %s: %v", pattern, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *TopicPolicy) enabled() bool {
+	return p != nil
+}
+
+// checkRequest returns an error if the request could change existing topics past the policy
+func (p *TopicPolicy) checkRequest(apiKey int16) error {
+	if p == nil {
+		return nil
+	}
+	var operation string
+	switch {
+	case apiKey == apiKeyCreatePartitions && p.maxPartitions > 0:
+		operation = "create-partitions"
+	case (apiKey == apiKeyAlterConfigs || apiKey == apiKeyIncrementalAlterConfigs) && len(p.requiredConfigs) != 0:
+		operation = "alter-configs"
+	default:
+		return nil
+	}
+	proxyTopicPolicyViolationsTotal.WithLabelValues(operation).Inc()
+	return fmt.Errorf("api key %d is forbidden by the topic policy", apiKey)
+}
+
+// createViolation returns the reason why the topic must not be created or an empty string
+func (p *TopicPolicy) createViolation(topic *protocol.CreatableTopic) string {
+	if len(p.namePatterns) != 0 && !matchAny(p.namePatterns, topic.Name) {
+		return fmt.Sprintf("topic name %s does not match the allowed patterns", topic.Name)
+	}
+	numPartitions, replicationFactor := int(topic.NumPartitions), int(topic.ReplicationFactor)
+	if len(topic.Assignments) != 0 {
+		numPartitions, replicationFactor = len(topic.Assignments), len(topic.Assignments[0].BrokerIDs)
+	}
+	if p.minReplicationFactor > 0 {
+		if replicationFactor < 0 {
+			return fmt.Sprintf("replication factor must be set, minimum is %d", p.minReplicationFactor)
+		}
+		if replicationFactor < p.minReplicationFactor {
+			return fmt.Sprintf("replication factor %d is lower than the minimum %d", replicationFactor, p.minReplicationFactor)
+		}
+	}
+	// the broker default is accepted for the number of partitions
+	if p.maxPartitions > 0 && numPartitions > p.maxPartitions {
+		return fmt.Sprintf("number of partitions %d is greater than the maximum %d", numPartitions, p.maxPartitions)
+	}
+	for _, required := range p.requiredConfigs {
+		var value *string
+		found := false
+		for _, config := range topic.Configs {
+			if config.Name == required.name {
+				value, found = config.Value, true
+			}
+		}
+		if !found {
+			return fmt.Sprintf("config %s is required", required.name)
+		}
+		if required.value != nil && (value == nil || *value != *required.value) {
+			return fmt.Sprintf("config %s must be %s", required.name, *required.value)
+		}
+	}
+	return ""
+}
+
+// deleteViolation returns the reason why the topic must not be deleted or an empty string
+func (p *TopicPolicy) deleteViolation(topic *protocol.DeleteTopicState) string {
+	if len(p.deleteProtectedPatterns) == 0 {
+		return ""
+	}
+	if topic.Name == nil {
+		return "topic must be deleted by name"
+	}
+	if matchAny(p.deleteProtectedPatterns, *topic.Name) {
+		return fmt.Sprintf("topic %s is protected from deletion", *topic.Name)
+	}
+	return ""
+}
+
+// applyCreateTopics removes the topics violating the policy from the create topics request body starting after the api key and version
+func (p *TopicPolicy) applyCreateTopics(apiVersion int16, body []byte, state *topicPolicyState) ([]byte, error) {
+	request := &protocol.CreateTopicsRequest{Version: apiVersion}
+	if err := protocol.Decode(body, request); err != nil {
+		return nil, err
+	}
+	allowed := make([]protocol.CreatableTopic, 0, len(request.Topics))
+	var topicErrors []protocol.TopicError
+	for i := range request.Topics {
+		topic := &request.Topics[i]
+		if reason := p.createViolation(topic); reason != "" {
+			topicErrors = append(topicErrors, policyViolation(topic.Name, reason))
+			continue
+		}
+		allowed = append(allowed, *topic)
+	}
+	if len(topicErrors) == 0 {
+		return body, nil
+	}
+	p.logViolations("create", topicErrors)
+	state.put(request.CorrelationID, topicErrors)
+	request.Topics = allowed
+	return protocol.Encode(request)
+}
+
+// applyDeleteTopics removes the topics violating the policy from the delete topics request body starting after the api key and version
+func (p *TopicPolicy) applyDeleteTopics(apiVersion int16, body []byte, state *topicPolicyState) ([]byte, error) {
+	request := &protocol.DeleteTopicsRequest{Version: apiVersion}
+	if err := protocol.Decode(body, request); err != nil {
+		return nil, err
+	}
+	allowed := make([]protocol.DeleteTopicState, 0, len(request.Topics))
+	var topicErrors []protocol.TopicError
+	for i := range request.Topics {
+		topic := &request.Topics[i]
+		if reason := p.deleteViolation(topic); reason != "" {
+			topicError := policyViolation("", reason)
+			topicError.Name, topicError.TopicID = topic.Name, topic.TopicID
+			topicErrors = append(topicErrors, topicError)
+			continue
+		}
+		allowed = append(allowed, *topic)
+	}
+	if len(topicErrors) == 0 {
+		return body, nil
+	}
+	p.logViolations("delete", topicErrors)
+	state.put(request.CorrelationID, topicErrors)
+	request.Topics = allowed
+	return protocol.Encode(request)
+}
+
+func (p *TopicPolicy) logViolations(operation string, topicErrors []protocol.TopicError) {
+	for _, topicError := range topicErrors {
+		proxyTopicPolicyViolationsTotal.WithLabelValues(operation).Inc()
+		logrus.Infof("Topic %s violates the topic policy: %s", operation, *topicError.ErrorMessage)
+	}
+}
+
+func policyViolation(name string, reason string) protocol.TopicError {
+	return protocol.TopicError{Name: &name, ErrorCode: protocol.ErrPolicyViolation, ErrorMessage: &reason}
+}
+
+// topicPolicyState is shared by the requests and responses loops of a connection
+type topicPolicyState struct {
+	mu sync.Mutex
+	// correlation id to the errors of the topics removed from the request
+	topicErrors map[int32][]protocol.TopicError
+}
+
+func (s *topicPolicyState) put(correlationID int32, topicErrors []protocol.TopicError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.topicErrors == nil {
+		s.topicErrors = make(map[int32][]protocol.TopicError)
+	}
+	s.topicErrors[correlationID] = topicErrors
+}
+
+func (s *topicPolicyState) take(correlationID int32) []protocol.TopicError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	topicErrors := s.topicErrors[correlationID]
+	delete(s.topicErrors, correlationID)
+	return topicErrors
+}
@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicPolicyCreateViolation(t *testing.T) {
+	policy, err := NewTopicPolicy([]string{"team-[a-z]+\\..+"}, 3, 12, []string{"min.insync.replicas", "cleanup.policy=delete"}, nil)
+	assert.Nil(t, err)
+
+	two, deletePolicy, compact := "2", "delete", "compact"
+	validConfigs := []protocol.CreatableTopicConfig{{Name: "min.insync.replicas", Value: &two}, {Name: "cleanup.policy", Value: &deletePolicy}}
+	tests := []struct {
+		name   string
+		topic  protocol.CreatableTopic
+		reason string
+	}{
+		{name: "valid", topic: protocol.CreatableTopic{Name: "team-a.orders", NumPartitions: 6, ReplicationFactor: 3, Configs: validConfigs}},
+		{name: "default partitions", topic: protocol.CreatableTopic{Name: "team-a.orders", NumPartitions: -1, ReplicationFactor: 3, Configs: validConfigs}},
+		{name: "name", topic: protocol.CreatableTopic{Name: "orders", NumPartitions: 6, ReplicationFactor: 3, Configs: validConfigs},
+			reason: "topic name orders does not match the allowed patterns"},
+		{name: "replication factor", topic: protocol.CreatableTopic{Name: "team-a.orders", NumPartitions: 6, ReplicationFactor: 1, Configs: validConfigs},
+			reason: "replication factor 1 is lower than the minimum 3"},
+		{name: "default replication factor", topic: protocol.CreatableTopic{Name: "team-a.orders", NumPartitions: 6, ReplicationFactor: -1, Configs: validConfigs},
+			reason: "replication factor must be set, minimum is 3"},
+		{name: "assignments", topic: protocol.CreatableTopic{Name: "team-a.orders", NumPartitions: -1, ReplicationFactor: -1, Configs: validConfigs,
+			Assignments: []protocol.CreatableReplicaAssignment{{PartitionIndex: 0, BrokerIDs: []int32{1, 2}}}},
+			reason: "replication factor 2 is lower than the minimum 3"},
+		{name: "partitions", topic: protocol.CreatableTopic{Name: "team-a.orders", NumPartitions: 13, ReplicationFactor: 3, Configs: validConfigs},
+			reason: "number of partitions 13 is greater than the maximum 12"},
+		{name: "missing config", topic: protocol.CreatableTopic{Name: "team-a.orders", NumPartitions: 6, ReplicationFactor: 3, Configs: validConfigs[1:]},
+			reason: "config min.insync.replicas is required"},
+		{name: "config value", topic: protocol.CreatableTopic{Name: "team-a.orders", NumPartitions: 6, ReplicationFactor: 3,
+			Configs: []protocol.CreatableTopicConfig{validConfigs[0], {Name: "cleanup.policy", Value: &compact}}},
+			reason: "config cleanup.policy must be delete"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.reason, policy.createViolation(&tt.topic))
+		})
+	}
+}
+
+func TestTopicPolicyApplyCreateTopics(t *testing.T) {
+	a := assert.New(t)
+
+	policy, err := NewTopicPolicy(nil, 0, 10, nil, nil)
+	a.Nil(err)
+	state := &topicPolicyState{}
+
+	clientID := "admin"
+	request := &protocol.CreateTopicsRequest{
+		Version:       5,
+		CorrelationID: 7,
+		ClientID:      &clientID,
+		Topics: []protocol.CreatableTopic{
+			{Name: "small", NumPartitions: 3, ReplicationFactor: 3},
+			{Name: "large", NumPartitions: 100, ReplicationFactor: 3},
+		},
+		TimeoutMs: 1000,
+	}
+	body, err := protocol.Encode(request)
+	a.Nil(err)
+
+	filtered, err := policy.applyCreateTopics(5, body, state)
+	a.Nil(err)
+	decoded := &protocol.CreateTopicsRequest{Version: 5}
+	a.Nil(protocol.Decode(filtered, decoded))
+	a.Len(decoded.Topics, 1)
+	a.Equal("small", decoded.Topics[0].Name)
+
+	topicErrors := state.take(7)
+	a.Len(topicErrors, 1)
+	a.Equal("large", *topicErrors[0].Name)
+	a.Equal(protocol.ErrPolicyViolation, topicErrors[0].ErrorCode)
+	a.Equal("number of partitions 100 is greater than the maximum 10", *topicErrors[0].ErrorMessage)
+	a.Nil(state.take(7))
+
+	// unchanged request
+	request.Topics = request.Topics[:1]
+	body, err = protocol.Encode(request)
+	a.Nil(err)
+	filtered, err = policy.applyCreateTopics(5, body, state)
+	a.Nil(err)
+	a.Equal(body, filtered)
+	a.Nil(state.take(7))
+}
+
+func TestTopicPolicyApplyDeleteTopics(t *testing.T) {
+	a := assert.New(t)
+
+	policy, err := NewTopicPolicy(nil, 0, 0, nil, []string{"__.*", "prod\\..*"})
+	a.Nil(err)
+	state := &topicPolicyState{}
+
+	orders, prodOrders := "orders", "prod.orders"
+	body, err := protocol.Encode(&protocol.DeleteTopicsRequest{
+		Version:       6,
+		CorrelationID: 9,
+		Topics:        []protocol.DeleteTopicState{{Name: &orders}, {Name: &prodOrders}, {TopicID: [2]int64{1, 2}}},
+		TimeoutMs:     1000,
+	})
+	a.Nil(err)
+
+	filtered, err := policy.applyDeleteTopics(6, body, state)
+	a.Nil(err)
+	decoded := &protocol.DeleteTopicsRequest{Version: 6}
+	a.Nil(protocol.Decode(filtered, decoded))
+	a.Len(decoded.Topics, 1)
+	a.Equal("orders", *decoded.Topics[0].Name)
+
+	topicErrors := state.take(9)
+	a.Len(topicErrors, 2)
+	a.Equal("topic prod.orders is protected from deletion", *topicErrors[0].ErrorMessage)
+	a.Nil(topicErrors[1].Name)
+	a.Equal([2]int64{1, 2}, topicErrors[1].TopicID)
+	a.Equal("topic must be deleted by name", *topicErrors[1].ErrorMessage)
+}
+
+func TestNewTopicPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	policy, err := NewTopicPolicy(nil, 0, 0, nil, nil)
+	a.Nil(err)
+	a.False(policy.enabled())
+
+	_, err = NewTopicPolicy([]string{"("}, 0, 0, nil, nil)
+	a.NotNil(err)
+}
+
+func TestTopicPolicyCheckRequest(t *testing.T) {
+	a := assert.New(t)
+
+	policy, err := NewTopicPolicy([]string{"app-.*"}, 0, 0, nil, nil)
+	a.Nil(err)
+	a.Nil(policy.checkRequest(apiKeyCreatePartitions))
+	a.Nil(policy.checkRequest(apiKeyAlterConfigs))
+
+	policy, err = NewTopicPolicy(nil, 0, 12, []string{"retention.ms"}, nil)
+	a.Nil(err)
+	a.EqualError(policy.checkRequest(apiKeyCreatePartitions), "api key 37 is forbidden by the topic policy")
+	a.EqualError(policy.checkRequest(apiKeyAlterConfigs), "api key 33 is forbidden by the topic policy")
+	a.EqualError(policy.checkRequest(apiKeyIncrementalAlterConfigs), "api key 44 is forbidden by the topic policy")
+	a.Nil(policy.checkRequest(apiKeyCreateTopics))
+
+	a.Nil((*TopicPolicy)(nil).checkRequest(apiKeyCreatePartitions))
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	errTransparentNotRedirected = errors.New("connection was not redirected")
+	errTransparentUnsupported   = errors.New("transparent listener is supported on Linux only")
+)
+
+// ListenTransparent starts the listener of connections to brokers redirected by iptables REDIRECT or TPROXY. The broker address
+// of a connection is its original destination. The clients connect to the brokers directly, so broker addresses in the responses
+// are not rewritten. The connections dialed by the proxy must not be redirected e.g. by excluding the proxy user with the iptables owner match.
+func (p *Listeners) ListenTransparent(address string, tproxy bool) (net.Listener, error) {
+	l, err := listenTransparent(address, tproxy)
+	if err != nil {
+		return nil, err
+	}
+	go withRecover(func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				logrus.Infof("Error in accept on transparent listener %v: %v", address, err)
+				l.Close()
+				return
+			}
+			tcpConn, ok := c.(*net.TCPConn)
+			if !ok {
+				_ = c.Close()
+				continue
+			}
+			brokerAddress, err := transparentBrokerAddress(tcpConn, l.Addr(), tproxy)
+			if err != nil {
+				logrus.Warnf("Closing connection from %s on transparent listener %v: %v", p.pseudonymizer.address(c.RemoteAddr()), address, err)
+				_ = c.Close()
+				continue
+			}
+			if err := p.tcpConnOptions.setTCPConnOptions(tcpConn); err != nil {
+				logrus.Infof("WARNING: Error while setting TCP options for accepted connection on transparent listener %v: %v", address, err)
+			}
+			if p.serverTLSConfig != nil {
+				c = tls.Server(c, p.serverTLSConfig(config.ListenerConfig{BrokerAddress: brokerAddress, ListenerAddress: address}))
+			}
+			p.connSrc <- Conn{BrokerAddress: brokerAddress, ListenerAddress: address, LocalConnection: c, Transparent: true}
+		}
+	})
+
+	logrus.Infof("Listening on %s (%s) for redirected connections", address, l.Addr().String())
+	return l, nil
+}
+
+// transparentBrokerAddress returns the original destination of the connection. Connections to the listener itself are refused,
+// as they would be dialed again by the proxy.
+func transparentBrokerAddress(conn *net.TCPConn, listenerAddr net.Addr, tproxy bool) (string, error) {
+	var dst *net.TCPAddr
+	if tproxy {
+		// the local address of connections accepted by TPROXY is the original destination
+		dst = conn.LocalAddr().(*net.TCPAddr)
+	} else {
+		var err error
+		if dst, err = originalDestination(conn); err != nil {
+			return "", err
+		}
+		if dst.String() == conn.LocalAddr().String() {
+			return "", errTransparentNotRedirected
+		}
+	}
+	if listener, ok := listenerAddr.(*net.TCPAddr); ok && dst.Port == listener.Port && (listener.IP.IsUnspecified() || listener.IP.Equal(dst.IP)) {
+		return "", errTransparentNotRedirected
+	}
+	return dst.String(), nil
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// SO_ORIGINAL_DST of linux/netfilter_ipv4.h and IP6T_SO_ORIGINAL_DST of linux/netfilter_ipv6/ip6_tables.h
+const (
+	soOriginalDst     = 80
+	ip6tSoOriginalDst = 80
+)
+
+// listenTransparent listens on the address, TPROXY requires the IP_TRANSPARENT socket option
+func listenTransparent(address string, tproxy bool) (net.Listener, error) {
+	var lc net.ListenConfig
+	if tproxy {
+		lc.Control = func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				if network == "tcp6" {
+					sockErr = unix.SetsockoptInt(int(fd), unix.SOL_IPV6, unix.IPV6_TRANSPARENT, 1)
+				} else {
+					sockErr = unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_TRANSPARENT, 1)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		}
+	}
+	return lc.Listen(context.Background(), "tcp", address)
+}
+
+// originalDestination returns the destination of a connection redirected by iptables REDIRECT
+func originalDestination(conn *net.TCPConn) (*net.TCPAddr, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var dst *net.TCPAddr
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		if local, ok := conn.LocalAddr().(*net.TCPAddr); ok && local.IP.To4() == nil {
+			// struct sockaddr_in6 is returned in the address of struct ip6_mtuinfo
+			var info *unix.IPv6MTUInfo
+			if info, sockErr = unix.GetsockoptIPv6MTUInfo(int(fd), unix.SOL_IPV6, ip6tSoOriginalDst); sockErr == nil {
+				port := (*[2]byte)(unsafe.Pointer(&info.Addr.Port))
+				dst = &net.TCPAddr{IP: net.IP(append([]byte{}, info.Addr.Addr[:]...)), Port: int(binary.BigEndian.Uint16(port[:]))}
+			}
+			return
+		}
+		// struct sockaddr_in is returned in the multicast address of struct ipv6_mreq
+		var mreq *unix.IPv6Mreq
+		if mreq, sockErr = unix.GetsockoptIPv6Mreq(int(fd), unix.SOL_IP, soOriginalDst); sockErr == nil {
+			dst = &net.TCPAddr{IP: net.IPv4(mreq.Multiaddr[4], mreq.Multiaddr[5], mreq.Multiaddr[6], mreq.Multiaddr[7]), Port: int(binary.BigEndian.Uint16(mreq.Multiaddr[2:4]))}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+	return dst, nil
+}
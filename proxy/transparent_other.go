@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package proxy
+
+import "net"
+
+func listenTransparent(address string, tproxy bool) (net.Listener, error) {
+	return nil, errTransparentUnsupported
+}
+
+func originalDestination(conn *net.TCPConn) (*net.TCPAddr, error) {
+	return nil, errTransparentUnsupported
+}
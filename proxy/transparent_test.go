@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func acceptedTCPConn(t *testing.T, l net.Listener) (*net.TCPConn, net.Conn) {
+	client, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	conn, err := l.Accept()
+	assert.Nil(t, err)
+	return conn.(*net.TCPConn), client
+}
+
+func TestTransparentBrokerAddressNotRedirected(t *testing.T) {
+	a := assert.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	defer l.Close()
+
+	for _, tproxy := range []bool{false, true} {
+		conn, client := acceptedTCPConn(t, l)
+		_, err = transparentBrokerAddress(conn, l.Addr(), tproxy)
+		a.NotNil(err)
+		_ = conn.Close()
+		_ = client.Close()
+	}
+}
+
+func TestTransparentBrokerAddressTProxy(t *testing.T) {
+	a := assert.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	defer l.Close()
+
+	conn, client := acceptedTCPConn(t, l)
+	defer client.Close()
+	defer conn.Close()
+
+	// the TPROXY listener port differs from the original destination port
+	listenerAddr := &net.TCPAddr{IP: net.IPv4zero, Port: l.Addr().(*net.TCPAddr).Port + 1}
+	brokerAddress, err := transparentBrokerAddress(conn, listenerAddr, true)
+	a.Nil(err)
+	a.Equal(l.Addr().String(), brokerAddress)
+}
+
+func TestListenTransparentNotRedirected(t *testing.T) {
+	a := assert.New(t)
+
+	listeners := &Listeners{connSrc: make(chan Conn, 1)}
+	l, err := listeners.ListenTransparent("127.0.0.1:0", false)
+	if err == errTransparentUnsupported {
+		t.Skip(err)
+	}
+	a.Nil(err)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	a.Nil(err)
+	defer client.Close()
+	// the connection is closed
+	_, err = client.Read(make([]byte, 1))
+	a.NotNil(err)
+	a.Len(listeners.connSrc, 0)
+}
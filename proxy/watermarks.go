@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/grepplabs/kafka-proxy/proxy/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	watermarkBuckets = 10
+
+	watermarkMaxRecords = "max-records"
+	watermarkMaxBytes   = "max-bytes"
+	watermarkMinRecords = "min-records"
+)
+
+// windowCounter counts records and bytes in a sliding window of watermarkBuckets buckets
+type windowCounter struct {
+	bucketSize time.Duration
+	epochs     [watermarkBuckets]int64
+	records    [watermarkBuckets]int64
+	bytes      [watermarkBuckets]int64
+}
+
+func newWindowCounter(window time.Duration) windowCounter {
+	bucketSize := window / watermarkBuckets
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	return windowCounter{bucketSize: bucketSize}
+}
+
+func (w *windowCounter) add(now time.Time, records int64, bytes int64) {
+	epoch := now.UnixNano() / int64(w.bucketSize)
+	i := epoch % watermarkBuckets
+	if w.epochs[i] != epoch {
+		w.epochs[i] = epoch
+		w.records[i] = 0
+		w.bytes[i] = 0
+	}
+	w.records[i] += records
+	w.bytes[i] += bytes
+}
+
+func (w *windowCounter) sum(now time.Time) (records int64, bytes int64) {
+	epoch := now.UnixNano() / int64(w.bucketSize)
+	for i := range w.epochs {
+		if w.epochs[i] > epoch-watermarkBuckets && w.epochs[i] <= epoch {
+			records += w.records[i]
+			bytes += w.bytes[i]
+		}
+	}
+	return records, bytes
+}
+
+type topicWindow struct {
+	topic     string
+	watermark config.TopicWatermark
+	counter   windowCounter
+	// tracking start, stopped traffic is reported after the first full window
+	started time.Time
+	alerts  map[string]bool
+}
+
+// topicWatermarks tracks records and bytes produced per topic and raises alerts when the watermarks are crossed
+type topicWatermarks struct {
+	mu         sync.Mutex
+	watermarks map[string]config.TopicWatermark
+	topics     map[string]*topicWindow
+	interval   time.Duration
+	// the records of spliced passthrough connections are not counted, min-records alerts are suppressed while they are open
+	passthroughConnections int
+}
+
+func newTopicWatermarks(watermarks config.TopicWatermarks, now time.Time) *topicWatermarks {
+	if len(watermarks) == 0 {
+		return nil
+	}
+	t := &topicWatermarks{
+		watermarks: make(map[string]config.TopicWatermark),
+		topics:     make(map[string]*topicWindow),
+	}
+	for _, watermark := range watermarks {
+		t.watermarks[watermark.Topic] = watermark
+		if watermark.Topic != config.AllTopics {
+			// explicitly configured topics are tracked before the first record is produced
+			t.topics[watermark.Topic] = newTopicWindow(watermark.Topic, watermark, now)
+		}
+		interval := watermark.Window / watermarkBuckets
+		if t.interval == 0 || interval < t.interval {
+			t.interval = interval
+		}
+	}
+	if t.interval < time.Second {
+		t.interval = time.Second
+	}
+	return t
+}
+
+func newTopicWindow(topic string, watermark config.TopicWatermark, now time.Time) *topicWindow {
+	return &topicWindow{
+		topic:     topic,
+		watermark: watermark,
+		counter:   newWindowCounter(watermark.Window),
+		started:   now,
+		alerts:    make(map[string]bool),
+	}
+}
+
+// copyProduceRequest forwards the rest of the produce request and counts its records per topic without buffering the request.
+// The body read so far is passed in readBytes and was already forwarded.
+func (t *topicWatermarks) copyProduceRequest(dst io.Writer, src io.Reader, apiVersion int16, readBytes []byte, remaining int64, buf []byte, log *logrus.Entry) (readErr bool, err error) {
+	rest := &io.LimitedReader{R: src, N: remaining}
+	forwarded := &forwardingReader{reader: rest, writer: dst}
+	now := time.Now()
+	countErr := protocol.CountProduceRequestRecords(io.MultiReader(bytes.NewReader(readBytes), forwarded), apiVersion, int32(int64(len(readBytes))+remaining),
+		func(topic string, records int64, size int64) {
+			t.observe(topic, records, size, now)
+		})
+	if forwarded.writeErr != nil {
+		return false, forwarded.writeErr
+	}
+	if forwarded.readErr != nil {
+		return true, forwarded.readErr
+	}
+	if countErr != nil {
+		log.Debugf("Produce request records could not be counted: %v", countErr)
+	}
+	// the rest of a request which could not be counted is forwarded unchanged
+	return myCopyN(dst, rest, rest.N, buf)
+}
+
+// passthroughOpened suppresses the min-records alerts until the passthrough connection is closed
+func (t *topicWatermarks) passthroughOpened() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.passthroughConnections++
+}
+
+func (t *topicWatermarks) passthroughClosed() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.passthroughConnections--
+}
+
+func (t *topicWatermarks) observe(topic string, records int64, bytes int64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.topics[topic]
+	if !ok {
+		watermark, ok := t.watermarks[config.AllTopics]
+		if !ok {
+			return
+		}
+		w = newTopicWindow(topic, watermark, now)
+		t.topics[topic] = w
+	}
+	w.counter.add(now, records, bytes)
+	w.evaluate(now, t.passthroughConnections > 0)
+}
+
+func (t *topicWatermarks) evaluate(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, w := range t.topics {
+		w.evaluate(now, t.passthroughConnections > 0)
+	}
+}
+
+// run evaluates the watermarks periodically, so stopped traffic is detected
+func (t *topicWatermarks) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			t.evaluate(now)
+		}
+	}
+}
+
+// evaluate raises and resolves the alerts, the min-records alert is not raised when records may not have been counted
+func (w *topicWindow) evaluate(now time.Time, uncounted bool) {
+	records, bytes := w.counter.sum(now)
+	proxyTopicWindowRecords.WithLabelValues(w.topic).Set(float64(records))
+	proxyTopicWindowBytes.WithLabelValues(w.topic).Set(float64(bytes))
+
+	watermark := w.watermark
+	w.setAlert(watermarkMaxRecords, watermark.MaxRecords > 0 && records > watermark.MaxRecords, records, watermark.MaxRecords)
+	w.setAlert(watermarkMaxBytes, watermark.MaxBytes > 0 && bytes > watermark.MaxBytes, bytes, watermark.MaxBytes)
+	fullWindow := now.Sub(w.started) >= watermark.Window
+	w.setAlert(watermarkMinRecords, watermark.MinRecords > 0 && fullWindow && !uncounted && records < watermark.MinRecords, records, watermark.MinRecords)
+}
+
+func (w *topicWindow) setAlert(name string, firing bool, value int64, threshold int64) {
+	if w.alerts[name] == firing {
+		return
+	}
+	w.alerts[name] = firing
+	fields := logrus.Fields{"topic": w.topic, "watermark": name, "window": w.watermark.Window.String(), "value": value, "threshold": threshold}
+	if firing {
+		proxyTopicWatermarkAlert.WithLabelValues(w.topic, name).Set(1)
+		proxyTopicWatermarkAlertsTotal.WithLabelValues(w.topic, name).Inc()
+		logrus.WithFields(fields).Warnf("Topic %s watermark %s alert: %d within %v, threshold %d", w.topic, name, value, w.watermark.Window, threshold)
+	} else {
+		proxyTopicWatermarkAlert.WithLabelValues(w.topic, name).Set(0)
+		logrus.WithFields(fields).Infof("Topic %s watermark %s resolved", w.topic, name)
+	}
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/kafka-proxy/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowCounter(t *testing.T) {
+	a := assert.New(t)
+
+	start := time.Unix(1000, 0)
+	counter := newWindowCounter(10 * time.Second)
+	counter.add(start, 1, 10)
+	counter.add(start.Add(5*time.Second), 2, 20)
+
+	records, bytes := counter.sum(start.Add(5 * time.Second))
+	a.EqualValues(3, records)
+	a.EqualValues(30, bytes)
+
+	records, bytes = counter.sum(start.Add(12 * time.Second))
+	a.EqualValues(2, records)
+	a.EqualValues(20, bytes)
+
+	records, _ = counter.sum(start.Add(20 * time.Second))
+	a.EqualValues(0, records)
+}
+
+func TestTopicWatermarksMaxThresholds(t *testing.T) {
+	a := assert.New(t)
+
+	start := time.Unix(1000, 0)
+	tracker := newTopicWatermarks(config.TopicWatermarks{
+		{Topic: "orders", Window: time.Minute, MaxRecords: 10, MaxBytes: 1000},
+	}, start)
+
+	tracker.observe("orders", 10, 100, start)
+	a.False(tracker.topics["orders"].alerts[watermarkMaxRecords])
+
+	tracker.observe("orders", 1, 100, start.Add(time.Second))
+	a.True(tracker.topics["orders"].alerts[watermarkMaxRecords])
+	a.False(tracker.topics["orders"].alerts[watermarkMaxBytes])
+
+	tracker.observe("orders", 0, 1000, start.Add(2*time.Second))
+	a.True(tracker.topics["orders"].alerts[watermarkMaxBytes])
+
+	// the window slides and the alerts are resolved
+	tracker.evaluate(start.Add(2 * time.Minute))
+	a.False(tracker.topics["orders"].alerts[watermarkMaxRecords])
+	a.False(tracker.topics["orders"].alerts[watermarkMaxBytes])
+
+	// topics without watermark are not tracked
+	tracker.observe("payments", 100, 100, start)
+	a.NotContains(tracker.topics, "payments")
+}
+
+func TestTopicWatermarksZeroTraffic(t *testing.T) {
+	a := assert.New(t)
+
+	start := time.Unix(1000, 0)
+	tracker := newTopicWatermarks(config.TopicWatermarks{
+		{Topic: "orders", Window: time.Minute, MinRecords: 1},
+		{Topic: config.AllTopics, Window: time.Minute, MinRecords: 1},
+	}, start)
+
+	// no alert before the first full window
+	tracker.evaluate(start.Add(30 * time.Second))
+	a.False(tracker.topics["orders"].alerts[watermarkMinRecords])
+
+	// configured topic without any traffic
+	tracker.evaluate(start.Add(time.Minute))
+	a.True(tracker.topics["orders"].alerts[watermarkMinRecords])
+
+	tracker.observe("orders", 1, 10, start.Add(time.Minute))
+	a.False(tracker.topics["orders"].alerts[watermarkMinRecords])
+
+	// wildcard topic is tracked after the first record
+	tracker.observe("payments", 1, 10, start.Add(time.Minute))
+	a.False(tracker.topics["payments"].alerts[watermarkMinRecords])
+
+	tracker.evaluate(start.Add(3 * time.Minute))
+	a.True(tracker.topics["orders"].alerts[watermarkMinRecords])
+	a.True(tracker.topics["payments"].alerts[watermarkMinRecords])
+}
+
+func TestTopicWatermarksPassthroughConnections(t *testing.T) {
+	a := assert.New(t)
+
+	start := time.Unix(1000, 0)
+	tracker := newTopicWatermarks(config.TopicWatermarks{{Topic: "orders", Window: time.Minute, MinRecords: 1}}, start)
+
+	// records of spliced connections are not counted
+	tracker.passthroughOpened()
+	tracker.evaluate(start.Add(time.Minute))
+	a.False(tracker.topics["orders"].alerts[watermarkMinRecords])
+
+	tracker.passthroughClosed()
+	tracker.evaluate(start.Add(time.Minute))
+	a.True(tracker.topics["orders"].alerts[watermarkMinRecords])
+}
+
+func TestTopicWatermarksCopyProduceRequest(t *testing.T) {
+	tt := []struct {
+		name       string
+		apiVersion int16
+		hexInput   string
+	}{
+		{name: "Produce v2, message set", apiVersion: 2,
+			hexInput: "00000086000000020000000500144b61666b614578616d706c6550726f647563657200010000753000000001000f746573742d6e6f2d68656164657273000000010000000000000041000000000000000000000035fe96cb720100000001734a61d94200000008000001734a61d8df0000001748656c6c6f204d6f6d2031353934363830373933333131",
+		},
+		{name: "Produce v8, record batch", apiVersion: 8,
+			hexInput: "000000c2000000080000000300144b61666b614578616d706c6550726f6475636572ffff00010000753000000001000f746573742d6e6f2d6865616465727300000001000000000000007b00000000000000000000006fffffffff02662a226b000000000000000001734a69dfbd000001734a69dfbdffffffffffffffffffffffffffff000000017a00000010000001734a69deba2e48656c6c6f204d6f6d203135393436383133313930393802146865616465722d6b6579186865616465722d76616c7565",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			input, err := hex.DecodeString(tc.hexInput)
+			a.Nil(err)
+
+			tracker := newTopicWatermarks(config.TopicWatermarks{{Topic: config.AllTopics, Window: time.Minute, MaxRecords: 100}}, time.Now())
+			// the first bytes of the body were already read and forwarded
+			body := input[8:]
+			dst := new(bytes.Buffer)
+			readErr, err := tracker.copyProduceRequest(dst, bytes.NewReader(body[10:]), tc.apiVersion, body[:10], int64(len(body)-10), make([]byte, 16), logrus.NewEntry(logrus.New()))
+			a.False(readErr)
+			a.Nil(err)
+			a.Equal(body[10:], dst.Bytes())
+
+			records, size := tracker.topics["test-no-headers"].counter.sum(time.Now())
+			a.EqualValues(1, records)
+			a.True(size > 0)
+		})
+	}
+}